@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// runBench runs a benchmark against the configured Chroma server and
+// embedding function, prints the report, as JSON with --json, and returns
+// the process exit code.
+func runBench(ingest *services.IngestService, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var opts services.BenchOptions
+	fs.IntVar(&opts.Docs, "docs", 200, "synthetic documents to ingest")
+	fs.IntVar(&opts.DocWords, "doc-words", 300, "words per document")
+	fs.IntVar(&opts.Queries, "queries", 200, "searches to run")
+	fs.IntVar(&opts.Concurrency, "concurrency", 4, "concurrent ingests or searches")
+	fs.IntVar(&opts.K, "k", 5, "results per search")
+	fs.Uint64Var(&opts.Seed, "seed", 1, "seed for the corpus and queries")
+	fs.BoolVar(&opts.Keep, "keep", false, "keep the scratch collection")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Ctrl-C stops the run early and still reports what finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := ingest.Bench(ctx, opts)
+	if report == nil {
+		fmt.Fprintf(out, "bench: %v\n", err)
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		fmt.Fprintf(out, "collection %s: %d docs of %d words, %d queries, concurrency %d, k %d\n\n",
+			report.Collection, opts.Docs, opts.DocWords, opts.Queries, opts.Concurrency, opts.K)
+		fmt.Fprintf(out, "%-7s  %6s  %6s  %9s  %9s  %9s  %9s  %9s\n", "phase", "ops", "errors", "ops/s", "mean ms", "p50 ms", "p95 ms", "p99 ms")
+		for _, p := range []struct {
+			name  string
+			phase services.BenchPhase
+		}{{"ingest", report.Ingest}, {"search", report.Search}} {
+			l := p.phase.LatencyMS
+			fmt.Fprintf(out, "%-7s  %6d  %6d  %9.1f  %9.1f  %9.1f  %9.1f  %9.1f\n", p.name, p.phase.Ops, p.phase.Errors, p.phase.OpsPerSec, l.Mean, l.P50, l.P95, l.P99)
+			if p.phase.FirstError != "" {
+				fmt.Fprintf(out, "         first error: %s\n", p.phase.FirstError)
+			}
+		}
+		fmt.Fprintf(out, "\n%d chunks, %.1f chunks/s\n", report.Chunks, report.ChunksPerSec)
+	}
+	if err != nil || report.Ingest.Errors > 0 || report.Search.Errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// runDoctor prints the diagnostic report, as JSON with --json, and returns
+// the process exit code: 1 when any check failed.
+func runDoctor(doctor *services.DoctorService, args []string, out io.Writer) int {
+	report := doctor.Run(context.Background())
+	if len(args) > 0 && args[0] == "--json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		for _, c := range report.Checks {
+			fmt.Fprintf(out, "%-4s  %-10s  %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+			for _, p := range c.Problems {
+				fmt.Fprintf(out, "      %-10s  - %s\n", "", p)
+			}
+		}
+		fmt.Fprintf(out, "\n%s\n", strings.ToUpper(report.Status))
+	}
+	if report.Status == services.DiagnosticFail {
+		return 1
+	}
+	return 0
+}
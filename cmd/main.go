@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"github.com/typicalfo/forge/backend/internal/db"
 	"github.com/typicalfo/forge/backend/internal/handlers"
 	"github.com/typicalfo/forge/backend/internal/logging"
@@ -16,7 +18,23 @@ import (
 	"github.com/typicalfo/forge/backend/internal/services"
 )
 
+// intentRetention is how long finished mutation intents are kept.
+const intentRetention = 7 * 24 * time.Hour
+
 func main() {
+	args, err := globalFlags(os.Args[1:])
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid arguments")
+		os.Exit(2)
+	}
+	// "service" installs or removes the OS service instead of serving
+	if len(args) > 0 && args[0] == "service" {
+		os.Exit(runService(args[1:], os.Stdout))
+	}
+	// Windows services must report to the service manager right away
+	serviceCtx, serviceReady, serviceStopped := serviceControl()
+	defer serviceStopped()
+
 	// Initialize SQLite-backed config and seed defaults
 	boot, err := initConfig()
 	if err != nil {
@@ -41,14 +59,246 @@ func main() {
 		}
 	}()
 
-	// Sanity check: Heartbeat
+	// "doctor" diagnoses the environment instead of serving
+	doctor := services.NewDoctorService(chromaDB.Client(), chromaDB, boot.ConfigStore, vals)
+	if len(args) > 0 && args[0] == "doctor" {
+		code := runDoctor(doctor, args[1:], os.Stdout)
+		_ = chromaDB.Close()
+		_ = boot.ConfigStore.Close()
+		os.Exit(code)
+	}
+
+	// Sanity check: Heartbeat. Before first-run setup the default Chroma URL
+	// may be wrong, so serve anyway and let POST /setup fix it.
+	setupService := services.NewSetupService(boot.ConfigStore, vals)
+	setupDone, err := setupService.Completed()
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Failed to read setup state")
+		os.Exit(1)
+	}
 	if err := chromaDB.Health(context.Background()); err != nil {
-		logging.GetLogger().WithError(err).Fatal("ChromaDB health check failed")
+		if setupDone {
+			logging.GetLogger().WithError(err).Fatal("ChromaDB health check failed")
+			os.Exit(1)
+		}
+		logging.GetLogger().WithError(err).Warn("ChromaDB unreachable; complete first-run setup via GET/POST /setup")
+	} else {
+		logging.GetLogger().Info("ChromaDB is healthy")
+	}
+
+	// Detect API version and tenant support up front so incompatible servers
+	// show up in logs and /health rather than as opaque request failures
+	compat := chromaDB.DetectCompatibility(context.Background())
+	compatLog := logging.GetLogger().WithFields(logrus.Fields{"chroma_version": compat.ServerVersion, "chroma_api": compat.API, "tenant": compat.Tenant, "database": compat.Database})
+	for _, problem := range compat.Problems {
+		compatLog.Warn("Chroma compatibility: " + problem)
+	}
+	if !compat.Compatible {
+		compatLog.Error("Chroma server is not compatible; requests will fail until it is upgraded")
+	}
+
+	systemKeys, err := services.NewSystemKeys(vals.SystemMetadataKeys, vals.SystemMetadataNamespace)
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid system metadata configuration")
+		os.Exit(1)
+	}
+
+	namePolicy := services.NamePolicy{Mode: vals.CollectionNameMode, Case: vals.CollectionNameCase}
+	if err := namePolicy.Validate(); err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid collection naming configuration")
+		os.Exit(1)
+	}
+	if _, err := services.GetTokenizer(vals.DefaultTokenizer); err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid default tokenizer")
+		os.Exit(1)
+	}
+	chunking := services.Chunking{Strategy: vals.ChunkStrategy, Size: vals.ChunkSize, Overlap: vals.ChunkOverlap}
+	if err := chunking.Validate(); err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid chunk_strategy, chunk_size or chunk_overlap")
+		os.Exit(1)
+	}
+	trashGrace, err := time.ParseDuration(vals.TrashGrace)
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Invalid trash_grace")
+		os.Exit(1)
+	}
+
+	// Optionally mirror every write to a secondary Chroma server
+	chromaClient := chromaDB.Client()
+	var mirror *services.Mirror
+	if vals.MirrorChromaURL != "" {
+		mirrorDB, err := db.NewChromaDB(vals.MirrorChromaURL)
+		if err != nil {
+			logging.GetLogger().WithError(err).Fatal("Failed to initialize mirror Chroma DB")
+			os.Exit(1)
+		}
+		defer mirrorDB.Close()
+		mirror = services.NewMirror(chromaClient, mirrorDB.Client(), boot.ConfigStore)
+		chromaClient = mirror.Client()
 	}
-	logging.GetLogger().Info("ChromaDB is healthy")
 
 	// Initialize services (without collection - collections will be handled per request)
-	ingestService := services.NewIngestService(chromaDB.Client())
+	ingestService := services.NewIngestService(chromaClient).
+		WithSettings(boot.ConfigStore).
+		WithSystemKeys(systemKeys).
+		WithNamePolicy(namePolicy).
+		WithDefaultTokenizer(vals.DefaultTokenizer).
+		WithDefaultChunking(chunking).
+		WithTrashGrace(trashGrace).
+		WithSources(boot.ConfigStore).
+		WithIntentLog(boot.ConfigStore).
+		WithDegradation(time.Duration(vals.SearchDegradeAfterMS) * time.Millisecond).
+		WithTimeouts(services.Timeouts{
+			Query: time.Duration(vals.QueryTimeoutMS) * time.Millisecond,
+			Embed: time.Duration(vals.EmbedTimeoutMS) * time.Millisecond,
+		}).
+		WithQueryStats(boot.ConfigStore).
+		WithCollectionStats(boot.ConfigStore).
+		WithBatchTuning(services.BatchTuning{
+			MinBatch:       vals.IngestBatchMin,
+			MaxBatch:       vals.IngestBatchMax,
+			MaxConcurrency: vals.IngestConcurrencyMax,
+			Target:         time.Duration(vals.IngestBatchTargetMS) * time.Millisecond,
+		}).
+		WithExpandLimits(services.ExpandLimits{
+			MaxDepth: vals.ExpandMaxDepth,
+			MaxFiles: vals.ExpandMaxFiles,
+			MaxBytes: int64(vals.ExpandMaxMB) << 20,
+		}).
+		WithPathRoots(vals.IngestPathRoots)
+	// Serve the embedding function collections use at /v1/embeddings
+	ingestService.WithEmbedder(services.DefaultEmbedder(), vals.EmbeddingModel)
+	ingestService.WithChunkDedupe(vals.ChunkDedupe)
+	ingestService.WithSearchLimits(services.SearchLimits{DefaultK: vals.SearchDefaultK, MaxK: vals.SearchMaxK})
+	ingestService.WithShortQueryTerms(vals.ShortQueryTerms)
+	ingestService.WithFileIndex(boot.ConfigStore)
+	ingestService.WithVersionStore(boot.ConfigStore, vals.DocVersionsKeep)
+
+	// Forward internal events to an external consumer
+	if vals.EventWebhookURL != "" {
+		services.EventWebhook(ingestService.Events(), vals.EventWebhookURL, vals.EventTypes...)
+	}
+
+	// OCR for images and PDFs without a text layer
+	ocr, err := services.NewOCR(services.OCRConfig{
+		Backend:       vals.OCRBackend,
+		URL:           vals.OCRURL,
+		Languages:     vals.OCRLanguages,
+		TesseractPath: vals.TesseractPath,
+		PdftoppmPath:  vals.PdftoppmPath,
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid OCR settings; OCR disabled")
+	} else if ocr != nil {
+		ingestService.WithOCR(ocr)
+	}
+
+	// Audio transcription (whisper.cpp server or OpenAI)
+	transcriber, err := services.NewTranscriber(services.TranscriptionConfig{
+		Backend:  vals.TranscriptionBackend,
+		URL:      vals.TranscriptionURL,
+		APIKey:   vals.TranscriptionAPIKey,
+		Model:    vals.TranscriptionModel,
+		Language: vals.TranscriptionLanguage,
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid transcription settings; audio ingestion disabled")
+	} else if transcriber != nil {
+		ingestService.WithTranscriber(transcriber, time.Duration(vals.TranscriptionWindowSeconds)*time.Second)
+	}
+
+	// Original uploads for download alongside their extracted text
+	blobs, err := services.NewBlobStore(services.BlobConfig{
+		Backend: vals.BlobStore,
+		Dir:     vals.BlobDir,
+		S3: services.BucketSpec{
+			Endpoint:  vals.BlobS3Endpoint,
+			Region:    vals.BlobS3Region,
+			Bucket:    vals.BlobS3Bucket,
+			Prefix:    vals.BlobS3Prefix,
+			AccessKey: vals.BlobS3AccessKey,
+			SecretKey: vals.BlobS3SecretKey,
+		},
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid blob store settings; original files are not kept")
+	} else if blobs != nil {
+		ingestService.WithBlobStore(blobs).WithPdftoppm(vals.PdftoppmPath)
+	}
+
+	// Image captions (vision model) or multi-modal image embeddings
+	images, err := services.NewImageDescriber(services.ImageConfig{
+		Backend: vals.ImageBackend,
+		URL:     vals.ImageURL,
+		APIKey:  vals.ImageAPIKey,
+		Model:   vals.ImageModel,
+		Prompt:  vals.ImageCaptionPrompt,
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid image settings; images go through OCR only")
+	} else if images != nil {
+		ingestService.WithImages(images)
+	}
+
+	// LLM answers over search results
+	generator, err := services.NewGenerator(services.LLMConfig{
+		URL:    vals.LLMURL,
+		APIKey: vals.LLMAPIKey,
+		Model:  vals.LLMModel,
+		Models: vals.LLMModels,
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid LLM settings; /answer disabled")
+	} else if generator != nil {
+		ingestService.WithGenerator(generator, vals.LLMAnswerPrompt).WithTemplateStore(boot.ConfigStore)
+	}
+
+	// Model usage and estimated cost per collection and API key
+	prices, err := services.ParseModelPrices(vals.ModelPrices)
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid model_prices; usage is tracked at zero cost")
+	}
+	costTracker := services.NewCostTracker(boot.ConfigStore, prices, vals.EmbeddingProvider, vals.EmbeddingModel)
+	ingestService.WithCostTracker(costTracker)
+
+	// "bench" measures ingest and search against the configured backend
+	// instead of serving
+	if len(args) > 0 && args[0] == "bench" {
+		code := runBench(ingestService, args[1:], os.Stdout)
+		_ = chromaDB.Close()
+		_ = boot.ConfigStore.Close()
+		os.Exit(code)
+	}
+
+	// Optional warm-up before serving, so first requests don't pay cold-start costs
+	if vals.WarmupEnabled {
+		warmCtx, warmCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ingestService.WarmUp(warmCtx, services.WarmupOptions{
+			Collections:    append([]string{vals.CollectionName}, vals.WarmupCollections...),
+			TopCollections: vals.WarmupTopCollections,
+			ReplayQueries:  vals.WarmupReplayQueries,
+		})
+		warmCancel()
+	}
+
+	// Optional email notifications for deployments without webhook consumers
+	var notifier services.Notifier
+	if vals.SMTPHost != "" {
+		smtpNotifier, err := services.NewSMTPNotifier(services.SMTPConfig{
+			Host:     vals.SMTPHost,
+			Port:     vals.SMTPPort,
+			Username: vals.SMTPUsername,
+			Password: vals.SMTPPassword,
+			From:     vals.SMTPFrom,
+			To:       vals.SMTPTo,
+			Events:   vals.SMTPEvents,
+		}, boot.ConfigStore)
+		if err != nil {
+			logging.GetLogger().WithError(err).Warn("Invalid SMTP settings; email notifications disabled")
+		} else {
+			notifier = smtpNotifier
+		}
+	}
 
 	// Initialize handlers
 	apiHandlers := handlers.NewAPIHandlers(ingestService)
@@ -56,13 +306,128 @@ func main() {
 	// Initialize Gin router
 	r := gin.Default()
 	// Inject config store into handlers for /config endpoint
-	apiHandlers = apiHandlers.WithConfigStore(boot.ConfigStore)
+	apiHandlers = apiHandlers.WithConfigStore(boot.ConfigStore).WithChromaReporter(chromaDB)
+
+	// Signed, expiring download links; the secret is generated on first start
+	if secret, err := boot.ConfigStore.EnsureSecret("url_signing_secret"); err != nil {
+		logging.GetLogger().WithError(err).Warn("No URL signing secret; signed download links disabled")
+	} else {
+		apiHandlers = apiHandlers.WithURLSigner(services.NewURLSigner([]byte(secret)))
+	}
+
+	// Cold storage for archived collections
+	archiveStore, err := services.NewArchiveStore(services.ArchiveConfig{
+		Backend: vals.ArchiveStore,
+		Dir:     vals.ArchiveDir,
+		S3: services.BucketSpec{
+			Endpoint:  vals.ArchiveS3Endpoint,
+			Region:    vals.ArchiveS3Region,
+			Bucket:    vals.ArchiveS3Bucket,
+			Prefix:    vals.ArchiveS3Prefix,
+			AccessKey: vals.ArchiveS3AccessKey,
+			SecretKey: vals.ArchiveS3SecretKey,
+		},
+	})
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Failed to init archive store")
+		os.Exit(1)
+	}
+	archiveService := services.NewArchiveService(chromaClient, archiveStore).WithNotifier(notifier)
+	apiHandlers = apiHandlers.WithArchiveService(archiveService)
+
+	// Administrators delete, export or re-embed many collections at once
+	apiHandlers = apiHandlers.WithBulkService(services.NewBulkService(ingestService, archiveService))
+
+	// Declarative ingestion pipelines, scheduled in the background
+	pipelineService := services.NewPipelineService(ingestService, boot.ConfigStore).WithNotifier(notifier).WithPrivateNetworks(vals.FetchPrivateNetworks)
+	apiHandlers = apiHandlers.WithPipelineService(pipelineService)
+	schedCtx, schedCancel := context.WithCancel(context.Background())
+	defer schedCancel()
+	go pipelineService.RunScheduler(schedCtx, time.Minute)
+	apiHandlers = apiHandlers.WithSourceService(services.NewSourceService(ingestService, pipelineService, boot.ConfigStore))
+
+	// Background website crawls
+	apiHandlers = apiHandlers.WithCrawlService(services.NewCrawlService(ingestService).WithPrivateNetworks(vals.FetchPrivateNetworks))
+
+	// Git repositories, re-ingested incrementally from kept checkouts
+	apiHandlers = apiHandlers.WithGitService(services.NewGitService(ingestService, vals.GitDir).WithInsecureTransports(vals.GitInsecureTransports))
+
+	// S3-compatible and GCS buckets
+	apiHandlers = apiHandlers.WithBucketService(services.NewBucketService(ingestService).WithPrivateNetworks(vals.FetchPrivateNetworks))
+
+	// RSS and Atom feeds, polled in the background
+	feedService := services.NewFeedService(ingestService, boot.ConfigStore).WithPrivateNetworks(vals.FetchPrivateNetworks)
+	apiHandlers = apiHandlers.WithFeedService(feedService)
+	go feedService.RunScheduler(schedCtx, time.Minute)
+
+	// Deleted collections are purged once their grace period ends
+	go ingestService.RunTrashPurger(schedCtx, time.Minute)
+
+	// Derived collections follow changes to their sources
+	derivedService := services.NewDerivedService(chromaClient, boot.ConfigStore).WithCostTracker(costTracker)
+	derivedService.Watch(ingestService)
+
+	// Settle mutations a previous run left half-done, then drop old log entries.
+	// After a graceful restart the old process is still draining its own
+	// mutations, so they are left for it to finish (or for POST /intents/reconcile).
+	if inherited() {
+		logging.GetLogger().Info("Skipping intent reconciliation while the previous process drains")
+	} else if settled, err := ingestService.ReconcileIntents(context.Background()); err != nil {
+		logging.GetLogger().WithError(err).Warn("Failed to reconcile mutation intents")
+	} else if len(settled) > 0 {
+		logging.GetLogger().WithField("intents", len(settled)).Warn("Reconciled interrupted mutations")
+	}
+	if _, err := boot.ConfigStore.PruneIntents(time.Now().Add(-intentRetention)); err != nil {
+		logging.GetLogger().WithError(err).Warn("Failed to prune mutation intents")
+	}
+	apiHandlers = apiHandlers.WithDerivedService(derivedService)
+
+	// Warm replicas on a second Chroma server serve searches while the
+	// primary is down
+	if vals.ReplicaChromaURL != "" {
+		replicaDB, err := db.NewChromaDB(vals.ReplicaChromaURL)
+		if err != nil {
+			logging.GetLogger().WithError(err).Fatal("Failed to initialize replica Chroma DB")
+			os.Exit(1)
+		}
+		defer replicaDB.Close()
+		checkEvery, err := time.ParseDuration(vals.ReplicaCheckInterval)
+		if err != nil {
+			logging.GetLogger().WithError(err).Warn("Invalid replica_check_interval; replicas are checked after changes only")
+		}
+		replicaService := services.NewReplicaService(chromaDB.Client(), replicaDB.Client(), vals.ReplicaCollections)
+		ingestService.WithReplica(replicaService)
+		go replicaService.Run(schedCtx, checkEvery)
+		apiHandlers = apiHandlers.WithReplicaService(replicaService)
+	}
+	if mirror != nil {
+		reconcileEvery, err := time.ParseDuration(vals.MirrorReconcileInterval)
+		if err != nil {
+			logging.GetLogger().WithError(err).Warn("Invalid mirror_reconcile_interval; queued collections are reconciled at startup only")
+		}
+		go mirror.Run(schedCtx, reconcileEvery)
+		apiHandlers = apiHandlers.WithMirror(mirror)
+	}
+
+	// Periodic collection health reports
+	reportService := services.NewReportService(ingestService, boot.ConfigStore, boot.ConfigStore, vals.ReportWebhookURL).WithNotifier(notifier)
+	apiHandlers = apiHandlers.WithReportService(reportService).WithDoctorService(doctor).WithSetupService(setupService)
+	if interval, err := time.ParseDuration(vals.ReportInterval); err != nil {
+		logging.GetLogger().WithError(err).Warn("Invalid report_interval; scheduled health reports disabled")
+	} else if interval > 0 {
+		go reportService.RunScheduler(schedCtx, interval, time.Hour)
+	}
+
+	// API keys: usage tracking and soft quota alerts
+	usageService := services.NewUsageService(boot.ConfigStore, vals.QuotaWebhookURL).WithNotifier(notifier)
+	apiHandlers = apiHandlers.WithUsageService(usageService).WithCostTracker(costTracker)
 
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, "+handlers.PrincipalsHeader+", "+handlers.APIKeyHeader+", "+handlers.AdminTokenHeader+", "+handlers.RequestIDHeader)
+		c.Header("Access-Control-Expose-Headers", handlers.RequestIDHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -71,54 +436,213 @@ func main() {
 
 		c.Next()
 	})
+	r.Use(handlers.RequestLogger())
+	r.Use(handlers.PrincipalsMiddleware())
+	r.Use(handlers.APIKeyMiddleware(usageService, handlers.KeyPolicy{AdminToken: vals.AdminToken, Required: vals.RequireAPIKey}))
+	r.Use(handlers.ConcurrencyLimitMiddleware(handlers.ConcurrencyLimits{
+		Ingest: vals.RouteLimitIngest,
+		Search: vals.RouteLimitSearch,
+		Admin:  vals.RouteLimitAdmin,
+		Wait:   time.Duration(vals.RouteLimitWaitMS) * time.Millisecond,
+	}))
 
 	// Routes
 	r.GET("/health", apiHandlers.Health)
 	r.GET("/config", apiHandlers.Config)
+	r.GET("/doctor", apiHandlers.Doctor)
+	r.GET("/setup", apiHandlers.SetupStatus)
+	r.POST("/setup", apiHandlers.Setup)
+	r.GET("/mcp/config", apiHandlers.MCPConfig)
+	r.POST("/mcp/config", apiHandlers.ImportMCPConfig)
 	// Canonical endpoints
 	r.POST("/collections", apiHandlers.CreateCollection)
 	r.GET("/collections", apiHandlers.ListCollections)
 	r.DELETE("/collections/:name", apiHandlers.DeleteCollection)
+	r.POST("/collections/bulk", apiHandlers.BulkCollections)
+	r.GET("/collections/:name/tags", apiHandlers.GetCollectionTags)
+	r.PUT("/collections/:name/tags", apiHandlers.SetCollectionTags)
+	r.GET("/trash", apiHandlers.ListTrash)
+	r.POST("/trash/:name/restore", apiHandlers.RestoreCollection)
+	r.DELETE("/trash/:name", apiHandlers.PurgeCollection)
+	r.GET("/collections/:name/protection", apiHandlers.GetCollectionProtection)
+	r.PUT("/collections/:name/protection", apiHandlers.SetCollectionProtection)
+	r.GET("/collections/:name/advisor", apiHandlers.CollectionAdvisor)
+	r.GET("/collections/:name/analyzer", apiHandlers.GetCollectionAnalyzer)
+	r.PUT("/collections/:name/analyzer", apiHandlers.SetCollectionAnalyzer)
+	r.GET("/collections/:name/schema", apiHandlers.CollectionSchema)
+	r.GET("/collections/:name/facets", apiHandlers.CollectionFacets)
+	r.GET("/collections/:name/metadata", apiHandlers.GetCollectionMetadata)
+	r.PUT("/collections/:name/metadata", apiHandlers.SetCollectionMetadata)
+	r.GET("/collections/:name/tokenizer", apiHandlers.GetCollectionTokenizer)
+	r.PUT("/collections/:name/tokenizer", apiHandlers.SetCollectionTokenizer)
+	r.GET("/collections/:name/chunking", apiHandlers.GetCollectionChunking)
+	r.PUT("/collections/:name/chunking", apiHandlers.SetCollectionChunking)
+	r.GET("/collections/:name/titles", apiHandlers.GetCollectionTitleBoost)
+	r.PUT("/collections/:name/titles", apiHandlers.SetCollectionTitleBoost)
+	r.GET("/collections/:name/guardrails", apiHandlers.GetCollectionGuardrails)
+	r.PUT("/collections/:name/guardrails", apiHandlers.SetCollectionGuardrails)
+	r.POST("/collections/:name/feeds", apiHandlers.AddFeed)
+	r.GET("/collections/:name/feeds", apiHandlers.ListFeeds)
+	r.DELETE("/collections/:name/feeds/:id", apiHandlers.DeleteFeed)
+	r.POST("/collections/:name/feeds/:id/poll", apiHandlers.PollFeed)
+	r.POST("/tokens/count", apiHandlers.CountTokens)
+	r.POST("/collections/:name/archive", apiHandlers.ArchiveCollection)
+	r.PUT("/collections/:name/derive", apiHandlers.DefineDerived)
+	r.DELETE("/collections/:name/files/:md5", apiHandlers.DeleteFile)
+	r.GET("/collections/:name/files/:md5/content", apiHandlers.FileContent)
+	r.GET("/collections/:name/files/:md5/download", apiHandlers.DownloadFile)
+	r.GET("/collections/:name/files/:md5/preview", apiHandlers.FilePreview)
+	r.POST("/collections/:name/files/:md5/signed-url", apiHandlers.CreateSignedURL)
+	r.GET("/files/:collection/:file_md5", apiHandlers.ReconstructFile)
+	r.DELETE("/files/:collection/:file_md5", apiHandlers.DeleteFileByHash)
+	r.GET("/download/:token", apiHandlers.SignedDownload)
+	r.GET("/collections/:name/sources", apiHandlers.ListSources)
+	r.POST("/collections/:name/sources/:id/rerun", apiHandlers.RerunSource)
+	r.DELETE("/collections/:name/sources/:id", apiHandlers.PurgeSource)
+	r.GET("/derived", apiHandlers.ListDerived)
+	r.POST("/derived/:name/sync", apiHandlers.SyncDerived)
+	r.DELETE("/derived/:name", apiHandlers.DeleteDerived)
+	r.GET("/replicas", apiHandlers.ReplicaStatus)
+	r.POST("/replicas/:name/check", apiHandlers.CheckReplica)
+	r.GET("/mirror", apiHandlers.MirrorStatus)
+	r.POST("/mirror/reconcile", apiHandlers.ReconcileMirror)
+	// Key management needs an admin credential
+	requireAdmin := handlers.RequireAdmin()
+	r.POST("/keys", requireAdmin, apiHandlers.CreateAPIKey)
+	r.GET("/keys", requireAdmin, apiHandlers.ListAPIKeys)
+	r.DELETE("/keys/:id", requireAdmin, apiHandlers.DeleteAPIKey)
+	r.PUT("/keys/:id/scope", requireAdmin, apiHandlers.SetAPIKeyScope)
+	r.GET("/keys/:id/usage", requireAdmin, apiHandlers.APIKeyUsage)
+	r.GET("/analytics/cost", apiHandlers.CostAnalytics)
+	r.GET("/reports", apiHandlers.ListReports)
+	r.POST("/reports", apiHandlers.GenerateReport)
+	r.GET("/reports/:id", apiHandlers.GetReport)
+	r.GET("/intents", apiHandlers.ListIntents)
+	r.POST("/intents/reconcile", apiHandlers.ReconcileIntents)
+	r.GET("/archives", apiHandlers.ListArchives)
+	r.POST("/archives/:name/restore", apiHandlers.RestoreArchive)
+	r.POST("/collections/:name/snapshots", apiHandlers.CreateSnapshot)
+	r.GET("/collections/:name/snapshots", apiHandlers.ListSnapshots)
+	r.DELETE("/collections/:name/snapshots/:snapshot", apiHandlers.DeleteSnapshot)
+	r.POST("/collections/:name/snapshots/:snapshot/search", apiHandlers.SearchSnapshot)
 
 	r.GET("/docs/:collection", apiHandlers.GetCollectionDocuments)
+	r.GET("/docs/:collection/:id", apiHandlers.GetDoc)
+	r.GET("/docs/:collection/:id/versions", apiHandlers.DocVersions)
+	r.POST("/docs/:collection/:id/versions/:version/rollback", apiHandlers.RollbackDoc)
+	r.DELETE("/docs/:collection", apiHandlers.DeleteDocs)
 	r.DELETE("/docs/:collection/:id", apiHandlers.DeleteDoc)
+	r.PUT("/docs/:collection/file", apiHandlers.UpdateFileText)
 
 	r.POST("/search", apiHandlers.Search)
+	r.DELETE("/search/sessions/:id", apiHandlers.ResetSearchSession)
+	r.POST("/answer", apiHandlers.Answer)
+	r.POST("/v1/query", apiHandlers.RetrievalQuery)
+	r.POST("/v1/embeddings", apiHandlers.Embeddings)
+	r.GET("/tools", apiHandlers.ToolDefinitions)
+	r.POST("/templates", apiHandlers.SaveTemplate)
+	r.GET("/templates", apiHandlers.ListTemplates)
+	r.GET("/templates/:name", apiHandlers.GetTemplate)
+	r.DELETE("/templates/:name", apiHandlers.DeleteTemplate)
+
+	r.POST("/pipelines", apiHandlers.SavePipeline)
+	r.GET("/pipelines", apiHandlers.ListPipelines)
+	r.GET("/pipelines/:name", apiHandlers.GetPipeline)
+	r.DELETE("/pipelines/:name", apiHandlers.DeletePipeline)
+	r.POST("/pipelines/:name/run", apiHandlers.RunPipeline)
+
+	r.POST("/crawls", apiHandlers.StartCrawl)
+	r.GET("/crawls", apiHandlers.ListCrawls)
+	r.GET("/crawls/:id", apiHandlers.GetCrawl)
+	r.DELETE("/crawls/:id", apiHandlers.CancelCrawl)
 
 	// Unified ingestion endpoint (handles both file uploads and direct text input)
 	r.POST("/api/ingest", apiHandlers.Ingest)
+	r.GET("/api/ingest/batching", apiHandlers.IngestBatching)
+	r.GET("/api/ingest/timings", apiHandlers.IngestTimings)
+	r.GET("/api/ingest/supported-types", apiHandlers.SupportedTypes)
+	r.POST("/api/ingest/git", apiHandlers.IngestGit)
+	r.POST("/api/ingest/bucket", apiHandlers.IngestBucket)
+	r.POST("/api/ingest/path", apiHandlers.IngestPath)
+	r.POST("/api/ingest/notion", apiHandlers.IngestNotion)
 
 	// Initialize MCP server (without collection - will handle collections dynamically)
-	mcpServer := mcp.NewMCPServer(chromaDB.Client())
+	mcpServer := mcp.NewMCPServer(chromaDB.Client(), ingestService)
 	mcpCtx, mcpCancel := context.WithCancel(context.Background())
 	defer mcpCancel()
-	go mcpServer.Start(mcpCtx, mcpPort)
+	if vals.SinglePort {
+		// One port for API, MCP and frontend, routed by path
+		r.Any(vals.MCPHTTPPath, gin.WrapH(withoutWriteDeadline(mcpServer.HTTPHandler())))
+		r.NoRoute(handlers.StaticFrontend(vals.FrontendDir))
+		logging.GetLogger().WithFields(logrus.Fields{
+			"mcp_path":     vals.MCPHTTPPath,
+			"frontend_dir": vals.FrontendDir,
+		}).Info("Single-port mode enabled")
+	} else {
+		go mcpServer.Start(mcpCtx, mcpPort)
+	}
 
 	// Graceful shutdown handling
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(serviceCtx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	addr := ":8080"
 	if vals.BackendHTTPPort > 0 {
 		addr = fmt.Sprintf(":%d", vals.BackendHTTPPort)
 	}
-	server := &http.Server{Addr: addr, Handler: r}
+	server := newHTTPServer(addr, r, vals)
+	ln, err := listen(addr, vals.HTTPReusePort)
+	if err != nil {
+		logging.GetLogger().WithError(err).Fatal("Failed to listen")
+		os.Exit(1)
+	}
 
 	go func() {
-		logging.GetLogger().Infof("Starting backend server on %s...", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logging.GetLogger().Infof("Starting backend server on %s...", ln.Addr())
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
 			logging.GetLogger().WithError(err).Error("Server error")
 			return
 		}
 	}()
+	signalReady()
+	notifyReady(ln.Addr())
+	serviceReady()
+	go runWatchdog(ctx)
 
-	<-ctx.Done()
+	// SIGHUP hands the socket to a new process (e.g. after an upgrade or a
+	// config change), then drains this one
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	// After a handover systemd tracks the new process; this one must not
+	// report the service as stopping
+	handedOver := false
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-hup:
+			if err := restart(ln); err != nil {
+				logging.GetLogger().WithError(err).Error("Graceful restart failed; still serving")
+				continue
+			}
+			logging.GetLogger().Info("Handed over to new process")
+			handedOver = true
+			break wait
+		}
+	}
+	signal.Stop(hup)
+	if !handedOver {
+		sdNotify("STOPPING=1")
+	}
 	logging.GetLogger().Info("Shutting down backend...")
 
-	// Cancel MCP first, so server.Run exits gracefully
+	// Cancel MCP first, so server.Run exits gracefully; stop schedulers so
+	// they don't run alongside a replacement's
 	stop()
+	schedCancel()
 
-	ctxShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), time.Duration(vals.ShutdownTimeoutMS)*time.Millisecond)
 	defer cancel()
 	if err := server.Shutdown(ctxShutdown); err != nil {
 		logging.GetLogger().WithError(err).Error("Server shutdown error")
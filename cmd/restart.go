@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// restartEnv marks a process started by a graceful restart. It inherits the
+// listening socket as fd 3 and reports readiness by writing to fd 4.
+const restartEnv = "FORGE_RESTART"
+
+// restartReadyTimeout bounds how long the old process waits for its
+// replacement to start serving before giving up and keeping the socket.
+const restartReadyTimeout = time.Minute
+
+// errRestartUnsupported is returned by restart where sockets cannot be
+// handed to a child process.
+var errRestartUnsupported = errors.New("graceful restart is not supported on this platform")
+
+// inherited reports whether this process took over from a graceful restart.
+func inherited() bool { return os.Getenv(restartEnv) == "1" }
+
+// listen returns the socket handed over by the previous process or passed
+// by systemd socket activation, or binds addr, with SO_REUSEPORT when
+// reusePort is set.
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	if inherited() {
+		f := os.NewFile(3, "listener")
+		defer f.Close()
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("inherit listener: %w", err)
+		}
+		return ln, nil
+	}
+	if ln, err := systemdListener(); ln != nil || err != nil {
+		return ln, err
+	}
+	return listenAddr(addr, reusePort)
+}
+
+// signalReady tells the previous process, if any, that this one is serving
+// and it can drain and exit.
+func signalReady() {
+	if !inherited() {
+		return
+	}
+	f := os.NewFile(4, "ready")
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "net"
+
+func listenAddr(addr string, _ bool) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func restart(net.Listener) error { return errRestartUnsupported }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newHTTPServer builds the backend server with the configured timeouts,
+// header limit, keep-alive and HTTP/2 settings, so slow or stalled clients
+// cannot hold connections open indefinitely.
+func newHTTPServer(addr string, handler http.Handler, vals config.Values) *http.Server {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: ms(vals.HTTPReadHeaderTimeoutMS),
+		ReadTimeout:       ms(vals.HTTPReadTimeoutMS),
+		WriteTimeout:      ms(vals.HTTPWriteTimeoutMS),
+		IdleTimeout:       ms(vals.HTTPIdleTimeoutMS),
+		MaxHeaderBytes:    vals.HTTPMaxHeaderBytes,
+	}
+	server.SetKeepAlivesEnabled(vals.HTTPKeepAlive)
+	if vals.HTTP2Enabled {
+		h2 := &http2.Server{
+			MaxConcurrentStreams: uint32(max(vals.HTTP2MaxStreams, 0)),
+			IdleTimeout:          server.IdleTimeout,
+		}
+		server.Handler = h2c.NewHandler(handler, h2)
+	}
+	return server
+}
+
+// withoutWriteDeadline clears the server write timeout for long-lived
+// streaming responses such as MCP's event stream.
+func withoutWriteDeadline(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// serviceName names the installed Windows service and, prefixed with
+// launchdLabelPrefix, the launchd agent.
+const serviceName = "forge"
+
+// errServiceUnsupported is returned where no service manager is supported;
+// Linux deployments use a systemd unit instead.
+var errServiceUnsupported = errors.New("service install is supported on Windows and macOS; on Linux, use a systemd unit")
+
+// serviceOptions describe the service to install.
+type serviceOptions struct {
+	Name string
+	// Exe is the absolute path of the binary to run.
+	Exe string
+	// Dir is the working directory, which holds backend/config.db.
+	Dir string
+	// LogFile receives the backend's log output.
+	LogFile string
+}
+
+// runService handles "service install" and "service uninstall" and returns
+// the process exit code.
+func runService(args []string, out io.Writer) int {
+	usage := func() int {
+		fmt.Fprintln(out, "usage: forge service install [--name forge] [--log-file path] | uninstall [--name forge]")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet("service "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	name := fs.String("name", serviceName, "service name")
+	logFile := fs.String("log-file", "", "log file (default: the platform's log directory)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		var opts serviceOptions
+		if opts, err = newServiceOptions(*name, *logFile); err == nil {
+			if err = installService(opts); err == nil {
+				fmt.Fprintf(out, "Installed service %s running %s in %s, logging to %s\n", opts.Name, opts.Exe, opts.Dir, opts.LogFile)
+			}
+		}
+	case "uninstall":
+		if err = uninstallService(*name); err == nil {
+			fmt.Fprintf(out, "Uninstalled service %s\n", *name)
+		}
+	default:
+		return usage()
+	}
+	if err != nil {
+		fmt.Fprintf(out, "service %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// newServiceOptions runs the current binary from the current directory, so
+// the service uses the same config database.
+func newServiceOptions(name, logFile string) (serviceOptions, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return serviceOptions{}, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return serviceOptions{}, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return serviceOptions{}, err
+	}
+	if logFile == "" {
+		if logFile, err = defaultServiceLog(name); err != nil {
+			return serviceOptions{}, err
+		}
+	}
+	if logFile, err = filepath.Abs(logFile); err != nil {
+		return serviceOptions{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0o755); err != nil {
+		return serviceOptions{}, err
+	}
+	return serviceOptions{Name: name, Exe: exe, Dir: dir, LogFile: logFile}, nil
+}
+
+// globalFlags applies the leading --dir and --log-file flags, which
+// services whose manager can't set a working directory or capture output
+// pass on the command line, and returns the remaining arguments.
+func globalFlags(args []string) ([]string, error) {
+	for len(args) >= 2 {
+		switch args[0] {
+		case "--dir":
+			if err := os.Chdir(args[1]); err != nil {
+				return nil, err
+			}
+		case "--log-file":
+			f, err := os.OpenFile(args[1], os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			logging.Logger.SetOutput(f)
+		default:
+			return args, nil
+		}
+		args = args[2:]
+	}
+	return args, nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "context"
+
+// serviceControl is a no-op outside Windows, where service managers signal
+// the process instead.
+func serviceControl() (context.Context, func(), func()) {
+	return context.Background(), func() {}, func() {}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launchdLabelPrefix namespaces the agent's label, e.g. com.typicalfo.forge.
+const launchdLabelPrefix = "com.typicalfo."
+
+// launchdPlist runs the binary at login and restarts it if it exits, with
+// stdout and stderr appended to the log file.
+func launchdPlist(label string, opts serviceOptions) []byte {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, esc(label), esc(opts.Exe), esc(opts.Dir), esc(opts.LogFile), esc(opts.LogFile)))
+}
+
+// launchdPath is where a login agent's plist is installed.
+func launchdPath(label string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", label+".plist"), nil
+}
+
+// defaultServiceLog is ~/Library/Logs/<name>/<name>.log.
+func defaultServiceLog(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Logs", name, name+".log"), nil
+}
+
+// installService writes a launchd agent for the current user and loads it,
+// replacing one of the same name.
+func installService(opts serviceOptions) error {
+	label := launchdLabelPrefix + opts.Name
+	path, err := launchdPath(label)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// A loaded agent keeps its old definition until it is unloaded
+	_ = launchctl("bootout", launchdDomain()+"/"+label)
+	if err := os.WriteFile(path, launchdPlist(label, opts), 0o644); err != nil {
+		return err
+	}
+	return launchctl("bootstrap", launchdDomain(), path)
+}
+
+// uninstallService unloads the agent and removes its plist.
+func uninstallService(name string) error {
+	label := launchdLabelPrefix + name
+	path, err := launchdPath(label)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is not installed", path)
+	}
+	_ = launchctl("bootout", launchdDomain()+"/"+label)
+	return os.Remove(path)
+}
+
+// launchdDomain is the current user's GUI domain, where login agents run.
+func launchdDomain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !windows
+
+package main
+
+func defaultServiceLog(string) (string, error) { return "", errServiceUnsupported }
+
+func installService(serviceOptions) error { return errServiceUnsupported }
+
+func uninstallService(string) error { return errServiceUnsupported }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// defaultServiceLog is %ProgramData%\<name>\logs\<name>.log, since a
+// service has no console to log to.
+func defaultServiceLog(name string) (string, error) {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		return "", errors.New("ProgramData is not set; pass --log-file")
+	}
+	return filepath.Join(dir, name, "logs", name+".log"), nil
+}
+
+// installService registers an automatically started service that runs the
+// binary in opts.Dir, logging to opts.LogFile, restarts it when it fails,
+// and starts it. Administrator rights are required.
+func installService(opts serviceOptions) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(opts.Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists; uninstall it first", opts.Name)
+	}
+	s, err := m.CreateService(opts.Name, opts.Exe, mgr.Config{
+		DisplayName: "Forge",
+		Description: "Forge document ingestion and search backend",
+		StartType:   mgr.StartAutomatic,
+	}, "--dir", opts.Dir, "--log-file", opts.LogFile)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 5 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, uint32((24 * time.Hour).Seconds())); err != nil {
+		return err
+	}
+	return s.Start()
+}
+
+// uninstallService stops and deletes the service.
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", name, err)
+	}
+	defer s.Close()
+	_, _ = s.Control(svc.Stop)
+	return s.Delete()
+}
+
+// windowsService reports the backend's state to the service control
+// manager and turns its stop requests into a cancelled context.
+type windowsService struct {
+	cancel    context.CancelFunc
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+	doneOnce  sync.Once
+	exited    chan struct{}
+}
+
+func (w *windowsService) Execute(_ []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+	ready := w.ready
+	for {
+		select {
+		case <-ready:
+			ready = nil
+			s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+		case <-w.done:
+			s <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				w.cancel()
+			}
+		}
+	}
+}
+
+// serviceControl, when running as a Windows service, connects to the
+// service control manager. It returns a context cancelled when the manager
+// stops the service, a func reporting it running and one reporting it
+// stopped, which waits for the manager to be told.
+func serviceControl() (context.Context, func(), func()) {
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return context.Background(), func() {}, func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &windowsService{cancel: cancel, ready: make(chan struct{}), done: make(chan struct{}), exited: make(chan struct{})}
+	go func() {
+		defer close(w.exited)
+		if err := svc.Run(serviceName, w); err != nil {
+			cancel()
+		}
+	}()
+	ready := func() { w.readyOnce.Do(func() { close(w.ready) }) }
+	stopped := func() {
+		w.doneOnce.Do(func() { close(w.done) })
+		<-w.exited
+	}
+	return ctx, ready, stopped
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets in.
+const listenFDsStart = 3
+
+// systemdListener returns the first socket systemd passed by socket
+// activation (LISTEN_PID and LISTEN_FDS), or nil when there is none; any
+// further sockets are ignored. The variables are cleared so processes
+// started later, such as a graceful restart, don't claim the sockets.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+	f := os.NewFile(listenFDsStart, "systemd")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket: %w", err)
+	}
+	return ln, nil
+}
+
+// sdNotify sends a state such as "READY=1" to the service manager when it
+// asked for notifications through NOTIFY_SOCKET. Failures are only logged:
+// notifications never stop the server.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// A leading @ names an abstract socket, which net maps itself
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err == nil {
+		_, err = conn.Write([]byte(state))
+		conn.Close()
+	}
+	if err != nil {
+		logging.GetLogger().WithError(err).Warn("Failed to notify systemd")
+	}
+}
+
+// notifyReady reports the server as started. A process that took over from
+// a graceful restart also names itself the service's main process, which
+// systemd accepts with NotifyAccess=all.
+func notifyReady(addr net.Addr) {
+	state := fmt.Sprintf("READY=1\nSTATUS=Serving on %s", addr)
+	if inherited() {
+		state = fmt.Sprintf("MAINPID=%d\n%s", os.Getpid(), state)
+	}
+	sdNotify(state)
+}
+
+// watchdogInterval is how often to ping systemd's watchdog: half its
+// WATCHDOG_USEC timeout, or zero when the watchdog is off or meant for
+// another process. A graceful restart's process inherits the watchdog
+// along with the main PID.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) && !inherited() {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// runWatchdog pings systemd's watchdog until ctx is done, so a hung process
+// is restarted.
+func runWatchdog(ctx context.Context) {
+	interval := watchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sdNotify("WATCHDOG=1")
+		}
+	}
+}
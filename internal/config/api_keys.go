@@ -0,0 +1,151 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// APIKey identifies a client. Only the SHA-256 hash of the secret is stored.
+// Requests naming no collection use the key's Collection; a Restricted key
+// may not use any other.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"-"`
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	Limits     Usage     `json:"soft_limits"`
+	Collection string    `json:"collection,omitempty"`
+	Restricted bool      `json:"restricted,omitempty"`
+	Admin      bool      `json:"admin,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Usage counts a key's activity for one UTC day. As soft limits, zero
+// fields mean unlimited.
+type Usage struct {
+	Day          string `json:"day,omitempty"`
+	Searches     int    `json:"searches"`
+	IngestFiles  int    `json:"ingest_files"`
+	IngestChunks int    `json:"ingest_chunks"`
+}
+
+const apiKeyColumns = `id, name, hash, webhook_url, limit_searches, limit_ingest_files, limit_ingest_chunks, collection, restricted, admin, created_at`
+
+func (s *Store) SaveAPIKey(k APIKey) error {
+	_, err := s.db.Exec(`INSERT INTO api_keys(`+apiKeyColumns+`) VALUES(?,?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET name=excluded.name, webhook_url=excluded.webhook_url,
+			limit_searches=excluded.limit_searches, limit_ingest_files=excluded.limit_ingest_files,
+			limit_ingest_chunks=excluded.limit_ingest_chunks, collection=excluded.collection,
+			restricted=excluded.restricted, admin=excluded.admin`,
+		k.ID, k.Name, k.Hash, k.WebhookURL, k.Limits.Searches, k.Limits.IngestFiles, k.Limits.IngestChunks, k.Collection, k.Restricted, k.Admin, k.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("save api key %q: %w", k.ID, err)
+	}
+	return nil
+}
+
+func (s *Store) GetAPIKey(id string) (APIKey, error) {
+	return s.queryAPIKey(`WHERE id=?`, id)
+}
+
+// GetAPIKeyByHash resolves a presented secret's hash to its key.
+func (s *Store) GetAPIKeyByHash(hash string) (APIKey, error) {
+	return s.queryAPIKey(`WHERE hash=?`, hash)
+}
+
+func (s *Store) queryAPIKey(where string, arg any) (APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys `+where, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrNotFound
+	}
+	return k, err
+}
+
+func (s *Store) ListAPIKeys() ([]APIKey, error) {
+	rows, err := s.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAPIKey removes a key and its usage history.
+func (s *Store) DeleteAPIKey(id string) error {
+	res, err := s.db.Exec(`DELETE FROM api_keys WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	_, err = s.db.Exec(`DELETE FROM api_key_usage WHERE key_id=?`, id)
+	return err
+}
+
+func scanAPIKey(r rowScanner) (APIKey, error) {
+	var k APIKey
+	var created int64
+	err := r.Scan(&k.ID, &k.Name, &k.Hash, &k.WebhookURL, &k.Limits.Searches, &k.Limits.IngestFiles, &k.Limits.IngestChunks, &k.Collection, &k.Restricted, &k.Admin, &created)
+	if err != nil {
+		return APIKey{}, err
+	}
+	k.CreatedAt = time.Unix(created, 0)
+	return k, nil
+}
+
+// AddUsage adds delta to a key's counters for delta.Day and returns the new
+// totals for that day.
+func (s *Store) AddUsage(keyID string, delta Usage) (Usage, error) {
+	_, err := s.db.Exec(`INSERT INTO api_key_usage(key_id,day,searches,ingest_files,ingest_chunks) VALUES(?,?,?,?,?)
+		ON CONFLICT(key_id,day) DO UPDATE SET searches=searches+excluded.searches,
+			ingest_files=ingest_files+excluded.ingest_files, ingest_chunks=ingest_chunks+excluded.ingest_chunks`,
+		keyID, delta.Day, delta.Searches, delta.IngestFiles, delta.IngestChunks)
+	if err != nil {
+		return Usage{}, fmt.Errorf("record usage for %q: %w", keyID, err)
+	}
+	u := Usage{Day: delta.Day}
+	err = s.db.QueryRow(`SELECT searches, ingest_files, ingest_chunks FROM api_key_usage WHERE key_id=? AND day=?`,
+		keyID, delta.Day).Scan(&u.Searches, &u.IngestFiles, &u.IngestChunks)
+	return u, err
+}
+
+// ListUsage returns a key's daily usage since the given day (YYYY-MM-DD), newest first.
+func (s *Store) ListUsage(keyID, since string) ([]Usage, error) {
+	rows, err := s.db.Query(`SELECT day, searches, ingest_files, ingest_chunks FROM api_key_usage
+		WHERE key_id=? AND day>=? ORDER BY day DESC`, keyID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Usage
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.Day, &u.Searches, &u.IngestFiles, &u.IngestChunks); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// MarkUsageAlert records that an alert for metric fired on day, returning
+// false if it had already fired.
+func (s *Store) MarkUsageAlert(keyID, day, metric string) (bool, error) {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO api_key_alerts(key_id,day,metric) VALUES(?,?,?)`, keyID, day, metric)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
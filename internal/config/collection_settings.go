@@ -0,0 +1,42 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// GetCollectionSetting decodes a per-collection JSON setting into dst.
+// It returns false when the setting has not been stored.
+func (s *Store) GetCollectionSetting(collection, key string, dst any) (bool, error) {
+	var v string
+	err := s.db.QueryRow(`SELECT value FROM collection_settings WHERE collection=? AND key=?`, collection, key).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(v), dst); err != nil {
+		return false, fmt.Errorf("decode %s setting for %q: %w", key, collection, err)
+	}
+	return true, nil
+}
+
+// SetCollectionSetting stores value as JSON under the collection and key.
+func (s *Store) SetCollectionSetting(collection, key string, value any) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO collection_settings(collection,key,value) VALUES(?,?,?)
+		ON CONFLICT(collection,key) DO UPDATE SET value=excluded.value`, collection, key, string(b))
+	return err
+}
+
+// DeleteCollectionSettings removes every setting stored for a collection.
+func (s *Store) DeleteCollectionSettings(collection string) error {
+	_, err := s.db.Exec(`DELETE FROM collection_settings WHERE collection=?`, collection)
+	return err
+}
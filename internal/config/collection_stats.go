@@ -0,0 +1,25 @@
+package config
+
+// CollectionCounters are per-collection activity counters for one UTC day.
+type CollectionCounters struct {
+	Searches       int `json:"searches"`
+	ZeroResults    int `json:"zero_results"`
+	IngestFailures int `json:"ingest_failures"`
+}
+
+// AddCollectionCounters adds delta to a collection's counters for day (YYYY-MM-DD).
+func (s *Store) AddCollectionCounters(collection, day string, delta CollectionCounters) error {
+	_, err := s.db.Exec(`INSERT INTO collection_stats(collection,day,searches,zero_results,ingest_failures) VALUES(?,?,?,?,?)
+		ON CONFLICT(collection,day) DO UPDATE SET searches=searches+excluded.searches,
+			zero_results=zero_results+excluded.zero_results, ingest_failures=ingest_failures+excluded.ingest_failures`,
+		collection, day, delta.Searches, delta.ZeroResults, delta.IngestFailures)
+	return err
+}
+
+// CollectionCountersSince sums a collection's counters from day onwards.
+func (s *Store) CollectionCountersSince(collection, day string) (CollectionCounters, error) {
+	var c CollectionCounters
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(searches),0), COALESCE(SUM(zero_results),0), COALESCE(SUM(ingest_failures),0)
+		FROM collection_stats WHERE collection=? AND day>=?`, collection, day).Scan(&c.Searches, &c.ZeroResults, &c.IngestFailures)
+	return c, err
+}
@@ -0,0 +1,49 @@
+package config
+
+// CostUsage is the model usage attributed to one API key (empty for
+// unauthenticated calls), collection, kind ("embedding" or "generation") and
+// provider/model on one UTC day.
+type CostUsage struct {
+	Day          string  `json:"day"`
+	KeyID        string  `json:"key_id"`
+	Collection   string  `json:"collection"`
+	Kind         string  `json:"kind"`
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// AddCost adds delta to the matching day's usage row.
+func (s *Store) AddCost(delta CostUsage) error {
+	_, err := s.db.Exec(`INSERT INTO cost_usage(day,key_id,collection,kind,provider,model,calls,input_tokens,output_tokens,cost_usd)
+		VALUES(?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(day,key_id,collection,kind,provider,model) DO UPDATE SET calls=calls+excluded.calls,
+			input_tokens=input_tokens+excluded.input_tokens, output_tokens=output_tokens+excluded.output_tokens,
+			cost_usd=cost_usd+excluded.cost_usd`,
+		delta.Day, delta.KeyID, delta.Collection, delta.Kind, delta.Provider, delta.Model,
+		delta.Calls, delta.InputTokens, delta.OutputTokens, delta.CostUSD)
+	return err
+}
+
+// ListCosts returns usage rows from day (YYYY-MM-DD) onwards, newest first.
+func (s *Store) ListCosts(since string) ([]CostUsage, error) {
+	rows, err := s.db.Query(`SELECT day, key_id, collection, kind, provider, model, calls, input_tokens, output_tokens, cost_usd
+		FROM cost_usage WHERE day>=? ORDER BY day DESC`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CostUsage
+	for rows.Next() {
+		var u CostUsage
+		if err := rows.Scan(&u.Day, &u.KeyID, &u.Collection, &u.Kind, &u.Provider, &u.Model,
+			&u.Calls, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
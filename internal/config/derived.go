@@ -0,0 +1,98 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DerivedCollection defines a collection kept in sync from a filtered and
+// transformed view over a source collection.
+type DerivedCollection struct {
+	Name       string                 `json:"name"`
+	Source     string                 `json:"source"`
+	Filter     map[string]interface{} `json:"filter"`
+	Transforms []string               `json:"transforms"`
+	LastSyncAt time.Time              `json:"last_sync_at"`
+}
+
+func (s *Store) SaveDerived(d DerivedCollection) error {
+	filter, err := json.Marshal(d.Filter)
+	if err != nil {
+		return err
+	}
+	transforms, err := json.Marshal(d.Transforms)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO derived_collections(name,source,filter,transforms) VALUES(?,?,?,?)
+		ON CONFLICT(name) DO UPDATE SET source=excluded.source, filter=excluded.filter, transforms=excluded.transforms`,
+		d.Name, d.Source, string(filter), string(transforms))
+	if err != nil {
+		return fmt.Errorf("save derived collection %q: %w", d.Name, err)
+	}
+	return nil
+}
+
+func (s *Store) GetDerived(name string) (DerivedCollection, error) {
+	row := s.db.QueryRow(`SELECT name, source, filter, transforms, last_sync_at FROM derived_collections WHERE name=?`, name)
+	d, err := scanDerived(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DerivedCollection{}, ErrNotFound
+	}
+	return d, err
+}
+
+func (s *Store) ListDerived() ([]DerivedCollection, error) {
+	rows, err := s.db.Query(`SELECT name, source, filter, transforms, last_sync_at FROM derived_collections ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DerivedCollection
+	for rows.Next() {
+		d, err := scanDerived(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeleteDerived(name string) error {
+	res, err := s.db.Exec(`DELETE FROM derived_collections WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) RecordDerivedSync(name string, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE derived_collections SET last_sync_at=? WHERE name=?`, at.Unix(), name)
+	return err
+}
+
+func scanDerived(row rowScanner) (DerivedCollection, error) {
+	var d DerivedCollection
+	var filter, transforms string
+	var lastSync int64
+	if err := row.Scan(&d.Name, &d.Source, &filter, &transforms, &lastSync); err != nil {
+		return DerivedCollection{}, err
+	}
+	if err := json.Unmarshal([]byte(filter), &d.Filter); err != nil {
+		return DerivedCollection{}, fmt.Errorf("decode filter for %q: %w", d.Name, err)
+	}
+	if err := json.Unmarshal([]byte(transforms), &d.Transforms); err != nil {
+		return DerivedCollection{}, fmt.Errorf("decode transforms for %q: %w", d.Name, err)
+	}
+	if lastSync > 0 {
+		d.LastSyncAt = time.Unix(lastSync, 0)
+	}
+	return d, nil
+}
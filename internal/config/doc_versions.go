@@ -0,0 +1,84 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DocVersion is a superseded version of an ingested file, kept so the file
+// can be rolled back to it.
+type DocVersion struct {
+	Collection string    `json:"collection"`
+	File       string    `json:"file"`
+	Version    int       `json:"version"`
+	MD5        string    `json:"file_md5"`
+	Chunks     int       `json:"chunks"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Records holds the version's chunks as JSON; listings leave it empty.
+	Records string `json:"-"`
+}
+
+// AddDocVersion stores v under the file's next version number, drops all
+// but the newest keep versions, and returns the number assigned.
+func (s *Store) AddDocVersion(v DocVersion, keep int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) + 1 FROM doc_versions WHERE collection=? AND file=?`, v.Collection, v.File).Scan(&version); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO doc_versions(collection,file,version,file_md5,chunks,records,created_at) VALUES(?,?,?,?,?,?,?)`,
+		v.Collection, v.File, version, v.MD5, v.Chunks, v.Records, time.Now().Unix()); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM doc_versions WHERE collection=? AND file=? AND version<=?`, v.Collection, v.File, version-keep); err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// DocVersions lists a file's kept versions, newest first, without their
+// records.
+func (s *Store) DocVersions(collection, file string) ([]DocVersion, error) {
+	rows, err := s.db.Query(`SELECT version, file_md5, chunks, created_at FROM doc_versions WHERE collection=? AND file=? ORDER BY version DESC`, collection, file)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []DocVersion{}
+	for rows.Next() {
+		v := DocVersion{Collection: collection, File: file}
+		var created int64
+		if err := rows.Scan(&v.Version, &v.MD5, &v.Chunks, &created); err != nil {
+			return nil, err
+		}
+		v.CreatedAt = time.Unix(created, 0)
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// GetDocVersion returns one version of a file with its records, or
+// ErrNotFound.
+func (s *Store) GetDocVersion(collection, file string, version int) (DocVersion, error) {
+	v := DocVersion{Collection: collection, File: file, Version: version}
+	var created int64
+	err := s.db.QueryRow(`SELECT file_md5, chunks, records, created_at FROM doc_versions WHERE collection=? AND file=? AND version=?`,
+		collection, file, version).Scan(&v.MD5, &v.Chunks, &v.Records, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DocVersion{}, ErrNotFound
+	}
+	v.CreatedAt = time.Unix(created, 0)
+	return v, err
+}
+
+// ForgetDocVersions drops every version kept for a collection, e.g. when
+// the collection is deleted.
+func (s *Store) ForgetDocVersions(collection string) error {
+	_, err := s.db.Exec(`DELETE FROM doc_versions WHERE collection=?`, collection)
+	return err
+}
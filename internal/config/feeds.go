@@ -0,0 +1,118 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Feed is an RSS or Atom feed polled into a collection.
+type Feed struct {
+	ID           int64     `json:"id"`
+	Collection   string    `json:"collection"`
+	URL          string    `json:"url"`
+	Interval     string    `json:"interval"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastPolledAt time.Time `json:"last_polled_at"`
+	LastStatus   string    `json:"last_status,omitempty"`
+	// ETag and LastModified validate the next poll.
+	ETag         string `json:"-"`
+	LastModified string `json:"-"`
+}
+
+// ErrFeedExists is returned when a collection already polls a feed URL.
+var ErrFeedExists = errors.New("feed already registered")
+
+// CreateFeed registers a feed and returns it with its ID.
+func (s *Store) CreateFeed(collection, url, interval string) (Feed, error) {
+	now := time.Now()
+	res, err := s.db.Exec(`INSERT INTO feeds(collection,url,interval,created_at) VALUES(?,?,?,?)
+		ON CONFLICT(collection,url) DO NOTHING`, collection, url, interval, now.Unix())
+	if err != nil {
+		return Feed{}, fmt.Errorf("create feed %q: %w", url, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Feed{}, ErrFeedExists
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Feed{}, err
+	}
+	return Feed{ID: id, Collection: collection, URL: url, Interval: interval, CreatedAt: time.Unix(now.Unix(), 0)}, nil
+}
+
+const feedColumns = `id, collection, url, interval, created_at, last_polled_at, last_status, etag, last_modified`
+
+func (s *Store) GetFeed(id int64) (Feed, error) {
+	f, err := scanFeed(s.db.QueryRow(`SELECT `+feedColumns+` FROM feeds WHERE id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Feed{}, ErrNotFound
+	}
+	return f, err
+}
+
+// ListFeeds returns a collection's feeds, or every feed when collection is empty.
+func (s *Store) ListFeeds(collection string) ([]Feed, error) {
+	rows, err := s.db.Query(`SELECT `+feedColumns+` FROM feeds WHERE ?='' OR collection=? ORDER BY id`, collection, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// DeleteFeed removes a feed and the entries it has seen.
+func (s *Store) DeleteFeed(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM feeds WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	_, err = s.db.Exec(`DELETE FROM feed_entries WHERE feed_id=?`, id)
+	return err
+}
+
+// RecordFeedPoll stores the outcome of the latest poll and the validators
+// the feed was served with.
+func (s *Store) RecordFeedPoll(id int64, at time.Time, status, etag, lastModified string) error {
+	_, err := s.db.Exec(`UPDATE feeds SET last_polled_at=?, last_status=?, etag=?, last_modified=? WHERE id=?`,
+		at.Unix(), status, etag, lastModified, id)
+	return err
+}
+
+// FeedEntrySeen reports whether a feed's entry has been ingested.
+func (s *Store) FeedEntrySeen(id int64, guid string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM feed_entries WHERE feed_id=? AND guid=?`, id, guid).Scan(&n)
+	return n > 0, err
+}
+
+// MarkFeedEntry records that a feed's entry has been ingested.
+func (s *Store) MarkFeedEntry(id int64, guid string) error {
+	_, err := s.db.Exec(`INSERT INTO feed_entries(feed_id,guid,seen_at) VALUES(?,?,?) ON CONFLICT DO NOTHING`, id, guid, time.Now().Unix())
+	return err
+}
+
+func scanFeed(row rowScanner) (Feed, error) {
+	var f Feed
+	var created, polled int64
+	if err := row.Scan(&f.ID, &f.Collection, &f.URL, &f.Interval, &created, &polled, &f.LastStatus, &f.ETag, &f.LastModified); err != nil {
+		return Feed{}, err
+	}
+	f.CreatedAt = time.Unix(created, 0)
+	if polled > 0 {
+		f.LastPolledAt = time.Unix(polled, 0)
+	}
+	return f, nil
+}
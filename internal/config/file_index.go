@@ -0,0 +1,83 @@
+package config
+
+import (
+	"time"
+)
+
+// IndexedFile records what a directory source last ingested of one file,
+// so later runs can tell whether it changed.
+type IndexedFile struct {
+	Collection string    `json:"collection"`
+	Source     string    `json:"source"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	MD5        string    `json:"md5"`
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// IndexedFiles returns a source's indexed files in a collection by path.
+func (s *Store) IndexedFiles(collection, source string) (map[string]IndexedFile, error) {
+	rows, err := s.db.Query(`SELECT path, size, mod_time, md5, ingested_at FROM file_index WHERE collection=? AND source=?`, collection, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]IndexedFile{}
+	for rows.Next() {
+		f := IndexedFile{Collection: collection, Source: source}
+		var modTime, ingested int64
+		if err := rows.Scan(&f.Path, &f.Size, &modTime, &f.MD5, &ingested); err != nil {
+			return nil, err
+		}
+		f.ModTime = time.Unix(0, modTime)
+		f.IngestedAt = time.Unix(ingested, 0)
+		out[f.Path] = f
+	}
+	return out, rows.Err()
+}
+
+// IndexFiles records or refreshes indexed files in one transaction.
+func (s *Store) IndexFiles(files []IndexedFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, f := range files {
+		if _, err := tx.Exec(`INSERT INTO file_index(collection,source,path,size,mod_time,md5,ingested_at) VALUES(?,?,?,?,?,?,?)
+			ON CONFLICT(collection,source,path) DO UPDATE SET size=excluded.size, mod_time=excluded.mod_time, md5=excluded.md5, ingested_at=excluded.ingested_at`,
+			f.Collection, f.Source, f.Path, f.Size, f.ModTime.UnixNano(), f.MD5, f.IngestedAt.Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ForgetIndexedFiles drops indexed files of a collection: all of them when
+// source is empty (e.g. when the collection is deleted), all of source's
+// when no paths are given, or else just the given paths of source.
+func (s *Store) ForgetIndexedFiles(collection, source string, paths ...string) error {
+	switch {
+	case source == "":
+		_, err := s.db.Exec(`DELETE FROM file_index WHERE collection=?`, collection)
+		return err
+	case len(paths) == 0:
+		_, err := s.db.Exec(`DELETE FROM file_index WHERE collection=? AND source=?`, collection, source)
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, p := range paths {
+		if _, err := tx.Exec(`DELETE FROM file_index WHERE collection=? AND source=? AND path=?`, collection, source, p); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Intent statuses.
+const (
+	IntentPending    = "pending"    // recorded, Chroma call not yet finished
+	IntentDone       = "done"       // Chroma call succeeded
+	IntentFailed     = "failed"     // Chroma call returned an error
+	IntentReconciled = "reconciled" // pending or failed intent checked after the fact
+)
+
+// Intent is a mutation recorded before it is sent to Chroma, so work cut
+// short by a crash or a failed batch can be found and reconciled.
+type Intent struct {
+	ID         int64             `json:"id"`
+	Collection string            `json:"collection"`
+	Op         string            `json:"op"`
+	IDs        []string          `json:"ids,omitempty"`
+	Filter     map[string]string `json:"filter,omitempty"` // metadata equality, for deletes by source
+	Ref        string            `json:"ref,omitempty"`    // what was being written, e.g. a file name
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Resolution string            `json:"resolution,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	// CompletedAt is zero while the intent is pending.
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// BeginIntent records a pending intent and returns its ID.
+func (s *Store) BeginIntent(in Intent) (int64, error) {
+	ids, err := json.Marshal(in.IDs)
+	if err != nil {
+		return 0, err
+	}
+	filter, err := json.Marshal(in.Filter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(`INSERT INTO mutation_intents(collection,op,ids,filter,ref,status,created_at) VALUES(?,?,?,?,?,?,?)`,
+		in.Collection, in.Op, string(ids), string(filter), in.Ref, IntentPending, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishIntent marks an intent done, or failed with errMsg.
+func (s *Store) FinishIntent(id int64, status, errMsg string) error {
+	_, err := s.db.Exec(`UPDATE mutation_intents SET status=?, error=?, completed_at=? WHERE id=?`,
+		status, errMsg, time.Now().Unix(), id)
+	return err
+}
+
+// ResolveIntent marks a pending or failed intent reconciled.
+func (s *Store) ResolveIntent(id int64, resolution string) error {
+	_, err := s.db.Exec(`UPDATE mutation_intents SET status=?, resolution=?, completed_at=? WHERE id=?`,
+		IntentReconciled, resolution, time.Now().Unix(), id)
+	return err
+}
+
+// ListIntents returns intents in the given statuses (all when none are
+// given), oldest first.
+func (s *Store) ListIntents(statuses ...string) ([]Intent, error) {
+	q := `SELECT id, collection, op, ids, filter, ref, status, error, resolution, created_at, completed_at FROM mutation_intents`
+	var args []any
+	if len(statuses) > 0 {
+		q += ` WHERE status IN (?` + strings.Repeat(",?", len(statuses)-1) + `)`
+		for _, st := range statuses {
+			args = append(args, st)
+		}
+	}
+	rows, err := s.db.Query(q+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Intent
+	for rows.Next() {
+		var in Intent
+		var ids, filter string
+		var created, completed int64
+		if err := rows.Scan(&in.ID, &in.Collection, &in.Op, &ids, &filter, &in.Ref, &in.Status, &in.Error, &in.Resolution, &created, &completed); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(ids), &in.IDs); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(filter), &in.Filter); err != nil {
+			return nil, err
+		}
+		in.CreatedAt = time.Unix(created, 0)
+		if completed > 0 {
+			in.CompletedAt = time.Unix(completed, 0)
+		}
+		out = append(out, in)
+	}
+	return out, rows.Err()
+}
+
+// PruneIntents removes finished intents completed before cutoff.
+func (s *Store) PruneIntents(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM mutation_intents WHERE status IN (?,?) AND completed_at < ?`,
+		IntentDone, IntentReconciled, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,55 @@
+package config
+
+import "time"
+
+// MirrorEntry is a collection whose copy on the secondary backend missed
+// writes and waits to be reconciled.
+type MirrorEntry struct {
+	Collection string    `json:"collection"`
+	Reason     string    `json:"reason"`
+	QueuedAt   time.Time `json:"queued_at"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// QueueMirror queues a collection for reconciliation, or refreshes its
+// entry if it is already queued.
+func (s *Store) QueueMirror(collection, reason string) error {
+	_, err := s.db.Exec(`INSERT INTO mirror_queue(collection,reason,queued_at) VALUES(?,?,?)
+		ON CONFLICT(collection) DO UPDATE SET reason=excluded.reason, queued_at=excluded.queued_at`,
+		collection, reason, time.Now().UnixNano())
+	return err
+}
+
+// MirrorQueue lists the queued collections, oldest first.
+func (s *Store) MirrorQueue() ([]MirrorEntry, error) {
+	rows, err := s.db.Query(`SELECT collection, reason, queued_at, attempts, last_error FROM mirror_queue ORDER BY queued_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []MirrorEntry{}
+	for rows.Next() {
+		var e MirrorEntry
+		var queued int64
+		if err := rows.Scan(&e.Collection, &e.Reason, &queued, &e.Attempts, &e.LastError); err != nil {
+			return nil, err
+		}
+		e.QueuedAt = time.Unix(0, queued)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// RecordMirrorAttempt counts a failed reconciliation of a queued collection.
+func (s *Store) RecordMirrorAttempt(collection, lastError string) error {
+	_, err := s.db.Exec(`UPDATE mirror_queue SET attempts=attempts+1, last_error=? WHERE collection=?`, lastError, collection)
+	return err
+}
+
+// DequeueMirror removes a reconciled collection unless it was queued again
+// after since.
+func (s *Store) DequeueMirror(collection string, since time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM mirror_queue WHERE collection=? AND queued_at<=?`, collection, since.UnixNano())
+	return err
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a stored record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Pipeline is a stored ingestion pipeline spec with its last run state.
+type Pipeline struct {
+	Name       string    `json:"name"`
+	Spec       string    `json:"spec"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastRunAt  time.Time `json:"last_run_at"`
+	LastStatus string    `json:"last_status,omitempty"`
+}
+
+func (s *Store) SavePipeline(name, spec string) error {
+	_, err := s.db.Exec(`INSERT INTO pipelines(name,spec,updated_at) VALUES(?,?,?)
+		ON CONFLICT(name) DO UPDATE SET spec=excluded.spec, updated_at=excluded.updated_at`,
+		name, spec, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("save pipeline %q: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Store) GetPipeline(name string) (Pipeline, error) {
+	row := s.db.QueryRow(`SELECT name, spec, updated_at, last_run_at, last_status FROM pipelines WHERE name=?`, name)
+	p, err := scanPipeline(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Pipeline{}, ErrNotFound
+	}
+	return p, err
+}
+
+func (s *Store) ListPipelines() ([]Pipeline, error) {
+	rows, err := s.db.Query(`SELECT name, spec, updated_at, last_run_at, last_status FROM pipelines ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Pipeline
+	for rows.Next() {
+		p, err := scanPipeline(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeletePipeline(name string) error {
+	res, err := s.db.Exec(`DELETE FROM pipelines WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordPipelineRun stores the outcome of the latest pipeline run.
+func (s *Store) RecordPipelineRun(name string, at time.Time, status string) error {
+	_, err := s.db.Exec(`UPDATE pipelines SET last_run_at=?, last_status=? WHERE name=?`, at.Unix(), status, name)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPipeline(row rowScanner) (Pipeline, error) {
+	var p Pipeline
+	var updated, lastRun int64
+	if err := row.Scan(&p.Name, &p.Spec, &updated, &lastRun, &p.LastStatus); err != nil {
+		return Pipeline{}, err
+	}
+	p.UpdatedAt = time.Unix(updated, 0)
+	if lastRun > 0 {
+		p.LastRunAt = time.Unix(lastRun, 0)
+	}
+	return p, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import "time"
+
+// QueryStat counts how often a query was searched in a collection.
+type QueryStat struct {
+	Collection string    `json:"collection"`
+	Query      string    `json:"query"`
+	Hits       int       `json:"hits"`
+	LastAt     time.Time `json:"last_at"`
+}
+
+// RecordQuery increments the hit count of a collection/query pair.
+func (s *Store) RecordQuery(collection, query string) error {
+	_, err := s.db.Exec(`INSERT INTO query_stats(collection,query,hits,last_at) VALUES(?,?,1,?)
+		ON CONFLICT(collection,query) DO UPDATE SET hits=hits+1, last_at=excluded.last_at`,
+		collection, query, time.Now().Unix())
+	return err
+}
+
+// TopQueries returns the n most frequently searched queries.
+func (s *Store) TopQueries(n int) ([]QueryStat, error) {
+	rows, err := s.db.Query(`SELECT collection, query, hits, last_at FROM query_stats
+		ORDER BY hits DESC, last_at DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []QueryStat
+	for rows.Next() {
+		var q QueryStat
+		var last int64
+		if err := rows.Scan(&q.Collection, &q.Query, &q.Hits, &last); err != nil {
+			return nil, err
+		}
+		q.LastAt = time.Unix(last, 0)
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+// TopCollections returns the n collections with the most recorded searches.
+func (s *Store) TopCollections(n int) ([]string, error) {
+	rows, err := s.db.Query(`SELECT collection FROM query_stats
+		GROUP BY collection ORDER BY SUM(hits) DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
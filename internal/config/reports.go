@@ -0,0 +1,54 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Report is a stored, JSON-encoded health report.
+type Report struct {
+	ID        int64
+	CreatedAt time.Time
+	Body      string
+}
+
+// SaveReport stores a report body and returns its ID.
+func (s *Store) SaveReport(createdAt time.Time, body string) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO reports(created_at, body) VALUES(?,?)`, createdAt.Unix(), body)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) GetReport(id int64) (Report, error) {
+	var r Report
+	var created int64
+	err := s.db.QueryRow(`SELECT id, created_at, body FROM reports WHERE id=?`, id).Scan(&r.ID, &created, &r.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Report{}, ErrNotFound
+	}
+	r.CreatedAt = time.Unix(created, 0)
+	return r, err
+}
+
+// ListReports returns the newest reports first.
+func (s *Store) ListReports(limit int) ([]Report, error) {
+	rows, err := s.db.Query(`SELECT id, created_at, body FROM reports ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Report
+	for rows.Next() {
+		var r Report
+		var created int64
+		if err := rows.Scan(&r.ID, &created, &r.Body); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = time.Unix(created, 0)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
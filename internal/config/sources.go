@@ -0,0 +1,71 @@
+package config
+
+import (
+	"time"
+)
+
+// Source is an ingest origin (upload batch, URL, pipeline run) whose chunks
+// carry its ID in source_id metadata.
+type Source struct {
+	ID           string    `json:"id"`
+	Collection   string    `json:"collection"`
+	Kind         string    `json:"kind"`
+	Ref          string    `json:"ref,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastIngestAt time.Time `json:"last_ingest_at"`
+}
+
+// RecordSource registers a source or refreshes its last ingest time.
+func (s *Store) RecordSource(src Source) error {
+	now := time.Now().Unix()
+	_, err := s.db.Exec(`INSERT INTO sources(id,collection,kind,ref,created_at,last_ingest_at) VALUES(?,?,?,?,?,?)
+		ON CONFLICT(id,collection) DO UPDATE SET kind=excluded.kind, ref=excluded.ref, last_ingest_at=excluded.last_ingest_at`,
+		src.ID, src.Collection, src.Kind, src.Ref, now, now)
+	return err
+}
+
+// ListSources returns sources, optionally limited to one collection.
+func (s *Store) ListSources(collection string) ([]Source, error) {
+	q := `SELECT id, collection, kind, ref, created_at, last_ingest_at FROM sources`
+	var args []any
+	if collection != "" {
+		q += ` WHERE collection=?`
+		args = append(args, collection)
+	}
+	rows, err := s.db.Query(q+` ORDER BY last_ingest_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Source
+	for rows.Next() {
+		var src Source
+		var created, last int64
+		if err := rows.Scan(&src.ID, &src.Collection, &src.Kind, &src.Ref, &created, &last); err != nil {
+			return nil, err
+		}
+		src.CreatedAt = time.Unix(created, 0)
+		src.LastIngestAt = time.Unix(last, 0)
+		out = append(out, src)
+	}
+	return out, rows.Err()
+}
+
+// GetSource returns a source registered in a collection.
+func (s *Store) GetSource(collection, id string) (Source, error) {
+	sources, err := s.ListSources(collection)
+	if err != nil {
+		return Source{}, err
+	}
+	for _, src := range sources {
+		if src.ID == id {
+			return src, nil
+		}
+	}
+	return Source{}, ErrNotFound
+}
+
+func (s *Store) DeleteSource(collection, id string) error {
+	_, err := s.db.Exec(`DELETE FROM sources WHERE collection=? AND id=?`, collection, id)
+	return err
+}
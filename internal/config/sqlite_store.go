@@ -1,11 +1,14 @@
 package config
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -19,13 +22,218 @@ type Values struct {
 	CollectionName  string
 	BackendHTTPPort int
 	MCPTransport    string
+	ArchiveDir      string
+	GitDir          string // checkouts kept for incremental git ingestion
+	// GitInsecureTransports allows http:// and git:// repository URLs.
+	GitInsecureTransports bool
+	// IngestPathRoots are the server directories /api/ingest/path may read;
+	// empty disables it.
+	IngestPathRoots []string
+	// FetchPrivateNetworks lets crawls, feeds and pipeline url sources
+	// fetch loopback, private and link-local addresses, which are refused
+	// by default.
+	FetchPrivateNetworks bool
+	// SearchDegradeAfterMS enables search load shedding when positive.
+	SearchDegradeAfterMS int
+	QueryTimeoutMS       int
+	EmbedTimeoutMS       int
+	// Result counts for searches that leave k unset, and the most any
+	// search may ask for; see services.SearchLimits.
+	SearchDefaultK int
+	SearchMaxK     int
+	// ShortQueryTerms routes queries of at most that many terms to lexical
+	// search first; zero disables it.
+	ShortQueryTerms int
+	// DocVersionsKeep is how many superseded versions of each file are kept
+	// for rollback; zero disables versioning.
+	DocVersionsKeep int
+	// Warm replicas of ReplicaCollections on a second Chroma server
+	// (ReplicaChromaURL; empty disables them), checked for consistency
+	// every ReplicaCheckInterval (Go duration, "0" only after changes).
+	ReplicaChromaURL     string
+	ReplicaCollections   []string
+	ReplicaCheckInterval string
+	// Every write is also made, asynchronously, on a secondary Chroma server
+	// (MirrorChromaURL; empty disables it). Collections that miss writes are
+	// reconciled every MirrorReconcileInterval (Go duration).
+	MirrorChromaURL         string
+	MirrorReconcileInterval string
+	// Warm-up on startup; see services.WarmupOptions.
+	WarmupEnabled        bool
+	WarmupCollections    []string
+	WarmupTopCollections int
+	WarmupReplayQueries  int
+	// Adaptive ingest batching bounds; see services.BatchTuning.
+	IngestBatchMin       int
+	IngestBatchMax       int
+	IngestConcurrencyMax int
+	IngestBatchTargetMS  int
+	// QuotaWebhookURL receives soft-limit alerts for keys without their own webhook.
+	QuotaWebhookURL string
+	// AdminToken is the operator secret for admin routes such as /keys;
+	// RequireAPIKey rejects requests that carry neither it nor an API key.
+	AdminToken    string
+	RequireAPIKey bool
+	// System metadata key overrides (JSON object) and namespace prefix.
+	SystemMetadataKeys      string
+	SystemMetadataNamespace string
+	// Health reports: interval (Go duration, "0" disables) and optional webhook.
+	ReportInterval   string
+	ReportWebhookURL string
+	// TrashGrace is how long deleted collections stay restorable (Go
+	// duration, "0" deletes right away).
+	TrashGrace string
+	// SMTP notifications are enabled when smtp_host is set.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTo       []string
+	SMTPEvents   []string
+	// Single-port mode also serves the frontend and MCP (streamable HTTP)
+	// on BackendHTTPPort.
+	SinglePort  bool
+	FrontendDir string
+	MCPHTTPPath string
+	// Collection naming rules; see services.NamePolicy.
+	CollectionNameMode string
+	CollectionNameCase string
+	// EventWebhookURL receives internal events (see services.EventBus) as JSON.
+	EventWebhookURL string
+	EventTypes      []string
+	// OCR for images and scanned PDFs: backend "" (off), "tesseract" or "http".
+	OCRBackend    string
+	OCRURL        string
+	OCRLanguages  string
+	TesseractPath string
+	PdftoppmPath  string
+	// Audio transcription: backend "" (off), "whisper.cpp" or "openai".
+	TranscriptionBackend       string
+	TranscriptionURL           string
+	TranscriptionAPIKey        string
+	TranscriptionModel         string
+	TranscriptionLanguage      string
+	TranscriptionWindowSeconds int
+	// Image ingestion: backend "" (OCR only), "caption" or "embed".
+	ImageBackend       string
+	ImageURL           string
+	ImageAPIKey        string
+	ImageModel         string
+	ImageCaptionPrompt string
+	// LLM for /answer (OpenAI-compatible chat completions); off without a model.
+	LLMURL          string
+	LLMAPIKey       string
+	LLMModel        string
+	LLMModels       []string // further models the endpoint serves, selectable per request
+	LLMAnswerPrompt string
+	// Cost tracking: the embedding model Chroma's embedding function uses
+	// (for attribution) and prices as "model=input:output" USD per million
+	// tokens.
+	EmbeddingProvider string
+	EmbeddingModel    string
+	ModelPrices       []string
+	// DefaultTokenizer chunks collections without a tokenizer of their own.
+	DefaultTokenizer string
+	// ChunkStrategy splits text for collections without a strategy of their
+	// own, by registered name (e.g. "recursive", "lines" or "sentences").
+	ChunkStrategy string
+	// ChunkSize and ChunkOverlap are the default chunk size and how many
+	// tokens consecutive chunks share, for collections without their own.
+	ChunkSize    int
+	ChunkOverlap int
+	// ChunkDedupe skips chunks whose text the collection already holds.
+	ChunkDedupe bool
+	// Uploaded zip/tar archives are expanded within these bounds.
+	ExpandMaxDepth int
+	ExpandMaxFiles int
+	ExpandMaxMB    int
+	// Concurrent requests per route class (0 = unlimited) and how long a
+	// request waits for a slot before it is rejected with 503.
+	RouteLimitIngest int
+	RouteLimitSearch int
+	RouteLimitAdmin  int
+	RouteLimitWaitMS int
+	// HTTP server limits (0 disables a timeout); HTTP2Enabled serves
+	// cleartext HTTP/2 (h2c) alongside HTTP/1.1.
+	HTTPReadHeaderTimeoutMS int
+	HTTPReadTimeoutMS       int
+	HTTPWriteTimeoutMS      int
+	HTTPIdleTimeoutMS       int
+	HTTPMaxHeaderBytes      int
+	HTTPKeepAlive           bool
+	HTTP2Enabled            bool
+	HTTP2MaxStreams         int
+	// HTTPReusePort binds with SO_REUSEPORT so a new version can start
+	// alongside a running one; ShutdownTimeoutMS bounds how long in-flight
+	// requests drain on shutdown or graceful restart.
+	HTTPReusePort     bool
+	ShutdownTimeoutMS int
+	// Original uploads, keyed by content hash: backend "" (off), "local"
+	// (BlobDir) or "s3" (any S3-compatible bucket).
+	BlobStore       string
+	BlobDir         string
+	BlobS3Endpoint  string
+	BlobS3Region    string
+	BlobS3Bucket    string
+	BlobS3Prefix    string
+	BlobS3AccessKey string
+	BlobS3SecretKey string
+	// Archives and snapshots: backend "local" (ArchiveDir, the default) or
+	// "s3" (any S3-compatible bucket).
+	ArchiveStore       string
+	ArchiveS3Endpoint  string
+	ArchiveS3Region    string
+	ArchiveS3Bucket    string
+	ArchiveS3Prefix    string
+	ArchiveS3AccessKey string
+	ArchiveS3SecretKey string
 }
 
 const (
-	defaultChromaURL      = "http://localhost:8000"
-	defaultCollectionName = "default"
-	defaultHTTPPort       = 8080
-	defaultMCPTransport   = "stdio"
+	defaultChromaURL        = "http://localhost:8000"
+	defaultCollectionName   = "default"
+	defaultHTTPPort         = 8080
+	defaultMCPTransport     = "stdio"
+	defaultArchiveDir       = "backend/archives"
+	defaultGitDir           = "backend/git"
+	defaultBlobDir          = "backend/blobs"
+	defaultDegradeAfterMS   = 0
+	defaultQueryTimeoutMS   = 10000
+	defaultEmbedTimeoutMS   = 60000
+	defaultWarmupTop        = 5
+	defaultSearchK          = 5
+	defaultSearchMaxK       = 100
+	defaultWarmupReplay     = 0
+	defaultBatchMin         = 16
+	defaultBatchMax         = 512
+	defaultConcurrencyMax   = 4
+	defaultBatchTargetMS    = 2000
+	defaultReportInterval   = "24h"
+	defaultTrashGrace       = "24h"
+	defaultSMTPPort         = 587
+	defaultFrontendDir      = "frontend/dist"
+	defaultMCPHTTPPath      = "/mcp"
+	defaultNameMode         = "validate"
+	defaultNameCase         = "insensitive"
+	defaultOCRLanguages     = "eng"
+	defaultTranscriptWindow = 60
+	defaultExpandMaxDepth   = 3
+	defaultExpandMaxFiles   = 1000
+	defaultExpandMaxMB      = 512
+	defaultRouteLimitWaitMS = 2000
+	defaultReadHeaderMS     = 10000
+	defaultReadTimeoutMS    = 300000
+	defaultWriteTimeoutMS   = 600000
+	defaultIdleTimeoutMS    = 120000
+	defaultMaxHeaderBytes   = 1 << 20
+	defaultHTTP2MaxStreams  = 250
+	defaultShutdownMS       = 5000
+	// Chroma's built-in embedding function: local ONNX all-MiniLM-L6-v2
+	defaultEmbeddingProvider = "chroma"
+	defaultEmbeddingModel    = "all-MiniLM-L6-v2"
+	defaultTokenizer         = "cl100k"
+	defaultChunkStrategy     = "recursive"
 )
 
 func Ensure(path string) (*Store, error) {
@@ -48,15 +256,201 @@ func Ensure(path string) (*Store, error) {
 
 func (s *Store) Close() error { return s.db.Close() }
 
+// IntegrityCheck runs SQLite's integrity check over the config database.
+func (s *Store) IntegrityCheck() error {
+	var result string
+	if err := s.db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	return nil
+}
+
+// schema lists idempotent DDL statements applied on every start.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS config (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS pipelines (
+		name TEXT PRIMARY KEY,
+		spec TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		last_run_at INTEGER NOT NULL DEFAULT 0,
+		last_status TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE IF NOT EXISTS derived_collections (
+		name TEXT PRIMARY KEY,
+		source TEXT NOT NULL,
+		filter TEXT NOT NULL DEFAULT '{}',
+		transforms TEXT NOT NULL DEFAULT '[]',
+		last_sync_at INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE IF NOT EXISTS collection_settings (
+		collection TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (collection, key)
+	);`,
+	`CREATE TABLE IF NOT EXISTS sources (
+		id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		ref TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		last_ingest_at INTEGER NOT NULL,
+		PRIMARY KEY (id, collection)
+	);`,
+	`CREATE TABLE IF NOT EXISTS query_stats (
+		collection TEXT NOT NULL,
+		query TEXT NOT NULL,
+		hits INTEGER NOT NULL DEFAULT 0,
+		last_at INTEGER NOT NULL,
+		PRIMARY KEY (collection, query)
+	);`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		webhook_url TEXT NOT NULL DEFAULT '',
+		limit_searches INTEGER NOT NULL DEFAULT 0,
+		limit_ingest_files INTEGER NOT NULL DEFAULT 0,
+		limit_ingest_chunks INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS api_key_usage (
+		key_id TEXT NOT NULL,
+		day TEXT NOT NULL,
+		searches INTEGER NOT NULL DEFAULT 0,
+		ingest_files INTEGER NOT NULL DEFAULT 0,
+		ingest_chunks INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (key_id, day)
+	);`,
+	`CREATE TABLE IF NOT EXISTS api_key_alerts (
+		key_id TEXT NOT NULL,
+		day TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		PRIMARY KEY (key_id, day, metric)
+	);`,
+	`CREATE TABLE IF NOT EXISTS collection_stats (
+		collection TEXT NOT NULL,
+		day TEXT NOT NULL,
+		searches INTEGER NOT NULL DEFAULT 0,
+		zero_results INTEGER NOT NULL DEFAULT 0,
+		ingest_failures INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (collection, day)
+	);`,
+	`CREATE TABLE IF NOT EXISTS reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at INTEGER NOT NULL,
+		body TEXT NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS mutation_intents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection TEXT NOT NULL,
+		op TEXT NOT NULL,
+		ids TEXT NOT NULL DEFAULT '[]',
+		filter TEXT NOT NULL DEFAULT '{}',
+		ref TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		resolution TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		completed_at INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE INDEX IF NOT EXISTS mutation_intents_status ON mutation_intents(status);`,
+	`CREATE TABLE IF NOT EXISTS cost_usage (
+		day TEXT NOT NULL,
+		key_id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		model TEXT NOT NULL,
+		calls INTEGER NOT NULL DEFAULT 0,
+		input_tokens INTEGER NOT NULL DEFAULT 0,
+		output_tokens INTEGER NOT NULL DEFAULT 0,
+		cost_usd REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, key_id, collection, kind, provider, model)
+	);`,
+	`CREATE TABLE IF NOT EXISTS prompt_templates (
+		name TEXT PRIMARY KEY,
+		spec TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS feeds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection TEXT NOT NULL,
+		url TEXT NOT NULL,
+		interval TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		last_polled_at INTEGER NOT NULL DEFAULT 0,
+		last_status TEXT NOT NULL DEFAULT '',
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		UNIQUE (collection, url)
+	);`,
+	`CREATE TABLE IF NOT EXISTS feed_entries (
+		feed_id INTEGER NOT NULL,
+		guid TEXT NOT NULL,
+		seen_at INTEGER NOT NULL,
+		PRIMARY KEY (feed_id, guid)
+	);`,
+	`CREATE TABLE IF NOT EXISTS file_index (
+		collection TEXT NOT NULL,
+		source TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		mod_time INTEGER NOT NULL,
+		md5 TEXT NOT NULL,
+		ingested_at INTEGER NOT NULL,
+		PRIMARY KEY (collection, source, path)
+	);`,
+	`CREATE TABLE IF NOT EXISTS doc_versions (
+		collection TEXT NOT NULL,
+		file TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		file_md5 TEXT NOT NULL,
+		chunks INTEGER NOT NULL,
+		records TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (collection, file, version)
+	);`,
+	`CREATE TABLE IF NOT EXISTS mirror_queue (
+		collection TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		queued_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT ''
+	);`,
+}
+
+// addedColumns lists columns added to tables after their creation; migrate
+// adds the ones an existing database lacks.
+var addedColumns = []struct{ table, column, def string }{
+	{"api_keys", "collection", "TEXT NOT NULL DEFAULT ''"},
+	{"api_keys", "restricted", "INTEGER NOT NULL DEFAULT 0"},
+	{"api_keys", "admin", "INTEGER NOT NULL DEFAULT 0"},
+}
+
 func (s *Store) migrate() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS config (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("migrate: %w", err)
+	for _, stmt := range schema {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	for _, c := range addedColumns {
+		var n int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, c.table, c.column).Scan(&n); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.db.Exec(`ALTER TABLE ` + c.table + ` ADD COLUMN ` + c.column + ` ` + c.def); err != nil {
+			return fmt.Errorf("migrate: add %s.%s: %w", c.table, c.column, err)
+		}
 	}
 	return nil
 }
@@ -69,11 +463,27 @@ func (s *Store) seedDefaults() error {
 	}
 	defer func() { _ = tx.Rollback() }()
 	ins := `INSERT OR IGNORE INTO config(key,value) VALUES(?,?)`
+	// chroma_url and collection_name depend on the environment and are
+	// written by the setup flow (POST /setup) instead
 	pairs := [][2]string{
-		{"chroma_url", defaultChromaURL},
-		{"collection_name", defaultCollectionName},
 		{"backend_http_port", fmt.Sprintf("%d", defaultHTTPPort)},
 		{"mcp_transport", defaultMCPTransport},
+		{"archive_dir", defaultArchiveDir},
+		{"search_degrade_after_ms", fmt.Sprintf("%d", defaultDegradeAfterMS)},
+		{"query_timeout_ms", fmt.Sprintf("%d", defaultQueryTimeoutMS)},
+		{"embed_timeout_ms", fmt.Sprintf("%d", defaultEmbedTimeoutMS)},
+		{"warmup_enabled", "false"},
+		{"single_port", "false"},
+		{"collection_name_mode", defaultNameMode},
+		{"collection_name_case", defaultNameCase},
+		{"warmup_top_collections", fmt.Sprintf("%d", defaultWarmupTop)},
+		{"warmup_replay_queries", fmt.Sprintf("%d", defaultWarmupReplay)},
+		{"ingest_batch_min", fmt.Sprintf("%d", defaultBatchMin)},
+		{"ingest_batch_max", fmt.Sprintf("%d", defaultBatchMax)},
+		{"ingest_concurrency_max", fmt.Sprintf("%d", defaultConcurrencyMax)},
+		{"ingest_batch_target_ms", fmt.Sprintf("%d", defaultBatchTargetMS)},
+		{"report_interval", defaultReportInterval},
+		{"trash_grace", defaultTrashGrace},
 	}
 	for _, p := range pairs {
 		if _, err := tx.Exec(ins, p[0], p[1]); err != nil {
@@ -101,10 +511,118 @@ func (s *Store) GetAll() (Values, error) {
 		return Values{}, err
 	}
 	v := Values{
-		ChromaURL:       pick(vals, "chroma_url", defaultChromaURL),
-		CollectionName:  pick(vals, "collection_name", defaultCollectionName),
-		BackendHTTPPort: atoi(pick(vals, "backend_http_port", fmt.Sprintf("%d", defaultHTTPPort))),
-		MCPTransport:    pick(vals, "mcp_transport", defaultMCPTransport),
+		ChromaURL:                  pick(vals, "chroma_url", defaultChromaURL),
+		CollectionName:             pick(vals, "collection_name", defaultCollectionName),
+		BackendHTTPPort:            atoi(pick(vals, "backend_http_port", fmt.Sprintf("%d", defaultHTTPPort))),
+		MCPTransport:               pick(vals, "mcp_transport", defaultMCPTransport),
+		ArchiveDir:                 pick(vals, "archive_dir", defaultArchiveDir),
+		GitDir:                     pick(vals, "git_dir", defaultGitDir),
+		GitInsecureTransports:      pick(vals, "git_insecure_transports", "false") == "true",
+		IngestPathRoots:            splitList(pick(vals, "ingest_path_roots", "")),
+		FetchPrivateNetworks:       pick(vals, "fetch_private_networks", "false") == "true",
+		SearchDegradeAfterMS:       atoi(pick(vals, "search_degrade_after_ms", fmt.Sprintf("%d", defaultDegradeAfterMS))),
+		QueryTimeoutMS:             atoi(pick(vals, "query_timeout_ms", fmt.Sprintf("%d", defaultQueryTimeoutMS))),
+		EmbedTimeoutMS:             atoi(pick(vals, "embed_timeout_ms", fmt.Sprintf("%d", defaultEmbedTimeoutMS))),
+		SearchDefaultK:             atoi(pick(vals, "search_default_k", fmt.Sprintf("%d", defaultSearchK))),
+		SearchMaxK:                 atoi(pick(vals, "search_max_k", fmt.Sprintf("%d", defaultSearchMaxK))),
+		ShortQueryTerms:            atoi(pick(vals, "short_query_terms", "0")),
+		DocVersionsKeep:            atoi(pick(vals, "doc_versions_keep", "10")),
+		ReplicaChromaURL:           pick(vals, "replica_chroma_url", ""),
+		ReplicaCollections:         splitList(pick(vals, "replica_collections", "")),
+		ReplicaCheckInterval:       pick(vals, "replica_check_interval", "10m"),
+		MirrorChromaURL:            pick(vals, "mirror_chroma_url", ""),
+		MirrorReconcileInterval:    pick(vals, "mirror_reconcile_interval", "1m"),
+		WarmupEnabled:              pick(vals, "warmup_enabled", "false") == "true",
+		WarmupCollections:          splitList(pick(vals, "warmup_collections", "")),
+		WarmupTopCollections:       atoi(pick(vals, "warmup_top_collections", fmt.Sprintf("%d", defaultWarmupTop))),
+		WarmupReplayQueries:        atoi(pick(vals, "warmup_replay_queries", fmt.Sprintf("%d", defaultWarmupReplay))),
+		IngestBatchMin:             atoi(pick(vals, "ingest_batch_min", fmt.Sprintf("%d", defaultBatchMin))),
+		IngestBatchMax:             atoi(pick(vals, "ingest_batch_max", fmt.Sprintf("%d", defaultBatchMax))),
+		IngestConcurrencyMax:       atoi(pick(vals, "ingest_concurrency_max", fmt.Sprintf("%d", defaultConcurrencyMax))),
+		IngestBatchTargetMS:        atoi(pick(vals, "ingest_batch_target_ms", fmt.Sprintf("%d", defaultBatchTargetMS))),
+		QuotaWebhookURL:            pick(vals, "quota_webhook_url", ""),
+		AdminToken:                 pick(vals, "admin_token", ""),
+		RequireAPIKey:              pick(vals, "require_api_key", "false") == "true",
+		SystemMetadataKeys:         pick(vals, "system_metadata_keys", ""),
+		SystemMetadataNamespace:    pick(vals, "system_metadata_namespace", ""),
+		ReportInterval:             pick(vals, "report_interval", defaultReportInterval),
+		ReportWebhookURL:           pick(vals, "report_webhook_url", ""),
+		TrashGrace:                 pick(vals, "trash_grace", defaultTrashGrace),
+		SMTPHost:                   pick(vals, "smtp_host", ""),
+		SMTPPort:                   atoi(pick(vals, "smtp_port", fmt.Sprintf("%d", defaultSMTPPort))),
+		SMTPUsername:               pick(vals, "smtp_username", ""),
+		SMTPPassword:               pick(vals, "smtp_password", ""),
+		SMTPFrom:                   pick(vals, "smtp_from", ""),
+		SMTPTo:                     splitList(pick(vals, "smtp_to", "")),
+		SMTPEvents:                 splitList(pick(vals, "smtp_events", "")),
+		SinglePort:                 pick(vals, "single_port", "false") == "true",
+		FrontendDir:                pick(vals, "frontend_dir", defaultFrontendDir),
+		MCPHTTPPath:                pick(vals, "mcp_http_path", defaultMCPHTTPPath),
+		CollectionNameMode:         pick(vals, "collection_name_mode", defaultNameMode),
+		CollectionNameCase:         pick(vals, "collection_name_case", defaultNameCase),
+		EventWebhookURL:            pick(vals, "event_webhook_url", ""),
+		EventTypes:                 splitList(pick(vals, "event_types", "")),
+		OCRBackend:                 pick(vals, "ocr_backend", ""),
+		OCRURL:                     pick(vals, "ocr_url", ""),
+		OCRLanguages:               pick(vals, "ocr_languages", defaultOCRLanguages),
+		TesseractPath:              pick(vals, "tesseract_path", ""),
+		PdftoppmPath:               pick(vals, "pdftoppm_path", ""),
+		TranscriptionBackend:       pick(vals, "transcription_backend", ""),
+		TranscriptionURL:           pick(vals, "transcription_url", ""),
+		TranscriptionAPIKey:        pick(vals, "transcription_api_key", ""),
+		TranscriptionModel:         pick(vals, "transcription_model", ""),
+		TranscriptionLanguage:      pick(vals, "transcription_language", ""),
+		TranscriptionWindowSeconds: atoi(pick(vals, "transcription_window_seconds", fmt.Sprintf("%d", defaultTranscriptWindow))),
+		ImageBackend:               pick(vals, "image_backend", ""),
+		ImageURL:                   pick(vals, "image_url", ""),
+		ImageAPIKey:                pick(vals, "image_api_key", ""),
+		ImageModel:                 pick(vals, "image_model", ""),
+		ImageCaptionPrompt:         pick(vals, "image_caption_prompt", ""),
+		LLMURL:                     pick(vals, "llm_url", ""),
+		LLMAPIKey:                  pick(vals, "llm_api_key", ""),
+		LLMModel:                   pick(vals, "llm_model", ""),
+		LLMModels:                  splitList(pick(vals, "llm_models", "")),
+		LLMAnswerPrompt:            pick(vals, "llm_answer_prompt", ""),
+		EmbeddingProvider:          pick(vals, "embedding_provider", defaultEmbeddingProvider),
+		EmbeddingModel:             pick(vals, "embedding_model", defaultEmbeddingModel),
+		DefaultTokenizer:           pick(vals, "default_tokenizer", defaultTokenizer),
+		ChunkStrategy:              pick(vals, "chunk_strategy", defaultChunkStrategy),
+		ChunkSize:                  atoi(pick(vals, "chunk_size", "0")),
+		ChunkOverlap:               atoi(pick(vals, "chunk_overlap", "0")),
+		ChunkDedupe:                pick(vals, "chunk_dedupe", "false") == "true",
+		ModelPrices:                splitList(pick(vals, "model_prices", "")),
+		ExpandMaxDepth:             atoi(pick(vals, "expand_max_depth", fmt.Sprintf("%d", defaultExpandMaxDepth))),
+		ExpandMaxFiles:             atoi(pick(vals, "expand_max_files", fmt.Sprintf("%d", defaultExpandMaxFiles))),
+		ExpandMaxMB:                atoi(pick(vals, "expand_max_mb", fmt.Sprintf("%d", defaultExpandMaxMB))),
+		RouteLimitIngest:           atoi(pick(vals, "route_limit_ingest", "0")),
+		RouteLimitSearch:           atoi(pick(vals, "route_limit_search", "0")),
+		RouteLimitAdmin:            atoi(pick(vals, "route_limit_admin", "0")),
+		RouteLimitWaitMS:           atoi(pick(vals, "route_limit_wait_ms", fmt.Sprintf("%d", defaultRouteLimitWaitMS))),
+		HTTPReadHeaderTimeoutMS:    atoi(pick(vals, "http_read_header_timeout_ms", fmt.Sprintf("%d", defaultReadHeaderMS))),
+		HTTPReadTimeoutMS:          atoi(pick(vals, "http_read_timeout_ms", fmt.Sprintf("%d", defaultReadTimeoutMS))),
+		HTTPWriteTimeoutMS:         atoi(pick(vals, "http_write_timeout_ms", fmt.Sprintf("%d", defaultWriteTimeoutMS))),
+		HTTPIdleTimeoutMS:          atoi(pick(vals, "http_idle_timeout_ms", fmt.Sprintf("%d", defaultIdleTimeoutMS))),
+		HTTPMaxHeaderBytes:         atoi(pick(vals, "http_max_header_bytes", fmt.Sprintf("%d", defaultMaxHeaderBytes))),
+		HTTPKeepAlive:              pick(vals, "http_keepalive", "true") == "true",
+		HTTP2Enabled:               pick(vals, "http2_enabled", "false") == "true",
+		HTTP2MaxStreams:            atoi(pick(vals, "http2_max_streams", fmt.Sprintf("%d", defaultHTTP2MaxStreams))),
+		HTTPReusePort:              pick(vals, "http_reuse_port", "false") == "true",
+		ShutdownTimeoutMS:          atoi(pick(vals, "shutdown_timeout_ms", fmt.Sprintf("%d", defaultShutdownMS))),
+		BlobStore:                  pick(vals, "blob_store", ""),
+		BlobDir:                    pick(vals, "blob_dir", defaultBlobDir),
+		BlobS3Endpoint:             pick(vals, "blob_s3_endpoint", ""),
+		BlobS3Region:               pick(vals, "blob_s3_region", ""),
+		BlobS3Bucket:               pick(vals, "blob_s3_bucket", ""),
+		BlobS3Prefix:               pick(vals, "blob_s3_prefix", ""),
+		BlobS3AccessKey:            pick(vals, "blob_s3_access_key", ""),
+		BlobS3SecretKey:            pick(vals, "blob_s3_secret_key", ""),
+		ArchiveStore:               pick(vals, "archive_store", ""),
+		ArchiveS3Endpoint:          pick(vals, "archive_s3_endpoint", ""),
+		ArchiveS3Region:            pick(vals, "archive_s3_region", ""),
+		ArchiveS3Bucket:            pick(vals, "archive_s3_bucket", ""),
+		ArchiveS3Prefix:            pick(vals, "archive_s3_prefix", ""),
+		ArchiveS3AccessKey:         pick(vals, "archive_s3_access_key", ""),
+		ArchiveS3SecretKey:         pick(vals, "archive_s3_secret_key", ""),
 	}
 	return v, nil
 }
@@ -124,6 +642,24 @@ func (s *Store) Set(key, value string) error {
 	return err
 }
 
+// EnsureSecret returns the secret stored under key, first generating and
+// storing a random 32-byte one (hex) if there is none, so it survives
+// restarts without being configured.
+func (s *Store) EnsureSecret(key string) (string, error) {
+	if v, err := s.Get(key); err != nil || v != "" {
+		return v, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate %s: %w", key, err)
+	}
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO config(key,value) VALUES(?,?)`, key, hex.EncodeToString(b)); err != nil {
+		return "", err
+	}
+	// Another process may have stored one first
+	return s.Get(key)
+}
+
 // helpers
 func pick(m map[string]string, k, d string) string {
 	if v, ok := m[k]; ok && v != "" {
@@ -132,6 +668,17 @@ func pick(m map[string]string, k, d string) string {
 	return d
 }
 
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 func atoi(s string) int {
 	var n int
 	_, _ = fmt.Sscanf(s, "%d", &n)
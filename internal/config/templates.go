@@ -0,0 +1,69 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PromptTemplate is a stored answer template; Spec is its JSON encoding.
+type PromptTemplate struct {
+	Name      string    `json:"name"`
+	Spec      string    `json:"spec"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Store) SavePromptTemplate(name, spec string) error {
+	_, err := s.db.Exec(`INSERT INTO prompt_templates(name,spec,updated_at) VALUES(?,?,?)
+		ON CONFLICT(name) DO UPDATE SET spec=excluded.spec, updated_at=excluded.updated_at`,
+		name, spec, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("save prompt template %q: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Store) GetPromptTemplate(name string) (PromptTemplate, error) {
+	var t PromptTemplate
+	var updated int64
+	err := s.db.QueryRow(`SELECT name, spec, updated_at FROM prompt_templates WHERE name=?`, name).Scan(&t.Name, &t.Spec, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PromptTemplate{}, ErrNotFound
+	}
+	if err != nil {
+		return PromptTemplate{}, err
+	}
+	t.UpdatedAt = time.Unix(updated, 0)
+	return t, nil
+}
+
+func (s *Store) ListPromptTemplates() ([]PromptTemplate, error) {
+	rows, err := s.db.Query(`SELECT name, spec, updated_at FROM prompt_templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PromptTemplate
+	for rows.Next() {
+		var t PromptTemplate
+		var updated int64
+		if err := rows.Scan(&t.Name, &t.Spec, &updated); err != nil {
+			return nil, err
+		}
+		t.UpdatedAt = time.Unix(updated, 0)
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeletePromptTemplate(name string) error {
+	res, err := s.db.Exec(`DELETE FROM prompt_templates WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -8,7 +8,9 @@ import (
 )
 
 type ChromaDB struct {
-	client chroma.Client
+	client  chroma.Client
+	baseURL string
+	compat  compatState
 }
 
 // NewChromaDB creates a minimal HTTP client. YAGNI: only base URL support.
@@ -21,7 +23,7 @@ func NewChromaDB(baseURL string) (*ChromaDB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create chroma http client: %w", err)
 	}
-	return &ChromaDB{client: client}, nil
+	return &ChromaDB{client: loggedClient{Client: client}, baseURL: baseURL}, nil
 }
 
 // Close releases underlying resources (e.g., local embedding functions).
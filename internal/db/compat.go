@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// compatTTL is how long a compatibility check is reused before /health
+// probes the server again.
+const compatTTL = 30 * time.Second
+
+// MinServerVersion is the oldest Chroma release serving the v2 API.
+const MinServerVersion = "0.6.0"
+
+// Compatibility describes what the connected Chroma server supports.
+type Compatibility struct {
+	ServerVersion string    `json:"server_version,omitempty"`
+	API           string    `json:"api"`     // "v2", "v1" or "unknown"
+	Tenants       bool      `json:"tenants"` // auth identity reports tenants and databases
+	Tenant        string    `json:"tenant,omitempty"`
+	Database      string    `json:"database,omitempty"`
+	Compatible    bool      `json:"compatible"`
+	Problems      []string  `json:"problems,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+type compatState struct {
+	mu      sync.Mutex
+	last    *Compatibility
+	adapted bool
+}
+
+// Compatibility returns the most recent compatibility check, probing the
+// server again once compatTTL has passed.
+func (c *ChromaDB) Compatibility(ctx context.Context) Compatibility {
+	c.compat.mu.Lock()
+	defer c.compat.mu.Unlock()
+	if c.compat.last != nil && time.Since(c.compat.last.CheckedAt) < compatTTL {
+		return *c.compat.last
+	}
+	return c.detectLocked(ctx)
+}
+
+// DetectCompatibility probes the server's API version and tenant support.
+// On the first successful probe it switches to the caller's tenant and
+// database when the server's auth identity names different ones, so requests
+// are not sent to a default tenant the credentials cannot reach.
+func (c *ChromaDB) DetectCompatibility(ctx context.Context) Compatibility {
+	c.compat.mu.Lock()
+	defer c.compat.mu.Unlock()
+	return c.detectLocked(ctx)
+}
+
+func (c *ChromaDB) detectLocked(ctx context.Context) Compatibility {
+	compat := Compatibility{API: "unknown", CheckedAt: time.Now().UTC()}
+	defer func() { c.compat.last = &compat }()
+
+	version, err := c.client.GetVersion(ctx)
+	if err != nil {
+		if c.probeV1(ctx) {
+			compat.API = "v1"
+			compat.Problems = append(compat.Problems, fmt.Sprintf("server only serves the v1 API; upgrade Chroma to %s or later", MinServerVersion))
+		} else {
+			compat.Problems = append(compat.Problems, fmt.Sprintf("chroma unreachable or not a Chroma server: %v", err))
+		}
+		return compat
+	}
+	compat.API = "v2"
+	compat.ServerVersion = strings.TrimSpace(version)
+	if !versionAtLeast(compat.ServerVersion, MinServerVersion) {
+		compat.Problems = append(compat.Problems, fmt.Sprintf("server version %s is older than %s", compat.ServerVersion, MinServerVersion))
+	}
+
+	identity, err := c.client.GetIdentity(ctx)
+	if err != nil {
+		// Open-source servers without auth may not expose identity; the
+		// default tenant and database still work there.
+		compat.Problems = append(compat.Problems, fmt.Sprintf("tenant discovery unavailable, using %s: %v", chroma.DefaultTenant, err))
+	} else if identity.Tenant != "" {
+		compat.Tenants = true
+		if !c.compat.adapted {
+			if err := c.adaptTenant(ctx, identity); err != nil {
+				compat.Problems = append(compat.Problems, err.Error())
+			}
+			c.compat.adapted = true
+		}
+	}
+	compat.Tenant = c.client.CurrentTenant().Name()
+	compat.Database = c.client.CurrentDatabase().Name()
+	compat.Compatible = compat.API == "v2" && versionAtLeast(compat.ServerVersion, MinServerVersion)
+	return compat
+}
+
+// adaptTenant moves off the default tenant and database when the identity
+// does not include them. Explicitly configured ones (CHROMA_TENANT,
+// CHROMA_DATABASE) are left alone.
+func (c *ChromaDB) adaptTenant(ctx context.Context, identity chroma.Identity) error {
+	if c.client.CurrentTenant().Name() != chroma.DefaultTenant || identity.Tenant == chroma.DefaultTenant {
+		return nil
+	}
+	tenant := chroma.NewTenant(identity.Tenant)
+	if err := c.client.UseTenant(ctx, tenant); err != nil {
+		return fmt.Errorf("switch to tenant %q: %w", identity.Tenant, err)
+	}
+	if len(identity.Databases) == 1 && identity.Databases[0] != chroma.DefaultDatabase && identity.Databases[0] != "*" {
+		if err := c.client.UseDatabase(ctx, chroma.NewDatabase(identity.Databases[0], tenant)); err != nil {
+			return fmt.Errorf("switch to database %q: %w", identity.Databases[0], err)
+		}
+	}
+	return nil
+}
+
+// probeV1 reports whether the server answers on the legacy v1 API.
+func (c *ChromaDB) probeV1(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverRoot(c.baseURL)+"/api/v1/version", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// serverRoot strips any API path from a configured Chroma URL.
+func serverRoot(baseURL string) string {
+	if baseURL == "" {
+		return "http://localhost:8000"
+	}
+	root := strings.TrimRight(baseURL, "/")
+	for _, suffix := range []string{"/api/v2", "/api/v1"} {
+		root = strings.TrimSuffix(root, suffix)
+	}
+	return root
+}
+
+// versionAtLeast compares dotted numeric versions; unparseable versions
+// (e.g. custom builds) are given the benefit of the doubt.
+func versionAtLeast(version, min string) bool {
+	have, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	want, _ := parseVersion(min)
+	for i := range want {
+		if have[i] != want[i] {
+			return have[i] > want[i]
+		}
+	}
+	return true
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
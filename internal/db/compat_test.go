@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectCompatibility(t *testing.T) {
+	cases := []struct {
+		name       string
+		routes     map[string]string
+		api        string
+		compatible bool
+		tenant     string
+	}{
+		{
+			name:       "v2",
+			routes:     map[string]string{"/api/v2/version": `"1.0.15"`, "/api/v2/auth/identity": `{"user_id":"","tenant":"default_tenant","databases":["*"]}`},
+			api:        "v2",
+			compatible: true,
+			tenant:     "default_tenant",
+		},
+		{
+			name:   "v1 only",
+			routes: map[string]string{"/api/v1/version": `"0.5.23"`},
+			api:    "v1",
+		},
+		{
+			name:   "not chroma",
+			routes: map[string]string{},
+			api:    "unknown",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tc.routes[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(body))
+			}))
+			defer srv.Close()
+
+			chromaDB, err := NewChromaDB(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compat := chromaDB.DetectCompatibility(context.Background())
+			if compat.API != tc.api || compat.Compatible != tc.compatible || compat.Tenant != tc.tenant {
+				t.Errorf("unexpected compatibility: %+v", compat)
+			}
+			if !tc.compatible && len(compat.Problems) == 0 {
+				t.Error("incompatible server reported no problems")
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	for v, want := range map[string]bool{"0.6.0": true, "1.0.15": true, "0.5.23": false, "v0.6.3-rc1": true, "custom": true} {
+		if got := versionAtLeast(v, MinServerVersion); got != want {
+			t.Errorf("versionAtLeast(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
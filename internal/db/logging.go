@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// loggedClient logs collection-level Chroma calls with the request-scoped
+// logger carried by ctx, so slow or failing calls are attributable.
+type loggedClient struct {
+	chroma.Client
+}
+
+func logCall(ctx context.Context, op, collection string, start time.Time, err error) {
+	entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"chroma_op":   op,
+		"collection":  collection,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Debug("Chroma call")
+}
+
+func (c loggedClient) CreateCollection(ctx context.Context, name string, options ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	start := time.Now()
+	col, err := c.Client.CreateCollection(ctx, name, options...)
+	logCall(ctx, "create_collection", name, start, err)
+	return col, err
+}
+
+func (c loggedClient) GetOrCreateCollection(ctx context.Context, name string, options ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	start := time.Now()
+	col, err := c.Client.GetOrCreateCollection(ctx, name, options...)
+	logCall(ctx, "get_or_create_collection", name, start, err)
+	return col, err
+}
+
+func (c loggedClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	start := time.Now()
+	col, err := c.Client.GetCollection(ctx, name, opts...)
+	logCall(ctx, "get_collection", name, start, err)
+	return col, err
+}
+
+func (c loggedClient) DeleteCollection(ctx context.Context, name string, options ...chroma.DeleteCollectionOption) error {
+	start := time.Now()
+	err := c.Client.DeleteCollection(ctx, name, options...)
+	logCall(ctx, "delete_collection", name, start, err)
+	return err
+}
@@ -1,28 +1,86 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/db"
 	"github.com/typicalfo/forge/backend/internal/logging"
 	"github.com/typicalfo/forge/backend/internal/services"
 )
 
 type APIHandlers struct {
-	ingestService *services.IngestService
-	configStore   ConfigProvider
+	ingestService   *services.IngestService
+	configStore     ConfigProvider
+	archiveService  *services.ArchiveService
+	pipelineService *services.PipelineService
+	derivedService  *services.DerivedService
+	sourceService   *services.SourceService
+	usageService    *services.UsageService
+	reportService   *services.ReportService
+	costTracker     *services.CostTracker
+	crawlService    *services.CrawlService
+	gitService      *services.GitService
+	bucketService   *services.BucketService
+	feedService     *services.FeedService
+	doctorService   *services.DoctorService
+	setupService    *services.SetupService
+	urlSigner       *services.URLSigner
+	bulkService     *services.BulkService
+	replicaService  *services.ReplicaService
+	mirror          *services.Mirror
+	chroma          ChromaReporter
 }
 
 func NewAPIHandlers(ingestService *services.IngestService) *APIHandlers {
 	return &APIHandlers{ingestService: ingestService}
 }
 
+// ChromaReporter reports what the connected Chroma server supports.
+type ChromaReporter interface {
+	Compatibility(ctx context.Context) db.Compatibility
+}
+
+// WithChromaReporter includes Chroma compatibility in /health.
+func (h *APIHandlers) WithChromaReporter(r ChromaReporter) *APIHandlers {
+	_h := *h
+	_h.chroma = r
+	return &_h
+}
+
+// Health reports "degraded" (still 200) when the Chroma server is
+// unreachable or incompatible, with the problems found.
 func (h *APIHandlers) Health(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	if h.chroma == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+	compat := h.chroma.Compatibility(c.Request.Context())
+	status := "ok"
+	if !compat.Compatible {
+		status = "degraded"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "chroma": compat})
+}
+
+// mcpTransport reports the effective MCP transport; single-port mode serves
+// MCP over HTTP regardless of mcp_transport.
+func mcpTransport(vals config.Values) string {
+	if vals.SinglePort {
+		return "http"
+	}
+	return vals.MCPTransport
 }
 
 // Config returns runtime configuration for the local app (no .env usage)
@@ -45,7 +103,8 @@ func (h *APIHandlers) Config(c *gin.Context) {
 		"backend_http_port":  vals.BackendHTTPPort,
 		"chroma_url":         vals.ChromaURL,
 		"default_collection": vals.CollectionName,
-		"mcp_transport":      vals.MCPTransport,
+		"mcp_transport":      mcpTransport(vals),
+		"single_port":        vals.SinglePort,
 	})
 }
 
@@ -62,6 +121,16 @@ func (h *APIHandlers) Ingest(c *gin.Context) {
 	}
 }
 
+// IngestBatching reports the current adaptive ingest batch settings.
+func (h *APIHandlers) IngestBatching(c *gin.Context) {
+	c.JSON(http.StatusOK, h.ingestService.BatchState())
+}
+
+// IngestTimings reports where ingestion has spent its time since start.
+func (h *APIHandlers) IngestTimings(c *gin.Context) {
+	c.JSON(http.StatusOK, h.ingestService.IngestTimings())
+}
+
 func (h *APIHandlers) handleFileUpload(c *gin.Context) {
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -77,19 +146,64 @@ func (h *APIHandlers) handleFileUpload(c *gin.Context) {
 	}
 
 	// Get collection name from form
-	collectionName := c.PostForm("collection_id")
-	if collectionName == "" {
+	scoped, ok := scopeCollections(c, c.PostForm("collection_id"))
+	if !ok {
+		return
+	}
+	if len(scoped) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "collection_id is required"})
 		return
 	}
+	collectionName := scoped[0]
 
 	// Optional metadata
+	var invalid fieldErrors
+	invalid.collections(h.ingestService, "collection_id", collectionName)
 	var userMetadata map[string]interface{}
 	if metadataStr := c.PostForm("metadata"); metadataStr != "" {
 		if err := json.Unmarshal([]byte(metadataStr), &userMetadata); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata JSON"})
+			invalid.add("metadata", "must be a JSON object: %v", err)
+		}
+	}
+	invalid.metadata("metadata", userMetadata)
+	if invalid.respond(c) {
+		return
+	}
+
+	// Optional ACL: comma-separated principals allowed to see these files
+	acl := services.ParsePrincipals(c.PostForm("acl"))
+
+	// Optional XPath mapping for XML files
+	var xmlMapping *services.XMLMapping
+	if mappingStr := c.PostForm("xml_mapping"); mappingStr != "" {
+		var mapping services.XMLMapping
+		if err := json.Unmarshal([]byte(mappingStr), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid xml_mapping JSON"})
+			return
+		}
+		if err := mapping.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		xmlMapping = &mapping
+	}
+
+	// Optional chunking strategy overriding the collection's
+	chunker := c.PostForm("chunker")
+	if chunker != "" {
+		if _, err := services.GetChunker(chunker); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Optional replace: a changed file replaces its earlier versions
+	replace := c.PostForm("replace") == "true"
+
+	// Every upload batch is a source; callers may name it to group batches
+	source := services.NewUploadSource()
+	if id := c.PostForm("source_id"); id != "" {
+		source.ID = id
 	}
 
 	var results []services.IngestResult
@@ -107,66 +221,236 @@ func (h *APIHandlers) handleFileUpload(c *gin.Context) {
 			continue
 		}
 
-		// Pass user metadata to the service
-		result, err := h.ingestService.IngestFile(c.Request.Context(), collectionName, fileHeader.Filename, buf, userMetadata)
-		if err != nil {
-			results = append(results, services.IngestResult{Status: "error", File: fileHeader.Filename})
-			continue
+		// Pass user metadata to the service; archives yield a result per member
+		results = append(results, h.ingestService.IngestUpload(c.Request.Context(), collectionName, fileHeader.Filename, buf, services.IngestOptions{
+			Metadata: userMetadata,
+			ACL:      acl,
+			Source:   source,
+			XML:      xmlMapping,
+			Chunker:  chunker,
+			Replace:  replace,
+		})...)
+	}
+
+	usage := config.Usage{}
+	for _, r := range results {
+		if r.Status == "ingested" {
+			usage.IngestFiles++
+			usage.IngestChunks += r.Chunks
 		}
-		results = append(results, *result)
 	}
+	h.recordUsage(c, usage)
 
-	c.JSON(http.StatusOK, gin.H{"results": results})
+	c.JSON(http.StatusOK, gin.H{"results": results, "source_id": source.ID})
+}
+
+// SupportedTypes lists the file types the server can ingest.
+func (h *APIHandlers) SupportedTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"types": h.ingestService.SupportedTypes()})
+}
+
+// ingestTextRequest is the JSON body of /api/ingest. Its tags also describe
+// the ingest tool (see ToolDefinitions).
+type ingestTextRequest struct {
+	Collection string                 `json:"collection" jsonschema:"the collection to add the text to (default: the API key's collection)"`
+	ID         string                 `json:"id" jsonschema:"optional document id; derived from the text when omitted"`
+	Text       string                 `json:"text" binding:"required" jsonschema:"the text to ingest"`
+	Metadata   map[string]interface{} `json:"metadata" jsonschema:"optional metadata stored with the document"`
+	ACL        []string               `json:"acl" jsonschema:"optional principals allowed to see the document"`
+	SourceID   string                 `json:"source_id" jsonschema:"optional id of the source the text comes from"`
 }
 
 func (h *APIHandlers) handleDirectText(c *gin.Context) {
-	var req struct {
-		Collection string                 `json:"collection" binding:"required"`
-		ID         string                 `json:"id"`
-		Text       string                 `json:"text" binding:"required"`
-		Metadata   map[string]interface{} `json:"metadata"`
+	var req ingestTextRequest
+	if !bindJSON(c, &req) {
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var invalid fieldErrors
+	invalid.collections(h.ingestService, "collection", req.Collection)
+	invalid.metadata("metadata", req.Metadata)
+	if invalid.respond(c) {
 		return
 	}
 
-	id, err := h.ingestService.CreateDocDirect(c.Request.Context(), req.Collection, req.ID, req.Text, req.Metadata)
+	scoped, ok := scopeCollections(c, req.Collection)
+	if !ok {
+		return
+	}
+	if len(scoped) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection is required"})
+		return
+	}
+	req.Collection = scoped[0]
+
+	var source services.IngestSource
+	if req.SourceID != "" {
+		source = services.IngestSource{ID: req.SourceID, Kind: services.SourceText}
+	}
+	id, err := h.ingestService.CreateDocDirect(c.Request.Context(), req.Collection, req.ID, req.Text, req.Metadata, req.ACL, source)
 	if err != nil {
-		if strings.Contains(err.Error(), "conflict") {
+		if errors.Is(err, services.ErrInvalidCollectionName) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrDimensionMismatch) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSnapshotReadOnly) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrCollectionTrashed) || strings.Contains(err.Error(), "conflict") {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.recordUsage(c, config.Usage{IngestFiles: 1, IngestChunks: 1})
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
 
+// searchRequest is the body of /search; its tags also describe the search tool.
+type searchRequest struct {
+	Query        string                 `json:"query" binding:"required" jsonschema:"the search query to find similar documents"`
+	CollectionId string                 `json:"collection_id" jsonschema:"the collection to search in"`
+	Collections  []string               `json:"collections,omitempty" jsonschema:"further collections to search, merged by distance"`
+	K            int                    `json:"k,omitempty" jsonschema:"number of results to return (default: 5 unless the server sets search_default_k)"`
+	Filter       map[string]interface{} `json:"filter,omitempty" jsonschema:"optional metadata equality filter"`
+	Dedupe       bool                   `json:"dedupe,omitempty" jsonschema:"collapse results with near-identical text"`
+	TimeoutMS    int                    `json:"timeout_ms,omitempty" jsonschema:"query timeout in milliseconds"`
+	Hybrid       bool                   `json:"hybrid,omitempty" jsonschema:"fuse keyword matches with the vector search"`
+	Exclude      services.Exclusion     `json:"exclude,omitempty" jsonschema:"chunk ids, file_md5s and metadata values to leave out, e.g. results already seen"`
+	SessionID    string                 `json:"session_id,omitempty" jsonschema:"exclude results earlier searches with this session returned"`
+	Parents      bool                   `json:"parents,omitempty" jsonschema:"return the larger parent section of each matched chunk, in collections chunked with a parent_size"`
+}
+
 func (h *APIHandlers) Search(c *gin.Context) {
-	var req struct {
-		Query        string                 `json:"query" binding:"required"`
-		CollectionId string                 `json:"collection_id" binding:"required"`
-		K            int                    `json:"k,omitempty"`
-		Filter       map[string]interface{} `json:"filter,omitempty"`
+	var req searchRequest
+	if !bindJSON(c, &req) {
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var invalid fieldErrors
+	invalid.k(h.ingestService, "k", &req.K)
+	invalid.collections(h.ingestService, "collection_id", req.CollectionId)
+	invalid.collections(h.ingestService, "collections", req.Collections...)
+	invalid.filter("filter", req.Filter)
+	if req.TimeoutMS < 0 {
+		invalid.add("timeout_ms", "must not be negative")
+	}
+	if err := req.Exclude.Validate(); err != nil {
+		invalid.add("exclude", "%v", err)
+	}
+	if invalid.respond(c) {
 		return
 	}
 
-	if req.K == 0 {
-		req.K = 5
+	collections, ok := scopeCollections(c, append([]string{req.CollectionId}, req.Collections...)...)
+	if !ok {
+		return
+	}
+	if len(collections) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection_id or collections is required"})
+		return
 	}
 
 	// Pass filter to service layer
-	results, err := h.ingestService.Search(c.Request.Context(), req.CollectionId, req.Query, req.K, req.Filter)
+	resp, err := h.ingestService.MultiSearch(c.Request.Context(), collections, req.Query, req.K, req.Filter, services.SearchOptions{
+		Dedupe:    req.Dedupe,
+		Timeout:   time.Duration(req.TimeoutMS) * time.Millisecond,
+		Hybrid:    req.Hybrid,
+		Exclude:   req.Exclude,
+		SessionID: req.SessionID,
+		Parents:   req.Parents,
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrCollectionTrashed) || errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrDimensionMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if vagueQuery(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.recordUsage(c, config.Usage{Searches: 1})
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Answer generates an answer to a question from search results.
+// answerRequest is the body of /answer; its tags also describe the answer tool.
+type answerRequest struct {
+	Question     string                 `json:"question" binding:"required" jsonschema:"the question to answer from the documents"`
+	CollectionId string                 `json:"collection_id" jsonschema:"the collection to search for sources"`
+	Collections  []string               `json:"collections,omitempty" jsonschema:"further collections to search for sources"`
+	K            int                    `json:"k,omitempty" jsonschema:"number of sources to answer from (default: 5 unless the server sets search_default_k)"`
+	Filter       map[string]interface{} `json:"filter,omitempty" jsonschema:"optional metadata equality filter for sources"`
+	Template     string                 `json:"template,omitempty" jsonschema:"name of a stored prompt template"`
+	Decompose    bool                   `json:"decompose,omitempty" jsonschema:"split a comparative question into sub-questions searched separately"`
+	services.GenerationParams
+}
+
+func (h *APIHandlers) Answer(c *gin.Context) {
+	var req answerRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	var invalid fieldErrors
+	invalid.k(h.ingestService, "k", &req.K)
+	invalid.collections(h.ingestService, "collection_id", req.CollectionId)
+	invalid.collections(h.ingestService, "collections", req.Collections...)
+	invalid.filter("filter", req.Filter)
+	if invalid.respond(c) {
+		return
+	}
+
+	collections, ok := scopeCollections(c, append([]string{req.CollectionId}, req.Collections...)...)
+	if !ok {
+		return
+	}
+	if len(collections) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection_id or collections is required"})
+		return
+	}
+
+	opts := services.AnswerOptions{Template: req.Template, GenerationParams: req.GenerationParams, Decompose: req.Decompose}
+	resp, err := h.ingestService.Answer(c.Request.Context(), collections, req.Question, req.K, req.Filter, opts)
+	if errors.Is(err, services.ErrDimensionMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if vagueQuery(c, err) {
+		return
+	}
+	if err != nil {
+		generationError(c, err)
+		return
+	}
+	if !resp.Cached {
+		h.recordUsage(c, config.Usage{Searches: 1})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
 
-	c.JSON(http.StatusOK, gin.H{"results": results})
+// ResetSearchSession forgets the chunks a search session has returned.
+func (h *APIHandlers) ResetSearchSession(c *gin.Context) {
+	if err := h.ingestService.ResetSearchSession(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
 func (h *APIHandlers) ListCollections(c *gin.Context) {
@@ -181,16 +465,23 @@ func (h *APIHandlers) ListCollections(c *gin.Context) {
 
 func (h *APIHandlers) CreateCollection(c *gin.Context) {
 	var req struct {
-		Name        string `json:"name" binding:"required"`
-		Description string `json:"description,omitempty"`
+		Name        string                 `json:"name" binding:"required"`
+		Description string                 `json:"description,omitempty"`
+		Metadata    map[string]interface{} `json:"metadata,omitempty"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	collection, err := h.ingestService.CreateCollection(c.Request.Context(), req.Name, req.Description)
-	if err != nil {
+	collection, err := h.ingestService.CreateCollection(c.Request.Context(), req.Name, req.Description, req.Metadata)
+	switch {
+	case errors.Is(err, services.ErrInvalidCollectionName), errors.Is(err, services.ErrInvalidCollectionMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrCollectionNameConflict), errors.Is(err, services.ErrCollectionMetadataLocked), errors.Is(err, services.ErrCollectionTrashed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -205,16 +496,16 @@ func (h *APIHandlers) GetCollectionDocuments(c *gin.Context) {
 		return
 	}
 
-	logging.GetLogger().WithField("collectionId", collectionId).Info("Fetching documents for collection")
+	logging.FromContext(c.Request.Context()).WithField("collectionId", collectionId).Info("Fetching documents for collection")
 
 	documents, err := h.ingestService.GetCollectionDocuments(c.Request.Context(), collectionId)
 	if err != nil {
-		logging.GetLogger().WithError(err).WithField("collectionId", collectionId).Error("Failed to get collection documents")
+		logging.FromContext(c.Request.Context()).WithError(err).WithField("collectionId", collectionId).Error("Failed to get collection documents")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	logging.GetLogger().WithFields(logrus.Fields{
+	logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
 		"collectionId":  collectionId,
 		"documentCount": len(documents),
 	}).Info("Successfully retrieved collection documents")
@@ -223,19 +514,113 @@ func (h *APIHandlers) GetCollectionDocuments(c *gin.Context) {
 }
 
 // New canonical handlers
+
+// DeleteCollection moves a collection to the trash when a grace period is
+// configured (202), or deletes it right away (204); ?purge=true skips the
+// trash.
 func (h *APIHandlers) DeleteCollection(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "collection name is required"})
 		return
 	}
-	if err := h.ingestService.DeleteCollection(c.Request.Context(), name); err != nil {
+	force := c.Query("force") == "true"
+	if c.Query("purge") == "true" {
+		if err := h.ingestService.DeleteCollection(c.Request.Context(), name, force); err != nil {
+			protectionError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+	entry, err := h.ingestService.TrashCollection(c.Request.Context(), name, force)
+	if err != nil {
+		protectionError(c, err)
+		return
+	}
+	if entry != nil {
+		c.JSON(http.StatusAccepted, gin.H{"trash": entry})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListTrash lists the collections pending deletion.
+func (h *APIHandlers) ListTrash(c *gin.Context) {
+	entries, err := h.ingestService.ListTrash(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"trash": entries})
+}
+
+// RestoreCollection takes a collection out of the trash.
+func (h *APIHandlers) RestoreCollection(c *gin.Context) {
+	if err := h.ingestService.RestoreCollection(c.Request.Context(), c.Param("name")); err != nil {
+		protectionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": c.Param("name"), "restored": true})
+}
+
+// PurgeCollection deletes a collection in the trash without waiting for
+// its grace period to end.
+func (h *APIHandlers) PurgeCollection(c *gin.Context) {
+	if err := h.ingestService.PurgeCollection(c.Request.Context(), c.Param("name"), c.Query("force") == "true"); err != nil {
+		protectionError(c, err)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
+// GetCollectionProtection reports whether a collection is protected.
+func (h *APIHandlers) GetCollectionProtection(c *gin.Context) {
+	protected, err := h.ingestService.IsProtected(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": c.Param("name"), "protected": protected})
+}
+
+// SetCollectionProtection marks or unmarks a collection as protected (admin only).
+func (h *APIHandlers) SetCollectionProtection(c *gin.Context) {
+	var req struct {
+		Protected *bool `json:"protected" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := h.ingestService.SetProtected(c.Request.Context(), c.Param("name"), *req.Protected); err != nil {
+		protectionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": c.Param("name"), "protected": *req.Protected})
+}
+
+// vagueQuery answers 422 for a query with no searchable terms, flagged so
+// clients can ask for a better one, and reports whether it did.
+func vagueQuery(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrVagueQuery) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "query_too_vague": true})
+	return true
+}
+
+func protectionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrCollectionProtected) || errors.Is(err, services.ErrAdminRequired) || errors.Is(err, services.ErrSnapshotReadOnly) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrNotInTrash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
 func (h *APIHandlers) ListDocs(c *gin.Context) {
 	collection := c.Param("collection")
 	if collection == "" {
@@ -250,6 +635,67 @@ func (h *APIHandlers) ListDocs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"documents": docs})
 }
 
+// GetDoc returns one document with its content and metadata; ?embedding=true
+// adds its vector.
+func (h *APIHandlers) GetDoc(c *gin.Context) {
+	doc, err := h.ingestService.GetDoc(c.Request.Context(), c.Param("collection"), c.Param("id"), c.Query("embedding") == "true")
+	switch {
+	case errors.Is(err, services.ErrDocNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"document": doc})
+}
+
+// DocVersions lists the kept versions of the file a document belongs to.
+func (h *APIHandlers) DocVersions(c *gin.Context) {
+	history, err := h.ingestService.DocVersions(c.Request.Context(), c.Param("collection"), c.Param("id"))
+	if versionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// RollbackDoc restores a kept version of the file a document belongs to.
+func (h *APIHandlers) RollbackDoc(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive integer"})
+		return
+	}
+	result, err := h.ingestService.RollbackDoc(c.Request.Context(), c.Param("collection"), c.Param("id"), version)
+	if versionError(c, err) {
+		return
+	}
+	h.recordUsage(c, config.Usage{IngestFiles: 1, IngestChunks: result.Moved + result.Embedded})
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+// versionError answers a document versioning error and reports whether
+// there was one.
+func versionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrVersioningDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocNotFound), errors.Is(err, services.ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotVersioned):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSnapshotReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDimensionMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return true
+}
+
 func (h *APIHandlers) DeleteDoc(c *gin.Context) {
 	collection := c.Param("collection")
 	id := c.Param("id")
@@ -258,8 +704,426 @@ func (h *APIHandlers) DeleteDoc(c *gin.Context) {
 		return
 	}
 	if err := h.ingestService.DeleteDoc(c.Request.Context(), collection, id); err != nil {
+		if errors.Is(err, services.ErrSnapshotReadOnly) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// DeleteDocs deletes every chunk of a collection whose metadata matches the
+// filter in the body, e.g. {"file_md5": "..."}.
+func (h *APIHandlers) DeleteDocs(c *gin.Context) {
+	var filter map[string]interface{}
+	if !bindJSON(c, &filter) {
+		return
+	}
+	var invalid fieldErrors
+	if len(filter) == 0 {
+		invalid.add("", "%s", services.ErrEmptyDeleteFilter)
+	}
+	invalid.filter("", filter)
+	if invalid.respond(c) {
+		return
+	}
+	deleted, err := h.ingestService.DeleteWhere(c.Request.Context(), c.Param("collection"), filter, c.Query("force") == "true")
+	if err != nil {
+		protectionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// FileContent returns a file's text reassembled from its chunks.
+func (h *APIHandlers) FileContent(c *gin.Context) {
+	h.fileContent(c, c.Param("name"), c.Param("md5"))
+}
+
+// ReconstructFile serves GET /files/:collection/:file_md5, the same text as
+// FileContent.
+func (h *APIHandlers) ReconstructFile(c *gin.Context) {
+	h.fileContent(c, c.Param("collection"), c.Param("file_md5"))
+}
+
+func (h *APIHandlers) fileContent(c *gin.Context, collection, md5 string) {
+	content, err := h.ingestService.FileContent(c.Request.Context(), collection, md5)
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"file": content})
+}
+
+// DeleteFile deletes every chunk of an ingested file.
+func (h *APIHandlers) DeleteFile(c *gin.Context) {
+	h.deleteFile(c, c.Param("name"), c.Param("md5"))
+}
+
+// DeleteFileByHash serves DELETE /files/:collection/:file_md5, the same
+// delete as DeleteFile.
+func (h *APIHandlers) DeleteFileByHash(c *gin.Context) {
+	h.deleteFile(c, c.Param("collection"), c.Param("file_md5"))
+}
+
+func (h *APIHandlers) deleteFile(c *gin.Context, collection, md5 string) {
+	deleted, err := h.ingestService.DeleteFile(c.Request.Context(), collection, md5)
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSnapshotReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// DownloadFile streams a file's original bytes from the blob store.
+func (h *APIHandlers) DownloadFile(c *gin.Context) {
+	h.serveOriginal(c, c.Request.Context(), c.Param("name"), c.Param("md5"))
+}
+
+// serveOriginal streams a stored original read with the principals in ctx.
+func (h *APIHandlers) serveOriginal(c *gin.Context, ctx context.Context, collection, md5Hash string) {
+	file, err := h.ingestService.OpenOriginal(ctx, collection, md5Hash)
+	switch {
+	case errors.Is(err, services.ErrBlobsDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrBlobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Body.Close()
+	name := path.Base(file.FileName)
+	if name == "." || name == "/" {
+		name = file.FileMD5
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Header("ETag", `"`+file.FileMD5+`"`)
+	c.DataFromReader(http.StatusOK, -1, file.MIME, file.Body, nil)
+}
+
+// FilePreview returns a JPEG thumbnail of a stored PDF or image; ?size sets
+// the longer side in pixels.
+func (h *APIHandlers) FilePreview(c *gin.Context) {
+	size, _ := strconv.Atoi(c.Query("size"))
+	preview, err := h.ingestService.Preview(c.Request.Context(), c.Param("name"), c.Param("md5"), size)
+	switch {
+	case errors.Is(err, services.ErrBlobsDisabled), errors.Is(err, services.ErrPreviewUnavailable):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrBlobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoPreview):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", `"`+preview.FileMD5+"-"+strconv.Itoa(preview.Size)+`"`)
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, "image/jpeg", preview.Data)
+}
+
+// UpdateFileText replaces the text of an ingested file, rewriting only the
+// chunks that changed.
+func (h *APIHandlers) UpdateFileText(c *gin.Context) {
+	var req struct {
+		File     string                 `json:"file" binding:"required"`
+		Text     string                 `json:"text" binding:"required"`
+		Metadata map[string]interface{} `json:"metadata"`
+		ACL      []string               `json:"acl"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	opts := services.IngestOptions{Metadata: req.Metadata, ACL: req.ACL}
+	result, err := h.ingestService.UpdateFileText(c.Request.Context(), c.Param("collection"), req.File, req.Text, opts)
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSnapshotReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrDimensionMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordUsage(c, config.Usage{IngestFiles: 1, IngestChunks: result.Moved + result.Embedded})
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+// CollectionAdvisor recommends re-chunking, dedupe or cleanup for a collection.
+func (h *APIHandlers) CollectionAdvisor(c *gin.Context) {
+	name := c.Param("name")
+	report, err := h.ingestService.Advise(c.Request.Context(), name)
+	if errors.Is(err, services.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CollectionSchema describes the metadata keys present in a collection.
+func (h *APIHandlers) CollectionSchema(c *gin.Context) {
+	schema, err := h.ingestService.Schema(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+// CollectionFacets returns value counts for metadata keys, e.g.
+// ?keys=user_category,language&limit=20. An optional filter query parameter
+// holds a JSON equality filter.
+func (h *APIHandlers) CollectionFacets(c *gin.Context) {
+	keys := strings.Split(c.Query("keys"), ",")
+	n := 0
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			keys[n] = k
+			n++
+		}
+	}
+	keys = keys[:n]
+	if len(keys) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keys is required"})
+		return
+	}
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = l
+	}
+	var filter map[string]interface{}
+	if v := c.Query("filter"); v != "" {
+		if err := json.Unmarshal([]byte(v), &filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter JSON"})
+			return
+		}
+	}
+	facets, err := h.ingestService.Facets(c.Request.Context(), c.Param("name"), keys, filter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"facets": facets})
+}
+
+// GetCollectionAnalyzer returns the lexical analyzer settings for a collection.
+func (h *APIHandlers) GetCollectionAnalyzer(c *gin.Context) {
+	settings, err := h.ingestService.CollectionAnalyzer(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"analyzer": settings, "languages": services.AnalyzerLanguages()})
+}
+
+// SetCollectionAnalyzer stores the lexical analyzer settings for a collection.
+func (h *APIHandlers) SetCollectionAnalyzer(c *gin.Context) {
+	settings := services.DefaultAnalyzerSettings
+	if !bindJSON(c, &settings) {
+		return
+	}
+	if err := h.ingestService.SetCollectionAnalyzer(c.Param("name"), settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"analyzer": settings})
+}
+
+// GetCollectionMetadata returns the metadata a collection is created with
+// and, once it exists, the metadata Chroma reports for it.
+func (h *APIHandlers) GetCollectionMetadata(c *gin.Context) {
+	stored, live, err := h.ingestService.CollectionMetadata(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"metadata": stored, "live": live})
+}
+
+// SetCollectionMetadata stores the metadata a collection not yet in Chroma
+// is created with.
+func (h *APIHandlers) SetCollectionMetadata(c *gin.Context) {
+	var md map[string]interface{}
+	if !bindJSON(c, &md) {
+		return
+	}
+	md, err := h.ingestService.SetCollectionMetadata(c.Request.Context(), c.Param("name"), md)
+	switch {
+	case errors.Is(err, services.ErrCollectionMetadataLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"metadata": md})
+}
+
+// GetCollectionTokenizer returns the tokenizer used to chunk and measure a collection.
+func (h *APIHandlers) GetCollectionTokenizer(c *gin.Context) {
+	tokenizer, err := h.ingestService.CollectionTokenizer(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokenizer": tokenizer.Name(), "available": services.Tokenizers()})
+}
+
+// SetCollectionTokenizer selects a collection's tokenizer.
+func (h *APIHandlers) SetCollectionTokenizer(c *gin.Context) {
+	var req struct {
+		Tokenizer string `json:"tokenizer" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := h.ingestService.SetCollectionTokenizer(c.Param("name"), req.Tokenizer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokenizer": req.Tokenizer})
+}
+
+// GetCollectionChunking returns how a collection's files are split into chunks.
+func (h *APIHandlers) GetCollectionChunking(c *gin.Context) {
+	chunking, err := h.ingestService.CollectionChunking(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(chunking.Separators) == 0 && chunking.Strategy == services.ChunkRecursive {
+		chunking.Separators = services.DefaultChunkSeparators
+	}
+	c.JSON(http.StatusOK, gin.H{"chunking": chunking, "available": services.Chunkers()})
+}
+
+// SetCollectionChunking stores a collection's chunking profile: strategy,
+// separators, size, overlap and parent size.
+func (h *APIHandlers) SetCollectionChunking(c *gin.Context) {
+	var req services.Chunking
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := h.ingestService.SetCollectionChunking(c.Param("name"), req); err != nil {
+		if errors.Is(err, services.ErrInvalidChunking) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"chunking": req})
+}
+
+// GetCollectionTitleBoost returns how strongly title similarity affects a collection's ranking.
+func (h *APIHandlers) GetCollectionTitleBoost(c *gin.Context) {
+	boost, err := h.ingestService.CollectionTitleBoost(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"title_boost": boost})
+}
+
+// SetCollectionTitleBoost stores a collection's title weight, embedding the
+// titles of its existing documents when enabled.
+func (h *APIHandlers) SetCollectionTitleBoost(c *gin.Context) {
+	var boost services.TitleBoost
+	if !bindJSON(c, &boost) {
+		return
+	}
+	if err := boost.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	titles, err := h.ingestService.SetCollectionTitleBoost(c.Request.Context(), c.Param("name"), boost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"title_boost": boost, "titles": titles})
+}
+
+// GetCollectionGuardrails returns the checks applied to answers drawn from a collection.
+func (h *APIHandlers) GetCollectionGuardrails(c *gin.Context) {
+	g, err := h.ingestService.CollectionGuardrails(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"guardrails": g})
+}
+
+// SetCollectionGuardrails stores a collection's answer guardrails.
+func (h *APIHandlers) SetCollectionGuardrails(c *gin.Context) {
+	var g services.Guardrails
+	if !bindJSON(c, &g) {
+		return
+	}
+	if err := g.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.ingestService.SetCollectionGuardrails(c.Param("name"), g); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"guardrails": g})
+}
+
+// CountTokens counts tokens in text with a named tokenizer or a collection's.
+func (h *APIHandlers) CountTokens(c *gin.Context) {
+	var req struct {
+		Text       string `json:"text" binding:"required"`
+		Tokenizer  string `json:"tokenizer"`
+		Collection string `json:"collection"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	var tokenizer services.Tokenizer
+	var err error
+	if req.Tokenizer != "" {
+		tokenizer, err = services.GetTokenizer(req.Tokenizer)
+	} else {
+		tokenizer, err = h.ingestService.CollectionTokenizer(req.Collection)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokenizer": tokenizer.Name(), "tokens": tokenizer.Count(req.Text)})
+}
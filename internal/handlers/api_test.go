@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/typicalfo/forge/backend/internal/db"
 	"github.com/typicalfo/forge/backend/internal/services"
@@ -92,3 +95,100 @@ func TestAPIHandlers_Search(t *testing.T) {
 	}
 	t.Logf("Response: %s", w.Body.String())
 }
+
+// chunkCollection returns all of its chunks from every Get, ignoring filters.
+type chunkCollection struct {
+	chroma.Collection
+	ids       chroma.DocumentIDs
+	docs      chroma.Documents
+	metadatas chroma.DocumentMetadatas
+}
+
+func (c chunkCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if op.Offset > 0 {
+		return &chroma.GetResultImpl{}, nil
+	}
+	return &chroma.GetResultImpl{Ids: c.ids, Documents: c.docs, Metadatas: c.metadatas}, nil
+}
+
+func (c chunkCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	return nil
+}
+
+type chunkClient struct {
+	chroma.Client
+	collection chunkCollection
+}
+
+func (c chunkClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if name != "docs" {
+		return nil, errors.New("collection not found")
+	}
+	return c.collection, nil
+}
+
+func TestAPIHandlers_ReconstructFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	col := chunkCollection{}
+	for i, text := range []string{"world", "hello "} {
+		index := 1 - i
+		col.ids = append(col.ids, chroma.DocumentID(fmt.Sprintf("c%d", index)))
+		col.docs = append(col.docs, chroma.NewTextDocument(text))
+		col.metadatas = append(col.metadatas, chroma.NewDocumentMetadata(
+			chroma.NewStringAttribute("file_md5", "abc123"),
+			chroma.NewStringAttribute("file_name", "hello.txt"),
+			chroma.NewIntAttribute("chunk_index", int64(index)),
+		))
+	}
+	handlers := NewAPIHandlers(services.NewIngestService(chunkClient{collection: col}))
+	router := gin.New()
+	router.GET("/files/:collection/:file_md5", handlers.ReconstructFile)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/docs/abc123", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct{ File services.FileContent }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.File.Content != "hello world" || resp.File.FileName != "hello.txt" || resp.File.Chunks != 2 {
+		t.Errorf("expected the chunks stitched in chunk_index order, got %+v", resp.File)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/other/abc123", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown collection, got %d", w.Code)
+	}
+}
+
+func TestAPIHandlers_DeleteFileByHash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	col := chunkCollection{
+		ids:       chroma.DocumentIDs{"c0", "c1"},
+		docs:      chroma.Documents{chroma.NewTextDocument("hello "), chroma.NewTextDocument("world")},
+		metadatas: chroma.DocumentMetadatas{chroma.NewDocumentMetadata(), chroma.NewDocumentMetadata()},
+	}
+	for _, tc := range []struct {
+		col  chunkCollection
+		code int
+	}{
+		{col, http.StatusOK},
+		{chunkCollection{}, http.StatusNotFound},
+	} {
+		handlers := NewAPIHandlers(services.NewIngestService(chunkClient{collection: tc.col}))
+		router := gin.New()
+		router.DELETE("/files/:collection/:file_md5", handlers.DeleteFileByHash)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/files/docs/abc123", nil))
+		if w.Code != tc.code {
+			t.Errorf("expected %d, got %d: %s", tc.code, w.Code, w.Body.String())
+		}
+	}
+}
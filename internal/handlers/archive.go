@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithArchiveService(svc *services.ArchiveService) *APIHandlers {
+	_h := *h
+	_h.archiveService = svc
+	return &_h
+}
+
+// ArchiveCollection exports a collection to cold storage and removes it from Chroma.
+func (h *APIHandlers) ArchiveCollection(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	name := c.Param("name")
+	info, err := h.archiveService.Archive(c.Request.Context(), name)
+	if err != nil {
+		if errors.Is(err, services.ErrArchiveExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"archive": info})
+}
+
+// ListArchives lists archived collections.
+func (h *APIHandlers) ListArchives(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	archives, err := h.archiveService.ListArchives()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"archives": archives})
+}
+
+// RestoreArchive recreates an archived collection in Chroma.
+func (h *APIHandlers) RestoreArchive(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	name := c.Param("name")
+	n, err := h.archiveService.Restore(c.Request.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrArchiveNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "conflict"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": name, "records": n})
+}
+
+// CreateSnapshot stores the current contents of a collection under a name.
+func (h *APIHandlers) CreateSnapshot(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	info, err := h.archiveService.Snapshot(c.Request.Context(), c.Param("name"), req.Name)
+	if err != nil {
+		snapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"snapshot": info})
+}
+
+// ListSnapshots lists a collection's snapshots.
+func (h *APIHandlers) ListSnapshots(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	snapshots, err := h.archiveService.ListSnapshots(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": snapshots})
+}
+
+// DeleteSnapshot removes a snapshot.
+func (h *APIHandlers) DeleteSnapshot(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	if err := h.archiveService.DeleteSnapshot(c.Request.Context(), c.Param("name"), c.Param("snapshot")); err != nil {
+		snapshotError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SearchSnapshot searches a collection as it was when a snapshot was taken.
+func (h *APIHandlers) SearchSnapshot(c *gin.Context) {
+	if h.archiveService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "archival is not configured"})
+		return
+	}
+	var req struct {
+		Query  string                 `json:"query" binding:"required"`
+		K      int                    `json:"k,omitempty"`
+		Filter map[string]interface{} `json:"filter,omitempty"`
+		Dedupe bool                   `json:"dedupe,omitempty"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	var invalid fieldErrors
+	invalid.k(h.ingestService, "k", &req.K)
+	if invalid.respond(c) {
+		return
+	}
+	name, snapshot := c.Param("name"), c.Param("snapshot")
+	mounted, err := h.archiveService.MountSnapshot(c.Request.Context(), name, snapshot)
+	if err != nil {
+		snapshotError(c, err)
+		return
+	}
+	resp, err := h.ingestService.MultiSearch(c.Request.Context(), []string{mounted}, req.Query, req.K, req.Filter, services.SearchOptions{Dedupe: req.Dedupe})
+	if vagueQuery(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range resp.Results {
+		resp.Results[i].Collection = name
+	}
+	h.recordUsage(c, config.Usage{Searches: 1})
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func snapshotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSnapshot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrArchiveExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDimensionMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
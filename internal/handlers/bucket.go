@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithBucketService(svc *services.BucketService) *APIHandlers {
+	_h := *h
+	_h.bucketService = svc
+	return &_h
+}
+
+// IngestBucket ingests the new and changed objects under a bucket prefix.
+func (h *APIHandlers) IngestBucket(c *gin.Context) {
+	var spec services.BucketSpec
+	if !bindJSON(c, &spec) {
+		return
+	}
+	run, err := h.bucketService.Run(c.Request.Context(), spec)
+	switch {
+	case errors.Is(err, services.ErrInvalidBucket):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBucketFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage := config.Usage{}
+	for _, r := range run.Results {
+		if r.Status == "ingested" {
+			usage.IngestFiles++
+			usage.IngestChunks += r.Chunks
+		}
+	}
+	h.recordUsage(c, usage)
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
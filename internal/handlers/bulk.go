@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithBulkService(svc *services.BulkService) *APIHandlers {
+	_h := *h
+	_h.bulkService = svc
+	return &_h
+}
+
+// BulkCollections deletes, exports or re-embeds the collections matching a
+// name pattern or tag (admin only). Without "confirm" it only returns the
+// plan and its confirmation token; repeating the request with the token
+// runs it.
+func (h *APIHandlers) BulkCollections(c *gin.Context) {
+	if h.bulkService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "bulk operations are not configured"})
+		return
+	}
+	var req services.BulkRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Confirm == "" {
+		plan, err := h.bulkService.Plan(c.Request.Context(), req)
+		if err != nil {
+			bulkError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"plan": plan})
+		return
+	}
+	plan, results, err := h.bulkService.Run(c.Request.Context(), req)
+	if errors.Is(err, services.ErrBulkConfirm) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "plan": plan})
+		return
+	}
+	if err != nil {
+		bulkError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"op": plan.Op, "results": results})
+}
+
+func bulkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAdminRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBulkRequest), errors.Is(err, services.ErrInvalidSnapshot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetCollectionTags returns a collection's tags.
+func (h *APIHandlers) GetCollectionTags(c *gin.Context) {
+	tags, err := h.ingestService.CollectionTags(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": c.Param("name"), "tags": tags})
+}
+
+// SetCollectionTags replaces a collection's tags.
+func (h *APIHandlers) SetCollectionTags(c *gin.Context) {
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	tags, err := h.ingestService.SetCollectionTags(c.Param("name"), req.Tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": c.Param("name"), "tags": tags})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithCostTracker(t *services.CostTracker) *APIHandlers {
+	_h := *h
+	_h.costTracker = t
+	return &_h
+}
+
+// CostAnalytics reports model usage and estimated cost for the last days
+// (default 30), by model, collection and API key.
+func (h *APIHandlers) CostAnalytics(c *gin.Context) {
+	if h.costTracker == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "cost tracking is not configured"})
+		return
+	}
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+	report, err := h.costTracker.Report(days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithCrawlService(svc *services.CrawlService) *APIHandlers {
+	_h := *h
+	_h.crawlService = svc
+	return &_h
+}
+
+// StartCrawl starts a background crawl and returns the job to poll.
+func (h *APIHandlers) StartCrawl(c *gin.Context) {
+	var spec services.CrawlSpec
+	if !bindJSON(c, &spec) {
+		return
+	}
+	job, err := h.crawlService.Start(c.Request.Context(), spec)
+	if err != nil {
+		crawlError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *APIHandlers) ListCrawls(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"crawls": h.crawlService.List()})
+}
+
+// GetCrawl reports a crawl's progress.
+func (h *APIHandlers) GetCrawl(c *gin.Context) {
+	job, err := h.crawlService.Get(c.Param("id"))
+	if err != nil {
+		crawlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelCrawl stops a running crawl.
+func (h *APIHandlers) CancelCrawl(c *gin.Context) {
+	if err := h.crawlService.Cancel(c.Param("id")); err != nil {
+		crawlError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func crawlError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCrawl):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCrawlNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
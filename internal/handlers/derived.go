@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithDerivedService(svc *services.DerivedService) *APIHandlers {
+	_h := *h
+	_h.derivedService = svc
+	return &_h
+}
+
+// DefineDerived creates or replaces a derived view named by the path parameter.
+func (h *APIHandlers) DefineDerived(c *gin.Context) {
+	var req struct {
+		Source     string                 `json:"source" binding:"required"`
+		Filter     map[string]interface{} `json:"filter"`
+		Transforms []string               `json:"transforms"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	res, err := h.derivedService.Define(c.Request.Context(), config.DerivedCollection{
+		Name:       c.Param("name"),
+		Source:     req.Source,
+		Filter:     req.Filter,
+		Transforms: req.Transforms,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sync": res})
+}
+
+func (h *APIHandlers) ListDerived(c *gin.Context) {
+	views, err := h.derivedService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"derived": views})
+}
+
+func (h *APIHandlers) SyncDerived(c *gin.Context) {
+	res, err := h.derivedService.Sync(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		derivedError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sync": res})
+}
+
+func (h *APIHandlers) DeleteDerived(c *gin.Context) {
+	if err := h.derivedService.Delete(c.Param("name")); err != nil {
+		derivedError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func derivedError(c *gin.Context, err error) {
+	if errors.Is(err, config.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "derived collection not found"})
+		return
+	}
+	if errors.Is(err, services.ErrDimensionMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithDoctorService(svc *services.DoctorService) *APIHandlers {
+	_h := *h
+	_h.doctorService = svc
+	return &_h
+}
+
+// Doctor runs the self-diagnostics and returns the pass/warn/fail report.
+// The status is 200 whatever the verdict, as with /health.
+func (h *APIHandlers) Doctor(c *gin.Context) {
+	if h.doctorService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "diagnostics are not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": h.doctorService.Run(c.Request.Context())})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithFeedService(svc *services.FeedService) *APIHandlers {
+	_h := *h
+	_h.feedService = svc
+	return &_h
+}
+
+// AddFeed registers an RSS or Atom feed to poll into a collection.
+func (h *APIHandlers) AddFeed(c *gin.Context) {
+	var spec services.FeedSpec
+	if !bindJSON(c, &spec) {
+		return
+	}
+	feed, err := h.feedService.Add(c.Param("name"), spec)
+	if err != nil {
+		feedError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"feed": feed})
+}
+
+func (h *APIHandlers) ListFeeds(c *gin.Context) {
+	feeds, err := h.feedService.List(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if feeds == nil {
+		feeds = []config.Feed{}
+	}
+	c.JSON(http.StatusOK, gin.H{"feeds": feeds})
+}
+
+func (h *APIHandlers) DeleteFeed(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feed id"})
+		return
+	}
+	if err := h.feedService.Delete(c.Param("name"), id); err != nil {
+		feedError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PollFeed polls a feed now and reports the entries it ingested.
+func (h *APIHandlers) PollFeed(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feed id"})
+		return
+	}
+	poll, err := h.feedService.Poll(c.Request.Context(), c.Param("name"), id)
+	if err != nil {
+		feedError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"poll": poll})
+}
+
+func feedError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidFeed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, config.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+	case errors.Is(err, config.ErrFeedExists), errors.Is(err, services.ErrFeedPolling):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFeedFetch):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
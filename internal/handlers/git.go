@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithGitService(svc *services.GitService) *APIHandlers {
+	_h := *h
+	_h.gitService = svc
+	return &_h
+}
+
+// IngestGit clones or updates a repository and ingests its changed files.
+func (h *APIHandlers) IngestGit(c *gin.Context) {
+	var spec services.GitSpec
+	if !bindJSON(c, &spec) {
+		return
+	}
+	run, err := h.gitService.Run(c.Request.Context(), spec)
+	switch {
+	case errors.Is(err, services.ErrInvalidGit), errors.Is(err, services.ErrInvalidPathSpec):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrPathNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrGitRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrGitFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage := config.Usage{}
+	for _, r := range run.Results {
+		if r.Status == "ingested" {
+			usage.IngestFiles++
+			usage.IngestChunks += r.Chunks
+		}
+	}
+	h.recordUsage(c, usage)
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
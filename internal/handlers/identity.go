@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// PrincipalsHeader carries the caller's user and group principals as a
+// comma-separated list. It must be set by a trusted proxy in shared deployments.
+const PrincipalsHeader = "X-Forge-Principals"
+
+// PrincipalsMiddleware attaches the caller principals to the request context
+// so search can enforce chunk-level ACLs.
+func PrincipalsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v := c.GetHeader(PrincipalsHeader); v != "" {
+			ctx := services.WithPrincipals(c.Request.Context(), services.ParsePrincipals(v))
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
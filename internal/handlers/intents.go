@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListIntents returns logged mutations, optionally filtered by
+// ?status=pending|done|failed|reconciled (repeatable).
+func (h *APIHandlers) ListIntents(c *gin.Context) {
+	intents, err := h.ingestService.Intents(c.QueryArray("status")...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"intents": intents})
+}
+
+// ReconcileIntents settles failed mutations and those interrupted by a crash.
+func (h *APIHandlers) ReconcileIntents(c *gin.Context) {
+	settled, err := h.ingestService.ReconcileIntents(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciled": settled})
+}
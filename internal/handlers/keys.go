@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// APIKeyHeader carries the caller's API key secret.
+const APIKeyHeader = "X-API-Key"
+
+// AdminTokenHeader carries the operator's admin token.
+const AdminTokenHeader = "X-Forge-Admin-Token"
+
+// KeyPolicy configures APIKeyMiddleware.
+type KeyPolicy struct {
+	// AdminToken, if set, is the operator secret that grants admin scope
+	// when presented in AdminTokenHeader.
+	AdminToken string
+	// Required rejects requests without an API key or the admin token,
+	// except on keylessRoutes.
+	Required bool
+}
+
+// keylessRoutes may be used without a key when keys are required: the
+// health check, signed downloads, which carry their own credential, and
+// unrouted paths such as the frontend's static files.
+var keylessRoutes = map[string]bool{
+	"/health":          true,
+	"/download/:token": true,
+	"":                 true,
+}
+
+func (h *APIHandlers) WithUsageService(svc *services.UsageService) *APIHandlers {
+	_h := *h
+	_h.usageService = svc
+	return &_h
+}
+
+// keyScopedRoutes take their collections from the request body, applying
+// the key's scope themselves, or touch no collection data. Keys restricted
+// to a collection may only use these and the routes of their collection.
+var keyScopedRoutes = map[string]bool{
+	"/health":                     true,
+	"/search":                     true,
+	"/search/sessions/:id":        true,
+	"/answer":                     true,
+	"/api/ingest":                 true,
+	"/api/ingest/supported-types": true,
+	"/download/:token":            true,
+	"/v1/query":                   true,
+	"/v1/embeddings":              true,
+	"/tools":                      true,
+}
+
+// keyAllowed reports whether a key restricted to collection may use the
+// matched route.
+func keyAllowed(c *gin.Context, collection string) bool {
+	path := c.FullPath()
+	switch {
+	case keyScopedRoutes[path]:
+		return true
+	case path == "/collections/:name" || strings.HasPrefix(path, "/collections/:name/"):
+		return c.Param("name") == collection
+	case strings.HasPrefix(path, "/docs/:collection"), strings.HasPrefix(path, "/files/:collection/"):
+		return c.Param("collection") == collection
+	}
+	return false
+}
+
+// APIKeyMiddleware attaches the API key presented in APIKeyHeader to the
+// request context so usage is attributed to it, and marks requests carrying
+// the policy's admin token or an admin key as admin. Requests without either are passed
+// through unless the policy requires a key; unknown keys and wrong admin
+// tokens are rejected, as are keys restricted to a collection on routes
+// outside it.
+func APIKeyMiddleware(svc *services.UsageService, policy KeyPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.GetHeader(AdminTokenHeader); token != "" {
+			if policy.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(policy.AdminToken)) != 1 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+				return
+			}
+			c.Request = c.Request.WithContext(services.WithAdmin(c.Request.Context()))
+		}
+		secret := c.GetHeader(APIKeyHeader)
+		if secret == "" {
+			if policy.Required && !services.AdminFromContext(c.Request.Context()) && !keylessRoutes[c.FullPath()] {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api key required"})
+				return
+			}
+			c.Next()
+			return
+		}
+		key, err := svc.Authenticate(secret)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				status = http.StatusUnauthorized
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if key.Restricted && !keyAllowed(c, key.Collection) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": services.ErrKeyScope.Error()})
+			return
+		}
+		ctx := services.WithAPIKey(c.Request.Context(), key)
+		if key.Admin {
+			ctx = services.WithAdmin(ctx)
+		}
+		ctx = logging.WithFields(ctx, logrus.Fields{"key_id": key.ID})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests that didn't present an admin credential.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.AdminFromContext(c.Request.Context()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": services.ErrAdminRequired.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// recordUsage attributes usage to the request's API key, if any.
+func (h *APIHandlers) recordUsage(c *gin.Context, delta config.Usage) {
+	if h.usageService != nil {
+		h.usageService.Record(c.Request.Context(), delta)
+	}
+}
+
+// CreateAPIKey issues a key; the secret is only returned here.
+func (h *APIHandlers) CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name       string       `json:"name" binding:"required"`
+		WebhookURL string       `json:"webhook_url"`
+		SoftLimits config.Usage `json:"soft_limits"`
+		services.KeyScope
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	key, secret, err := h.usageService.CreateKey(req.Name, req.WebhookURL, req.SoftLimits, req.KeyScope)
+	if err != nil {
+		keyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": key, "secret": secret})
+}
+
+// SetAPIKeyScope binds a key to a default collection, optionally
+// restricting it to that collection.
+func (h *APIHandlers) SetAPIKeyScope(c *gin.Context) {
+	var scope services.KeyScope
+	if !bindJSON(c, &scope) {
+		return
+	}
+	key, err := h.usageService.SetKeyScope(c.Param("id"), scope)
+	if err != nil {
+		keyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"key": key})
+}
+
+// scopeCollections applies the request's API key to the collections named
+// in its body, writing the error response when the key may not use them.
+func scopeCollections(c *gin.Context, collections ...string) ([]string, bool) {
+	var named []string
+	for _, name := range collections {
+		if name != "" {
+			named = append(named, name)
+		}
+	}
+	scoped, err := services.ScopeCollections(c.Request.Context(), named)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return scoped, true
+}
+
+func (h *APIHandlers) ListAPIKeys(c *gin.Context) {
+	keys, err := h.usageService.ListKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+func (h *APIHandlers) DeleteAPIKey(c *gin.Context) {
+	if err := h.usageService.DeleteKey(c.Param("id")); err != nil {
+		keyError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// APIKeyUsage reports a key's daily usage; ?days= selects the window (default 30).
+func (h *APIHandlers) APIKeyUsage(c *gin.Context) {
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+	usage, err := h.usageService.Usage(c.Param("id"), days)
+	if err != nil {
+		keyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+func keyError(c *gin.Context, err error) {
+	if errors.Is(err, config.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidKeyScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
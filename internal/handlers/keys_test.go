@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// memKeys holds API keys by hash.
+type memKeys struct {
+	services.KeyStore
+	keys map[string]config.APIKey
+}
+
+func (m memKeys) SaveAPIKey(k config.APIKey) error {
+	m.keys[k.Hash] = k
+	return nil
+}
+
+func (m memKeys) GetAPIKeyByHash(hash string) (config.APIKey, error) {
+	if k, ok := m.keys[hash]; ok {
+		return k, nil
+	}
+	return config.APIKey{}, config.ErrNotFound
+}
+
+func (m memKeys) GetAPIKey(id string) (config.APIKey, error) {
+	for _, k := range m.keys {
+		if k.ID == id {
+			return k, nil
+		}
+	}
+	return config.APIKey{}, config.ErrNotFound
+}
+
+func TestAPIKeyScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := services.NewUsageService(memKeys{keys: map[string]config.APIKey{}}, "")
+	if _, _, err := svc.CreateKey("bad", "", config.Usage{}, services.KeyScope{Restricted: true}); err == nil {
+		t.Error("expected a restricted key without a collection to be rejected")
+	}
+	_, restricted, _ := svc.CreateKey("widget", "", config.Usage{}, services.KeyScope{Collection: "docs", Restricted: true})
+	_, defaulted, _ := svc.CreateKey("bot", "", config.Usage{}, services.KeyScope{Collection: "docs"})
+
+	router := gin.New()
+	router.Use(APIKeyMiddleware(svc, KeyPolicy{AdminToken: "operator-secret"}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/collections/:name/documents", ok)
+	router.GET("/files/:collection/:file_md5", ok)
+	router.DELETE("/files/:collection/:file_md5", ok)
+	router.GET("/keys", RequireAdmin(), ok)
+	router.POST("/search", func(c *gin.Context) {
+		var req struct {
+			CollectionID string   `json:"collection_id"`
+			Collections  []string `json:"collections"`
+		}
+		c.ShouldBindJSON(&req)
+		if collections, ok := scopeCollections(c, append([]string{req.CollectionID}, req.Collections...)...); ok {
+			c.JSON(http.StatusOK, gin.H{"collections": collections})
+		}
+	})
+
+	cases := []struct {
+		secret, method, path, body string
+		want                       int
+		collections                string
+		adminToken                 string
+	}{
+		{restricted, "GET", "/collections/docs/documents", "", http.StatusOK, "", ""},
+		{restricted, "GET", "/collections/other/documents", "", http.StatusForbidden, "", ""},
+		{restricted, "GET", "/files/docs/abc123", "", http.StatusOK, "", ""},
+		{restricted, "GET", "/files/other/abc123", "", http.StatusForbidden, "", ""},
+		{restricted, "DELETE", "/files/docs/abc123", "", http.StatusOK, "", ""},
+		{restricted, "DELETE", "/files/other/abc123", "", http.StatusForbidden, "", ""},
+		{restricted, "GET", "/keys", "", http.StatusForbidden, "", ""},
+		{restricted, "POST", "/search", `{}`, http.StatusOK, `["docs"]`, ""},
+		{restricted, "POST", "/search", `{"collections": ["docs", "other"]}`, http.StatusForbidden, "", ""},
+		{defaulted, "POST", "/search", `{}`, http.StatusOK, `["docs"]`, ""},
+		{defaulted, "POST", "/search", `{"collection_id": "other"}`, http.StatusOK, `["other"]`, ""},
+		{defaulted, "GET", "/keys", "", http.StatusForbidden, "", ""},
+		{"", "GET", "/keys", "", http.StatusForbidden, "", ""},
+		{"", "GET", "/keys", "", http.StatusOK, "", "operator-secret"},
+		{defaulted, "GET", "/keys", "", http.StatusOK, "", "operator-secret"},
+		{"", "GET", "/keys", "", http.StatusUnauthorized, "", "guess"},
+		{"", "POST", "/search", `{}`, http.StatusOK, `null`, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.secret != "" {
+			req.Header.Set(APIKeyHeader, tc.secret)
+		}
+		if tc.adminToken != "" {
+			req.Header.Set(AdminTokenHeader, tc.adminToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s %s %s: got %d, want %d", tc.method, tc.path, tc.body, w.Code, tc.want)
+			continue
+		}
+		if tc.collections != "" {
+			var resp struct{ Collections json.RawMessage }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if string(resp.Collections) != tc.collections {
+				t.Errorf("%s %s: got collections %s, want %s", tc.path, tc.body, resp.Collections, tc.collections)
+			}
+		}
+	}
+}
+
+func TestAPIKeyRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := services.NewUsageService(memKeys{keys: map[string]config.APIKey{}}, "")
+	_, secret, _ := svc.CreateKey("bot", "", config.Usage{}, services.KeyScope{})
+
+	router := gin.New()
+	router.Use(APIKeyMiddleware(svc, KeyPolicy{AdminToken: "operator-secret", Required: true}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/health", ok)
+	router.POST("/search", ok)
+	router.NoRoute(ok)
+
+	cases := []struct {
+		path, secret, adminToken string
+		want                     int
+	}{
+		{"/search", "", "", http.StatusUnauthorized},
+		{"/search", secret, "", http.StatusOK},
+		{"/search", "", "operator-secret", http.StatusOK},
+		{"/health", "", "", http.StatusOK},
+		{"/index.html", "", "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		if tc.path != "/search" {
+			req.Method = http.MethodGet
+		}
+		if tc.secret != "" {
+			req.Header.Set(APIKeyHeader, tc.secret)
+		}
+		if tc.adminToken != "" {
+			req.Header.Set(AdminTokenHeader, tc.adminToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s with key %q and admin token %q: got %d, want %d", tc.path, tc.secret, tc.adminToken, w.Code, tc.want)
+		}
+	}
+}
+
+// memSettings stores collection settings as JSON.
+type memSettings map[string][]byte
+
+func (m memSettings) GetCollectionSetting(collection, key string, dst any) (bool, error) {
+	raw, ok := m[collection+"/"+key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, dst)
+}
+
+func (m memSettings) SetCollectionSetting(collection, key string, value any) error {
+	raw, err := json.Marshal(value)
+	m[collection+"/"+key] = raw
+	return err
+}
+
+func TestAdminScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := services.NewUsageService(memKeys{keys: map[string]config.APIKey{}}, "")
+	if _, _, err := svc.CreateKey("bad", "", config.Usage{}, services.KeyScope{Collection: "docs", Restricted: true, Admin: true}); err == nil {
+		t.Error("expected a restricted admin key to be rejected")
+	}
+	_, adminKey, _ := svc.CreateKey("ops", "", config.Usage{}, services.KeyScope{Admin: true})
+	_, userKey, _ := svc.CreateKey("bot", "", config.Usage{}, services.KeyScope{})
+
+	h := NewAPIHandlers(services.NewIngestService(nil).WithSettings(memSettings{}))
+	router := gin.New()
+	router.Use(PrincipalsMiddleware(), APIKeyMiddleware(svc, KeyPolicy{AdminToken: "operator-secret"}))
+	router.PUT("/collections/:name/protection", h.SetCollectionProtection)
+
+	cases := []struct {
+		principals, secret, adminToken string
+		want                           int
+	}{
+		{"admin", "", "", http.StatusForbidden},
+		{"admin", userKey, "", http.StatusForbidden},
+		{"", adminKey, "", http.StatusOK},
+		{"", "", "operator-secret", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/collections/prod/protection", strings.NewReader(`{"protected": true}`))
+		req.Header.Set(PrincipalsHeader, tc.principals)
+		req.Header.Set(APIKeyHeader, tc.secret)
+		req.Header.Set(AdminTokenHeader, tc.adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("principals %q, key %q, admin token %q: got %d, want %d", tc.principals, tc.secret, tc.adminToken, w.Code, tc.want)
+		}
+	}
+}
+
+func TestSignedDownloadKeyScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := memKeys{keys: map[string]config.APIKey{}}
+	svc := services.NewUsageService(keys, "")
+	moved, _, _ := svc.CreateKey("widget", "", config.Usage{}, services.KeyScope{Collection: "docs", Restricted: true})
+	signer := services.NewURLSigner([]byte("secret"))
+	sign := func(key config.APIKey) string {
+		token, _, err := signer.Sign(services.WithAPIKey(context.Background(), key), "docs", "abc123", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	deletedToken := sign(config.APIKey{ID: "gone"})
+	movedToken := sign(moved)
+	if _, err := svc.SetKeyScope(moved.ID, services.KeyScope{Collection: "other", Restricted: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAPIHandlers(nil).WithURLSigner(signer).WithUsageService(svc)
+	router := gin.New()
+	router.GET("/download/:token", h.SignedDownload)
+	for name, token := range map[string]string{"deleted key": deletedToken, "key moved to another collection": movedToken} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download/"+token, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want 403", name, w.Code)
+		}
+	}
+}
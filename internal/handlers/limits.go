@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"golang.org/x/sync/semaphore"
+)
+
+// Route classes for ConcurrencyLimits.
+const (
+	RouteClassIngest = "ingest"
+	RouteClassSearch = "search"
+	RouteClassAdmin  = "admin"
+)
+
+// ConcurrencyLimits caps the requests each route class serves at once; zero
+// leaves a class unlimited. A request waits up to Wait for a slot before it
+// is turned away with 503.
+type ConcurrencyLimits struct {
+	Ingest int
+	Search int
+	Admin  int
+	Wait   time.Duration
+}
+
+// searchRoutes are the latency-sensitive routes.
+var searchRoutes = map[string]bool{
+	"POST /search":   true,
+	"POST /answer":   true,
+	"POST /v1/query": true,
+	"POST /collections/:name/snapshots/:snapshot/search": true,
+}
+
+// ingestRoutes start ingestion or rewrite documents outside /api/ingest.
+var ingestRoutes = map[string]bool{
+	"PUT /docs/:collection/file":                            true,
+	"POST /docs/:collection/:id/versions/:version/rollback": true,
+	"POST /collections/:name/feeds/:id/poll":                true,
+	"POST /collections/:name/sources/:id/rerun":             true,
+	"POST /collections/:name/archive":                       true,
+	"POST /archives/:name/restore":                          true,
+	"POST /derived/:name/sync":                              true,
+	"POST /replicas/:name/check":                            true,
+	"POST /mirror/reconcile":                                true,
+	"POST /pipelines/:name/run":                             true,
+	"POST /crawls":                                          true,
+}
+
+// routeClass classifies a matched route. Health checks and unmatched paths
+// (the frontend) are not limited.
+func routeClass(method, route string) string {
+	if route == "" || route == "/health" {
+		return ""
+	}
+	key := method + " " + route
+	switch {
+	case searchRoutes[key]:
+		return RouteClassSearch
+	case ingestRoutes[key], method == http.MethodPost && strings.HasPrefix(route, "/api/ingest"):
+		return RouteClassIngest
+	default:
+		return RouteClassAdmin
+	}
+}
+
+// ConcurrencyLimitMiddleware enforces limits with a weighted semaphore per
+// route class, so bulk ingestion cannot starve searches of handlers.
+func ConcurrencyLimitMiddleware(limits ConcurrencyLimits) gin.HandlerFunc {
+	sems := make(map[string]*semaphore.Weighted)
+	for class, n := range map[string]int{RouteClassIngest: limits.Ingest, RouteClassSearch: limits.Search, RouteClassAdmin: limits.Admin} {
+		if n > 0 {
+			sems[class] = semaphore.NewWeighted(int64(n))
+		}
+	}
+	return func(c *gin.Context) {
+		class := routeClass(c.Request.Method, c.FullPath())
+		sem := sems[class]
+		if sem == nil {
+			c.Next()
+			return
+		}
+		if !sem.TryAcquire(1) {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), limits.Wait)
+			err := sem.Acquire(ctx, 1)
+			cancel()
+			if err != nil {
+				logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{"route_class": class}).Warn("Concurrency limit reached; request rejected")
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "too many concurrent " + class + " requests"})
+				return
+			}
+		}
+		defer sem.Release(1)
+		c.Next()
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteClass(t *testing.T) {
+	cases := []struct {
+		method, route, want string
+	}{
+		{http.MethodPost, "/search", RouteClassSearch},
+		{http.MethodPost, "/collections/:name/snapshots/:snapshot/search", RouteClassSearch},
+		{http.MethodPost, "/v1/query", RouteClassSearch},
+		{http.MethodPost, "/api/ingest/path", RouteClassIngest},
+		{http.MethodGet, "/api/ingest/batching", RouteClassAdmin},
+		{http.MethodPost, "/pipelines/:name/run", RouteClassIngest},
+		{http.MethodPut, "/docs/:collection/file", RouteClassIngest},
+		{http.MethodPost, "/docs/:collection/:id/versions/:version/rollback", RouteClassIngest},
+		{http.MethodGet, "/docs/:collection/:id/versions", RouteClassAdmin},
+		{http.MethodGet, "/collections", RouteClassAdmin},
+		{http.MethodGet, "/health", ""},
+		{http.MethodGet, "", ""},
+	}
+	for _, tc := range cases {
+		if got := routeClass(tc.method, tc.route); got != tc.want {
+			t.Errorf("routeClass(%s %s) = %q, want %q", tc.method, tc.route, got, tc.want)
+		}
+	}
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ConcurrencyLimitMiddleware(ConcurrencyLimits{Ingest: 1, Wait: 20 * time.Millisecond}))
+	started, release := make(chan struct{}), make(chan struct{})
+	router.POST("/api/ingest", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.POST("/search", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/ingest", nil))
+		done <- w.Code
+	}()
+	<-started
+
+	// The ingest slot is taken: a second ingest times out, searches pass
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/ingest", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/search", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected search to be unaffected, got %d", w.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected first ingest to succeed, got %d", code)
+	}
+	go func() { <-started }()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/ingest", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected slot to be released, got %d", w.Code)
+	}
+}
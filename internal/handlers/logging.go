@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// RequestIDHeader carries a caller-supplied request ID; one is generated when absent.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds caller-supplied IDs so they cannot bloat logs.
+const maxRequestIDLen = 128
+
+// RequestLogger attaches a request-scoped logger to the request context,
+// carrying the request ID, route and, for collection routes, the collection.
+// Later middleware (API keys) and services add their own fields to it.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		fields := logrus.Fields{"request_id": id, "method": c.Request.Method, "route": c.FullPath()}
+		if strings.HasPrefix(c.FullPath(), "/collections/:name") {
+			fields["collection"] = c.Param("name")
+		} else if col := c.Param("collection"); col != "" {
+			fields["collection"] = col
+		}
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), fields))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestLogger())
+	var fields map[string]interface{}
+	router.GET("/collections/:name/schema", func(c *gin.Context) {
+		fields = logging.FromContext(c.Request.Context()).Data
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/collections/docs/schema", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("expected request ID to be echoed, got %q", got)
+	}
+	if fields["request_id"] != "req-42" || fields["collection"] != "docs" || fields["route"] != "/collections/:name/schema" {
+		t.Errorf("unexpected logger fields: %v", fields)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/collections/docs/schema", nil))
+	if len(w.Header().Get(RequestIDHeader)) != 16 {
+		t.Errorf("expected generated request ID, got %q", w.Header().Get(RequestIDHeader))
+	}
+}
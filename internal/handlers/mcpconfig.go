@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/mcp"
+)
+
+// mcpEndpoint describes how MCP clients reach this backend: over HTTP at
+// mcp_http_path on the requested host in single-port mode, otherwise by
+// launching this executable in the current directory.
+func (h *APIHandlers) mcpEndpoint(c *gin.Context) (mcp.Endpoint, error) {
+	vals, err := h.configStore.GetAll()
+	if err != nil {
+		return mcp.Endpoint{}, err
+	}
+	if mcpTransport(vals) == "http" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		return mcp.Endpoint{Transport: "http", URL: scheme + "://" + c.Request.Host + vals.MCPHTTPPath}, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return mcp.Endpoint{}, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return mcp.Endpoint{}, err
+	}
+	return mcp.Endpoint{Transport: "stdio", Command: exe, Dir: dir}, nil
+}
+
+// MCPConfig returns ready-to-paste MCP client configuration for ?client=
+// (claude_desktop, cursor or generic), or for every client when omitted.
+func (h *APIHandlers) MCPConfig(c *gin.Context) {
+	if h.configStore == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "config store is not configured"})
+		return
+	}
+	endpoint, err := h.mcpEndpoint(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clients := mcp.Clients
+	if client := c.Query("client"); client != "" {
+		clients = []string{client}
+	}
+	configs := make(map[string]any, len(clients))
+	for _, client := range clients {
+		cfg, err := mcp.ClientConfig(client, endpoint)
+		if err != nil {
+			mcpConfigError(c, err)
+			return
+		}
+		configs[client] = cfg
+	}
+	c.JSON(http.StatusOK, gin.H{"endpoint": endpoint, "configs": configs})
+}
+
+// ImportMCPConfig adds Forge to an existing client config (the JSON body)
+// for ?client= and returns the merged config, keeping its other servers.
+func (h *APIHandlers) ImportMCPConfig(c *gin.Context) {
+	if h.configStore == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "config store is not configured"})
+		return
+	}
+	var existing map[string]any
+	if !bindJSON(c, &existing) {
+		return
+	}
+	endpoint, err := h.mcpEndpoint(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	merged, err := mcp.MergeClientConfig(c.Query("client"), existing, endpoint)
+	if err != nil {
+		mcpConfigError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"config": merged})
+}
+
+func mcpConfigError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, mcp.ErrUnknownClient) || errors.Is(err, mcp.ErrInvalidClientConfig) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithMirror(m *services.Mirror) *APIHandlers {
+	_h := *h
+	_h.mirror = m
+	return &_h
+}
+
+// MirrorStatus reports the writes waiting to be mirrored and the
+// collections waiting to be reconciled.
+func (h *APIHandlers) MirrorStatus(c *gin.Context) {
+	if h.mirror == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "write mirroring is not configured"})
+		return
+	}
+	status, err := h.mirror.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ReconcileMirror reconciles the queued collections now.
+func (h *APIHandlers) ReconcileMirror(c *gin.Context) {
+	if h.mirror == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "write mirroring is not configured"})
+		return
+	}
+	results, err := h.mirror.Reconcile(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciled": results})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// IngestNotion imports a Notion workspace export uploaded as "file".
+func (h *APIHandlers) IngestNotion(c *gin.Context) {
+	collectionName := c.PostForm("collection_id")
+	if collectionName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection_id is required"})
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	var userMetadata map[string]interface{}
+	if metadataStr := c.PostForm("metadata"); metadataStr != "" {
+		if err := json.Unmarshal([]byte(metadataStr), &userMetadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata JSON"})
+			return
+		}
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := services.IngestOptions{Metadata: userMetadata, ACL: services.ParsePrincipals(c.PostForm("acl"))}
+	if id := c.PostForm("source_id"); id != "" {
+		opts.Source = services.IngestSource{ID: id, Kind: services.SourceNotion, Ref: fileHeader.Filename}
+	}
+	imported, err := h.ingestService.IngestNotionExport(c.Request.Context(), collectionName, fileHeader.Filename, content, opts)
+	switch {
+	case errors.Is(err, services.ErrInvalidNotionExport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrExpandLimit):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordUsage(c, config.Usage{IngestFiles: imported.Ingested, IngestChunks: imported.Chunks})
+
+	c.JSON(http.StatusOK, gin.H{"import": imported})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// RetrievalQuery answers queries in the OpenAI retrieval plugin protocol
+// ({"queries": [...]} to {"results": [...]}), so plugin clients can use
+// Forge as their backend. The protocol has no collections: they are named
+// with ?collection= (repeatable) or default to the API key's collection.
+func (h *APIHandlers) RetrievalQuery(c *gin.Context) {
+	var req struct {
+		Queries []services.RetrievalQuery `json:"queries" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	collections, ok := scopeCollections(c, c.QueryArray("collection")...)
+	if !ok {
+		return
+	}
+	if len(collections) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection is required"})
+		return
+	}
+	results, err := h.ingestService.RetrievalSearch(c.Request.Context(), collections, req.Queries)
+	if vagueQuery(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidRetrievalQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrCollectionTrashed):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrDimensionMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// embeddingsInput accepts the OpenAI embeddings "input": a string or a list
+// of strings. Token arrays are not supported.
+type embeddingsInput []string
+
+func (in *embeddingsInput) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*in = []string{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("input must be a string or a list of strings")
+	}
+	*in = many
+	return nil
+}
+
+// Embeddings serves the OpenAI embeddings API with the embedding function
+// collections use, so tools embed queries in the same space. The requested
+// model is ignored; the response names the configured one.
+func (h *APIHandlers) Embeddings(c *gin.Context) {
+	var req struct {
+		Input embeddingsInput `json:"input" binding:"required"`
+		Model string          `json:"model"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if len(req.Input) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "input must not be empty"})
+		return
+	}
+	vectors, err := h.ingestService.Embed(c.Request.Context(), req.Input)
+	switch {
+	case errors.Is(err, services.ErrNoEmbedder):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	tokenizer, _ := services.GetTokenizer("cl100k")
+	tokens := 0
+	for _, text := range req.Input {
+		tokens += tokenizer.Count(text)
+	}
+	data := make([]gin.H, len(vectors))
+	for i, v := range vectors {
+		data[i] = gin.H{"object": "embedding", "index": i, "embedding": v}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+		"model":  h.ingestService.EmbeddingModel(),
+		"usage":  gin.H{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
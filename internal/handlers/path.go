@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// IngestPath ingests the matching files of a server-local directory.
+func (h *APIHandlers) IngestPath(c *gin.Context) {
+	var spec services.PathIngestSpec
+	if !bindJSON(c, &spec) {
+		return
+	}
+	report, err := h.ingestService.IngestPath(c.Request.Context(), spec)
+	switch {
+	case errors.Is(err, services.ErrPathIngestDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrPathNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidPathSpec):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordUsage(c, config.Usage{IngestFiles: report.Ingested, IngestChunks: report.Chunks})
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
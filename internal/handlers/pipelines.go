@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithPipelineService(svc *services.PipelineService) *APIHandlers {
+	_h := *h
+	_h.pipelineService = svc
+	return &_h
+}
+
+// SavePipeline stores a YAML or JSON pipeline spec posted as the request body.
+func (h *APIHandlers) SavePipeline(c *gin.Context) {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec, err := h.pipelineService.Save(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pipeline": spec})
+}
+
+func (h *APIHandlers) ListPipelines(c *gin.Context) {
+	pipelines, err := h.pipelineService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pipelines": pipelines})
+}
+
+func (h *APIHandlers) GetPipeline(c *gin.Context) {
+	p, err := h.pipelineService.Get(c.Param("name"))
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pipeline": p})
+}
+
+func (h *APIHandlers) DeletePipeline(c *gin.Context) {
+	if err := h.pipelineService.Delete(c.Param("name")); err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RunPipeline executes a stored pipeline synchronously and returns its report.
+func (h *APIHandlers) RunPipeline(c *gin.Context) {
+	run, err := h.pipelineService.Run(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func pipelineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, config.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "pipeline not found"})
+	case errors.Is(err, services.ErrPipelineRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithReplicaService(svc *services.ReplicaService) *APIHandlers {
+	_h := *h
+	_h.replicaService = svc
+	return &_h
+}
+
+// ReplicaStatus reports the primary's health and the last consistency check
+// of each replicated collection.
+func (h *APIHandlers) ReplicaStatus(c *gin.Context) {
+	if h.replicaService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": services.ErrReplicaDisabled.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.replicaService.Status())
+}
+
+// CheckReplica brings a collection's replica up to date now.
+func (h *APIHandlers) CheckReplica(c *gin.Context) {
+	if h.replicaService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": services.ErrReplicaDisabled.Error()})
+		return
+	}
+	check, err := h.replicaService.Check(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, services.ErrNotReplicated) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrDimensionMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, check)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithSetupService(svc *services.SetupService) *APIHandlers {
+	_h := *h
+	_h.setupService = svc
+	return &_h
+}
+
+// SetupStatus reports whether first-run setup has run and the Chroma
+// servers it can see.
+func (h *APIHandlers) SetupStatus(c *gin.Context) {
+	if h.setupService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "setup is not configured"})
+		return
+	}
+	status, err := h.setupService.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"setup": status})
+}
+
+// Setup validates and writes the first-run configuration. Failed checks
+// return 422 with the checks that ran.
+func (h *APIHandlers) Setup(c *gin.Context) {
+	if h.setupService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "setup is not configured"})
+		return
+	}
+	var req services.SetupRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	result, err := h.setupService.Apply(c.Request.Context(), req)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"result": result})
+	case errors.Is(err, services.ErrSetupCheck):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "result": result})
+	case errors.Is(err, services.ErrInvalidSetup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// WithURLSigner enables signed, expiring download links.
+func (h *APIHandlers) WithURLSigner(signer *services.URLSigner) *APIHandlers {
+	_h := *h
+	_h.urlSigner = signer
+	return &_h
+}
+
+// CreateSignedURL issues a temporary link to a stored original file. The
+// link downloads with the caller's principals, if the caller authenticated,
+// and within its API key's scope, without further headers.
+func (h *APIHandlers) CreateSignedURL(c *gin.Context) {
+	if h.urlSigner == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "signed links are not configured"})
+		return
+	}
+	var req struct {
+		ExpiresIn int `json:"expires_in"` // seconds
+	}
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+	collection, md5Hash := c.Param("name"), c.Param("md5")
+	if _, ok := scopeCollections(c, collection); !ok {
+		return
+	}
+	// Only link to what the caller can download now, with the principals
+	// the link will carry
+	ctx := c.Request.Context()
+	ctx = services.WithPrincipals(ctx, services.AuthenticatedPrincipals(ctx))
+	file, err := h.ingestService.OpenOriginal(ctx, collection, md5Hash)
+	switch {
+	case errors.Is(err, services.ErrBlobsDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrBlobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	file.Body.Close()
+	token, expires, err := h.urlSigner.Sign(ctx, collection, md5Hash, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"url": "/download/" + token, "expires_at": expires})
+}
+
+// SignedDownload streams the file a signed link grants, reading it with the
+// principals of whoever created the link. A link created with an API key
+// stops working when the key is deleted or restricted to another collection.
+func (h *APIHandlers) SignedDownload(c *gin.Context) {
+	if h.urlSigner == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "signed links are not configured"})
+		return
+	}
+	grant, err := h.urlSigner.Verify(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := services.WithPrincipals(c.Request.Context(), grant.Principals)
+	if grant.KeyID != "" {
+		if h.usageService == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrInvalidSignature.Error()})
+			return
+		}
+		key, err := h.usageService.Key(grant.KeyID)
+		switch {
+		case errors.Is(err, config.ErrNotFound):
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrInvalidSignature.Error() + ": its api key was deleted"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		case key.Restricted && key.Collection != grant.Collection:
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrKeyScope.Error()})
+			return
+		}
+		ctx = services.WithAPIKey(ctx, key)
+	}
+	h.serveOriginal(c, ctx, grant.Collection, grant.FileMD5)
+}
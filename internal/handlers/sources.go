@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func (h *APIHandlers) WithSourceService(svc *services.SourceService) *APIHandlers {
+	_h := *h
+	_h.sourceService = svc
+	return &_h
+}
+
+// ListSources lists the ingest sources recorded for a collection.
+func (h *APIHandlers) ListSources(c *gin.Context) {
+	sources, err := h.sourceService.List(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// RerunSource re-ingests a URL or pipeline source.
+func (h *APIHandlers) RerunSource(c *gin.Context) {
+	results, err := h.sourceService.Rerun(c.Request.Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		sourceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// PurgeSource deletes every chunk ingested from a source.
+func (h *APIHandlers) PurgeSource(c *gin.Context) {
+	if err := h.sourceService.Purge(c.Request.Context(), c.Param("name"), c.Param("id"), c.Query("force") == "true"); err != nil {
+		sourceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func sourceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, config.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "source not found"})
+	case errors.Is(err, services.ErrSourceNotRerunnable), errors.Is(err, services.ErrPipelineRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCollectionProtected), errors.Is(err, services.ErrAdminRequired), errors.Is(err, services.ErrSnapshotReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
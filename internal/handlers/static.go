@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaticFrontend serves the frontend's built assets from dir. It is meant to be
+// installed as the router's NoRoute handler, so API routes take precedence.
+// Unknown paths fall back to index.html for client-side routing when the
+// request accepts HTML.
+func StaticFrontend(dir string) gin.HandlerFunc {
+	root := os.DirFS(dir)
+	fileServer := http.FileServer(http.FS(root))
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		name := strings.TrimPrefix(path.Clean("/"+c.Request.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if info, err := fs.Stat(root, name); err == nil && !info.IsDir() {
+			fileServer.ServeHTTP(c.Writer, c.Request)
+			return
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if path.Ext(name) != "" || !strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		index, err := fs.ReadFile(root, "index.html")
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	}
+}
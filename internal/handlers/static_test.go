@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStaticFrontend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0o755)
+	os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0o644)
+
+	router := gin.New()
+	router.GET("/health", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.NoRoute(StaticFrontend(dir))
+
+	cases := []struct {
+		path, accept string
+		code         int
+		body         string
+	}{
+		{"/health", "", http.StatusOK, "ok"},
+		{"/assets/app.js", "", http.StatusOK, "console.log(1)"},
+		{"/", "text/html", http.StatusOK, "app"},
+		{"/collections/docs", "text/html,*/*", http.StatusOK, "app"},
+		{"/assets/missing.js", "text/html", http.StatusNotFound, "not found"},
+		{"/nope", "application/json", http.StatusNotFound, "not found"},
+		{"/../../etc/passwd", "", http.StatusNotFound, "not found"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.code || !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("%s: got %d %q, want %d containing %q", tc.path, w.Code, w.Body.String(), tc.code, tc.body)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// SaveTemplate creates or replaces a prompt template for /answer.
+func (h *APIHandlers) SaveTemplate(c *gin.Context) {
+	var t services.PromptTemplate
+	if !bindJSON(c, &t) {
+		return
+	}
+	saved, err := h.ingestService.SaveTemplate(t)
+	if err != nil {
+		generationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": saved})
+}
+
+func (h *APIHandlers) ListTemplates(c *gin.Context) {
+	templates, err := h.ingestService.ListTemplates()
+	if err != nil {
+		generationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func (h *APIHandlers) GetTemplate(c *gin.Context) {
+	t, err := h.ingestService.GetTemplate(c.Param("name"))
+	if err != nil {
+		generationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": t})
+}
+
+func (h *APIHandlers) DeleteTemplate(c *gin.Context) {
+	if err := h.ingestService.DeleteTemplate(c.Param("name")); err != nil {
+		generationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func generationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNoGenerator):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidGeneration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
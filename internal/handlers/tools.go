@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toolSpec ties an agent tool to the route it calls and the request body
+// that route binds.
+type toolSpec struct {
+	name        string
+	description string
+	method      string
+	path        string
+	request     any
+}
+
+var toolSpecs = []toolSpec{
+	{"search", "Search the ingested documents using semantic similarity. Returns the closest chunks with their ids, text and distance.", http.MethodPost, "/search", searchRequest{}},
+	{"ingest", "Add a text document to a collection, chunking and embedding it so it can be searched.", http.MethodPost, "/api/ingest", ingestTextRequest{}},
+	{"answer", "Answer a question from the ingested documents with an LLM, citing the sources it used.", http.MethodPost, "/answer", answerRequest{}},
+}
+
+// ToolDefinitions returns function-calling tool definitions for search,
+// ingest and answer in ?format=anthropic (default) or openai, with the
+// route each tool calls. Parameters are generated from the request bodies
+// the handlers bind, so they stay in step with the API.
+func (h *APIHandlers) ToolDefinitions(c *gin.Context) {
+	format := c.DefaultQuery("format", "anthropic")
+	tools := make([]gin.H, 0, len(toolSpecs))
+	endpoints := make(map[string]gin.H, len(toolSpecs))
+	for _, spec := range toolSpecs {
+		schema := requestSchema(reflect.TypeOf(spec.request))
+		switch format {
+		case "anthropic":
+			tools = append(tools, gin.H{"name": spec.name, "description": spec.description, "input_schema": schema})
+		case "openai":
+			tools = append(tools, gin.H{"type": "function", "function": gin.H{"name": spec.name, "description": spec.description, "parameters": schema}})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be anthropic or openai"})
+			return
+		}
+		endpoints[spec.name] = gin.H{"method": spec.method, "path": spec.path}
+	}
+	c.JSON(http.StatusOK, gin.H{"format": format, "tools": tools, "endpoints": endpoints})
+}
+
+// requestSchema describes a request body as a JSON Schema object. Fields
+// take their names from json tags and descriptions from jsonschema tags,
+// fields of embedded structs are inlined like encoding/json does, and
+// fields with binding:"required" are required.
+func requestSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			prop := fieldSchema(field.Type)
+			if desc := field.Tag.Get("jsonschema"); desc != "" {
+				prop["description"] = desc
+			}
+			properties[name] = prop
+			if strings.Contains(field.Tag.Get("binding"), "required") {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// fieldSchema describes a value of type t.
+func fieldSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": fieldSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object"}
+	case reflect.Struct:
+		return requestSchema(t)
+	}
+	// Interfaces accept any JSON value
+	return map[string]any{}
+}
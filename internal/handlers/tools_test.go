@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func TestToolDefinitions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/tools", NewAPIHandlers(services.NewIngestService(nil)).ToolDefinitions)
+
+	get := func(query string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tools"+query, nil))
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := get("")
+	if code != http.StatusOK || body["format"] != "anthropic" {
+		t.Fatalf("unexpected response %d: %v", code, body)
+	}
+	tools := body["tools"].([]any)
+	if len(tools) != len(toolSpecs) {
+		t.Fatalf("expected %d tools, got %d", len(toolSpecs), len(tools))
+	}
+	search := tools[0].(map[string]any)
+	schema := search["input_schema"].(map[string]any)
+	if search["name"] != "search" || !reflect.DeepEqual(schema["required"], []any{"query"}) {
+		t.Errorf("unexpected search tool %v", search)
+	}
+	props := schema["properties"].(map[string]any)
+	if k := props["k"].(map[string]any); k["type"] != "integer" || k["description"] == nil {
+		t.Errorf("unexpected k property %v", k)
+	}
+	exclude := props["exclude"].(map[string]any)
+	if _, ok := exclude["properties"].(map[string]any)["file_md5s"]; !ok {
+		t.Errorf("expected nested exclude properties, got %v", exclude)
+	}
+	if route := body["endpoints"].(map[string]any)["ingest"]; !reflect.DeepEqual(route, map[string]any{"method": "POST", "path": "/api/ingest"}) {
+		t.Errorf("unexpected ingest endpoint %v", route)
+	}
+
+	// Embedded generation parameters are inlined
+	code, body = get("?format=openai")
+	answer := body["tools"].([]any)[2].(map[string]any)
+	fn := answer["function"].(map[string]any)
+	params := fn["parameters"].(map[string]any)["properties"].(map[string]any)
+	if code != http.StatusOK || answer["type"] != "function" || fn["name"] != "answer" || params["temperature"] == nil || params["GenerationParams"] != nil {
+		t.Errorf("unexpected answer tool %v", answer)
+	}
+
+	if code, _ := get("?format=xml"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", code)
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+// Request limits checked before a request reaches the services.
+const (
+	maxFilterKeys       = 32
+	maxMetadataKeys     = 64
+	maxMetadataKeyLen   = 128
+	maxMetadataValueLen = 8 << 10
+	maxMetadataBytes    = 64 << 10
+)
+
+// embeddedField names the fields of embedded structs in validator
+// namespaces, so fieldPath can leave them out.
+const embeddedField = "~"
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName reports fields by their JSON name in validation errors.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch {
+	case name == "-":
+		return ""
+	case name == "" && f.Anonymous:
+		return embeddedField
+	}
+	return name
+}
+
+// FieldError describes one invalid field of a request body. Field is the
+// field's JSON path, e.g. "filter.year"; it is empty when the body as a
+// whole is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// fieldErrors collects the problems found in a request.
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// respond answers 400 listing every field error, with the first in
+// "error", and reports whether there were any.
+func (e fieldErrors) respond(c *gin.Context) bool {
+	if len(e) == 0 {
+		return false
+	}
+	msg := "invalid request: " + e[0].String()
+	if len(e) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e)-1)
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": msg, "fields": []FieldError(e)})
+	return true
+}
+
+// bindJSON binds the request body into dst, answering 400 with field
+// errors when it is not valid JSON, has mistyped fields or breaks a
+// binding rule.
+func bindJSON(c *gin.Context, dst interface{}) bool {
+	err := c.ShouldBindJSON(dst)
+	if err == nil {
+		return true
+	}
+	bindErrors(err).respond(c)
+	return false
+}
+
+func bindErrors(err error) fieldErrors {
+	var errs fieldErrors
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &invalid):
+		for _, fe := range invalid {
+			errs.add(fieldPath(fe.Namespace()), "%s", ruleMessage(fe))
+		}
+	case errors.As(err, &typeErr):
+		errs.add(typeErr.Field, "must be %s", jsonKind(typeErr.Type))
+	case errors.As(err, &syntaxErr):
+		errs.add("", "malformed JSON at byte %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.Is(err, io.EOF):
+		errs.add("", "a JSON body is required")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		errs.add("", "malformed JSON: unexpected end of body")
+	default:
+		errs.add("", "%v", err)
+	}
+	return errs
+}
+
+// fieldPath drops the struct name and embedded structs from a validator
+// namespace such as "answerRequest.~.max_tokens".
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")[1:]
+	kept := parts[:0]
+	for _, p := range parts {
+		if p != embeddedField {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, ".")
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	sized := fe.Kind() == reflect.String || fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		if sized {
+			return "must have at least " + fe.Param() + " elements or characters"
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		if sized {
+			return "must have at most " + fe.Param() + " elements or characters"
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("fails the %s=%s rule", fe.Tag(), fe.Param())
+	}
+	return "fails the " + fe.Tag() + " rule"
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// k checks a result count against the search limits and replaces 0 with
+// the default.
+func (e *fieldErrors) k(s *services.IngestService, field string, k *int) {
+	resolved, err := s.SearchK(*k)
+	if err != nil {
+		e.add(field, "%s", strings.TrimPrefix(err.Error(), services.ErrInvalidK.Error()+": "))
+		return
+	}
+	*k = resolved
+}
+
+// collections checks names against the collection naming rules.
+func (e *fieldErrors) collections(s *services.IngestService, field string, names ...string) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if err := s.CheckCollectionName(name); err != nil {
+			e.add(field, "%s", strings.TrimPrefix(err.Error(), services.ErrInvalidCollectionName.Error()+" "))
+		}
+	}
+}
+
+// filter checks a metadata equality filter, whose values must be scalars
+// since other values would be ignored rather than matched. An empty field
+// names a filter that is the whole body.
+func (e *fieldErrors) filter(field string, filter map[string]interface{}) {
+	if len(filter) > maxFilterKeys {
+		e.add(field, "must have at most %d keys", maxFilterKeys)
+		return
+	}
+	for k, v := range filter {
+		if k == "" {
+			e.add(field, "keys must not be empty")
+			continue
+		}
+		if !scalar(v) {
+			e.add(strings.TrimPrefix(field+"."+k, "."), "must be a string, number or boolean")
+		}
+	}
+}
+
+// metadata checks user metadata against the size limits; values must be
+// scalars, since others could not be stored.
+func (e *fieldErrors) metadata(field string, md map[string]interface{}) {
+	if len(md) > maxMetadataKeys {
+		e.add(field, "must have at most %d keys", maxMetadataKeys)
+		return
+	}
+	for k, v := range md {
+		switch {
+		case k == "":
+			e.add(field, "keys must not be empty")
+		case len(k) > maxMetadataKeyLen:
+			e.add(field, "key %.32q... is longer than %d bytes", k, maxMetadataKeyLen)
+		case !scalar(v):
+			e.add(field+"."+k, "must be a string, number or boolean")
+		default:
+			if s, ok := v.(string); ok && len(s) > maxMetadataValueLen {
+				e.add(field+"."+k, "must be at most %d bytes", maxMetadataValueLen)
+			}
+		}
+	}
+	if b, err := json.Marshal(md); err == nil && len(b) > maxMetadataBytes {
+		e.add(field, "must be at most %d bytes encoded", maxMetadataBytes)
+	}
+}
+
+func scalar(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool, int, int64, json.Number:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/typicalfo/forge/backend/internal/services"
+)
+
+func TestRequestValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewAPIHandlers(services.NewIngestService(nil))
+	router := gin.New()
+	router.POST("/search", h.Search)
+	router.POST("/answer", h.Answer)
+	router.POST("/api/ingest", h.Ingest)
+
+	cases := []struct {
+		name, path, body string
+		want             []FieldError
+	}{
+		{"empty body", "/search", ``, []FieldError{{"", "a JSON body is required"}}},
+		{"missing query", "/search", `{"collection_id":"docs"}`, []FieldError{{"query", "is required"}}},
+		{"mistyped k", "/search", `{"query":"q","k":"ten"}`, []FieldError{{"k", "must be an integer"}}},
+		{"embedded field", "/answer", `{"question":"q","max_tokens":"many"}`, []FieldError{{"max_tokens", "must be an integer"}}},
+		{"out of bounds", "/search", `{"query":"q","collection_id":"a","k":500,"filter":{"tags":["x"]},"timeout_ms":-1}`, []FieldError{
+			{"k", "must be between 1 and 100"},
+			{"collection_id", `"a": must be 3-512 characters`},
+			{"filter.tags", "must be a string, number or boolean"},
+			{"timeout_ms", "must not be negative"},
+		}},
+		{"answer filter", "/answer", `{"question":"q","collections":["docs","bad name"],"filter":{"":1}}`, []FieldError{
+			{"collections", `"bad name": use letters, digits, '.', '_' or '-', starting and ending with a letter or digit`},
+			{"filter", "keys must not be empty"},
+		}},
+		{"metadata", "/api/ingest", `{"text":"t","collection":"docs","metadata":{"author":{"name":"x"},"body":"` + strings.Repeat("x", maxMetadataValueLen+1) + `"}}`, nil},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", tc.name, w.Code, w.Body)
+			continue
+		}
+		var resp struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !strings.HasPrefix(resp.Error, "invalid request: ") {
+			t.Errorf("%s: error %q", tc.name, resp.Error)
+		}
+		if tc.want == nil {
+			// Map order is random; check the fields, not their order
+			if len(resp.Fields) != 2 {
+				t.Errorf("%s: fields %+v, want 2", tc.name, resp.Fields)
+			}
+			continue
+		}
+		if len(resp.Fields) != len(tc.want) {
+			t.Errorf("%s: fields %+v, want %+v", tc.name, resp.Fields, tc.want)
+			continue
+		}
+		for i := range tc.want {
+			if resp.Fields[i] != tc.want[i] {
+				t.Errorf("%s: field %d = %+v, want %+v", tc.name, i, resp.Fields[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	for ns, want := range map[string]string{
+		"searchRequest.query":               "query",
+		"answerRequest.~.max_tokens":        "max_tokens",
+		"spec.schedule.interval":            "schedule.interval",
+		"ingestTextRequest.metadata[title]": "metadata[title]",
+	} {
+		if got := fieldPath(ns); got != want {
+			t.Errorf("fieldPath(%q) = %q, want %q", ns, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// MCP clients with a known configuration format.
+const (
+	ClientClaudeDesktop = "claude_desktop"
+	ClientCursor        = "cursor"
+	ClientGeneric       = "generic"
+)
+
+// Clients lists the supported clients in display order.
+var Clients = []string{ClientClaudeDesktop, ClientCursor, ClientGeneric}
+
+// ServerName is the key Forge is registered under in client configs.
+const ServerName = "forge"
+
+var (
+	// ErrUnknownClient is returned for a client without a known format.
+	ErrUnknownClient = errors.New("unknown MCP client")
+	// ErrInvalidClientConfig is returned when an imported config cannot
+	// take Forge's entry.
+	ErrInvalidClientConfig = errors.New("invalid MCP client config")
+)
+
+// Endpoint describes how a client reaches this server: by launching Command
+// in Dir (stdio) or at URL (streamable HTTP).
+type Endpoint struct {
+	Transport string `json:"transport"` // "stdio" or "http"
+	Command   string `json:"command,omitempty"`
+	Dir       string `json:"dir,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// ClientConfig returns a ready-to-paste configuration registering Forge
+// with client.
+func ClientConfig(client string, e Endpoint) (map[string]any, error) {
+	entry, err := serverEntry(client, e)
+	if err != nil {
+		return nil, err
+	}
+	if client == ClientGeneric {
+		return entry, nil
+	}
+	return map[string]any{"mcpServers": map[string]any{ServerName: entry}}, nil
+}
+
+// MergeClientConfig adds or replaces Forge's entry in an existing client
+// config, keeping its other servers and settings.
+func MergeClientConfig(client string, existing map[string]any, e Endpoint) (map[string]any, error) {
+	if client == ClientGeneric {
+		return nil, fmt.Errorf("%w: the generic format has no servers to merge into", ErrInvalidClientConfig)
+	}
+	entry, err := serverEntry(client, e)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		existing = make(map[string]any)
+	}
+	servers, ok := existing["mcpServers"].(map[string]any)
+	if !ok {
+		if existing["mcpServers"] != nil {
+			return nil, fmt.Errorf("%w: mcpServers is not an object", ErrInvalidClientConfig)
+		}
+		servers = make(map[string]any)
+	}
+	servers[ServerName] = entry
+	existing["mcpServers"] = servers
+	return existing, nil
+}
+
+func serverEntry(client string, e Endpoint) (map[string]any, error) {
+	switch client {
+	case ClientClaudeDesktop, ClientCursor, ClientGeneric:
+	default:
+		return nil, fmt.Errorf("%w: %q (expected one of %s)", ErrUnknownClient, client, strings.Join(Clients, ", "))
+	}
+	if e.Transport == "http" {
+		switch client {
+		case ClientClaudeDesktop:
+			// Claude Desktop only launches local servers; bridge with mcp-remote
+			return map[string]any{"command": "npx", "args": []string{"-y", "mcp-remote", e.URL}}, nil
+		case ClientCursor:
+			return map[string]any{"url": e.URL}, nil
+		default:
+			return map[string]any{"name": ServerName, "transport": "http", "url": e.URL}, nil
+		}
+	}
+	if client == ClientGeneric {
+		return map[string]any{"name": ServerName, "transport": "stdio", "command": e.Command, "args": []string{}, "cwd": e.Dir}, nil
+	}
+	// The backend resolves its config database from the working directory,
+	// which these clients don't set, so change into it first
+	command, args := inDir(e.Dir, e.Command)
+	return map[string]any{"command": command, "args": args}, nil
+}
+
+func inDir(dir, command string) (string, []string) {
+	if dir == "" {
+		return command, []string{}
+	}
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/c", fmt.Sprintf(`cd /d "%s" && "%s"`, dir, command)}
+	}
+	return "sh", []string{"-c", fmt.Sprintf("cd %s && exec %s", shellQuote(dir), shellQuote(command))}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
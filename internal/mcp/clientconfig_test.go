@@ -0,0 +1,56 @@
+package mcp
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestClientConfig(t *testing.T) {
+	http := Endpoint{Transport: "http", URL: "http://localhost:8080/mcp"}
+	cfg, err := ClientConfig(ClientCursor, http)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"mcpServers": map[string]any{"forge": map[string]any{"url": "http://localhost:8080/mcp"}}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("unexpected cursor config %v", cfg)
+	}
+	cfg, _ = ClientConfig(ClientClaudeDesktop, http)
+	entry := cfg["mcpServers"].(map[string]any)["forge"].(map[string]any)
+	if entry["command"] != "npx" || !reflect.DeepEqual(entry["args"], []string{"-y", "mcp-remote", "http://localhost:8080/mcp"}) {
+		t.Errorf("expected an mcp-remote bridge, got %v", entry)
+	}
+
+	if runtime.GOOS != "windows" {
+		stdio := Endpoint{Transport: "stdio", Command: "/opt/forge/main", Dir: "/srv/it's here"}
+		cfg, _ = ClientConfig(ClientClaudeDesktop, stdio)
+		entry = cfg["mcpServers"].(map[string]any)["forge"].(map[string]any)
+		if entry["command"] != "sh" || !reflect.DeepEqual(entry["args"], []string{"-c", `cd '/srv/it'\''s here' && exec '/opt/forge/main'`}) {
+			t.Errorf("unexpected stdio entry %v", entry)
+		}
+	}
+
+	if _, err := ClientConfig("vim", http); !errors.Is(err, ErrUnknownClient) {
+		t.Errorf("expected ErrUnknownClient, got %v", err)
+	}
+}
+
+func TestMergeClientConfig(t *testing.T) {
+	existing := map[string]any{
+		"theme":      "dark",
+		"mcpServers": map[string]any{"other": map[string]any{"command": "other"}},
+	}
+	merged, err := MergeClientConfig(ClientCursor, existing, Endpoint{Transport: "http", URL: "http://h/mcp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := merged["mcpServers"].(map[string]any)
+	if merged["theme"] != "dark" || servers["other"] == nil || servers["forge"] == nil {
+		t.Errorf("expected forge added alongside existing settings, got %v", merged)
+	}
+	if _, err := MergeClientConfig(ClientCursor, map[string]any{"mcpServers": []any{}}, Endpoint{Transport: "http"}); !errors.Is(err, ErrInvalidClientConfig) {
+		t.Errorf("expected ErrInvalidClientConfig, got %v", err)
+	}
+}
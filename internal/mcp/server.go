@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -15,43 +16,60 @@ import (
 // YAGNI: Just what we need to expose search + health.
 type MCPServer struct {
 	chromaDB chroma.Client
+	// ingest runs searches, with the API's system keys, name policy,
+	// limits and timeouts.
+	ingest *services.IngestService
 }
 
-func NewMCPServer(chromaDB chroma.Client) *MCPServer {
-	return &MCPServer{chromaDB: chromaDB}
+func NewMCPServer(chromaDB chroma.Client, ingest *services.IngestService) *MCPServer {
+	return &MCPServer{chromaDB: chromaDB, ingest: ingest}
 }
 
-// Start runs the MCP server until the provided context is canceled.
-func (s *MCPServer) Start(ctx context.Context, port string) {
-	logging.GetLogger().WithField("port", port).Info("Starting MCP server")
-
+// newServer builds the MCP server with Forge's tools registered.
+func (s *MCPServer) newServer() *mcp.Server {
 	server := mcp.NewServer(&mcp.Implementation{Name: "Forge MCP Server", Version: "1.0.0"}, nil)
 
 	mcp.AddTool(server, &mcp.Tool{Name: "search", Description: "Search the ingested documents using semantic similarity"}, s.handleSearchFunc())
 	mcp.AddTool(server, &mcp.Tool{Name: "health", Description: "Check the health of the ChromaDB connection"}, s.handleHealthFunc())
+	return server
+}
+
+// Start runs the MCP server until the provided context is canceled.
+func (s *MCPServer) Start(ctx context.Context, port string) {
+	logging.FromContext(ctx).WithField("port", port).Info("Starting MCP server")
 
-	logging.GetLogger().Info("MCP server ready")
+	server := s.newServer()
+
+	logging.FromContext(ctx).Info("MCP server ready")
 	if err := server.Run(ctx, &mcp.StdioTransport{}); err != nil {
-		logging.GetLogger().WithError(err).Error("MCP server error")
+		logging.FromContext(ctx).WithError(err).Error("MCP server error")
 	}
-	logging.GetLogger().Info("MCP server stopped")
+	logging.FromContext(ctx).Info("MCP server stopped")
+}
+
+// HTTPHandler serves MCP over the streamable HTTP transport so it can share
+// the backend's port.
+func (s *MCPServer) HTTPHandler() http.Handler {
+	server := s.newServer()
+	return mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
 }
 
 // handleSearchFunc creates a standalone function that can be used with AddTool
 func (s *MCPServer) handleSearchFunc() func(context.Context, *mcp.CallToolRequest, SearchParams) (*mcp.CallToolResult, any, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, args SearchParams) (*mcp.CallToolResult, any, error) {
-		k := args.K
-		if k == 0 {
-			k = 5
+		k, err := s.ingest.SearchK(args.K)
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Search error: %v", err)}},
+			}, nil, nil
 		}
-		service := services.NewIngestService(s.chromaDB)
-		results, err := service.Search(ctx, args.CollectionId, args.Query, k, args.Filter)
+		resp, err := s.ingest.SearchWithFallback(ctx, args.CollectionId, args.Query, k, args.Filter, services.SearchOptions{Exclude: args.Exclude})
 		if err != nil {
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Search error: %v", err)}},
 			}, nil, nil
 		}
-		resultJSON, _ := json.Marshal(results)
+		resultJSON, _ := json.Marshal(resp.Results)
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{&mcp.TextContent{Text: string(resultJSON)}},
 		}, nil, nil
@@ -75,8 +93,9 @@ func (s *MCPServer) handleHealthFunc() func(context.Context, *mcp.CallToolReques
 type SearchParams struct {
 	Query        string                 `json:"query" jsonschema:"the search query to find similar documents"`
 	CollectionId string                 `json:"collection_id" jsonschema:"the collection to search in"`
-	K            int                    `json:"k,omitempty" jsonschema:"number of results to return (default: 5)"`
+	K            int                    `json:"k,omitempty" jsonschema:"number of results to return (default: 5 unless the server sets search_default_k)"`
 	Filter       map[string]interface{} `json:"filter,omitempty" jsonschema:"optional metadata filter for search results"`
+	Exclude      services.Exclusion     `json:"exclude,omitempty" jsonschema:"optional chunk ids, file_md5s and metadata values to leave out, e.g. results already seen"`
 }
 
 type HealthParams struct{}
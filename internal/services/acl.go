@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// Chunk-level ACLs. Chroma metadata values are scalars, so a principal list is
+// stored as one boolean key per principal plus an acl_restricted marker:
+//
+//	acl_restricted = true
+//	acl:alice      = true
+//	acl:group:eng  = true
+//
+// Unrestricted chunks carry acl_restricted = false and are visible to everyone.
+const (
+	aclRestrictedKey = "acl_restricted"
+	aclPrincipalKey  = "acl:"
+)
+
+type principalsKey struct{}
+
+// WithPrincipals returns a context carrying the caller's identity (user and
+// group principals). Search only returns restricted chunks shared with one of
+// these principals.
+func WithPrincipals(ctx context.Context, principals []string) context.Context {
+	return context.WithValue(ctx, principalsKey{}, normalizePrincipals(principals))
+}
+
+// PrincipalsFromContext returns the caller principals stored by WithPrincipals.
+func PrincipalsFromContext(ctx context.Context) []string {
+	p, _ := ctx.Value(principalsKey{}).([]string)
+	return p
+}
+
+// ParsePrincipals splits a comma-separated principal list.
+func ParsePrincipals(s string) []string {
+	return normalizePrincipals(strings.Split(s, ","))
+}
+
+func normalizePrincipals(in []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range in {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+// applyACL adds the ACL marker keys to a chunk's metadata.
+func applyACL(metadata map[string]interface{}, acl []string) {
+	acl = normalizePrincipals(acl)
+	metadata[aclRestrictedKey] = len(acl) > 0
+	for _, p := range acl {
+		metadata[aclPrincipalKey+p] = true
+	}
+}
+
+// aclWhere builds the visibility clause for the given caller principals.
+// Chunks ingested before ACL support have no acl_restricted key and are
+// matched by the $ne clause.
+func aclWhere(principals []string) chroma.WhereClause {
+	clauses := []chroma.WhereClause{chroma.NotEqBool(aclRestrictedKey, true)}
+	for _, p := range principals {
+		clauses = append(clauses, chroma.EqBool(aclPrincipalKey+p, true))
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return chroma.Or(clauses...)
+}
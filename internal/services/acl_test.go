@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func TestApplyACL(t *testing.T) {
+	md := map[string]interface{}{}
+	applyACL(md, []string{"alice", " group:eng ", "alice", ""})
+	if md[aclRestrictedKey] != true {
+		t.Fatalf("expected restricted chunk, got %v", md[aclRestrictedKey])
+	}
+	if md["acl:alice"] != true || md["acl:group:eng"] != true {
+		t.Fatalf("missing principal keys: %v", md)
+	}
+	if len(md) != 3 {
+		t.Fatalf("expected 3 keys, got %d: %v", len(md), md)
+	}
+
+	public := map[string]interface{}{}
+	applyACL(public, nil)
+	if public[aclRestrictedKey] != false {
+		t.Fatalf("expected unrestricted chunk, got %v", public)
+	}
+}
+
+func TestACLWhere(t *testing.T) {
+	tests := []struct {
+		name       string
+		principals []string
+		want       string
+	}{
+		{"anonymous", nil, `{"acl_restricted":{"$ne":true}}`},
+		{"with principals", []string{"alice", "group:eng"}, `{"$or":[{"acl_restricted":{"$ne":true}},{"acl:alice":{"$eq":true}},{"acl:group:eng":{"$eq":true}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithPrincipals(context.Background(), tt.principals)
+			got, err := json.Marshal(aclWhere(PrincipalsFromContext(ctx)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("aclWhere() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// aclCollection holds documents with ACL metadata and evaluates the
+// $or/$and/$eq/$ne filters Get is called with.
+type aclCollection struct {
+	chroma.Collection
+	docs map[string]map[string]interface{}
+}
+
+func (c *aclCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var where map[string]interface{}
+	if op.Where != nil {
+		raw, err := op.Where.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(raw, &where)
+	}
+	res := &chroma.GetResultImpl{}
+	for id, md := range c.docs {
+		if where == nil || matchWhere(where, md) {
+			res.Ids = append(res.Ids, chroma.DocumentID(id))
+			res.Documents = append(res.Documents, chroma.NewTextDocument(id))
+			res.Metadatas = append(res.Metadatas, toDocumentMetadata(md))
+		}
+	}
+	return res, nil
+}
+
+func matchWhere(where map[string]interface{}, md map[string]interface{}) bool {
+	for key, cond := range where {
+		switch key {
+		case "$or", "$and":
+			matched := false
+			for _, sub := range cond.([]interface{}) {
+				ok := matchWhere(sub.(map[string]interface{}), md)
+				if key == "$and" && !ok {
+					return false
+				}
+				matched = matched || ok
+			}
+			if key == "$or" && !matched {
+				return false
+			}
+		default:
+			for op, want := range cond.(map[string]interface{}) {
+				if (op == "$eq") != (md[key] == want) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+type aclClient struct {
+	chroma.Client
+	collection *aclCollection
+}
+
+func (c aclClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestGetCollectionDocumentsACL(t *testing.T) {
+	public, eng := map[string]interface{}{}, map[string]interface{}{}
+	applyACL(public, nil)
+	applyACL(eng, []string{"group:eng"})
+	s := NewIngestService(aclClient{collection: &aclCollection{docs: map[string]map[string]interface{}{"public": public, "eng": eng}}})
+
+	for _, tt := range []struct {
+		principals []string
+		want       string
+	}{
+		{nil, "public"},
+		{[]string{"bob"}, "public"},
+		{[]string{"alice", "group:eng"}, "eng,public"},
+	} {
+		docs, err := s.GetCollectionDocuments(WithPrincipals(context.Background(), tt.principals), "docs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, d := range docs {
+			ids = append(ids, d.ID)
+		}
+		sort.Strings(ids)
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("principals %v: got %q, want %q", tt.principals, got, tt.want)
+		}
+	}
+}
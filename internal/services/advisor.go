@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Advisor thresholds.
+const (
+	advisorTinyChunkTokens   = 32
+	advisorMaxChunkTokens    = 512
+	advisorTinyRatioWarn     = 0.25
+	advisorDuplicateRatioMax = 0.05
+)
+
+// ErrCollectionNotFound is returned when advising on a collection that
+// doesn't exist.
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// ChunkSizeStats summarizes chunk sizes in tokens of the collection's tokenizer.
+type ChunkSizeStats struct {
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	Mean      float64 `json:"mean"`
+	P50       int     `json:"p50"`
+	P95       int     `json:"p95"`
+	Tiny      int     `json:"tiny"`
+	Oversized int     `json:"oversized"`
+}
+
+// Recommendation is a suggested maintenance action for a collection.
+type Recommendation struct {
+	Action   string `json:"action"` // "rechunk", "dedupe" or "cleanup"
+	Severity string `json:"severity"`
+	Reason   string `json:"reason"`
+}
+
+// AdvisorReport describes a collection's shape and recommended actions.
+type AdvisorReport struct {
+	Collection      string           `json:"collection"`
+	Tokenizer       string           `json:"tokenizer"`
+	Chunks          int              `json:"chunks"`
+	Files           int              `json:"files"` // distinct file names
+	ChunkSize       ChunkSizeStats   `json:"chunk_size"`
+	DuplicateChunks int              `json:"duplicate_chunks"`
+	DuplicateRatio  float64          `json:"duplicate_ratio"`
+	StaleFiles      []string         `json:"stale_files"`
+	StaleChunks     int              `json:"stale_chunks"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// Advise scans a collection and recommends re-chunking, dedupe or cleanup.
+func (s *IngestService) Advise(ctx context.Context, collectionName string) (*AdvisorReport, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionName)
+	}
+	tokenizer, err := s.CollectionTokenizer(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	records, err := scanRecords(ctx, collection, nil)
+	if err != nil {
+		return nil, err
+	}
+	report := analyzeRecords(records, tokenizer, s.keys)
+	report.Collection = collectionName
+	return &report, nil
+}
+
+// analyzeRecords computes advisor statistics over a collection's records.
+// A file is stale when a newer ingest of the same file_name (by timestamp)
+// exists under a different file_md5.
+func analyzeRecords(records []Record, tokenizer Tokenizer, keys SystemKeys) AdvisorReport {
+	report := AdvisorReport{Tokenizer: tokenizer.Name(), Chunks: len(records), StaleFiles: []string{}, Recommendations: []Recommendation{}}
+	if len(records) == 0 {
+		return report
+	}
+
+	sizes := make([]int, 0, len(records))
+	seen := make(map[[32]byte]bool)
+	type version struct {
+		md5    string
+		ts     int64
+		chunks int
+	}
+	versions := make(map[string]map[string]*version) // file_name -> md5 -> version
+	total := 0
+
+	for _, r := range records {
+		n := tokenizer.Count(r.Document)
+		sizes = append(sizes, n)
+		total += n
+		if n < advisorTinyChunkTokens {
+			report.ChunkSize.Tiny++
+		}
+		if n > advisorMaxChunkTokens {
+			report.ChunkSize.Oversized++
+		}
+
+		h := sha256.Sum256([]byte(strings.TrimSpace(r.Document)))
+		if seen[h] {
+			report.DuplicateChunks++
+		}
+		seen[h] = true
+
+		name, _ := r.Metadata[keys.FileName].(string)
+		md5, _ := r.Metadata[keys.FileMD5].(string)
+		if name == "" || md5 == "" {
+			continue
+		}
+		if versions[name] == nil {
+			versions[name] = make(map[string]*version)
+		}
+		v := versions[name][md5]
+		if v == nil {
+			v = &version{md5: md5}
+			versions[name][md5] = v
+		}
+		v.chunks++
+		if ts := toInt64(r.Metadata[keys.Timestamp]); ts > v.ts {
+			v.ts = ts
+		}
+	}
+
+	sort.Ints(sizes)
+	report.ChunkSize.Min = sizes[0]
+	report.ChunkSize.Max = sizes[len(sizes)-1]
+	report.ChunkSize.Mean = float64(total) / float64(len(sizes))
+	report.ChunkSize.P50 = sizes[len(sizes)/2]
+	report.ChunkSize.P95 = sizes[(len(sizes)*95)/100]
+	report.DuplicateRatio = float64(report.DuplicateChunks) / float64(len(records))
+
+	report.Files = len(versions)
+	for name, byMD5 := range versions {
+		if len(byMD5) < 2 {
+			continue
+		}
+		var newest *version
+		for _, v := range byMD5 {
+			if newest == nil || v.ts > newest.ts {
+				newest = v
+			}
+		}
+		for _, v := range byMD5 {
+			if v != newest {
+				report.StaleChunks += v.chunks
+			}
+		}
+		report.StaleFiles = append(report.StaleFiles, name)
+	}
+	sort.Strings(report.StaleFiles)
+
+	tinyRatio := float64(report.ChunkSize.Tiny) / float64(len(records))
+	if tinyRatio > advisorTinyRatioWarn || report.ChunkSize.Oversized > 0 {
+		report.Recommendations = append(report.Recommendations, Recommendation{
+			Action:   "rechunk",
+			Severity: "warn",
+			Reason: fmt.Sprintf("%d of %d chunks are under %d tokens and %d exceed %d tokens",
+				report.ChunkSize.Tiny, len(records), advisorTinyChunkTokens, report.ChunkSize.Oversized, advisorMaxChunkTokens),
+		})
+	}
+	if report.DuplicateRatio > advisorDuplicateRatioMax {
+		report.Recommendations = append(report.Recommendations, Recommendation{
+			Action:   "dedupe",
+			Severity: "warn",
+			Reason:   fmt.Sprintf("%.1f%% of chunks duplicate another chunk's text", report.DuplicateRatio*100),
+		})
+	}
+	if report.StaleChunks > 0 {
+		report.Recommendations = append(report.Recommendations, Recommendation{
+			Action:   "cleanup",
+			Severity: "info",
+			Reason:   fmt.Sprintf("%d files have older versions still indexed (%d stale chunks)", len(report.StaleFiles), report.StaleChunks),
+		})
+	}
+	return report
+}
+
+// toInt64 reads a numeric metadata value regardless of its decoded type.
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeRecords(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	records := []Record{
+		{ID: "1", Document: long, Metadata: map[string]interface{}{"file_name": "a.md", "file_md5": "old", "timestamp": int64(1)}},
+		{ID: "2", Document: long, Metadata: map[string]interface{}{"file_name": "a.md", "file_md5": "new", "timestamp": int64(2)}},
+		{ID: "3", Document: "tiny", Metadata: map[string]interface{}{"file_name": "b.md", "file_md5": "b", "timestamp": int64(1)}},
+	}
+	report := analyzeRecords(records, whitespaceTokenizer{}, DefaultSystemKeys)
+
+	if report.Chunks != 3 || report.Files != 2 {
+		t.Errorf("chunks/files = %d/%d, want 3/2", report.Chunks, report.Files)
+	}
+	if report.DuplicateChunks != 1 {
+		t.Errorf("DuplicateChunks = %d, want 1", report.DuplicateChunks)
+	}
+	if len(report.StaleFiles) != 1 || report.StaleFiles[0] != "a.md" || report.StaleChunks != 1 {
+		t.Errorf("stale = %v/%d, want [a.md]/1", report.StaleFiles, report.StaleChunks)
+	}
+	if report.ChunkSize.Min != 1 || report.ChunkSize.Max != 100 || report.ChunkSize.Tiny != 1 {
+		t.Errorf("ChunkSize = %+v", report.ChunkSize)
+	}
+
+	actions := map[string]bool{}
+	for _, r := range report.Recommendations {
+		actions[r.Action] = true
+	}
+	for _, want := range []string{"rechunk", "dedupe", "cleanup"} {
+		if !actions[want] {
+			t.Errorf("missing %q recommendation in %+v", want, report.Recommendations)
+		}
+	}
+}
+
+func TestAdviseMissingCollection(t *testing.T) {
+	var down bool
+	s := NewIngestService(replicaClient{down: &down})
+	if _, err := s.Advise(context.Background(), "nope"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
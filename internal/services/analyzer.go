@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// analyzerSettingKey stores a collection's AnalyzerSettings in the settings table.
+const analyzerSettingKey = "analyzer"
+
+// AnalyzerSettings configures lexical tokenization for a collection. The
+// language selects the stopword list and stemmer used for lexical matching.
+type AnalyzerSettings struct {
+	Language       string   `json:"language"`
+	Stemming       bool     `json:"stemming"`
+	Stopwords      bool     `json:"stopwords"`
+	ExtraStopwords []string `json:"extra_stopwords,omitempty"`
+}
+
+// DefaultAnalyzerSettings is used for collections without stored settings.
+var DefaultAnalyzerSettings = AnalyzerSettings{Language: "english", Stemming: true, Stopwords: true}
+
+type language struct {
+	stopwords []string
+	suffixes  []string // tried longest-first by the light stemmer
+}
+
+var languages = map[string]language{
+	"none": {},
+	"english": {
+		stopwords: strings.Fields(`a an and are as at be but by for from has have how i in is it its
+			of on or that the this to was were what when where which who why will with you your`),
+		suffixes: []string{"ational", "ization", "fulness", "ousness", "iveness", "ingly", "ments", "ement",
+			"ness", "ment", "able", "ible", "ing", "ies", "ied", "ers", "est", "ed", "er", "ly", "es", "s"},
+	},
+	"german": {
+		stopwords: strings.Fields(`aber als am an auch auf aus bei bin bis das dass dem den der des die
+			du ein eine einer es für hat ich im in ist mit nicht noch oder sich sie sind und von war wie zu`),
+		suffixes: []string{"ungen", "heit", "keit", "lich", "isch", "ung", "em", "en", "er", "es", "e", "n", "s"},
+	},
+	"french": {
+		stopwords: strings.Fields(`au aux avec ce ces dans de des du elle en est et il ils je la le les
+			leur lui mais me ne nous on ou par pas pour qu que qui sa se ses son sur un une vous`),
+		suffixes: []string{"issements", "issement", "ations", "ation", "ements", "ement", "euses", "euse",
+			"ités", "ité", "ives", "ive", "eux", "es", "er", "ez", "e", "s"},
+	},
+	"spanish": {
+		stopwords: strings.Fields(`a al como con de del el en es esta este la las lo los más mi no o para
+			pero por que se si sin su sus un una y ya`),
+		suffixes: []string{"amientos", "imientos", "amiento", "imiento", "aciones", "ación", "mente",
+			"idades", "idad", "ista", "ando", "iendo", "os", "as", "es", "o", "a", "s"},
+	},
+}
+
+func init() {
+	for _, l := range languages {
+		sort.SliceStable(l.suffixes, func(i, j int) bool { return len(l.suffixes[i]) > len(l.suffixes[j]) })
+	}
+}
+
+// AnalyzerLanguages lists the supported analyzer languages.
+func AnalyzerLanguages() []string {
+	out := make([]string, 0, len(languages))
+	for l := range languages {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Validate checks that the language is supported.
+func (a AnalyzerSettings) Validate() error {
+	if _, ok := languages[a.Language]; !ok {
+		return fmt.Errorf("unsupported analyzer language %q (supported: %s)", a.Language, strings.Join(AnalyzerLanguages(), ", "))
+	}
+	return nil
+}
+
+// Analyzer turns text into normalized lexical terms.
+type Analyzer struct {
+	settings  AnalyzerSettings
+	lang      language
+	stopwords map[string]bool
+}
+
+// NewAnalyzer builds an analyzer; unknown languages fall back to "none".
+func NewAnalyzer(settings AnalyzerSettings) *Analyzer {
+	lang := languages[settings.Language]
+	a := &Analyzer{settings: settings, lang: lang, stopwords: make(map[string]bool)}
+	if settings.Stopwords {
+		for _, w := range lang.stopwords {
+			a.stopwords[w] = true
+		}
+	}
+	for _, w := range settings.ExtraStopwords {
+		a.stopwords[strings.ToLower(w)] = true
+	}
+	return a
+}
+
+// IsStopword reports whether a lowercased term is a stopword for this analyzer.
+func (a *Analyzer) IsStopword(term string) bool {
+	return a.stopwords[term]
+}
+
+// Tokens splits text into lowercase letter/digit runs without filtering.
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Analyze tokenizes text, removes stopwords and applies light stemming.
+func (a *Analyzer) Analyze(text string) []string {
+	var terms []string
+	for _, tok := range Tokens(text) {
+		if a.stopwords[tok] {
+			continue
+		}
+		if a.settings.Stemming {
+			tok = a.stem(tok)
+		}
+		terms = append(terms, tok)
+	}
+	return terms
+}
+
+// stem strips the longest matching suffix while keeping a stem of at least
+// three runes.
+func (a *Analyzer) stem(tok string) string {
+	n := len([]rune(tok))
+	for _, suf := range a.lang.suffixes {
+		if strings.HasSuffix(tok, suf) && n-len([]rune(suf)) >= 3 {
+			return strings.TrimSuffix(tok, suf)
+		}
+	}
+	return tok
+}
+
+// CollectionAnalyzer returns the analyzer settings for a collection, falling
+// back to DefaultAnalyzerSettings.
+func (s *IngestService) CollectionAnalyzer(collection string) (AnalyzerSettings, error) {
+	settings := DefaultAnalyzerSettings
+	if s.settings == nil {
+		return settings, nil
+	}
+	if _, err := s.settings.GetCollectionSetting(collection, analyzerSettingKey, &settings); err != nil {
+		return DefaultAnalyzerSettings, err
+	}
+	return settings, nil
+}
+
+// SetCollectionAnalyzer validates and stores analyzer settings for a collection.
+func (s *IngestService) SetCollectionAnalyzer(collection string, settings AnalyzerSettings) error {
+	if s.settings == nil {
+		return errNoSettingsStore
+	}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	return s.settings.SetCollectionSetting(collection, analyzerSettingKey, settings)
+}
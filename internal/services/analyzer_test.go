@@ -0,0 +1,37 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AnalyzerSettings
+		text     string
+		want     []string
+	}{
+		{"english", DefaultAnalyzerSettings, "The runners were running quickly", []string{"runn", "runn", "quick"}},
+		{"german", AnalyzerSettings{Language: "german", Stemming: true, Stopwords: true}, "Die Zeitungen und der Hund", []string{"zeit", "hund"}},
+		{"no stemming", AnalyzerSettings{Language: "english", Stopwords: true}, "Is it working?", []string{"working"}},
+		{"extra stopwords", AnalyzerSettings{Language: "none", ExtraStopwords: []string{"Forge"}}, "forge search", []string{"search"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAnalyzer(tt.settings).Analyze(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzerSettings_Validate(t *testing.T) {
+	if err := (AnalyzerSettings{Language: "klingon"}).Validate(); err == nil {
+		t.Error("expected error for unsupported language")
+	}
+	if err := DefaultAnalyzerSettings.Validate(); err != nil {
+		t.Errorf("default settings invalid: %v", err)
+	}
+}
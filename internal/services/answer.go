@@ -0,0 +1,334 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Answers. Answer retrieves context for a question and has an LLM answer
+// from it. Answers are cached by normalized question and the revision of
+// each collection searched; any change to one of those collections bumps its
+// revision, so a repeated question costs no tokens until its sources change.
+const (
+	answerCacheSize = 512
+
+	// DefaultAnswerPrompt is the system prompt used unless llm_answer_prompt
+	// overrides it.
+	DefaultAnswerPrompt = "Answer the question using only the numbered sources provided. Cite the sources you use by number, like [1]. If the sources do not contain the answer, say that you don't know."
+)
+
+// ErrNoGenerator is returned by Answer when no LLM is configured.
+var ErrNoGenerator = errors.New("answer generation is not configured")
+
+// Generator produces text from a system prompt and a user prompt.
+type Generator interface {
+	Generate(ctx context.Context, system, prompt string, params GenerationParams) (string, TokenUsage, error)
+	// Models lists the models params may select; the first is the default.
+	Models() []string
+}
+
+// LLMConfig configures the model that writes answers.
+type LLMConfig struct {
+	// URL of an OpenAI-compatible chat completions endpoint, default OpenAI's.
+	URL    string
+	APIKey string
+	Model  string
+	// Models are further models the endpoint serves.
+	Models []string
+}
+
+// NewGenerator builds a Generator, or returns nil when no model is set.
+func NewGenerator(cfg LLMConfig) (Generator, error) {
+	if cfg.Model == "" {
+		if cfg.URL != "" || len(cfg.Models) > 0 {
+			return nil, errors.New("llm_url and llm_models require llm_model")
+		}
+		return nil, nil
+	}
+	g := &ChatGenerator{URL: cfg.URL, APIKey: cfg.APIKey, Model: cfg.Model, Extra: cfg.Models, client: &http.Client{Timeout: 5 * time.Minute}}
+	if g.URL == "" {
+		g.URL = openAIChatURL
+	}
+	return g, nil
+}
+
+// WithGenerator enables Answer. An empty prompt uses DefaultAnswerPrompt.
+func (s *IngestService) WithGenerator(g Generator, prompt string) *IngestService {
+	if prompt == "" {
+		prompt = DefaultAnswerPrompt
+	}
+	s.generator, s.answerPrompt = g, prompt
+	if s.answers == nil {
+		s.answers = newAnswerCache(answerCacheSize)
+		s.events.Subscribe(func(e Event) { s.answers.bump(e.Collection) }, EventCollectionChanged)
+	}
+	return s
+}
+
+// AnswerResponse is a generated answer and the search results it drew on.
+type AnswerResponse struct {
+	Answer  string         `json:"answer"`
+	Sources []SearchResult `json:"sources"`
+	Cached  bool           `json:"cached,omitempty"`
+	// Guardrail names the collection guardrail that changed the answer.
+	Guardrail string `json:"guardrail,omitempty"`
+	// SubQuestions are the parts a decomposed question was searched by.
+	SubQuestions []string `json:"sub_questions,omitempty"`
+}
+
+// AnswerOptions pick a stored template and override its generation
+// parameters.
+type AnswerOptions struct {
+	Template string
+	GenerationParams
+	// Decompose has the LLM split a multi-part question into sub-questions,
+	// each searched separately, before answering from their merged results.
+	Decompose bool
+}
+
+// Answer searches collections for question and generates an answer from the
+// top k results.
+func (s *IngestService) Answer(ctx context.Context, collections []string, question string, k int, filter map[string]interface{}, opts AnswerOptions) (*AnswerResponse, error) {
+	if s.generator == nil {
+		return nil, ErrNoGenerator
+	}
+	system, params := s.answerPrompt, GenerationParams{}
+	if opts.Template != "" {
+		tmpl, err := s.GetTemplate(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl.Prompt != "" {
+			system = tmpl.Prompt
+		}
+		params = tmpl.GenerationParams
+	}
+	params = params.merge(opts.GenerationParams)
+	// Templates were valid when saved, but the configured models may have changed since
+	if err := params.validate(s.generator.Models()); err != nil {
+		return nil, err
+	}
+	key := s.answers.key(ctx, collections, question, k, filter, system, params, opts.Decompose)
+	if cached, ok := s.answers.get(key); ok {
+		out := *cached
+		out.Cached = true
+		return &out, nil
+	}
+
+	guards, err := s.answerGuardrails(collections)
+	if err != nil {
+		return nil, err
+	}
+	var subs []string
+	if opts.Decompose {
+		if subs, err = s.decompose(ctx, collections, question, params); err != nil {
+			return nil, err
+		}
+	}
+	var resp *SearchResponse
+	prompt := answerPrompt
+	if subs != nil {
+		resp, err = s.searchSubQuestions(ctx, collections, subs, k, filter)
+		prompt = func(question string, results []SearchResult) string {
+			return decomposedPrompt(question, subs, results)
+		}
+	} else {
+		resp, err = s.MultiSearch(ctx, collections, question, k, filter, SearchOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := &AnswerResponse{Sources: resp.Results, SubQuestions: subs}
+	if guards.confident(collections, resp.Results) {
+		text, usage, err := s.generator.Generate(ctx, system, prompt(question, resp.Results), params)
+		if err != nil {
+			return nil, fmt.Errorf("generate answer: %w", err)
+		}
+		s.costs.Record(ctx, strings.Join(collections, ","), CostGeneration, usage)
+		out.Answer, out.Guardrail = guards.check(strings.TrimSpace(text), system, len(resp.Results))
+	} else {
+		// Too little relevant context to answer from; don't spend tokens
+		out.Answer, out.Guardrail = guards.refusal, GuardrailConfidence
+	}
+	if out.Sources == nil {
+		out.Sources = []SearchResult{}
+	}
+	// Degraded retrieval may have missed sources; don't pin its answer
+	if !resp.Degraded {
+		s.answers.put(key, out)
+	}
+	return out, nil
+}
+
+// answerPrompt lays out the question under numbered sources.
+func answerPrompt(question string, results []SearchResult) string {
+	var b strings.Builder
+	b.WriteString("Sources:\n")
+	for i, r := range results {
+		fmt.Fprintf(&b, "\n[%d] %s\n", i+1, strings.TrimSpace(r.Document))
+	}
+	if len(results) == 0 {
+		b.WriteString("\n(none)\n")
+	}
+	b.WriteString("\nQuestion: ")
+	b.WriteString(question)
+	return b.String()
+}
+
+// normalizeQuestion folds case, whitespace and trailing punctuation, so
+// trivially different phrasings of a question share a cache entry.
+func normalizeQuestion(q string) string {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+	return strings.TrimRightFunc(q, unicode.IsPunct)
+}
+
+// ChatGenerator generates text with a model behind an OpenAI-compatible chat
+// completions API (OpenAI, Ollama, vLLM, ...).
+type ChatGenerator struct {
+	URL    string
+	APIKey string
+	Model  string
+	Extra  []string // further models requests may select
+	client *http.Client
+}
+
+func (g *ChatGenerator) Models() []string {
+	return append([]string{g.Model}, g.Extra...)
+}
+
+func (g *ChatGenerator) Generate(ctx context.Context, system, prompt string, params GenerationParams) (string, TokenUsage, error) {
+	req := map[string]any{
+		"model": g.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": prompt},
+		},
+	}
+	if params.Model != "" {
+		req["model"] = params.Model
+	}
+	if params.Temperature != nil {
+		req["temperature"] = *params.Temperature
+	}
+	if params.MaxTokens > 0 {
+		req["max_tokens"] = params.MaxTokens
+	}
+	if len(params.Stop) > 0 {
+		req["stop"] = params.Stop
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", TokenUsage{}, err
+	}
+	var out chatResponse
+	if err := postJSON(ctx, g.client, g.URL, g.APIKey, "application/json", "", body, &out); err != nil {
+		return "", TokenUsage{}, err
+	}
+	if len(out.Choices) == 0 {
+		return "", TokenUsage{}, errors.New("chat response has no choices")
+	}
+	return out.Choices[0].Message.Content, out.usage(g.URL, req["model"].(string)), nil
+}
+
+// chatResponse is the part of an OpenAI-compatible chat completion used here.
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// usage reports the tokens the provider counted, under the model it
+// answered with (falling back to the one requested).
+func (r chatResponse) usage(endpoint, model string) TokenUsage {
+	if r.Model != "" {
+		model = r.Model
+	}
+	return TokenUsage{Provider: providerName(endpoint), Model: model, InputTokens: r.Usage.PromptTokens, OutputTokens: r.Usage.CompletionTokens}
+}
+
+// answerCache is an LRU of generated answers plus the revision counters
+// their keys embed. Bumping a revision strands the entries keyed on the old
+// one, which then age out.
+type answerCache struct {
+	mu        sync.Mutex
+	size      int
+	order     *list.List
+	items     map[string]*list.Element
+	revisions map[string]uint64
+}
+
+type answerCacheEntry struct {
+	key    string
+	answer *AnswerResponse
+}
+
+func newAnswerCache(size int) *answerCache {
+	return &answerCache{size: size, order: list.New(), items: make(map[string]*list.Element), revisions: make(map[string]uint64)}
+}
+
+func (c *answerCache) bump(collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revisions[collection]++
+}
+
+// key identifies an answer by everything that shapes it: the normalized
+// question, each collection at its current revision, k, the filter, the
+// system prompt, generation parameters and decomposition, and the caller's
+// principals (which decide what retrieval can see).
+func (c *answerCache) key(ctx context.Context, collections []string, question string, k int, filter map[string]interface{}, system string, params GenerationParams, decompose bool) string {
+	c.mu.Lock()
+	revs := make([]string, len(collections))
+	for i, coll := range collections {
+		revs[i] = fmt.Sprintf("%s@%d", coll, c.revisions[coll])
+	}
+	c.mu.Unlock()
+	f, _ := json.Marshal(filter) // map keys are sorted
+	p, _ := json.Marshal(params)
+	principals := append([]string(nil), PrincipalsFromContext(ctx)...)
+	sort.Strings(principals)
+	return strings.Join([]string{normalizeQuestion(question), strings.Join(revs, ","), fmt.Sprint(k), string(f), system, string(p), fmt.Sprint(decompose), strings.Join(principals, ",")}, "\x00")
+}
+
+func (c *answerCache) get(key string) (*AnswerResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*answerCacheEntry).answer, true
+}
+
+func (c *answerCache) put(key string, answer *AnswerResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*answerCacheEntry).answer = answer
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&answerCacheEntry{key: key, answer: answer})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*answerCacheEntry).key)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+type docsClient struct {
+	chroma.Client
+	docs *queryCollection
+}
+
+func (c docsClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.docs, nil
+}
+
+type countingGenerator struct {
+	calls   int
+	prompts []string
+	systems []string
+	params  []GenerationParams
+}
+
+func (g *countingGenerator) Generate(ctx context.Context, system, prompt string, params GenerationParams) (string, TokenUsage, error) {
+	g.calls++
+	g.prompts = append(g.prompts, prompt)
+	g.systems = append(g.systems, system)
+	g.params = append(g.params, params)
+	return " See [1]. ", TokenUsage{}, nil
+}
+
+func (g *countingGenerator) Models() []string { return []string{"small", "large"} }
+
+func TestAnswerCache(t *testing.T) {
+	gen := &countingGenerator{}
+	s := NewIngestService(docsClient{docs: &queryCollection{name: "faq", hits: []queryHit{{"reset.md", 0.1}}}}).WithGenerator(gen, "")
+	ask := func(q string) *AnswerResponse {
+		t.Helper()
+		resp, err := s.Answer(context.Background(), []string{"faq"}, q, 3, nil, AnswerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := ask("How do I reset my password?")
+	if first.Answer != "See [1]." || first.Cached || len(first.Sources) != 1 {
+		t.Errorf("unexpected answer %+v", first)
+	}
+	if !strings.Contains(gen.prompts[0], "[1] reset.md\n") {
+		t.Errorf("prompt lacks numbered source: %q", gen.prompts[0])
+	}
+	if again := ask("  how do I reset my   password "); !again.Cached || gen.calls != 1 {
+		t.Errorf("expected normalized repeat to be cached, got %+v after %d calls", again, gen.calls)
+	}
+
+	// Changes elsewhere keep the answer; changes to faq invalidate it
+	s.publishChange(EventIngested, "other", nil)
+	if ask("How do I reset my password?"); gen.calls != 1 {
+		t.Errorf("expected cached answer after unrelated change, got %d calls", gen.calls)
+	}
+	s.publishChange(EventIngested, "faq", nil)
+	if again := ask("How do I reset my password?"); again.Cached || gen.calls != 2 {
+		t.Errorf("expected regeneration after ingest, got %+v after %d calls", again, gen.calls)
+	}
+
+	if _, err := NewIngestService(nil).Answer(context.Background(), []string{"faq"}, "q", 3, nil, AnswerOptions{}); err != ErrNoGenerator {
+		t.Errorf("expected ErrNoGenerator, got %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// ErrArchiveExists is returned when archiving a collection that already has an archive.
+var ErrArchiveExists = errors.New("archive already exists")
+
+// ErrArchiveNotFound is returned when restoring an unknown archive.
+var ErrArchiveNotFound = errors.New("archive not found")
+
+const archiveExt = ".json.gz"
+
+// ArchiveStore persists compressed collection archives outside the vector store.
+type ArchiveStore interface {
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	List() ([]ArchiveInfo, error)
+	Delete(name string) error
+}
+
+// ArchiveInfo describes an archived collection.
+type ArchiveInfo struct {
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// LocalArchiveStore keeps archives as gzip files in a local directory.
+type LocalArchiveStore struct {
+	Dir string
+}
+
+func NewLocalArchiveStore(dir string) (*LocalArchiveStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+	return &LocalArchiveStore{Dir: dir}, nil
+}
+
+func (l *LocalArchiveStore) path(name string) string {
+	return filepath.Join(l.Dir, filepath.Base(name)+archiveExt)
+}
+
+func (l *LocalArchiveStore) Create(name string) (io.WriteCloser, error) {
+	f, err := os.OpenFile(l.path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, ErrArchiveExists
+	}
+	return f, err
+}
+
+func (l *LocalArchiveStore) Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrArchiveNotFound
+	}
+	return f, err
+}
+
+func (l *LocalArchiveStore) List() ([]ArchiveInfo, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read archive dir: %w", err)
+	}
+	var out []ArchiveInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), archiveExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, ArchiveInfo{
+			Name:       strings.TrimSuffix(e.Name(), archiveExt),
+			SizeBytes:  info.Size(),
+			ArchivedAt: info.ModTime(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (l *LocalArchiveStore) Delete(name string) error {
+	err := os.Remove(l.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrArchiveNotFound
+	}
+	return err
+}
+
+// Archive store backends.
+const (
+	ArchiveLocal = "local"
+	ArchiveS3    = "s3"
+)
+
+// ArchiveConfig selects an archive store. S3 addresses the bucket and prefix
+// archives are written under; its collection fields are unused.
+type ArchiveConfig struct {
+	Backend string
+	Dir     string
+	S3      BucketSpec
+}
+
+// NewArchiveStore builds the configured store; an empty Backend is local.
+func NewArchiveStore(cfg ArchiveConfig) (ArchiveStore, error) {
+	switch cfg.Backend {
+	case "", ArchiveLocal:
+		return NewLocalArchiveStore(cfg.Dir)
+	case ArchiveS3:
+		return NewS3ArchiveStore(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown archive store %q", cfg.Backend)
+	}
+}
+
+// S3ArchiveStore keeps archives as objects under a prefix of an
+// S3-compatible bucket. An archive is buffered in memory while it is written
+// and uploaded when closed.
+type S3ArchiveStore struct {
+	objects *S3BlobStore
+}
+
+func NewS3ArchiveStore(spec BucketSpec) (*S3ArchiveStore, error) {
+	objects, err := NewS3BlobStore(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &S3ArchiveStore{objects: objects}, nil
+}
+
+func (s *S3ArchiveStore) key(name string) string {
+	return path.Base(name) + archiveExt
+}
+
+// s3ArchiveWriter uploads what was written to it on Close.
+type s3ArchiveWriter struct {
+	bytes.Buffer
+	store *S3ArchiveStore
+	key   string
+}
+
+func (w *s3ArchiveWriter) Close() error {
+	// A concurrent Create of the same name may have raced the check in
+	// Create; the condition keeps the first upload.
+	objects := w.store.objects
+	header := http.Header{"If-None-Match": {"*"}}
+	resp, err := objects.do(context.Background(), http.MethodPut, objects.spec.bucketURL(objects.spec.Prefix+w.key), w.Bytes(), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed:
+		return ErrArchiveExists
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("put archive: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+func (s *S3ArchiveStore) Create(name string) (io.WriteCloser, error) {
+	exists, err := s.exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrArchiveExists
+	}
+	return &s3ArchiveWriter{store: s, key: s.key(name)}, nil
+}
+
+func (s *S3ArchiveStore) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.objects.request(context.Background(), http.MethodGet, s.key(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrArchiveNotFound
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("get archive: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// exists reports whether the archive's object is present.
+func (s *S3ArchiveStore) exists(name string) (bool, error) {
+	resp, err := s.objects.request(context.Background(), http.MethodHead, s.key(name), nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("head archive: unexpected status %s", resp.Status)
+	}
+}
+
+// List pages through ListObjectsV2 under the prefix. Objects in nested
+// folders are not archives of this store.
+func (s *S3ArchiveStore) List() ([]ArchiveInfo, error) {
+	spec := s.objects.spec
+	var out []ArchiveInfo
+	token := ""
+	for {
+		u := spec.bucketURL("")
+		q := url.Values{"list-type": {"2"}}
+		if spec.Prefix != "" {
+			q.Set("prefix", spec.Prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		resp, err := s.objects.do(context.Background(), http.MethodGet, u, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list archives: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			return nil, fmt.Errorf("list archives: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		var page struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list archives: %w", err)
+		}
+		for _, obj := range page.Contents {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(obj.Key, spec.Prefix), archiveExt)
+			if !ok || name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			out = append(out, ArchiveInfo{Name: name, SizeBytes: obj.Size, ArchivedAt: obj.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Delete removes an archive. S3 reports success for deleting a missing
+// object, so its presence is checked first.
+func (s *S3ArchiveStore) Delete(name string) error {
+	exists, err := s.exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrArchiveNotFound
+	}
+	resp, err := s.objects.request(context.Background(), http.MethodDelete, s.key(name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("delete archive: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// collectionArchive is the on-disk archive format.
+type collectionArchive struct {
+	Name       string                 `json:"name"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	ArchivedAt time.Time              `json:"archived_at"`
+	Records    []Record               `json:"records"`
+}
+
+// ArchiveService moves collections between Chroma and cold storage.
+type ArchiveService struct {
+	chromaDB chroma.Client
+	store    ArchiveStore
+	notifier Notifier
+
+	mu sync.Mutex
+	// mounted lists the restored snapshot collections, most recently used last.
+	mounted []string
+}
+
+func NewArchiveService(chromaDB chroma.Client, store ArchiveStore) *ArchiveService {
+	return &ArchiveService{chromaDB: chromaDB, store: store}
+}
+
+// WithNotifier reports archive and restore outcomes to n.
+func (s *ArchiveService) WithNotifier(n Notifier) *ArchiveService {
+	s.notifier = n
+	return s
+}
+
+func (s *ArchiveService) notifyResult(op, name string, records int, err error) {
+	result := BackupResult{Operation: op, Collection: name, Records: records}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	notify(s.notifier, EventBackupResult, result)
+}
+
+// Archive exports a collection (including embeddings) to the archive store and
+// removes it from Chroma. The collection is only deleted once the archive has
+// been fully written.
+func (s *ArchiveService) Archive(ctx context.Context, name string) (_ *ArchiveInfo, err error) {
+	var records []Record
+	defer func() { s.notifyResult("archive", name, len(records), err) }()
+	archive, err := s.export(ctx, name, name)
+	if archive != nil {
+		records = archive.Records
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.chromaDB.DeleteCollection(ctx, name); err != nil {
+		return nil, fmt.Errorf("delete archived collection %q: %w", name, err)
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"collection": name,
+		"records":    len(records),
+	}).Info("Archived collection")
+	return &ArchiveInfo{Name: name, ArchivedAt: archive.ArchivedAt}, nil
+}
+
+// export writes a collection, including embeddings, to the store as entry.
+func (s *ArchiveService) export(ctx context.Context, name, entry string) (*collectionArchive, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get collection %q: %w", name, err)
+	}
+	records, err := scanRecords(ctx, collection, nil, chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+	archive := &collectionArchive{
+		Name:       name,
+		Metadata:   collectionMetadataToMap(collection.Metadata()),
+		ArchivedAt: time.Now().UTC(),
+		Records:    records,
+	}
+
+	w, err := s.store.Create(entry)
+	if err != nil {
+		return archive, err
+	}
+	gz := gzip.NewWriter(w)
+	encErr := json.NewEncoder(gz).Encode(archive)
+	gzErr := gz.Close()
+	closeErr := w.Close()
+	if err := errors.Join(encErr, gzErr, closeErr); err != nil {
+		_ = s.store.Delete(entry)
+		return archive, fmt.Errorf("write archive %q: %w", entry, err)
+	}
+	return archive, nil
+}
+
+// load reads the store entry written by export.
+func (s *ArchiveService) load(entry string) (*collectionArchive, error) {
+	r, err := s.store.Open(entry)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open archive %q: %w", entry, err)
+	}
+	defer gz.Close()
+	var archive collectionArchive
+	dec := json.NewDecoder(gz)
+	dec.UseNumber() // keep integer metadata (e.g. chunk_index) as ints
+	if err := dec.Decode(&archive); err != nil {
+		return nil, fmt.Errorf("decode archive %q: %w", entry, err)
+	}
+	return &archive, nil
+}
+
+// create recreates an archive's collection in Chroma as name.
+func (s *ArchiveService) create(ctx context.Context, name string, archive *collectionArchive) error {
+	var createOpts []chroma.CreateCollectionOption
+	if len(archive.Metadata) > 0 {
+		createOpts = append(createOpts, chroma.WithCollectionMetadataCreate(chroma.NewMetadataFromMap(archive.Metadata)))
+	}
+	collection, err := s.chromaDB.CreateCollection(ctx, name, createOpts...)
+	if err != nil {
+		return fmt.Errorf("create collection %q: %w", name, err)
+	}
+	return dimensionError(name, writeRecords(ctx, collection.Add, archive.Records))
+}
+
+// ListArchives returns all archived collections.
+func (s *ArchiveService) ListArchives() ([]ArchiveInfo, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	out := entries[:0]
+	for _, e := range entries {
+		if !strings.Contains(e.Name, snapshotSeparator) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Restore recreates an archived collection in Chroma with its stored
+// embeddings and removes the archive.
+func (s *ArchiveService) Restore(ctx context.Context, name string) (restored int, err error) {
+	defer func() { s.notifyResult("restore", name, restored, err) }()
+	archive, err := s.load(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := s.chromaDB.GetCollection(ctx, name); err == nil {
+		return 0, fmt.Errorf("conflict: collection %q already exists", name)
+	}
+	if err := s.create(ctx, name, archive); err != nil {
+		return 0, err
+	}
+	if err := s.store.Delete(name); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", name).Warn("Failed to remove restored archive")
+	}
+	return len(archive.Records), nil
+}
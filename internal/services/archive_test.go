@@ -0,0 +1,152 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalArchiveStore(t *testing.T) {
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := store.Create("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if _, err := store.Create("docs"); !errors.Is(err, ErrArchiveExists) {
+		t.Errorf("Create() twice error = %v, want ErrArchiveExists", err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "docs" || list[0].SizeBytes != 7 {
+		t.Errorf("List() = %+v", list)
+	}
+
+	r, err := store.Open("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != "payload" {
+		t.Errorf("Open() content = %q", got)
+	}
+
+	if err := store.Delete("docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open("docs"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("Open() after delete error = %v, want ErrArchiveNotFound", err)
+	}
+}
+
+func TestS3ArchiveStore(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]string{"/kb/archives/nested/old.json.gz": "x"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		body, ok := objects[r.URL.Path]
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			type object struct {
+				Key          string
+				Size         int
+				LastModified string
+			}
+			var page struct {
+				XMLName  xml.Name `xml:"ListBucketResult"`
+				Contents []object
+			}
+			for p, body := range objects {
+				if key := strings.TrimPrefix(p, "/kb/"); strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+					page.Contents = append(page.Contents, object{key, len(body), "2026-01-02T03:04:05.000Z"})
+				}
+			}
+			xml.NewEncoder(w).Encode(page)
+		case r.Method == http.MethodPut:
+			if ok && r.Header.Get("If-None-Match") == "*" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			b, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(b)
+		case !ok && r.Method != http.MethodDelete:
+			http.NotFound(w, r)
+		case r.Method == http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			io.WriteString(w, body)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewArchiveStore(ArchiveConfig{Backend: ArchiveS3, S3: BucketSpec{Endpoint: srv.URL, Bucket: "kb", Prefix: "archives", AccessKey: "AK", SecretKey: "SK"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := store.Create("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("payload"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if objects["/kb/archives/docs.json.gz"] != "payload" {
+		t.Fatalf("unexpected objects %v", objects)
+	}
+	if _, err := store.Create("docs"); !errors.Is(err, ErrArchiveExists) {
+		t.Errorf("Create() twice error = %v, want ErrArchiveExists", err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "docs" || list[0].SizeBytes != 7 || !list[0].ArchivedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("List() = %+v", list)
+	}
+
+	r, err := store.Open("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != "payload" {
+		t.Errorf("Open() content = %q", got)
+	}
+
+	if err := store.Delete("docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open("docs"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("Open() after delete error = %v, want ErrArchiveNotFound", err)
+	}
+	if err := store.Delete("docs"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrArchiveNotFound", err)
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"golang.org/x/sync/errgroup"
+)
+
+// BatchTuning bounds adaptive ingest batching. Batch size and the number of
+// concurrent batch writes (each embeds its chunks) grow while writes finish
+// well under Target and shrink when they are slow or fail.
+type BatchTuning struct {
+	MinBatch       int
+	MaxBatch       int
+	MaxConcurrency int
+	Target         time.Duration
+}
+
+// DefaultBatchTuning is used unless WithBatchTuning overrides it.
+var DefaultBatchTuning = BatchTuning{MinBatch: 16, MaxBatch: 512, MaxConcurrency: 4, Target: 2 * time.Second}
+
+// BatchState is the batcher's current settings and what it last observed.
+type BatchState struct {
+	BatchSize   int    `json:"batch_size"`
+	Concurrency int    `json:"concurrency"`
+	LastLatency string `json:"last_latency,omitempty"`
+	Errors      int    `json:"errors"`
+}
+
+// adaptiveBatcher tunes batch size and concurrency with additive increase,
+// multiplicative decrease from observed write latency and errors.
+type adaptiveBatcher struct {
+	mu          sync.Mutex
+	tuning      BatchTuning
+	size        int
+	concurrency int
+	last        time.Duration
+	errors      int
+}
+
+func newAdaptiveBatcher(t BatchTuning) *adaptiveBatcher {
+	if t.MinBatch <= 0 {
+		t.MinBatch = 1
+	}
+	if t.MaxBatch < t.MinBatch {
+		t.MaxBatch = t.MinBatch
+	}
+	if t.MaxConcurrency <= 0 {
+		t.MaxConcurrency = 1
+	}
+	if t.Target <= 0 {
+		t.Target = DefaultBatchTuning.Target
+	}
+	return &adaptiveBatcher{tuning: t, size: t.MinBatch, concurrency: 1}
+}
+
+// WithBatchTuning sets the bounds used for adaptive ingest batching.
+func (s *IngestService) WithBatchTuning(t BatchTuning) *IngestService {
+	s.batcher = newAdaptiveBatcher(t)
+	return s
+}
+
+// BatchState reports the current adaptive batching settings.
+func (s *IngestService) BatchState() BatchState {
+	return s.batcher.state()
+}
+
+func (b *adaptiveBatcher) current() (size, concurrency int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size, b.concurrency
+}
+
+func (b *adaptiveBatcher) state() BatchState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BatchState{BatchSize: b.size, Concurrency: b.concurrency, Errors: b.errors}
+	if b.last > 0 {
+		st.LastLatency = b.last.Round(time.Millisecond).String()
+	}
+	return st
+}
+
+// observe feeds back one batch write of n chunks.
+func (b *adaptiveBatcher) observe(n int, d time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last = d
+	t := b.tuning
+	switch {
+	case err != nil:
+		b.errors++
+		b.size = max(t.MinBatch, b.size/2)
+		b.concurrency = max(1, b.concurrency/2)
+	case d > t.Target:
+		b.size = max(t.MinBatch, b.size/2)
+		b.concurrency = max(1, b.concurrency-1)
+	case d < t.Target/2 && n >= b.size:
+		// Only grow on full batches; small files say nothing about headroom
+		if b.size < t.MaxBatch {
+			b.size = min(t.MaxBatch, b.size+max(1, b.size/4))
+		} else {
+			b.concurrency = min(t.MaxConcurrency, b.concurrency+1)
+		}
+	}
+}
+
+// upsertChunks writes chunks in adaptively sized batches, running up to the
+// current concurrency of batches at a time. A failed batch is retried once
+// at the reduced size before the error is returned; a failure cancels the
+// round's other batches. embs, when non-nil, holds a precomputed vector per
+// chunk.
+func (s *IngestService) upsertChunks(ctx context.Context, collection chroma.Collection, ids []chroma.DocumentID, texts []string, metadatas []chroma.DocumentMetadata, embs []embeddings.Embedding) error {
+	write := func(ctx context.Context, start, end int) error {
+		wctx, cancel := withTimeout(ctx, s.timeouts.Embed)
+		defer cancel()
+		began := time.Now()
+		var batch []embeddings.Embedding
+		if embs != nil {
+			batch = embs[start:end]
+		} else if s.embedder != nil {
+			// Embed here rather than inside the Chroma client's write, so
+			// the two are timed apart
+			vecs, err := s.embedder.EmbedDocuments(wctx, texts[start:end])
+			if err != nil {
+				s.batcher.observe(end-start, time.Since(began), err)
+				return fmt.Errorf("embed chunks: %w", err)
+			}
+			batch = vecs
+			recordStage(ctx, stageEmbed, began)
+		}
+		added := time.Now()
+		opts := []chroma.CollectionAddOption{
+			chroma.WithIDs(ids[start:end]...),
+			chroma.WithTexts(texts[start:end]...),
+			chroma.WithMetadatas(metadatas[start:end]...),
+		}
+		if batch != nil {
+			opts = append(opts, chroma.WithEmbeddings(batch...))
+		}
+		err := collection.Upsert(wctx, opts...)
+		recordStage(ctx, stageAdd, added)
+		s.batcher.observe(end-start, time.Since(began), err)
+		if err == nil && embs == nil {
+			s.costs.embedded(ctx, collection.Name(), texts[start:end]...)
+		}
+		return err
+	}
+	retry := func(ctx context.Context, start, end int) error {
+		size, _ := s.batcher.current()
+		for i := start; i < end; i += size {
+			if err := write(ctx, i, min(i+size, end)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for next := 0; next < len(ids); {
+		size, concurrency := s.batcher.current()
+		g, gctx := errgroup.WithContext(ctx)
+		for w := 0; w < concurrency && next < len(ids); w++ {
+			start, end := next, min(next+size, len(ids))
+			next = end
+			g.Go(func() error {
+				if err := write(gctx, start, end); err != nil {
+					if gctx.Err() != nil {
+						return err
+					}
+					return retry(gctx, start, end)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackChunks deletes the chunks a failed upsertChunks call may have
+// written for the file version md5Hash, so its dedupe check doesn't take a
+// partial file for an ingested one. The delete runs even when ctx was
+// canceled; a failure is logged, since the original error is the one
+// returned.
+func (s *IngestService) rollbackChunks(ctx context.Context, collection chroma.Collection, ids []chroma.DocumentID, md5Hash string) {
+	err := collection.Delete(context.WithoutCancel(ctx), chroma.WithIDsDelete(ids...), chroma.WithWhereDelete(chroma.EqString(s.keys.FileMD5, md5Hash)))
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("md5", md5Hash).Error("Error rolling back partially written chunks")
+	}
+}
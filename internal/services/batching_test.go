@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func TestAdaptiveBatcherGrowsAndShrinks(t *testing.T) {
+	b := newAdaptiveBatcher(BatchTuning{MinBatch: 8, MaxBatch: 16, MaxConcurrency: 3, Target: time.Second})
+
+	for i := 0; i < 10; i++ {
+		size, _ := b.current()
+		b.observe(size, 10*time.Millisecond, nil)
+	}
+	if size, conc := b.current(); size != 16 || conc != 3 {
+		t.Fatalf("expected growth to bounds (16, 3), got (%d, %d)", size, conc)
+	}
+
+	b.observe(16, 2*time.Second, nil)
+	if size, conc := b.current(); size != 8 || conc != 2 {
+		t.Fatalf("expected slow batch to shrink to (8, 2), got (%d, %d)", size, conc)
+	}
+
+	b.observe(8, time.Millisecond, errors.New("boom"))
+	if size, conc := b.current(); size != 8 || conc != 1 {
+		t.Fatalf("expected error to back off to (8, 1), got (%d, %d)", size, conc)
+	}
+}
+
+func TestAdaptiveBatcherIgnoresPartialBatches(t *testing.T) {
+	b := newAdaptiveBatcher(BatchTuning{MinBatch: 8, MaxBatch: 64, MaxConcurrency: 1, Target: time.Second})
+	b.observe(3, time.Millisecond, nil)
+	if size, _ := b.current(); size != 8 {
+		t.Fatalf("expected partial batch not to grow size, got %d", size)
+	}
+}
+
+// flakyCollection fails every upsert after the first ok.
+type flakyCollection struct {
+	*fileCollection
+	ok, upserts int
+}
+
+func (c *flakyCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	c.upserts++
+	if c.upserts > c.ok {
+		return errors.New("connection reset")
+	}
+	return c.fileCollection.Upsert(ctx, opts...)
+}
+
+type flakyClient struct {
+	chroma.Client
+	collection *flakyCollection
+}
+
+func (c flakyClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func (c flakyClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestUpsertChunksRollsBackPartialFile(t *testing.T) {
+	ctx := context.Background()
+	col := &flakyCollection{fileCollection: &fileCollection{records: map[string]Record{}}, ok: 1}
+	s := NewIngestService(flakyClient{collection: col}).
+		WithBatchTuning(BatchTuning{MinBatch: 1, MaxBatch: 1, MaxConcurrency: 1, Target: time.Second})
+
+	content := []byte("# A\nalpha\n# B\nbeta\n# C\ngamma\n# D\ndelta")
+	if _, err := s.IngestFileWithOptions(ctx, "docs", "guide.md", content, IngestOptions{}); err == nil {
+		t.Fatal("expected the failed batch to fail the ingest")
+	}
+	if col.upserts < 2 {
+		t.Fatalf("expected a batch written before the failure, got %d upserts", col.upserts)
+	}
+	if len(col.records) != 0 {
+		t.Errorf("expected the written batch rolled back, got %d chunks", len(col.records))
+	}
+
+	// With nothing left behind, the retried ingest isn't skipped as a duplicate
+	col.ok = col.upserts + 100
+	res, err := s.IngestFileWithOptions(ctx, "docs", "guide.md", content, IngestOptions{})
+	if err != nil || res.Status != "ingested" {
+		t.Fatalf("expected the retry ingested, got %+v, %v", res, err)
+	}
+}
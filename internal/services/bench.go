@@ -0,0 +1,230 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// benchCollectionPrefix names the scratch collections benchmarks ingest into.
+const benchCollectionPrefix = "forge-bench-"
+
+// ErrInvalidBench is returned for benchmark options out of range.
+var ErrInvalidBench = errors.New("invalid benchmark options")
+
+// benchWords is the vocabulary synthetic documents are drawn from.
+var benchWords = strings.Fields(`account agent alert archive backup batch billing branch
+	buffer cache cluster commit config container cursor dashboard database deploy
+	disk domain driver endpoint engine event export failover feature firewall gateway
+	index invoice kernel latency ledger license limit lock metric migration monitor
+	network node operator package partition patch pipeline policy pool quota queue
+	region release replica request router runtime schema secret server session shard
+	snapshot storage stream subnet tenant token topic trace upgrade volume webhook worker`)
+
+// BenchOptions size a benchmark run. Zero values take the defaults.
+type BenchOptions struct {
+	// Docs is how many synthetic documents to ingest (default 200).
+	Docs int `json:"docs"`
+	// DocWords is the length of each document in words (default 300).
+	DocWords int `json:"doc_words"`
+	// Queries is how many searches to run (default 200).
+	Queries int `json:"queries"`
+	// Concurrency is how many ingests or searches run at once (default 4).
+	Concurrency int `json:"concurrency"`
+	// K is the number of results per search (default 5).
+	K int `json:"k"`
+	// Seed makes the corpus and queries reproducible (default 1).
+	Seed uint64 `json:"seed"`
+	// Keep leaves the scratch collection in place after the run.
+	Keep bool `json:"keep"`
+}
+
+func (o *BenchOptions) defaults() error {
+	if o.Docs < 0 || o.DocWords < 0 || o.Queries < 0 || o.Concurrency < 0 || o.K < 0 {
+		return fmt.Errorf("%w: sizes must not be negative", ErrInvalidBench)
+	}
+	o.Docs = cmp.Or(o.Docs, 200)
+	o.DocWords = cmp.Or(o.DocWords, 300)
+	o.Queries = cmp.Or(o.Queries, 200)
+	o.Concurrency = cmp.Or(o.Concurrency, 4)
+	o.K = cmp.Or(o.K, 5)
+	o.Seed = cmp.Or(o.Seed, 1)
+	return nil
+}
+
+// BenchPhase reports one phase of a benchmark.
+type BenchPhase struct {
+	Ops        int     `json:"ops"`
+	Errors     int     `json:"errors"`
+	Seconds    float64 `json:"seconds"`
+	OpsPerSec  float64 `json:"ops_per_sec"`
+	LatencyMS  Latency `json:"latency_ms"`
+	FirstError string  `json:"first_error,omitempty"`
+}
+
+// Latency summarizes operation latencies in milliseconds.
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// BenchReport is the result of a benchmark run.
+type BenchReport struct {
+	Collection string       `json:"collection"`
+	Options    BenchOptions `json:"options"`
+	Ingest     BenchPhase   `json:"ingest"`
+	// Chunks is how many chunks the ingest phase wrote.
+	Chunks       int        `json:"chunks"`
+	ChunksPerSec float64    `json:"chunks_per_sec"`
+	Search       BenchPhase `json:"search"`
+}
+
+// Bench ingests synthetic documents into a scratch collection, runs a
+// search workload against it and reports throughput and latency
+// percentiles for both, through the same code paths as the API. The
+// collection is dropped afterwards unless opts.Keep is set.
+func (s *IngestService) Bench(ctx context.Context, opts BenchOptions) (*BenchReport, error) {
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	docs := make([]string, opts.Docs)
+	for i := range docs {
+		docs[i] = benchDocument(rng, opts.DocWords)
+	}
+	queries := make([]string, opts.Queries)
+	for i := range queries {
+		queries[i] = benchQuery(rng, docs)
+	}
+
+	report := &BenchReport{Collection: fmt.Sprintf("%s%d", benchCollectionPrefix, time.Now().UnixNano()), Options: opts}
+	if !opts.Keep {
+		defer func() { _ = s.dropCollection(context.WithoutCancel(ctx), report.Collection) }()
+	}
+	var chunks atomic.Int64
+	report.Ingest = runBenchPhase(ctx, len(docs), opts.Concurrency, func(ctx context.Context, i int) error {
+		res, err := s.IngestFileWithOptions(ctx, report.Collection, fmt.Sprintf("bench-%05d.txt", i), []byte(docs[i]), IngestOptions{})
+		if err == nil && res.Error != "" {
+			err = errors.New(res.Error)
+		}
+		if err == nil {
+			chunks.Add(int64(res.Chunks))
+		}
+		return err
+	})
+	report.Chunks = int(chunks.Load())
+	if report.Ingest.Seconds > 0 {
+		report.ChunksPerSec = float64(report.Chunks) / report.Ingest.Seconds
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	report.Search = runBenchPhase(ctx, len(queries), opts.Concurrency, func(ctx context.Context, i int) error {
+		_, err := s.SearchWithFallback(ctx, report.Collection, queries[i], opts.K, nil, SearchOptions{})
+		return err
+	})
+	return report, ctx.Err()
+}
+
+// runBenchPhase runs op for 0..n-1 on concurrency workers and times each call.
+func runBenchPhase(ctx context.Context, n, concurrency int, op func(ctx context.Context, i int) error) BenchPhase {
+	latencies := make([]time.Duration, n)
+	errs := make([]error, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range min(concurrency, max(n, 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				t := time.Now()
+				errs[i] = op(ctx, i)
+				latencies[i] = time.Since(t)
+			}
+		}()
+	}
+	ran := 0
+	for ; ran < n && ctx.Err() == nil; ran++ {
+		next <- ran
+	}
+	close(next)
+	wg.Wait()
+
+	phase := BenchPhase{Ops: ran, Seconds: time.Since(start).Seconds()}
+	for _, err := range errs[:ran] {
+		if err != nil {
+			if phase.Errors == 0 {
+				phase.FirstError = err.Error()
+			}
+			phase.Errors++
+		}
+	}
+	if phase.Seconds > 0 {
+		phase.OpsPerSec = float64(ran) / phase.Seconds
+	}
+	phase.LatencyMS = latencyStats(latencies[:ran])
+	return phase
+}
+
+// latencyStats summarizes durations, using nearest-rank percentiles.
+func latencyStats(d []time.Duration) Latency {
+	if len(d) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration(nil), d...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	rank := func(p int) float64 { return ms(sorted[max((len(sorted)*p+99)/100, 1)-1]) }
+	var total time.Duration
+	for _, v := range sorted {
+		total += v
+	}
+	return Latency{
+		Mean: ms(total / time.Duration(len(sorted))),
+		P50:  rank(50),
+		P95:  rank(95),
+		P99:  rank(99),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
+
+// benchDocument writes sentences of benchWords until the document has words words.
+func benchDocument(rng *rand.Rand, words int) string {
+	var b strings.Builder
+	for i := 0; i < words; i++ {
+		w := benchWords[rng.IntN(len(benchWords))]
+		if i%12 == 0 {
+			if i > 0 {
+				b.WriteString(". ")
+			}
+			w = strings.ToUpper(w[:1]) + w[1:]
+		} else {
+			b.WriteByte(' ')
+		}
+		b.WriteString(w)
+	}
+	b.WriteByte('.')
+	return b.String()
+}
+
+// benchQuery takes three consecutive words of a random document, so queries
+// have matches.
+func benchQuery(rng *rand.Rand, docs []string) string {
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(docs[rng.IntN(len(docs))], ".", "")))
+	if len(words) <= 3 {
+		return strings.Join(words, " ")
+	}
+	i := rng.IntN(len(words) - 3)
+	return strings.Join(words[i:i+3], " ")
+}
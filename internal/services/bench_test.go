@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+// benchCollection accepts writes and answers every query with no hits.
+type benchCollection struct {
+	*writeCollection
+	queries atomic.Int32
+}
+
+func (c *benchCollection) Metadata() chroma.CollectionMetadata { return nil }
+
+func (c *benchCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	c.queries.Add(1)
+	return &chroma.QueryResultImpl{IDLists: []chroma.DocumentIDs{nil}, DocumentsLists: []chroma.Documents{nil},
+		MetadatasLists: []chroma.DocumentMetadatas{nil}, DistancesLists: []embeddings.Distances{nil}}, nil
+}
+
+type benchClient struct {
+	chroma.Client
+	collection *benchCollection
+	dropped    *string
+}
+
+func (c benchClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func (c benchClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func (c benchClient) DeleteCollection(ctx context.Context, name string, opts ...chroma.DeleteCollectionOption) error {
+	*c.dropped = name
+	return nil
+}
+
+func TestBench(t *testing.T) {
+	col := &benchCollection{writeCollection: &writeCollection{files: map[string]int{}}}
+	var dropped string
+	s := NewIngestService(benchClient{collection: col, dropped: &dropped})
+
+	report, err := s.Bench(context.Background(), BenchOptions{Docs: 12, DocWords: 40, Queries: 30, Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Ingest.Ops != 12 || report.Ingest.Errors != 0 || len(col.files) != 12 || report.Chunks == 0 {
+		t.Errorf("expected 12 documents ingested, got %+v with %d files", report.Ingest, len(col.files))
+	}
+	if report.Search.Ops != 30 || report.Search.Errors != 0 || col.queries.Load() != 30 {
+		t.Errorf("expected 30 searches, got %+v with %d queries", report.Search, col.queries.Load())
+	}
+	if !strings.HasPrefix(report.Collection, benchCollectionPrefix) || dropped != report.Collection {
+		t.Errorf("expected the scratch collection %q dropped, dropped %q", report.Collection, dropped)
+	}
+	if report.Options.K != 5 || report.Options.Seed != 1 {
+		t.Errorf("expected defaults filled in, got %+v", report.Options)
+	}
+
+	if _, err := s.Bench(context.Background(), BenchOptions{Docs: -1}); !errors.Is(err, ErrInvalidBench) {
+		t.Errorf("expected ErrInvalidBench, got %v", err)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var d []time.Duration
+	for i := 100; i >= 1; i-- {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	got := latencyStats(d)
+	if got.P50 != 50 || got.P95 != 95 || got.P99 != 99 || got.Max != 100 || got.Mean != 50.5 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+	if got := latencyStats([]time.Duration{7 * time.Millisecond}); got.P50 != 7 || got.P99 != 7 {
+		t.Errorf("expected one sample to be every percentile, got %+v", got)
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+var (
+	// ErrBlobsDisabled is returned when no blob store is configured.
+	ErrBlobsDisabled = errors.New("original file storage is not configured")
+	// ErrBlobNotFound is returned for a file whose original bytes weren't
+	// stored, e.g. one ingested before the blob store was enabled.
+	ErrBlobNotFound = errors.New("original file not stored")
+)
+
+// Blob store backends.
+const (
+	BlobLocal = "local"
+	BlobS3    = "s3"
+)
+
+// BlobStore keeps the original bytes of ingested files, keyed by their MD5
+// content hash (the file_md5 recorded on every chunk).
+type BlobStore interface {
+	Put(ctx context.Context, key string, content []byte) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// BlobConfig selects a blob store. S3 addresses the bucket and prefix blobs
+// are written under; its collection fields are unused.
+type BlobConfig struct {
+	Backend string
+	Dir     string
+	S3      BucketSpec
+}
+
+// NewBlobStore builds the configured store, or nil when Backend is empty.
+func NewBlobStore(cfg BlobConfig) (BlobStore, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BlobLocal:
+		if cfg.Dir == "" {
+			return nil, errors.New("local blob store requires blob_dir")
+		}
+		return NewLocalBlobStore(cfg.Dir)
+	case BlobS3:
+		return NewS3BlobStore(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.Backend)
+	}
+}
+
+// validBlobKey reports whether key is a hex content hash, so it can't
+// escape the store's directory or prefix.
+func validBlobKey(key string) bool {
+	if len(key) < 2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// LocalBlobStore keeps blobs in a directory, sharded by the first two
+// characters of the key.
+type LocalBlobStore struct {
+	Dir string
+}
+
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+	return &LocalBlobStore{Dir: dir}, nil
+}
+
+func (l *LocalBlobStore) path(key string) string {
+	return filepath.Join(l.Dir, key[:2], key)
+}
+
+// Put writes content atomically; a key already stored is left alone since
+// its content is the same.
+func (l *LocalBlobStore) Put(_ context.Context, key string, content []byte) error {
+	if !validBlobKey(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	dst := l.path(key)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (l *LocalBlobStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	if !validBlobKey(key) {
+		return nil, ErrBlobNotFound
+	}
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+// S3BlobStore keeps blobs as objects under a prefix of an S3-compatible
+// bucket.
+type S3BlobStore struct {
+	spec   BucketSpec
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3BlobStore(spec BucketSpec) (*S3BlobStore, error) {
+	if err := spec.validateBucket(); err != nil {
+		return nil, err
+	}
+	if spec.Prefix != "" && !strings.HasSuffix(spec.Prefix, "/") {
+		spec.Prefix += "/"
+	}
+	return &S3BlobStore{spec: spec, client: &http.Client{Timeout: 5 * time.Minute}, now: time.Now}, nil
+}
+
+func (s *S3BlobStore) request(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	return s.do(ctx, method, s.spec.bucketURL(s.spec.Prefix+key), body, nil)
+}
+
+// do sends a request for u, signed when the spec has keys. A non-nil body
+// is sent with its SHA-256, as signing requires.
+func (s *S3BlobStore) do(ctx context.Context, method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		req.Header.Set("x-amz-content-sha256", hex.EncodeToString(sum[:]))
+	}
+	if s.spec.AccessKey != "" {
+		signV4(req, s.spec.AccessKey, s.spec.SecretKey, s.spec.SessionToken, s.spec.Region, s.now())
+	}
+	return s.client.Do(req)
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, content []byte) error {
+	if !validBlobKey(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	resp, err := s.request(ctx, http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("put blob: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *S3BlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validBlobKey(key) {
+		return nil, ErrBlobNotFound
+	}
+	resp, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("get blob: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// WithBlobStore keeps the original bytes of every ingested file.
+func (s *IngestService) WithBlobStore(store BlobStore) *IngestService {
+	s.blobs = store
+	return s
+}
+
+// storeBlob saves a file's original bytes. The file is already searchable,
+// so a failure is logged rather than failing the ingest.
+func (s *IngestService) storeBlob(ctx context.Context, md5Hash, filePath string, content []byte) {
+	if s.blobs == nil {
+		return
+	}
+	if err := s.blobs.Put(ctx, md5Hash, content); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("file", filePath).Warn("Failed to store original file")
+	}
+}
+
+// OriginalFile is a stored upload, ready to stream. The caller closes Body.
+type OriginalFile struct {
+	FileMD5  string
+	FileName string
+	MIME     string
+	Body     io.ReadCloser
+}
+
+// OpenOriginal opens the original bytes of the file with the given content
+// hash, provided one of its chunks is visible to the caller.
+func (s *IngestService) OpenOriginal(ctx context.Context, collectionName, md5Hash string) (*OriginalFile, error) {
+	if s.blobs == nil {
+		return nil, ErrBlobsDisabled
+	}
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, md5Hash)
+	}
+	where := andWhere([]chroma.WhereClause{chroma.EqString(s.keys.FileMD5, md5Hash), aclWhere(PrincipalsFromContext(ctx))})
+	res, err := collection.Get(ctx, chroma.WithWhereGet(where), chroma.WithLimitGet(1), chroma.WithIncludeGet(chroma.IncludeMetadatas))
+	if err != nil {
+		return nil, err
+	}
+	records := toRecords(res)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, md5Hash)
+	}
+	rc, err := s.blobs.Open(ctx, md5Hash)
+	if err != nil {
+		return nil, err
+	}
+	out := &OriginalFile{FileMD5: md5Hash}
+	out.FileName, _ = records[0].Metadata[s.keys.FileName].(string)
+	out.Body = rc
+	// A truncated zip can't be told apart by its members, so a known
+	// extension wins over the content's leading bytes
+	if t, ok := fileTypeByExtension(strings.ToLower(filepath.Ext(out.FileName))); ok {
+		out.MIME = t.MIME
+		return out, nil
+	}
+	br := bufio.NewReaderSize(rc, charsetSampleSize)
+	head, _ := br.Peek(charsetSampleSize)
+	out.MIME = sniffType(out.FileName, head)
+	out.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, rc}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "abc123", []byte("original")); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := store.Open(ctx, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "original" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := store.Open(ctx, "ffff"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	if _, err := store.Open(ctx, "../etc/passwd"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected a path key to be rejected, got %v", err)
+	}
+	if err := store.Put(ctx, "../x", nil); err == nil {
+		t.Error("expected a path key to be rejected")
+	}
+}
+
+func TestS3BlobStore(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("x-amz-content-sha256") != hex.EncodeToString(sum[:]) {
+				http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, body)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := NewS3BlobStore(BucketSpec{Endpoint: srv.URL, Bucket: "kb", Prefix: "blobs", AccessKey: "AK", SecretKey: "SK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "abc123", []byte("original")); err != nil {
+		t.Fatal(err)
+	}
+	if objects["/kb/blobs/abc123"] != "original" {
+		t.Fatalf("unexpected objects %v", objects)
+	}
+	rc, err := store.Open(ctx, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "original" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := store.Open(ctx, "ffff"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestOpenOriginal(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{}}
+	id := ChunkID("report.pdf", 0, "text")
+	col.records[id] = Record{ID: id, Document: "text", Metadata: map[string]interface{}{"file_md5": "abc123", "file_name": "docs/report.pdf", "chunk_index": int64(0)}}
+	s := NewIngestService(fileClient{collection: col})
+
+	if _, err := s.OpenOriginal(ctx, "docs", "abc123"); !errors.Is(err, ErrBlobsDisabled) {
+		t.Errorf("expected ErrBlobsDisabled, got %v", err)
+	}
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WithBlobStore(store)
+	if _, err := s.OpenOriginal(ctx, "docs", "abc123"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound before the blob is stored, got %v", err)
+	}
+
+	s.storeBlob(ctx, "abc123", "docs/report.pdf", []byte("%PDF-1.7 original"))
+	file, err := s.OpenOriginal(ctx, "docs", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(file.Body)
+	file.Body.Close()
+	if string(got) != "%PDF-1.7 original" || file.FileName != "docs/report.pdf" || file.MIME != "application/pdf" {
+		t.Errorf("unexpected file %+v with body %q", file, got)
+	}
+
+	col.records = map[string]Record{}
+	if _, err := s.OpenOriginal(ctx, "docs", "abc123"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound without visible chunks, got %v", err)
+	}
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Bucket providers. GCS is read through its S3-compatible XML API with HMAC
+// keys, so both use the same client.
+const (
+	BucketS3  = "s3"
+	BucketGCS = "gcs"
+)
+
+const (
+	gcsEndpoint          = "https://storage.googleapis.com"
+	maxBucketObjects     = 10000
+	maxBucketObjectBytes = 50 << 20
+	// emptyPayloadHash is the SHA-256 of an empty request body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var (
+	// ErrInvalidBucket is returned for an unusable bucket spec.
+	ErrInvalidBucket = errors.New("invalid bucket spec")
+	// ErrBucketFailed is returned when the bucket cannot be listed.
+	ErrBucketFailed = errors.New("bucket request failed")
+)
+
+// BucketSpec selects the objects under a prefix of an S3-compatible or GCS
+// bucket. Without keys the bucket is read anonymously.
+type BucketSpec struct {
+	Provider string `json:"provider,omitempty"` // "s3" (default) or "gcs"
+	// Endpoint overrides the provider's, e.g. a MinIO server.
+	Endpoint     string `json:"endpoint,omitempty"`
+	Region       string `json:"region,omitempty"` // default us-east-1 ("auto" for GCS)
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix,omitempty"`
+	AccessKey    string `json:"access_key,omitempty"`
+	SecretKey    string `json:"secret_key,omitempty"`
+	SessionToken string `json:"session_token,omitempty"`
+	// PathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint; always used with a custom endpoint.
+	PathStyle  bool                   `json:"path_style,omitempty"`
+	Collection string                 `json:"collection"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	ACL        []string               `json:"acl,omitempty"`
+}
+
+// Validate checks the spec and fills in the provider's defaults.
+func (b *BucketSpec) Validate() error {
+	if err := b.validateBucket(); err != nil {
+		return err
+	}
+	if b.Collection == "" {
+		return fmt.Errorf("%w: collection is required", ErrInvalidBucket)
+	}
+	return nil
+}
+
+// validateBucket checks the provider, endpoint, bucket and keys.
+func (b *BucketSpec) validateBucket() error {
+	switch b.Provider {
+	case "", BucketS3:
+		b.Provider = BucketS3
+		if b.Region == "" {
+			b.Region = "us-east-1"
+		}
+	case BucketGCS:
+		if b.Endpoint == "" {
+			b.Endpoint = gcsEndpoint
+		}
+		if b.Region == "" {
+			b.Region = "auto"
+		}
+	default:
+		return fmt.Errorf("%w: unknown provider %q", ErrInvalidBucket, b.Provider)
+	}
+	if b.Endpoint != "" {
+		u, err := url.Parse(b.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: endpoint must be an absolute http(s) URL", ErrInvalidBucket)
+		}
+		b.PathStyle = true
+	}
+	if b.Bucket == "" || strings.ContainsAny(b.Bucket, "/?#") {
+		return fmt.Errorf("%w: bucket name is required", ErrInvalidBucket)
+	}
+	if (b.AccessKey == "") != (b.SecretKey == "") {
+		return fmt.Errorf("%w: access_key and secret_key go together", ErrInvalidBucket)
+	}
+	return nil
+}
+
+// bucketURL addresses key (or the bucket itself when empty). The path is
+// escaped the way SigV4 canonicalizes it, so what is signed is what is sent.
+func (b *BucketSpec) bucketURL(key string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: b.Bucket + ".s3." + b.Region + ".amazonaws.com", Path: "/" + key}
+	if b.PathStyle {
+		u, _ = url.Parse(strings.TrimRight(b.Endpoint, "/"))
+		u.Path += "/" + b.Bucket + "/" + key
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	u.RawPath = strings.Join(segments, "/")
+	return u
+}
+
+// BucketRun reports one ingest of a bucket prefix.
+type BucketRun struct {
+	Bucket    string         `json:"bucket"`
+	Prefix    string         `json:"prefix,omitempty"`
+	Objects   int            `json:"objects"`
+	Unchanged int            `json:"unchanged"`
+	Results   []IngestResult `json:"results"`
+	Deleted   []string       `json:"deleted,omitempty"`
+	Errors    []string       `json:"errors,omitempty"`
+	Duration  string         `json:"duration"`
+	Timings   IngestTimings  `json:"timings"`
+}
+
+// BucketService ingests bucket objects, re-ingesting only objects whose ETag
+// changed since the last run; the ETag is recorded on every chunk.
+type BucketService struct {
+	ingest       *IngestService
+	client       *http.Client
+	allowPrivate bool
+	now          func() time.Time
+
+	// ingestFile, deleteFile and ingestedETags touch the collection,
+	// replaceable in tests.
+	ingestFile    func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error)
+	deleteFile    func(ctx context.Context, collection, name, sourceID string) error
+	ingestedETags func(ctx context.Context, collection, sourceID string) (map[string]string, error)
+}
+
+func NewBucketService(ingest *IngestService) *BucketService {
+	return &BucketService{
+		ingest:        ingest,
+		client:        newFetchClient(5*time.Minute, isPublicIP),
+		now:           time.Now,
+		ingestFile:    ingest.IngestFileWithOptions,
+		deleteFile:    ingest.deleteFileChunks,
+		ingestedETags: ingest.ingestedETags,
+	}
+}
+
+// WithPrivateNetworks lets buckets be read from loopback, private and
+// link-local endpoints, e.g. a MinIO server on the same host. By default
+// they are refused.
+func (s *BucketService) WithPrivateNetworks(allow bool) *BucketService {
+	s.allowPrivate = allow
+	s.client = newFetchClient(5*time.Minute, fetchGuard(allow))
+	return s
+}
+
+type bucketObject struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int64  `xml:"Size"`
+}
+
+// Run lists the objects under the prefix and ingests new and changed ones,
+// named by key and tagged with bucket, key and etag metadata. Objects that
+// disappeared since the last run are deleted from the collection.
+func (s *BucketService) Run(ctx context.Context, spec BucketSpec) (*BucketRun, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if !s.allowPrivate {
+		if err := checkPublicHost(spec.bucketURL("")); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBucket, err)
+		}
+	}
+	started := time.Now()
+	objects, complete, err := s.list(ctx, &spec)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{spec.Collection, spec.Endpoint, spec.Bucket, spec.Prefix}, "\x00")))
+	source := IngestSource{ID: "bucket-" + hex.EncodeToString(sum[:8]), Kind: SourceBucket, Ref: spec.Provider + "://" + spec.Bucket + "/" + spec.Prefix}
+	previous, err := s.ingestedETags(ctx, spec.Collection, source.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &BucketRun{Bucket: spec.Bucket, Prefix: spec.Prefix, Objects: len(objects), Results: []IngestResult{}}
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{"bucket": spec.Bucket, "state": "running"}})
+	listed := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		listed[obj.Key] = true
+		etag, seen := previous[obj.Key]
+		if seen && etag == obj.ETag {
+			run.Unchanged++
+			continue
+		}
+		if ctx.Err() != nil {
+			run.Errors = append(run.Errors, ctx.Err().Error())
+			break
+		}
+		s.ingestObject(ctx, run, &spec, source, obj, seen)
+	}
+	for key := range previous {
+		// Keys past the listing cap are not known to be gone
+		if listed[key] || !complete || ctx.Err() != nil {
+			continue
+		}
+		if err := s.deleteFile(ctx, spec.Collection, key, source.ID); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		run.Deleted = append(run.Deleted, key)
+	}
+	sort.Strings(run.Deleted)
+
+	run.Duration = time.Since(started).Round(time.Millisecond).String()
+	run.Timings = sumTimings(run.Results)
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{
+		"bucket": spec.Bucket,
+		"state":  "finished",
+		"files":  len(run.Results),
+		"errors": len(run.Errors),
+	}})
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"bucket":    spec.Bucket,
+		"prefix":    spec.Prefix,
+		"files":     len(run.Results),
+		"unchanged": run.Unchanged,
+		"deleted":   len(run.Deleted),
+		"errors":    len(run.Errors),
+	}).Info("Bucket ingest complete")
+	return run, nil
+}
+
+// ingestObject fetches an object and ingests it. With replace set the new
+// version replaces the chunks of the previous one once it is stored, so a
+// failed fetch or ingest leaves the previous version searchable.
+func (s *BucketService) ingestObject(ctx context.Context, run *BucketRun, spec *BucketSpec, source IngestSource, obj bucketObject, replace bool) {
+	fail := func(err error) {
+		run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", obj.Key, err))
+		run.Results = append(run.Results, IngestResult{Status: "error", File: obj.Key})
+	}
+	if obj.Size > maxBucketObjectBytes {
+		fail(fmt.Errorf("object is larger than %d bytes", maxBucketObjectBytes))
+		return
+	}
+	resp, err := s.do(ctx, spec, spec.bucketURL(obj.Key))
+	if err != nil {
+		fail(err)
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBucketObjectBytes))
+	resp.Body.Close()
+	if err != nil {
+		fail(err)
+		return
+	}
+	metadata := make(map[string]interface{}, len(spec.Metadata)+3)
+	for k, v := range spec.Metadata {
+		metadata[k] = v
+	}
+	metadata["bucket"], metadata["key"], metadata["etag"] = spec.Bucket, obj.Key, obj.ETag
+	res, err := s.ingestFile(ctx, spec.Collection, obj.Key, content, IngestOptions{Metadata: metadata, ACL: spec.ACL, Source: source, Replace: replace})
+	if err != nil {
+		fail(err)
+		return
+	}
+	run.Results = append(run.Results, *res)
+}
+
+// list pages through ListObjectsV2, skipping folder placeholders. complete
+// is false when the listing stopped at maxBucketObjects.
+func (s *BucketService) list(ctx context.Context, spec *BucketSpec) (_ []bucketObject, complete bool, _ error) {
+	var out []bucketObject
+	token := ""
+	for {
+		u := spec.bucketURL("")
+		q := url.Values{"list-type": {"2"}}
+		if spec.Prefix != "" {
+			q.Set("prefix", spec.Prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		resp, err := s.do(ctx, spec, u)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: list %s: %w", ErrBucketFailed, spec.Bucket, err)
+		}
+		var page struct {
+			Contents              []bucketObject `xml:"Contents"`
+			IsTruncated           bool           `xml:"IsTruncated"`
+			NextContinuationToken string         `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: list %s: %w", ErrBucketFailed, spec.Bucket, err)
+		}
+		for _, obj := range page.Contents {
+			if strings.HasSuffix(obj.Key, "/") {
+				continue
+			}
+			obj.ETag = strings.Trim(obj.ETag, `"`)
+			out = append(out, obj)
+			if len(out) >= maxBucketObjects {
+				return out, false, nil
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return out, true, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// do sends a signed GET and returns the response when it succeeded.
+func (s *BucketService) do(ctx context.Context, spec *BucketSpec, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if spec.AccessKey != "" {
+		signV4(req, spec.AccessKey, spec.SecretKey, spec.SessionToken, spec.Region, s.now())
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// signV4 signs an S3 request with AWS Signature Version 4. A request with a
+// body must carry its SHA-256 in x-amz-content-sha256; without one the
+// request is signed as body-less.
+func signV4(req *http.Request, accessKey, secretKey, sessionToken, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	payloadHash := req.Header.Get("x-amz-content-sha256")
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+		req.Header.Set("x-amz-content-sha256", payloadHash)
+	}
+	if sessionToken != "" {
+		req.Header.Set("x-amz-security-token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// canonicalQuery sorts and URI-encodes query parameters as SigV4 requires.
+func canonicalQuery(q url.Values) string {
+	pairs := make([]string, 0, len(q))
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// ingestedETags maps the files sourceID ingested into collection to the
+// ETag recorded on their chunks. A collection that cannot be opened (it may
+// not exist yet) has none.
+func (s *IngestService) ingestedETags(ctx context.Context, collectionName, sourceID string) (map[string]string, error) {
+	out := map[string]string{}
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return out, nil
+	}
+	records, err := scanRecords(ctx, collection, chroma.EqString(s.keys.SourceID, sourceID), chroma.IncludeMetadatas)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		name, _ := r.Metadata[s.keys.FileName].(string)
+		etag, _ := r.Metadata[userMetadataPrefix+"etag"].(string)
+		if name != "" {
+			out[name] = etag
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Bulk operations on the collections a selector matches.
+const (
+	BulkDelete  = "delete"
+	BulkExport  = "export"
+	BulkReembed = "reembed"
+)
+
+// tagsSettingKey stores a collection's tags in the settings table.
+const tagsSettingKey = "tags"
+
+var (
+	// ErrInvalidBulkRequest is returned for an unknown operation or a
+	// selector that matches nothing by construction.
+	ErrInvalidBulkRequest = errors.New("invalid bulk request")
+	// ErrBulkConfirm is returned when the confirmation token doesn't match
+	// the planned operation, e.g. because the matching collections changed.
+	ErrBulkConfirm = errors.New("confirmation token does not match; review the plan and retry")
+)
+
+// BulkRequest selects collections by a glob on their names (path.Match
+// syntax), a tag, or both, and names the operation to run on each.
+type BulkRequest struct {
+	Op      string `json:"op"`
+	Pattern string `json:"pattern,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	// Force is passed on to deletes of protected collections.
+	Force bool `json:"force,omitempty"`
+	// Snapshot names the snapshots an export writes (default bulk-<time>).
+	Snapshot string `json:"snapshot,omitempty"`
+	// Confirm is the token from the plan; without it nothing runs.
+	Confirm string `json:"confirm,omitempty"`
+}
+
+// BulkPlan lists what a bulk request would touch, and the token that
+// confirms it.
+type BulkPlan struct {
+	Op          string   `json:"op"`
+	Collections []string `json:"collections"`
+	Confirm     string   `json:"confirm"`
+}
+
+// BulkResult is the outcome for one collection.
+type BulkResult struct {
+	Collection string        `json:"collection"`
+	Records    int           `json:"records,omitempty"`
+	Snapshot   *SnapshotInfo `json:"snapshot,omitempty"`
+	Trash      *TrashEntry   `json:"trash,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// BulkService runs one operation over many collections for administrators.
+type BulkService struct {
+	ingest   *IngestService
+	archives *ArchiveService
+	now      func() time.Time
+}
+
+func NewBulkService(ingest *IngestService, archives *ArchiveService) *BulkService {
+	return &BulkService{ingest: ingest, archives: archives, now: time.Now}
+}
+
+// Plan returns the collections req matches without changing anything.
+func (b *BulkService) Plan(ctx context.Context, req BulkRequest) (*BulkPlan, error) {
+	if !b.ingest.IsAdmin(ctx) {
+		return nil, ErrAdminRequired
+	}
+	switch req.Op {
+	case BulkDelete, BulkReembed:
+	case BulkExport:
+		if b.archives == nil {
+			return nil, fmt.Errorf("%w: archival is not configured", ErrInvalidBulkRequest)
+		}
+		if req.Snapshot != "" {
+			if err := validSnapshot(req.Snapshot); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: op must be %s, %s or %s", ErrInvalidBulkRequest, BulkDelete, BulkExport, BulkReembed)
+	}
+	if req.Pattern == "" && req.Tag == "" {
+		return nil, fmt.Errorf("%w: pattern or tag is required", ErrInvalidBulkRequest)
+	}
+	if _, err := path.Match(req.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: pattern: %v", ErrInvalidBulkRequest, err)
+	}
+
+	names, err := b.ingest.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &BulkPlan{Op: req.Op, Collections: []string{}}
+	for _, name := range names {
+		if req.Pattern != "" {
+			if ok, _ := path.Match(req.Pattern, name); !ok {
+				continue
+			}
+		}
+		if req.Tag != "" {
+			tags, err := b.ingest.CollectionTags(name)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(tags, normalizeTag(req.Tag)) {
+				continue
+			}
+		}
+		plan.Collections = append(plan.Collections, name)
+	}
+	slices.Sort(plan.Collections)
+	plan.Confirm = bulkToken(req, plan.Collections)
+	return plan, nil
+}
+
+// bulkToken binds a confirmation to the operation and the exact set of
+// collections it was planned for.
+func bulkToken(req BulkRequest, collections []string) string {
+	h := sha256.New()
+	for _, part := range append([]string{req.Op, strconv.FormatBool(req.Force), req.Snapshot}, collections...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:12])
+}
+
+// Run plans req and, when req.Confirm matches the plan, applies the
+// operation to each collection in turn. A failure on one collection is
+// reported in its result and doesn't stop the others. Deletes move
+// collections to the trash when a grace period is configured.
+func (b *BulkService) Run(ctx context.Context, req BulkRequest) (*BulkPlan, []BulkResult, error) {
+	plan, err := b.Plan(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Confirm != plan.Confirm {
+		return plan, nil, ErrBulkConfirm
+	}
+	snapshot := req.Snapshot
+	if req.Op == BulkExport && snapshot == "" {
+		snapshot = "bulk-" + b.now().UTC().Format("20060102-150405")
+	}
+
+	results := make([]BulkResult, 0, len(plan.Collections))
+	for _, name := range plan.Collections {
+		result := BulkResult{Collection: name}
+		switch req.Op {
+		case BulkDelete:
+			result.Trash, err = b.ingest.TrashCollection(ctx, name, req.Force)
+		case BulkExport:
+			result.Snapshot, err = b.archives.Snapshot(ctx, name, snapshot)
+			if result.Snapshot != nil {
+				result.Records = result.Snapshot.Records
+			}
+		case BulkReembed:
+			result.Records, err = b.ingest.ReembedCollection(ctx, name)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"op":          req.Op,
+		"collections": len(results),
+	}).Info("Ran bulk collection operation")
+	return plan, results, nil
+}
+
+// ReembedCollection recomputes every chunk's embedding with the
+// collection's embedding function, e.g. after the embedding model changed.
+// Documents, metadata and IDs are kept.
+func (s *IngestService) ReembedCollection(ctx context.Context, name string) (int, error) {
+	if IsSnapshotCollection(name) {
+		return 0, ErrSnapshotReadOnly
+	}
+	if err := s.checkNotTrashed(name); err != nil {
+		return 0, err
+	}
+	collection, err := s.chromaDB.GetCollection(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get collection '%s': %w", name, err)
+	}
+	records, err := scanRecords(ctx, collection, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	ids := make([]chroma.DocumentID, len(records))
+	texts := make([]string, len(records))
+	metadatas := make([]chroma.DocumentMetadata, len(records))
+	for i, rec := range records {
+		ids[i] = chroma.DocumentID(rec.ID)
+		texts[i] = rec.Document
+		metadatas[i] = toDocumentMetadata(rec.Metadata)
+	}
+	if err := s.upsertChunks(ctx, collection, ids, texts, metadatas, nil); err != nil {
+		return 0, dimensionError(name, err)
+	}
+	s.publishChange(EventIngested, name, map[string]interface{}{"reembedded": len(records)})
+	return len(records), nil
+}
+
+// CollectionTags returns a collection's tags, sorted.
+func (s *IngestService) CollectionTags(collection string) ([]string, error) {
+	if s.settings == nil {
+		return nil, nil
+	}
+	var tags []string
+	if _, err := s.settings.GetCollectionSetting(collection, tagsSettingKey, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetCollectionTags replaces a collection's tags. Tags are trimmed and
+// lowercased; empty and repeated tags are dropped.
+func (s *IngestService) SetCollectionTags(collection string, tags []string) ([]string, error) {
+	if s.settings == nil {
+		return nil, errNoSettingsStore
+	}
+	out := []string{}
+	for _, tag := range tags {
+		if tag = normalizeTag(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if err := s.settings.SetCollectionSetting(collection, tagsSettingKey, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
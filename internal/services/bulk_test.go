@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func (c *memCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	return c.Add(ctx, opts...)
+}
+
+func TestBulkCollections(t *testing.T) {
+	admin := WithAdmin(context.Background())
+	client := &memClient{collections: map[string]*memCollection{}}
+	for _, name := range []string{"proj-a", "proj-b", "proj-c", "other"} {
+		client.collections[name] = &memCollection{name: name, docs: map[string]string{"1": "text of " + name}}
+	}
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingest := NewIngestService(client).WithSettings(memSettings{}).WithTrashGrace(time.Hour)
+	bulk := NewBulkService(ingest, NewArchiveService(client, store))
+
+	if _, err := bulk.Plan(context.Background(), BulkRequest{Op: BulkDelete, Pattern: "*"}); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("expected ErrAdminRequired, got %v", err)
+	}
+	for _, req := range []BulkRequest{{Op: "drop", Pattern: "*"}, {Op: BulkDelete}, {Op: BulkDelete, Pattern: "["}} {
+		if _, err := bulk.Plan(admin, req); !errors.Is(err, ErrInvalidBulkRequest) {
+			t.Errorf("expected %+v to be rejected, got %v", req, err)
+		}
+	}
+
+	// Pattern and tag narrow each other
+	ingest.SetCollectionTags("proj-a", []string{" Archive ", "archive"})
+	ingest.SetCollectionTags("other", []string{"archive"})
+	if tags, _ := ingest.CollectionTags("proj-a"); !reflect.DeepEqual(tags, []string{"archive"}) {
+		t.Errorf("expected normalized tags, got %v", tags)
+	}
+	plan, err := bulk.Plan(admin, BulkRequest{Op: BulkDelete, Pattern: "proj-*"})
+	if err != nil || !reflect.DeepEqual(plan.Collections, []string{"proj-a", "proj-b", "proj-c"}) {
+		t.Fatalf("Plan() = %+v, %v", plan, err)
+	}
+	if plan, _ := bulk.Plan(admin, BulkRequest{Op: BulkDelete, Pattern: "proj-*", Tag: "ARCHIVE"}); !reflect.DeepEqual(plan.Collections, []string{"proj-a"}) {
+		t.Errorf("expected only proj-a, got %v", plan.Collections)
+	}
+
+	// Nothing runs without the plan's token, and the token is bound to the
+	// matching collections
+	req := BulkRequest{Op: BulkDelete, Pattern: "proj-*", Confirm: "guess"}
+	if _, _, err := bulk.Run(admin, req); !errors.Is(err, ErrBulkConfirm) {
+		t.Errorf("expected ErrBulkConfirm, got %v", err)
+	}
+	client.collections["proj-d"] = &memCollection{name: "proj-d", docs: map[string]string{}}
+	req.Confirm = plan.Confirm
+	if _, _, err := bulk.Run(admin, req); !errors.Is(err, ErrBulkConfirm) {
+		t.Errorf("expected a stale token to be rejected, got %v", err)
+	}
+	delete(client.collections, "proj-d")
+
+	// Export snapshots each collection; re-embed rewrites its chunks
+	export := BulkRequest{Op: BulkExport, Tag: "archive", Snapshot: "s1"}
+	plan, _ = bulk.Plan(admin, export)
+	export.Confirm = plan.Confirm
+	if _, results, err := bulk.Run(admin, export); err != nil || len(results) != 2 || results[0].Snapshot == nil || results[0].Records != 1 {
+		t.Fatalf("Run(export) = %+v, %v", results, err)
+	}
+	reembed := BulkRequest{Op: BulkReembed, Pattern: "other"}
+	plan, _ = bulk.Plan(admin, reembed)
+	reembed.Confirm = plan.Confirm
+	if _, results, err := bulk.Run(admin, reembed); err != nil || len(results) != 1 || results[0].Records != 1 {
+		t.Fatalf("Run(reembed) = %+v, %v", results, err)
+	}
+
+	_, results, err := bulk.Run(admin, req)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("Run(delete) = %+v, %v", results, err)
+	}
+	for _, r := range results {
+		if r.Trash == nil || r.Error != "" {
+			t.Errorf("expected %s to be trashed, got %+v", r.Collection, r)
+		}
+	}
+	if names, _ := ingest.ListCollections(admin); !reflect.DeepEqual(names, []string{"other"}) {
+		t.Errorf("expected only other to be listed, got %v", names)
+	}
+}
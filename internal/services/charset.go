@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ErrBinaryContent is returned for a file that is neither a supported
+// document format nor text in a recognizable encoding.
+var ErrBinaryContent = fmt.Errorf("%w: file content is binary, not text", ErrUnsupportedType)
+
+// charsetKey records the encoding a text file was decoded from.
+const charsetKey = "charset"
+
+// charsetSampleSize bounds how much of a file the heuristics look at.
+const charsetSampleSize = 8 << 10
+
+// binaryFormats are the extensions read by format-specific extractors
+// rather than as text.
+var binaryFormats = map[string]bool{".pdf": true, ".docx": true, ".pptx": true, ".xlsx": true, ".epub": true}
+
+// isTextFormat reports whether a file is read as text, and so decoded.
+func isTextFormat(filePath string) bool {
+	ext := strings.ToLower(path.Ext(filePath))
+	return !binaryFormats[ext] && !imageExtensions[ext] && !audioExtensions[ext]
+}
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// decodeText detects a text file's encoding and returns its content as
+// UTF-8 with the charset name. UTF-16 is recognized by its byte order mark
+// or by the zero bytes of mostly-ASCII text; invalid UTF-8 with no stray
+// zero bytes is read as Windows-1252 when it uses that code page's 0x80-0x9f
+// punctuation, and as ISO-8859-1 otherwise.
+func decodeText(content []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		return bytes.ToValidUTF8(content[len(utf8BOM):], []byte("\ufffd")), "utf-8", nil
+	case bytes.HasPrefix(content, utf16LEBOM):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), content, "utf-16le")
+	case bytes.HasPrefix(content, utf16BEBOM):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), content, "utf-16be")
+	}
+	sample := content
+	if len(sample) > charsetSampleSize {
+		sample = sample[:charsetSampleSize]
+	}
+	if order, ok := utf16Order(sample); ok {
+		if order == "utf-16le" {
+			return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), content, order)
+		}
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), content, order)
+	}
+	if looksBinary(sample) {
+		return nil, "", ErrBinaryContent
+	}
+	if utf8.Valid(content) {
+		return content, "utf-8", nil
+	}
+	if mostlyUTF8(content) {
+		return bytes.ToValidUTF8(content, []byte("\ufffd")), "utf-8", nil
+	}
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9f {
+			return decodeWith(charmap.Windows1252, content, "windows-1252")
+		}
+	}
+	return decodeWith(charmap.ISO8859_1, content, "iso-8859-1")
+}
+
+func decodeWith(enc encoding.Encoding, content []byte, name string) ([]byte, string, error) {
+	out, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", name, err)
+	}
+	return out, name, nil
+}
+
+// utf16Order spots UTF-16 without a byte order mark: in mostly-ASCII text
+// every other byte is zero.
+func utf16Order(sample []byte) (string, bool) {
+	if len(sample) < 4 {
+		return "", false
+	}
+	var even, odd int
+	for i, b := range sample[:len(sample)&^1] {
+		if b == 0 {
+			if i%2 == 0 {
+				even++
+			} else {
+				odd++
+			}
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case odd*10 > half*4 && even*10 < half:
+		return "utf-16le", true
+	case even*10 > half*4 && odd*10 < half:
+		return "utf-16be", true
+	}
+	return "", false
+}
+
+// looksBinary reports zero bytes or a high share of control characters
+// other than whitespace, form feed and escape.
+func looksBinary(sample []byte) bool {
+	control := 0
+	for _, b := range sample {
+		switch {
+		case b == 0:
+			return true
+		case b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\v' && b != 0x1b:
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
+
+// mostlyUTF8 reports UTF-8 with a few corrupt bytes: valid multi-byte
+// sequences outnumber the invalid bytes. Single-byte text almost never
+// forms valid sequences by chance.
+func mostlyUTF8(content []byte) bool {
+	multi, invalid := 0, 0
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRune(content[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			multi++
+		}
+		i += size
+	}
+	return multi > invalid
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      []byte
+		text    string
+		charset string
+	}{
+		{"ascii", []byte("plain text\n"), "plain text\n", "utf-8"},
+		{"utf-8 bom", []byte("\xef\xbb\xbfcafé"), "café", "utf-8"},
+		{"latin-1", []byte("caf\xe9 cr\xe8me"), "café crème", "iso-8859-1"},
+		{"windows-1252", []byte("\x93quoted\x94 \x80 5"), "“quoted” € 5", "windows-1252"},
+		{"utf-16le bom", []byte("\xff\xfeh\x00\xe9\x00"), "hé", "utf-16le"},
+		{"utf-16be", []byte("\x00h\x00e\x00l\x00l\x00o"), "hello", "utf-16be"},
+		{"mostly utf-8", []byte("naïve café \xff résumé"), "naïve café � résumé", "utf-8"},
+	}
+	for _, tc := range cases {
+		out, charset, err := decodeText(tc.in)
+		if err != nil || string(out) != tc.text || charset != tc.charset {
+			t.Errorf("%s: got %q %s %v, want %q %s", tc.name, out, charset, err, tc.text, tc.charset)
+		}
+	}
+	for _, bin := range [][]byte{{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0}, {1, 2, 3, 4, 5, 'a', 6, 7}} {
+		if _, _, err := decodeText(bin); !errors.Is(err, ErrBinaryContent) {
+			t.Errorf("expected %q rejected as binary, got %v", bin, err)
+		}
+	}
+	if isTextFormat("report.pdf") || isTextFormat("scan.PNG") || !isTextFormat("notes.txt") {
+		t.Error("unexpected text format classification")
+	}
+}
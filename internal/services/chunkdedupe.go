@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// contentHashBatch bounds how many hashes one duplicate lookup asks for.
+const contentHashBatch = 100
+
+// ContentHash is the hash recorded on every chunk under the content_hash
+// key: hex(sha256(text))[:32].
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("%x", sum[:16])
+}
+
+// WithChunkDedupe makes ingests skip chunks whose text the collection
+// already holds, or that repeat an earlier chunk of the same file, on top
+// of the whole-file check by content hash.
+func (s *IngestService) WithChunkDedupe(enabled bool) *IngestService {
+	s.chunkDedupe = enabled
+	return s
+}
+
+// dropDuplicateChunks removes chunks from p whose text is already stored
+// in the collection, visible to at least everyone the new chunk would be,
+// or appears earlier in p, relinks the remaining chunks' neighbours and
+// returns how many were dropped. Chunks with a precomputed embedding are
+// kept, since their text doesn't identify them, and stored chunks in
+// replaced, which are about to be deleted, don't count.
+func (s *IngestService) dropDuplicateChunks(ctx context.Context, collection chroma.Collection, p *preparedChunks, replaced []string) (int, error) {
+	ignore := make(map[string]bool, len(replaced))
+	for _, id := range replaced {
+		ignore[id] = true
+	}
+	hashes := make([]string, len(p.ids))
+	for i, md := range p.metadatas {
+		hashes[i], _ = md[s.keys.ContentHash].(string)
+	}
+	stored := map[string][]map[string]interface{}{}
+	for from := 0; from < len(hashes); from += contentHashBatch {
+		batch := hashes[from:min(from+contentHashBatch, len(hashes))]
+		records, err := scanRecords(ctx, collection, chroma.InString(s.keys.ContentHash, batch...), chroma.IncludeMetadatas)
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range records {
+			if ignore[r.ID] {
+				continue
+			}
+			hash, _ := r.Metadata[s.keys.ContentHash].(string)
+			stored[hash] = append(stored[hash], r.Metadata)
+		}
+	}
+
+	kept := &preparedChunks{title: p.title}
+	for i, md := range p.metadatas {
+		duplicate := false
+		if p.embeddings == nil {
+			for _, other := range stored[hashes[i]] {
+				if aclCovers(other, md) {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if duplicate {
+			continue
+		}
+		stored[hashes[i]] = append(stored[hashes[i]], md)
+		kept.ids = append(kept.ids, p.ids[i])
+		kept.chunks = append(kept.chunks, p.chunks[i])
+		kept.metadatas = append(kept.metadatas, md)
+		if p.embeddings != nil {
+			kept.embeddings = append(kept.embeddings, p.embeddings[i])
+		}
+	}
+	for i, md := range kept.metadatas {
+		delete(md, prevChunkKey)
+		delete(md, nextChunkKey)
+		if i > 0 {
+			md[prevChunkKey] = kept.ids[i-1]
+		}
+		if i < len(kept.ids)-1 {
+			md[nextChunkKey] = kept.ids[i+1]
+		}
+	}
+	dropped := len(p.ids) - len(kept.ids)
+	*p = *kept
+	return dropped, nil
+}
+
+// aclCovers reports whether a chunk with metadata existing is visible to
+// everyone a chunk with metadata md would be.
+func aclCovers(existing, md map[string]interface{}) bool {
+	if restricted, _ := existing[aclRestrictedKey].(bool); !restricted {
+		return true
+	}
+	if restricted, _ := md[aclRestrictedKey].(bool); !restricted {
+		return false
+	}
+	for key := range md {
+		if strings.HasPrefix(key, aclPrincipalKey) {
+			if allowed, _ := existing[key].(bool); !allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
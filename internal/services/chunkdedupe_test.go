@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDropDuplicateChunks(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{}}
+	s := NewIngestService(fileClient{collection: col}).WithSettings(memSettings{}).WithDefaultTokenizer("whitespace").
+		WithDefaultChunking(Chunking{Strategy: ChunkLines}).WithChunkDedupe(true)
+	opts := IngestOptions{MaxTokens: 3}
+	old, err := s.prepareChunks(ctx, "docs", "v1.txt", []byte("a b c\nd e f\ng h i"), "v1", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range old.ids {
+		col.records[id] = Record{ID: id, Document: old.chunks[i], Metadata: old.metadatas[i]}
+	}
+
+	// Lines the first version has and a repeated line are left out
+	p, err := s.prepareChunks(ctx, "docs", "v2.txt", []byte("a b c\nd e X\nd e X\ng h i"), "v2", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := s.dropDuplicateChunks(ctx, col, p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 3 || len(p.chunks) != 1 || p.chunks[0] != "d e X\n" {
+		t.Fatalf("expected only the edited line to be kept, dropped %d, kept %q", dropped, p.chunks)
+	}
+	if _, ok := p.metadatas[0][prevChunkKey]; ok {
+		t.Error("expected links to dropped chunks to be removed")
+	}
+
+	// A restricted copy doesn't stand in for a chunk more people may see
+	restricted, err := s.prepareChunks(ctx, "docs", "v3.txt", []byte("p q r\n"), "v3", IngestOptions{MaxTokens: 3, ACL: []string{"team-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	col.records[restricted.ids[0]] = Record{ID: restricted.ids[0], Document: restricted.chunks[0], Metadata: restricted.metadatas[0]}
+	p, err = s.prepareChunks(ctx, "docs", "v4.txt", []byte("p q r\n"), "v4", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped, err := s.dropDuplicateChunks(ctx, col, p, nil); err != nil || dropped != 0 {
+		t.Errorf("expected a public chunk to be kept despite a restricted copy, dropped %d (%v)", dropped, err)
+	}
+	p, err = s.prepareChunks(ctx, "docs", "v5.txt", []byte("p q r\n"), "v5", IngestOptions{MaxTokens: 3, ACL: []string{"team-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped, err := s.dropDuplicateChunks(ctx, col, p, nil); err != nil || dropped != 1 {
+		t.Errorf("expected a chunk with the same ACL to be dropped, dropped %d (%v)", dropped, err)
+	}
+}
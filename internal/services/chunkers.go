@@ -0,0 +1,250 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ChunkerStrategy splits text without structure of its own into chunks.
+// Strategies are registered by name and selected by Chunking.Strategy,
+// whether it comes from a collection's profile or the service default.
+type ChunkerStrategy interface {
+	// Chunk splits text into chunks of at most about maxTokens tokens,
+	// using c's options (e.g. separators) where the strategy has any.
+	Chunk(text string, maxTokens int, tokenizer Tokenizer, c Chunking) []string
+}
+
+// ChunkerFunc adapts a function to ChunkerStrategy.
+type ChunkerFunc func(text string, maxTokens int, tokenizer Tokenizer, c Chunking) []string
+
+func (f ChunkerFunc) Chunk(text string, maxTokens int, tokenizer Tokenizer, c Chunking) []string {
+	return f(text, maxTokens, tokenizer, c)
+}
+
+var (
+	chunkerMu  sync.RWMutex
+	chunkerReg = map[string]ChunkerStrategy{}
+)
+
+// RegisterChunker adds a chunking strategy to the registry, replacing any
+// of the same name.
+func RegisterChunker(name string, strategy ChunkerStrategy) {
+	chunkerMu.Lock()
+	defer chunkerMu.Unlock()
+	chunkerReg[name] = strategy
+}
+
+// GetChunker returns a registered chunking strategy by name.
+func GetChunker(name string) (ChunkerStrategy, error) {
+	chunkerMu.RLock()
+	defer chunkerMu.RUnlock()
+	strategy, ok := chunkerReg[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown strategy %q (available: %s)", ErrInvalidChunking, name, strings.Join(chunkerNames(), ", "))
+	}
+	return strategy, nil
+}
+
+// Chunkers lists the registered chunking strategy names.
+func Chunkers() []string {
+	chunkerMu.RLock()
+	defer chunkerMu.RUnlock()
+	return chunkerNames()
+}
+
+func chunkerNames() []string {
+	out := make([]string, 0, len(chunkerReg))
+	for name := range chunkerReg {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func init() {
+	RegisterChunker(ChunkRecursive, ChunkerFunc(func(text string, maxTokens int, tokenizer Tokenizer, c Chunking) []string {
+		separators := c.Separators
+		if len(separators) == 0 {
+			separators = DefaultChunkSeparators
+		}
+		return splitRecursive(text, maxTokens, tokenizer, separators)
+	}))
+	RegisterChunker(ChunkLines, ChunkerFunc(func(text string, maxTokens int, tokenizer Tokenizer, _ Chunking) []string {
+		return chunkText(text, maxTokens, tokenizer)
+	}))
+	RegisterChunker(ChunkSentences, ChunkerFunc(func(text string, maxTokens int, tokenizer Tokenizer, _ Chunking) []string {
+		return packPieces(sentenceUnits(text), maxTokens, tokenizer, func(piece string) []string {
+			return splitRecursive(piece, maxTokens, tokenizer, sentenceFallbackSeparators)
+		})
+	}))
+	RegisterChunker(ChunkMarkdown, ChunkerFunc(func(text string, maxTokens int, tokenizer Tokenizer, _ Chunking) []string {
+		return chunkUnits(markdownUnits(text), maxTokens, tokenizer)
+	}))
+	RegisterChunker(ChunkCode, ChunkerFunc(func(text string, maxTokens int, tokenizer Tokenizer, _ Chunking) []string {
+		return chunkUnits(codeUnits(text), maxTokens, tokenizer)
+	}))
+	RegisterChunker(ChunkSemantic, ChunkerFunc(splitSemantic))
+}
+
+// sentenceFallbackSeparators split a sentence longer than a chunk.
+var sentenceFallbackSeparators = []string{"; ", ", ", " "}
+
+// sentenceUnits splits text after each sentence end (., ?, ! or a CJK full
+// stop, with any closing quotes or brackets, followed by whitespace) and
+// at blank lines. The units concatenate back to text.
+func sentenceUnits(text string) []string {
+	var units []string
+	start := 0
+	runes := []rune(text)
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + len(string(r))
+	}
+	for i := 0; i < len(runes); i++ {
+		end := -1
+		switch r := runes[i]; {
+		case r == '.' || r == '?' || r == '!':
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune(`"')]”’`, runes[j]) {
+				j++
+			}
+			if j < len(runes) && unicode.IsSpace(runes[j]) {
+				end = j
+			}
+		case r == '。' || r == '？' || r == '！':
+			end = i + 1
+		case r == '\n' && i+1 < len(runes) && runes[i+1] == '\n':
+			end = i + 1
+		}
+		if end < 0 {
+			continue
+		}
+		// Keep the whitespace after a sentence with it
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		units = append(units, text[offsets[start]:offsets[end]])
+		start, i = end, end-1
+	}
+	if offsets[start] < len(text) {
+		units = append(units, text[offsets[start]:])
+	}
+	return units
+}
+
+// packPieces packs consecutive pieces into chunks of at most maxTokens.
+// A piece larger than that is passed to oversize, and blank pieces stay
+// with the chunk before them. The chunks concatenate back to the pieces.
+func packPieces(pieces []string, maxTokens int, tokenizer Tokenizer, oversize func(string) []string) []string {
+	var chunks []string
+	var current strings.Builder
+	tokens := 0
+	flush := func() {
+		chunk := current.String()
+		current.Reset()
+		tokens = 0
+		switch {
+		case chunk == "":
+		case strings.TrimSpace(chunk) == "" && len(chunks) > 0:
+			// Don't embed runs of blank lines on their own
+			chunks[len(chunks)-1] += chunk
+		default:
+			chunks = append(chunks, chunk)
+		}
+	}
+	for _, piece := range pieces {
+		n := tokenizer.Count(piece)
+		if n > maxTokens {
+			if strings.TrimSpace(current.String()) == "" {
+				piece = current.String() + piece
+				current.Reset()
+				tokens = 0
+			} else {
+				flush()
+			}
+			chunks = append(chunks, oversize(piece)...)
+			continue
+		}
+		if tokens+n > maxTokens {
+			flush()
+		}
+		current.WriteString(piece)
+		tokens += n
+	}
+	flush()
+	return chunks
+}
+
+// semanticWindow is how many sentences on each side of a boundary are
+// compared.
+const semanticWindow = 3
+
+// splitSemantic starts new chunks where the topic shifts: between
+// sentences whose neighbourhoods share fewer terms than at the boundaries
+// around them and unusually few overall (more than half a standard
+// deviation below the mean similarity), besides wherever a chunk would
+// exceed maxTokens. Similarity is lexical, the Jaccard overlap of
+// analyzed terms, so no embedding calls are made while chunking.
+func splitSemantic(text string, maxTokens int, tokenizer Tokenizer, _ Chunking) []string {
+	units := sentenceUnits(text)
+	if len(units) < 2 || tokenizer.Count(text) <= maxTokens {
+		return packPieces(units, maxTokens, tokenizer, func(piece string) []string {
+			return splitRecursive(piece, maxTokens, tokenizer, sentenceFallbackSeparators)
+		})
+	}
+	analyzer := NewAnalyzer(DefaultAnalyzerSettings)
+	terms := make([][]string, len(units))
+	for i, u := range units {
+		terms[i] = analyzer.Analyze(u)
+	}
+	window := func(from, to int) map[string]bool {
+		set := map[string]bool{}
+		for _, t := range terms[max(from, 0):min(to, len(terms))] {
+			for _, term := range t {
+				set[term] = true
+			}
+		}
+		return set
+	}
+	// sims[i] compares the sentences before unit i with those from it on
+	sims := make([]float64, len(units))
+	mean := 0.0
+	for i := 1; i < len(units); i++ {
+		sims[i] = jaccard(window(i-semanticWindow, i), window(i, i+semanticWindow))
+		mean += sims[i]
+	}
+	mean /= float64(len(units) - 1)
+	variance := 0.0
+	for i := 1; i < len(units); i++ {
+		variance += (sims[i] - mean) * (sims[i] - mean)
+	}
+	cutoff := mean - math.Sqrt(variance/float64(len(units)-1))/2
+
+	valley := func(i int) bool {
+		return sims[i] < cutoff && (i == 1 || sims[i] <= sims[i-1]) && (i == len(units)-1 || sims[i] <= sims[i+1])
+	}
+
+	var groups []string
+	var current strings.Builder
+	for i, u := range units {
+		if i > 0 && valley(i) && strings.TrimSpace(current.String()) != "" {
+			groups = append(groups, current.String())
+			current.Reset()
+		}
+		current.WriteString(u)
+	}
+	groups = append(groups, current.String())
+
+	// Topics larger than a chunk are packed by sentence
+	var chunks []string
+	for _, g := range groups {
+		chunks = append(chunks, packPieces(sentenceUnits(g), maxTokens, tokenizer, func(piece string) []string {
+			return splitRecursive(piece, maxTokens, tokenizer, sentenceFallbackSeparators)
+		})...)
+	}
+	return chunks
+}
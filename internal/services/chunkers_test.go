@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkerRegistry(t *testing.T) {
+	for _, name := range []string{ChunkRecursive, ChunkLines, ChunkSentences, ChunkMarkdown, ChunkCode, ChunkSemantic} {
+		if _, err := GetChunker(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := GetChunker("words"); !errors.Is(err, ErrInvalidChunking) {
+		t.Errorf("expected an unknown strategy to be rejected, got %v", err)
+	}
+
+	RegisterChunker("halves", ChunkerFunc(func(text string, _ int, _ Tokenizer, _ Chunking) []string {
+		return []string{text[:len(text)/2], text[len(text)/2:]}
+	}))
+	defer func() {
+		chunkerMu.Lock()
+		delete(chunkerReg, "halves")
+		chunkerMu.Unlock()
+	}()
+	tok, _ := GetTokenizer("whitespace")
+	c := Chunking{Strategy: "halves"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.split("abcd", 10, tok); !reflect.DeepEqual(got, []string{"ab", "cd"}) {
+		t.Errorf("expected the registered strategy to be used, got %q", got)
+	}
+}
+
+func TestSentenceChunking(t *testing.T) {
+	text := "First one. Second \"quoted.\" Third?\nStill third! 東京です。Last\n\nNew paragraph"
+	units := sentenceUnits(text)
+	if strings.Join(units, "") != text {
+		t.Fatalf("units don't concatenate back to the text: %q", units)
+	}
+	want := []string{"First one. ", "Second \"quoted.\" ", "Third?\n", "Still third! ", "東京です。", "Last\n\n", "New paragraph"}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("got %q, want %q", units, want)
+	}
+
+	// Sentences are packed whole, across line breaks
+	tok, _ := GetTokenizer("whitespace")
+	chunks := Chunking{Strategy: ChunkSentences}.split("One two three.\nFour five. Six seven eight.", 5, tok)
+	if want := []string{"One two three.\nFour five. ", "Six seven eight."}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("got %q, want %q", chunks, want)
+	}
+}
+
+func TestSemanticChunking(t *testing.T) {
+	cats := "Cats purr when content. Cats groom their fur daily. Cats hunt mice at night. Cats nap in sunny spots. "
+	rust := "Rust compilers check borrow lifetimes. Rust compilers reject data races. Rust compilers emit fast binaries. Rust compilers explain errors well."
+	tok, _ := GetTokenizer("whitespace")
+	chunks := Chunking{Strategy: ChunkSemantic}.split(cats+rust, 30, tok)
+	if strings.Join(chunks, "") != cats+rust {
+		t.Fatalf("chunks don't concatenate back to the text: %q", chunks)
+	}
+	if !reflect.DeepEqual(chunks, []string{cats, rust}) {
+		t.Errorf("expected a break at the topic shift, got %q", chunks)
+	}
+	semantic := Chunking{Strategy: ChunkSemantic}
+	for _, c := range semantic.split(cats+rust, 8, tok) {
+		if n := tok.Count(c); n > 8 {
+			t.Errorf("chunk has %d tokens: %q", n, c)
+		}
+	}
+}
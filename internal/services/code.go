@@ -0,0 +1,311 @@
+package services
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// Metadata attached to chunks of source code. The language goes under
+// languageKey, e.g. "go".
+const (
+	symbolKey    = "symbol"     // declared name, e.g. "IngestService.Search"
+	startLineKey = "start_line" // 1-based, inclusive
+	endLineKey   = "end_line"
+)
+
+// codeLanguage describes how to find top-level declarations in a language
+// without a full parser.
+type codeLanguage struct {
+	name string
+	// decl matches a top-level declaration line; its last non-empty group
+	// is the symbol.
+	decl *regexp.Regexp
+	// attach matches comment and decorator lines that belong to the
+	// declaration directly below them.
+	attach *regexp.Regexp
+	lex    codeLexer
+}
+
+var (
+	pythonLanguage = codeLanguage{
+		name:   "python",
+		decl:   regexp.MustCompile(`^(?:async\s+)?(?:def|class)\s+([A-Za-z_]\w*)`),
+		attach: regexp.MustCompile(`^(?:#|@)`),
+		lex:    codeLexer{lineComment: "#", tripleQuotes: true},
+	}
+	jsDecl     = regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(?:function\*?|class|interface|type|enum|const|let|var|namespace)\s+([A-Za-z_$][\w$]*)|^export\s+(default)\b`)
+	jsAttach   = regexp.MustCompile(`^(?://|/\*|\*|@)`)
+	jsLexer    = codeLexer{lineComment: "//", blockComments: true, backticks: true}
+	jsLanguage = codeLanguage{name: "javascript", decl: jsDecl, attach: jsAttach, lex: jsLexer}
+	tsLanguage = codeLanguage{name: "typescript", decl: jsDecl, attach: jsAttach, lex: jsLexer}
+)
+
+func init() {
+	extractors[".go"] = extractGo
+	for ext, lang := range map[string]codeLanguage{
+		".py": pythonLanguage,
+		".js": jsLanguage, ".jsx": jsLanguage, ".mjs": jsLanguage, ".cjs": jsLanguage,
+		".ts": tsLanguage, ".tsx": tsLanguage,
+	} {
+		extractors[ext] = lang.extract
+	}
+}
+
+// codeSection builds the section for lines[start:end] (0-based, end
+// exclusive), dropping trailing blank lines.
+func codeSection(lang string, lines []string, start, end int, symbol string) (docSection, bool) {
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	for start < end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start >= end {
+		return docSection{}, false
+	}
+	md := map[string]interface{}{languageKey: lang, startLineKey: start + 1, endLineKey: end}
+	if symbol != "" {
+		md[symbolKey] = symbol
+	}
+	return docSection{text: strings.Join(lines[start:end], "\n"), metadata: md, split: codeUnits}, true
+}
+
+// codeUnits splits code at blank lines, so a declaration that fits a chunk
+// stays whole and a longer one breaks between blocks rather than
+// mid-statement.
+func codeUnits(text string) []string {
+	var units, block []string
+	for _, line := range strings.Split(text, "\n") {
+		block = append(block, line)
+		if strings.TrimSpace(line) == "" {
+			units = append(units, strings.Join(block, "\n"))
+			block = nil
+		}
+	}
+	if len(block) > 0 {
+		units = append(units, strings.Join(block, "\n"))
+	}
+	return units
+}
+
+// extractGo returns one section per top-level declaration with its doc
+// comment, plus one for the package clause and imports. A file that does
+// not parse becomes a single section.
+func extractGo(content []byte) ([]docSection, error) {
+	lines := strings.Split(string(content), "\n")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		if sec, ok := codeSection("go", lines, 0, len(lines), ""); ok {
+			return []docSection{sec}, nil
+		}
+		return nil, nil
+	}
+
+	var sections []docSection
+	add := func(start, end int, symbol string) {
+		if sec, ok := codeSection("go", lines, start, end, symbol); ok {
+			sections = append(sections, sec)
+		}
+	}
+	prev := 0 // first line not yet in a section
+	for _, decl := range f.Decls {
+		symbol, isImport := goSymbol(decl)
+		if isImport {
+			continue // stays with the package clause
+		}
+		start := fset.Position(decl.Pos()).Line - 1
+		if doc := goDoc(decl); doc != nil {
+			start = fset.Position(doc.Pos()).Line - 1
+		}
+		end := fset.Position(decl.End()).Line
+		if start > prev {
+			// Package clause, imports and free-standing comments
+			add(prev, start, "")
+		}
+		add(start, end, symbol)
+		prev = end
+	}
+	add(prev, len(lines), "")
+	return sections, nil
+}
+
+func goDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+// goSymbol names a declaration: "Func", "Type.Method", or the names a
+// type, var or const group declares.
+func goSymbol(decl ast.Decl) (symbol string, isImport bool) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return receiverType(d.Recv.List[0].Type) + "." + d.Name.Name, false
+		}
+		return d.Name.Name, false
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT {
+			return "", true
+		}
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		return strings.Join(names, ", "), false
+	}
+	return "", false
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// extract splits a file into one section per top-level declaration,
+// including the comments and decorators directly above it. Other top-level
+// code (imports, statements) forms sections without a symbol.
+func (l codeLanguage) extract(content []byte) ([]docSection, error) {
+	lines := strings.Split(string(content), "\n")
+	top := l.lex.topLevel(lines)
+
+	var sections []docSection
+	start, symbol := 0, ""
+	isCode := false // whether the open section is symbol-less top-level code
+	attachFrom := -1
+	boundary := func(at int) {
+		if sec, ok := codeSection(l.name, lines, start, at, symbol); ok {
+			sections = append(sections, sec)
+		}
+		start = at
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			attachFrom = -1
+			continue
+		}
+		if !top[i] {
+			continue
+		}
+		if m := l.decl.FindStringSubmatch(line); m != nil {
+			at := i
+			if attachFrom >= 0 {
+				at = attachFrom
+			}
+			boundary(at)
+			symbol, isCode, attachFrom = lastGroup(m), false, -1
+			continue
+		}
+		if l.attach.MatchString(line) {
+			if attachFrom < 0 {
+				attachFrom = i
+			}
+			continue
+		}
+		if !isCode {
+			at := i
+			if attachFrom >= 0 {
+				at = attachFrom
+			}
+			boundary(at)
+			symbol, isCode = "", true
+		}
+		attachFrom = -1
+	}
+	boundary(len(lines))
+	return sections, nil
+}
+
+func lastGroup(m []string) string {
+	for i := len(m) - 1; i > 0; i-- {
+		if m[i] != "" {
+			return m[i]
+		}
+	}
+	return ""
+}
+
+// codeLexer tracks just enough syntax (strings, comments and bracket depth)
+// to tell which lines start at the top level of a file.
+type codeLexer struct {
+	lineComment   string
+	blockComments bool // /* ... */
+	tripleQuotes  bool // Python """...""" and '''...'''
+	backticks     bool // JS template literals
+}
+
+// topLevel reports, per line, whether it starts outside any bracket, string
+// or comment and without indentation.
+func (x codeLexer) topLevel(lines []string) []bool {
+	out := make([]bool, len(lines))
+	depth := 0
+	inBlock := false // inside /* */
+	quote := ""      // open multi-line string delimiter
+	for i, line := range lines {
+		out[i] = depth == 0 && !inBlock && quote == "" && line != "" && line[0] != ' ' && line[0] != '\t'
+		for j := 0; j < len(line); j++ {
+			rest := line[j:]
+			switch {
+			case inBlock:
+				if strings.HasPrefix(rest, "*/") {
+					inBlock = false
+					j++
+				}
+			case quote != "":
+				if rest[0] == '\\' {
+					j++
+				} else if strings.HasPrefix(rest, quote) {
+					j += len(quote) - 1
+					quote = ""
+				}
+			case strings.HasPrefix(rest, x.lineComment):
+				j = len(line)
+			case x.blockComments && strings.HasPrefix(rest, "/*"):
+				inBlock = true
+				j++
+			case x.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+				quote = rest[:3]
+				j += 2
+			case x.backticks && rest[0] == '`':
+				quote = "`"
+			case rest[0] == '"' || rest[0] == '\'':
+				// Single-line string: skip to its end
+				for j++; j < len(line) && line[j] != rest[0]; j++ {
+					if line[j] == '\\' {
+						j++
+					}
+				}
+			case strings.ContainsRune("([{", rune(rest[0])):
+				depth++
+			case strings.ContainsRune(")]}", rune(rest[0])):
+				depth = max(0, depth-1)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+type wantSection struct {
+	symbol     string
+	start, end int
+}
+
+func checkCodeSections(t *testing.T, file, src, lang string, want []wantSection) []docSection {
+	t.Helper()
+	sections, err := extractSections(file, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("%s: expected %d sections, got %d: %+v", file, len(want), len(sections), sections)
+	}
+	for i, w := range want {
+		md := sections[i].metadata
+		symbol, _ := md[symbolKey].(string)
+		if symbol != w.symbol || md[startLineKey] != w.start || md[endLineKey] != w.end || md[languageKey] != lang {
+			t.Errorf("%s section %d: got %v, want %+v", file, i, md, w)
+		}
+	}
+	return sections
+}
+
+func TestExtractGo(t *testing.T) {
+	src := strings.Join([]string{
+		"package demo", // 1
+		"",
+		`import "fmt"`,
+		"",
+		"// Greeter greets.", // 5
+		"type Greeter struct{ name string }",
+		"",
+		"// Greet says hello.",
+		"func (g *Greeter) Greet() {",
+		"	fmt.Println(\"hi\", g.name)", // 10
+		"",
+		"	fmt.Println(\"}\")",
+		"}",
+		"",
+		"const a, b = 1, 2", // 15
+	}, "\n")
+	sections := checkCodeSections(t, "demo.go", src, "go", []wantSection{
+		{"", 1, 3},
+		{"Greeter", 5, 6},
+		{"Greeter.Greet", 8, 13},
+		{"a, b", 15, 15},
+	})
+	// A function that fits a chunk stays whole
+	if units := sections[2].units(sections[2].text); len(units) != 2 || chunkUnits(units, 512, whitespaceTokenizer{})[0] != sections[2].text+"\n" {
+		t.Errorf("unexpected units %q", units)
+	}
+}
+
+func TestExtractPython(t *testing.T) {
+	src := strings.Join([]string{
+		"import os", // 1
+		"",
+		"@cached",
+		"def load(path):",
+		`    """Load a file.`, // 5
+		"",
+		"def not_a_def():",
+		`    """`,
+		"    return open(path)",
+		"", // 10
+		"class Store:",
+		"    def get(self):",
+		"        return {",
+		"'k': 1,",
+		"        }", // 15
+		"",
+		"if __name__ == '__main__':",
+		"    load('x')",
+	}, "\n")
+	checkCodeSections(t, "util.py", src, "python", []wantSection{
+		{"", 1, 1},
+		{"load", 3, 9},
+		{"Store", 11, 15},
+		{"", 17, 18},
+	})
+}
+
+func TestExtractTypeScript(t *testing.T) {
+	src := strings.Join([]string{
+		`import { x } from "./x";`, // 1
+		"",
+		"/** Config for the app. */",
+		"export interface Config {",
+		"  url: string;", // 5
+		"}",
+		"",
+		"export async function start(cfg: Config) {",
+		"  const s = `",
+		"function fake() {", // 10
+		"`;",
+		"  /* } */",
+		"}",
+	}, "\n")
+	checkCodeSections(t, "app.ts", src, "typescript", []wantSection{
+		{"", 1, 1},
+		{"Config", 3, 6},
+		{"start", 8, 13},
+	})
+}
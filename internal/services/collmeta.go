@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// collectionMetadataSettingKey stores the metadata a collection is created
+// with in the settings table.
+const collectionMetadataSettingKey = "collection_metadata"
+
+var (
+	// ErrInvalidCollectionMetadata is returned for metadata Chroma would
+	// reject or misread, such as an unknown hnsw: key or a bad value.
+	ErrInvalidCollectionMetadata = errors.New("invalid collection metadata")
+	// ErrCollectionMetadataLocked is returned when creation metadata is set
+	// for a collection that already exists: Chroma fixes the HNSW index
+	// parameters when the collection is created.
+	ErrCollectionMetadataLocked = errors.New("collection metadata is fixed once the collection exists")
+)
+
+// hnswSpaces are the distance functions Chroma's HNSW index supports.
+var hnswSpaces = []string{"cosine", "ip", "l2"}
+
+// hnswIntKeys are the HNSW parameters that take a positive integer.
+var hnswIntKeys = map[string]bool{
+	chroma.HNSWM:              true,
+	chroma.HNSWConstructionEF: true,
+	chroma.HNSWSearchEF:       true,
+	chroma.HNSWNumThreads:     true,
+	chroma.HNSWBatchSize:      true,
+	chroma.HNSWSyncThreshold:  true,
+}
+
+// NormalizeCollectionMetadata checks metadata for a new collection and
+// returns it with HNSW integer parameters as ints. Values must be strings,
+// numbers or booleans; keys under "hnsw:" must be parameters Chroma knows.
+func NormalizeCollectionMetadata(md map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(md))
+	for key, value := range md {
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty key", ErrInvalidCollectionMetadata)
+		}
+		switch key {
+		case chroma.HNSWSpace:
+			space, _ := value.(string)
+			i := sort.SearchStrings(hnswSpaces, space)
+			if i == len(hnswSpaces) || hnswSpaces[i] != space {
+				return nil, fmt.Errorf("%w: %s must be one of %s", ErrInvalidCollectionMetadata, key, strings.Join(hnswSpaces, ", "))
+			}
+		case chroma.HNSWResizeFactor:
+			f, ok := toFloat(value)
+			if !ok || f <= 0 {
+				return nil, fmt.Errorf("%w: %s must be a positive number", ErrInvalidCollectionMetadata, key)
+			}
+			value = f
+		default:
+			if hnswIntKeys[key] {
+				f, ok := toFloat(value)
+				if !ok || f < 1 || f != math.Trunc(f) {
+					return nil, fmt.Errorf("%w: %s must be a positive integer", ErrInvalidCollectionMetadata, key)
+				}
+				value = int(f)
+				break
+			}
+			if strings.HasPrefix(key, "hnsw:") {
+				return nil, fmt.Errorf("%w: unknown HNSW parameter %q", ErrInvalidCollectionMetadata, key)
+			}
+			switch value.(type) {
+			case string, bool, float64, int:
+			default:
+				return nil, fmt.Errorf("%w: %s must be a string, number or boolean", ErrInvalidCollectionMetadata, key)
+			}
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// CollectionMetadata returns the metadata stored for creating a collection
+// and, when it exists, the metadata Chroma reports for it.
+func (s *IngestService) CollectionMetadata(ctx context.Context, collection string) (stored, live map[string]interface{}, err error) {
+	if stored, err = s.storedCollectionMetadata(collection); err != nil {
+		return nil, nil, err
+	}
+	c, err := s.chromaDB.GetCollection(ctx, collection)
+	if err != nil || c.Metadata() == nil {
+		return stored, nil, nil
+	}
+	raw, err := c.Metadata().MarshalJSON()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read collection metadata: %w", err)
+	}
+	if err := json.Unmarshal(raw, &live); err != nil {
+		return nil, nil, fmt.Errorf("read collection metadata: %w", err)
+	}
+	return stored, live, nil
+}
+
+// SetCollectionMetadata validates and stores the metadata a collection is
+// created with, whether by CreateCollection or on its first ingest.
+func (s *IngestService) SetCollectionMetadata(ctx context.Context, collection string, md map[string]interface{}) (map[string]interface{}, error) {
+	if s.settings == nil {
+		return nil, errNoSettingsStore
+	}
+	md, err := NormalizeCollectionMetadata(md)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.chromaDB.GetCollection(ctx, collection); err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrCollectionMetadataLocked, collection)
+	}
+	return md, s.settings.SetCollectionSetting(collection, collectionMetadataSettingKey, md)
+}
+
+func (s *IngestService) storedCollectionMetadata(collection string) (map[string]interface{}, error) {
+	if s.settings == nil {
+		return nil, nil
+	}
+	var md map[string]interface{}
+	if _, err := s.settings.GetCollectionSetting(collection, collectionMetadataSettingKey, &md); err != nil {
+		return nil, err
+	}
+	// Stored JSON reads HNSW integers back as floats
+	return NormalizeCollectionMetadata(md)
+}
+
+// createCollection gets or creates a collection, creating it with md or,
+// if md is empty, with the metadata stored for it.
+func (s *IngestService) createCollection(ctx context.Context, name string, md map[string]interface{}) (chroma.Collection, error) {
+	if len(md) == 0 {
+		stored, err := s.storedCollectionMetadata(name)
+		if err != nil {
+			return nil, err
+		}
+		md = stored
+	}
+	if len(md) == 0 {
+		return s.chromaDB.GetOrCreateCollection(ctx, name)
+	}
+	// Check first: get-or-create with metadata would try to change an
+	// existing collection's metadata
+	if c, err := s.chromaDB.GetCollection(ctx, name); err == nil {
+		return c, nil
+	}
+	return s.chromaDB.GetOrCreateCollection(ctx, name, chroma.WithCollectionMetadataCreate(chroma.NewMetadataFromMap(md)))
+}
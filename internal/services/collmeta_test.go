@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// metaClient records the metadata collections are created with.
+type metaClient struct {
+	memClient
+	created map[string]chroma.CollectionMetadata
+}
+
+func (c *metaClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	if col, ok := c.collections[name]; ok {
+		return namedCollection{col}, nil
+	}
+	op := &chroma.CreateCollectionOp{}
+	for _, o := range opts {
+		if err := o(op); err != nil {
+			return nil, err
+		}
+	}
+	c.created[name] = op.Metadata
+	col, err := c.CreateCollection(ctx, name)
+	return namedCollection{col}, err
+}
+
+type namedCollection struct{ chroma.Collection }
+
+func (c namedCollection) ID() string { return "id-" + c.Name() }
+
+func TestNormalizeCollectionMetadata(t *testing.T) {
+	md, err := NormalizeCollectionMetadata(map[string]interface{}{"hnsw:space": "cosine", "hnsw:M": 32.0, "hnsw:resize_factor": 1.5, "team": "search"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md["hnsw:M"] != 32 || md["hnsw:resize_factor"] != 1.5 || md["team"] != "search" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+	for _, bad := range []map[string]interface{}{
+		{"hnsw:space": "manhattan"},
+		{"hnsw:construction_ef": 0.0},
+		{"hnsw:M": 16.5},
+		{"hnsw:ef": 100.0},
+		{"tags": []interface{}{"a"}},
+	} {
+		if _, err := NormalizeCollectionMetadata(bad); !errors.Is(err, ErrInvalidCollectionMetadata) {
+			t.Errorf("expected %v to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestCollectionMetadataOnCreate(t *testing.T) {
+	ctx := context.Background()
+	client := &metaClient{memClient: memClient{collections: map[string]*memCollection{}}, created: map[string]chroma.CollectionMetadata{}}
+	s := NewIngestService(client).WithSettings(memSettings{}).WithNamePolicy(NamePolicy{Mode: NamingOff})
+
+	if _, err := s.CreateCollection(ctx, "big", "", map[string]interface{}{"hnsw:construction_ef": 200.0}); err != nil {
+		t.Fatal(err)
+	}
+	if ef, ok := client.created["big"].GetInt("hnsw:construction_ef"); !ok || ef != 200 {
+		t.Errorf("expected construction_ef passed to Chroma, got %v", client.created["big"])
+	}
+	if _, err := s.CreateCollection(ctx, "big", "", map[string]interface{}{"hnsw:M": 8.0}); !errors.Is(err, ErrCollectionMetadataLocked) {
+		t.Errorf("expected ErrCollectionMetadataLocked, got %v", err)
+	}
+
+	// Stored metadata applies when the first ingest creates the collection
+	if _, err := s.SetCollectionMetadata(ctx, "lazy", map[string]interface{}{"hnsw:space": "ip"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.getOrCreateCollection(ctx, "lazy"); err != nil {
+		t.Fatal(err)
+	}
+	if space, _ := client.created["lazy"].GetString("hnsw:space"); space != "ip" {
+		t.Errorf("expected stored space on lazy create, got %v", client.created["lazy"])
+	}
+	if _, err := s.SetCollectionMetadata(ctx, "lazy", map[string]interface{}{"hnsw:space": "l2"}); !errors.Is(err, ErrCollectionMetadataLocked) {
+		t.Errorf("expected ErrCollectionMetadataLocked, got %v", err)
+	}
+}
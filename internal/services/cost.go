@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Kinds of model call tracked for cost.
+const (
+	CostEmbedding  = "embedding"
+	CostGeneration = "generation"
+)
+
+// TokenUsage is what one model call consumed.
+type TokenUsage struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// ParseModelPrices parses "model=input:output" entries (USD per million
+// tokens; ":output" may be omitted for embedding models).
+func ParseModelPrices(entries []string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice, len(entries))
+	for _, e := range entries {
+		model, price, ok := strings.Cut(e, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("model price %q: want model=input:output", e)
+		}
+		in, out, _ := strings.Cut(price, ":")
+		var p ModelPrice
+		var err error
+		if p.Input, err = strconv.ParseFloat(strings.TrimSpace(in), 64); err != nil {
+			return nil, fmt.Errorf("model price %q: %w", e, err)
+		}
+		if out != "" {
+			if p.Output, err = strconv.ParseFloat(strings.TrimSpace(out), 64); err != nil {
+				return nil, fmt.Errorf("model price %q: %w", e, err)
+			}
+		}
+		prices[model] = p
+	}
+	return prices, nil
+}
+
+// CostStore persists daily model usage.
+type CostStore interface {
+	AddCost(delta config.CostUsage) error
+	ListCosts(since string) ([]config.CostUsage, error)
+}
+
+// CostTracker records the tokens and estimated cost of model calls per
+// API key and collection. Generation calls report their own token usage.
+// Embeddings run inside Chroma's embedding function, so their tokens are
+// estimated with the cl100k tokenizer and attributed to the configured
+// embedding model. A nil tracker records nothing.
+type CostTracker struct {
+	store     CostStore
+	prices    map[string]ModelPrice
+	embedding TokenUsage // provider and model of embedding calls
+	tokenizer Tokenizer
+}
+
+// NewCostTracker builds a tracker pricing calls by model name; models
+// without a price are tracked at zero cost.
+func NewCostTracker(store CostStore, prices map[string]ModelPrice, embeddingProvider, embeddingModel string) *CostTracker {
+	tok, err := GetTokenizer("cl100k")
+	if err != nil {
+		tok = whitespaceTokenizer{}
+	}
+	return &CostTracker{store: store, prices: prices, embedding: TokenUsage{Provider: embeddingProvider, Model: embeddingModel}, tokenizer: tok}
+}
+
+// WithCostTracker records embedding and generation usage.
+func (s *IngestService) WithCostTracker(t *CostTracker) *IngestService {
+	s.costs = t
+	return s
+}
+
+// Record adds one call's usage. Failures are logged, not returned.
+func (t *CostTracker) Record(ctx context.Context, collection, kind string, u TokenUsage) {
+	if t == nil {
+		return
+	}
+	delta := config.CostUsage{
+		Day:          time.Now().UTC().Format(time.DateOnly),
+		Collection:   collection,
+		Kind:         kind,
+		Provider:     u.Provider,
+		Model:        u.Model,
+		Calls:        1,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+	}
+	if key, ok := APIKeyFromContext(ctx); ok {
+		delta.KeyID = key.ID
+	}
+	if p, ok := t.prices[u.Model]; ok {
+		delta.CostUSD = (float64(u.InputTokens)*p.Input + float64(u.OutputTokens)*p.Output) / 1e6
+	}
+	if err := t.store.AddCost(delta); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", collection).Warn("Failed to record model usage")
+	}
+}
+
+// embedded records an embedding call over texts.
+func (t *CostTracker) embedded(ctx context.Context, collection string, texts ...string) {
+	if t == nil || len(texts) == 0 {
+		return
+	}
+	u := t.embedding
+	for _, text := range texts {
+		u.InputTokens += t.tokenizer.Count(text)
+	}
+	t.Record(ctx, collection, CostEmbedding, u)
+}
+
+// CostTotals sums usage.
+type CostTotals struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+func (c *CostTotals) add(u config.CostUsage) {
+	c.Calls += u.Calls
+	c.InputTokens += u.InputTokens
+	c.OutputTokens += u.OutputTokens
+	c.CostUSD += u.CostUSD
+}
+
+// CostGroup is the usage of one model, collection or API key.
+type CostGroup struct {
+	Kind       string `json:"kind,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	KeyID      string `json:"key_id,omitempty"`
+	CostTotals
+}
+
+// CostReport aggregates model usage over a period.
+type CostReport struct {
+	Since        string      `json:"since"`
+	Total        CostTotals  `json:"total"`
+	ByModel      []CostGroup `json:"by_model"`
+	ByCollection []CostGroup `json:"by_collection"`
+	ByKey        []CostGroup `json:"by_key"`
+}
+
+// Report aggregates usage over the last days (including today). Groups are
+// ordered by cost, then by tokens.
+func (t *CostTracker) Report(days int) (*CostReport, error) {
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format(time.DateOnly)
+	rows, err := t.store.ListCosts(since)
+	if err != nil {
+		return nil, err
+	}
+	report := &CostReport{Since: since}
+	models, collections, keys := map[CostGroup]*CostTotals{}, map[CostGroup]*CostTotals{}, map[CostGroup]*CostTotals{}
+	add := func(groups map[CostGroup]*CostTotals, g CostGroup, u config.CostUsage) {
+		if groups[g] == nil {
+			groups[g] = &CostTotals{}
+		}
+		groups[g].add(u)
+	}
+	for _, u := range rows {
+		report.Total.add(u)
+		add(models, CostGroup{Kind: u.Kind, Provider: u.Provider, Model: u.Model}, u)
+		add(collections, CostGroup{Collection: u.Collection}, u)
+		add(keys, CostGroup{KeyID: u.KeyID}, u)
+	}
+	report.ByModel, report.ByCollection, report.ByKey = costGroups(models), costGroups(collections), costGroups(keys)
+	return report, nil
+}
+
+func costGroups(m map[CostGroup]*CostTotals) []CostGroup {
+	out := make([]CostGroup, 0, len(m))
+	for g, totals := range m {
+		g.CostTotals = *totals
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		if at, bt := a.InputTokens+a.OutputTokens, b.InputTokens+b.OutputTokens; at != bt {
+			return at > bt
+		}
+		return a.Kind+a.Provider+a.Model+a.Collection+a.KeyID < b.Kind+b.Provider+b.Model+b.Collection+b.KeyID
+	})
+	return out
+}
+
+// providerName labels a model endpoint by its host, e.g. "api.openai.com".
+func providerName(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return endpoint
+}
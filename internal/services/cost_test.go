@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+type memCostStore struct{ rows []config.CostUsage }
+
+func (m *memCostStore) AddCost(delta config.CostUsage) error {
+	m.rows = append(m.rows, delta)
+	return nil
+}
+
+func (m *memCostStore) ListCosts(since string) ([]config.CostUsage, error) { return m.rows, nil }
+
+func TestParseModelPrices(t *testing.T) {
+	prices, err := ParseModelPrices([]string{"gpt-4o-mini=0.15:0.60", "text-embedding-3-small=0.02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices["gpt-4o-mini"] != (ModelPrice{0.15, 0.6}) || prices["text-embedding-3-small"] != (ModelPrice{Input: 0.02}) {
+		t.Errorf("unexpected prices %+v", prices)
+	}
+	for _, bad := range []string{"gpt-4o", "=1", "gpt-4o=cheap", "gpt-4o=1:x"} {
+		if _, err := ParseModelPrices([]string{bad}); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCostTracker(t *testing.T) {
+	store := &memCostStore{}
+	tracker := NewCostTracker(store, map[string]ModelPrice{"gpt-4o-mini": {Input: 0.15, Output: 0.6}}, "chroma", "all-MiniLM-L6-v2")
+	keyed := WithAPIKey(context.Background(), config.APIKey{ID: "team-a"})
+
+	tracker.Record(keyed, "faq", CostGeneration, TokenUsage{Provider: "api.openai.com", Model: "gpt-4o-mini", InputTokens: 1_000_000, OutputTokens: 500_000})
+	tracker.embedded(context.Background(), "docs", "hello world", "again")
+	var nilTracker *CostTracker
+	nilTracker.embedded(context.Background(), "docs", "ignored")
+
+	if len(store.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", store.rows)
+	}
+	if gen := store.rows[0]; gen.KeyID != "team-a" || math.Abs(gen.CostUSD-0.45) > 1e-9 {
+		t.Errorf("unexpected generation row %+v", gen)
+	}
+	if emb := store.rows[1]; emb.Kind != CostEmbedding || emb.Model != "all-MiniLM-L6-v2" || emb.InputTokens != 3 || emb.CostUSD != 0 || emb.KeyID != "" {
+		t.Errorf("unexpected embedding row %+v", emb)
+	}
+
+	report, err := tracker.Report(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Calls != 2 || report.Total.InputTokens != 1_000_003 || len(report.ByModel) != 2 || len(report.ByKey) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.ByCollection[0].Collection != "faq" || report.ByCollection[1].Collection != "docs" {
+		t.Errorf("expected collections ordered by cost, got %+v", report.ByCollection)
+	}
+}
@@ -0,0 +1,711 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"golang.org/x/net/html"
+)
+
+var (
+	ErrInvalidCrawl  = errors.New("invalid crawl")
+	ErrCrawlNotFound = errors.New("crawl not found")
+)
+
+// Crawl job states.
+const (
+	CrawlRunning  = "running"
+	CrawlFinished = "finished"
+	CrawlCanceled = "canceled"
+	CrawlFailed   = "failed"
+)
+
+const (
+	defaultCrawlDepth = 2
+	defaultCrawlPages = 100
+	maxCrawlDepth     = 10
+	maxCrawlPages     = 10000
+	// crawlPageBytes caps the size of one fetched page.
+	crawlPageBytes = 10 << 20
+	// defaultCrawlDelay spaces requests unless robots.txt asks for more
+	// (up to maxCrawlDelay).
+	defaultCrawlDelay = 250 * time.Millisecond
+	maxCrawlDelay     = 10 * time.Second
+	crawlUserAgent    = "forge-crawler"
+	crawlJobsKept     = 100
+	crawlErrorsKept   = 50
+)
+
+// CrawlSpec describes a crawl: pages reachable from URL on the same host,
+// following links up to MaxDepth hops and fetching at most MaxPages pages.
+// A URL ending in .xml is read as a sitemap (or sitemap index) whose pages
+// are the starting points.
+type CrawlSpec struct {
+	URL        string                 `json:"url"`
+	Collection string                 `json:"collection"`
+	MaxDepth   *int                   `json:"max_depth,omitempty"` // default 2; 0 fetches only the starting pages
+	MaxPages   int                    `json:"max_pages,omitempty"` // default 100
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Validate checks the spec and fills in defaults.
+func (c *CrawlSpec) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidCrawl)
+	}
+	if c.Collection == "" {
+		return fmt.Errorf("%w: collection is required", ErrInvalidCrawl)
+	}
+	if c.MaxDepth == nil {
+		depth := defaultCrawlDepth
+		c.MaxDepth = &depth
+	}
+	if *c.MaxDepth < 0 || *c.MaxDepth > maxCrawlDepth {
+		return fmt.Errorf("%w: max_depth must be between 0 and %d", ErrInvalidCrawl, maxCrawlDepth)
+	}
+	if c.MaxPages == 0 {
+		c.MaxPages = defaultCrawlPages
+	}
+	if c.MaxPages < 0 || c.MaxPages > maxCrawlPages {
+		return fmt.Errorf("%w: max_pages must be between 1 and %d", ErrInvalidCrawl, maxCrawlPages)
+	}
+	return nil
+}
+
+// CrawlJob reports a crawl's progress.
+type CrawlJob struct {
+	ID         string     `json:"id"`
+	URL        string     `json:"url"`
+	Collection string     `json:"collection"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Queued pages are discovered but not yet fetched.
+	Queued   int `json:"queued"`
+	Fetched  int `json:"fetched"`
+	Ingested int `json:"ingested"`
+	// Skipped pages are disallowed by robots rules, not HTML, empty or
+	// marked noindex.
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"` // the first few per-page errors
+	Error   string   `json:"error,omitempty"`  // why a failed crawl stopped
+	// Timings sums the stage times of the ingested pages.
+	Timings IngestTimings `json:"timings"`
+}
+
+// CrawlService runs website crawls in the background, ingesting each page
+// into the target collection. Jobs are kept in memory; the most recent
+// crawlJobsKept are listed.
+type CrawlService struct {
+	ingest *IngestService
+	client *http.Client
+	delay  time.Duration
+	// allowPrivate lets crawls reach loopback and private addresses.
+	allowPrivate bool
+	// ingestPage is IngestFileWithOptions, replaceable in tests.
+	ingestPage func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error)
+
+	mu    sync.Mutex
+	jobs  map[string]*crawlJob
+	order []string // job IDs, oldest first
+}
+
+type crawlJob struct {
+	CrawlJob
+	cancel context.CancelFunc
+}
+
+func NewCrawlService(ingest *IngestService) *CrawlService {
+	return &CrawlService{
+		ingest:     ingest,
+		client:     newFetchClient(30*time.Second, isPublicIP),
+		delay:      defaultCrawlDelay,
+		ingestPage: ingest.IngestFileWithOptions,
+		jobs:       make(map[string]*crawlJob),
+	}
+}
+
+// WithPrivateNetworks lets crawls fetch loopback, private and link-local
+// addresses, e.g. an intranet wiki. By default they are refused.
+func (s *CrawlService) WithPrivateNetworks(allow bool) *CrawlService {
+	s.allowPrivate = allow
+	s.client = newFetchClient(30*time.Second, fetchGuard(allow))
+	return s
+}
+
+// Start validates spec and starts crawling in the background. The crawl
+// outlives the request but keeps its identity (API key, principals).
+func (s *CrawlService) Start(ctx context.Context, spec CrawlSpec) (CrawlJob, error) {
+	if err := spec.Validate(); err != nil {
+		return CrawlJob{}, err
+	}
+	if !s.allowPrivate {
+		u, _ := url.Parse(spec.URL)
+		if err := checkPublicHost(u); err != nil {
+			return CrawlJob{}, fmt.Errorf("%w: %w", ErrInvalidCrawl, err)
+		}
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j := &crawlJob{CrawlJob: CrawlJob{ID: hex.EncodeToString(b), URL: spec.URL, Collection: spec.Collection, State: CrawlRunning, StartedAt: time.Now().UTC()}, cancel: cancel}
+
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	s.order = append(s.order, j.ID)
+	s.prune()
+	snapshot := j.snapshot()
+	s.mu.Unlock()
+
+	go s.run(ctx, j, spec)
+	return snapshot, nil
+}
+
+// prune forgets the oldest finished jobs beyond crawlJobsKept.
+func (s *CrawlService) prune() {
+	for i := 0; len(s.order) > crawlJobsKept && i < len(s.order); {
+		if id := s.order[i]; s.jobs[id].State != CrawlRunning {
+			delete(s.jobs, id)
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+func (j *crawlJob) snapshot() CrawlJob {
+	out := j.CrawlJob
+	out.Errors = append([]string(nil), j.Errors...)
+	return out
+}
+
+// Get returns a job's current progress.
+func (s *CrawlService) Get(id string) (CrawlJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return CrawlJob{}, ErrCrawlNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// List returns known jobs, newest first.
+func (s *CrawlService) List() []CrawlJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]CrawlJob, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		out = append(out, s.jobs[s.order[i]].snapshot())
+	}
+	return out
+}
+
+// Cancel stops a running crawl; pages already ingested stay.
+func (s *CrawlService) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return ErrCrawlNotFound
+	}
+	j.cancel()
+	return nil
+}
+
+func (s *CrawlService) update(j *crawlJob, fn func(*CrawlJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&j.CrawlJob)
+}
+
+func (s *CrawlService) run(ctx context.Context, j *crawlJob, spec CrawlSpec) {
+	defer j.cancel()
+	log := logging.FromContext(ctx).WithFields(logrus.Fields{"crawl": j.ID, "collection": spec.Collection})
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{"crawl": j.ID, "state": CrawlRunning}})
+
+	err := s.crawl(ctx, j, spec)
+	var final CrawlJob
+	s.update(j, func(cj *CrawlJob) {
+		now := time.Now().UTC()
+		cj.FinishedAt = &now
+		switch {
+		case err != nil:
+			cj.State, cj.Error = CrawlFailed, err.Error()
+		case ctx.Err() != nil:
+			cj.State = CrawlCanceled
+		default:
+			cj.State = CrawlFinished
+		}
+		final = *cj
+	})
+	log.WithFields(logrus.Fields{"state": final.State, "ingested": final.Ingested, "failed": final.Failed}).Info("Crawl complete")
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{
+		"crawl":    j.ID,
+		"state":    final.State,
+		"ingested": final.Ingested,
+		"failed":   final.Failed,
+	}})
+}
+
+type crawlItem struct {
+	url   *url.URL
+	depth int
+}
+
+// crawl fetches pages breadth-first. It returns an error only when the
+// crawl cannot start; page failures are counted on the job.
+func (s *CrawlService) crawl(ctx context.Context, j *crawlJob, spec CrawlSpec) error {
+	start, _ := url.Parse(spec.URL)
+	start.Fragment = ""
+	robots, err := s.robots(ctx, start)
+	if err != nil {
+		return err
+	}
+	delay := max(s.delay, min(robots.delay, maxCrawlDelay))
+
+	var queue []crawlItem
+	seen := map[string]bool{}
+	enqueue := func(u *url.URL, depth int) {
+		if !sameSite(u, start) || seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		queue = append(queue, crawlItem{u, depth})
+	}
+	if strings.HasSuffix(strings.ToLower(start.Path), ".xml") {
+		pages, err := s.sitemap(ctx, start, spec.MaxPages, 0)
+		if err != nil {
+			return fmt.Errorf("read sitemap: %w", err)
+		}
+		for _, u := range pages {
+			enqueue(u, 0)
+		}
+	} else {
+		enqueue(start, 0)
+	}
+
+	opts := IngestOptions{
+		Metadata: spec.Metadata,
+		Source:   IngestSource{ID: "crawl-" + j.ID, Kind: SourceCrawl, Ref: spec.URL},
+	}
+	fetched := 0
+	for len(queue) > 0 && fetched < spec.MaxPages && ctx.Err() == nil {
+		item := queue[0]
+		queue = queue[1:]
+		s.update(j, func(cj *CrawlJob) { cj.Queued = len(queue) })
+		if !robots.allowed(item.url) {
+			s.update(j, func(cj *CrawlJob) { cj.Skipped++ })
+			continue
+		}
+		if fetched > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+		}
+		fetched++
+		s.update(j, func(cj *CrawlJob) { cj.Fetched++ })
+		page, err := s.fetchPage(ctx, item.url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.pageFailed(j, item.url, err)
+			continue
+		}
+		if page == nil || !sameSite(page.url, start) {
+			// Not HTML, or redirected off-site
+			s.update(j, func(cj *CrawlJob) { cj.Skipped++ })
+			continue
+		}
+		seen[page.url.String()] = true
+		if !page.nofollow && item.depth < *spec.MaxDepth {
+			for _, link := range page.links {
+				enqueue(link, item.depth+1)
+			}
+		}
+		if page.noindex || page.text == "" {
+			s.update(j, func(cj *CrawlJob) { cj.Skipped++; cj.Queued = len(queue) })
+			continue
+		}
+
+		text := page.text
+		if page.title != "" {
+			text = page.title + "\n\n" + text
+		}
+		pageOpts := opts
+		pageOpts.Title = page.title
+		res, err := s.ingestPage(ctx, spec.Collection, item.url.String(), []byte(text), pageOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.pageFailed(j, item.url, err)
+			continue
+		}
+		s.update(j, func(cj *CrawlJob) {
+			cj.Ingested++
+			cj.Queued = len(queue)
+			if res.Timings != nil {
+				cj.Timings.Add(*res.Timings)
+			}
+		})
+	}
+	s.update(j, func(cj *CrawlJob) { cj.Queued = len(queue) })
+	return nil
+}
+
+func (s *CrawlService) pageFailed(j *crawlJob, u *url.URL, err error) {
+	s.update(j, func(cj *CrawlJob) {
+		cj.Failed++
+		if len(cj.Errors) < crawlErrorsKept {
+			cj.Errors = append(cj.Errors, fmt.Sprintf("%s: %v", u, err))
+		}
+	})
+}
+
+// sameSite reports whether u is an http(s) URL on base's host.
+func sameSite(u, base *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Hostname(), base.Hostname())
+}
+
+func (s *CrawlService) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", crawlUserAgent)
+	return s.client.Do(req)
+}
+
+// readLimited reads a response body of at most crawlPageBytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, crawlPageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > crawlPageBytes {
+		return nil, fmt.Errorf("larger than %d bytes", crawlPageBytes)
+	}
+	return body, nil
+}
+
+// crawledPage is the text and links of a fetched HTML page.
+type crawledPage struct {
+	url      *url.URL // after redirects
+	title    string
+	text     string
+	links    []*url.URL
+	noindex  bool
+	nofollow bool
+}
+
+// fetchPage fetches and parses u. It returns nil without an error for
+// responses that are not HTML.
+func (s *CrawlService) fetchPage(ctx context.Context, u *url.URL) (*crawledPage, error) {
+	resp, err := s.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct != "text/html" && ct != "application/xhtml+xml" {
+		return nil, nil
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	page, err := parsePage(resp.Request.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if robots := strings.ToLower(resp.Header.Get("X-Robots-Tag")); robots != "" {
+		page.noindex = page.noindex || strings.Contains(robots, "noindex")
+		page.nofollow = page.nofollow || strings.Contains(robots, "nofollow")
+	}
+	return page, nil
+}
+
+// htmlSkipped elements hold no page content.
+var htmlSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "nav": true, "footer": true,
+}
+
+// htmlBlocks end a line of extracted text, in addition to xhtmlBlocks.
+var htmlBlocks = map[string]bool{
+	"article": true, "main": true, "header": true, "aside": true, "ul": true, "ol": true,
+	"table": true, "hr": true, "dt": true, "dd": true, "figcaption": true,
+}
+
+// parsePage extracts a page's title, body text and links, honoring
+// <base href>, rel="nofollow" and robots meta tags.
+func parsePage(u *url.URL, body []byte) (*crawledPage, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	page := &crawledPage{url: u}
+	base := u
+	var hrefs []string
+	var b strings.Builder
+	var walk func(n *html.Node, skip bool)
+	walk = func(n *html.Node, skip bool) {
+		switch n.Type {
+		case html.TextNode:
+			if !skip {
+				b.WriteString(n.Data)
+			}
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "title":
+				if page.title == "" && n.FirstChild != nil {
+					page.title = collapseSpaces(n.FirstChild.Data)
+				}
+			case "base":
+				if ref, err := u.Parse(htmlAttr(n, "href")); err == nil && htmlAttr(n, "href") != "" {
+					base = ref
+				}
+			case "meta":
+				if name := strings.ToLower(htmlAttr(n, "name")); name == "robots" || name == crawlUserAgent {
+					content := strings.ToLower(htmlAttr(n, "content"))
+					page.noindex = page.noindex || strings.Contains(content, "noindex") || strings.Contains(content, "none")
+					page.nofollow = page.nofollow || strings.Contains(content, "nofollow") || strings.Contains(content, "none")
+				}
+			case "a":
+				if href := htmlAttr(n, "href"); href != "" && !strings.Contains(strings.ToLower(htmlAttr(n, "rel")), "nofollow") {
+					hrefs = append(hrefs, href)
+				}
+			}
+			skip = skip || htmlSkipped[n.Data]
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, skip)
+		}
+		if n.Type == html.ElementNode && (xhtmlBlocks[n.Data] || htmlBlocks[n.Data]) {
+			b.WriteString("\n")
+		}
+	}
+	walk(doc, false)
+
+	for _, href := range hrefs {
+		link, err := base.Parse(strings.TrimSpace(href))
+		if err != nil {
+			continue
+		}
+		link.Fragment = ""
+		page.links = append(page.links, link)
+	}
+	page.text = blankLinesRe.ReplaceAllString(strings.TrimSpace(collapseLineSpaces(b.String())), "\n\n")
+	return page, nil
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// sitemap lists the page URLs of a sitemap, following sitemap indexes one
+// level deep, up to limit URLs.
+func (s *CrawlService) sitemap(ctx context.Context, u *url.URL, limit, depth int) ([]*url.URL, error) {
+	resp, err := s.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", u, resp.Status)
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	var pages []*url.URL
+	for _, loc := range doc.URLs {
+		if p, err := url.Parse(strings.TrimSpace(loc)); err == nil && len(pages) < limit {
+			p.Fragment = ""
+			pages = append(pages, p)
+		}
+	}
+	if depth == 0 {
+		for _, loc := range doc.Sitemaps {
+			sub, err := url.Parse(strings.TrimSpace(loc))
+			if err != nil || len(pages) >= limit {
+				continue
+			}
+			more, err := s.sitemap(ctx, sub, limit-len(pages), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, more...)
+		}
+	}
+	return pages, nil
+}
+
+// robotsRules are the robots.txt rules that apply to the crawler.
+type robotsRules struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robots fetches the host's robots.txt. A missing file (4xx) allows
+// everything; an unreachable one stops the crawl.
+func (s *CrawlService) robots(ctx context.Context, start *url.URL) (robotsRules, error) {
+	u := &url.URL{Scheme: start.Scheme, Host: start.Host, Path: "/robots.txt"}
+	resp, err := s.get(ctx, u)
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return robotsRules{}, fmt.Errorf("fetch robots.txt: unexpected status %s", resp.Status)
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	return parseRobots(string(body), crawlUserAgent), nil
+}
+
+// parseRobots returns the rules of the group naming agent, else those of
+// the "*" group.
+func parseRobots(body, agent string) robotsRules {
+	var own, star robotsRules
+	var hasOwn bool
+	var groupAgents []string
+	inRules := false // a rule line ends a group's user-agent list
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if key == "user-agent" {
+			if inRules {
+				groupAgents, inRules = nil, false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+			continue
+		}
+		inRules = true
+		for _, a := range groupAgents {
+			var target *robotsRules
+			switch {
+			case a != "*" && strings.Contains(agent, a):
+				target, hasOwn = &own, true
+			case a == "*":
+				target = &star
+			default:
+				continue
+			}
+			switch key {
+			case "allow", "disallow":
+				if value != "" {
+					target.rules = append(target.rules, robotsRule{allow: key == "allow", pattern: value})
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					target.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+	if hasOwn {
+		return own
+	}
+	return star
+}
+
+// allowed applies the longest matching rule; Allow wins ties.
+func (r robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	rules := append([]robotsRule(nil), r.rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].allow && !rules[j].allow
+	})
+	for _, rule := range rules {
+		if robotsMatch(rule.pattern, path) {
+			return rule.allow
+		}
+	}
+	return true
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any run of
+// characters and a trailing $ anchors the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawl(t *testing.T) {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Home</title></head><body><p>Welcome</p>
+			<a href="/a">A</a> <a href="/private/x">P</a> <a href="https://elsewhere.example/">E</a><script>ignored()</script></body></html>`)
+	})
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><p>Page A</p><a href="/b">B</a><a href="/file.pdf">F</a></body></html>`)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><p>Page B</p><a href="/c">C</a></body></html>`)
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF"))
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%s/b</loc></url><url><loc>https://elsewhere.example/x</loc></url></urlset>`, srv.URL)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	ingested := map[string]string{}
+	s := NewCrawlService(NewIngestService(nil)).WithPrivateNetworks(true)
+	s.delay = 0
+	s.ingestPage = func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ingested[name] = string(content)
+		return &IngestResult{}, nil
+	}
+	wait := func(spec CrawlSpec) CrawlJob {
+		t.Helper()
+		job, err := s.Start(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if job, _ = s.Get(job.ID); job.State != CrawlRunning {
+				return job
+			}
+		}
+		t.Fatalf("crawl %s did not finish", job.ID)
+		return job
+	}
+
+	depth := 1
+	job := wait(CrawlSpec{URL: srv.URL + "/", Collection: "site", MaxDepth: &depth})
+	if job.State != CrawlFinished || job.Ingested != 2 || job.Skipped != 1 || job.Failed != 0 {
+		t.Errorf("unexpected job %+v", job)
+	}
+	names := make([]string, 0, len(ingested))
+	for name := range ingested {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != fmt.Sprint([]string{srv.URL + "/", srv.URL + "/a"}) {
+		t.Errorf("unexpected pages %v", names)
+	}
+	if got := ingested[srv.URL+"/"]; got != "Home\n\nWelcome\n\nA P E" {
+		t.Errorf("unexpected page text %q", got)
+	}
+
+	clear(ingested)
+	depth = 0
+	if job := wait(CrawlSpec{URL: srv.URL + "/sitemap.xml", Collection: "site", MaxDepth: &depth}); job.Ingested != 1 || ingested[srv.URL+"/b"] == "" {
+		t.Errorf("expected sitemap to seed only /b, got %+v %v", job, ingested)
+	}
+
+	if _, err := s.Start(context.Background(), CrawlSpec{URL: "ftp://x", Collection: "site"}); err == nil {
+		t.Error("expected invalid URL error")
+	}
+	if err := s.Cancel("missing"); err != ErrCrawlNotFound {
+		t.Errorf("expected ErrCrawlNotFound, got %v", err)
+	}
+	if jobs := s.List(); len(jobs) != 2 || jobs[0].URL != srv.URL+"/sitemap.xml" {
+		t.Errorf("expected newest crawl first, got %+v", jobs)
+	}
+}
+
+func TestCrawlPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><p>Internal</p></body></html>`)
+	}))
+	defer srv.Close()
+	s := NewCrawlService(NewIngestService(nil))
+	for _, u := range []string{srv.URL, "http://169.254.169.254/latest/meta-data/", "http://10.0.0.1/", "http://localhost/"} {
+		if _, err := s.Start(context.Background(), CrawlSpec{URL: u, Collection: "web"}); !errors.Is(err, ErrInvalidCrawl) || !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("expected %s refused, got %v", u, err)
+		}
+	}
+	if _, err := s.fetchPage(context.Background(), mustParseURL(t, srv.URL)); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected the crawler's client to refuse a loopback address, got %v", err)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParseRobots(t *testing.T) {
+	robots := parseRobots(`User-agent: *
+Disallow: /
+
+User-agent: forge-crawler
+Disallow: /admin
+Allow: /admin/public$
+Disallow: /*.json
+Crawl-delay: 2
+`, crawlUserAgent)
+	if robots.delay != 2*time.Second {
+		t.Errorf("expected 2s delay, got %v", robots.delay)
+	}
+	for path, want := range map[string]bool{
+		"/":                  true,
+		"/admin/users":       false,
+		"/admin/public":      true,
+		"/admin/public/more": false,
+		"/data/x.json":       false,
+	} {
+		u, _ := url.Parse("https://example.com" + path)
+		if got := robots.allowed(u); got != want {
+			t.Errorf("allowed(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if u, _ := url.Parse("https://example.com/x"); parseRobots("User-agent: *\nDisallow: /\n", crawlUserAgent).allowed(u) {
+		t.Error("expected wildcard group to apply")
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxSubQuestions caps how many sub-questions a question is split into, and
+// so how many searches one answer costs.
+const maxSubQuestions = 4
+
+// decomposePrompt asks for the retrieval queries a question needs, one per
+// line, or the question itself when it has a single part.
+const decomposePrompt = "Split the user's question into the simpler, self-contained sub-questions that must each be looked up to answer it, such as one per item being compared. Reply with one sub-question per line and nothing else, at most 4. If the question asks only one thing, reply with it unchanged."
+
+// listMarkerRe matches the bullets and numbering a model may put before a
+// sub-question.
+var listMarkerRe = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+
+// decompose has the generator split question into sub-questions. It returns
+// nil when the question has a single part.
+func (s *IngestService) decompose(ctx context.Context, collections []string, question string, params GenerationParams) ([]string, error) {
+	text, usage, err := s.generator.Generate(ctx, decomposePrompt, question, GenerationParams{Model: params.Model})
+	if err != nil {
+		return nil, fmt.Errorf("decompose question: %w", err)
+	}
+	s.costs.Record(ctx, strings.Join(collections, ","), CostGeneration, usage)
+	subs := parseSubQuestions(text)
+	if len(subs) < 2 {
+		return nil, nil
+	}
+	return subs, nil
+}
+
+// parseSubQuestions reads one sub-question per line, dropping list markers,
+// blanks and repeats.
+func parseSubQuestions(text string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		q := strings.TrimSpace(listMarkerRe.ReplaceAllString(line, ""))
+		if q == "" || seen[normalizeQuestion(q)] {
+			continue
+		}
+		seen[normalizeQuestion(q)] = true
+		out = append(out, q)
+		if len(out) == maxSubQuestions {
+			break
+		}
+	}
+	return out
+}
+
+// searchSubQuestions retrieves k results for each sub-question and merges
+// them round-robin, so every part of the question is represented in the
+// context, dropping chunks already found for an earlier one.
+func (s *IngestService) searchSubQuestions(ctx context.Context, collections []string, subs []string, k int, filter map[string]interface{}) (*SearchResponse, error) {
+	merged := &SearchResponse{}
+	lists := make([][]SearchResult, len(subs))
+	for i, q := range subs {
+		resp, err := s.MultiSearch(ctx, collections, q, k, filter, SearchOptions{})
+		if err != nil {
+			return nil, err
+		}
+		lists[i] = resp.Results
+		merged.Degraded = merged.Degraded || resp.Degraded
+		merged.Replica = merged.Replica || resp.Replica
+	}
+	seen := make(map[string]bool)
+	for rank := 0; rank < k; rank++ {
+		for _, results := range lists {
+			if rank >= len(results) {
+				continue
+			}
+			r := results[rank]
+			if id := r.Collection + "\x00" + r.ID; !seen[id] {
+				seen[id] = true
+				merged.Results = append(merged.Results, r)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// decomposedPrompt is answerPrompt with the sub-questions the sources were
+// retrieved for, so the answer addresses each of them.
+func decomposedPrompt(question string, subs []string, results []SearchResult) string {
+	var b strings.Builder
+	b.WriteString(answerPrompt(question, results))
+	b.WriteString("\n\nAddress each part:")
+	for _, q := range subs {
+		b.WriteString("\n- ")
+		b.WriteString(q)
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+// planCollection returns the files listed for the query text it is searched with.
+type planCollection struct {
+	chroma.Collection
+	hits map[string][]string
+}
+
+func (c *planCollection) Name() string { return "plans" }
+
+func (c *planCollection) Metadata() chroma.CollectionMetadata { return nil }
+
+func (c *planCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	op, err := chroma.NewCollectionQueryOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := &chroma.QueryResultImpl{IDLists: []chroma.DocumentIDs{nil}, DocumentsLists: []chroma.Documents{nil},
+		MetadatasLists: []chroma.DocumentMetadatas{nil}, DistancesLists: []embeddings.Distances{nil}}
+	for i, file := range c.hits[op.QueryTexts[0]] {
+		res.IDLists[0] = append(res.IDLists[0], chroma.DocumentID(file))
+		res.DocumentsLists[0] = append(res.DocumentsLists[0], chroma.NewTextDocument(file))
+		res.MetadatasLists[0] = append(res.MetadatasLists[0], chroma.NewDocumentMetadata(chroma.NewStringAttribute("file_name", file)))
+		res.DistancesLists[0] = append(res.DistancesLists[0], embeddings.Distance(0.1*float64(i+1)))
+	}
+	return res, nil
+}
+
+type planClient struct {
+	chroma.Client
+	plans *planCollection
+}
+
+func (c planClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.plans, nil
+}
+
+// plannerGenerator splits questions by its script and otherwise answers.
+type plannerGenerator struct {
+	countingGenerator
+	split string
+}
+
+func (g *plannerGenerator) Generate(ctx context.Context, system, prompt string, params GenerationParams) (string, TokenUsage, error) {
+	text, usage, err := g.countingGenerator.Generate(ctx, system, prompt, params)
+	if system == decomposePrompt {
+		return g.split, usage, err
+	}
+	return text, usage, err
+}
+
+func TestAnswerDecompose(t *testing.T) {
+	plans := &planCollection{hits: map[string][]string{
+		"Compare free and pro":  {"overview.md"},
+		"What does free offer?": {"free.md", "pricing.md"},
+		"What does pro offer?":  {"pro.md", "pricing.md"},
+	}}
+	gen := &plannerGenerator{split: "1. What does free offer?\n2) What does pro offer?\n- what does free offer\n"}
+	s := NewIngestService(planClient{plans: plans}).WithGenerator(gen, "")
+	ask := func(opts AnswerOptions) *AnswerResponse {
+		t.Helper()
+		resp, err := s.Answer(context.Background(), []string{"plans"}, "Compare free and pro", 2, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	files := func(resp *AnswerResponse) []string {
+		var out []string
+		for _, r := range resp.Sources {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	if resp := ask(AnswerOptions{}); !reflect.DeepEqual(files(resp), []string{"overview.md"}) || resp.SubQuestions != nil || gen.calls != 1 {
+		t.Errorf("expected a plain answer without decompose, got %+v after %d calls", resp, gen.calls)
+	}
+
+	resp := ask(AnswerOptions{Decompose: true, GenerationParams: GenerationParams{Model: "large", MaxTokens: 50}})
+	if want := []string{"What does free offer?", "What does pro offer?"}; !reflect.DeepEqual(resp.SubQuestions, want) {
+		t.Errorf("expected sub-questions %v, got %v", want, resp.SubQuestions)
+	}
+	if want := []string{"free.md", "pro.md", "pricing.md"}; !reflect.DeepEqual(files(resp), want) {
+		t.Errorf("expected merged sources %v, got %v", want, files(resp))
+	}
+	if gen.calls != 3 || gen.params[1].Model != "large" || gen.params[1].MaxTokens != 0 {
+		t.Errorf("expected a decompose call with the model only, got %d calls %+v", gen.calls, gen.params)
+	}
+	if p := gen.prompts[2]; !strings.Contains(p, "Question: Compare free and pro") || !strings.Contains(p, "- What does pro offer?") {
+		t.Errorf("prompt lacks question or parts: %q", p)
+	}
+
+	// A single-part question is answered from its own search
+	gen.split = "Compare free and pro"
+	if resp := ask(AnswerOptions{Decompose: true}); resp.SubQuestions != nil || !reflect.DeepEqual(files(resp), []string{"overview.md"}) {
+		t.Errorf("expected no decomposition of a single part, got %+v", resp)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// nearDuplicateJaccard is the word-shingle similarity above which two
+// chunks are considered the same text.
+const nearDuplicateJaccard = 0.9
+
+// dedupeResults drops results whose text duplicates a better-scoring one.
+// Results must be ordered best-first, as returned by Chroma.
+func dedupeResults(results []SearchResult) []SearchResult {
+	var out []SearchResult
+	var keptShingles []map[string]bool
+	seen := make(map[[32]byte]bool)
+	for _, r := range results {
+		tokens := Tokens(r.Document)
+		h := sha256.Sum256([]byte(strings.Join(tokens, " ")))
+		if seen[h] {
+			continue
+		}
+		sh := shingles(tokens, 3)
+		dup := false
+		for _, other := range keptShingles {
+			if jaccard(sh, other) >= nearDuplicateJaccard {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		seen[h] = true
+		keptShingles = append(keptShingles, sh)
+		out = append(out, r)
+	}
+	return out
+}
+
+// shingles returns the set of n-word shingles; short texts yield one shingle.
+func shingles(tokens []string, n int) map[string]bool {
+	set := make(map[string]bool)
+	if len(tokens) <= n {
+		set[strings.Join(tokens, " ")] = true
+		return set
+	}
+	for i := 0; i+n <= len(tokens); i++ {
+		set[strings.Join(tokens[i:i+n], " ")] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inter := 0
+	for s := range a {
+		if b[s] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
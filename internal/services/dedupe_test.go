@@ -0,0 +1,21 @@
+package services
+
+import "testing"
+
+func TestDedupeResults(t *testing.T) {
+	results := []SearchResult{
+		{ID: "a", Document: "Install Forge with go install ./cmd", Distance: 0.1},
+		{ID: "b", Document: "install forge with GO install ./cmd\n", Distance: 0.2},
+		{ID: "c", Document: "Configure the Chroma URL before starting the backend server today", Distance: 0.3},
+		{ID: "d", Document: "Configure the Chroma URL before starting the backend server today!", Distance: 0.4},
+		{ID: "e", Document: "A completely different chunk", Distance: 0.5},
+	}
+	got := dedupeResults(results)
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) != 3 || ids[0] != "a" || ids[1] != "c" || ids[2] != "e" {
+		t.Errorf("dedupeResults() ids = %v, want [a c e]", ids)
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Load shedding. When degradation is enabled and a vector search (Chroma
+// plus its embedder) does not answer within the budget or fails, search is
+// served from the result cache, or failing that from a lexical scan of the
+// collection, and the response is flagged degraded instead of hanging.
+const (
+	searchCacheSize = 256
+	// lexicalScanLimit bounds how many records a lexical fallback scores.
+	lexicalScanLimit = 2000
+
+	DegradedCached  = "cached"
+	DegradedLexical = "lexical"
+)
+
+// errNoLexicalTerms is returned by lexicalSearch for a query the analyzer
+// reduces to nothing, e.g. one made only of stopwords.
+var errNoLexicalTerms = errors.New("query has no lexical terms")
+
+// SearchResponse is a search result set plus degradation details.
+type SearchResponse struct {
+	Results        []SearchResult `json:"results"`
+	Degraded       bool           `json:"degraded,omitempty"`
+	DegradedReason string         `json:"degraded_reason,omitempty"`
+	// Strategy is set when the results came from another search than the
+	// vector search, e.g. StrategyLexical for short queries.
+	Strategy string `json:"strategy,omitempty"`
+	// Replica is set when the primary was down and a collection's warm
+	// replica answered instead (see WithReplica).
+	Replica bool `json:"replica,omitempty"`
+}
+
+// WithDegradation enables load shedding: vector searches slower than after
+// fall back to cached or lexical results. Zero disables it.
+func (s *IngestService) WithDegradation(after time.Duration) *IngestService {
+	s.degradeAfter = after
+	if after > 0 && s.cache == nil {
+		s.cache = newSearchCache(searchCacheSize)
+		// Cached results only go stale by omission on ingest, but must not
+		// resurface deleted records.
+		s.events.Subscribe(func(e Event) { s.cache.dropCollection(e.Collection) }, EventDeleted)
+	}
+	return s
+}
+
+// SearchWithFallback runs SearchWithOptions, degrading to cached or lexical
+// results when the vector search is too slow or fails.
+func (s *IngestService) SearchWithFallback(ctx context.Context, collectionName, query string, k int, filter map[string]interface{}, opts SearchOptions) (*SearchResponse, error) {
+	ctx, fromReplica := withReplicaMark(ctx)
+	if resp, err := s.searchShortQuery(ctx, collectionName, query, k, filter, opts.Exclude); resp != nil || err != nil {
+		if resp != nil {
+			resp.Replica = fromReplica.Load()
+		}
+		return resp, err
+	}
+	if s.degradeAfter <= 0 {
+		results, err := s.SearchWithOptions(ctx, collectionName, query, k, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &SearchResponse{Results: results, Replica: fromReplica.Load()}, nil
+	}
+
+	key := searchCacheKey(ctx, collectionName, query, k, filter, opts)
+	vctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type outcome struct {
+		results []SearchResult
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		results, err := s.SearchWithOptions(vctx, collectionName, query, k, filter, opts)
+		done <- outcome{results, err}
+	}()
+
+	timer := time.NewTimer(s.degradeAfter)
+	defer timer.Stop()
+	var cause error
+	select {
+	case out := <-done:
+		if out.err == nil {
+			s.cache.put(key, out.results)
+			return &SearchResponse{Results: out.results, Replica: fromReplica.Load()}, nil
+		}
+		if errors.Is(out.err, ErrDimensionMismatch) {
+			// A model mismatch won't clear up; surface it instead of masking it
+			return nil, out.err
+		}
+		cause = out.err
+	case <-timer.C:
+		cause = fmt.Errorf("vector search exceeded %s: %w", s.degradeAfter, context.DeadlineExceeded)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// Shed the slow leg rather than letting it pile up behind the fallback
+	cancel()
+
+	log := logging.FromContext(ctx).WithError(cause).WithField("collection", collectionName)
+	if cached, ok := s.cache.get(key); ok {
+		log.Warn("Serving cached search results")
+		return &SearchResponse{Results: cached, Degraded: true, DegradedReason: DegradedCached}, nil
+	}
+	lctx, lcancel := context.WithTimeout(ctx, s.degradeAfter)
+	defer lcancel()
+	results, err := s.lexicalSearch(lctx, collectionName, query, k, filter, opts.Exclude)
+	if err != nil {
+		log.WithFields(logrus.Fields{"fallback_error": err.Error()}).Error("Search degraded fallback failed")
+		return nil, cause
+	}
+	log.Warn("Serving lexical-only search results")
+	return &SearchResponse{Results: results, Degraded: true, DegradedReason: DegradedLexical, Replica: fromReplica.Load()}, nil
+}
+
+// lexicalSearch scores up to lexicalScanLimit records by analyzed term
+// overlap with the query. It needs no embedding call.
+func (s *IngestService) lexicalSearch(ctx context.Context, collectionName, query string, k int, filter map[string]interface{}, exclude Exclusion) ([]SearchResult, error) {
+	settings, err := s.CollectionAnalyzer(collectionName)
+	if err != nil {
+		settings = DefaultAnalyzerSettings
+	}
+	analyzer := NewAnalyzer(settings)
+	terms := analyzer.Analyze(query)
+	if len(terms) == 0 {
+		return nil, errNoLexicalTerms
+	}
+
+	collection, err := s.searchCollection(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := exclude.clauses(s.keys)
+	if err != nil {
+		return nil, err
+	}
+	clauses := append(filterClauses(filter), aclWhere(PrincipalsFromContext(ctx)))
+	clauses = append(clauses, excluded...)
+	res, err := collection.Get(ctx,
+		chroma.WithWhereGet(andWhere(clauses)),
+		chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas),
+		chroma.WithLimitGet(lexicalScanLimit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return exclude.dropIDs(rankLexical(analyzer, terms, toRecords(res), k+len(exclude.IDs))), nil
+}
+
+// rankLexical orders records by the fraction of query terms they contain,
+// reported as a distance (0 = every term matched).
+func rankLexical(analyzer *Analyzer, terms []string, records []Record, k int) []SearchResult {
+	var results []SearchResult
+	for _, r := range records {
+		have := make(map[string]bool)
+		for _, t := range analyzer.Analyze(r.Document) {
+			have[t] = true
+		}
+		matched := 0
+		for _, t := range terms {
+			if have[t] {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		fraction := float32(matched) / float32(len(terms))
+		title, _ := r.Metadata[titleKey].(string)
+		results = append(results, SearchResult{
+			ID:        r.ID,
+			Document:  r.Document,
+			Metadata:  r.Metadata,
+			Distance:  1 - fraction,
+			Relevance: fraction,
+			Title:     title,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func searchCacheKey(ctx context.Context, collection, query string, k int, filter map[string]interface{}, opts SearchOptions) string {
+	f, _ := json.Marshal(filter) // map keys are sorted
+	x, _ := json.Marshal(opts.Exclude)
+	principals := append([]string(nil), PrincipalsFromContext(ctx)...)
+	sort.Strings(principals)
+	return strings.Join([]string{collection, query, fmt.Sprint(k), string(f), fmt.Sprint(opts.Dedupe), string(x), strings.Join(principals, ",")}, "\x00")
+}
+
+// searchCache is a small LRU of recent successful search results.
+type searchCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type searchCacheEntry struct {
+	key     string
+	results []SearchResult
+}
+
+func newSearchCache(size int) *searchCache {
+	return &searchCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// dropCollection removes every cached result for a collection.
+func (c *searchCache) dropCollection(collection string) {
+	prefix := collection + "\x00"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+func (c *searchCache) get(key string) ([]SearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*searchCacheEntry).results, true
+}
+
+func (c *searchCache) put(key string, results []SearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*searchCacheEntry).results = results
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&searchCacheEntry{key: key, results: results})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*searchCacheEntry).key)
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import "testing"
+
+func TestSearchCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newSearchCache(2)
+	c.put("a", []SearchResult{{ID: "a"}})
+	c.put("b", []SearchResult{{ID: "b"}})
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.put("c", []SearchResult{{ID: "c"}})
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected a to survive eviction")
+	}
+}
+
+func TestRankLexical(t *testing.T) {
+	a := NewAnalyzer(DefaultAnalyzerSettings)
+	records := []Record{
+		{ID: "1", Document: "Cats and dogs"},
+		{ID: "2", Document: "Running dogs chase running cats"},
+		{ID: "3", Document: "Nothing relevant here"},
+	}
+	got := rankLexical(a, a.Analyze("running cats"), records, 5)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(got))
+	}
+	if got[0].ID != "2" || got[0].Distance != 0 {
+		t.Fatalf("expected full match first, got %+v", got[0])
+	}
+	if got[1].ID != "1" || got[1].Distance != 0.5 {
+		t.Fatalf("expected half match second, got %+v", got[1])
+	}
+}
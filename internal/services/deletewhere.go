@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// ErrEmptyDeleteFilter is returned for a filtered delete without a filter,
+// which would match every chunk.
+var ErrEmptyDeleteFilter = errors.New("a delete filter needs at least one key")
+
+// DeleteWhere deletes every chunk of a collection whose metadata equals the
+// filter's values and returns how many it deleted. Whole numbers compare
+// as ints, matching how chunk metadata stores them. Protected collections
+// require force and an admin caller.
+func (s *IngestService) DeleteWhere(ctx context.Context, collectionName string, filter map[string]interface{}, force bool) (int, error) {
+	if len(filter) == 0 {
+		return 0, ErrEmptyDeleteFilter
+	}
+	if err := s.checkDestructive(ctx, collectionName, force); err != nil {
+		return 0, err
+	}
+	where := andWhere(filterClauses(wholeNumbersAsInts(filter)))
+	return s.deleteMatching(ctx, collectionName, where, map[string]interface{}{"filter": filter})
+}
+
+// DeleteFile deletes every chunk of the file with the given content hash
+// and returns how many it deleted, or ErrFileNotFound when the collection
+// holds none.
+func (s *IngestService) DeleteFile(ctx context.Context, collectionName, md5Hash string) (int, error) {
+	if IsSnapshotCollection(collectionName) {
+		return 0, ErrSnapshotReadOnly
+	}
+	n, err := s.deleteMatching(ctx, collectionName, chroma.EqString(s.keys.FileMD5, md5Hash), map[string]interface{}{"file_md5": md5Hash})
+	if err == nil && n == 0 {
+		err = fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, md5Hash)
+	}
+	return n, err
+}
+
+// deleteMatching deletes the chunks matching where, announcing the delete
+// with the given event data.
+func (s *IngestService) deleteMatching(ctx context.Context, collectionName string, where chroma.WhereClause, data map[string]interface{}) (int, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return 0, fmt.Errorf("get collection %q: %w", collectionName, err)
+	}
+	// The matching IDs are logged so an interrupted delete can be reconciled
+	records, err := scanRecords(ctx, collection, where, chroma.IncludeMetadatas)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: collectionName, Op: IntentDelete, IDs: ids})
+	if err != nil {
+		return 0, err
+	}
+	err = collection.Delete(ctx, chroma.WithWhereDelete(where))
+	finish(err)
+	if err != nil {
+		return 0, err
+	}
+	s.forgetIndexedRecords(ctx, collectionName, records)
+	data["deleted"] = len(ids)
+	s.publishChange(EventDeleted, collectionName, data)
+	return len(ids), nil
+}
+
+func wholeNumbersAsInts(filter map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(filter))
+	for k, v := range filter {
+		if f, ok := v.(float64); ok && f == math.Trunc(f) {
+			v = int(f)
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// whereCollection holds the records a filter matches and records the
+// filter chunks are deleted with.
+type whereCollection struct {
+	chroma.Collection
+	ids     []string
+	deleted string
+}
+
+func (c *whereCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := &chroma.GetResultImpl{}
+	if op.Offset > 0 {
+		return res, nil
+	}
+	for _, id := range c.ids {
+		res.Ids = append(res.Ids, chroma.DocumentID(id))
+		res.Metadatas = append(res.Metadatas, chroma.NewDocumentMetadata())
+	}
+	return res, nil
+}
+
+func (c *whereCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		return err
+	}
+	where, err := op.Where.MarshalJSON()
+	c.deleted, c.ids = string(where), nil
+	return err
+}
+
+type whereClient struct {
+	chroma.Client
+	collection *whereCollection
+}
+
+func (c whereClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestDeleteWhere(t *testing.T) {
+	ctx := context.Background()
+	col := &whereCollection{ids: []string{"a", "b", "c"}}
+	intents := &memIntents{}
+	s := NewIngestService(whereClient{collection: col}).WithSettings(memSettings{}).WithIntentLog(intents)
+
+	if _, err := s.DeleteWhere(ctx, "docs", nil, false); !errors.Is(err, ErrEmptyDeleteFilter) {
+		t.Errorf("expected an empty filter to be refused, got %v", err)
+	}
+	n, err := s.DeleteWhere(ctx, "docs", map[string]interface{}{"chunk_index": float64(2)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || col.deleted != `{"chunk_index":{"$eq":2}}` {
+		t.Errorf("deleted %d with %s", n, col.deleted)
+	}
+	if len(intents.intents) != 1 || len(intents.intents[0].IDs) != 3 {
+		t.Errorf("expected the matched IDs to be logged, got %+v", intents.intents)
+	}
+	if n, err := s.DeleteWhere(ctx, "docs", map[string]interface{}{"user_project": "x"}, false); err != nil || n != 0 {
+		t.Errorf("expected nothing left to delete, got %d, %v", n, err)
+	}
+
+	if err := s.SetProtected(WithAdmin(ctx), "docs", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteWhere(ctx, "docs", map[string]interface{}{"user_project": "x"}, false); !errors.Is(err, ErrCollectionProtected) {
+		t.Errorf("expected a protected collection to need force, got %v", err)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	col := &whereCollection{ids: []string{"a", "b"}}
+	s := NewIngestService(whereClient{collection: col})
+	n, err := s.DeleteFile(ctx, "docs", "9e10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || col.deleted != `{"file_md5":{"$eq":"9e10"}}` {
+		t.Errorf("deleted %d with %s", n, col.deleted)
+	}
+	if _, err := s.DeleteFile(ctx, "docs", "9e10"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound once the file is gone, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// derivedFromKey marks records written into a derived collection.
+const derivedFromKey = "derived_from"
+
+// DerivedStore persists derived collection definitions.
+type DerivedStore interface {
+	SaveDerived(d config.DerivedCollection) error
+	GetDerived(name string) (config.DerivedCollection, error)
+	ListDerived() ([]config.DerivedCollection, error)
+	DeleteDerived(name string) error
+	RecordDerivedSync(name string, at time.Time) error
+}
+
+// DerivedSync reports the outcome of syncing a derived collection.
+type DerivedSync struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Written int    `json:"written"`
+	Removed int    `json:"removed"`
+}
+
+// DerivedService maintains collections derived from a filter and text
+// transforms over a source collection. Views are re-synced whenever their
+// source changes through the IngestService, and chains of views cascade.
+type DerivedService struct {
+	chromaDB chroma.Client
+	store    DerivedStore
+	costs    *CostTracker
+	mu       sync.Mutex // serializes syncs
+}
+
+func NewDerivedService(chromaDB chroma.Client, store DerivedStore) *DerivedService {
+	return &DerivedService{chromaDB: chromaDB, store: store}
+}
+
+// WithCostTracker records the embeddings of transformed views.
+func (s *DerivedService) WithCostTracker(t *CostTracker) *DerivedService {
+	s.costs = t
+	return s
+}
+
+// Watch subscribes to source changes so views stay in sync automatically.
+func (s *DerivedService) Watch(ingest *IngestService) {
+	ingest.OnCollectionChanged(func(collection string) {
+		go s.syncDependents(context.Background(), collection)
+	})
+}
+
+// Define validates and stores a derived collection, then performs an initial sync.
+func (s *DerivedService) Define(ctx context.Context, d config.DerivedCollection) (*DerivedSync, error) {
+	if d.Name == "" || d.Source == "" {
+		return nil, errors.New("name and source are required")
+	}
+	for _, t := range d.Transforms {
+		if _, ok := pipelineTransforms[t]; !ok {
+			return nil, fmt.Errorf("unknown transform %q", t)
+		}
+	}
+	if err := s.checkCycle(d.Name, d.Source); err != nil {
+		return nil, err
+	}
+	if err := s.store.SaveDerived(d); err != nil {
+		return nil, err
+	}
+	return s.Sync(ctx, d.Name)
+}
+
+// checkCycle rejects definitions whose source chain leads back to name.
+func (s *DerivedService) checkCycle(name, source string) error {
+	seen := map[string]bool{name: true}
+	for cur := source; cur != ""; {
+		if seen[cur] {
+			return fmt.Errorf("derived collection %q would form a cycle via %q", name, cur)
+		}
+		seen[cur] = true
+		parent, err := s.store.GetDerived(cur)
+		if errors.Is(err, config.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cur = parent.Source
+	}
+	return nil
+}
+
+func (s *DerivedService) List() ([]config.DerivedCollection, error) {
+	return s.store.ListDerived()
+}
+
+// Delete removes a view definition; the derived collection itself is kept.
+func (s *DerivedService) Delete(name string) error {
+	return s.store.DeleteDerived(name)
+}
+
+// Sync rebuilds a derived collection from its source, upserting matching
+// records and removing ones that no longer match, then cascades to views
+// derived from it.
+func (s *DerivedService) Sync(ctx context.Context, name string) (*DerivedSync, error) {
+	d, err := s.store.GetDerived(name)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.syncOne(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	s.syncDependents(ctx, name)
+	return result, nil
+}
+
+func (s *DerivedService) syncOne(ctx context.Context, d config.DerivedCollection) (*DerivedSync, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, err := s.chromaDB.GetCollection(ctx, d.Source)
+	if err != nil {
+		return nil, fmt.Errorf("get source collection %q: %w", d.Source, err)
+	}
+	include := []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas}
+	if len(d.Transforms) == 0 {
+		// Unchanged text: reuse the source embeddings instead of re-embedding
+		include = append(include, chroma.IncludeEmbeddings)
+	}
+	records, err := scanRecords(ctx, source, andWhere(filterClauses(d.Filter)), include...)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(records))
+	for i := range records {
+		for _, t := range d.Transforms {
+			records[i].Document = pipelineTransforms[t](records[i].Document)
+		}
+		if records[i].Metadata == nil {
+			records[i].Metadata = make(map[string]interface{})
+		}
+		records[i].Metadata[derivedFromKey] = d.Source
+		keep[records[i].ID] = true
+	}
+
+	target, err := s.chromaDB.GetOrCreateCollection(ctx, d.Name)
+	if err != nil {
+		return nil, fmt.Errorf("get/create derived collection %q: %w", d.Name, err)
+	}
+	if err := checkDimensions(target, records); err != nil {
+		return nil, err
+	}
+	if err := writeRecords(ctx, target.Upsert, records); err != nil {
+		return nil, dimensionError(d.Name, err)
+	}
+	if len(d.Transforms) > 0 {
+		texts := make([]string, len(records))
+		for i, r := range records {
+			texts[i] = r.Document
+		}
+		s.costs.embedded(ctx, d.Name, texts...)
+	}
+
+	existing, err := scanRecords(ctx, target, nil, chroma.IncludeMetadatas)
+	if err != nil {
+		return nil, err
+	}
+	var stale []chroma.DocumentID
+	for _, r := range existing {
+		if !keep[r.ID] {
+			stale = append(stale, chroma.DocumentID(r.ID))
+		}
+	}
+	for start := 0; start < len(stale); start += getPageSize {
+		end := min(start+getPageSize, len(stale))
+		if err := target.Delete(ctx, chroma.WithIDsDelete(stale[start:end]...)); err != nil {
+			return nil, fmt.Errorf("delete stale records from %q: %w", d.Name, err)
+		}
+	}
+
+	if err := s.store.RecordDerivedSync(d.Name, time.Now()); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("derived", d.Name).Warn("Failed to record derived sync")
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"derived": d.Name,
+		"source":  d.Source,
+		"written": len(records),
+		"removed": len(stale),
+	}).Info("Synced derived collection")
+	return &DerivedSync{Name: d.Name, Source: d.Source, Written: len(records), Removed: len(stale)}, nil
+}
+
+// syncDependents re-syncs every view whose source is collection.
+func (s *DerivedService) syncDependents(ctx context.Context, collection string) {
+	views, err := s.store.ListDerived()
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Failed to list derived collections")
+		return
+	}
+	for _, v := range views {
+		if v.Source != collection {
+			continue
+		}
+		if _, err := s.Sync(ctx, v.Name); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("derived", v.Name).Error("Failed to sync derived collection")
+		}
+	}
+}
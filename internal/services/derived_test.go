@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func (c *memCollection) Dimension() int { return 0 }
+
+func (c *memCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		return err
+	}
+	for _, id := range op.Ids {
+		delete(c.docs, string(id))
+	}
+	return nil
+}
+
+func (c *memClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	if col, ok := c.collections[name]; ok {
+		return col, nil
+	}
+	return c.CreateCollection(ctx, name)
+}
+
+// memDerived is an in-memory DerivedStore.
+type memDerived map[string]config.DerivedCollection
+
+func (m memDerived) SaveDerived(d config.DerivedCollection) error {
+	m[d.Name] = d
+	return nil
+}
+
+func (m memDerived) GetDerived(name string) (config.DerivedCollection, error) {
+	d, ok := m[name]
+	if !ok {
+		return config.DerivedCollection{}, config.ErrNotFound
+	}
+	return d, nil
+}
+
+func (m memDerived) ListDerived() ([]config.DerivedCollection, error) {
+	var out []config.DerivedCollection
+	for _, d := range m {
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (m memDerived) DeleteDerived(name string) error {
+	delete(m, name)
+	return nil
+}
+
+func (m memDerived) RecordDerivedSync(name string, at time.Time) error {
+	d := m[name]
+	d.LastSyncAt = at
+	m[name] = d
+	return nil
+}
+
+func TestDerivedCollections(t *testing.T) {
+	ctx := context.Background()
+	docs := &memCollection{name: "docs", docs: map[string]string{"a": "  Alpha <b>Beta</b>  ", "b": "GAMMA"}}
+	client := &memClient{collections: map[string]*memCollection{"docs": docs}}
+	store := memDerived{}
+	s := NewDerivedService(client, store)
+
+	if _, err := s.Define(ctx, config.DerivedCollection{Name: "clean", Source: "docs", Transforms: []string{"shout"}}); err == nil {
+		t.Error("expected an unknown transform to be rejected")
+	}
+	sync, err := s.Define(ctx, config.DerivedCollection{Name: "clean", Source: "docs", Transforms: []string{"strip_html", "collapse_whitespace", "trim", "lowercase"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sync.Written != 2 || sync.Removed != 0 {
+		t.Errorf("unexpected sync %+v", sync)
+	}
+	if want := map[string]string{"a": "alpha beta", "b": "gamma"}; !reflect.DeepEqual(client.collections["clean"].docs, want) {
+		t.Errorf("expected the transformed text, got %q", client.collections["clean"].docs)
+	}
+	if store["clean"].LastSyncAt.IsZero() {
+		t.Error("expected the sync to be recorded")
+	}
+
+	// A view of the view is synced along with it
+	if _, err := s.Define(ctx, config.DerivedCollection{Name: "copy", Source: "clean"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Define(ctx, config.DerivedCollection{Name: "clean", Source: "copy"}); err == nil {
+		t.Error("expected a cycle to be rejected")
+	}
+
+	delete(docs.docs, "b")
+	sync, err = s.Sync(ctx, "clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sync.Written != 1 || sync.Removed != 1 {
+		t.Errorf("expected the record gone from the source removed, got %+v", sync)
+	}
+	if want := map[string]string{"a": "alpha beta"}; !reflect.DeepEqual(client.collections["copy"].docs, want) {
+		t.Errorf("expected the dependent view synced, got %q", client.collections["copy"].docs)
+	}
+}
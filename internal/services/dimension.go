@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// ErrDimensionMismatch is returned when embeddings do not have the
+// dimension a collection was created with, typically because the embedding
+// model changed since the collection was first written.
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// chromaDimensionRe matches Chroma's server-side rejection, e.g.
+// "Collection expecting embedding with dimension of 384, got 1536".
+var chromaDimensionRe = regexp.MustCompile(`(?i)dimension of (\d+), got (\d+)`)
+
+func dimensionMismatch(collection string, want, got int) error {
+	return fmt.Errorf("%w: collection %q holds %d-dimensional embeddings but got %d; "+
+		"use the embedding model the collection was built with, or re-ingest into a new collection with the current model",
+		ErrDimensionMismatch, collection, want, got)
+}
+
+// checkDimensions rejects embeddings that do not match the collection's
+// dimension before they are sent. Empty collections accept any dimension.
+func checkDimensions(collection chroma.Collection, records []Record) error {
+	want := collection.Dimension()
+	if want <= 0 {
+		return nil
+	}
+	for _, r := range records {
+		if got := len(r.Embedding); got > 0 && got != want {
+			return dimensionMismatch(collection.Name(), want, got)
+		}
+	}
+	return nil
+}
+
+// dimensionError turns Chroma's dimension rejection into ErrDimensionMismatch
+// and returns any other error unchanged.
+func dimensionError(collection string, err error) error {
+	if err == nil || errors.Is(err, ErrDimensionMismatch) {
+		return err
+	}
+	m := chromaDimensionRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	want, _ := strconv.Atoi(m[1])
+	got, _ := strconv.Atoi(m[2])
+	return dimensionMismatch(collection, want, got)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+type dimCollection struct {
+	chroma.Collection
+	dim int
+}
+
+func (c dimCollection) Name() string   { return "docs" }
+func (c dimCollection) Dimension() int { return c.dim }
+
+func TestDimensionError(t *testing.T) {
+	err := dimensionError("docs", errors.New("error sending request: InvalidArgumentError: Collection expecting embedding with dimension of 384, got 1536"))
+	if !errors.Is(err, ErrDimensionMismatch) || !strings.Contains(err.Error(), "384-dimensional embeddings but got 1536") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	other := errors.New("connection refused")
+	if got := dimensionError("docs", other); got != other {
+		t.Errorf("unrelated error rewritten: %v", got)
+	}
+}
+
+func TestCheckDimensions(t *testing.T) {
+	records := []Record{{ID: "a", Embedding: []float32{1, 2, 3}}, {ID: "b"}}
+	if err := checkDimensions(dimCollection{dim: 3}, records); err != nil {
+		t.Errorf("matching dimension rejected: %v", err)
+	}
+	if err := checkDimensions(dimCollection{}, records); err != nil {
+		t.Errorf("empty collection rejected embeddings: %v", err)
+	}
+	if err := checkDimensions(dimCollection{dim: 4}, records); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package services
+
+import "errors"
+
+func freeDiskBytes(string) (uint64, error) {
+	return 0, errors.New("not supported on this platform")
+}
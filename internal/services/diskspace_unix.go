@@ -0,0 +1,15 @@
+//go:build unix
+
+package services
+
+import "golang.org/x/sys/unix"
+
+// freeDiskBytes reports the space available to unprivileged users on the
+// filesystem holding path.
+func freeDiskBytes(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// titleKey records a document's title on each of its chunks.
+const titleKey = "title"
+
+// maxTitleLen bounds a stored title in bytes.
+const maxTitleLen = 256
+
+// documentMetaTitle reads the title a file declares for itself, by lower-case
+// file extension.
+var documentMetaTitle = map[string]func(content []byte) string{
+	".pdf":   pdfTitle,
+	".html":  htmlTitle,
+	".htm":   htmlTitle,
+	".xhtml": htmlTitle,
+}
+
+// extractTitle derives a human-readable title for a document: the title it
+// declares (PDF document information, HTML <title>, Markdown front matter),
+// else its first top-level heading. It returns "" when there is neither.
+func extractTitle(filePath string, content []byte, sections []docSection) string {
+	var title string
+	if read, ok := documentMetaTitle[strings.ToLower(path.Ext(filePath))]; ok {
+		title = read(content)
+	}
+	if title == "" && len(sections) > 0 {
+		title, _ = sections[0].metadata[userMetadataPrefix+"title"].(string)
+	}
+	if title == "" {
+		title, _, _ = strings.Cut(firstHeading(sections), " > ")
+	}
+	return clipTitle(title)
+}
+
+// clipTitle collapses whitespace and cuts a title to maxTitleLen bytes on a
+// rune boundary.
+func clipTitle(title string) string {
+	title = collapseSpaces(title)
+	if len(title) <= maxTitleLen {
+		return title
+	}
+	cut := maxTitleLen
+	for cut > 0 && !utf8.RuneStart(title[cut]) {
+		cut--
+	}
+	return title[:cut]
+}
+
+var (
+	pdfInfoRefRe = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	pdfTitleRe   = regexp.MustCompile(`/Title\s*([(<])`)
+)
+
+// pdfTitle reads /Title from a PDF's document information dictionary. The
+// dictionary must be a plain object: PDFs that keep it in a compressed
+// object stream yield no title.
+func pdfTitle(content []byte) string {
+	refs := pdfInfoRefRe.FindAllSubmatch(content, -1)
+	if len(refs) == 0 {
+		return ""
+	}
+	// Incremental updates append trailers; the last one is current
+	ref := refs[len(refs)-1]
+	header := regexp.MustCompile(`(?:^|\s)` + string(ref[1]) + `\s+` + string(ref[2]) + `\s+obj\b`)
+	loc := header.FindIndex(content)
+	if loc == nil {
+		return ""
+	}
+	obj := content[loc[1]:]
+	if end := bytes.Index(obj, []byte("endobj")); end >= 0 {
+		obj = obj[:end]
+	}
+	m := pdfTitleRe.FindSubmatchIndex(obj)
+	if m == nil {
+		return ""
+	}
+	start := m[2]
+	if obj[start] == '<' {
+		end := bytes.IndexByte(obj[start:], '>')
+		if end < 0 {
+			return ""
+		}
+		return pdfTextString(obj[start+1 : start+end])
+	}
+	title, _ := pdfLiteralString(obj, start)
+	// Literal UTF-16 strings decode to their BOM, shown as "þÿ", and their
+	// ASCII characters
+	return strings.TrimPrefix(title, "þÿ")
+}
+
+// pdfTextString decodes a hex text string, which is UTF-16BE when it starts
+// with a byte order mark and PDFDocEncoding (read as Latin-1) otherwise.
+func pdfTextString(digits []byte) string {
+	digits = bytes.Join(bytes.Fields(digits), nil)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	raw := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(raw, digits); err != nil {
+		return ""
+	}
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	return pdfPrintable(string(raw))
+}
+
+// htmlTitle returns the text of an HTML document's <title>.
+func htmlTitle(content []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				var b strings.Builder
+				for z.Next() == html.TextToken {
+					b.Write(z.Text())
+				}
+				return b.String()
+			case "body":
+				return ""
+			}
+		}
+	}
+}
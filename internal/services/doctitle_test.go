@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func TestExtractTitle(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj << /Type /Outlines /Title (Bookmark) >> endobj\n" +
+		"4 0 obj << /Title (Annual \\(2026\\) Report) /Author (Ops) >> endobj\n" +
+		"trailer << /Root 2 0 R /Info 4 0 R >>\n%%EOF"
+	utf16PDF := "5 0 obj << /Title <FEFF00C9007400E9> >> endobj\ntrailer << /Info 5 0 R >>"
+	cases := []struct {
+		file, content, want string
+	}{
+		{"guide.md", "Intro text\n\n# Installing Forge\n\n## Linux\n\nSteps", "Installing Forge"},
+		{"notes.md", "---\ntitle: Release  notes\n---\n# v2\n\nChanges", "Release notes"},
+		{"page.html", "<html><head><title>\n  Forge docs </title></head><body><h1>Other</h1></body></html>", "Forge docs"},
+		{"page.htm", "<html><body><title>Late</title></body></html>", ""},
+		{"report.pdf", pdf, "Annual (2026) Report"},
+		{"été.pdf", utf16PDF, "Été"},
+		{"plain.txt", "# not markdown", ""},
+	}
+	for _, c := range cases {
+		sections, err := extractSections(c.file, []byte(c.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := extractTitle(c.file, []byte(c.content), sections); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.file, got, c.want)
+		}
+	}
+	if got := clipTitle(strings.Repeat("é", maxTitleLen)); len(got) != maxTitleLen || !strings.HasSuffix(got, "é") {
+		t.Errorf("expected the title cut on a rune boundary, got %d bytes", len(got))
+	}
+}
+
+func TestIngestTitle(t *testing.T) {
+	col := &writeCollection{files: map[string]int{}, metadata: map[string]chroma.DocumentMetadata{}}
+	s := NewIngestService(writeClient{collection: col})
+	ctx := context.Background()
+	if _, err := s.IngestFileWithOptions(ctx, "docs", "guide.md", []byte("# Setup\n\nRun it."), IngestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.IngestFileWithOptions(ctx, "docs", "https://example.com/a", []byte("Page text"), IngestOptions{Title: "Example page"}); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"guide.md": "Setup", "https://example.com/a": "Example page"} {
+		if got, _ := col.metadata[file].GetString(titleKey); got != want {
+			t.Errorf("%s: title %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/db"
+)
+
+// Diagnostic statuses, from best to worst.
+const (
+	DiagnosticPass = "pass"
+	DiagnosticWarn = "warn"
+	DiagnosticFail = "fail"
+)
+
+// doctorCheckTimeout bounds each check that talks to Chroma.
+const doctorCheckTimeout = 30 * time.Second
+
+// doctorCollection is created and dropped again to test embedding.
+const doctorCollection = "forge-doctor-probe"
+
+// minTempFreeBytes is the free temp space below which uploads are likely
+// to fail outright.
+const minTempFreeBytes = 100 << 20
+
+// DiagnosticCheck is the outcome of one doctor check.
+type DiagnosticCheck struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// DiagnosticReport is the doctor's verdict: the worst status of its checks.
+type DiagnosticReport struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    []DiagnosticCheck `json:"checks"`
+}
+
+// ChromaProber probes the Chroma server's version and API support.
+type ChromaProber interface {
+	DetectCompatibility(ctx context.Context) db.Compatibility
+}
+
+// IntegrityChecker verifies the config database.
+type IntegrityChecker interface {
+	IntegrityCheck() error
+}
+
+// DoctorService diagnoses the backend's environment and configuration.
+type DoctorService struct {
+	client  chroma.Client
+	prober  ChromaProber
+	store   IntegrityChecker
+	vals    config.Values
+	tempDir string
+}
+
+func NewDoctorService(client chroma.Client, prober ChromaProber, store IntegrityChecker, vals config.Values) *DoctorService {
+	return &DoctorService{client: client, prober: prober, store: store, vals: vals, tempDir: os.TempDir()}
+}
+
+// Run performs every check. Embedding is only tested when Chroma is
+// reachable.
+func (s *DoctorService) Run(ctx context.Context) *DiagnosticReport {
+	report := &DiagnosticReport{Status: DiagnosticPass, CheckedAt: time.Now().UTC()}
+	add := func(name string, check func() DiagnosticCheck) DiagnosticCheck {
+		started := time.Now()
+		c := check()
+		c.Name = name
+		c.Duration = time.Since(started).Round(time.Millisecond).String()
+		report.Checks = append(report.Checks, c)
+		if diagnosticRank(c.Status) > diagnosticRank(report.Status) {
+			report.Status = c.Status
+		}
+		return c
+	}
+	chromaCheck := add("chroma", func() DiagnosticCheck { return s.checkChroma(ctx) })
+	add("embedding", func() DiagnosticCheck {
+		if chromaCheck.Status == DiagnosticFail {
+			return DiagnosticCheck{Status: DiagnosticFail, Detail: "skipped: Chroma is unreachable"}
+		}
+		return s.checkEmbedding(ctx)
+	})
+	add("sqlite", s.checkSQLite)
+	add("temp_space", s.checkTempSpace)
+	add("config", func() DiagnosticCheck { return checkConfig(s.vals) })
+	return report
+}
+
+func diagnosticRank(status string) int {
+	switch status {
+	case DiagnosticFail:
+		return 2
+	case DiagnosticWarn:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s *DoctorService) checkChroma(ctx context.Context) DiagnosticCheck {
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	compat := s.prober.DetectCompatibility(ctx)
+	c := DiagnosticCheck{Status: DiagnosticPass, Problems: compat.Problems}
+	switch {
+	case compat.API == "unknown":
+		c.Status = DiagnosticFail
+		c.Detail = "unreachable at " + s.vals.ChromaURL
+	case !compat.Compatible:
+		c.Status = DiagnosticFail
+		c.Detail = fmt.Sprintf("version %s (%s API) is not supported", compat.ServerVersion, compat.API)
+	default:
+		c.Detail = fmt.Sprintf("version %s (%s API)", compat.ServerVersion, compat.API)
+		if len(compat.Problems) > 0 {
+			c.Status = DiagnosticWarn
+		}
+	}
+	return c
+}
+
+// checkEmbedding embeds a sample with the embedding function ingestion uses.
+func (s *DoctorService) checkEmbedding(ctx context.Context) DiagnosticCheck {
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	if err := probeEmbedding(ctx, s.client); err != nil {
+		return DiagnosticCheck{Status: DiagnosticFail, Detail: err.Error()}
+	}
+	return DiagnosticCheck{Status: DiagnosticPass, Detail: fmt.Sprintf("%s/%s embedded a sample", s.vals.EmbeddingProvider, s.vals.EmbeddingModel)}
+}
+
+// probeEmbedding writes a document to a scratch collection, which embeds it,
+// and drops the collection again.
+func probeEmbedding(ctx context.Context, client chroma.Client) error {
+	collection, err := client.GetOrCreateCollection(ctx, doctorCollection)
+	if err != nil {
+		return fmt.Errorf("create probe collection: %w", err)
+	}
+	defer func() { _ = client.DeleteCollection(context.WithoutCancel(ctx), doctorCollection) }()
+	if err := collection.Add(ctx, chroma.WithIDs("probe"), chroma.WithTexts("forge doctor embedding probe")); err != nil {
+		return fmt.Errorf("embed sample: %w", err)
+	}
+	return nil
+}
+
+func (s *DoctorService) checkSQLite() DiagnosticCheck {
+	if err := s.store.IntegrityCheck(); err != nil {
+		return DiagnosticCheck{Status: DiagnosticFail, Detail: err.Error()}
+	}
+	return DiagnosticCheck{Status: DiagnosticPass, Detail: "integrity check ok"}
+}
+
+// checkTempSpace checks the free space where large uploads are buffered;
+// less than one fully expanded archive (expand_max_mb) is a warning.
+func (s *DoctorService) checkTempSpace() DiagnosticCheck {
+	free, err := freeDiskBytes(s.tempDir)
+	if err != nil {
+		return DiagnosticCheck{Status: DiagnosticWarn, Detail: fmt.Sprintf("cannot measure free space in %s: %v", s.tempDir, err)}
+	}
+	c := DiagnosticCheck{Status: DiagnosticPass, Detail: fmt.Sprintf("%d MiB free in %s", free>>20, s.tempDir)}
+	switch {
+	case free < minTempFreeBytes:
+		c.Status = DiagnosticFail
+	case free < uint64(s.vals.ExpandMaxMB)<<20:
+		c.Status = DiagnosticWarn
+		c.Problems = []string{fmt.Sprintf("less than expand_max_mb (%d MiB) free", s.vals.ExpandMaxMB)}
+	}
+	return c
+}
+
+// checkConfig reports settings the backend refuses to start with as
+// failures, and settings that disable a feature or look wrong as warnings.
+func checkConfig(vals config.Values) DiagnosticCheck {
+	var fails, warns []string
+	if u, err := url.Parse(vals.ChromaURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fails = append(fails, fmt.Sprintf("chroma_url %q is not an http(s) URL", vals.ChromaURL))
+	}
+	if vals.BackendHTTPPort < 0 || vals.BackendHTTPPort > 65535 {
+		fails = append(fails, fmt.Sprintf("backend_http_port %d is out of range", vals.BackendHTTPPort))
+	}
+	if _, err := NewSystemKeys(vals.SystemMetadataKeys, vals.SystemMetadataNamespace); err != nil {
+		fails = append(fails, "system metadata keys: "+err.Error())
+	}
+	if err := (NamePolicy{Mode: vals.CollectionNameMode, Case: vals.CollectionNameCase}).Validate(); err != nil {
+		fails = append(fails, "collection naming: "+err.Error())
+	}
+
+	if _, err := NewOCR(OCRConfig{Backend: vals.OCRBackend, URL: vals.OCRURL, Languages: vals.OCRLanguages, TesseractPath: vals.TesseractPath, PdftoppmPath: vals.PdftoppmPath}); err != nil {
+		warns = append(warns, "OCR disabled: "+err.Error())
+	}
+	if _, err := NewTranscriber(TranscriptionConfig{Backend: vals.TranscriptionBackend, URL: vals.TranscriptionURL, APIKey: vals.TranscriptionAPIKey, Model: vals.TranscriptionModel, Language: vals.TranscriptionLanguage}); err != nil {
+		warns = append(warns, "transcription disabled: "+err.Error())
+	}
+	if _, err := NewImageDescriber(ImageConfig{Backend: vals.ImageBackend, URL: vals.ImageURL, APIKey: vals.ImageAPIKey, Model: vals.ImageModel, Prompt: vals.ImageCaptionPrompt}); err != nil {
+		warns = append(warns, "image descriptions disabled: "+err.Error())
+	}
+	if _, err := NewGenerator(LLMConfig{URL: vals.LLMURL, APIKey: vals.LLMAPIKey, Model: vals.LLMModel, Models: vals.LLMModels}); err != nil {
+		warns = append(warns, "/answer disabled: "+err.Error())
+	}
+	if _, err := ParseModelPrices(vals.ModelPrices); err != nil {
+		warns = append(warns, "model_prices: "+err.Error())
+	}
+	if _, err := time.ParseDuration(vals.ReportInterval); err != nil {
+		warns = append(warns, fmt.Sprintf("report_interval %q: scheduled reports disabled", vals.ReportInterval))
+	}
+	if vals.IngestBatchMin > vals.IngestBatchMax {
+		warns = append(warns, fmt.Sprintf("ingest_batch_min (%d) exceeds ingest_batch_max (%d)", vals.IngestBatchMin, vals.IngestBatchMax))
+	}
+	if vals.SMTPHost != "" && (vals.SMTPFrom == "" || len(vals.SMTPTo) == 0) {
+		warns = append(warns, "smtp_host is set without smtp_from and smtp_to")
+	}
+	for _, root := range vals.IngestPathRoots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			warns = append(warns, fmt.Sprintf("ingest_path_roots entry %q is not a directory", root))
+		}
+	}
+	for _, setting := range []struct {
+		key string
+		v   int
+	}{
+		{"route_limit_ingest", vals.RouteLimitIngest},
+		{"route_limit_search", vals.RouteLimitSearch},
+		{"route_limit_admin", vals.RouteLimitAdmin},
+		{"http_read_timeout_ms", vals.HTTPReadTimeoutMS},
+		{"http_write_timeout_ms", vals.HTTPWriteTimeoutMS},
+		{"shutdown_timeout_ms", vals.ShutdownTimeoutMS},
+	} {
+		if setting.v < 0 {
+			warns = append(warns, setting.key+" is negative")
+		}
+	}
+
+	c := DiagnosticCheck{Status: DiagnosticPass, Problems: append(fails, warns...)}
+	switch {
+	case len(fails) > 0:
+		c.Status = DiagnosticFail
+	case len(warns) > 0:
+		c.Status = DiagnosticWarn
+	}
+	return c
+}
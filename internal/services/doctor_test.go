@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/db"
+)
+
+type fixedProber db.Compatibility
+
+func (p fixedProber) DetectCompatibility(context.Context) db.Compatibility {
+	return db.Compatibility(p)
+}
+
+type integrityFunc func() error
+
+func (f integrityFunc) IntegrityCheck() error { return f() }
+
+// probeClient is a memClient that also records GetOrCreateCollection.
+type probeClient struct{ memClient }
+
+func (c *probeClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.CreateCollection(ctx, name, opts...)
+}
+
+func doctorValues() config.Values {
+	return config.Values{
+		ChromaURL:          "http://localhost:8000",
+		BackendHTTPPort:    8080,
+		CollectionNameMode: "validate",
+		CollectionNameCase: "insensitive",
+		ReportInterval:     "24h",
+		IngestBatchMin:     16,
+		IngestBatchMax:     512,
+		ExpandMaxMB:        1,
+	}
+}
+
+func TestDoctorRun(t *testing.T) {
+	ctx := context.Background()
+	client := &probeClient{memClient{collections: map[string]*memCollection{}}}
+	healthy := fixedProber{API: "v2", ServerVersion: "1.0.0", Compatible: true}
+	s := NewDoctorService(client, healthy, integrityFunc(func() error { return nil }), doctorValues())
+	s.tempDir = t.TempDir()
+
+	report := s.Run(ctx)
+	if report.Status != DiagnosticPass || len(report.Checks) != 5 {
+		t.Fatalf("expected 5 passing checks, got %+v", report)
+	}
+	if client.creates != 1 || len(client.collections) != 0 {
+		t.Errorf("expected the probe collection to be created and dropped, got %d creates, %v", client.creates, client.collections)
+	}
+
+	// An unreachable Chroma fails both Chroma checks; a bad database fails sqlite
+	s = NewDoctorService(client, fixedProber{API: "unknown"}, integrityFunc(func() error { return errors.New("integrity check: page 3 corrupt") }), doctorValues())
+	s.tempDir = t.TempDir()
+	report = s.Run(ctx)
+	statuses := make(map[string]string)
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	if report.Status != DiagnosticFail || statuses["chroma"] != DiagnosticFail || statuses["embedding"] != DiagnosticFail || statuses["sqlite"] != DiagnosticFail || statuses["config"] != DiagnosticPass {
+		t.Errorf("unexpected statuses: %v", statuses)
+	}
+	if client.creates != 1 {
+		t.Errorf("expected embedding to be skipped, got %d creates", client.creates)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	vals := doctorValues()
+	vals.ReportInterval = "daily"
+	vals.IngestBatchMin = 1024
+	if c := checkConfig(vals); c.Status != DiagnosticWarn || len(c.Problems) != 2 {
+		t.Errorf("expected two warnings, got %+v", c)
+	}
+
+	vals.ChromaURL = "localhost:8000"
+	c := checkConfig(vals)
+	if c.Status != DiagnosticFail || !strings.Contains(c.Problems[0], "chroma_url") {
+		t.Errorf("expected chroma_url failure first, got %+v", c)
+	}
+}
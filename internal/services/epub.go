@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Metadata attached to chunks of EPUB books.
+const (
+	bookTitleKey    = "book_title"
+	bookAuthorKey   = "book_author"
+	chapterKey      = "chapter"       // chapter title
+	chapterIndexKey = "chapter_index" // 1-based position in reading order
+)
+
+func init() {
+	extractors[".epub"] = extractEpub
+}
+
+// extractEpub returns one section per chapter in spine (reading) order, with
+// markup stripped and the book's title and author attached.
+func extractEpub(content []byte) ([]docSection, error) {
+	parts, err := openParts(content)
+	if err != nil {
+		return nil, err
+	}
+	var container struct {
+		Rootfiles []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := parts.decodeInto("META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub container lists no package document")
+	}
+	opfPath := container.Rootfiles[0].Path
+	var pkg struct {
+		Titles   []string `xml:"metadata>title"`
+		Creators []string `xml:"metadata>creator"`
+		Items    []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := parts.decodeInto(opfPath, &pkg); err != nil {
+		return nil, err
+	}
+	hrefs := make(map[string]string, len(pkg.Items))
+	for _, it := range pkg.Items {
+		hrefs[it.ID] = path.Join(path.Dir(opfPath), it.Href)
+	}
+	book := map[string]interface{}{}
+	if len(pkg.Titles) > 0 {
+		book[bookTitleKey] = strings.TrimSpace(pkg.Titles[0])
+	}
+	if len(pkg.Creators) > 0 {
+		book[bookAuthorKey] = strings.Join(trimAll(pkg.Creators), ", ")
+	}
+
+	var sections []docSection
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		title, text, err := parts.xhtmlText(href)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		md := make(map[string]interface{}, len(book)+2)
+		for k, v := range book {
+			md[k] = v
+		}
+		md[chapterIndexKey] = len(sections) + 1
+		if title != "" {
+			md[chapterKey] = title
+		}
+		sections = append(sections, docSection{text: text, metadata: md})
+	}
+	return sections, nil
+}
+
+func trimAll(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// xhtmlBlocks end a line of extracted text.
+var xhtmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "section": true,
+}
+
+// xhtmlText strips markup from a chapter, returning its title (first
+// heading, else <title>) and body text.
+func (p zipParts) xhtmlText(name string) (title, text string, err error) {
+	dec, closeFn, err := p.decoder(name)
+	if err != nil {
+		return "", "", err
+	}
+	defer closeFn()
+	// Chapters are often not strict XML (HTML entities, unclosed tags)
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var b, heading, docTitle strings.Builder
+	skip := 0 // depth inside <script>/<style>/<head>
+	inHeading, inTitle := false, false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch n := strings.ToLower(t.Name.Local); {
+			case n == "title":
+				inTitle = true
+			case n == "script" || n == "style" || n == "head":
+				skip++
+			case title == "" && (n == "h1" || n == "h2" || n == "h3"):
+				inHeading = true
+			}
+		case xml.EndElement:
+			n := strings.ToLower(t.Name.Local)
+			switch n {
+			case "title":
+				inTitle = false
+			case "script", "style", "head":
+				skip--
+			}
+			if inHeading && (n == "h1" || n == "h2" || n == "h3") {
+				inHeading = false
+				title = collapseSpaces(heading.String())
+			}
+			if xhtmlBlocks[n] {
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inTitle {
+				docTitle.Write(t)
+			}
+			if skip > 0 {
+				continue
+			}
+			b.Write(t)
+			if inHeading {
+				heading.Write(t)
+			}
+		}
+	}
+	if title == "" {
+		title = collapseSpaces(docTitle.String())
+	}
+	return title, blankLinesRe.ReplaceAllString(strings.TrimSpace(collapseLineSpaces(b.String())), "\n\n"), nil
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collapseLineSpaces normalizes whitespace within each line.
+func collapseLineSpaces(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = collapseSpaces(l)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractEpubChapters(t *testing.T) {
+	chapter := func(heading, body string) string {
+		return `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>ignored</title>` +
+			`<style>p { color: red }</style></head><body><h1>` + heading + `</h1><p>` + body + `</p><br></body></html>`
+	}
+	content := buildZip(t, map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package xmlns:dc="http://purl.org/dc/elements/1.1/"><metadata>` +
+			`<dc:title>Forge Handbook</dc:title><dc:creator>A. Writer</dc:creator><dc:creator>B. Editor</dc:creator></metadata>` +
+			`<manifest><item id="c1" href="text/one.xhtml"/><item id="c2" href="text/two.xhtml"/><item id="css" href="style.css"/></manifest>` +
+			`<spine><itemref idref="c2"/><itemref idref="c1"/></spine></package>`,
+		"OEBPS/text/one.xhtml": chapter("Getting  Started", "First&nbsp;steps &amp; more"),
+		"OEBPS/text/two.xhtml": chapter("Preface", "Why this book"),
+	})
+	sections, err := extractSections("handbook.epub", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("expected 2 chapters, got %+v", sections)
+	}
+	first, second := sections[0].metadata, sections[1].metadata
+	if first[chapterKey] != "Preface" || first[chapterIndexKey] != 1 || second[chapterKey] != "Getting Started" {
+		t.Errorf("chapters not in spine order: %v, %v", first, second)
+	}
+	if first[bookTitleKey] != "Forge Handbook" || first[bookAuthorKey] != "A. Writer, B. Editor" {
+		t.Errorf("unexpected book metadata: %v", first)
+	}
+	if text := sections[1].text; !strings.Contains(text, "First steps & more") || strings.Contains(text, "color") || strings.Contains(text, "ignored") {
+		t.Errorf("markup not stripped cleanly: %q", text)
+	}
+}
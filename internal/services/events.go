@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Event types published on the EventBus.
+const (
+	EventIngested          = "ingested"           // a file or document was written
+	EventDeleted           = "deleted"            // records or a whole collection were removed
+	EventCollectionChanged = "collection_changed" // follows every ingested or deleted event
+	EventJobState          = "job_state"          // a pipeline run started or finished
+	EventTrashed           = "trashed"            // a collection was moved to the trash
+	EventRestored          = "restored"           // a collection was taken out of the trash
+)
+
+// Event describes something that happened inside the backend.
+type Event struct {
+	Type       string                 `json:"type"`
+	Collection string                 `json:"collection,omitempty"`
+	Time       time.Time              `json:"time"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventBus is an in-process publish/subscribe hub that decouples cross-cutting
+// features (derived views, cache invalidation, webhooks) from the code paths
+// that change data.
+type EventBus struct {
+	mu   sync.RWMutex
+	next int
+	subs []subscription // in subscription order
+}
+
+type subscription struct {
+	id    int
+	types map[string]bool // nil matches every type
+	fn    func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers fn for the given event types, or for every type when
+// none are given, and returns a function that removes the subscription.
+// Subscribers run synchronously in the publisher's goroutine and must hand
+// slow work off to their own goroutines.
+func (b *EventBus) Subscribe(fn func(Event), types ...string) (unsubscribe func()) {
+	sub := subscription{fn: fn}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	sub.id = b.next
+	b.next++
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = slices.DeleteFunc(b.subs, func(s subscription) bool { return s.id == sub.id })
+	}
+}
+
+// Publish delivers e to matching subscribers in subscription order.
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if s.types == nil || s.types[e.Type] {
+			s.fn(e)
+		}
+	}
+}
+
+// Events returns the bus the service publishes data changes on.
+func (s *IngestService) Events() *EventBus {
+	return s.events
+}
+
+// publishChange announces a write or deletion, followed by collection_changed.
+func (s *IngestService) publishChange(eventType, collection string, data map[string]interface{}) {
+	s.events.Publish(Event{Type: eventType, Collection: collection, Data: data})
+	s.events.Publish(Event{Type: EventCollectionChanged, Collection: collection})
+}
+
+// EventWebhook POSTs every matching event as JSON to url in the background.
+func EventWebhook(bus *EventBus, url string, types ...string) (unsubscribe func()) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return bus.Subscribe(func(e Event) {
+		go func() {
+			log := logging.GetLogger().WithFields(logrus.Fields{"event": e.Type, "collection": e.Collection})
+			body, _ := json.Marshal(e)
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				log.WithError(err).Warn("Failed to deliver event webhook")
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.WithField("status", resp.Status).Warn("Event webhook rejected event")
+			}
+		}()
+	}, types...)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventBusSubscriptions(t *testing.T) {
+	bus := NewEventBus()
+	var all, deletes []string
+	unsubscribe := bus.Subscribe(func(e Event) { all = append(all, e.Type) })
+	bus.Subscribe(func(e Event) { deletes = append(deletes, e.Collection) }, EventDeleted)
+
+	s := &IngestService{events: bus}
+	s.publishChange(EventIngested, "docs", nil)
+	s.publishChange(EventDeleted, "notes", nil)
+	unsubscribe()
+	bus.Publish(Event{Type: EventJobState})
+
+	if want := []string{EventIngested, EventCollectionChanged, EventDeleted, EventCollectionChanged}; !reflect.DeepEqual(all, want) {
+		t.Errorf("got %v, want %v", all, want)
+	}
+	if !reflect.DeepEqual(deletes, []string{"notes"}) {
+		t.Errorf("type filter not applied: %v", deletes)
+	}
+}
+
+func TestSearchCacheDroppedOnDelete(t *testing.T) {
+	ctx := context.Background()
+	s := NewIngestService(nil).WithDegradation(time.Second)
+	s.cache.put(searchCacheKey(ctx, "docs", "q", 5, nil, SearchOptions{}), []SearchResult{{ID: "a"}})
+	s.cache.put(searchCacheKey(ctx, "docs2", "q", 5, nil, SearchOptions{}), []SearchResult{{ID: "b"}})
+
+	s.publishChange(EventDeleted, "docs", nil)
+	if _, ok := s.cache.get(searchCacheKey(ctx, "docs", "q", 5, nil, SearchOptions{})); ok {
+		t.Error("expected cached results for docs to be dropped")
+	}
+	if _, ok := s.cache.get(searchCacheKey(ctx, "docs2", "q", 5, nil, SearchOptions{})); !ok {
+		t.Error("expected other collections to stay cached")
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// ErrInvalidExclusion is returned for exclude blocks Chroma cannot express.
+var ErrInvalidExclusion = errors.New("invalid exclude")
+
+// Exclusion removes chunks from search results, e.g. those an agent loop
+// has already shown.
+type Exclusion struct {
+	IDs      []string `json:"ids,omitempty" jsonschema:"chunk ids to leave out"`
+	FileMD5s []string `json:"file_md5s,omitempty" jsonschema:"files to leave out, by content hash"`
+	// Metadata excludes chunks whose key holds one of the given values; each
+	// value is a string, number or bool, or a list of one kind.
+	Metadata map[string]interface{} `json:"metadata,omitempty" jsonschema:"metadata keys mapped to a value or list of values to leave out"`
+}
+
+// IsZero reports whether the exclusion excludes nothing.
+func (e Exclusion) IsZero() bool {
+	return len(e.IDs) == 0 && len(e.FileMD5s) == 0 && len(e.Metadata) == 0
+}
+
+// Validate checks that every metadata entry maps to values of one kind.
+func (e Exclusion) Validate() error {
+	_, err := e.clauses(DefaultSystemKeys)
+	return err
+}
+
+// clauses returns a $nin clause per excluded key. IDs are not expressible in
+// a where filter and are dropped from results instead (see dropIDs).
+func (e Exclusion) clauses(keys SystemKeys) ([]chroma.WhereClause, error) {
+	var clauses []chroma.WhereClause
+	if len(e.FileMD5s) > 0 {
+		clauses = append(clauses, chroma.NinString(keys.FileMD5, e.FileMD5s...))
+	}
+	names := make([]string, 0, len(e.Metadata))
+	for k := range e.Metadata {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		clause, err := ninClause(k, e.Metadata[k])
+		if err != nil {
+			return nil, fmt.Errorf("%w: metadata %q: %v", ErrInvalidExclusion, k, err)
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+// ninClause builds a $nin clause from a JSON value or list of values.
+// Whole numbers compare as ints, matching how chunk metadata stores them.
+func ninClause(key string, value interface{}) (chroma.WhereClause, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no values")
+	}
+	var strs []string
+	var nums []float64
+	var bools []bool
+	for _, v := range values {
+		switch val := v.(type) {
+		case string:
+			strs = append(strs, val)
+		case float64:
+			nums = append(nums, val)
+		case int:
+			nums = append(nums, float64(val))
+		case json.Number:
+			f, err := val.Float64()
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, f)
+		case bool:
+			bools = append(bools, val)
+		default:
+			return nil, fmt.Errorf("unsupported value %v", v)
+		}
+	}
+	switch len(values) {
+	case len(strs):
+		return chroma.NinString(key, strs...), nil
+	case len(bools):
+		return chroma.NinBool(key, bools...), nil
+	case len(nums):
+		ints := make([]int, 0, len(nums))
+		for _, n := range nums {
+			if n != math.Trunc(n) {
+				floats := make([]float32, len(nums))
+				for i, n := range nums {
+					floats[i] = float32(n)
+				}
+				return chroma.NinFloat(key, floats...), nil
+			}
+			ints = append(ints, int(n))
+		}
+		return chroma.NinInt(key, ints...), nil
+	}
+	return nil, errors.New("values must all be strings, numbers or bools")
+}
+
+// dropIDs removes results with excluded IDs.
+func (e Exclusion) dropIDs(results []SearchResult) []SearchResult {
+	if len(e.IDs) == 0 {
+		return results
+	}
+	excluded := make(map[string]bool, len(e.IDs))
+	for _, id := range e.IDs {
+		excluded[id] = true
+	}
+	kept := results[:0]
+	for _, r := range results {
+		if !excluded[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestExclusionClauses(t *testing.T) {
+	var e Exclusion
+	if err := json.Unmarshal([]byte(`{"file_md5s":["abc"],"metadata":{"chunk_index":[0,1],"score":0.5,"user_tag":"draft"}}`), &e); err != nil {
+		t.Fatal(err)
+	}
+	clauses, err := e.clauses(DefaultSystemKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range clauses {
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	want := []string{
+		`{"file_md5":{"$nin":["abc"]}}`,
+		`{"chunk_index":{"$nin":[0,1]}}`,
+		`{"score":{"$nin":[0.5]}}`,
+		`{"user_tag":{"$nin":["draft"]}}`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("clause %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	mixed := Exclusion{Metadata: map[string]interface{}{"user_tag": []interface{}{"a", 1.0}}}
+	if err := mixed.Validate(); !errors.Is(err, ErrInvalidExclusion) {
+		t.Errorf("expected ErrInvalidExclusion for mixed values, got %v", err)
+	}
+}
+
+func TestExclusionDropIDs(t *testing.T) {
+	results := []SearchResult{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	got := Exclusion{IDs: []string{"b"}}.dropIDs(results)
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Errorf("unexpected results %+v", got)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrExpandLimit is returned when an uploaded archive exceeds ExpandLimits.
+var ErrExpandLimit = errors.New("archive exceeds expansion limits")
+
+// ExpandLimits bound server-side expansion of uploaded archives, so a zip
+// bomb cannot exhaust memory. Nested archives count against the same
+// limits.
+type ExpandLimits struct {
+	// MaxDepth is how deep archives nest; 1 expands only the upload itself.
+	MaxDepth int
+	MaxFiles int
+	// MaxBytes caps the total expanded size of one upload.
+	MaxBytes int64
+}
+
+// DefaultExpandLimits is used unless WithExpandLimits overrides it.
+var DefaultExpandLimits = ExpandLimits{MaxDepth: 3, MaxFiles: 1000, MaxBytes: 512 << 20}
+
+// WithExpandLimits sets the bounds for expanding uploaded archives.
+// Non-positive fields keep their defaults.
+func (s *IngestService) WithExpandLimits(l ExpandLimits) *IngestService {
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultExpandLimits.MaxDepth
+	}
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultExpandLimits.MaxFiles
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultExpandLimits.MaxBytes
+	}
+	s.expand = l
+	return s
+}
+
+// IngestUpload ingests an uploaded file. Zip and tar (.tar, .tar.gz, .tgz)
+// archives are expanded and each member is ingested as
+// "<archive>/<member path>", with a result per member. An archive that
+// cannot be expanded, or exceeds the limits, yields a single error result
+// and nothing from it is ingested.
+func (s *IngestService) IngestUpload(ctx context.Context, collectionName, filename string, content []byte, opts IngestOptions) []IngestResult {
+	if !isExpandable(filename) {
+		return []IngestResult{s.ingestResult(ctx, collectionName, filename, content, opts)}
+	}
+	exp := &expansion{limits: s.expand}
+	if err := exp.expand(filename, content, 1); err != nil {
+		return []IngestResult{{Status: "error", File: filename, Error: err.Error()}}
+	}
+	results := make([]IngestResult, 0, len(exp.files))
+	for _, f := range exp.files {
+		results = append(results, s.ingestResult(ctx, collectionName, f.name, f.content, opts))
+	}
+	return results
+}
+
+func (s *IngestService) ingestResult(ctx context.Context, collectionName, filename string, content []byte, opts IngestOptions) IngestResult {
+	res, err := s.IngestFileWithOptions(ctx, collectionName, filename, content, opts)
+	if err != nil {
+		return IngestResult{Status: "error", File: filename, Error: err.Error()}
+	}
+	return *res
+}
+
+func isExpandable(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+type expandedFile struct {
+	name    string
+	content []byte
+}
+
+// expansion collects the regular files of an archive tree while enforcing
+// limits across all of it.
+type expansion struct {
+	limits ExpandLimits
+	files  []expandedFile
+	bytes  int64
+}
+
+func (e *expansion) expand(name string, content []byte, depth int) error {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return e.expandZip(name, content, depth)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		defer zr.Close()
+		return e.expandTar(name, zr, depth)
+	default:
+		return e.expandTar(name, bytes.NewReader(content), depth)
+	}
+}
+
+func (e *expansion) expandZip(name string, content []byte, depth int) error {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || skipMember(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("read %s/%s: %w", name, f.Name, err)
+		}
+		err = e.add(name, f.Name, rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *expansion) expandTar(name string, r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || skipMember(hdr.Name) {
+			continue
+		}
+		if err := e.add(name, hdr.Name, tr, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// add reads one member, counting it against the limits (declared sizes
+// are not trusted), and expands it in turn if it is an archive.
+func (e *expansion) add(archive, member string, r io.Reader, depth int) error {
+	name := path.Join(archive, path.Clean("/" + strings.ReplaceAll(member, "\\", "/"))[1:])
+	budget := e.limits.MaxBytes - e.bytes
+	content, err := io.ReadAll(io.LimitReader(r, budget+1))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	e.bytes += int64(len(content))
+	if e.bytes > e.limits.MaxBytes {
+		return fmt.Errorf("%w: more than %d bytes expanded", ErrExpandLimit, e.limits.MaxBytes)
+	}
+	if isExpandable(member) {
+		if depth >= e.limits.MaxDepth {
+			return fmt.Errorf("%w: archives nested deeper than %d", ErrExpandLimit, e.limits.MaxDepth)
+		}
+		return e.expand(name, content, depth+1)
+	}
+	if len(e.files) >= e.limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrExpandLimit, e.limits.MaxFiles)
+	}
+	e.files = append(e.files, expandedFile{name: name, content: content})
+	return nil
+}
+
+// skipMember reports archive entries that are never content: macOS resource
+// forks and Finder metadata.
+func skipMember(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || base == ".DS_Store" || strings.HasPrefix(base, "._")
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+)
+
+func buildTarGz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExpandArchive(t *testing.T) {
+	inner := buildTarGz(t, map[string][]byte{"notes/b.txt": []byte("bee")})
+	upload := buildZip(t, map[string]string{
+		"docs/a.md":            "# A",
+		"../escape.txt":        "x",
+		"__MACOSX/docs/._a.md": "fork",
+		"more.tgz":             string(inner),
+	})
+
+	exp := &expansion{limits: DefaultExpandLimits}
+	if err := exp.expand("bundle.zip", upload, 1); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range exp.files {
+		got[f.name] = string(f.content)
+	}
+	want := map[string]string{"bundle.zip/docs/a.md": "# A", "bundle.zip/escape.txt": "x", "bundle.zip/more.tgz/notes/b.txt": "bee"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s: got %q, want %q", name, got[name], content)
+		}
+	}
+
+	for _, limits := range []ExpandLimits{
+		{MaxDepth: 1, MaxFiles: 10, MaxBytes: 1 << 20},
+		{MaxDepth: 3, MaxFiles: 2, MaxBytes: 1 << 20},
+		{MaxDepth: 3, MaxFiles: 10, MaxBytes: 4},
+	} {
+		exp := &expansion{limits: limits}
+		if err := exp.expand("bundle.zip", upload, 1); !errors.Is(err, ErrExpandLimit) {
+			t.Errorf("limits %+v: expected ErrExpandLimit, got %v", limits, err)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Structural metadata attached to chunks of extracted documents.
+const (
+	headingKey     = "heading"      // heading path, e.g. "Install > Linux"
+	slideNumberKey = "slide_number" // 1-based
+	sheetNameKey   = "sheet_name"
+)
+
+// maxExtractedPartSize bounds how much of one archive member is read, so a
+// crafted office file cannot decompress without limit.
+const maxExtractedPartSize = 64 << 20
+
+// docSection is a run of text sharing structural metadata. Sections are
+// chunked independently so chunks never straddle a heading, slide or sheet.
+type docSection struct {
+	text     string
+	metadata map[string]interface{}
+	// split breaks text into chunkable units; nil splits by line.
+	split func(text string) []string
+	// embedding, when set, is stored as the section's vector instead of
+	// embedding its text, and the section is kept as a single chunk.
+	embedding []float32
+}
+
+func (d docSection) units(text string) []string {
+	if d.split != nil {
+		return d.split(text)
+	}
+	return strings.Split(text, "\n")
+}
+
+// extractors convert documents to sections by lower-case file extension.
+// Files with other extensions are treated as plain text.
+var extractors = map[string]func(content []byte) ([]docSection, error){
+	".docx": extractDocx,
+	".pptx": extractPptx,
+	".xlsx": extractXlsx,
+}
+
+// extractSections converts a file's content into text sections.
+func extractSections(filePath string, content []byte) ([]docSection, error) {
+	extract, ok := extractors[strings.ToLower(path.Ext(filePath))]
+	if !ok {
+		return []docSection{{text: string(content)}}, nil
+	}
+	sections, err := extract(content)
+	if err != nil {
+		return nil, fmt.Errorf("extract %s: %w", filePath, err)
+	}
+	return sections, nil
+}
+
+// extract converts a file into sections, transcribing audio, describing
+// images when an image backend is configured and recognizing other images
+// and PDFs without a text layer through OCR.
+func (s *IngestService) extract(ctx context.Context, collectionName, filePath string, content []byte) ([]docSection, error) {
+	ext := strings.ToLower(path.Ext(filePath))
+	if audioExtensions[ext] {
+		return s.transcribe(ctx, filePath, content)
+	}
+	if imageExtensions[ext] && s.images != nil {
+		return s.describeImage(ctx, collectionName, filePath, content)
+	}
+	if !imageExtensions[ext] {
+		sections, err := extractSections(filePath, content)
+		if err != nil || ext != ".pdf" || len(sections) > 0 {
+			return sections, err
+		}
+	}
+	return s.recognize(ctx, filePath, content)
+}
+
+// zipParts indexes an OOXML package's members by name.
+type zipParts map[string]*zip.File
+
+func openParts(content []byte) (zipParts, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	parts := make(zipParts, len(zr.File))
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	return parts, nil
+}
+
+func (p zipParts) decoder(name string) (*xml.Decoder, func() error, error) {
+	f, ok := p[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing part %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return xml.NewDecoder(io.LimitReader(rc, maxExtractedPartSize)), rc.Close, nil
+}
+
+// headingStack tracks the headings enclosing the current position.
+type headingStack []struct {
+	level int
+	text  string
+}
+
+// push enters a heading, leaving any headings at the same or a deeper level.
+func (h headingStack) push(level int, text string) headingStack {
+	for len(h) > 0 && h[len(h)-1].level >= level {
+		h = h[:len(h)-1]
+	}
+	return append(h, struct {
+		level int
+		text  string
+	}{level, text})
+}
+
+// metadata returns the heading path metadata, e.g. "Install > Linux".
+func (h headingStack) metadata() map[string]interface{} {
+	md := map[string]interface{}{}
+	if len(h) == 0 {
+		return md
+	}
+	parts := make([]string, len(h))
+	for i, e := range h {
+		parts[i] = e.text
+	}
+	md[headingKey] = strings.Join(parts, " > ")
+	return md
+}
+
+var headingStyleRe = regexp.MustCompile(`(?i)^heading\s*([1-9])$`)
+
+// extractDocx splits a Word document into sections at headings, recording
+// the heading path of each section.
+func extractDocx(content []byte) ([]docSection, error) {
+	parts, err := openParts(content)
+	if err != nil {
+		return nil, err
+	}
+	dec, closeFn, err := parts.decoder("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	var sections []docSection
+	var headings headingStack
+	var body strings.Builder
+	hasBody := false // whether body holds more than its heading line
+	flush := func() {
+		if hasBody {
+			sections = append(sections, docSection{text: body.String(), metadata: headings.metadata()})
+		}
+		body.Reset()
+		hasBody = false
+	}
+
+	var para strings.Builder
+	level := 0 // heading level of the current paragraph, 0 for body text
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				level = 0
+			case "pStyle":
+				if m := headingStyleRe.FindStringSubmatch(attr(t, "val")); m != nil {
+					level, _ = strconv.Atoi(m[1])
+				} else if strings.EqualFold(attr(t, "val"), "title") {
+					level = 1
+				}
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if level > 0 && text != "" {
+					flush()
+					headings = headings.push(level, text)
+				} else if text != "" {
+					hasBody = true
+				}
+				body.WriteString(para.String() + "\n")
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	flush()
+	return sections, nil
+}
+
+var slidePartRe = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// extractPptx returns one section per slide, in slide order.
+func extractPptx(content []byte) ([]docSection, error) {
+	parts, err := openParts(content)
+	if err != nil {
+		return nil, err
+	}
+	var slides []int
+	for name := range parts {
+		if m := slidePartRe.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, n)
+		}
+	}
+	sort.Ints(slides)
+
+	var sections []docSection
+	for _, n := range slides {
+		text, err := parts.paragraphText(fmt.Sprintf("ppt/slides/slide%d.xml", n), "p", "t")
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sections = append(sections, docSection{text: text, metadata: map[string]interface{}{slideNumberKey: n}})
+	}
+	return sections, nil
+}
+
+// paragraphText concatenates text elements, ending a line at each paragraph element.
+func (p zipParts) paragraphText(name, paraElem, textElem string) (string, error) {
+	dec, closeFn, err := p.decoder(name)
+	if err != nil {
+		return "", err
+	}
+	defer closeFn()
+	var b strings.Builder
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inText = t.Name.Local == textElem
+		case xml.EndElement:
+			if t.Name.Local == textElem {
+				inText = false
+			} else if t.Name.Local == paraElem {
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+// extractXlsx returns one section per worksheet with rows as tab-separated lines.
+func extractXlsx(content []byte) ([]docSection, error) {
+	parts, err := openParts(content)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := parts.sharedStrings()
+	if err != nil {
+		return nil, err
+	}
+	sheets, err := parts.worksheets()
+	if err != nil {
+		return nil, err
+	}
+	var sections []docSection
+	for _, sh := range sheets {
+		text, err := parts.sheetText(sh.part, shared)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sections = append(sections, docSection{text: text, metadata: map[string]interface{}{sheetNameKey: sh.name}})
+	}
+	return sections, nil
+}
+
+type worksheet struct{ name, part string }
+
+// worksheets lists sheets in workbook order with their part names.
+func (p zipParts) worksheets() ([]worksheet, error) {
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := p.decodeInto("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, r := range rels.Relationships {
+		t := strings.TrimPrefix(r.Target, "/")
+		if !strings.HasPrefix(t, "xl/") {
+			t = path.Join("xl", t)
+		}
+		targets[r.ID] = t
+	}
+	var wb struct {
+		Sheets []struct {
+			Name string     `xml:"name,attr"`
+			Attr []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := p.decodeInto("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	var out []worksheet
+	for _, s := range wb.Sheets {
+		for _, a := range s.Attr {
+			if a.Name.Local == "id" {
+				if part, ok := targets[a.Value]; ok {
+					out = append(out, worksheet{name: s.Name, part: part})
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (p zipParts) sharedStrings() ([]string, error) {
+	if _, ok := p["xl/sharedStrings.xml"]; !ok {
+		return nil, nil
+	}
+	var sst struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := p.decodeInto("xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(sst.Items))
+	for i, it := range sst.Items {
+		s := it.T
+		for _, r := range it.Runs {
+			s += r.T
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func (p zipParts) sheetText(part string, shared []string) (string, error) {
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := p.decodeInto(part, &ws); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, row := range ws.Rows {
+		cells := make([]string, 0, len(row.Cells))
+		for _, c := range row.Cells {
+			v := c.Value
+			switch c.Type {
+			case "s":
+				if i, err := strconv.Atoi(v); err == nil && i >= 0 && i < len(shared) {
+					v = shared[i]
+				}
+			case "inlineStr":
+				v = c.Inline
+			}
+			cells = append(cells, v)
+		}
+		line := strings.TrimRight(strings.Join(cells, "\t"), "\t")
+		if line != "" {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func (p zipParts) decodeInto(name string, v any) error {
+	dec, closeFn, err := p.decoder(name)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	return dec.Decode(v)
+}
+
+func attr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
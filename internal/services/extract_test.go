@@ -0,0 +1,109 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func para(style, text string) string {
+	props := ""
+	if style != "" {
+		props = `<w:pPr><w:pStyle w:val="` + style + `"/></w:pPr>`
+	}
+	return `<w:p>` + props + `<w:r><w:t>` + text + `</w:t></w:r></w:p>`
+}
+
+func TestExtractDocxHeadingPath(t *testing.T) {
+	doc := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		para("", "Preamble") +
+		para("Heading1", "Install") +
+		para("Heading2", "Linux") +
+		para("", "apt install forge") +
+		para("Heading1", "Usage") +
+		para("", "run it") +
+		`</w:body></w:document>`
+	sections, err := extractSections("guide.DOCX", buildZip(t, map[string]string{"word/document.xml": doc}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ heading, text string }{
+		{"", "Preamble\n"},
+		{"Install > Linux", "Linux\napt install forge\n"},
+		{"Usage", "Usage\nrun it\n"},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("expected %d sections, got %+v", len(want), sections)
+	}
+	for i, w := range want {
+		got, _ := sections[i].metadata[headingKey].(string)
+		if got != w.heading || sections[i].text != w.text {
+			t.Errorf("section %d: got (%q, %q), want (%q, %q)", i, got, sections[i].text, w.heading, w.text)
+		}
+	}
+}
+
+func TestExtractXlsxSheets(t *testing.T) {
+	content := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			`<sheet name="Prices" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>item</t></si><si><t>apple</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row><c t="s"><v>0</v></c><c><v>price</v></c></row>` +
+			`<row><c t="s"><v>1</v></c><c><v>3</v></c></row></sheetData></worksheet>`,
+	})
+	sections, err := extractSections("book.xlsx", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 1 || sections[0].metadata[sheetNameKey] != "Prices" {
+		t.Fatalf("unexpected sections: %+v", sections)
+	}
+	if want := "item\tprice\napple\t3\n"; sections[0].text != want {
+		t.Errorf("got %q, want %q", sections[0].text, want)
+	}
+}
+
+func TestExtractPptxSlideOrder(t *testing.T) {
+	slide := func(text string) string {
+		return `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:sld>`
+	}
+	sections, err := extractSections("deck.pptx", buildZip(t, map[string]string{
+		"ppt/slides/slide10.xml": slide("ten"),
+		"ppt/slides/slide2.xml":  slide("two"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].metadata[slideNumberKey] != 2 || sections[1].text != "ten\n" {
+		t.Fatalf("unexpected sections: %+v", sections)
+	}
+}
+
+func TestExtractPlainAndInvalid(t *testing.T) {
+	sections, err := extractSections("notes.txt", []byte("hi"))
+	if err != nil || len(sections) != 1 || sections[0].text != "hi" {
+		t.Fatalf("unexpected plain extraction: %+v, %v", sections, err)
+	}
+	if _, err := extractSections("broken.docx", []byte("not a zip")); err == nil {
+		t.Fatal("expected error for invalid docx")
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Section is a run of extracted text. Its metadata is stored on each chunk
+// the text is split into, so chunks never straddle two sections.
+type Section struct {
+	Text     string
+	Metadata map[string]interface{}
+}
+
+// Extractor adds a document format. CanHandle is given the file name and
+// the MIME type sniffed from its content (application/octet-stream when
+// nothing recognizes it); Extract receives the file's raw bytes.
+type Extractor interface {
+	CanHandle(filePath, mime string) bool
+	Extract(ctx context.Context, filePath string, content []byte) ([]Section, error)
+}
+
+// ExtractorTypes is optionally implemented by an Extractor to list the
+// formats it adds in GET /api/ingest/supported-types.
+type ExtractorTypes interface {
+	FileTypes() []FileType
+}
+
+var (
+	registryMu           sync.RWMutex
+	registeredExtractors []Extractor
+)
+
+// RegisterExtractor adds an extractor, typically from the init function of
+// a file behind a build tag. Registered extractors are tried in order before
+// the built-in ones, so one may also take over a built-in format.
+func RegisterExtractor(e Extractor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registeredExtractors = append(registeredExtractors, e)
+}
+
+// registeredExtractor returns the first registered extractor that handles
+// the file, or nil.
+func registeredExtractor(filePath, mime string) Extractor {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, e := range registeredExtractors {
+		if e.CanHandle(filePath, mime) {
+			return e
+		}
+	}
+	return nil
+}
+
+// registeredTypes lists the formats registered extractors declare.
+func registeredTypes() []FileType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var out []FileType
+	for _, e := range registeredExtractors {
+		if typed, ok := e.(ExtractorTypes); ok {
+			for _, t := range typed.FileTypes() {
+				t.Available = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// extractFile converts a file into sections with the extractor for its
+// content type, or for opts.Format: a registered one, the request's XML
+// mapping or a built-in. Built-ins get text decoded to UTF-8; charset names
+// the source encoding.
+func (s *IngestService) extractFile(ctx context.Context, collectionName, filePath string, content []byte, opts IngestOptions) (sections []docSection, charset string, err error) {
+	mapping := opts.XML
+	mime := sniffType(filePath, content)
+	if opts.Format != "" {
+		if !strings.EqualFold(path.Ext(filePath), opts.Format) {
+			filePath += opts.Format
+		}
+		if t, ok := fileTypeByExtension(strings.ToLower(opts.Format)); ok {
+			mime = t.MIME
+		}
+	}
+	if e := registeredExtractor(filePath, mime); e != nil && (mapping == nil || !isXMLFile(filePath)) {
+		extracted, err := e.Extract(ctx, filePath, content)
+		if err != nil {
+			return nil, "", fmt.Errorf("extract %s: %w", filePath, err)
+		}
+		sections = make([]docSection, len(extracted))
+		for i, sec := range extracted {
+			sections[i] = docSection{text: sec.Text, metadata: sec.Metadata}
+		}
+		return sections, "", nil
+	}
+
+	// Route by content type, so misnamed files reach the right extractor
+	route, err := routeFile(filePath, mime)
+	if err != nil {
+		return nil, "", err
+	}
+	// Decode text files to UTF-8; the dedupe hash stays on the raw bytes
+	if isTextFormat(route) {
+		if content, charset, err = decodeText(content); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+
+	// Office documents are split into structural sections, audio is
+	// transcribed and images or scanned PDFs go through OCR
+	if mapping != nil && isXMLFile(route) {
+		sections, err = extractXML(content, *mapping)
+	} else {
+		sections, err = s.extract(ctx, collectionName, route, content)
+	}
+	return sections, charset, err
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// odtExtractor reads "OpenDocument" files as lines of text, one section each.
+type odtExtractor struct{}
+
+func (odtExtractor) CanHandle(filePath, mime string) bool {
+	return strings.HasSuffix(filePath, ".odt")
+}
+
+func (odtExtractor) Extract(ctx context.Context, filePath string, content []byte) ([]Section, error) {
+	var out []Section
+	for i, line := range strings.Split(string(content), "\n") {
+		out = append(out, Section{Text: line, Metadata: map[string]interface{}{"line": i + 1}})
+	}
+	return out, nil
+}
+
+func (odtExtractor) FileTypes() []FileType {
+	return []FileType{{MIME: "application/vnd.oasis.opendocument.text", Extensions: []string{".odt"}, Extractor: "odt"}}
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	ctx := context.Background()
+	s := NewIngestService(nil)
+	content := []byte("\x00\x01first\nsecond")
+	if _, _, err := s.extractFile(ctx, "docs", "notes.odt", content, IngestOptions{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType without an extractor, got %v", err)
+	}
+
+	RegisterExtractor(odtExtractor{})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registeredExtractors = nil
+		registryMu.Unlock()
+	})
+	sections, _, err := s.extractFile(ctx, "docs", "notes.odt", content, IngestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[1].text != "second" || sections[1].metadata["line"] != 2 {
+		t.Errorf("unexpected sections %+v", sections)
+	}
+	// Other files still reach the built-ins
+	if sections, _, err := s.extractFile(ctx, "docs", "notes.md", []byte("# Title\nbody"), IngestOptions{}); err != nil || sections[0].metadata[headingKey] != "Title" {
+		t.Errorf("unexpected built-in sections %+v, %v", sections, err)
+	}
+
+	types := s.SupportedTypes()
+	if last := types[len(types)-1]; last.Extractor != "odt" || !last.Available {
+		t.Errorf("expected the registered type to be listed, got %+v", last)
+	}
+}
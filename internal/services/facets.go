@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// defaultFacetLimit caps the distinct values returned per facet key.
+const defaultFacetLimit = 100
+
+// FacetValue is one distinct metadata value and how many chunks carry it.
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// Facet lists a key's most common values.
+type Facet struct {
+	Key    string       `json:"key"`
+	Values []FacetValue `json:"values"`
+	// Distinct is the total number of distinct values, which may exceed len(Values).
+	Distinct int `json:"distinct"`
+	// Missing counts chunks without the key.
+	Missing int `json:"missing"`
+}
+
+// Facets computes value counts for metadata keys over the chunks the caller
+// may see, optionally narrowed by filter. Each facet returns at most limit
+// values (defaultFacetLimit when zero), most frequent first.
+func (s *IngestService) Facets(ctx context.Context, collectionName string, keys []string, filter map[string]interface{}, limit int) ([]Facet, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one facet key is required")
+	}
+	if limit <= 0 {
+		limit = defaultFacetLimit
+	}
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	clauses := append(filterClauses(filter), aclWhere(PrincipalsFromContext(ctx)))
+	records, err := scanRecords(ctx, collection, andWhere(clauses), chroma.IncludeMetadatas)
+	if err != nil {
+		return nil, err
+	}
+	return computeFacets(records, keys, limit), nil
+}
+
+func computeFacets(records []Record, keys []string, limit int) []Facet {
+	facets := make([]Facet, 0, len(keys))
+	for _, key := range keys {
+		counts := make(map[interface{}]int)
+		f := Facet{Key: key}
+		for _, r := range records {
+			v, ok := r.Metadata[key]
+			if !ok {
+				f.Missing++
+				continue
+			}
+			counts[v]++
+		}
+		for v, n := range counts {
+			f.Values = append(f.Values, FacetValue{Value: v, Count: n})
+		}
+		sort.Slice(f.Values, func(i, j int) bool {
+			if f.Values[i].Count != f.Values[j].Count {
+				return f.Values[i].Count > f.Values[j].Count
+			}
+			return fmt.Sprint(f.Values[i].Value) < fmt.Sprint(f.Values[j].Value)
+		})
+		f.Distinct = len(f.Values)
+		if len(f.Values) > limit {
+			f.Values = f.Values[:limit]
+		}
+		if f.Values == nil {
+			f.Values = []FacetValue{}
+		}
+		facets = append(facets, f)
+	}
+	return facets
+}
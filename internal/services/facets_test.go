@@ -0,0 +1,22 @@
+package services
+
+import "testing"
+
+func TestComputeFacets(t *testing.T) {
+	records := []Record{
+		{Metadata: map[string]interface{}{"user_category": "faq", "lang": "en"}},
+		{Metadata: map[string]interface{}{"user_category": "guide", "lang": "en"}},
+		{Metadata: map[string]interface{}{"user_category": "faq"}},
+	}
+	facets := computeFacets(records, []string{"user_category", "lang"}, 1)
+	if len(facets) != 2 {
+		t.Fatalf("expected 2 facets, got %d", len(facets))
+	}
+	cat := facets[0]
+	if cat.Distinct != 2 || len(cat.Values) != 1 || cat.Values[0].Value != "faq" || cat.Values[0].Count != 2 {
+		t.Fatalf("unexpected category facet: %+v", cat)
+	}
+	if lang := facets[1]; lang.Missing != 1 || lang.Values[0].Count != 2 {
+		t.Fatalf("unexpected lang facet: %+v", lang)
+	}
+}
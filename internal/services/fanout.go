@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxSearchLegs bounds how many sub-queries of one search run at once.
+const maxSearchLegs = 8
+
+// rrfK is the reciprocal rank fusion constant used to merge hybrid legs.
+const rrfK = 60
+
+// searchLeg is one sub-query of a fan-out search.
+type searchLeg struct {
+	collection string
+	lexical    bool
+}
+
+// MultiSearch searches one or more collections, running a vector leg per
+// collection plus a lexical leg when opts.Hybrid is set. Legs run
+// concurrently (at most maxSearchLegs at a time) and are merged: by distance
+// for vector-only searches, by reciprocal rank fusion for hybrid ones.
+// With opts.SessionID, chunks the session already returned are excluded and
+// the new results are added to it. With opts.Parents, results are expanded
+// to their parent sections last.
+func (s *IngestService) MultiSearch(ctx context.Context, collections []string, query string, k int, filter map[string]interface{}, opts SearchOptions) (*SearchResponse, error) {
+	if opts.SessionID != "" {
+		id := opts.SessionID
+		opts.SessionID = ""
+		return s.sessionSearch(ctx, id, opts, func(opts SearchOptions) (*SearchResponse, error) {
+			return s.MultiSearch(ctx, collections, query, k, filter, opts)
+		})
+	}
+	n := k
+	if opts.Parents {
+		n *= parentOverfetch
+	}
+	var legs []searchLeg
+	seen := make(map[string]bool)
+	for _, c := range collections {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if err := s.checkNotTrashed(c); err != nil {
+			return nil, err
+		}
+		go s.recordQuery(ctx, c, query)
+		legs = append(legs, searchLeg{collection: c})
+		if opts.Hybrid {
+			legs = append(legs, searchLeg{collection: c, lexical: true})
+		}
+	}
+	if len(legs) == 1 {
+		resp, err := s.SearchWithFallback(ctx, legs[0].collection, query, n, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		tagCollection(resp.Results, legs[0].collection)
+		s.recordSearchOutcome(ctx, legs[0].collection, resp.Results)
+		if opts.Parents {
+			if resp.Results, err = s.expandParents(ctx, resp.Results, k); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+
+	out := make([]*SearchResponse, len(legs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxSearchLegs)
+	for i, leg := range legs {
+		g.Go(func() error {
+			var resp *SearchResponse
+			if leg.lexical {
+				lctx, cancel := withTimeout(gctx, s.timeouts.Query)
+				defer cancel()
+				results, err := s.lexicalSearch(lctx, leg.collection, query, n, filter, opts.Exclude)
+				// A query with no lexical terms leaves the vector leg to answer,
+				// or to reject it as vague
+				if err != nil && !errors.Is(err, errNoLexicalTerms) {
+					return err
+				}
+				resp = &SearchResponse{Results: results}
+			} else {
+				var err error
+				if resp, err = s.SearchWithFallback(gctx, leg.collection, query, n, filter, opts); err != nil {
+					return err
+				}
+			}
+			tagCollection(resp.Results, leg.collection)
+			if !leg.lexical {
+				s.recordSearchOutcome(ctx, leg.collection, resp.Results)
+			}
+			out[i] = resp
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	merged := &SearchResponse{}
+	lists := make([][]SearchResult, len(out))
+	for i, resp := range out {
+		lists[i] = resp.Results
+		if resp.Degraded {
+			merged.Degraded = true
+			merged.DegradedReason = resp.DegradedReason
+		}
+		if resp.Strategy != "" {
+			merged.Strategy = resp.Strategy
+		}
+		merged.Replica = merged.Replica || resp.Replica
+	}
+	if opts.Hybrid {
+		merged.Results = fuseRRF(lists)
+	} else {
+		merged.Results = mergeByDistance(lists)
+	}
+	if opts.Dedupe {
+		merged.Results = dedupeResults(merged.Results)
+	}
+	if opts.Parents {
+		var err error
+		if merged.Results, err = s.expandParents(ctx, merged.Results, k); err != nil {
+			return nil, err
+		}
+	}
+	if len(merged.Results) > k {
+		merged.Results = merged.Results[:k]
+	}
+	return merged, nil
+}
+
+func (s *IngestService) recordSearchOutcome(ctx context.Context, collection string, results []SearchResult) {
+	delta := config.CollectionCounters{Searches: 1}
+	if len(results) == 0 {
+		delta.ZeroResults = 1
+	}
+	s.recordCounters(ctx, collection, delta)
+}
+
+func tagCollection(results []SearchResult, collection string) {
+	for i := range results {
+		results[i].Collection = collection
+	}
+}
+
+// mergeByDistance interleaves per-collection vector results, best first.
+func mergeByDistance(lists [][]SearchResult) []SearchResult {
+	var all []SearchResult
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+	return all
+}
+
+// fuseRRF merges ranked lists with reciprocal rank fusion. A chunk found by
+// several legs keeps its best (smallest) distance and accumulates score.
+func fuseRRF(lists [][]SearchResult) []SearchResult {
+	type key struct{ collection, id string }
+	byKey := make(map[key]*SearchResult)
+	var order []key
+	for _, l := range lists {
+		for rank, r := range l {
+			kk := key{r.Collection, r.ID}
+			cur, ok := byKey[kk]
+			if !ok {
+				first := r
+				first.Score = 0
+				cur = &first
+				byKey[kk] = cur
+				order = append(order, kk)
+			} else if r.Distance < cur.Distance {
+				cur.Distance = r.Distance
+			}
+			cur.Score += 1 / float32(rrfK+rank+1)
+		}
+	}
+	fused := make([]SearchResult, len(order))
+	for i, kk := range order {
+		fused[i] = *byKey[kk]
+	}
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+	return fused
+}
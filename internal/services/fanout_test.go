@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMergeByDistance(t *testing.T) {
+	got := mergeByDistance([][]SearchResult{
+		{{ID: "a1", Distance: 0.1}, {ID: "a2", Distance: 0.5}},
+		{{ID: "b1", Distance: 0.3}},
+	})
+	want := []string{"a1", "b1", "a2"}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Fatalf("position %d: expected %s, got %s", i, id, got[i].ID)
+		}
+	}
+}
+
+func TestFuseRRF(t *testing.T) {
+	vector := []SearchResult{{ID: "x", Collection: "c", Distance: 0.2}, {ID: "y", Collection: "c", Distance: 0.4}}
+	lexical := []SearchResult{{ID: "y", Collection: "c", Distance: 0}, {ID: "z", Collection: "c", Distance: 0.5}}
+	got := fuseRRF([][]SearchResult{vector, lexical})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 fused results, got %d", len(got))
+	}
+	if got[0].ID != "y" {
+		t.Fatalf("expected y (found by both legs) first, got %s", got[0].ID)
+	}
+	if got[0].Distance != 0 {
+		t.Fatalf("expected best distance to be kept, got %v", got[0].Distance)
+	}
+}
+
+func TestMultiSearchHybridWithoutLexicalTerms(t *testing.T) {
+	primary := &replicaCollection{&fileCollection{records: map[string]Record{
+		"a": {ID: "a", Document: "alpha"},
+	}}}
+	var down bool
+	s := NewIngestService(replicaClient{collection: primary, down: &down})
+	// The lexical leg's failure must never win the race with the vector leg's
+	for i := 0; i < 20; i++ {
+		if _, err := s.MultiSearch(context.Background(), []string{"docs"}, "the", 5, nil, SearchOptions{Hybrid: true}); !errors.Is(err, ErrVagueQuery) {
+			t.Fatalf("expected ErrVagueQuery for a stopword-only query, got %v", err)
+		}
+	}
+	resp, err := s.MultiSearch(context.Background(), []string{"docs"}, "alpha", 5, nil, SearchOptions{Hybrid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].ID != "a" {
+		t.Errorf("expected the fused results, got %+v", resp.Results)
+	}
+}
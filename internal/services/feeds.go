@@ -0,0 +1,471 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+const (
+	defaultFeedInterval = time.Hour
+	minFeedInterval     = 5 * time.Minute
+	feedBytes           = 10 << 20
+	feedUserAgent       = "forge-feeds"
+	// feedErrorsKept caps the per-entry errors reported by a poll.
+	feedErrorsKept = 10
+)
+
+// ErrInvalidFeed is returned for an unusable feed registration.
+var ErrInvalidFeed = errors.New("invalid feed")
+
+// ErrFeedFetch is returned when a feed cannot be fetched or parsed.
+var ErrFeedFetch = errors.New("feed fetch failed")
+
+// ErrFeedPolling is returned when a feed is polled while a poll is running.
+var ErrFeedPolling = errors.New("feed is already being polled")
+
+// FeedSpec registers a feed URL with a collection.
+type FeedSpec struct {
+	URL string `json:"url"`
+	// Interval between polls as a Go duration; default 1h, at least 5m.
+	Interval string `json:"interval,omitempty"`
+}
+
+// Validate checks the URL and interval, defaulting the interval.
+func (f *FeedSpec) Validate() error {
+	u, err := url.Parse(f.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidFeed)
+	}
+	if f.Interval == "" {
+		f.Interval = defaultFeedInterval.String()
+	}
+	if d, err := time.ParseDuration(f.Interval); err != nil || d < minFeedInterval {
+		return fmt.Errorf("%w: interval %q must be a duration of at least %s", ErrInvalidFeed, f.Interval, minFeedInterval)
+	}
+	return nil
+}
+
+// FeedStore persists feeds and the entries they have yielded.
+type FeedStore interface {
+	CreateFeed(collection, url, interval string) (config.Feed, error)
+	GetFeed(id int64) (config.Feed, error)
+	ListFeeds(collection string) ([]config.Feed, error)
+	DeleteFeed(id int64) error
+	RecordFeedPoll(id int64, at time.Time, status, etag, lastModified string) error
+	FeedEntrySeen(id int64, guid string) (bool, error)
+	MarkFeedEntry(id int64, guid string) error
+}
+
+// FeedPoll reports the outcome of polling a feed.
+type FeedPoll struct {
+	Feed      int64     `json:"feed"`
+	StartedAt time.Time `json:"started_at"`
+	// NotModified is set when the server reported no change since the last poll.
+	NotModified bool     `json:"not_modified,omitempty"`
+	Entries     int      `json:"entries"`
+	Ingested    int      `json:"ingested"`
+	Seen        int      `json:"seen"`
+	Failed      int      `json:"failed"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// FeedService polls RSS and Atom feeds into collections, ingesting each entry
+// once by its GUID.
+type FeedService struct {
+	ingest *IngestService
+	store  FeedStore
+	client *http.Client
+	// allowPrivate lets feeds reach loopback and private addresses.
+	allowPrivate bool
+	// ingestEntry is IngestFileWithOptions, replaceable in tests.
+	ingestEntry func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error)
+
+	mu      sync.Mutex
+	polling map[int64]bool
+}
+
+func NewFeedService(ingest *IngestService, store FeedStore) *FeedService {
+	return &FeedService{
+		ingest:      ingest,
+		store:       store,
+		client:      newFetchClient(30*time.Second, isPublicIP),
+		ingestEntry: ingest.IngestFileWithOptions,
+		polling:     make(map[int64]bool),
+	}
+}
+
+// WithPrivateNetworks lets feeds be fetched from loopback, private and
+// link-local addresses. By default they are refused.
+func (s *FeedService) WithPrivateNetworks(allow bool) *FeedService {
+	s.allowPrivate = allow
+	s.client = newFetchClient(30*time.Second, fetchGuard(allow))
+	return s
+}
+
+// Add registers a feed with collection; the scheduler polls it on its next tick.
+func (s *FeedService) Add(collection string, spec FeedSpec) (config.Feed, error) {
+	if err := spec.Validate(); err != nil {
+		return config.Feed{}, err
+	}
+	if !s.allowPrivate {
+		u, _ := url.Parse(spec.URL)
+		if err := checkPublicHost(u); err != nil {
+			return config.Feed{}, fmt.Errorf("%w: %w", ErrInvalidFeed, err)
+		}
+	}
+	return s.store.CreateFeed(collection, spec.URL, spec.Interval)
+}
+
+func (s *FeedService) List(collection string) ([]config.Feed, error) {
+	return s.store.ListFeeds(collection)
+}
+
+// get returns a feed of collection, hiding other collections' feeds.
+func (s *FeedService) get(collection string, id int64) (config.Feed, error) {
+	f, err := s.store.GetFeed(id)
+	if err == nil && f.Collection != collection {
+		return config.Feed{}, config.ErrNotFound
+	}
+	return f, err
+}
+
+// Delete unregisters a feed; the chunks it ingested are kept.
+func (s *FeedService) Delete(collection string, id int64) error {
+	if _, err := s.get(collection, id); err != nil {
+		return err
+	}
+	return s.store.DeleteFeed(id)
+}
+
+// Poll fetches a feed now and ingests the entries not seen before.
+func (s *FeedService) Poll(ctx context.Context, collection string, id int64) (*FeedPoll, error) {
+	f, err := s.get(collection, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.poll(ctx, f)
+}
+
+func (s *FeedService) poll(ctx context.Context, f config.Feed) (*FeedPoll, error) {
+	s.mu.Lock()
+	if s.polling[f.ID] {
+		s.mu.Unlock()
+		return nil, ErrFeedPolling
+	}
+	s.polling[f.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.polling, f.ID)
+		s.mu.Unlock()
+	}()
+
+	poll := &FeedPoll{Feed: f.ID, StartedAt: time.Now()}
+	etag, modified, err := s.fetchAndIngest(ctx, f, poll)
+	status := "ok"
+	switch {
+	case err != nil:
+		status = "error: " + err.Error()
+		// Keep the validators so a failed poll doesn't refetch unchanged entries
+		etag, modified = f.ETag, f.LastModified
+	case poll.Failed > 0:
+		status = fmt.Sprintf("errors: %d", poll.Failed)
+		// Refetch next time to retry the failed entries
+		etag, modified = "", ""
+	}
+	if err := s.store.RecordFeedPoll(f.ID, poll.StartedAt, status, etag, modified); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("feed", f.URL).Warn("Failed to record feed poll")
+	}
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"feed":       f.URL,
+		"collection": f.Collection,
+		"ingested":   poll.Ingested,
+		"failed":     poll.Failed,
+	}).Info("Polled feed")
+	return poll, nil
+}
+
+// fetchAndIngest polls f into poll, returning the validators to send next time.
+func (s *FeedService) fetchAndIngest(ctx context.Context, f config.Feed, poll *FeedPoll) (etag, modified string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", feedUserAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	if f.ETag != "" {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if f.LastModified != "" {
+		req.Header.Set("If-Modified-Since", f.LastModified)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrFeedFetch, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		poll.NotModified = true
+		return f.ETag, f.LastModified, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%w: unexpected status %s", ErrFeedFetch, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, feedBytes+1))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrFeedFetch, err)
+	}
+	if len(body) > feedBytes {
+		return "", "", fmt.Errorf("%w: larger than %d bytes", ErrFeedFetch, feedBytes)
+	}
+	entries, err := parseFeed(body)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrFeedFetch, err)
+	}
+
+	base := resp.Request.URL
+	sum := sha256.Sum256([]byte(f.URL))
+	source := IngestSource{ID: "feed-" + hex.EncodeToString(sum[:8]), Kind: SourceFeed, Ref: f.URL}
+	poll.Entries = len(entries)
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		seen, err := s.store.FeedEntrySeen(f.ID, e.GUID)
+		if err != nil {
+			return "", "", err
+		}
+		if seen {
+			poll.Seen++
+			continue
+		}
+		if err := s.ingestFeedEntry(ctx, f, base, e, source); err != nil {
+			poll.Failed++
+			if len(poll.Errors) < feedErrorsKept {
+				poll.Errors = append(poll.Errors, fmt.Sprintf("%s: %v", e.GUID, err))
+			}
+			continue
+		}
+		if err := s.store.MarkFeedEntry(f.ID, e.GUID); err != nil {
+			return "", "", err
+		}
+		poll.Ingested++
+	}
+	return resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), nil
+}
+
+func (s *FeedService) ingestFeedEntry(ctx context.Context, f config.Feed, base *url.URL, e feedEntry, source IngestSource) error {
+	text := ""
+	if e.Content != "" {
+		page, err := parsePage(base, []byte(e.Content))
+		if err != nil {
+			return err
+		}
+		text = page.text
+	}
+	if e.Title != "" {
+		text = strings.TrimSpace(e.Title + "\n\n" + text)
+	}
+	if text == "" {
+		return errors.New("entry has no title or content")
+	}
+	md := map[string]interface{}{"feed": f.URL, "guid": e.GUID}
+	for k, v := range map[string]string{"title": e.Title, "link": e.Link} {
+		if v != "" {
+			md[k] = v
+		}
+	}
+	if !e.Published.IsZero() {
+		md["published"] = e.Published.UTC().Format(time.RFC3339)
+	}
+	name := e.Link
+	if name == "" {
+		name = e.GUID
+	}
+	res, err := s.ingestEntry(ctx, f.Collection, name, []byte(text), IngestOptions{Metadata: md, Source: source, Title: e.Title})
+	if err != nil {
+		return err
+	}
+	if res.Status == "error" {
+		return errors.New(res.Error)
+	}
+	return nil
+}
+
+// RunScheduler polls feeds whose interval has elapsed, checking every tick
+// until ctx is canceled.
+func (s *FeedService) RunScheduler(ctx context.Context, tick time.Duration) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			feeds, err := s.store.ListFeeds("")
+			if err != nil {
+				logging.FromContext(ctx).WithError(err).Warn("Failed to list feeds for polling")
+				continue
+			}
+			for _, f := range feeds {
+				if d, err := time.ParseDuration(f.Interval); err != nil || now.Sub(f.LastPolledAt) < d {
+					continue
+				}
+				go func(f config.Feed) {
+					if _, err := s.poll(ctx, f); err != nil && !errors.Is(err, ErrFeedPolling) {
+						logging.FromContext(ctx).WithError(err).WithField("feed", f.URL).Error("Scheduled feed poll failed")
+					}
+				}(f)
+			}
+		}
+	}
+}
+
+// feedEntry is an RSS item or Atom entry.
+type feedEntry struct {
+	GUID      string
+	Title     string
+	Link      string
+	Published time.Time
+	Content   string // HTML or text
+}
+
+type rssItem struct {
+	GUID        string `xml:"guid"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// atomText is an Atom text construct; xhtml content is inline markup.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+}
+
+// rawFeed decodes RSS 2.0 (<rss><channel><item>), RSS 1.0 (<rdf:RDF><item>)
+// and Atom (<feed><entry>) documents.
+type rawFeed struct {
+	XMLName      xml.Name
+	ChannelItems []rssItem   `xml:"channel>item"`
+	Items        []rssItem   `xml:"item"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+// parseFeed reads a feed's entries, giving each a GUID: its own, else its
+// link, else a hash of its title and content.
+func parseFeed(body []byte) ([]feedEntry, error) {
+	var raw rawFeed
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, r io.Reader) (io.Reader, error) {
+		// Feeds that declare another charset are usually ASCII-compatible
+		return r, nil
+	}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parse feed: %w", err)
+	}
+	var out []feedEntry
+	switch strings.ToLower(raw.XMLName.Local) {
+	case "rss", "rdf":
+		for _, it := range append(raw.ChannelItems, raw.Items...) {
+			e := feedEntry{GUID: strings.TrimSpace(it.GUID), Title: collapseSpaces(it.Title), Link: strings.TrimSpace(it.Link), Content: it.Encoded}
+			if e.Content == "" {
+				e.Content = it.Description
+			}
+			e.Published = parseFeedTime(it.PubDate, it.Date)
+			out = append(out, e)
+		}
+	case "feed":
+		for _, it := range raw.Entries {
+			e := feedEntry{GUID: strings.TrimSpace(it.ID), Title: collapseSpaces(it.Title), Content: it.Content.String()}
+			for _, l := range it.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					e.Link = strings.TrimSpace(l.Href)
+					break
+				}
+			}
+			if e.Content == "" {
+				e.Content = it.Summary.String()
+			}
+			e.Published = parseFeedTime(it.Published, it.Updated)
+			out = append(out, e)
+		}
+	default:
+		return nil, fmt.Errorf("parse feed: unknown document <%s>", raw.XMLName.Local)
+	}
+	for i := range out {
+		if out[i].GUID == "" {
+			out[i].GUID = out[i].Link
+		}
+		if out[i].GUID == "" {
+			sum := sha256.Sum256([]byte(out[i].Title + "\x00" + out[i].Content))
+			out[i].GUID = "sha256:" + hex.EncodeToString(sum[:])
+		}
+	}
+	return out, nil
+}
+
+// feedTimeLayouts are the date formats seen in RSS (RFC 822 and variants) and
+// Atom (RFC 3339) feeds.
+var feedTimeLayouts = []string{
+	time.RFC3339, time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700", "2006-01-02",
+}
+
+// parseFeedTime parses the first of values in a known layout.
+func parseFeedTime(values ...string) time.Time {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		for _, layout := range feedTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+type memFeedStore struct {
+	feeds map[int64]config.Feed
+	seen  map[string]bool
+}
+
+func (m *memFeedStore) CreateFeed(collection, url, interval string) (config.Feed, error) {
+	f := config.Feed{ID: int64(len(m.feeds) + 1), Collection: collection, URL: url, Interval: interval}
+	m.feeds[f.ID] = f
+	return f, nil
+}
+
+func (m *memFeedStore) GetFeed(id int64) (config.Feed, error) {
+	f, ok := m.feeds[id]
+	if !ok {
+		return config.Feed{}, config.ErrNotFound
+	}
+	return f, nil
+}
+
+func (m *memFeedStore) ListFeeds(collection string) ([]config.Feed, error) {
+	var out []config.Feed
+	for _, f := range m.feeds {
+		if collection == "" || f.Collection == collection {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *memFeedStore) DeleteFeed(id int64) error {
+	delete(m.feeds, id)
+	return nil
+}
+
+func (m *memFeedStore) RecordFeedPoll(id int64, at time.Time, status, etag, lastModified string) error {
+	f := m.feeds[id]
+	f.LastPolledAt, f.LastStatus, f.ETag, f.LastModified = at, status, etag, lastModified
+	m.feeds[id] = f
+	return nil
+}
+
+func (m *memFeedStore) FeedEntrySeen(id int64, guid string) (bool, error) {
+	return m.seen[fmt.Sprint(id, guid)], nil
+}
+
+func (m *memFeedStore) MarkFeedEntry(id int64, guid string) error {
+	m.seen[fmt.Sprint(id, guid)] = true
+	return nil
+}
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Blog</title>
+%s
+</channel></rss>`
+
+const testRSSItem = `<item><title>Post %[1]d</title><link>https://blog.example/%[1]d</link><guid>post-%[1]d</guid>
+<pubDate>Mon, 2 Mar 2026 10:00:00 +0000</pubDate><description>Summary</description>
+<content:encoded><![CDATA[<p>Body of <b>post %[1]d</b></p>]]></content:encoded></item>`
+
+func TestFeedPoll(t *testing.T) {
+	posts := 2
+	etag := `"v2"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		var items strings.Builder
+		for i := 1; i <= posts; i++ {
+			fmt.Fprintf(&items, testRSSItem, i)
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, testRSS, items.String())
+	}))
+	defer srv.Close()
+
+	store := &memFeedStore{feeds: map[int64]config.Feed{}, seen: map[string]bool{}}
+	s := NewFeedService(NewIngestService(nil), store)
+	if _, err := s.Add("blog", FeedSpec{URL: srv.URL}); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected a loopback feed refused, got %v", err)
+	}
+	store.feeds[99] = config.Feed{ID: 99, Collection: "blog", URL: srv.URL, Interval: "1h"}
+	if _, err := s.Poll(context.Background(), "blog", 99); !errors.Is(err, ErrFeedFetch) || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+		t.Errorf("expected a stored loopback feed refused when fetched, got %v", err)
+	}
+	delete(store.feeds, 99)
+	s.WithPrivateNetworks(true)
+	type ingested struct {
+		name, text string
+		metadata   map[string]interface{}
+	}
+	var got []ingested
+	s.ingestEntry = func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error) {
+		got = append(got, ingested{name, string(content), opts.Metadata})
+		return &IngestResult{Status: "ingested", File: name}, nil
+	}
+
+	if _, err := s.Add("blog", FeedSpec{URL: srv.URL, Interval: "1m"}); !errors.Is(err, ErrInvalidFeed) {
+		t.Errorf("expected a too short interval to be rejected, got %v", err)
+	}
+	feed, err := s.Add("blog", FeedSpec{URL: srv.URL})
+	if err != nil || feed.Interval != "1h0m0s" {
+		t.Fatalf("Add() = %+v, %v", feed, err)
+	}
+	if _, err := s.Poll(context.Background(), "other", feed.ID); !errors.Is(err, config.ErrNotFound) {
+		t.Errorf("expected another collection's feed to be hidden, got %v", err)
+	}
+
+	etag = `"v1"`
+	poll, err := s.Poll(context.Background(), "blog", feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.Entries != 2 || poll.Ingested != 2 || len(got) != 2 {
+		t.Fatalf("unexpected first poll %+v", poll)
+	}
+	if got[0].name != "https://blog.example/1" || got[0].text != "Post 1\n\nBody of post 1" {
+		t.Errorf("unexpected entry %+v", got[0])
+	}
+	if md := got[0].metadata; md["guid"] != "post-1" || md["title"] != "Post 1" || md["link"] != "https://blog.example/1" || md["published"] != "2026-03-02T10:00:00Z" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	if poll, err := s.Poll(context.Background(), "blog", feed.ID); err != nil || !poll.NotModified || len(got) != 2 {
+		t.Errorf("expected an unchanged feed to be skipped, got %+v, %v", poll, err)
+	}
+
+	posts, etag = 3, `"v2"`
+	store.feeds[feed.ID] = config.Feed{ID: feed.ID, Collection: "blog", URL: srv.URL, Interval: "1h"} // drop the validators
+	if poll, err := s.Poll(context.Background(), "blog", feed.ID); err != nil || poll.Ingested != 1 || poll.Seen != 2 || got[2].name != "https://blog.example/3" {
+		t.Errorf("expected only the new entry, got %+v, %v", poll, err)
+	}
+	if store.feeds[feed.ID].LastStatus != "ok" || store.feeds[feed.ID].ETag != `"v2"` {
+		t.Errorf("unexpected poll state %+v", store.feeds[feed.ID])
+	}
+}
+
+func TestParseFeed(t *testing.T) {
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom"><title>Notes</title>
+<entry><id>urn:1</id><title>First</title><link rel="self" href="https://x/self"/><link href="https://x/1"/>
+<updated>2026-03-01T12:00:00Z</updated><content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Inline</p></div></content></entry>
+<entry><title>Second</title><summary type="html">&lt;p&gt;Escaped&lt;/p&gt;</summary></entry>
+</feed>`
+	entries, err := parseFeed([]byte(atom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.GUID != "urn:1" || e.Link != "https://x/1" || !strings.Contains(e.Content, "<p>Inline</p>") || e.Published.Day() != 1 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := entries[1]; !strings.HasPrefix(e.GUID, "sha256:") || e.Content != "<p>Escaped</p>" {
+		t.Errorf("expected a content hash GUID and unescaped summary, got %+v", e)
+	}
+
+	rdf := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<item><title>Old</title><link>https://x/old</link><dc:date>2025-12-24</dc:date></item></rdf:RDF>`
+	if entries, err := parseFeed([]byte(rdf)); err != nil || len(entries) != 1 || entries[0].GUID != "https://x/old" || entries[0].Published.Year() != 2025 {
+		t.Errorf("unexpected RSS 1.0 entries %+v, %v", entries, err)
+	}
+	if _, err := parseFeed([]byte(`<html></html>`)); err == nil {
+		t.Error("expected HTML to be rejected")
+	}
+}
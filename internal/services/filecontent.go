@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// FileContent is a document's text rebuilt from its chunks.
+type FileContent struct {
+	FileMD5  string `json:"file_md5"`
+	FileName string `json:"file_name"`
+	Chunks   int    `json:"chunks"`
+	// Missing lists chunk indexes absent from the sequence, e.g. after a
+	// partial delete; the content then has gaps.
+	Missing []int  `json:"missing,omitempty"`
+	Content string `json:"content"`
+}
+
+// FileContent reassembles the extracted text of the file with the given
+// content hash by concatenating its chunks in chunk_index order, less the
+// text each repeats from the one before. This is the text as chunked: after
+// extraction and transforms, not the original bytes.
+func (s *IngestService) FileContent(ctx context.Context, collectionName, md5Hash string) (*FileContent, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, md5Hash)
+	}
+	where := andWhere([]chroma.WhereClause{chroma.EqString(s.keys.FileMD5, md5Hash), aclWhere(PrincipalsFromContext(ctx))})
+	records, err := scanRecords(ctx, collection, where)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, md5Hash)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return toInt64(records[i].Metadata[s.keys.ChunkIndex]) < toInt64(records[j].Metadata[s.keys.ChunkIndex])
+	})
+
+	out := &FileContent{FileMD5: md5Hash, Chunks: len(records)}
+	out.FileName, _ = records[0].Metadata[s.keys.FileName].(string)
+	var b strings.Builder
+	next := 0
+	for _, r := range records {
+		index := int(toInt64(r.Metadata[s.keys.ChunkIndex]))
+		if index < next {
+			continue // another file name with the same content
+		}
+		for ; next < index; next++ {
+			out.Missing = append(out.Missing, next)
+		}
+		b.WriteString(withoutOverlap(r))
+		next = index + 1
+	}
+	out.Content = b.String()
+	return out, nil
+}
+
+// withoutOverlap is a chunk's text less what it repeats from the one before.
+func withoutOverlap(r Record) string {
+	overlap := min(max(int(toInt64(r.Metadata[overlapKey])), 0), len(r.Document))
+	return r.Document[overlap:]
+}
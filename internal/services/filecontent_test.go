@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFileContent(t *testing.T) {
+	col := &fileCollection{records: map[string]Record{}}
+	for i, text := range map[int]string{2: "third\n", 0: "first\n", 3: "fourth\n"} {
+		id := ChunkID("notes.txt", i, text)
+		col.records[id] = Record{ID: id, Document: text, Metadata: map[string]interface{}{"file_md5": "abc", "file_name": "notes.txt", "chunk_index": int64(i)}}
+	}
+	s := NewIngestService(fileClient{collection: col})
+
+	got, err := s.FileContent(context.Background(), "docs", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FileContent{FileMD5: "abc", FileName: "notes.txt", Chunks: 3, Missing: []int{1}, Content: "first\nthird\nfourth\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	col.records = map[string]Record{}
+	if _, err := s.FileContent(context.Background(), "docs", "abc"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
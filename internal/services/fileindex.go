@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// FileIndexStore remembers the size, modification time and MD5 of the
+// files directory sources ingested.
+type FileIndexStore interface {
+	IndexedFiles(collection, source string) (map[string]config.IndexedFile, error)
+	IndexFiles(files []config.IndexedFile) error
+	ForgetIndexedFiles(collection, source string, paths ...string) error
+}
+
+// WithFileIndex makes directory ingests and pipeline path sources read
+// only files that changed since they were last ingested, and replace the
+// chunks of the ones that did.
+func (s *IngestService) WithFileIndex(store FileIndexStore) *IngestService {
+	s.fileIndex = store
+	return s
+}
+
+// forgetIndexed drops the index entries of files whose chunks were deleted
+// (see FileIndexStore.ForgetIndexedFiles), so the next directory ingest
+// reads them again instead of finding them unchanged. A failure is only
+// logged, since the chunks are already gone.
+func (s *IngestService) forgetIndexed(ctx context.Context, collection, source string, paths ...string) {
+	if s.fileIndex == nil {
+		return
+	}
+	if err := s.fileIndex.ForgetIndexedFiles(collection, source, paths...); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", collection).Warn("Failed to update file index")
+	}
+}
+
+// forgetIndexedRecords forgets the files the deleted records belonged to.
+func (s *IngestService) forgetIndexedRecords(ctx context.Context, collection string, records []Record) {
+	bySource := map[string]map[string]bool{}
+	for _, r := range records {
+		source, _ := r.Metadata[s.keys.SourceID].(string)
+		name, _ := r.Metadata[s.keys.FileName].(string)
+		if source == "" || name == "" {
+			continue
+		}
+		if bySource[source] == nil {
+			bySource[source] = map[string]bool{}
+		}
+		bySource[source][name] = true
+	}
+	for source, names := range bySource {
+		paths := make([]string, 0, len(names))
+		for name := range names {
+			paths = append(paths, name)
+		}
+		sort.Strings(paths)
+		s.forgetIndexed(ctx, collection, source, paths...)
+	}
+}
+
+// How a file compares with its index entry.
+const (
+	fileAdded     = "added"
+	fileUpdated   = "updated"
+	fileUnchanged = "unchanged"
+)
+
+// statusUnchanged is the result status of a file the index shows unchanged.
+const statusUnchanged = "unchanged"
+
+// dirIndex compares one source's files with the index and collects the
+// entries to record once they are ingested.
+type dirIndex struct {
+	store      FileIndexStore
+	collection string
+	source     string
+	previous   map[string]config.IndexedFile
+	full       bool
+
+	mu      sync.Mutex
+	updates []config.IndexedFile
+}
+
+// openDirIndex loads a source's index; with full, no file counts as
+// unchanged, so every file is read and checked against the collection.
+// Without a store every file counts as added.
+func (s *IngestService) openDirIndex(collection, source string, full bool) (*dirIndex, error) {
+	d := &dirIndex{store: s.fileIndex, collection: collection, source: source, previous: map[string]config.IndexedFile{}, full: full}
+	if s.fileIndex == nil {
+		return d, nil
+	}
+	previous, err := s.fileIndex.IndexedFiles(collection, source)
+	if err != nil {
+		return nil, fmt.Errorf("load file index: %w", err)
+	}
+	d.previous = previous
+	return d, nil
+}
+
+// read returns a file's content, unless it is unchanged, with how it
+// changed and the index entry to record once it is ingested. A file whose
+// size and modification time match its entry is not read; one whose
+// content still matches is unchanged, with its entry refreshed.
+func (d *dirIndex) read(path, rel string) ([]byte, string, config.IndexedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", config.IndexedFile{}, err
+	}
+	prev, known := d.previous[rel]
+	if known && !d.full && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+		return nil, fileUnchanged, prev, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", config.IndexedFile{}, err
+	}
+	entry := config.IndexedFile{
+		Collection: d.collection,
+		Source:     d.source,
+		Path:       rel,
+		Size:       int64(len(content)),
+		ModTime:    info.ModTime(),
+		MD5:        fmt.Sprintf("%x", md5.Sum(content)),
+		IngestedAt: time.Now(),
+	}
+	switch {
+	case !known:
+		return content, fileAdded, entry, nil
+	case prev.MD5 == entry.MD5 && !d.full:
+		entry.IngestedAt = prev.IngestedAt
+		d.record(entry)
+		return nil, fileUnchanged, entry, nil
+	}
+	return content, fileUpdated, entry, nil
+}
+
+// record queues an entry for save.
+func (d *dirIndex) record(entry config.IndexedFile) {
+	d.mu.Lock()
+	d.updates = append(d.updates, entry)
+	d.mu.Unlock()
+}
+
+// save writes the queued entries.
+func (d *dirIndex) save() error {
+	if d.store == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.store.IndexFiles(d.updates); err != nil {
+		return fmt.Errorf("save file index: %w", err)
+	}
+	d.updates = nil
+	return nil
+}
+
+// ingestIndexed ingests a file of a directory source unless the index shows
+// it unchanged, replacing the chunks of its earlier version when it
+// changed, and returns the result with how the file changed.
+func (s *IngestService) ingestIndexed(ctx context.Context, collection, path, rel string, opts IngestOptions, index *dirIndex) (IngestResult, string) {
+	content, change, entry, err := index.read(path, rel)
+	if err != nil {
+		return IngestResult{Status: "error", File: rel, Error: err.Error()}, ""
+	}
+	if change == fileUnchanged {
+		return IngestResult{Status: statusUnchanged, File: rel}, change
+	}
+	opts.Replace = change == fileUpdated
+	res, err := s.IngestFileWithOptions(ctx, collection, rel, content, opts)
+	if err != nil {
+		return IngestResult{Status: "error", File: rel, Error: err.Error()}, change
+	}
+	if res.Status == "ingested" || res.Status == "skipped" {
+		index.record(entry)
+	}
+	return *res, change
+}
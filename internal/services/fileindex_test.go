@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+type memFileIndex struct {
+	mu    sync.Mutex
+	files map[string]config.IndexedFile
+}
+
+func (m *memFileIndex) IndexedFiles(collection, source string) (map[string]config.IndexedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]config.IndexedFile{}
+	for _, f := range m.files {
+		if f.Collection == collection && f.Source == source {
+			out[f.Path] = f
+		}
+	}
+	return out, nil
+}
+
+func (m *memFileIndex) IndexFiles(files []config.IndexedFile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range files {
+		m.files[f.Collection+"/"+f.Source+"/"+f.Path] = f
+	}
+	return nil
+}
+
+func (m *memFileIndex) ForgetIndexedFiles(collection, source string, paths ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	forget := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		forget[p] = true
+	}
+	for k, f := range m.files {
+		if f.Collection == collection && (source == "" || f.Source == source && (len(paths) == 0 || forget[f.Path])) {
+			delete(m.files, k)
+		}
+	}
+	return nil
+}
+
+func TestIngestPathIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, content string, mtime time.Time) {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	then := time.Now().Add(-time.Hour)
+	write("a.md", "Alpha", then)
+	write("b.md", "Beta", then)
+	col := &writeCollection{files: map[string]int{}}
+	s := NewIngestService(writeClient{collection: col}).WithPathRoots([]string{dir}).WithFileIndex(&memFileIndex{files: map[string]config.IndexedFile{}})
+	spec := PathIngestSpec{Path: dir, Collection: "docs"}
+
+	run := func(want PathIngestReport) {
+		t.Helper()
+		report, err := s.IngestPath(ctx, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Added != want.Added || report.Updated != want.Updated || report.Unchanged != want.Unchanged || report.Ingested != want.Added+want.Updated {
+			t.Errorf("got added %d, updated %d, unchanged %d, ingested %d; want %d, %d, %d",
+				report.Added, report.Updated, report.Unchanged, report.Ingested, want.Added, want.Updated, want.Unchanged)
+		}
+	}
+	run(PathIngestReport{Added: 2})
+	run(PathIngestReport{Unchanged: 2})
+
+	// A changed file is re-ingested; a touched one is read but left alone
+	write("b.md", "Beta, revised", then.Add(time.Minute))
+	write("a.md", "Alpha", then.Add(time.Minute))
+	run(PathIngestReport{Updated: 1, Unchanged: 1})
+	if col.files["a.md"] != 1 || col.files["b.md"] != 2 {
+		t.Errorf("unexpected writes %v", col.files)
+	}
+	run(PathIngestReport{Unchanged: 2})
+
+	spec.Full = true
+	run(PathIngestReport{Updated: 2})
+}
+
+// filterCollection keeps upserted chunks and applies $eq, $in and $and
+// metadata filters to gets and deletes.
+type filterCollection struct {
+	chroma.Collection
+	records map[string]Record
+}
+
+func (c *filterCollection) Name() string { return "docs" }
+
+func (c *filterCollection) matching(where chroma.WhereFilter) []Record {
+	var clause map[string]interface{}
+	if where != nil {
+		raw, _ := where.MarshalJSON()
+		json.Unmarshal(raw, &clause)
+	}
+	var out []Record
+	for _, r := range c.records {
+		if matchesClause(r.Metadata, clause) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func matchesClause(md map[string]interface{}, clause map[string]interface{}) bool {
+	for key, cond := range clause {
+		if key == "$and" {
+			for _, sub := range cond.([]interface{}) {
+				if !matchesClause(md, sub.(map[string]interface{})) {
+					return false
+				}
+			}
+			continue
+		}
+		ops, _ := cond.(map[string]interface{})
+		v := fmt.Sprint(md[key])
+		if eq, ok := ops["$eq"]; ok && fmt.Sprint(eq) != v {
+			return false
+		}
+		if in, ok := ops["$in"].([]interface{}); ok {
+			found := false
+			for _, x := range in {
+				found = found || fmt.Sprint(x) == v
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *filterCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := &chroma.GetResultImpl{}
+	if op.Offset > 0 {
+		return res, nil
+	}
+	for _, r := range c.matching(op.Where) {
+		res.Ids = append(res.Ids, chroma.DocumentID(r.ID))
+		res.Documents = append(res.Documents, chroma.NewTextDocument(r.Document))
+		res.Metadatas = append(res.Metadatas, toDocumentMetadata(r.Metadata))
+	}
+	return res, nil
+}
+
+func (c *filterCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	op, err := chroma.NewCollectionAddOp(opts...)
+	if err != nil {
+		return err
+	}
+	for i, id := range op.Ids {
+		c.records[string(id)] = Record{ID: string(id), Document: op.Documents[i].ContentString(), Metadata: metadataToMap(op.Metadatas[i])}
+	}
+	return nil
+}
+
+func (c *filterCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		return err
+	}
+	for _, id := range op.Ids {
+		delete(c.records, string(id))
+	}
+	if op.Where != nil {
+		for _, r := range c.matching(op.Where) {
+			delete(c.records, r.ID)
+		}
+	}
+	return nil
+}
+
+type filterClient struct {
+	chroma.Client
+	collection *filterCollection
+}
+
+func (c filterClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func (c filterClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestIngestPathAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.md": "Alpha", "b.md": "Beta"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	col := &filterCollection{records: map[string]Record{}}
+	s := NewIngestService(filterClient{collection: col}).WithPathRoots([]string{dir}).WithFileIndex(&memFileIndex{files: map[string]config.IndexedFile{}})
+	spec := PathIngestSpec{Path: dir, Collection: "docs"}
+	chunksOf := func(name string) int {
+		n := 0
+		for _, r := range col.records {
+			if r.Metadata[DefaultSystemKeys.FileName] == name {
+				n++
+			}
+		}
+		return n
+	}
+
+	if report, err := s.IngestPath(ctx, spec); err != nil || report.Added != 2 {
+		t.Fatalf("unexpected first ingest %+v, %v", report, err)
+	}
+	if _, err := s.DeleteFile(ctx, "docs", fmt.Sprintf("%x", md5.Sum([]byte("Alpha")))); err != nil {
+		t.Fatal(err)
+	}
+	if chunksOf("a.md") != 0 {
+		t.Fatal("expected a.md deleted")
+	}
+	report, err := s.IngestPath(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Added != 1 || report.Unchanged != 1 || chunksOf("a.md") == 0 {
+		t.Errorf("expected the deleted file ingested again, got %+v", report)
+	}
+}
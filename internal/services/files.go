@@ -0,0 +1,108 @@
+package services
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// walkFiles returns regular files under root (as slash-separated paths
+// relative to root) that match any include glob and no exclude glob. An empty
+// include list matches everything.
+func walkFiles(root string, include, exclude []string) ([]string, error) {
+	inc, err := compileGlobs(include)
+	if err != nil {
+		return nil, err
+	}
+	exc, err := compileGlobs(exclude)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && matchAny(exc, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if (len(inc) == 0 || matchAny(inc, rel)) && !matchAny(exc, rel) {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	return out, err
+}
+
+type glob struct {
+	re       *regexp.Regexp
+	baseOnly bool
+}
+
+// compileGlobs compiles shell globs with "**" support. Patterns without a
+// slash match against the file's base name, so "*.md" matches at any depth.
+func compileGlobs(patterns []string) ([]glob, error) {
+	var out []glob
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile("^" + globToRegexp(p) + "$")
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, glob{re: re, baseOnly: !strings.Contains(p, "/")})
+	}
+	return out, nil
+}
+
+func globToRegexp(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+func matchAny(globs []glob, rel string) bool {
+	for _, g := range globs {
+		target := rel
+		if g.baseOnly {
+			target = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if g.re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
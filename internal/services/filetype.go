@@ -0,0 +1,207 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrUnsupportedType is returned for content no extractor can read, such
+// as executables, archives that reach ingest unexpanded or unknown binary
+// formats.
+var ErrUnsupportedType = errors.New("unsupported file type")
+
+// statusUnsupportedType is the IngestResult status for ErrUnsupportedType.
+const statusUnsupportedType = "unsupported_type"
+
+// mimeOctetStream is reported for binary content of no known type.
+const mimeOctetStream = "application/octet-stream"
+
+// FileType is a format the server can ingest, and the configuration it
+// needs when it isn't always available.
+type FileType struct {
+	MIME       string   `json:"mime"`
+	Extensions []string `json:"extensions"`
+	Extractor  string   `json:"extractor"`
+	Requires   string   `json:"requires,omitempty"`
+	Available  bool     `json:"available"`
+}
+
+// fileTypes lists the ingestible formats; the first extension of each is
+// used to route content whose name doesn't say what it is.
+var fileTypes = []FileType{
+	{MIME: "text/plain", Extensions: []string{".txt"}, Extractor: "text"},
+	{MIME: "text/markdown", Extensions: []string{".md", ".markdown"}, Extractor: "markdown"},
+	{MIME: "text/x-go", Extensions: []string{".go"}, Extractor: "code"},
+	{MIME: "text/x-python", Extensions: []string{".py"}, Extractor: "code"},
+	{MIME: "text/javascript", Extensions: []string{".js", ".jsx", ".mjs", ".cjs"}, Extractor: "code"},
+	{MIME: "application/typescript", Extensions: []string{".ts", ".tsx"}, Extractor: "code"},
+	{MIME: "application/xml", Extensions: []string{".xml", ".dita", ".ditamap", ".dbk"}, Extractor: "xml"},
+	{MIME: "application/pdf", Extensions: []string{".pdf"}, Extractor: "pdf"},
+	{MIME: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Extensions: []string{".docx"}, Extractor: "office"},
+	{MIME: "application/vnd.openxmlformats-officedocument.presentationml.presentation", Extensions: []string{".pptx"}, Extractor: "office"},
+	{MIME: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extensions: []string{".xlsx"}, Extractor: "office"},
+	{MIME: "application/epub+zip", Extensions: []string{".epub"}, Extractor: "epub"},
+	{MIME: "image/png", Extensions: []string{".png"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "image/jpeg", Extensions: []string{".jpg", ".jpeg"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "image/gif", Extensions: []string{".gif"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "image/webp", Extensions: []string{".webp"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "image/bmp", Extensions: []string{".bmp"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "image/tiff", Extensions: []string{".tif", ".tiff"}, Extractor: "image", Requires: "ocr_backend or image_backend"},
+	{MIME: "audio/mpeg", Extensions: []string{".mp3"}, Extractor: "transcription", Requires: "transcription_backend"},
+	{MIME: "audio/wav", Extensions: []string{".wav"}, Extractor: "transcription", Requires: "transcription_backend"},
+	{MIME: "audio/mp4", Extensions: []string{".m4a"}, Extractor: "transcription", Requires: "transcription_backend"},
+}
+
+func fileTypeByMIME(mime string) (FileType, bool) {
+	for _, t := range fileTypes {
+		if t.MIME == mime {
+			return t, true
+		}
+	}
+	return FileType{}, false
+}
+
+func fileTypeByExtension(ext string) (FileType, bool) {
+	for _, t := range fileTypes {
+		for _, e := range t.Extensions {
+			if e == ext {
+				return t, true
+			}
+		}
+	}
+	return FileType{}, false
+}
+
+// SupportedTypes lists the formats this server handles, marking those that
+// need an OCR, image or transcription backend it doesn't have, followed by
+// those registered extractors add. Text files with other extensions are
+// read as text/plain.
+func (s *IngestService) SupportedTypes() []FileType {
+	out := make([]FileType, len(fileTypes))
+	for i, t := range fileTypes {
+		switch t.Extractor {
+		case "image":
+			t.Available = s.ocr != nil || s.images != nil
+		case "transcription":
+			t.Available = s.transcriber != nil
+		default:
+			t.Available = true
+		}
+		out[i] = t
+	}
+	return append(out, registeredTypes()...)
+}
+
+// sniffType identifies content by its magic bytes, then by extension, and
+// finally as text/plain or application/octet-stream.
+func sniffType(filePath string, content []byte) string {
+	if mime := magicType(content); mime != "" {
+		return mime
+	}
+	if t, ok := fileTypeByExtension(strings.ToLower(path.Ext(filePath))); ok {
+		return t.MIME
+	}
+	sample := content
+	if len(sample) > charsetSampleSize {
+		sample = sample[:charsetSampleSize]
+	}
+	if _, ok := utf16Order(sample); ok || bytes.HasPrefix(content, utf16LEBOM) || bytes.HasPrefix(content, utf16BEBOM) || !looksBinary(sample) {
+		return "text/plain"
+	}
+	return mimeOctetStream
+}
+
+// magicSignatures map leading bytes to MIME types. Binary formats no
+// extractor reads map to application/octet-stream.
+var magicSignatures = []struct {
+	offset int
+	magic  string
+	mime   string
+}{
+	{0, "%PDF-", "application/pdf"},
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{8, "WEBP", "image/webp"},
+	{0, "BM", "image/bmp"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "ID3", "audio/mpeg"},
+	{8, "WAVE", "audio/wav"},
+	{4, "ftypM4A", "audio/mp4"},
+	{0, "\x7fELF", mimeOctetStream},
+	{0, "MZ", mimeOctetStream},
+	{0, "\xcf\xfa\xed\xfe", mimeOctetStream},
+	{0, "\x1f\x8b", mimeOctetStream},
+	{0, "7z\xbc\xaf\x27\x1c", mimeOctetStream},
+	{0, "Rar!\x1a\x07", mimeOctetStream},
+	{0, "SQLite format 3\x00", mimeOctetStream},
+}
+
+func magicType(content []byte) string {
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return zipType(content)
+	}
+	for _, sig := range magicSignatures {
+		if len(content) >= sig.offset+len(sig.magic) && string(content[sig.offset:sig.offset+len(sig.magic)]) == sig.magic {
+			// Two-byte signatures such as "BM" and "MZ" also start plain text
+			if len(sig.magic) == 2 && !looksBinary(content[:min(len(content), charsetSampleSize)]) {
+				continue
+			}
+			return sig.mime
+		}
+	}
+	return ""
+}
+
+// zipType tells zip-based document formats from plain archives by their
+// members.
+func zipType(content []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return mimeOctetStream
+	}
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "mimetype":
+			if rc, err := f.Open(); err == nil {
+				var b [32]byte
+				n, _ := rc.Read(b[:])
+				rc.Close()
+				if strings.TrimSpace(string(b[:n])) == "application/epub+zip" {
+					return "application/epub+zip"
+				}
+			}
+		case strings.HasPrefix(f.Name, "word/"):
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		case strings.HasPrefix(f.Name, "xl/"):
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		}
+	}
+	return mimeOctetStream
+}
+
+// routeFile returns the name extraction should use for content of the
+// given type: filePath itself when its extension names the type, or for
+// text, otherwise filePath with the type's extension appended, so a PDF
+// uploaded as "report" or a PNG named "notes.md" reach the right extractor.
+func routeFile(filePath, mime string) (string, error) {
+	t, ok := fileTypeByMIME(mime)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is %s", ErrUnsupportedType, filePath, mime)
+	}
+	if t.MIME == "text/plain" {
+		return filePath, nil
+	}
+	if byExt, known := fileTypeByExtension(strings.ToLower(path.Ext(filePath))); known && byExt.MIME == t.MIME {
+		return filePath, nil
+	}
+	return filePath + t.Extensions[0], nil
+}
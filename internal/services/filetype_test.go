@@ -0,0 +1,66 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSniffType(t *testing.T) {
+	var docx bytes.Buffer
+	zw := zip.NewWriter(&docx)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte("<w:document/>"))
+	zw.Close()
+	var archive bytes.Buffer
+	zw = zip.NewWriter(&archive)
+	w, _ = zw.Create("notes.txt")
+	w.Write([]byte("hi"))
+	zw.Close()
+
+	cases := []struct {
+		name    string
+		content []byte
+		mime    string
+		route   string
+	}{
+		{"report", []byte("%PDF-1.7\n..."), "application/pdf", "report.pdf"},
+		{"notes.md", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "image/png", "notes.md.png"},
+		{"letter", docx.Bytes(), "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "letter.docx"},
+		{"guide.md", []byte("# Guide"), "text/markdown", "guide.md"},
+		{"data.csv", []byte("a,b\n1,2\n"), "text/plain", "data.csv"},
+		{"BMW.txt", []byte("BMW makes cars"), "text/plain", "BMW.txt"},
+		{"tool", []byte("\x7fELF\x02\x01\x01\x00\x00\x00"), mimeOctetStream, ""},
+		{"bundle.zip", archive.Bytes(), mimeOctetStream, ""},
+	}
+	for _, tc := range cases {
+		mime := sniffType(tc.name, tc.content)
+		if mime != tc.mime {
+			t.Errorf("%s: sniffed %s, want %s", tc.name, mime, tc.mime)
+			continue
+		}
+		route, err := routeFile(tc.name, mime)
+		if tc.route == "" {
+			if !errors.Is(err, ErrUnsupportedType) {
+				t.Errorf("%s: expected ErrUnsupportedType, got %v", tc.name, err)
+			}
+		} else if route != tc.route {
+			t.Errorf("%s: routed to %q, want %q (%v)", tc.name, route, tc.route, err)
+		}
+	}
+}
+
+func TestSupportedTypes(t *testing.T) {
+	s := NewIngestService(nil)
+	for _, ft := range s.SupportedTypes() {
+		if want := ft.Requires == ""; ft.Available != want {
+			t.Errorf("%s: available %v without backends", ft.MIME, ft.Available)
+		}
+	}
+	prepared, err := s.prepareChunks(context.Background(), "docs", "a.out", []byte("\x7fELF\x02\x01\x01\x00"), "x", IngestOptions{})
+	if !errors.Is(err, ErrUnsupportedType) || prepared != nil {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
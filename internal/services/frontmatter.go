@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelims maps each opening fence to its format: YAML between
+// "---" lines, TOML between "+++" lines.
+var frontMatterDelims = map[string]string{"---": "yaml", "+++": "toml"}
+
+// splitFrontMatter separates a Markdown document's front matter from its
+// body and converts it to user_ metadata. Documents without front matter,
+// or with front matter that doesn't parse, are returned unchanged.
+func splitFrontMatter(content []byte) (map[string]interface{}, []byte) {
+	first, rest, ok := strings.Cut(strings.TrimPrefix(string(content), "\ufeff"), "\n")
+	delim := strings.TrimRight(first, " \t\r")
+	format, known := frontMatterDelims[delim]
+	if !ok || !known {
+		return nil, content
+	}
+	lines := strings.SplitAfter(rest, "\n")
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t\r\n") != delim {
+			continue
+		}
+		md, err := parseFrontMatter(format, []byte(strings.Join(lines[:i], "")))
+		if err != nil {
+			return nil, content
+		}
+		return md, []byte(strings.Join(lines[i+1:], ""))
+	}
+	return nil, content // unterminated
+}
+
+func parseFrontMatter(format string, raw []byte) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	var err error
+	if format == "yaml" {
+		err = yaml.Unmarshal(raw, &fields)
+	} else {
+		err = toml.Unmarshal(raw, &fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+	md := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if v, ok := frontMatterValue(value); ok && key != "" {
+			md[userMetadataPrefix+key] = v
+		}
+	}
+	return md, nil
+}
+
+// frontMatterValue converts a front matter value to a metadata value:
+// scalars are kept, dates become "2006-01-02" (or RFC 3339 with a time of
+// day) and lists of scalars are joined with ", ". Tables are dropped.
+func frontMatterValue(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case string, bool, int, int64, float64:
+		return val, true
+	case time.Time:
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 && val.Nanosecond() == 0 {
+			return val.Format(time.DateOnly), true
+		}
+		return val.Format(time.RFC3339), true
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := frontMatterValue(item)
+			if !ok {
+				return nil, false
+			}
+			parts = append(parts, fmt.Sprint(s))
+		}
+		return strings.Join(parts, ", "), len(parts) > 0
+	case fmt.Stringer: // TOML local dates and times
+		return val.String(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// gitIngestedRef marks, in each checkout, the commit last ingested without
+// errors; the next run ingests only what changed since.
+const (
+	gitIngestedRef  = "refs/forge/ingested"
+	maxGitFileBytes = 1 << 20
+)
+
+// DefaultGitExtensions are the file types ingested when a spec lists none.
+var DefaultGitExtensions = []string{
+	".md", ".markdown", ".mdx", ".rst", ".adoc", ".txt",
+	".go", ".py", ".js", ".jsx", ".ts", ".tsx", ".java", ".kt", ".rb", ".rs", ".c", ".h", ".cpp", ".cs", ".php", ".swift", ".sh",
+	".html", ".css", ".sql", ".yaml", ".yml", ".toml", ".json",
+}
+
+var (
+	// ErrInvalidGit is returned for an unusable git ingest spec.
+	ErrInvalidGit = errors.New("invalid git spec")
+	// ErrGitFailed is returned when cloning or fetching the repository fails.
+	ErrGitFailed = errors.New("git failed")
+	// ErrGitRunning is returned when the same repository is already being
+	// ingested into the collection.
+	ErrGitRunning = errors.New("git ingest is already running")
+)
+
+// GitSpec selects a repository branch to ingest into a collection.
+type GitSpec struct {
+	URL        string                 `json:"url"`
+	Branch     string                 `json:"branch,omitempty"` // default: the remote's default branch
+	Collection string                 `json:"collection"`
+	Extensions []string               `json:"extensions,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	ACL        []string               `json:"acl,omitempty"`
+	// Full re-ingests every file, dropping the repository's earlier chunks.
+	Full bool `json:"full,omitempty"`
+}
+
+// Validate checks the spec and normalizes its extensions.
+func (g *GitSpec) Validate() error {
+	if g.URL == "" || strings.HasPrefix(g.URL, "-") {
+		return fmt.Errorf("%w: url is required", ErrInvalidGit)
+	}
+	if u, err := url.Parse(g.URL); err == nil && u.Scheme != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git", "file":
+		default:
+			return fmt.Errorf("%w: unsupported url scheme %q", ErrInvalidGit, u.Scheme)
+		}
+	} else if host, _, ok := strings.Cut(g.URL, ":"); !ok || !strings.Contains(host, "@") || strings.Contains(host, "/") {
+		// Only scp-style user@host:path is accepted without a scheme; git
+		// reads anything with a slash before the colon as a local path
+		return fmt.Errorf("%w: url must be a git URL", ErrInvalidGit)
+	}
+	if strings.HasPrefix(g.Branch, "-") || strings.ContainsAny(g.Branch, " \t\n~^:?*[\\") || strings.Contains(g.Branch, "..") {
+		return fmt.Errorf("%w: invalid branch %q", ErrInvalidGit, g.Branch)
+	}
+	if g.Collection == "" {
+		return fmt.Errorf("%w: collection is required", ErrInvalidGit)
+	}
+	if len(g.Extensions) == 0 {
+		g.Extensions = append([]string(nil), DefaultGitExtensions...)
+	}
+	for i, ext := range g.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if len(ext) < 2 {
+			return fmt.Errorf("%w: empty extension", ErrInvalidGit)
+		}
+		g.Extensions[i] = ext
+	}
+	return nil
+}
+
+// GitRun reports one ingest of a repository.
+type GitRun struct {
+	Repo     string         `json:"repo"`
+	Branch   string         `json:"branch"`
+	Commit   string         `json:"commit"`
+	Previous string         `json:"previous,omitempty"` // commit the run diffed against; empty for full runs
+	Results  []IngestResult `json:"results"`
+	Deleted  []string       `json:"deleted,omitempty"`
+	Errors   []string       `json:"errors,omitempty"`
+	Duration string         `json:"duration"`
+	Timings  IngestTimings  `json:"timings"`
+}
+
+// GitService clones repositories into dir and ingests their files. The
+// checkouts are kept so later runs only fetch and re-ingest changes.
+type GitService struct {
+	ingest *IngestService
+	dir    string
+
+	// ingestFile and deleteFile write to the collection, replaceable in tests.
+	ingestFile func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error)
+	deleteFile func(ctx context.Context, collection, name, sourceID string) error
+
+	// insecure allows the unencrypted http and git transports.
+	insecure bool
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewGitService(ingest *IngestService, dir string) *GitService {
+	return &GitService{ingest: ingest, dir: dir, ingestFile: ingest.IngestFileWithOptions, deleteFile: ingest.deleteFileChunks, running: make(map[string]bool)}
+}
+
+// WithInsecureTransports allows http:// and git:// repository URLs, which
+// are refused by default since neither authenticates the server.
+func (s *GitService) WithInsecureTransports(allow bool) *GitService {
+	s.insecure = allow
+	return s
+}
+
+// checkTransport refuses URLs the operator hasn't allowed: plain http and
+// git without WithInsecureTransports, and file URLs outside the ingest
+// service's path roots, so a request can't clone any repository on the
+// server.
+func (s *GitService) checkTransport(spec GitSpec) error {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return nil // scp-style ssh
+	}
+	switch u.Scheme {
+	case "http", "git":
+		if !s.insecure {
+			return fmt.Errorf("%w: %s URLs are not allowed", ErrInvalidGit, u.Scheme)
+		}
+	case "file":
+		if len(s.ingest.pathRoots) == 0 {
+			return fmt.Errorf("%w: file URLs need ingest_path_roots", ErrPathNotAllowed)
+		}
+		if _, err := s.ingest.allowedPath(u.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run clones or updates the repository and ingests the files that changed
+// since the last clean run (all of them on the first run or with Full).
+// Files are named by their path in the repository and tagged with repo,
+// path, branch and commit metadata.
+func (s *GitService) Run(ctx context.Context, spec GitSpec) (*GitRun, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkTransport(spec); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(spec.Collection + "\x00" + spec.URL + "\x00" + spec.Branch))
+	key := hex.EncodeToString(sum[:8])
+
+	s.mu.Lock()
+	if s.running[key] {
+		s.mu.Unlock()
+		return nil, ErrGitRunning
+	}
+	s.running[key] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, key)
+		s.mu.Unlock()
+	}()
+
+	run := &GitRun{Repo: spec.URL}
+	started := time.Now()
+	checkout := filepath.Join(s.dir, key)
+	branch, err := s.sync(ctx, checkout, spec)
+	if err != nil {
+		return nil, err
+	}
+	run.Branch = branch
+	if run.Commit, err = git(ctx, checkout, "rev-parse", "HEAD"); err != nil {
+		return nil, err
+	}
+	prev, _ := git(ctx, checkout, "rev-parse", "-q", "--verify", gitIngestedRef+"^{commit}")
+
+	source := IngestSource{ID: "git-" + key, Kind: SourceGit, Ref: spec.URL}
+	var changed, deleted []string
+	if prev == "" || spec.Full {
+		if prev != "" {
+			// Drop chunks of files removed since, along with everything else.
+			// This fails if the collection was deleted, leaving nothing to drop.
+			if err := s.deleteFile(ctx, spec.Collection, "", source.ID); err != nil {
+				logging.FromContext(ctx).WithError(err).WithField("repo", spec.URL).Warn("Failed to drop earlier git chunks")
+			}
+		}
+		out, err := git(ctx, checkout, "ls-files", "-z")
+		if err != nil {
+			return nil, err
+		}
+		changed = splitNul(out)
+	} else if prev != run.Commit {
+		run.Previous = prev
+		out, err := git(ctx, checkout, "diff", "--name-status", "--no-renames", "-z", prev, run.Commit)
+		if err != nil {
+			return nil, err
+		}
+		fields := splitNul(out)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "D" {
+				deleted = append(deleted, fields[i+1])
+			} else {
+				changed = append(changed, fields[i+1])
+			}
+		}
+	}
+	changed, err = s.ingestable(ctx, checkout, changed, spec.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{"git": spec.URL, "state": "running"}})
+	for _, name := range deleted {
+		if err := s.deleteFile(ctx, spec.Collection, name, source.ID); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		run.Deleted = append(run.Deleted, name)
+	}
+	for _, name := range changed {
+		if ctx.Err() != nil {
+			run.Errors = append(run.Errors, ctx.Err().Error())
+			break
+		}
+		s.ingestOne(ctx, run, spec, source, checkout, name, prev != "" && !spec.Full)
+	}
+
+	if len(run.Errors) == 0 {
+		if _, err := git(ctx, checkout, "update-ref", gitIngestedRef, run.Commit); err != nil {
+			run.Errors = append(run.Errors, err.Error())
+		}
+	}
+	run.Duration = time.Since(started).Round(time.Millisecond).String()
+	run.Timings = sumTimings(run.Results)
+	if run.Results == nil {
+		run.Results = []IngestResult{}
+	}
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{
+		"git":    spec.URL,
+		"state":  "finished",
+		"commit": run.Commit,
+		"files":  len(run.Results),
+		"errors": len(run.Errors),
+	}})
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"repo":    spec.URL,
+		"commit":  run.Commit,
+		"files":   len(run.Results),
+		"deleted": len(run.Deleted),
+		"errors":  len(run.Errors),
+	}).Info("Git ingest complete")
+	return run, nil
+}
+
+// ingestOne ingests a file, first dropping the chunks of its earlier version
+// when replace is set.
+func (s *GitService) ingestOne(ctx context.Context, run *GitRun, spec GitSpec, source IngestSource, checkout, name string, replace bool) {
+	fail := func(err error) {
+		run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", name, err))
+		run.Results = append(run.Results, IngestResult{Status: "error", File: name})
+	}
+	content, err := os.ReadFile(filepath.Join(checkout, filepath.FromSlash(name)))
+	if err != nil {
+		fail(err)
+		return
+	}
+	if replace {
+		if err := s.deleteFile(ctx, spec.Collection, name, source.ID); err != nil {
+			fail(err)
+			return
+		}
+	}
+	metadata := make(map[string]interface{}, len(spec.Metadata)+4)
+	for k, v := range spec.Metadata {
+		metadata[k] = v
+	}
+	metadata["repo"], metadata["path"], metadata["branch"], metadata["commit"] = spec.URL, name, run.Branch, run.Commit
+	res, err := s.ingestFile(ctx, spec.Collection, name, content, IngestOptions{Metadata: metadata, ACL: spec.ACL, Source: source})
+	if err != nil {
+		fail(err)
+		return
+	}
+	run.Results = append(run.Results, *res)
+}
+
+// sync clones the repository into checkout, or fetches and checks out the
+// branch's latest commit, returning the branch.
+func (s *GitService) sync(ctx context.Context, checkout string, spec GitSpec) (string, error) {
+	if _, err := os.Stat(filepath.Join(checkout, ".git")); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return "", err
+		}
+		args := []string{"clone", "--quiet", "--depth", "1", "--single-branch"}
+		if spec.Branch != "" {
+			args = append(args, "--branch", spec.Branch)
+		}
+		if _, err := git(ctx, "", append(args, "--", spec.URL, checkout)...); err != nil {
+			_ = os.RemoveAll(checkout)
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	branch := spec.Branch
+	if branch == "" {
+		var err error
+		if branch, err = git(ctx, checkout, "rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := git(ctx, checkout, "fetch", "--quiet", "--depth", "1", "origin", branch); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, checkout, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// ingestable keeps the regular files with an allowed extension that are
+// not ignored by .gitignore (even if tracked) and not too large.
+func (s *GitService) ingestable(ctx context.Context, checkout string, names, extensions []string) ([]string, error) {
+	var candidates []string
+	for _, name := range names {
+		ext := strings.ToLower(path.Ext(name))
+		for _, allowed := range extensions {
+			if ext == allowed {
+				candidates = append(candidates, name)
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	ignored := map[string]bool{}
+	out, err := gitInput(ctx, checkout, strings.Join(candidates, "\x00")+"\x00", "check-ignore", "--no-index", "-z", "--stdin")
+	var exit *exec.ExitError
+	if err != nil && !(errors.As(err, &exit) && exit.ExitCode() == 1) { // 1: nothing ignored
+		return nil, err
+	}
+	for _, name := range splitNul(out) {
+		ignored[name] = true
+	}
+	var keep []string
+	for _, name := range candidates {
+		if ignored[name] {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(checkout, filepath.FromSlash(name)))
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxGitFileBytes {
+			continue
+		}
+		keep = append(keep, name)
+	}
+	return keep, nil
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	return gitInput(ctx, dir, "", args...)
+}
+
+func gitInput(ctx context.Context, dir, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%w: git %s: %w: %s", ErrGitFailed, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func splitNul(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, "\x00") {
+		if f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// deleteFileChunks deletes the chunks sourceID wrote for file name, or all
+// of the source's chunks when name is empty.
+func (s *IngestService) deleteFileChunks(ctx context.Context, collectionName, name, sourceID string) error {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("get collection %q: %w", collectionName, err)
+	}
+	filter := map[string]string{s.keys.SourceID: sourceID}
+	if name != "" {
+		filter[s.keys.FileName] = name
+	}
+	var clauses []chroma.WhereClause
+	for k, v := range filter {
+		clauses = append(clauses, chroma.EqString(k, v))
+	}
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: collectionName, Op: IntentDelete, Filter: filter, Ref: name})
+	if err != nil {
+		return err
+	}
+	err = collection.Delete(ctx, chroma.WithWhereDelete(andWhere(clauses)))
+	finish(err)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		s.forgetIndexed(ctx, collectionName, sourceID)
+	} else {
+		s.forgetIndexed(ctx, collectionName, sourceID, name)
+	}
+	s.publishChange(EventDeleted, collectionName, map[string]interface{}{"source_id": sourceID, "file": name})
+	return nil
+}
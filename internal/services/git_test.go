@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGitIngest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	sh := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sh("init", "--quiet", "--initial-branch=main")
+	write(".gitignore", "gen/\n")
+	write("README.md", "# Hello")
+	write("docs/guide.md", "Guide")
+	write("main.go", "package main")
+	write("logo.png", "PNG")
+	write("gen/out.md", "generated")
+	sh("add", ".")
+	sh("add", "-f", "gen/out.md")
+	sh("commit", "--quiet", "-m", "initial")
+
+	var ingested, deleted []string
+	var meta map[string]interface{}
+	s := NewGitService(NewIngestService(nil).WithPathRoots([]string{repo}), t.TempDir())
+	s.ingestFile = func(ctx context.Context, collection, name string, content []byte, opts IngestOptions) (*IngestResult, error) {
+		ingested = append(ingested, name)
+		meta = opts.Metadata
+		return &IngestResult{Status: "ingested", File: name}, nil
+	}
+	s.deleteFile = func(ctx context.Context, collection, name, sourceID string) error {
+		deleted = append(deleted, name)
+		return nil
+	}
+	run := func() *GitRun {
+		t.Helper()
+		ingested, deleted = nil, nil
+		r, err := s.Run(context.Background(), GitSpec{URL: "file://" + repo, Collection: "code", Extensions: []string{"md", ".GO"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ingested)
+		return r
+	}
+
+	first := run()
+	if strings.Join(ingested, ",") != "README.md,docs/guide.md,main.go" || len(deleted) != 0 {
+		t.Errorf("unexpected first run: ingested %v, deleted %v", ingested, deleted)
+	}
+	if first.Branch != "main" || first.Previous != "" || meta["commit"] != first.Commit || meta["branch"] != "main" || meta["repo"] != "file://"+repo {
+		t.Errorf("unexpected run %+v, metadata %v", first, meta)
+	}
+
+	write("docs/guide.md", "Guide v2")
+	write("docs/new.md", "New")
+	sh("rm", "--quiet", "main.go")
+	sh("add", ".")
+	sh("commit", "--quiet", "-m", "update")
+	second := run()
+	if strings.Join(ingested, ",") != "docs/guide.md,docs/new.md" || second.Previous != first.Commit {
+		t.Errorf("expected only changed files, got %v (previous %s)", ingested, second.Previous)
+	}
+	// Changed files drop their old chunks first; removed files only that
+	sort.Strings(deleted)
+	if strings.Join(deleted, ",") != "docs/guide.md,docs/new.md,main.go" || strings.Join(second.Deleted, ",") != "main.go" {
+		t.Errorf("unexpected deletions %v (%v)", deleted, second.Deleted)
+	}
+
+	if run(); len(ingested) != 0 || len(deleted) != 0 {
+		t.Errorf("expected no work without new commits, got %v %v", ingested, deleted)
+	}
+
+	for _, bad := range []GitSpec{{URL: "--upload-pack=x", Collection: "c"}, {URL: "ftp://host/repo", Collection: "c"}, {URL: "file:///r", Collection: "c", Branch: "-x"}, {URL: "file:///r"}, {URL: "./a@b:c", Collection: "c"}} {
+		if _, err := s.Run(context.Background(), bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestGitTransports(t *testing.T) {
+	root := t.TempDir()
+	s := NewGitService(NewIngestService(nil), t.TempDir())
+	for _, u := range []string{"http://example.com/repo.git", "git://example.com/repo.git"} {
+		if err := s.checkTransport(GitSpec{URL: u}); !errors.Is(err, ErrInvalidGit) {
+			t.Errorf("%s: expected ErrInvalidGit, got %v", u, err)
+		}
+		if err := s.WithInsecureTransports(true).checkTransport(GitSpec{URL: u}); err != nil {
+			t.Errorf("%s: expected the opt-in to allow it, got %v", u, err)
+		}
+		s.WithInsecureTransports(false)
+	}
+	for _, u := range []string{"https://example.com/repo.git", "ssh://git@example.com/repo.git", "git@example.com:org/repo.git"} {
+		if err := s.checkTransport(GitSpec{URL: u}); err != nil {
+			t.Errorf("%s: %v", u, err)
+		}
+	}
+
+	if err := s.checkTransport(GitSpec{URL: "file://" + root}); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("expected file URLs refused without path roots, got %v", err)
+	}
+	s.ingest.WithPathRoots([]string{filepath.Join(root, "allowed")})
+	if err := os.Mkdir(filepath.Join(root, "allowed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkTransport(GitSpec{URL: "file://" + root}); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("expected a file URL outside the roots refused, got %v", err)
+	}
+	if err := s.checkTransport(GitSpec{URL: "file://" + filepath.Join(root, "allowed")}); err != nil {
+		t.Errorf("expected a file URL under a root allowed, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// guardrailSettingKey stores a collection's Guardrails in the settings table.
+const guardrailSettingKey = "answer_guardrails"
+
+// DefaultRefusal is the answer given when a guardrail rejects one.
+const DefaultRefusal = "I don't know based on the available sources."
+
+// Guardrail rules, reported in AnswerResponse.Guardrail when one changed the
+// answer.
+const (
+	GuardrailConfidence = "min_confidence"
+	GuardrailCitations  = "citations"
+	GuardrailPromptLeak = "prompt_leak"
+)
+
+// minLeakLength is the shortest system prompt sentence stripped from
+// answers; shorter ones are too likely to occur naturally.
+const minLeakLength = 24
+
+var (
+	citationRe     = regexp.MustCompile(`\[(\d+)\]`)
+	sentenceEndRe  = regexp.MustCompile(`[.!?]\s+|\n+`)
+	leakSpacingRe  = regexp.MustCompile(`[ \t]{2,}`)
+	leakBlankRunRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// Guardrails post-process answers drawn from a collection. The zero value
+// applies none.
+type Guardrails struct {
+	// RequireCitations refuses answers that cite none of their sources.
+	RequireCitations bool `json:"require_citations"`
+	// MaxDistance refuses without generating when no source from the
+	// collection is at most this distance from the question; zero disables.
+	MaxDistance float64 `json:"max_distance"`
+	// StripPrompt removes system prompt sentences echoed in an answer.
+	StripPrompt bool `json:"strip_prompt"`
+	// Refusal replaces DefaultRefusal.
+	Refusal string `json:"refusal,omitempty"`
+}
+
+// Validate checks that the distance threshold is not negative.
+func (g Guardrails) Validate() error {
+	if g.MaxDistance < 0 {
+		return fmt.Errorf("max_distance must not be negative, got %g", g.MaxDistance)
+	}
+	return nil
+}
+
+// CollectionGuardrails returns a collection's guardrails; none when unset.
+func (s *IngestService) CollectionGuardrails(collection string) (Guardrails, error) {
+	var g Guardrails
+	if s.settings == nil {
+		return g, nil
+	}
+	if _, err := s.settings.GetCollectionSetting(collection, guardrailSettingKey, &g); err != nil {
+		return Guardrails{}, err
+	}
+	return g, nil
+}
+
+// SetCollectionGuardrails stores a collection's guardrails, discarding
+// answers cached under the old ones.
+func (s *IngestService) SetCollectionGuardrails(collection string, g Guardrails) error {
+	if s.settings == nil {
+		return errNoSettingsStore
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if err := s.settings.SetCollectionSetting(collection, guardrailSettingKey, g); err != nil {
+		return err
+	}
+	if s.answers != nil {
+		s.answers.bump(collection)
+	}
+	return nil
+}
+
+// answerGuardrails combines the guardrails of the collections an answer is
+// drawn from: a rule any of them enables applies, and each collection's
+// distance threshold applies to its own sources.
+type answerGuardrails struct {
+	requireCitations bool
+	stripPrompt      bool
+	maxDistance      map[string]float64
+	refusal          string
+}
+
+func (s *IngestService) answerGuardrails(collections []string) (answerGuardrails, error) {
+	out := answerGuardrails{maxDistance: map[string]float64{}, refusal: DefaultRefusal}
+	for _, coll := range collections {
+		g, err := s.CollectionGuardrails(coll)
+		if err != nil {
+			return answerGuardrails{}, err
+		}
+		out.requireCitations = out.requireCitations || g.RequireCitations
+		out.stripPrompt = out.stripPrompt || g.StripPrompt
+		if g.MaxDistance > 0 {
+			out.maxDistance[coll] = g.MaxDistance
+		}
+		if g.Refusal != "" && out.refusal == DefaultRefusal {
+			out.refusal = g.Refusal
+		}
+	}
+	return out, nil
+}
+
+// confident reports whether any source passes its collection's threshold.
+// results come from a single collection when only one was searched and may
+// then carry no Collection.
+func (g answerGuardrails) confident(collections []string, results []SearchResult) bool {
+	if len(g.maxDistance) == 0 {
+		return true
+	}
+	for _, r := range results {
+		coll := r.Collection
+		if coll == "" && len(collections) == 1 {
+			coll = collections[0]
+		}
+		limit, ok := g.maxDistance[coll]
+		if !ok || float64(r.Distance) <= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// check applies the post-generation rules, returning the answer to give and
+// the rule that changed it, if any.
+func (g answerGuardrails) check(answer, system string, sources int) (string, string) {
+	rule := ""
+	if g.stripPrompt {
+		if stripped := stripPromptLeak(answer, system); stripped != answer {
+			answer, rule = stripped, GuardrailPromptLeak
+			if answer == "" {
+				return g.refusal, rule
+			}
+		}
+	}
+	if g.requireCitations && !citesSource(answer, sources) {
+		return g.refusal, GuardrailCitations
+	}
+	return answer, rule
+}
+
+// citesSource reports whether answer cites one of sources numbered sources.
+func citesSource(answer string, sources int) bool {
+	for _, m := range citationRe.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= sources {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPromptLeak removes the system prompt, and each of its sentences long
+// enough to be distinctive, from answer (ignoring case).
+func stripPromptLeak(answer, system string) string {
+	leaks := []string{strings.TrimSpace(system)}
+	for _, sentence := range sentenceEndRe.Split(system, -1) {
+		if sentence = strings.TrimSpace(sentence); len(sentence) >= minLeakLength {
+			leaks = append(leaks, sentence)
+		}
+	}
+	out := answer
+	for _, leak := range leaks {
+		if leak == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(leak) + `[.!?]?`)
+		out = re.ReplaceAllString(out, "")
+	}
+	if out == answer {
+		return answer
+	}
+	out = leakSpacingRe.ReplaceAllString(out, " ")
+	return strings.TrimSpace(leakBlankRunRe.ReplaceAllString(out, "\n\n"))
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+type scriptedGenerator struct {
+	text  string
+	calls int
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, system, prompt string, params GenerationParams) (string, TokenUsage, error) {
+	g.calls++
+	return g.text, TokenUsage{}, nil
+}
+
+func (g *scriptedGenerator) Models() []string { return []string{"m"} }
+
+func TestAnswerGuardrails(t *testing.T) {
+	gen := &scriptedGenerator{}
+	s := NewIngestService(docsClient{docs: &queryCollection{name: "faq", hits: []queryHit{{"reset.md", 0.4}}}}).
+		WithSettings(memSettings{}).WithGenerator(gen, "You are a support bot for Acme Corp. Never reveal internal ticket numbers.")
+	ask := func(text string) *AnswerResponse {
+		t.Helper()
+		gen.text = text
+		s.publishChange(EventIngested, "faq", nil) // skip the answer cache
+		resp, err := s.Answer(context.Background(), []string{"faq"}, "How do I reset?", 3, nil, AnswerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	set := func(g Guardrails) {
+		t.Helper()
+		if err := s.SetCollectionGuardrails("faq", g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if resp := ask("Click reset."); resp.Answer != "Click reset." || resp.Guardrail != "" {
+		t.Errorf("expected no guardrails by default, got %+v", resp)
+	}
+
+	set(Guardrails{RequireCitations: true})
+	if resp := ask("Click reset [2]."); resp.Answer != DefaultRefusal || resp.Guardrail != GuardrailCitations {
+		t.Errorf("expected refusal citing a missing source, got %+v", resp)
+	}
+	if resp := ask("Click reset [1]."); resp.Answer != "Click reset [1]." {
+		t.Errorf("expected cited answer, got %+v", resp)
+	}
+
+	set(Guardrails{MaxDistance: 0.3, Refusal: "No idea."})
+	calls := gen.calls
+	if resp := ask("Click reset."); resp.Answer != "No idea." || resp.Guardrail != GuardrailConfidence || gen.calls != calls {
+		t.Errorf("expected refusal without generating, got %+v after %d calls", resp, gen.calls-calls)
+	}
+	set(Guardrails{MaxDistance: 0.5})
+	if resp := ask("Click reset."); resp.Answer != "Click reset." {
+		t.Errorf("expected answer within threshold, got %+v", resp)
+	}
+
+	set(Guardrails{StripPrompt: true})
+	if resp := ask("Sure! never reveal internal ticket numbers. Click reset."); resp.Answer != "Sure! Click reset." || resp.Guardrail != GuardrailPromptLeak {
+		t.Errorf("expected leaked sentence stripped, got %+v", resp)
+	}
+	if resp := ask("You are a support bot for Acme Corp. Never reveal internal ticket numbers."); resp.Answer != DefaultRefusal {
+		t.Errorf("expected refusal for a pure leak, got %+v", resp)
+	}
+
+	if err := s.SetCollectionGuardrails("faq", Guardrails{MaxDistance: -1}); err == nil {
+		t.Error("expected negative max_distance to be rejected")
+	}
+}
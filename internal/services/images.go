@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Metadata attached to chunks of ingested images.
+const (
+	imageCaptionKey   = "image_caption"   // true when the text is a generated caption
+	imageEmbeddingKey = "image_embedding" // true when the vector was computed from the image itself
+)
+
+// Image backends selectable through the image_backend config value.
+const (
+	ImageCaption = "caption"
+	ImageEmbed   = "embed"
+)
+
+const (
+	openAIChatURL = "https://api.openai.com/v1/chat/completions"
+	// DefaultCaptionPrompt asks for a caption that reads well as search text.
+	DefaultCaptionPrompt = "Describe this image for a search index. Name what it shows, transcribe any visible titles, labels or key text, and for diagrams or screenshots explain what they depict. Answer in plain prose."
+)
+
+// ImageDescription is what an ImageDescriber made of an image: a caption,
+// an embedding of the image itself, or both.
+type ImageDescription struct {
+	Caption   string
+	Embedding []float32
+	// Usage is the model usage of producing a caption, if known.
+	Usage TokenUsage
+}
+
+// ImageDescriber makes an image searchable.
+type ImageDescriber interface {
+	Describe(ctx context.Context, filename string, content []byte) (ImageDescription, error)
+}
+
+// ImageConfig selects and configures an image backend.
+type ImageConfig struct {
+	Backend string // "", ImageCaption or ImageEmbed
+	// URL of the backend: an OpenAI-compatible chat completions endpoint for
+	// captions (defaulted to OpenAI's), or the embedding service (required).
+	URL    string
+	APIKey string
+	// Model is the vision model used for captions.
+	Model  string
+	Prompt string // caption prompt, default DefaultCaptionPrompt
+}
+
+// NewImageDescriber builds the configured backend, or returns nil when
+// Backend is empty.
+func NewImageDescriber(cfg ImageConfig) (ImageDescriber, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case ImageCaption:
+		c := &VisionCaptioner{URL: cfg.URL, APIKey: cfg.APIKey, Model: cfg.Model, Prompt: cfg.Prompt, client: &http.Client{Timeout: 5 * time.Minute}}
+		if c.URL == "" {
+			c.URL = openAIChatURL
+		}
+		if c.Model == "" {
+			return nil, errors.New("caption backend requires image_model")
+		}
+		if c.Prompt == "" {
+			c.Prompt = DefaultCaptionPrompt
+		}
+		return c, nil
+	case ImageEmbed:
+		if cfg.URL == "" {
+			return nil, errors.New("embed backend requires image_url")
+		}
+		return &HTTPImageEmbedder{URL: cfg.URL, APIKey: cfg.APIKey, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+	default:
+		return nil, fmt.Errorf("unknown image backend %q", cfg.Backend)
+	}
+}
+
+// WithImages ingests images by captioning or embedding them rather than
+// through OCR alone.
+func (s *IngestService) WithImages(d ImageDescriber) *IngestService {
+	s.images = d
+	return s
+}
+
+// describeImage turns an image into sections. A caption becomes a section
+// of its own, followed by any OCR text. An image embedding yields a single
+// section carrying the vector, whose text (caption and OCR text, else the
+// file name) is what search results show.
+func (s *IngestService) describeImage(ctx context.Context, collectionName, filePath string, content []byte) ([]docSection, error) {
+	desc, err := s.images.Describe(ctx, filePath, content)
+	if err != nil {
+		return nil, fmt.Errorf("describe image %s: %w", filePath, err)
+	}
+	if desc.Usage.Model != "" {
+		s.costs.Record(ctx, collectionName, CostGeneration, desc.Usage)
+	}
+	var recognized []docSection
+	if s.ocr != nil {
+		// Recognized text helps but is not required once the image is described
+		if recognized, err = s.recognize(ctx, filePath, content); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("file", filePath).Warn("OCR failed; ingesting image description only")
+			recognized = nil
+		}
+	}
+	caption := strings.TrimSpace(desc.Caption)
+
+	if len(desc.Embedding) > 0 {
+		parts := []string{caption}
+		for _, sec := range recognized {
+			parts = append(parts, strings.TrimSpace(sec.text))
+		}
+		text := strings.TrimSpace(strings.Join(parts, "\n\n"))
+		if text == "" {
+			text = path.Base(filePath)
+		}
+		return []docSection{{text: text, metadata: map[string]interface{}{imageEmbeddingKey: true}, embedding: desc.Embedding}}, nil
+	}
+	if caption == "" {
+		return recognized, nil
+	}
+	return append([]docSection{{text: caption, metadata: map[string]interface{}{imageCaptionKey: true}}}, recognized...), nil
+}
+
+// VisionCaptioner captions images with a vision model behind an
+// OpenAI-compatible chat completions API (OpenAI, Ollama, vLLM, ...).
+type VisionCaptioner struct {
+	URL    string
+	APIKey string
+	Model  string
+	Prompt string
+	client *http.Client
+}
+
+func (c *VisionCaptioner) Describe(ctx context.Context, filename string, content []byte) (ImageDescription, error) {
+	dataURL := "data:" + imageContentType(filename) + ";base64," + base64.StdEncoding.EncodeToString(content)
+	body, err := json.Marshal(map[string]any{
+		"model": c.Model,
+		"messages": []map[string]any{{
+			"role": "user",
+			"content": []map[string]any{
+				{"type": "text", "text": c.Prompt},
+				{"type": "image_url", "image_url": map[string]string{"url": dataURL}},
+			},
+		}},
+	})
+	if err != nil {
+		return ImageDescription{}, err
+	}
+	var out chatResponse
+	if err := postJSON(ctx, c.client, c.URL, c.APIKey, "application/json", "", body, &out); err != nil {
+		return ImageDescription{}, err
+	}
+	if len(out.Choices) == 0 {
+		return ImageDescription{}, errors.New("caption response has no choices")
+	}
+	return ImageDescription{Caption: out.Choices[0].Message.Content, Usage: out.usage(c.URL, c.Model)}, nil
+}
+
+// HTTPImageEmbedder posts the image to a multi-modal embedding service (e.g.
+// a CLIP server), which must answer with JSON {"embedding": [0.1, ...]}.
+type HTTPImageEmbedder struct {
+	URL    string
+	APIKey string
+	client *http.Client
+}
+
+func (e *HTTPImageEmbedder) Describe(ctx context.Context, filename string, content []byte) (ImageDescription, error) {
+	var out struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := postJSON(ctx, e.client, e.URL, e.APIKey, imageContentType(filename), path.Base(filename), content, &out); err != nil {
+		return ImageDescription{}, err
+	}
+	if len(out.Embedding) == 0 {
+		return ImageDescription{}, errors.New("embedding response is empty")
+	}
+	return ImageDescription{Embedding: out.Embedding}, nil
+}
+
+func imageContentType(filename string) string {
+	if ct := mime.TypeByExtension(path.Ext(filename)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// postJSON posts body and decodes the JSON response into dst.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey, contentType, filename string, body []byte, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if filename != "" {
+		req.Header.Set("X-Filename", filename)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("decode %s response: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeImages struct {
+	desc ImageDescription
+}
+
+func (f fakeImages) Describe(ctx context.Context, filename string, content []byte) (ImageDescription, error) {
+	return f.desc, nil
+}
+
+func TestDescribeImage(t *testing.T) {
+	ctx := context.Background()
+
+	s := NewIngestService(nil).WithOCR(&fakeOCR{}).WithImages(fakeImages{ImageDescription{Caption: "A deployment diagram."}})
+	sections, err := s.extract(ctx, "docs", "arch.png", []byte("img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].metadata[imageCaptionKey] != true || sections[1].metadata[ocrKey] != true {
+		t.Fatalf("expected caption then OCR sections, got %+v", sections)
+	}
+
+	s = NewIngestService(nil).WithImages(fakeImages{ImageDescription{Embedding: []float32{0.1, 0.2}}})
+	sections, err = s.extract(ctx, "docs", "shots/login.png", []byte("img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 1 || sections[0].text != "login.png" || len(sections[0].embedding) != 2 || sections[0].metadata[imageEmbeddingKey] != true {
+		t.Errorf("expected one embedded section, got %+v", sections)
+	}
+
+	// Documents are unaffected by the image backend
+	sections, err = s.extract(ctx, "docs", "notes.txt", []byte("text"))
+	if err != nil || len(sections) != 1 || sections[0].embedding != nil {
+		t.Errorf("unexpected text sections %+v (%v)", sections, err)
+	}
+}
+
+func TestVisionCaptioner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content []struct {
+					Type     string            `json:"type"`
+					ImageURL map[string]string `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "llava" || len(req.Messages) != 1 ||
+			len(req.Messages[0].Content) != 2 || !strings.HasPrefix(req.Messages[0].Content[1].ImageURL["url"], "data:image/png;base64,") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"A login form."}}]}`))
+	}))
+	defer srv.Close()
+
+	d, err := NewImageDescriber(ImageConfig{Backend: ImageCaption, URL: srv.URL, Model: "llava"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.Describe(context.Background(), "login.png", []byte("img"))
+	if err != nil || got.Caption != "A login form." {
+		t.Errorf("unexpected description %+v (%v)", got, err)
+	}
+
+	if _, err := NewImageDescriber(ImageConfig{Backend: ImageEmbed}); err == nil {
+		t.Error("expected embed backend without a URL to be rejected")
+	}
+}
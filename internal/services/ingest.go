@@ -4,103 +4,234 @@ import (
 	"context"
 	"crypto/md5"
 	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
 	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
 	"github.com/typicalfo/forge/backend/internal/logging"
 )
 
 type IngestResult struct {
-	Status string `json:"status"` // "ingested" or "skipped"
+	Status string `json:"status"` // "ingested", "skipped" or "unsupported_type"
 	File   string `json:"file"`
 	Chunks int    `json:"chunks,omitempty"`
+	// DuplicateChunks counts chunks left out because the collection already
+	// held their text (see WithChunkDedupe).
+	DuplicateChunks int `json:"duplicate_chunks,omitempty"`
+	// Replaced counts chunks of earlier versions deleted by a replacing
+	// ingest (see IngestOptions.Replace).
+	Replaced int    `json:"replaced,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Timings breaks down where an ingested file's time went.
+	Timings *IngestTimings `json:"timings,omitempty"`
 }
 
 type IngestService struct {
 	chromaDB chroma.Client
+	settings SettingsStore
+	sources  SourceStore
+	events   *EventBus
+
+	keys         SystemKeys
+	queryStats   QueryStatsStore
+	stats        CollectionStatsStore
+	batcher      *adaptiveBatcher
+	timeouts     Timeouts
+	degradeAfter time.Duration
+	cache        *searchCache
+	naming       NamePolicy
+	searchLimits SearchLimits
+	ocr          OCR
+	images       ImageDescriber
+	expand       ExpandLimits
+	sessions     *searchSessions
+	generator    Generator
+	templates    TemplateStore
+	pathRoots    []string
+	fileIndex    FileIndexStore
+	answerPrompt string
+	stageTotals  stageTotals
+	answers      *answerCache
+	costs        *CostTracker
+	intents      IntentStore
+	intentsSince time.Time
+	blobs        BlobStore
+	pdftoppm     string
+	versions     VersionStore
+	versionsKeep int
+	replica      *ReplicaService
+
+	defaultTokenizer string
+	defaultChunking  Chunking
+	chunkDedupe      bool
+	embedder         embeddings.EmbeddingFunction
+	embedderModel    string
+	trashGrace       time.Duration
+	shortQueryTerms  int
+
+	transcriber      Transcriber
+	transcriptWindow time.Duration
 }
 
 func NewIngestService(chromaDB chroma.Client) *IngestService {
-	return &IngestService{chromaDB: chromaDB}
+	return &IngestService{chromaDB: chromaDB, keys: DefaultSystemKeys, batcher: newAdaptiveBatcher(DefaultBatchTuning), events: NewEventBus(), naming: DefaultNamePolicy, searchLimits: DefaultSearchLimits, expand: DefaultExpandLimits, sessions: newSearchSessions()}
+}
+
+// SettingsStore persists per-collection settings as JSON values.
+type SettingsStore interface {
+	GetCollectionSetting(collection, key string, dst any) (bool, error)
+	SetCollectionSetting(collection, key string, value any) error
+}
+
+var errNoSettingsStore = errors.New("collection settings store is not configured")
+
+// WithSettings attaches a per-collection settings store.
+func (s *IngestService) WithSettings(store SettingsStore) *IngestService {
+	s.settings = store
+	return s
+}
+
+// IngestOptions carries optional per-file ingest settings.
+type IngestOptions struct {
+	// Metadata is user metadata merged into every chunk with a "user_" prefix.
+	Metadata map[string]interface{}
+	// ACL lists the principals allowed to see the file's chunks in search.
+	// An empty ACL leaves the chunks visible to everyone.
+	ACL []string
+	// MaxTokens caps chunk size; zero uses the collection's chunking size.
+	MaxTokens int
+	// Chunker, if set, names the chunking strategy to use instead of the
+	// collection's (see RegisterChunker).
+	Chunker string
+	// Source tags every chunk with source_id and registers the source.
+	Source IngestSource
+	// Transform, if set, rewrites extracted text before chunking.
+	Transform func(string) string
+	// XML, if set, maps XML files (.xml, .dita, .ditamap, .dbk) to text and
+	// metadata instead of ingesting their markup.
+	XML *XMLMapping
+	// Replace makes a file whose content changed replace the chunks of its
+	// earlier versions, matched by file name, instead of adding to them.
+	Replace bool
+	// Title, if set, is stored as the document's title instead of the one
+	// extracted from it.
+	Title string
+	// Format, if set, is the extension of the file type to read the file
+	// as (e.g. ".md"), instead of the type detected from its name and
+	// content.
+	Format string
+}
+
+// defaultChunkTokens is the approximate chunk size used when none is given.
+const defaultChunkTokens = 512
+
+// OnCollectionChanged registers a callback invoked after a collection's
+// contents change through this service.
+func (s *IngestService) OnCollectionChanged(fn func(collection string)) {
+	s.events.Subscribe(func(e Event) { fn(e.Collection) }, EventCollectionChanged)
 }
 
 func (s *IngestService) IngestFile(ctx context.Context, collectionName string, filePath string, content []byte, userMetadata map[string]interface{}) (*IngestResult, error) {
+	return s.IngestFileWithOptions(ctx, collectionName, filePath, content, IngestOptions{Metadata: userMetadata})
+}
+
+// IngestFileWithOptions chunks and stores a file using the given options.
+func (s *IngestService) IngestFileWithOptions(ctx context.Context, collectionName string, filePath string, content []byte, opts IngestOptions) (_ *IngestResult, err error) {
+	ctx = logging.WithFields(ctx, logrus.Fields{"collection": collectionName})
+	defer func() {
+		if err != nil {
+			s.recordCounters(ctx, collectionName, config.CollectionCounters{IngestFailures: 1})
+		}
+	}()
+	ctx, timer := withStageTimer(ctx)
 	// Get or create collection
-	collection, err := s.chromaDB.GetOrCreateCollection(ctx, collectionName)
+	lookupCtx, cancelLookup := withTimeout(ctx, s.timeouts.Query)
+	defer cancelLookup()
+	collection, err := s.getOrCreateCollection(lookupCtx, collectionName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get/create collection: %w", err)
 	}
+	collectionName = collection.Name()
 	// Compute MD5 of file content for dedupe
 	md5Hash := fmt.Sprintf("%x", md5.Sum(content))
 
-	// Check if file already ingested by querying for existing MD5
-	results, err := collection.Get(ctx, chroma.WithWhereGet(chroma.EqString("file_md5", md5Hash)))
+	// Check if file already ingested by querying for existing MD5; a
+	// replacing ingest only compares with the file's own chunks
+	var replaced []string
+	if opts.Replace {
+		var current bool
+		if replaced, current, err = s.fileVersions(lookupCtx, collection, filePath, md5Hash); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("file", filePath).Error("Error querying for earlier versions")
+			return nil, err
+		}
+		if current {
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"file": filePath,
+				"md5":  md5Hash,
+			}).Info("File already ingested, skipping")
+			return &IngestResult{Status: "skipped", File: filePath}, nil
+		}
+	} else {
+		results, err := collection.Get(lookupCtx, chroma.WithWhereGet(chroma.EqString(s.keys.FileMD5, md5Hash)))
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("file", filePath).Error("Error querying for dedupe")
+			return nil, err
+		}
+
+		// Check if we got any results
+		docs := results.GetDocuments()
+		if len(docs) > 0 {
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"file": filePath,
+				"md5":  md5Hash,
+			}).Info("File already ingested, skipping")
+			return &IngestResult{Status: "skipped", File: filePath}, nil
+		}
+	}
+
+	prepared, err := s.prepareChunks(ctx, collectionName, filePath, content, md5Hash, opts)
+	if errors.Is(err, ErrUnsupportedType) {
+		return &IngestResult{Status: statusUnsupportedType, File: filePath, Error: err.Error()}, nil
+	}
 	if err != nil {
-		logging.GetLogger().WithError(err).WithField("file", filePath).Error("Error querying for dedupe")
 		return nil, err
 	}
-
-	// Check if we got any results
-	docs := results.GetDocuments()
-	if len(docs) > 0 {
-		logging.GetLogger().WithFields(logrus.Fields{
-			"file": filePath,
-			"md5":  md5Hash,
-		}).Info("File already ingested, skipping")
-		return &IngestResult{Status: "skipped", File: filePath}, nil
+	// Read the version being replaced before the upsert overwrites chunks
+	// the new one shares with it
+	previous, err := s.replacedRecords(ctx, collection, filePath, replaced)
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract text (assume text-based files)
-	text := string(content)
-
-	// Chunk into segments (simple: split by lines, limit to 512 tokens approx)
-	chunks := chunkText(text, 512)
-
-	// Generate IDs and metadata
-	ids := make([]string, len(chunks))
-	metadatas := make([]map[string]interface{}, len(chunks))
-	for i, chunk := range chunks {
-		hash := sha256.Sum256([]byte(filePath + chunk + fmt.Sprintf("%d", i)))
-		ids[i] = fmt.Sprintf("%x", hash[:8])
-
-		// Start with system metadata
-		metadata := map[string]interface{}{
-			"file_md5":    md5Hash,
-			"file_name":   filePath,
-			"timestamp":   time.Now().Unix(),
-			"chunk_index": i,
+	duplicates := 0
+	if s.chunkDedupe {
+		if duplicates, err = s.dropDuplicateChunks(ctx, collection, prepared, replaced); err != nil {
+			return nil, err
 		}
-
-		// Merge user metadata if provided
-		if userMetadata != nil {
-			for key, value := range userMetadata {
-				// Prefix user metadata keys to avoid conflicts with system metadata
-				metadata["user_"+key] = value
+		if len(prepared.ids) == 0 {
+			deleted, err := s.deleteReplaced(ctx, collection, filePath, replaced, nil)
+			if err != nil {
+				return nil, err
 			}
+			s.saveVersions(ctx, collectionName, filePath, previous, md5Hash)
+			logging.FromContext(ctx).WithField("file", filePath).Info("Every chunk already ingested, skipping")
+			return &IngestResult{Status: "skipped", File: filePath, DuplicateChunks: duplicates, Replaced: deleted}, nil
 		}
-
-		metadatas[i] = metadata
 	}
+	ids, chunks, metadatas, chunkEmbeddings := prepared.ids, prepared.chunks, prepared.metadatas, prepared.embeddings
 
 	// Convert metadatas to chroma format
 	var chromaMetadatas []chroma.DocumentMetadata
 	for _, m := range metadatas {
-		var attributes []*chroma.MetaAttribute
-		for k, v := range m {
-			switch val := v.(type) {
-			case string:
-				attributes = append(attributes, chroma.NewStringAttribute(k, val))
-			case int64:
-				attributes = append(attributes, chroma.NewIntAttribute(k, val))
-			case float64:
-				attributes = append(attributes, chroma.NewFloatAttribute(k, val))
-			}
-		}
-		metadata := chroma.NewDocumentMetadata(attributes...)
-		chromaMetadatas = append(chromaMetadatas, metadata)
+		chromaMetadatas = append(chromaMetadatas, toDocumentMetadata(m))
 	}
 
 	// Convert IDs to DocumentIDs
@@ -109,21 +240,240 @@ func (s *IngestService) IngestFile(ctx context.Context, collectionName string, f
 		docIDs = append(docIDs, chroma.DocumentID(id))
 	}
 
-	// Add to collection
-	err = collection.Add(ctx,
-		chroma.WithIDs(docIDs...),
-		chroma.WithTexts(chunks...),
-		chroma.WithMetadatas(chromaMetadatas...))
+	// Upsert so re-ingesting identical chunks is idempotent (IDs are stable)
+	finish, err := s.beginIntent(ctx, config.Intent{
+		Collection: collectionName,
+		Op:         IntentUpsert,
+		IDs:        ids,
+		// Chunks of an earlier version may share IDs; only this version's count
+		Filter: map[string]string{s.keys.FileMD5: md5Hash},
+		Ref:    filePath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.upsertChunks(ctx, collection, docIDs, chunks, chromaMetadatas, chunkEmbeddings)
+	finish(err)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("file", filePath).Error("Error adding to collection")
+		s.rollbackChunks(ctx, collection, docIDs, md5Hash)
+		return nil, dimensionError(collectionName, err)
+	}
+	deleted, err := s.deleteReplaced(ctx, collection, filePath, replaced, ids)
 	if err != nil {
-		logging.GetLogger().WithError(err).WithField("file", filePath).Error("Error adding to collection")
 		return nil, err
 	}
+	s.saveVersions(ctx, collectionName, filePath, previous, md5Hash)
+
+	s.storeTitle(ctx, collectionName, filePath, md5Hash, prepared.title)
+	s.storeBlob(ctx, md5Hash, filePath, content)
+	s.recordSource(collectionName, opts.Source)
+	s.publishChange(EventIngested, collectionName, map[string]interface{}{"file": filePath, "chunks": len(chunks), "source_id": opts.Source.ID, "replaced": deleted})
 
-	logging.GetLogger().WithFields(logrus.Fields{
+	timings := timer.timings()
+	s.stageTotals.add(timings)
+	logging.FromContext(ctx).WithFields(logrus.Fields{
 		"file":   filePath,
 		"chunks": len(chunks),
 	}).Info("Successfully ingested file")
-	return &IngestResult{Status: "ingested", File: filePath, Chunks: len(chunks)}, nil
+	return &IngestResult{Status: "ingested", File: filePath, Chunks: len(chunks), DuplicateChunks: duplicates, Replaced: deleted, Timings: &timings}, nil
+}
+
+// preparedChunks is a file's chunks, ready to write.
+type preparedChunks struct {
+	ids        []string
+	chunks     []string
+	metadatas  []map[string]interface{}
+	embeddings []embeddings.Embedding // nil unless every chunk has one
+	title      string
+}
+
+// prepareChunks extracts and chunks a file and builds each chunk's ID and
+// metadata.
+func (s *IngestService) prepareChunks(ctx context.Context, collectionName, filePath string, content []byte, md5Hash string, opts IngestOptions) (*preparedChunks, error) {
+	began := time.Now()
+	sections, charset, err := s.extractFile(ctx, collectionName, filePath, content, opts)
+	if err != nil {
+		return nil, err
+	}
+	recordStage(ctx, stageExtract, began)
+	began = time.Now()
+	title := clipTitle(opts.Title)
+	if title == "" {
+		title = extractTitle(filePath, content, sections)
+	}
+
+	// Chunk each section (limit to ~512 tokens by default)
+	tokenizer, err := s.CollectionTokenizer(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	chunking, err := s.CollectionChunking(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Chunker != "" {
+		chunking.Strategy = opts.Chunker
+	}
+	maxTokens := chunking.chunkTokens(opts.MaxTokens)
+	// Overlap counts toward the chunk size
+	overlap := chunking.overlapTokens(maxTokens)
+	withParents := chunking.ParentSize > maxTokens
+	var chunks []string
+	var chunkOverlaps []int
+	var chunkSections []map[string]interface{}
+	var chunkEmbeddings []embeddings.Embedding
+	var parents []string
+	var chunkParents []int // index into parents, -1 for none
+	for _, sec := range sections {
+		text := sec.text
+		if opts.Transform != nil {
+			text = opts.Transform(text)
+		}
+		if sec.embedding != nil {
+			chunks = append(chunks, text)
+			chunkOverlaps = append(chunkOverlaps, 0)
+			chunkSections = append(chunkSections, sec.metadata)
+			chunkEmbeddings = append(chunkEmbeddings, embeddings.NewEmbeddingFromFloat32(sec.embedding))
+			chunkParents = append(chunkParents, -1)
+			continue
+		}
+		split := func(text string, maxTokens int) []string {
+			if sec.split != nil {
+				return chunkUnits(sec.units(text), maxTokens, tokenizer)
+			}
+			return chunking.split(text, maxTokens, tokenizer)
+		}
+		// Each parent is chunked on its own, so no chunk spans two parents
+		secParents := []string{text}
+		if withParents {
+			secParents = split(text, chunking.ParentSize)
+		}
+		for _, parent := range secParents {
+			parentIndex := -1
+			if withParents {
+				parentIndex = len(parents)
+				parents = append(parents, parent)
+			}
+			secChunks, prefixes := withOverlap(split(parent, maxTokens-overlap), overlap, tokenizer)
+			for i, chunk := range secChunks {
+				chunks = append(chunks, chunk)
+				chunkOverlaps = append(chunkOverlaps, prefixes[i])
+				chunkSections = append(chunkSections, sec.metadata)
+				chunkParents = append(chunkParents, parentIndex)
+			}
+		}
+	}
+	ids := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		ids[i] = ChunkID(filePath, i, chunk)
+	}
+	parentIDs := make([]string, len(parents))
+	for i, parent := range parents {
+		parentIDs[i] = ParentID(filePath, i, parent)
+	}
+	// Only decoded text files can contain their chunks verbatim
+	var spans []*chunkSpan
+	if charset != "" && opts.Transform == nil {
+		spans = chunkSpans(content, chunks, chunkOverlaps)
+	}
+
+	// Generate metadata
+	metadatas := make([]map[string]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		// Start with system metadata
+		metadata := map[string]interface{}{
+			s.keys.FileMD5:     md5Hash,
+			s.keys.FileName:    filePath,
+			s.keys.Timestamp:   time.Now().Unix(),
+			s.keys.ChunkIndex:  i,
+			s.keys.TokenCount:  tokenizer.Count(chunk),
+			s.keys.ContentHash: ContentHash(chunk),
+		}
+		if charset != "" {
+			metadata[charsetKey] = charset
+		}
+		if title != "" {
+			metadata[titleKey] = title
+		}
+		if i > 0 {
+			metadata[prevChunkKey] = ids[i-1]
+		}
+		if i < len(chunks)-1 {
+			metadata[nextChunkKey] = ids[i+1]
+		}
+		if chunkOverlaps[i] > 0 {
+			metadata[overlapKey] = chunkOverlaps[i]
+		}
+		if chunkParents[i] >= 0 {
+			metadata[parentChunkKey] = parentIDs[chunkParents[i]]
+		}
+		if spans != nil && spans[i] != nil {
+			metadata[startByteKey] = spans[i].start
+			metadata[endByteKey] = spans[i].end
+			metadata[chunkStartLineKey] = spans[i].startLine
+			metadata[chunkEndLineKey] = spans[i].endLine
+		}
+		for key, value := range chunkSections[i] {
+			metadata[key] = value
+		}
+
+		// Merge user metadata if provided
+		if opts.Metadata != nil {
+			for key, value := range opts.Metadata {
+				// Prefix user metadata keys to avoid conflicts with system metadata
+				metadata[userMetadataPrefix+key] = value
+			}
+		}
+		applyACL(metadata, opts.ACL)
+		if opts.Source.ID != "" {
+			metadata[s.keys.SourceID] = opts.Source.ID
+		}
+
+		metadatas[i] = metadata
+	}
+
+	// Precomputed vectors are only sent when every chunk has one; Chroma
+	// embeds all of a write's texts or none
+	if len(chunkEmbeddings) != len(chunks) {
+		chunkEmbeddings = nil
+	}
+	recordStage(ctx, stageChunk, began)
+	return &preparedChunks{ids: ids, chunks: chunks, metadatas: metadatas, embeddings: chunkEmbeddings, title: title}, nil
+}
+
+// chunkIDScheme versions the chunk ID derivation; bump it if ChunkID changes.
+// Parent IDs use their own scheme, so they never collide with chunk IDs.
+const (
+	chunkIDScheme  = "forge-chunk-v1"
+	parentIDScheme = "forge-parent-v1"
+)
+
+// ChunkID derives a stable chunk ID from the file identity, the chunk's
+// position and its text:
+//
+//	hex(sha256("forge-chunk-v1" NUL path NUL index NUL text))[:16]
+//
+// path is the slash-separated, cleaned file name as ingested. Re-ingesting an
+// unchanged file therefore yields identical IDs, so writes are idempotent
+// upserts and external references to chunks survive re-ingestion.
+func ChunkID(filePath string, index int, chunk string) string {
+	return stableID(chunkIDScheme, filePath, index, chunk)
+}
+
+// ParentID derives a parent section's ID like ChunkID, from its position
+// among the file's parents and its text, under the "forge-parent-v1" scheme.
+func ParentID(filePath string, index int, parent string) string {
+	return stableID(parentIDScheme, filePath, index, parent)
+}
+
+func stableID(scheme, filePath string, index int, text string) string {
+	h := sha256.New()
+	for _, part := range []string{scheme, path.Clean(filepath.ToSlash(filePath)), strconv.Itoa(index), text} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:8])
 }
 
 type SearchResult struct {
@@ -131,42 +481,125 @@ type SearchResult struct {
 	Document string                 `json:"document"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Distance float32                `json:"distance"`
+	// Relevance is the distance mapped onto [0, 1] for the collection's
+	// distance function, higher for closer matches.
+	Relevance float32 `json:"relevance"`
+	// Title is the title of the result's document, when it has one.
+	Title string `json:"title,omitempty"`
+	// Collection is set by multi-collection and hybrid searches.
+	Collection string `json:"collection,omitempty"`
+	// Score is the fused rank score of hybrid searches (higher is better).
+	Score float32 `json:"score,omitempty"`
+	// ParentID is set when Document is the parent section of the matched
+	// chunk (see SearchOptions.Parents).
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// SearchOptions carries optional search behavior.
+type SearchOptions struct {
+	// Dedupe collapses results with identical or near-identical text,
+	// keeping the best-scoring instance.
+	Dedupe bool
+	// Timeout overrides the default query timeout for this search.
+	Timeout time.Duration
+	// Hybrid adds a lexical leg per collection, fused with the vector leg.
+	Hybrid bool
+	// Exclude removes matching chunks from the results.
+	Exclude Exclusion
+	// SessionID, if set, also excludes chunks returned by earlier searches
+	// with the same session (see MultiSearch).
+	SessionID string
+	// Parents returns the parent section a matched chunk was split from in
+	// place of the chunk, once per parent, in collections chunked with a
+	// parent size (see MultiSearch).
+	Parents bool
 }
 
+// dedupeOverfetch is how many candidates per requested result are fetched
+// when deduplicating, so collapsing still yields k results.
+const dedupeOverfetch = 3
+
 func (s *IngestService) Search(ctx context.Context, collectionName string, query string, k int, filter map[string]interface{}) ([]SearchResult, error) {
+	return s.SearchWithOptions(ctx, collectionName, query, k, filter, SearchOptions{})
+}
+
+// SearchWithOptions runs a similarity search using the given options.
+func (s *IngestService) SearchWithOptions(ctx context.Context, collectionName string, query string, k int, filter map[string]interface{}, opts SearchOptions) ([]SearchResult, error) {
+	n := k
+	if opts.Dedupe {
+		n = k * dedupeOverfetch
+	}
+	boost, err := s.CollectionTitleBoost(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if boost.Weight > 0 {
+		n *= titleOverfetch
+	}
+	// Excluded IDs are dropped after the query and must not shrink the results
+	n += len(opts.Exclude.IDs)
+	excluded, err := opts.Exclude.clauses(s.keys)
+	if err != nil {
+		return nil, err
+	}
+	timeout := s.timeouts.Query
+	if opts.Timeout > 0 {
+		timeout = min(opts.Timeout, MaxSearchTimeout)
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx = logging.WithFields(ctx, logrus.Fields{"collection": collectionName})
+
 	// Try to get collection first
-	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	collection, err := s.searchCollection(ctx, collectionName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get collection '%s': %w", collectionName, err)
+		return nil, err
 	}
 
 	var queryOptions []chroma.CollectionQueryOption
 	queryOptions = append(queryOptions, chroma.WithQueryTexts(query))
-	queryOptions = append(queryOptions, chroma.WithNResults(k))
-
-	// Add filter if provided
-	if len(filter) > 0 {
-		var whereClause chroma.WhereFilter
-		for k, v := range filter {
-			switch val := v.(type) {
-			case string:
-				whereClause = chroma.EqString(k, val)
-			case int:
-				whereClause = chroma.EqInt(k, val)
-			case float64:
-				whereClause = chroma.EqFloat(k, float32(val))
-			}
-		}
-		queryOptions = append(queryOptions, chroma.WithWhereQuery(whereClause))
-	}
+	queryOptions = append(queryOptions, chroma.WithNResults(n))
+
+	// Add filter if provided, always restricted to chunks the caller may see
+	clauses := filterClauses(filter)
+	clauses = append(clauses, aclWhere(PrincipalsFromContext(ctx)))
+	clauses = append(clauses, excluded...)
+	queryOptions = append(queryOptions, chroma.WithWhereQuery(andWhere(clauses)))
 
 	results, err := collection.Query(ctx, queryOptions...)
 	if err != nil {
-		logging.GetLogger().WithError(err).WithField("queryOptions", queryOptions).Error("Error querying collection")
-		return nil, err
+		if replica := s.replica.failover(ctx, collectionName, err); replica != nil {
+			collection = replica
+			results, err = collection.Query(ctx, queryOptions...)
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("queryOptions", queryOptions).Error("Error querying collection")
+		return nil, dimensionError(collectionName, err)
+	}
+	s.costs.embedded(ctx, collectionName, query)
+
+	searchResults, files := queryResults(results, s.keys.FileName)
+	if boost.Weight > 0 {
+		searchResults = s.boostTitles(ctx, collection, query, clauses, searchResults, files, boost.Weight)
 	}
+	searchResults = opts.Exclude.dropIDs(searchResults)
+	setRelevance(searchResults, distanceSpace(collection))
 
+	if opts.Dedupe {
+		searchResults = dedupeResults(searchResults)
+	}
+	if len(searchResults) > k {
+		searchResults = searchResults[:k]
+	}
+	return searchResults, nil
+}
+
+// queryResults converts the first group of a query response into search
+// results, also returning each result's file name.
+func queryResults(results chroma.QueryResult, fileKey string) ([]SearchResult, []string) {
 	var searchResults []SearchResult
+	var files []string
 
 	// QueryResult returns groups - we want the first group
 	docsGroups := results.GetDocumentsGroups()
@@ -183,7 +616,10 @@ func (s *IngestService) Search(ctx context.Context, collectionName string, query
 		for i, doc := range docs {
 			// Convert metadata back to map
 			metadataMap := make(map[string]interface{})
+			var file, title string
 			if i < len(metadatas) && metadatas[i] != nil {
+				file, _ = metadatas[i].GetString(fileKey)
+				title, _ = metadatas[i].GetString(titleKey)
 				// This is a simplified conversion - you might need to handle different attribute types
 				metadataMap = map[string]interface{}{
 					"id":       string(ids[i]),
@@ -194,26 +630,104 @@ func (s *IngestService) Search(ctx context.Context, collectionName string, query
 				}
 			}
 
+			files = append(files, file)
 			searchResults = append(searchResults, SearchResult{
 				ID:       string(ids[i]),
 				Document: doc.ContentString(),
 				Metadata: metadataMap,
 				Distance: float32(distances[i]),
+				Title:    title,
 			})
 		}
 	}
 
-	return searchResults, nil
+	return searchResults, files
 }
 
-func chunkText(text string, maxTokens int) []string {
-	lines := strings.Split(text, "\n")
+// filterClauses converts a simple equality filter into where clauses.
+func filterClauses(filter map[string]interface{}) []chroma.WhereClause {
+	var clauses []chroma.WhereClause
+	for k, v := range filter {
+		switch val := v.(type) {
+		case string:
+			clauses = append(clauses, chroma.EqString(k, val))
+		case int:
+			clauses = append(clauses, chroma.EqInt(k, val))
+		case float64:
+			clauses = append(clauses, chroma.EqFloat(k, float32(val)))
+		case bool:
+			clauses = append(clauses, chroma.EqBool(k, val))
+		}
+	}
+	return clauses
+}
+
+// andWhere joins clauses with $and; Chroma rejects $and with a single operand.
+func andWhere(clauses []chroma.WhereClause) chroma.WhereClause {
+	switch len(clauses) {
+	case 0:
+		return nil
+	case 1:
+		return clauses[0]
+	}
+	return chroma.And(clauses...)
+}
+
+// toDocumentMetadata converts a plain map into Chroma document metadata,
+// dropping values of unsupported types.
+func toDocumentMetadata(m map[string]interface{}) chroma.DocumentMetadata {
+	var attrs []*chroma.MetaAttribute
+	for k, v := range m {
+		switch val := v.(type) {
+		case string:
+			attrs = append(attrs, chroma.NewStringAttribute(k, val))
+		case int:
+			attrs = append(attrs, chroma.NewIntAttribute(k, int64(val)))
+		case int64:
+			attrs = append(attrs, chroma.NewIntAttribute(k, val))
+		case float64:
+			attrs = append(attrs, chroma.NewFloatAttribute(k, val))
+		case bool:
+			attrs = append(attrs, chroma.NewBoolAttribute(k, val))
+		case json.Number:
+			if n, err := val.Int64(); err == nil {
+				attrs = append(attrs, chroma.NewIntAttribute(k, n))
+			} else if f, err := val.Float64(); err == nil {
+				attrs = append(attrs, chroma.NewFloatAttribute(k, f))
+			}
+		}
+	}
+	return chroma.NewDocumentMetadata(attrs...)
+}
+
+func chunkText(text string, maxTokens int, tokenizer Tokenizer) []string {
+	return chunkUnits(strings.Split(text, "\n"), maxTokens, tokenizer)
+}
+
+// chunkUnits packs units (lines, or multi-line blocks that must stay whole)
+// into chunks of about maxTokens. A line longer than maxTokens is cut at
+// token boundaries when the tokenizer can split; a larger block becomes a
+// chunk of its own.
+func chunkUnits(units []string, maxTokens int, tokenizer Tokenizer) []string {
 	var chunks []string
 	var currentChunk strings.Builder
 	tokenCount := 0
+	splitter, canSplit := tokenizer.(tokenSplitter)
 
-	for _, line := range lines {
-		lineTokens := len(strings.Fields(line))
+	for _, line := range units {
+		lineTokens := tokenizer.Count(line)
+		if canSplit && lineTokens > maxTokens && !strings.Contains(line, "\n") {
+			if currentChunk.Len() > 0 {
+				chunks = append(chunks, currentChunk.String())
+				currentChunk.Reset()
+			}
+			// Pieces concatenate back to the line; the last one packs on
+			pieces := splitter.Split(line, maxTokens)
+			chunks = append(chunks, pieces[:len(pieces)-1]...)
+			line = pieces[len(pieces)-1]
+			lineTokens = tokenizer.Count(line)
+			tokenCount = 0
+		}
 		if tokenCount+lineTokens > maxTokens {
 			if currentChunk.Len() > 0 {
 				chunks = append(chunks, currentChunk.String())
@@ -240,14 +754,54 @@ func (s *IngestService) ListCollections(ctx context.Context) ([]string, error) {
 
 	var names []string
 	for _, collection := range collections {
+		if strings.HasSuffix(collection.Name(), titleCollectionSuffix) || IsSnapshotCollection(collection.Name()) {
+			continue
+		}
+		if entry, err := s.trashEntry(collection.Name()); err != nil {
+			return nil, err
+		} else if entry != nil {
+			continue
+		}
 		names = append(names, collection.Name())
 	}
 
 	return names, nil
 }
 
-func (s *IngestService) CreateCollection(ctx context.Context, name string, description string) (map[string]interface{}, error) {
-	collection, err := s.chromaDB.GetOrCreateCollection(ctx, name)
+// CreateCollection creates a collection, or returns it if it already exists,
+// enforcing the naming policy. Under a case-insensitive policy a name that
+// differs only in case from an existing collection is rejected. Metadata,
+// such as HNSW parameters, is passed to Chroma and stored so the collection
+// is recreated with it; it is rejected for an existing collection.
+func (s *IngestService) CreateCollection(ctx context.Context, name string, description string, metadata map[string]interface{}) (map[string]interface{}, error) {
+	applied, err := s.naming.Apply(name)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := s.resolveCollectionName(ctx, applied)
+	if err != nil {
+		return nil, err
+	}
+	if resolved != applied {
+		return nil, fmt.Errorf("%w: %q already exists", ErrCollectionNameConflict, resolved)
+	}
+	if err := s.checkNotTrashed(applied); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if metadata, err = NormalizeCollectionMetadata(metadata); err != nil {
+			return nil, err
+		}
+		if _, err := s.chromaDB.GetCollection(ctx, applied); err == nil {
+			return nil, fmt.Errorf("%w: %q", ErrCollectionMetadataLocked, applied)
+		}
+		if s.settings != nil {
+			if err := s.settings.SetCollectionSetting(applied, collectionMetadataSettingKey, metadata); err != nil {
+				return nil, err
+			}
+		}
+	}
+	collection, err := s.createCollection(ctx, applied, metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create collection: %w", err)
 	}
@@ -268,14 +822,15 @@ type Document struct {
 }
 
 // CreateDocDirect creates a single document directly without chunking or deduplication
-func (s *IngestService) CreateDocDirect(ctx context.Context, collectionName, id, text string, metadata map[string]interface{}) (string, error) {
+func (s *IngestService) CreateDocDirect(ctx context.Context, collectionName, id, text string, metadata map[string]interface{}, acl []string, source IngestSource) (string, error) {
 	if text == "" {
 		return "", fmt.Errorf("text is required")
 	}
-	collection, err := s.chromaDB.GetOrCreateCollection(ctx, collectionName)
+	collection, err := s.getOrCreateCollection(ctx, collectionName)
 	if err != nil {
 		return "", fmt.Errorf("get/create collection: %w", err)
 	}
+	collectionName = collection.Name()
 	// Determine ID
 	docID := id
 	if docID == "" {
@@ -290,49 +845,134 @@ func (s *IngestService) CreateDocDirect(ctx context.Context, collectionName, id,
 		}
 	}
 	// Build metadata
-	var attrs []*chroma.MetaAttribute
+	m := make(map[string]interface{}, len(metadata)+1)
 	for k, v := range metadata {
-		switch val := v.(type) {
-		case string:
-			attrs = append(attrs, chroma.NewStringAttribute(k, val))
-		case int:
-			attrs = append(attrs, chroma.NewIntAttribute(k, int64(val)))
-		case int64:
-			attrs = append(attrs, chroma.NewIntAttribute(k, val))
-		case float64:
-			attrs = append(attrs, chroma.NewFloatAttribute(k, val))
-		}
+		m[k] = v
+	}
+	applyACL(m, acl)
+	if source.ID != "" {
+		m[s.keys.SourceID] = source.ID
 	}
-	md := chroma.NewDocumentMetadata(attrs...)
+	md := toDocumentMetadata(m)
 	// Add
-	err = collection.Add(ctx,
+	writeCtx, cancel := withTimeout(ctx, s.timeouts.Embed)
+	defer cancel()
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: collectionName, Op: IntentAdd, IDs: []string{docID}})
+	if err != nil {
+		return "", err
+	}
+	err = collection.Add(writeCtx,
 		chroma.WithIDs(chroma.DocumentID(docID)),
 		chroma.WithTexts(text),
 		chroma.WithMetadatas(md),
 	)
+	finish(err)
 	if err != nil {
-		return "", fmt.Errorf("add document: %w", err)
+		return "", fmt.Errorf("add document: %w", dimensionError(collectionName, err))
 	}
+	s.costs.embedded(ctx, collectionName, text)
+	s.recordSource(collectionName, source)
+	s.publishChange(EventIngested, collectionName, map[string]interface{}{"id": docID, "source_id": source.ID})
 	return docID, nil
 }
 
+// ErrDocNotFound is returned for a document ID the collection doesn't hold
+// or the caller may not see.
+var ErrDocNotFound = errors.New("document not found")
+
+// GetDoc returns one stored document with its full text and metadata, and
+// its embedding when withEmbedding is set. Documents hidden from the
+// caller's principals are not found.
+func (s *IngestService) GetDoc(ctx context.Context, collectionName, id string, withEmbedding bool) (*Record, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	include := []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas}
+	if withEmbedding {
+		include = append(include, chroma.IncludeEmbeddings)
+	}
+	res, err := collection.Get(ctx,
+		chroma.WithIDsGet(chroma.DocumentID(id)),
+		chroma.WithWhereGet(aclWhere(PrincipalsFromContext(ctx))),
+		chroma.WithIncludeGet(include...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	records := toRecords(res)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrDocNotFound, collectionName, id)
+	}
+	return &records[0], nil
+}
+
 // DeleteDoc deletes a document by id from a collection
 func (s *IngestService) DeleteDoc(ctx context.Context, collectionName, id string) error {
+	if IsSnapshotCollection(collectionName) {
+		return ErrSnapshotReadOnly
+	}
 	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
 	if err != nil {
 		return fmt.Errorf("err getting collection %s to delete: %w", collectionName, err)
 
 	}
-	return collection.Delete(ctx, chroma.WithIDsDelete(chroma.DocumentID(id)))
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: collectionName, Op: IntentDelete, IDs: []string{id}})
+	if err != nil {
+		return err
+	}
+	err = collection.Delete(ctx, chroma.WithIDsDelete(chroma.DocumentID(id)))
+	finish(err)
+	if err != nil {
+		return err
+	}
+	s.publishChange(EventDeleted, collectionName, map[string]interface{}{"id": id})
+	return nil
+}
+
+// DeleteCollection drops a collection. Protected collections require force
+// and an admin caller.
+func (s *IngestService) DeleteCollection(ctx context.Context, name string, force bool) error {
+	if err := s.checkDestructive(ctx, name, force); err != nil {
+		return err
+	}
+	return s.dropCollection(ctx, name)
 }
 
-// DeleteCollection removes the entire collection
-func (s *IngestService) DeleteCollection(ctx context.Context, name string) error {
-	return s.chromaDB.DeleteCollection(ctx, name)
+// dropCollection deletes a collection and its title collection from Chroma
+// and clears any trash entry.
+func (s *IngestService) dropCollection(ctx context.Context, name string) error {
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: name, Op: IntentDeleteCollection})
+	if err != nil {
+		return err
+	}
+	err = s.chromaDB.DeleteCollection(ctx, name)
+	finish(err)
+	if err != nil {
+		return err
+	}
+	if boost, _ := s.CollectionTitleBoost(name); boost.Weight > 0 {
+		if err := s.chromaDB.DeleteCollection(ctx, titleCollection(name)); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", name).Warn("Failed to delete title collection")
+		}
+	}
+	if entry, _ := s.trashEntry(name); entry != nil {
+		if err := s.settings.SetCollectionSetting(name, trashSettingKey, nil); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", name).Warn("Failed to clear trash entry")
+		}
+	}
+	s.forgetIndexed(ctx, name, "")
+	if s.versions != nil {
+		if err := s.versions.ForgetDocVersions(name); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", name).Warn("Failed to clear document versions")
+		}
+	}
+	s.publishChange(EventDeleted, name, map[string]interface{}{"collection_deleted": true})
+	return nil
 }
 
 func (s *IngestService) GetCollectionDocuments(ctx context.Context, collectionName string) ([]Document, error) {
-	logging.GetLogger().WithField("collectionName", collectionName).Info("Getting collection documents")
+	logging.FromContext(ctx).WithField("collectionName", collectionName).Info("Getting collection documents")
 
 	// Get the collection
 	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
@@ -340,16 +980,16 @@ func (s *IngestService) GetCollectionDocuments(ctx context.Context, collectionNa
 		return nil, fmt.Errorf("failed to get collection: %w", err)
 	}
 
-	logging.GetLogger().WithField("collectionName", collectionName).Info("Collection found, getting documents")
+	logging.FromContext(ctx).WithField("collectionName", collectionName).Info("Collection found, getting documents")
 
-	// Get all documents from the collection
-	results, err := collection.Get(ctx)
+	// Get all documents the caller's principals may see
+	results, err := collection.Get(ctx, chroma.WithWhereGet(aclWhere(PrincipalsFromContext(ctx))))
 	if err != nil {
-		logging.GetLogger().WithError(err).WithField("collectionName", collectionName).Error("Failed to get documents from collection")
+		logging.FromContext(ctx).WithError(err).WithField("collectionName", collectionName).Error("Failed to get documents from collection")
 		return nil, fmt.Errorf("failed to get documents: %w", err)
 	}
 
-	logging.GetLogger().WithFields(logrus.Fields{
+	logging.FromContext(ctx).WithFields(logrus.Fields{
 		"collectionName": collectionName,
 		"documentCount":  len(results.GetDocuments()),
 	}).Info("Retrieved documents from collection")
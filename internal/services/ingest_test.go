@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/typicalfo/forge/backend/internal/db"
@@ -80,3 +81,44 @@ func TestIngestService_Search(t *testing.T) {
 	}
 	t.Logf("Search results: %+v", results)
 }
+
+func TestChunkID(t *testing.T) {
+	id := ChunkID("docs/guide.md", 0, "hello")
+	if len(id) != 16 {
+		t.Fatalf("ChunkID length = %d, want 16", len(id))
+	}
+	if again := ChunkID("docs/./guide.md", 0, "hello"); again != id {
+		t.Errorf("ChunkID not stable across equivalent paths: %s != %s", again, id)
+	}
+	for _, other := range []string{
+		ChunkID("docs/guide.md", 1, "hello"),
+		ChunkID("docs/guide.md", 0, "hello!"),
+		ChunkID("docs/guide.m", 0, "dhello"),
+	} {
+		if other == id {
+			t.Errorf("ChunkID collision for different inputs: %s", other)
+		}
+	}
+}
+
+func TestGetDoc(t *testing.T) {
+	col := &fileCollection{records: map[string]Record{
+		"c1": {ID: "c1", Document: "full text", Metadata: map[string]interface{}{"file_name": "a.md"}, Embedding: []float32{1, 2}},
+	}}
+	s := NewIngestService(fileClient{collection: col})
+	ctx := context.Background()
+	doc, err := s.GetDoc(ctx, "docs", "c1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Document != "full text" || doc.Metadata["file_name"] != "a.md" || doc.Embedding != nil {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	if doc, err := s.GetDoc(ctx, "docs", "c1", true); err != nil || len(doc.Embedding) != 2 {
+		t.Errorf("expected the embedding on request, got %+v, %v", doc, err)
+	}
+	col.records = map[string]Record{}
+	if _, err := s.GetDoc(ctx, "docs", "c1", false); !errors.Is(err, ErrDocNotFound) {
+		t.Errorf("expected ErrDocNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Mutation kinds recorded in the intent log.
+const (
+	IntentUpsert           = "upsert"
+	IntentAdd              = "add"
+	IntentDelete           = "delete"
+	IntentDeleteCollection = "delete_collection"
+)
+
+// Reconciliation outcomes.
+const (
+	ResolutionApplied    = "applied"     // Chroma already reflects the mutation
+	ResolutionNotApplied = "not_applied" // none of it reached Chroma
+	ResolutionRolledBack = "rolled_back" // a partial write was removed so it can be retried cleanly
+	ResolutionReapplied  = "reapplied"   // an incomplete delete was run again
+)
+
+// IntentStore is a write-ahead log of Chroma mutations.
+type IntentStore interface {
+	BeginIntent(in config.Intent) (int64, error)
+	FinishIntent(id int64, status, errMsg string) error
+	ResolveIntent(id int64, resolution string) error
+	ListIntents(statuses ...string) ([]config.Intent, error)
+}
+
+// WithIntentLog records every write and delete before it is sent to Chroma.
+func (s *IngestService) WithIntentLog(store IntentStore) *IngestService {
+	s.intents = store
+	s.intentsSince = time.Now().Truncate(time.Second)
+	return s
+}
+
+// beginIntent records a pending mutation and returns a function that marks
+// it done or failed. Without an intent log it is a no-op. A mutation that
+// cannot be logged is not attempted.
+func (s *IngestService) beginIntent(ctx context.Context, in config.Intent) (finish func(error), err error) {
+	if s.intents == nil {
+		return func(error) {}, nil
+	}
+	id, err := s.intents.BeginIntent(in)
+	if err != nil {
+		return nil, fmt.Errorf("record mutation intent: %w", err)
+	}
+	return func(err error) {
+		status, msg := config.IntentDone, ""
+		if err != nil {
+			status, msg = config.IntentFailed, err.Error()
+		}
+		if ferr := s.intents.FinishIntent(id, status, msg); ferr != nil {
+			logging.FromContext(ctx).WithError(ferr).WithField("intent", id).Warn("Failed to finish mutation intent")
+		}
+	}, nil
+}
+
+// Intents lists logged mutations in the given statuses.
+func (s *IngestService) Intents(statuses ...string) ([]config.Intent, error) {
+	if s.intents == nil {
+		return nil, nil
+	}
+	return s.intents.ListIntents(statuses...)
+}
+
+// ReconcileIntents checks failed intents, and intents left pending by an
+// earlier process, against Chroma and settles them: writes that fully
+// landed count as applied, partial writes are rolled back so the file can be
+// re-ingested (its MD5 would otherwise make ingest skip it), and incomplete
+// deletes are run again. Intents pending in this process may still be in
+// flight and are left alone.
+func (s *IngestService) ReconcileIntents(ctx context.Context) ([]config.Intent, error) {
+	if s.intents == nil {
+		return nil, nil
+	}
+	open, err := s.intents.ListIntents(config.IntentPending, config.IntentFailed)
+	if err != nil {
+		return nil, err
+	}
+	var settled []config.Intent
+	for _, in := range open {
+		if in.Status == config.IntentPending && !in.CreatedAt.Before(s.intentsSince) {
+			continue
+		}
+		resolution, err := s.reconcile(ctx, in)
+		log := logging.FromContext(ctx).WithFields(logrus.Fields{"intent": in.ID, "op": in.Op, "collection": in.Collection})
+		if err != nil {
+			// Left open for the next attempt
+			log.WithError(err).Warn("Failed to reconcile mutation intent")
+			continue
+		}
+		if err := s.intents.ResolveIntent(in.ID, resolution); err != nil {
+			return settled, err
+		}
+		log.WithField("resolution", resolution).Info("Reconciled mutation intent")
+		in.Status, in.Resolution = config.IntentReconciled, resolution
+		settled = append(settled, in)
+	}
+	return settled, nil
+}
+
+func (s *IngestService) reconcile(ctx context.Context, in config.Intent) (string, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, in.Collection)
+	if err != nil {
+		// A missing collection holds none of the records; a deleted one is
+		// what a collection delete wanted. Any other failure leaves the
+		// intent open.
+		if exists, lerr := s.collectionListed(ctx, in.Collection); lerr != nil || exists {
+			return "", err
+		}
+		if in.Op == IntentDeleteCollection || in.Op == IntentDelete {
+			return ResolutionApplied, nil
+		}
+		return ResolutionNotApplied, nil
+	}
+
+	switch in.Op {
+	case IntentDeleteCollection:
+		if in.Status == config.IntentFailed {
+			// The caller saw the error; don't drop the collection behind its back
+			return ResolutionNotApplied, nil
+		}
+		if err := s.chromaDB.DeleteCollection(ctx, in.Collection); err != nil {
+			return "", err
+		}
+		s.publishChange(EventDeleted, in.Collection, map[string]interface{}{"collection_deleted": true})
+		return ResolutionReapplied, nil
+
+	case IntentDelete:
+		present, err := s.presentIDs(ctx, collection, in)
+		if err != nil {
+			return "", err
+		}
+		if len(present) == 0 {
+			return ResolutionApplied, nil
+		}
+		if in.Status == config.IntentFailed {
+			// As with a collection delete, the caller saw the error
+			return ResolutionNotApplied, nil
+		}
+		if err := deleteIDs(ctx, collection, present); err != nil {
+			return "", err
+		}
+		s.publishChange(EventDeleted, in.Collection, map[string]interface{}{"ids": len(present)})
+		return ResolutionReapplied, nil
+
+	default: // IntentAdd, IntentUpsert
+		present, err := s.presentIDs(ctx, collection, in)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(present) == 0:
+			return ResolutionNotApplied, nil
+		case len(present) == len(in.IDs):
+			return ResolutionApplied, nil
+		}
+		if err := deleteIDs(ctx, collection, present); err != nil {
+			return "", err
+		}
+		s.publishChange(EventDeleted, in.Collection, map[string]interface{}{"ids": len(present)})
+		return ResolutionRolledBack, nil
+	}
+}
+
+// collectionListed reports whether Chroma lists a collection named name.
+func (s *IngestService) collectionListed(ctx context.Context, name string) (bool, error) {
+	collections, err := s.chromaDB.ListCollections(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range collections {
+		if c.Name() == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// presentIDs returns which of the intent's records exist: those of its IDs
+// that match its filter, or everything matching the filter when it lists no
+// IDs.
+func (s *IngestService) presentIDs(ctx context.Context, collection chroma.Collection, in config.Intent) ([]string, error) {
+	var clauses []chroma.WhereClause
+	for k, v := range in.Filter {
+		clauses = append(clauses, chroma.EqString(k, v))
+	}
+	where := andWhere(clauses)
+	if len(in.IDs) == 0 {
+		records, err := scanRecords(ctx, collection, where, chroma.IncludeMetadatas)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(records))
+		for i, r := range records {
+			ids[i] = r.ID
+		}
+		return ids, nil
+	}
+	var present []string
+	for start := 0; start < len(in.IDs); start += getPageSize {
+		page := in.IDs[start:min(start+getPageSize, len(in.IDs))]
+		docIDs := make([]chroma.DocumentID, len(page))
+		for i, id := range page {
+			docIDs[i] = chroma.DocumentID(id)
+		}
+		opts := []chroma.CollectionGetOption{chroma.WithIDsGet(docIDs...), chroma.WithIncludeGet(chroma.IncludeMetadatas)}
+		if where != nil {
+			opts = append(opts, chroma.WithWhereGet(where))
+		}
+		res, err := collection.Get(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range res.GetIDs() {
+			present = append(present, string(id))
+		}
+	}
+	return present, nil
+}
+
+func deleteIDs(ctx context.Context, collection chroma.Collection, ids []string) error {
+	for start := 0; start < len(ids); start += getPageSize {
+		page := ids[start:min(start+getPageSize, len(ids))]
+		docIDs := make([]chroma.DocumentID, len(page))
+		for i, id := range page {
+			docIDs[i] = chroma.DocumentID(id)
+		}
+		if err := collection.Delete(ctx, chroma.WithIDsDelete(docIDs...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// memIntents is an in-memory IntentStore.
+type memIntents struct {
+	intents []config.Intent
+}
+
+func (m *memIntents) BeginIntent(in config.Intent) (int64, error) {
+	in.ID = int64(len(m.intents) + 1)
+	in.Status = config.IntentPending
+	in.CreatedAt = time.Now()
+	m.intents = append(m.intents, in)
+	return in.ID, nil
+}
+
+func (m *memIntents) FinishIntent(id int64, status, errMsg string) error {
+	m.intents[id-1].Status, m.intents[id-1].Error = status, errMsg
+	return nil
+}
+
+func (m *memIntents) ResolveIntent(id int64, resolution string) error {
+	m.intents[id-1].Status, m.intents[id-1].Resolution = config.IntentReconciled, resolution
+	return nil
+}
+
+func (m *memIntents) ListIntents(statuses ...string) ([]config.Intent, error) {
+	var out []config.Intent
+	for _, in := range m.intents {
+		for _, st := range statuses {
+			if in.Status == st {
+				out = append(out, in)
+			}
+		}
+	}
+	return out, nil
+}
+
+// idCollection holds record IDs only.
+type idCollection struct {
+	chroma.Collection
+	ids map[string]bool
+}
+
+func (c *idCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := &chroma.GetResultImpl{}
+	for _, id := range op.Ids {
+		if c.ids[string(id)] {
+			res.Ids = append(res.Ids, id)
+		}
+	}
+	return res, nil
+}
+
+func (c *idCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		return err
+	}
+	for _, id := range op.Ids {
+		delete(c.ids, string(id))
+	}
+	return nil
+}
+
+type idClient struct {
+	chroma.Client
+	collection *idCollection
+}
+
+func (c idClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if c.collection == nil {
+		return nil, errors.New("collection not found")
+	}
+	return c.collection, nil
+}
+
+func (c idClient) ListCollections(ctx context.Context, opts ...chroma.ListCollectionsOption) ([]chroma.Collection, error) {
+	return nil, nil
+}
+
+func TestReconcileIntents(t *testing.T) {
+	col := &idCollection{ids: map[string]bool{"a1": true, "a2": true, "b1": true, "d1": true, "f1": true}}
+	store := &memIntents{}
+	// Left by an earlier process
+	store.intents = []config.Intent{
+		{ID: 1, Op: IntentUpsert, IDs: []string{"a1", "a2"}, Status: config.IntentPending}, // fully landed
+		{ID: 2, Op: IntentUpsert, IDs: []string{"b1", "b2"}, Status: config.IntentPending}, // partial
+		{ID: 3, Op: IntentAdd, IDs: []string{"c1"}, Status: config.IntentFailed},           // never landed
+		{ID: 4, Op: IntentDelete, IDs: []string{"d1"}, Status: config.IntentPending},       // interrupted delete
+		{ID: 5, Op: IntentDeleteCollection, Status: config.IntentFailed},                   // reported to the caller
+		{ID: 6, Op: IntentUpsert, IDs: []string{"a1"}, Status: config.IntentDone},          // finished
+		{ID: 7, Op: IntentDelete, IDs: []string{"f1"}, Status: config.IntentFailed},        // reported to the caller
+	}
+	s := NewIngestService(idClient{collection: col}).WithIntentLog(store)
+	// Pending in this process, possibly still in flight
+	if _, err := s.beginIntent(context.Background(), config.Intent{Op: IntentAdd, IDs: []string{"e1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	settled, err := s.ReconcileIntents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]string{1: ResolutionApplied, 2: ResolutionRolledBack, 3: ResolutionNotApplied, 4: ResolutionReapplied, 5: ResolutionNotApplied, 7: ResolutionNotApplied}
+	if len(settled) != len(want) {
+		t.Fatalf("expected %d settled intents, got %+v", len(want), settled)
+	}
+	for _, in := range settled {
+		if in.Resolution != want[in.ID] {
+			t.Errorf("intent %d: got %s, want %s", in.ID, in.Resolution, want[in.ID])
+		}
+	}
+	if col.ids["b1"] || col.ids["d1"] || !col.ids["a1"] || !col.ids["f1"] {
+		t.Errorf("unexpected records after reconcile: %v", col.ids)
+	}
+	if store.intents[7].Status != config.IntentPending {
+		t.Errorf("in-flight intent was reconciled: %+v", store.intents[7])
+	}
+}
+
+// listedCollection is a collection known only by name.
+type listedCollection struct {
+	chroma.Collection
+	name string
+}
+
+func (c listedCollection) Name() string { return c.name }
+
+// unreachableClient fails every collection lookup, listing listed.
+type unreachableClient struct {
+	chroma.Client
+	listed []string
+}
+
+func (c unreachableClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return nil, errors.New("context deadline exceeded")
+}
+
+func (c unreachableClient) ListCollections(ctx context.Context, opts ...chroma.ListCollectionsOption) ([]chroma.Collection, error) {
+	var out []chroma.Collection
+	for _, name := range c.listed {
+		out = append(out, listedCollection{name: name})
+	}
+	return out, nil
+}
+
+func TestReconcileIntentsCollectionLookupFailure(t *testing.T) {
+	store := &memIntents{intents: []config.Intent{
+		{ID: 1, Op: IntentUpsert, Collection: "docs", IDs: []string{"a1"}, Status: config.IntentPending},
+	}}
+	// The collection exists, so the failed lookup says nothing about its records
+	s := NewIngestService(unreachableClient{listed: []string{"docs"}}).WithIntentLog(store)
+	if settled, err := s.ReconcileIntents(context.Background()); err != nil || len(settled) != 0 {
+		t.Fatalf("expected the intent left open, got %+v, %v", settled, err)
+	}
+
+	s = NewIngestService(unreachableClient{listed: []string{"other"}}).WithIntentLog(store)
+	settled, err := s.ReconcileIntents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settled) != 1 || settled[0].Resolution != ResolutionNotApplied {
+		t.Errorf("expected a missing collection to resolve the upsert as not applied, got %+v", settled)
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultPathConcurrency = 4
+	maxPathConcurrency     = 16
+)
+
+var (
+	// ErrPathIngestDisabled is returned when no ingest_path_roots are set.
+	ErrPathIngestDisabled = errors.New("path ingestion is not configured")
+	// ErrPathNotAllowed is returned for a directory outside every allowed root.
+	ErrPathNotAllowed = errors.New("path is outside the allowed roots")
+	// ErrInvalidPathSpec is returned for an unusable path ingest spec.
+	ErrInvalidPathSpec = errors.New("invalid path spec")
+)
+
+// PathIngestSpec selects files under a server-local directory. Include and
+// Exclude are globs relative to Path, as in pipeline path sources.
+type PathIngestSpec struct {
+	Path       string                 `json:"path"`
+	Collection string                 `json:"collection"`
+	Include    []string               `json:"include,omitempty"`
+	Exclude    []string               `json:"exclude,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	ACL        []string               `json:"acl,omitempty"`
+	MaxTokens  int                    `json:"max_tokens,omitempty"`
+	// Concurrency is how many files are ingested at once; default 4, max 16.
+	Concurrency int `json:"concurrency,omitempty"`
+	// XML maps XML files to text and metadata; see XMLMapping.
+	XML *XMLMapping `json:"xml,omitempty"`
+	// Full reads every file and checks it against the collection instead
+	// of trusting the file index (see WithFileIndex).
+	Full bool `json:"full,omitempty"`
+}
+
+// PathIngestReport aggregates the ingest of a directory.
+type PathIngestReport struct {
+	Path     string `json:"path"`
+	Files    int    `json:"files"`
+	Ingested int    `json:"ingested"`
+	Skipped  int    `json:"skipped"`
+	Failed   int    `json:"failed"`
+	Chunks   int    `json:"chunks"`
+	// Added, Updated and Unchanged classify the files by the file index:
+	// ingested for the first time, re-ingested because they changed, or
+	// left alone.
+	Added     int            `json:"added"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Results   []IngestResult `json:"results"`
+	Duration  string         `json:"duration"`
+	Timings   IngestTimings  `json:"timings"`
+}
+
+// WithPathRoots allows IngestPath to read directories under roots.
+func (s *IngestService) WithPathRoots(roots []string) *IngestService {
+	s.pathRoots = roots
+	return s
+}
+
+// IngestPath ingests the matching files under spec.Path concurrently. The
+// directory must lie within one of the allowed roots; symlinks are not
+// followed out of it.
+func (s *IngestService) IngestPath(ctx context.Context, spec PathIngestSpec) (*PathIngestReport, error) {
+	if len(s.pathRoots) == 0 {
+		return nil, ErrPathIngestDisabled
+	}
+	if spec.Collection == "" {
+		return nil, fmt.Errorf("%w: collection is required", ErrInvalidPathSpec)
+	}
+	if _, err := compileGlobs(append(spec.Include, spec.Exclude...)); err != nil {
+		return nil, fmt.Errorf("%w: invalid glob: %v", ErrInvalidPathSpec, err)
+	}
+	if spec.XML != nil {
+		if err := spec.XML.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPathSpec, err)
+		}
+	}
+	dir, err := s.allowedPath(spec.Path)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := spec.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPathConcurrency
+	}
+	concurrency = min(concurrency, maxPathConcurrency)
+
+	started := time.Now()
+	files, err := walkFiles(dir, spec.Include, spec.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(dir))
+	opts := IngestOptions{
+		Metadata:  spec.Metadata,
+		ACL:       spec.ACL,
+		MaxTokens: spec.MaxTokens,
+		Source:    IngestSource{ID: "path-" + hex.EncodeToString(sum[:8]), Kind: SourcePath, Ref: dir},
+		XML:       spec.XML,
+	}
+
+	index, err := s.openDirIndex(spec.Collection, opts.Source.ID, spec.Full)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]IngestResult, len(files))
+	changes := make([]string, len(files))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i, rel := range files {
+		g.Go(func() error {
+			results[i], changes[i] = s.ingestLocalFile(gctx, spec.Collection, dir, rel, opts, index)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if err := index.save(); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("path", dir).Warn("Failed to save file index")
+	}
+
+	// Like bucket runs, unchanged files are counted but not listed
+	report := &PathIngestReport{Path: dir, Files: len(files), Results: []IngestResult{}}
+	for i, r := range results {
+		if r.Status != statusUnchanged {
+			report.Results = append(report.Results, r)
+		}
+		switch r.Status {
+		case "ingested":
+			report.Ingested++
+			report.Chunks += r.Chunks
+			if changes[i] == fileUpdated {
+				report.Updated++
+			} else {
+				report.Added++
+			}
+		case "skipped":
+			report.Skipped++
+		case statusUnchanged:
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+	}
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+	report.Timings = sumTimings(report.Results)
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"path":      dir,
+		"files":     report.Files,
+		"ingested":  report.Ingested,
+		"unchanged": report.Unchanged,
+		"failed":    report.Failed,
+	}).Info("Path ingest complete")
+	return report, nil
+}
+
+func (s *IngestService) ingestLocalFile(ctx context.Context, collection, dir, rel string, opts IngestOptions, index *dirIndex) (IngestResult, string) {
+	if err := ctx.Err(); err != nil {
+		return IngestResult{Status: "error", File: rel, Error: err.Error()}, ""
+	}
+	return s.ingestIndexed(ctx, collection, filepath.Join(dir, filepath.FromSlash(rel)), rel, opts, index)
+}
+
+// allowedPath resolves p, following symlinks, and checks that it is a
+// directory within an allowed root.
+func (s *IngestService) allowedPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("%w: path is required", ErrInvalidPathSpec)
+	}
+	dir, err := filepath.Abs(p)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPathSpec, err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %s is not a directory", ErrInvalidPathSpec, p)
+	}
+	for _, root := range s.pathRoots {
+		root, err := filepath.Abs(root)
+		if err == nil {
+			root, err = filepath.EvalSymlinks(root)
+		}
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrPathNotAllowed, p)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// writeCollection records upserted documents and holds nothing to dedupe against.
+type writeCollection struct {
+	chroma.Collection
+	mu    sync.Mutex
+	files map[string]int
+	// metadata holds the metadata of each file's last chunk.
+	metadata map[string]chroma.DocumentMetadata
+}
+
+func (c *writeCollection) Name() string { return "docs" }
+
+func (c *writeCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	return &chroma.GetResultImpl{}, nil
+}
+
+func (c *writeCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	op, err := chroma.NewCollectionAddOp(opts...)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range op.Metadatas {
+		name, _ := m.GetString(DefaultSystemKeys.FileName)
+		c.files[name]++
+		if c.metadata != nil {
+			c.metadata[name] = m
+		}
+	}
+	return nil
+}
+
+type writeClient struct {
+	chroma.Client
+	collection *writeCollection
+}
+
+func (c writeClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func (c writeClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestIngestPath(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"docs/a.md":        "Alpha",
+		"docs/b.txt":       "Beta",
+		"docs/drafts/c.md": "Draft",
+		"docs/d.md":        "Delta",
+		"secret.md":        "Outside",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	col := &writeCollection{files: map[string]int{}}
+	s := NewIngestService(writeClient{collection: col})
+	spec := PathIngestSpec{Path: filepath.Join(root, "docs"), Collection: "docs", Include: []string{"**/*.md"}, Exclude: []string{"drafts/**"}, Concurrency: 2}
+
+	if _, err := s.IngestPath(context.Background(), spec); !errors.Is(err, ErrPathIngestDisabled) {
+		t.Errorf("expected ErrPathIngestDisabled without roots, got %v", err)
+	}
+	s.WithPathRoots([]string{filepath.Join(root, "docs")})
+
+	report, err := s.IngestPath(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range report.Results {
+		names = append(names, r.File)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.md,d.md" || report.Files != 2 || report.Ingested != 2 || report.Failed != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if col.files["a.md"] != 1 || col.files["d.md"] != 1 {
+		t.Errorf("unexpected writes %v", col.files)
+	}
+
+	if _, err := s.IngestPath(context.Background(), PathIngestSpec{Path: root, Collection: "docs"}); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("expected ErrPathNotAllowed for the parent of the root, got %v", err)
+	}
+	if _, err := s.IngestPath(context.Background(), PathIngestSpec{Path: filepath.Join(root, "docs", "..", "secret.md"), Collection: "docs"}); !errors.Is(err, ErrInvalidPathSpec) {
+		t.Errorf("expected ErrInvalidPathSpec for a file, got %v", err)
+	}
+	link := filepath.Join(root, "docs", "escape")
+	if err := os.Symlink(root, link); err == nil {
+		if _, err := s.IngestPath(context.Background(), PathIngestSpec{Path: link, Collection: "docs"}); !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("expected symlink out of the root to be refused, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+func init() {
+	extractors[".md"] = extractMarkdown
+	extractors[".markdown"] = extractMarkdown
+}
+
+var (
+	atxHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fenceRe      = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+)
+
+// extractMarkdown splits a Markdown document into one section per ATX
+// heading, recording the heading path. Headings inside code fences are
+// ignored, and fenced blocks are kept whole when chunking. YAML or TOML
+// front matter is stripped and recorded on every section.
+func extractMarkdown(content []byte) ([]docSection, error) {
+	frontMatter, content := splitFrontMatter(content)
+	var sections []docSection
+	var headings headingStack
+	var body []string
+	hasBody := false
+	flush := func() {
+		if hasBody {
+			md := headings.metadata()
+			for k, v := range frontMatter {
+				md[k] = v
+			}
+			sections = append(sections, docSection{text: strings.Join(body, "\n"), metadata: md, split: markdownUnits})
+		}
+		body, hasBody = nil, false
+	}
+
+	fence := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			body = append(body, line)
+			hasBody = true
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+		} else if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			flush()
+			headings = headings.push(len(m[1]), strings.TrimSpace(m[2]))
+			body = append(body, line)
+			continue
+		}
+		body = append(body, line)
+		if strings.TrimSpace(line) != "" {
+			hasBody = true
+		}
+	}
+	flush()
+	return sections, nil
+}
+
+// closesFence reports whether line closes a fence opened with open: the same
+// character, at least as long, and nothing else on the line.
+func closesFence(line, open string) bool {
+	t := strings.TrimSpace(line)
+	return len(t) >= len(open) && strings.Trim(t, open[:1]) == "" && len(line)-len(strings.TrimLeft(line, " ")) <= 3
+}
+
+// markdownUnits splits text into lines, keeping each fenced code block as a
+// single unit so chunk boundaries never fall inside one.
+func markdownUnits(text string) []string {
+	var units, block []string
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case fence != "":
+			block = append(block, line)
+			if closesFence(line, fence) {
+				units = append(units, strings.Join(block, "\n"))
+				block, fence = nil, ""
+			}
+		case fenceRe.MatchString(line):
+			fence = fenceRe.FindStringSubmatch(line)[1]
+			block = []string{line}
+		default:
+			units = append(units, line)
+		}
+	}
+	if len(block) > 0 { // unterminated fence runs to the end
+		units = append(units, strings.Join(block, "\n"))
+	}
+	return units
+}
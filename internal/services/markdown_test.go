@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractMarkdownHeadingPath(t *testing.T) {
+	doc := strings.Join([]string{
+		"Intro text",
+		"# Install",
+		"## Linux",
+		"apt install forge",
+		"```sh",
+		"# not a heading",
+		"```",
+		"### Notes ###",
+		"needs root",
+		"## macOS",
+		"brew install forge",
+		"# Usage",
+	}, "\n")
+	sections, err := extractSections("README.md", []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", "Install > Linux", "Install > Linux > Notes", "Install > macOS"}
+	if len(sections) != len(want) {
+		t.Fatalf("expected %d sections, got %d: %+v", len(want), len(sections), sections)
+	}
+	for i, w := range want {
+		if got, _ := sections[i].metadata[headingKey].(string); got != w {
+			t.Errorf("section %d: heading %q, want %q", i, got, w)
+		}
+	}
+	if !strings.Contains(sections[1].text, "# not a heading") {
+		t.Errorf("fenced content missing from section: %q", sections[1].text)
+	}
+}
+
+func TestMarkdownChunksKeepFencesWhole(t *testing.T) {
+	tok, _ := GetTokenizer("whitespace")
+	text := "intro words here\n```go\nfunc a() {}\nfunc b() {}\nfunc c() {}\n```\ntail"
+	chunks := chunkUnits(markdownUnits(text), 4, tok)
+	for _, c := range chunks {
+		if strings.Count(c, "```") == 1 {
+			t.Fatalf("chunk splits a code fence: %q", chunks)
+		}
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected intro, fence and tail chunks, got %q", chunks)
+	}
+}
+
+func TestExtractMarkdownFrontMatter(t *testing.T) {
+	yamlDoc := "---\ntitle: Install guide\ntags: [setup, linux]\ndate: 2024-03-01\ndraft: false\nauthor: {name: x}\n---\n# Install\nRun it.\n"
+	sections, err := extractSections("guide.md", []byte(yamlDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 1 || strings.Contains(sections[0].text, "title:") {
+		t.Fatalf("expected front matter stripped, got %+v", sections)
+	}
+	md := sections[0].metadata
+	if md["user_title"] != "Install guide" || md["user_tags"] != "setup, linux" || md["user_date"] != "2024-03-01" || md["user_draft"] != false || md["user_author"] != nil {
+		t.Errorf("unexpected metadata %v", md)
+	}
+	if md[headingKey] != "Install" {
+		t.Errorf("expected heading path kept, got %v", md)
+	}
+
+	tomlDoc := "+++\ntitle = \"Notes\"\ndate = 2024-03-01\nweight = 3\n+++\nBody\n"
+	sections, _ = extractSections("notes.md", []byte(tomlDoc))
+	if md := sections[0].metadata; md["user_title"] != "Notes" || md["user_date"] != "2024-03-01" || md["user_weight"] != int64(3) {
+		t.Errorf("unexpected TOML metadata %v", md)
+	}
+
+	// A leading thematic break is not front matter
+	sections, _ = extractSections("hr.md", []byte("---\nJust text.\n---\nMore\n"))
+	if len(sections) != 1 || !strings.HasPrefix(sections[0].text, "---") || len(sections[0].metadata) != 0 {
+		t.Errorf("expected the document unchanged, got %+v", sections)
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+const (
+	// mirrorQueueSize bounds the writes waiting to be mirrored; past it a
+	// write's collection is queued for reconciliation instead of blocking.
+	mirrorQueueSize = 1024
+	// mirrorOpTimeout bounds mirroring one write.
+	mirrorOpTimeout = 2 * time.Minute
+)
+
+// MirrorQueueStore persists the collections waiting to be reconciled.
+type MirrorQueueStore interface {
+	QueueMirror(collection, reason string) error
+	MirrorQueue() ([]config.MirrorEntry, error)
+	RecordMirrorAttempt(collection, lastError string) error
+	DequeueMirror(collection string, since time.Time) error
+}
+
+// MirrorStatus reports the writes waiting to be mirrored, how many were
+// mirrored or failed since start, and the collections waiting to be
+// reconciled.
+type MirrorStatus struct {
+	Pending  int                  `json:"pending"`
+	Mirrored int64                `json:"mirrored"`
+	Failed   int64                `json:"failed"`
+	Queue    []config.MirrorEntry `json:"queue"`
+}
+
+// MirrorReconcile reports reconciling a collection's copy on the secondary.
+// Dropped is set when the collection no longer exists on the primary.
+type MirrorReconcile struct {
+	ReplicaCheck
+	Dropped bool `json:"dropped,omitempty"`
+}
+
+// Mirror makes every write through its Client on a secondary Chroma server
+// too, after the primary accepted it and without holding up the caller.
+// Records are copied from the primary with their embeddings, so the
+// secondary embeds nothing. A collection whose write cannot be mirrored, or
+// doesn't fit in the queue, is queued for reconciliation: a full copy from
+// the primary, retried until it succeeds.
+type Mirror struct {
+	primary   chroma.Client
+	secondary chroma.Client
+	queue     MirrorQueueStore
+	ops       chan mirrorOp
+	pending   atomic.Int64 // writes queued or being mirrored
+	mirrored  atomic.Int64
+	failed    atomic.Int64
+	mu        sync.Mutex // serializes mirrored writes and reconciliation
+}
+
+// mirrorOp is one write to repeat on the secondary: copying records (or
+// creating the collection, without ids), a delete, or dropping the
+// collection.
+type mirrorOp struct {
+	collection string
+	ids        []string
+	del        *chroma.CollectionDeleteOp
+	drop       bool
+}
+
+func NewMirror(primary, secondary chroma.Client, queue MirrorQueueStore) *Mirror {
+	return &Mirror{primary: primary, secondary: secondary, queue: queue, ops: make(chan mirrorOp, mirrorQueueSize)}
+}
+
+// Client returns the primary client with its writes mirrored.
+func (m *Mirror) Client() chroma.Client {
+	return mirrorClient{Client: m.primary, m: m}
+}
+
+// Status reports the mirror's progress and reconciliation queue.
+func (m *Mirror) Status() (*MirrorStatus, error) {
+	queue, err := m.queue.MirrorQueue()
+	if err != nil {
+		return nil, err
+	}
+	return &MirrorStatus{Pending: int(m.pending.Load()), Mirrored: m.mirrored.Load(), Failed: m.failed.Load(), Queue: queue}, nil
+}
+
+func (m *Mirror) enqueue(op mirrorOp) {
+	m.pending.Add(1)
+	select {
+	case m.ops <- op:
+	default:
+		m.pending.Add(-1)
+		m.requeue(op.collection, "mirror queue full")
+	}
+}
+
+// requeue queues a collection for reconciliation.
+func (m *Mirror) requeue(collection, reason string) {
+	m.failed.Add(1)
+	if err := m.queue.QueueMirror(collection, reason); err != nil {
+		logging.GetLogger().WithError(err).WithField("collection", collection).Error("Failed to queue collection for mirror reconciliation")
+	}
+}
+
+// Run mirrors queued writes and reconciles the queued collections, at once
+// and then every interval, until ctx is done. Writes still waiting then
+// queue their collections for reconciliation.
+func (m *Mirror) Run(ctx context.Context, interval time.Duration) {
+	m.reconcileLogged(ctx)
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case op := <-m.ops:
+					m.requeue(op.collection, "shut down before mirroring")
+					m.pending.Add(-1)
+				default:
+					return
+				}
+			}
+		case op := <-m.ops:
+			m.apply(ctx, op)
+			m.pending.Add(-1)
+		case <-ticks:
+			m.reconcileLogged(ctx)
+		}
+	}
+}
+
+func (m *Mirror) apply(ctx context.Context, op mirrorOp) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mirrorOpTimeout)
+	defer cancel()
+	m.mu.Lock()
+	err := m.mirror(ctx, op)
+	m.mu.Unlock()
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", op.collection).Warn("Failed to mirror write; collection queued for reconciliation")
+		m.requeue(op.collection, err.Error())
+		return
+	}
+	m.mirrored.Add(1)
+}
+
+func (m *Mirror) mirror(ctx context.Context, op mirrorOp) error {
+	switch {
+	case op.drop:
+		return m.secondary.DeleteCollection(ctx, op.collection)
+	case op.del != nil:
+		target, err := m.secondary.GetCollection(ctx, op.collection)
+		if err != nil {
+			return fmt.Errorf("get collection %q: %w", op.collection, err)
+		}
+		opts := []chroma.CollectionDeleteOption{chroma.WithIDsDelete(op.del.Ids...)}
+		if op.del.Where != nil {
+			opts = append(opts, chroma.WithWhereDelete(op.del.Where))
+		}
+		if op.del.WhereDocument != nil {
+			opts = append(opts, chroma.WithWhereDocumentDelete(op.del.WhereDocument))
+		}
+		return target.Delete(ctx, opts...)
+	}
+	source, err := m.primary.GetCollection(ctx, op.collection)
+	if err != nil {
+		return fmt.Errorf("get collection %q: %w", op.collection, err)
+	}
+	target, err := copyTarget(ctx, m.secondary, source)
+	if err != nil || len(op.ids) == 0 {
+		return err
+	}
+	for start := 0; start < len(op.ids); start += getPageSize {
+		end := min(start+getPageSize, len(op.ids))
+		ids := make([]chroma.DocumentID, 0, end-start)
+		for _, id := range op.ids[start:end] {
+			ids = append(ids, chroma.DocumentID(id))
+		}
+		res, err := source.Get(ctx, chroma.WithIDsGet(ids...), chroma.WithIncludeGet(chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings))
+		if err != nil {
+			return fmt.Errorf("get records of %q: %w", op.collection, err)
+		}
+		if err := writeRecords(ctx, target.Upsert, toRecords(res)); err != nil {
+			return fmt.Errorf("copy records of %q: %w", op.collection, err)
+		}
+	}
+	return nil
+}
+
+// Reconcile copies every queued collection from the primary to the
+// secondary, dropping the copies of collections the primary no longer has,
+// and removes them from the queue. Failures stay queued.
+func (m *Mirror) Reconcile(ctx context.Context) ([]MirrorReconcile, error) {
+	queue, err := m.queue.MirrorQueue()
+	if err != nil {
+		return nil, err
+	}
+	out := []MirrorReconcile{}
+	for _, entry := range queue {
+		started := time.Now()
+		m.mu.Lock()
+		result, err := m.reconcile(ctx, entry.Collection)
+		m.mu.Unlock()
+		result.Collection, result.CheckedAt = entry.Collection, started.UTC()
+		if err != nil {
+			result.Error = err.Error()
+			err = m.queue.RecordMirrorAttempt(entry.Collection, err.Error())
+		} else {
+			err = m.queue.DequeueMirror(entry.Collection, started)
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+func (m *Mirror) reconcile(ctx context.Context, collection string) (MirrorReconcile, error) {
+	if _, err := m.primary.GetCollection(ctx, collection); err != nil {
+		if herr := m.primary.Heartbeat(ctx); herr != nil {
+			return MirrorReconcile{}, fmt.Errorf("primary unavailable: %w", herr)
+		}
+		// The primary is up without the collection: it was deleted
+		if _, err := m.secondary.GetCollection(ctx, collection); err != nil {
+			return MirrorReconcile{Dropped: true}, nil
+		}
+		return MirrorReconcile{Dropped: true}, m.secondary.DeleteCollection(ctx, collection)
+	}
+	check, err := copyCollection(ctx, m.primary, m.secondary, collection)
+	return MirrorReconcile{ReplicaCheck: *check}, err
+}
+
+func (m *Mirror) reconcileLogged(ctx context.Context) {
+	results, err := m.Reconcile(ctx)
+	log := logging.FromContext(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Failed to reconcile mirrored collections")
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			log.WithField("collection", r.Collection).WithError(errors.New(r.Error)).Warn("Failed to reconcile mirrored collection")
+		} else {
+			log.WithField("collection", r.Collection).Info("Reconciled mirrored collection")
+		}
+	}
+}
+
+// mirrorClient mirrors collection creation and deletion and hands out
+// collections whose writes are mirrored.
+type mirrorClient struct {
+	chroma.Client
+	m *Mirror
+}
+
+func (c mirrorClient) wrap(col chroma.Collection, err error) (chroma.Collection, error) {
+	if err != nil {
+		return nil, err
+	}
+	return mirrorCollection{Collection: col, m: c.m}, nil
+}
+
+func (c mirrorClient) CreateCollection(ctx context.Context, name string, options ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	col, err := c.wrap(c.Client.CreateCollection(ctx, name, options...))
+	if err == nil {
+		c.m.enqueue(mirrorOp{collection: name})
+	}
+	return col, err
+}
+
+func (c mirrorClient) GetOrCreateCollection(ctx context.Context, name string, options ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	col, err := c.wrap(c.Client.GetOrCreateCollection(ctx, name, options...))
+	if err == nil {
+		c.m.enqueue(mirrorOp{collection: name})
+	}
+	return col, err
+}
+
+func (c mirrorClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.wrap(c.Client.GetCollection(ctx, name, opts...))
+}
+
+func (c mirrorClient) ListCollections(ctx context.Context, opts ...chroma.ListCollectionsOption) ([]chroma.Collection, error) {
+	cols, err := c.Client.ListCollections(ctx, opts...)
+	for i, col := range cols {
+		cols[i] = mirrorCollection{Collection: col, m: c.m}
+	}
+	return cols, err
+}
+
+func (c mirrorClient) DeleteCollection(ctx context.Context, name string, options ...chroma.DeleteCollectionOption) error {
+	err := c.Client.DeleteCollection(ctx, name, options...)
+	if err == nil {
+		c.m.enqueue(mirrorOp{collection: name, drop: true})
+	}
+	return err
+}
+
+// mirrorCollection mirrors the writes the primary accepts.
+type mirrorCollection struct {
+	chroma.Collection
+	m *Mirror
+}
+
+func (c mirrorCollection) Add(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	if err := c.Collection.Add(ctx, opts...); err != nil {
+		return err
+	}
+	c.copyAdded(opts)
+	return nil
+}
+
+func (c mirrorCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	if err := c.Collection.Upsert(ctx, opts...); err != nil {
+		return err
+	}
+	c.copyAdded(opts)
+	return nil
+}
+
+func (c mirrorCollection) Update(ctx context.Context, opts ...chroma.CollectionUpdateOption) error {
+	if err := c.Collection.Update(ctx, opts...); err != nil {
+		return err
+	}
+	op, err := chroma.NewCollectionUpdateOp(opts...)
+	if err != nil || len(op.Ids) == 0 {
+		c.m.requeue(c.Name(), "update without ids")
+		return nil
+	}
+	c.m.enqueue(mirrorOp{collection: c.Name(), ids: documentIDs(op.Ids)})
+	return nil
+}
+
+func (c mirrorCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	if err := c.Collection.Delete(ctx, opts...); err != nil {
+		return err
+	}
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		c.m.requeue(c.Name(), err.Error())
+		return nil
+	}
+	c.m.enqueue(mirrorOp{collection: c.Name(), del: op})
+	return nil
+}
+
+// copyAdded mirrors added or upserted records by id; records added without
+// ids (generated by Chroma) can only be reconciled.
+func (c mirrorCollection) copyAdded(opts []chroma.CollectionAddOption) {
+	op, err := chroma.NewCollectionAddOp(opts...)
+	if err != nil || len(op.Ids) == 0 {
+		c.m.requeue(c.Name(), "write without ids")
+		return
+	}
+	c.m.enqueue(mirrorOp{collection: c.Name(), ids: documentIDs(op.Ids)})
+}
+
+func documentIDs(ids []chroma.DocumentID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = string(id)
+	}
+	return out
+}
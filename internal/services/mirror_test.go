@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// memMirrorQueue keeps queued collections in memory.
+type memMirrorQueue struct {
+	entries map[string]config.MirrorEntry
+}
+
+func (q *memMirrorQueue) QueueMirror(collection, reason string) error {
+	q.entries[collection] = config.MirrorEntry{Collection: collection, Reason: reason, QueuedAt: time.Now()}
+	return nil
+}
+
+func (q *memMirrorQueue) MirrorQueue() ([]config.MirrorEntry, error) {
+	out := []config.MirrorEntry{}
+	for _, e := range q.entries {
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (q *memMirrorQueue) RecordMirrorAttempt(collection, lastError string) error {
+	e := q.entries[collection]
+	e.Attempts++
+	e.LastError = lastError
+	q.entries[collection] = e
+	return nil
+}
+
+func (q *memMirrorQueue) DequeueMirror(collection string, since time.Time) error {
+	if !q.entries[collection].QueuedAt.After(since) {
+		delete(q.entries, collection)
+	}
+	return nil
+}
+
+// waitMirrored waits for the mirror to apply every write made so far.
+func waitMirrored(t *testing.T, m *Mirror) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.pending.Load() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("writes were not mirrored in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary := &replicaCollection{&fileCollection{records: map[string]Record{}}}
+	secondary := &replicaCollection{&fileCollection{records: map[string]Record{}}}
+	var primaryDown, secondaryDown bool
+	queue := &memMirrorQueue{entries: map[string]config.MirrorEntry{}}
+	m := NewMirror(replicaClient{collection: primary, down: &primaryDown}, replicaClient{collection: secondary, down: &secondaryDown}, queue)
+	go m.Run(ctx, time.Hour)
+
+	col, err := m.Client().GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRecords(ctx, col.Upsert, []Record{{ID: "a", Document: "alpha", Embedding: []float32{1}}, {ID: "b", Document: "beta", Embedding: []float32{2}}}); err != nil {
+		t.Fatal(err)
+	}
+	waitMirrored(t, m)
+	if got := documents(secondary.fileCollection); len(got) != 2 || secondary.reused != 2 {
+		t.Errorf("expected both records mirrored with their embeddings, got %q after %d reused", got, secondary.reused)
+	}
+
+	if err := col.Delete(ctx, chroma.WithIDsDelete("a")); err != nil {
+		t.Fatal(err)
+	}
+	waitMirrored(t, m)
+	if _, ok := secondary.records["a"]; ok || len(secondary.records) != 1 {
+		t.Errorf("expected the delete mirrored, got %v", secondary.records)
+	}
+
+	// A write the secondary misses queues its collection for reconciliation
+	secondaryDown = true
+	if err := writeRecords(ctx, col.Upsert, []Record{{ID: "c", Document: "gamma"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitMirrored(t, m)
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Failed != 1 || status.Mirrored != 2 || len(status.Queue) != 1 || status.Queue[0].Collection != "docs" {
+		t.Fatalf("expected the collection queued, got %+v", status)
+	}
+	if results, err := m.Reconcile(ctx); err != nil || len(results) != 1 || results[0].Error == "" || len(queue.entries) != 1 {
+		t.Errorf("expected a failed reconciliation to stay queued, got %+v, %v", results, err)
+	}
+	secondaryDown = false
+	results, err := m.Reconcile(ctx)
+	if err != nil || len(results) != 1 || results[0].Copied != 1 || results[0].Error != "" || len(queue.entries) != 0 {
+		t.Fatalf("expected the missed record copied and the queue emptied, got %+v, %v", results, err)
+	}
+	if got := documents(secondary.fileCollection); len(got) != 2 || got[1] != "gamma" {
+		t.Errorf("expected the secondary to match the primary, got %q", got)
+	}
+
+	if err := m.Client().DeleteCollection(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	waitMirrored(t, m)
+	if len(secondary.records) != 0 {
+		t.Errorf("expected the collection drop mirrored, got %v", secondary.records)
+	}
+}
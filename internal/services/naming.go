@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// ErrInvalidCollectionName is returned for names that break the naming rules.
+var ErrInvalidCollectionName = errors.New("invalid collection name")
+
+// ErrCollectionNameConflict is returned when creating a collection whose name
+// differs only in case from an existing one.
+var ErrCollectionNameConflict = errors.New("collection name conflicts with an existing collection")
+
+// Collection naming modes.
+const (
+	NamingOff       = "off"       // pass names to Chroma unchanged
+	NamingValidate  = "validate"  // reject names Chroma would mishandle
+	NamingNormalize = "normalize" // rewrite invalid characters, then validate
+)
+
+// Case policies for collection names.
+const (
+	CaseSensitive   = "sensitive"   // "Docs" and "docs" are distinct collections
+	CaseInsensitive = "insensitive" // names resolve to an existing collection ignoring case
+	CaseLower       = "lower"       // names are lowercased
+)
+
+// Collection names are limited to the rules of recent Chroma releases.
+const (
+	minCollectionName = 3
+	maxCollectionName = 512
+)
+
+var (
+	collectionNameRe  = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+	invalidNameCharRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	repeatedDotsRe    = regexp.MustCompile(`\.{2,}`)
+)
+
+// NamePolicy controls how collection names are checked at creation.
+type NamePolicy struct {
+	Mode string `json:"mode"`
+	Case string `json:"case"`
+}
+
+// DefaultNamePolicy validates names and treats case as insignificant.
+var DefaultNamePolicy = NamePolicy{Mode: NamingValidate, Case: CaseInsensitive}
+
+// Validate checks that the policy's mode and case policy are known.
+func (p NamePolicy) Validate() error {
+	switch p.Mode {
+	case NamingOff, NamingValidate, NamingNormalize:
+	default:
+		return fmt.Errorf("unknown collection naming mode %q", p.Mode)
+	}
+	switch p.Case {
+	case CaseSensitive, CaseInsensitive, CaseLower:
+	default:
+		return fmt.Errorf("unknown collection name case policy %q", p.Case)
+	}
+	return nil
+}
+
+// Apply normalizes (if enabled) and validates a name, returning the name to use.
+func (p NamePolicy) Apply(name string) (string, error) {
+	if p.Mode == NamingOff {
+		return name, nil
+	}
+	if p.Mode == NamingNormalize {
+		name = normalizeCollectionName(name)
+	}
+	if p.Case == CaseLower {
+		name = strings.ToLower(name)
+	}
+	if err := validateCollectionName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func normalizeCollectionName(name string) string {
+	name = invalidNameCharRe.ReplaceAllString(strings.TrimSpace(name), "_")
+	name = repeatedDotsRe.ReplaceAllString(name, ".")
+	return strings.Trim(name, "._-")
+}
+
+func validateCollectionName(name string) error {
+	switch n := len(name); {
+	case n < minCollectionName || n > maxCollectionName:
+		return fmt.Errorf("%w %q: must be %d-%d characters", ErrInvalidCollectionName, name, minCollectionName, maxCollectionName)
+	case !collectionNameRe.MatchString(name):
+		return fmt.Errorf("%w %q: use letters, digits, '.', '_' or '-', starting and ending with a letter or digit", ErrInvalidCollectionName, name)
+	case strings.Contains(name, ".."):
+		return fmt.Errorf("%w %q: must not contain \"..\"", ErrInvalidCollectionName, name)
+	case net.ParseIP(name) != nil:
+		return fmt.Errorf("%w %q: must not be an IP address", ErrInvalidCollectionName, name)
+	}
+	return nil
+}
+
+// WithNamePolicy sets the naming rules enforced when collections are created.
+func (s *IngestService) WithNamePolicy(p NamePolicy) *IngestService {
+	s.naming = p
+	return s
+}
+
+// CheckCollectionName reports a name the validating naming policy would
+// reject; other modes accept any name, which they pass on or rewrite.
+func (s *IngestService) CheckCollectionName(name string) error {
+	if s.naming.Mode != NamingValidate {
+		return nil
+	}
+	return validateCollectionName(name)
+}
+
+// resolveCollectionName applies the naming policy and, for case-insensitive
+// policies, returns the name of an existing collection that matches ignoring
+// case.
+func (s *IngestService) resolveCollectionName(ctx context.Context, name string) (string, error) {
+	name, err := s.naming.Apply(name)
+	if err != nil || s.naming.Mode == NamingOff || s.naming.Case != CaseInsensitive {
+		return name, err
+	}
+	collections, err := s.chromaDB.ListCollections(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list collections: %w", err)
+	}
+	names := make([]string, len(collections))
+	for i, c := range collections {
+		names[i] = c.Name()
+	}
+	return matchCollectionName(name, names)
+}
+
+// matchCollectionName prefers an exact match, then a case-insensitive one.
+func matchCollectionName(name string, existing []string) (string, error) {
+	var folded []string
+	for _, e := range existing {
+		if e == name {
+			return name, nil
+		}
+		if strings.EqualFold(e, name) {
+			folded = append(folded, e)
+		}
+	}
+	switch len(folded) {
+	case 0:
+		return name, nil
+	case 1:
+		return folded[0], nil
+	}
+	return "", fmt.Errorf("%w: %q matches %s", ErrCollectionNameConflict, name, strings.Join(folded, ", "))
+}
+
+// getOrCreateCollection opens a collection for writing, creating it under the
+// naming policy when it does not exist yet.
+func (s *IngestService) getOrCreateCollection(ctx context.Context, name string) (chroma.Collection, error) {
+	if IsSnapshotCollection(name) {
+		return nil, ErrSnapshotReadOnly
+	}
+	if err := s.checkNotTrashed(name); err != nil {
+		return nil, err
+	}
+	if s.naming.Mode != NamingOff {
+		if c, err := s.chromaDB.GetCollection(ctx, name); err == nil {
+			return c, nil
+		}
+		resolved, err := s.resolveCollectionName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		name = resolved
+	}
+	return s.createCollection(ctx, name, nil)
+}
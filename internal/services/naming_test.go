@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamePolicyApply(t *testing.T) {
+	cases := []struct {
+		policy  NamePolicy
+		in      string
+		want    string
+		invalid bool
+	}{
+		{DefaultNamePolicy, "docs-v2", "docs-v2", false},
+		{DefaultNamePolicy, "My Docs", "", true},
+		{DefaultNamePolicy, "ab", "", true},
+		{DefaultNamePolicy, "a..b", "", true},
+		{DefaultNamePolicy, "10.0.0.1", "", true},
+		{DefaultNamePolicy, "_docs", "", true},
+		{NamePolicy{Mode: NamingNormalize, Case: CaseLower}, "  My Docs!! ", "my_docs", false},
+		{NamePolicy{Mode: NamingNormalize, Case: CaseSensitive}, "Team..Notes.", "Team.Notes", false},
+		{NamePolicy{Mode: NamingNormalize, Case: CaseSensitive}, "!!", "", true},
+		{NamePolicy{Mode: NamingOff, Case: CaseLower}, "Any Name", "Any Name", false},
+	}
+	for _, tc := range cases {
+		got, err := tc.policy.Apply(tc.in)
+		if tc.invalid {
+			if !errors.Is(err, ErrInvalidCollectionName) {
+				t.Errorf("%+v %q: expected invalid name error, got %q, %v", tc.policy, tc.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%+v %q: got %q, %v; want %q", tc.policy, tc.in, got, err, tc.want)
+		}
+	}
+	if err := (NamePolicy{Mode: "strict", Case: CaseLower}).Validate(); err == nil {
+		t.Error("expected unknown mode to be rejected")
+	}
+}
+
+func TestMatchCollectionName(t *testing.T) {
+	existing := []string{"docs", "Notes", "notes", "Wiki"}
+	if got, err := matchCollectionName("Docs", existing); err != nil || got != "docs" {
+		t.Errorf("expected case-insensitive match to docs, got %q, %v", got, err)
+	}
+	if got, err := matchCollectionName("notes", existing); err != nil || got != "notes" {
+		t.Errorf("expected exact match to win, got %q, %v", got, err)
+	}
+	if _, err := matchCollectionName("NOTES", existing); !errors.Is(err, ErrCollectionNameConflict) {
+		t.Errorf("expected ambiguity conflict, got %v", err)
+	}
+	if got, err := matchCollectionName("fresh", existing); err != nil || got != "fresh" {
+		t.Errorf("expected new name to pass through, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a fetch of a user-supplied URL would
+// connect to a loopback, private or link-local address.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate leaves out.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is routable on the internet, i.e. not
+// loopback, RFC 1918/4193 private, link-local (which includes cloud
+// metadata endpoints such as 169.254.169.254), unspecified or multicast.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// checkPublicHost refuses a URL whose host is an IP literal or localhost
+// name that isn't public, so a bad spec fails when it is submitted rather
+// than when it is fetched.
+func checkPublicHost(u *url.URL) error {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, u.Hostname())
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, u.Hostname())
+	}
+	return nil
+}
+
+// newFetchClient returns an HTTP client for fetching user-supplied URLs.
+// With allowed set, every connection, including those made to follow
+// redirects, is checked after DNS resolution and refused unless allowed
+// accepts the address. Proxies are not used then, since they would make
+// the connection on the client's behalf. A nil allowed permits any address.
+func newFetchClient(timeout time.Duration, allowed func(net.IP) bool) *http.Client {
+	if allowed == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// fetchGuard returns the address check for fetch clients: isPublicIP, or
+// nil when private networks are allowed.
+func fetchGuard(allowPrivate bool) func(net.IP) bool {
+	if allowPrivate {
+		return nil
+	}
+	return isPublicIP
+}
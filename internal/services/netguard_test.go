@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := isPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	for raw, public := range map[string]bool{
+		"https://example.com/":             true,
+		"http://93.184.216.34/":            true,
+		"http://127.0.0.1:8080/":           false,
+		"http://[::1]/":                    false,
+		"http://LOCALHOST./":               false,
+		"http://app.localhost/":            false,
+		"http://169.254.169.254/latest/":   false,
+		"http://192.168.0.10:9000/archive": false,
+	} {
+		u, _ := url.Parse(raw)
+		if err := checkPublicHost(u); (err == nil) != public {
+			t.Errorf("checkPublicHost(%s) = %v", raw, err)
+		}
+	}
+}
+
+func TestFetchClientRefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %v", err)
+	}
+	internal.Listener = ln
+	internal.Start()
+	defer internal.Close()
+	front := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer front.Close()
+
+	if _, err := newFetchClient(time.Second, isPublicIP).Get(front.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected a loopback address refused, got %v", err)
+	}
+
+	// Only the front server counts as public here, so the redirect is
+	// refused when its target is dialed.
+	front127 := func(ip net.IP) bool { return ip.Equal(net.IPv4(127, 0, 0, 1)) }
+	if _, err := newFetchClient(time.Second, front127).Get(front.URL); !errors.Is(err, ErrPrivateAddress) || hit {
+		t.Errorf("expected the redirect to a private address refused, got %v (reached: %v)", err, hit)
+	}
+	if resp, err := newFetchClient(time.Second, nil).Get(front.URL); err != nil || !hit {
+		t.Errorf("expected an unguarded client to follow the redirect, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
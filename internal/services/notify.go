@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Notification events.
+const (
+	EventJobFailed    = "job_failed"    // data: PipelineRun
+	EventQuotaAlert   = "quota_alert"   // data: QuotaAlert
+	EventBackupResult = "backup_result" // data: BackupResult
+	EventHealthReport = "health_report" // data: HealthReport
+)
+
+// Notifier delivers operational events to humans.
+type Notifier interface {
+	Notify(ctx context.Context, event string, data any) error
+}
+
+// notify sends an event in the background, logging delivery failures.
+func notify(n Notifier, event string, data any) {
+	if n == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := n.Notify(ctx, event, data); err != nil {
+			logging.GetLogger().WithError(err).WithField("event", event).Error("Failed to send notification")
+		}
+	}()
+}
+
+// BackupResult describes the outcome of archiving or restoring a collection.
+type BackupResult struct {
+	Operation  string `json:"operation"` // "archive" or "restore"
+	Collection string `json:"collection"`
+	Records    int    `json:"records"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SMTPConfig configures the SMTP notifier.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Events limits which events are mailed; empty means all.
+	Events []string
+}
+
+// TemplateSource looks up stored message templates by key; an empty value
+// selects the built-in template.
+type TemplateSource interface {
+	Get(key string) (string, error)
+}
+
+// messageTemplate is a subject and body rendered with text/template.
+type messageTemplate struct{ subject, body string }
+
+var defaultTemplates = map[string]messageTemplate{
+	EventJobFailed: {
+		subject: `[forge] Pipeline {{.Pipeline}} failed`,
+		body: `Pipeline {{.Pipeline}} started at {{.StartedAt.Format "2006-01-02 15:04:05 MST"}} finished with {{len .Errors}} error(s) in {{.Duration}}:
+{{range .Errors}}
+- {{.}}{{end}}
+`,
+	},
+	EventQuotaAlert: {
+		subject: `[forge] API key {{.KeyName}} {{.Level}} its {{.Metric}} limit`,
+		body: `API key {{.KeyName}} ({{.KeyID}}) has used {{.Used}} of {{.Limit}} {{.Metric}} on {{.Day}} ({{.Level}}).
+`,
+	},
+	EventBackupResult: {
+		subject: `[forge] {{.Operation}} of {{.Collection}} {{if .Error}}failed{{else}}succeeded{{end}}`,
+		body: `{{if .Error}}The {{.Operation}} of collection {{.Collection}} failed: {{.Error}}{{else}}The {{.Operation}} of collection {{.Collection}} completed ({{.Records}} records).{{end}}
+`,
+	},
+	EventHealthReport: {
+		subject: `[forge] Health report {{.ID}}: {{len .Collections}} collection(s)`,
+		body: `Collection health since {{.Since}}:
+{{range .Collections}}
+- {{.Collection}}: {{if .Error}}error: {{.Error}}{{else}}{{.Chunks}} chunks{{if .Growth}} ({{.Growth}} since the last report){{end}}, {{.Searches}} searches, {{printf "%.2f" .ZeroResultRate}} zero-result rate, {{printf "%.2f" .DuplicateRatio}} duplicate ratio, {{.FailedIngests}} failed ingests{{end}}{{end}}
+`,
+	},
+}
+
+// SMTPNotifier mails events using templates that can be overridden in the
+// config store with smtp_subject_<event> and smtp_body_<event>.
+type SMTPNotifier struct {
+	cfg       SMTPConfig
+	templates TemplateSource
+	send      func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewSMTPNotifier(cfg SMTPConfig, templates TemplateSource) (*SMTPNotifier, error) {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("smtp notifier requires host, from and at least one recipient")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	for _, e := range cfg.Events {
+		if _, ok := defaultTemplates[e]; !ok {
+			return nil, fmt.Errorf("unknown notification event %q", e)
+		}
+	}
+	return &SMTPNotifier{cfg: cfg, templates: templates, send: smtp.SendMail}, nil
+}
+
+// Notify renders and mails an event. Events excluded by configuration are ignored.
+func (n *SMTPNotifier) Notify(ctx context.Context, event string, data any) error {
+	if len(n.cfg.Events) > 0 && !slices.Contains(n.cfg.Events, event) {
+		return nil
+	}
+	subject, body, err := n.render(event, data)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	msg := buildMessage(n.cfg.From, n.cfg.To, subject, body)
+	// net/smtp takes no context; run the send so cancellation is honored.
+	done := make(chan error, 1)
+	go func() { done <- n.send(addr, auth, n.cfg.From, n.cfg.To, msg) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *SMTPNotifier) render(event string, data any) (subject, body string, err error) {
+	tmpl, ok := defaultTemplates[event]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification event %q", event)
+	}
+	if n.templates != nil {
+		if v, err := n.templates.Get("smtp_subject_" + event); err == nil && v != "" {
+			tmpl.subject = v
+		}
+		if v, err := n.templates.Get("smtp_body_" + event); err == nil && v != "" {
+			tmpl.body = v
+		}
+	}
+	if subject, err = execTemplate(event+" subject", tmpl.subject, data); err != nil {
+		return "", "", err
+	}
+	if body, err = execTemplate(event+" body", tmpl.body, data); err != nil {
+		return "", "", err
+	}
+	// Header injection guard: subjects must stay on one line
+	subject = strings.Join(strings.Fields(subject), " ")
+	return subject, body, nil
+}
+
+func execTemplate(name, text string, data any) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func buildMessage(from string, to []string, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
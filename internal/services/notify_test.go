@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+type mapTemplates map[string]string
+
+func (m mapTemplates) Get(key string) (string, error) { return m[key], nil }
+
+func TestSMTPNotifierRendersTemplates(t *testing.T) {
+	n, err := NewSMTPNotifier(SMTPConfig{Host: "mail.local", From: "forge@local", To: []string{"ops@local"}},
+		mapTemplates{"smtp_subject_" + EventQuotaAlert: "quota {{.Metric}}\r\nBcc: evil@local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addr string
+	var msg []byte
+	n.send = func(a string, _ smtp.Auth, _ string, _ []string, m []byte) error {
+		addr, msg = a, m
+		return nil
+	}
+	alert := QuotaAlert{KeyID: "k1", KeyName: "ci", Metric: "searches", Level: AlertExceeded, Used: 10, Limit: 10}
+	if err := n.Notify(context.Background(), EventQuotaAlert, alert); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "mail.local:587" {
+		t.Errorf("unexpected address %q", addr)
+	}
+	text := string(msg)
+	if !strings.Contains(text, "Subject: quota searches Bcc: evil@local\r\n") {
+		t.Errorf("subject override not applied on one line:\n%s", text)
+	}
+	if !strings.Contains(text, "has used 10 of 10 searches") {
+		t.Errorf("default body not rendered:\n%s", text)
+	}
+}
+
+func TestSMTPNotifierEventFilter(t *testing.T) {
+	if _, err := NewSMTPNotifier(SMTPConfig{Host: "h", From: "f", To: []string{"t"}, Events: []string{"nope"}}, nil); err == nil {
+		t.Fatal("expected error for unknown event")
+	}
+	n, err := NewSMTPNotifier(SMTPConfig{Host: "h", From: "f", To: []string{"t"}, Events: []string{EventJobFailed}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := false
+	n.send = func(string, smtp.Auth, string, []string, []byte) error { sent = true; return nil }
+	if err := n.Notify(context.Background(), EventBackupResult, BackupResult{Operation: "archive"}); err != nil || sent {
+		t.Fatalf("filtered event was sent (err=%v)", err)
+	}
+	if err := n.Notify(context.Background(), EventJobFailed, PipelineRun{Pipeline: "p", Errors: []string{"boom"}}); err != nil || !sent {
+		t.Fatalf("expected job failure to be sent (err=%v)", err)
+	}
+}
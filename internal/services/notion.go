@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidNotionExport is returned for an upload that is not a Notion
+// markdown/CSV export.
+var ErrInvalidNotionExport = errors.New("not a Notion export")
+
+// Notion names exported pages and databases "<title> <32 hex id>".
+var notionIDRe = regexp.MustCompile(`^(.*?)\s*([0-9a-f]{32})$`)
+
+// propertyKeyRe matches the characters replaced in property metadata keys.
+var propertyKeyRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// NotionImport reports the outcome of importing a Notion export.
+type NotionImport struct {
+	Pages     int `json:"pages"`
+	Databases int `json:"databases"`
+	Rows      int `json:"rows"`
+	// Attachments are the images and other files of the export, which are
+	// not ingested.
+	Attachments int            `json:"attachments"`
+	Ingested    int            `json:"ingested"`
+	Skipped     int            `json:"skipped"`
+	Failed      int            `json:"failed"`
+	Chunks      int            `json:"chunks"`
+	Results     []IngestResult `json:"results"`
+}
+
+// notionNode is a page or database named by its place in the export.
+type notionNode struct {
+	titles []string // from the top-level page down to this one
+	ids    []string // Notion IDs, "" where a folder had none
+}
+
+func (n notionNode) title() string { return n.titles[len(n.titles)-1] }
+func (n notionNode) id() string    { return n.ids[len(n.ids)-1] }
+func (n notionNode) name() string  { return strings.Join(n.titles, "/") }
+
+// metadata describes the node's place in the page hierarchy.
+func (n notionNode) metadata() map[string]interface{} {
+	md := map[string]interface{}{
+		"notion_title": n.title(),
+		"notion_path":  strings.Join(n.titles, " / "),
+		"notion_depth": len(n.titles) - 1,
+	}
+	if id := n.id(); id != "" {
+		md["notion_id"] = id
+	}
+	if len(n.titles) > 1 {
+		md["notion_parent"] = n.titles[len(n.titles)-2]
+		if id := n.ids[len(n.ids)-2]; id != "" {
+			md["notion_parent_id"] = id
+		}
+	}
+	return md
+}
+
+// parseNotionPath splits an exported member path into a node, dropping the
+// archives it was nested in and the file extension.
+func parseNotionPath(name string) notionNode {
+	var n notionNode
+	segments := strings.Split(name, "/")
+	for i, seg := range segments {
+		if isExpandable(seg) {
+			continue
+		}
+		if i == len(segments)-1 {
+			seg = strings.TrimSuffix(seg, path.Ext(seg))
+		}
+		title, id := seg, ""
+		if m := notionIDRe.FindStringSubmatch(seg); m != nil && m[1] != "" {
+			title, id = m[1], m[2]
+		}
+		n.titles = append(n.titles, title)
+		n.ids = append(n.ids, id)
+	}
+	return n
+}
+
+// IngestNotionExport imports a Notion workspace export (a zip of markdown
+// pages and CSV databases). Pages keep their hierarchy as notion_* metadata;
+// each database row becomes a document of its properties, merged with the
+// row's page when the export has one, with the properties as prop_* metadata.
+func (s *IngestService) IngestNotionExport(ctx context.Context, collection, filename string, content []byte, opts IngestOptions) (*NotionImport, error) {
+	if !strings.HasSuffix(strings.ToLower(filename), ".zip") {
+		return nil, fmt.Errorf("%w: expected a .zip export", ErrInvalidNotionExport)
+	}
+	exp := &expansion{limits: s.expand}
+	if err := exp.expand(filename, content, 1); err != nil {
+		return nil, err
+	}
+
+	out := &NotionImport{}
+	pages := make(map[string]expandedFile) // by member path without ".md"
+	var databases []expandedFile
+	for _, f := range exp.files {
+		switch strings.ToLower(path.Ext(f.name)) {
+		case ".md":
+			pages[strings.TrimSuffix(f.name, path.Ext(f.name))] = f
+		case ".csv":
+			databases = append(databases, f)
+		default:
+			out.Attachments++
+		}
+	}
+	databases = preferFullDatabases(databases)
+	if len(pages) == 0 && len(databases) == 0 {
+		return nil, fmt.Errorf("%w: no markdown pages or CSV databases", ErrInvalidNotionExport)
+	}
+	if opts.Source.ID == "" {
+		sum := sha256.Sum256(content)
+		opts.Source = IngestSource{ID: "notion-" + hex.EncodeToString(sum[:8]), Kind: SourceNotion, Ref: filename}
+	}
+
+	type document struct {
+		name     string
+		content  []byte
+		metadata map[string]interface{}
+	}
+	var docs []document
+	for _, db := range databases {
+		base := strings.TrimSuffix(strings.TrimSuffix(db.name, ".csv"), "_all")
+		dbNode := parseNotionPath(base)
+		rows, err := notionRows(db.content)
+		if err != nil {
+			out.Failed++
+			out.Results = append(out.Results, IngestResult{Status: "error", File: dbNode.name(), Error: err.Error()})
+			continue
+		}
+		out.Databases++
+		// Row pages are exported next to the CSV, in a folder named like it
+		rowPages := make(map[string]string)
+		for key := range pages {
+			if rest, ok := strings.CutPrefix(key, base+"/"); ok && !strings.Contains(rest, "/") {
+				rowPages[parseNotionPath(key).title()] = key
+			}
+		}
+		for _, row := range rows {
+			out.Rows++
+			rowNode := notionNode{titles: append(slices.Clone(dbNode.titles), row.title), ids: append(slices.Clone(dbNode.ids), "")}
+			text := row.text()
+			if key, ok := rowPages[row.title]; ok {
+				rowNode.ids[len(rowNode.ids)-1] = parseNotionPath(key).id()
+				text = string(pages[key].content)
+				delete(pages, key)
+				delete(rowPages, row.title)
+			}
+			md := rowNode.metadata()
+			md["notion_database"] = dbNode.title()
+			if id := dbNode.id(); id != "" {
+				md["notion_database_id"] = id
+			}
+			for _, p := range row.props {
+				if p.value != "" {
+					md["prop_"+p.key] = p.value
+				}
+			}
+			docs = append(docs, document{name: rowNode.name(), content: []byte(text), metadata: md})
+		}
+	}
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		n := parseNotionPath(key)
+		out.Pages++
+		docs = append(docs, document{name: n.name(), content: pages[key].content, metadata: n.metadata()})
+	}
+
+	used := make(map[string]bool)
+	for _, d := range docs {
+		name := d.name + ".md"
+		// Notion allows siblings with the same title
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s (%d).md", d.name, n)
+		}
+		used[name] = true
+		docOpts := opts
+		docOpts.Metadata = make(map[string]interface{}, len(opts.Metadata)+len(d.metadata))
+		for k, v := range d.metadata {
+			docOpts.Metadata[k] = v
+		}
+		for k, v := range opts.Metadata {
+			docOpts.Metadata[k] = v
+		}
+		r := s.ingestResult(ctx, collection, name, d.content, docOpts)
+		switch r.Status {
+		case "ingested":
+			out.Ingested++
+			out.Chunks += r.Chunks
+		case "skipped":
+			out.Skipped++
+		default:
+			out.Failed++
+		}
+		out.Results = append(out.Results, r)
+	}
+	return out, nil
+}
+
+// preferFullDatabases drops a database's view CSV when the export also has
+// its "_all.csv", which includes every row.
+func preferFullDatabases(files []expandedFile) []expandedFile {
+	full := make(map[string]bool)
+	for _, f := range files {
+		if base, ok := strings.CutSuffix(f.name, "_all.csv"); ok {
+			full[base] = true
+		}
+	}
+	out := files[:0]
+	for _, f := range files {
+		if !strings.HasSuffix(f.name, "_all.csv") && full[strings.TrimSuffix(f.name, ".csv")] {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+type notionProperty struct {
+	name, key, value string
+}
+
+// notionRow is a database row: its title (the first column) and properties.
+type notionRow struct {
+	title string
+	props []notionProperty
+}
+
+// text lays the row out as a page: its title as a heading, then its
+// properties one per line.
+func (r notionRow) text() string {
+	var b strings.Builder
+	b.WriteString("# " + r.title + "\n")
+	for _, p := range r.props {
+		if p.value != "" {
+			fmt.Fprintf(&b, "\n%s: %s", p.name, p.value)
+		}
+	}
+	return b.String()
+}
+
+// notionRows parses a database CSV.
+func notionRows(content []byte) ([]notionRow, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse database: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	keys := make([]string, len(header))
+	for i, h := range header {
+		keys[i] = strings.Trim(propertyKeyRe.ReplaceAllString(strings.ToLower(h), "_"), "_")
+	}
+	var rows []notionRow
+	for _, rec := range records[1:] {
+		if len(rec) == 0 || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		row := notionRow{title: strings.TrimSpace(rec[0])}
+		for i := 1; i < len(rec) && i < len(header); i++ {
+			if keys[i] == "" {
+				continue
+			}
+			row.props = append(row.props, notionProperty{name: header[i], key: keys[i], value: strings.TrimSpace(rec[i])})
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func notionZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIngestNotionExport(t *testing.T) {
+	const (
+		wiki    = "0123456789abcdef0123456789abcdef"
+		setup   = "11111111111111111111111111111111"
+		tasks   = "22222222222222222222222222222222"
+		laptop  = "33333333333333333333333333333333"
+		unnamed = "44444444444444444444444444444444"
+	)
+	export := notionZip(t, map[string]string{
+		"Wiki " + wiki + ".md":                                                 "# Wiki\n\nWelcome.",
+		"Wiki " + wiki + "/Setup " + setup + ".md":                             "# Setup\n\nInstall tools.",
+		"Wiki " + wiki + "/Setup " + setup + "/diagram.png":                    "png",
+		"Wiki " + wiki + "/Tasks " + tasks + ".csv":                            "Name,Status,Due date\nOld view,Done,\n",
+		"Wiki " + wiki + "/Tasks " + tasks + "_all.csv":                        "\ufeffName,Status,Due date\nOrder laptop,Done,2026-03-01\nWrite docs,In progress,\n",
+		"Wiki " + wiki + "/Tasks " + tasks + "/Order laptop " + laptop + ".md": "# Order laptop\n\nStatus: Done\n\nAsk IT.",
+		"Untitled " + unnamed + ".md":                                          "# Untitled\n\nNotes.",
+	})
+	col := &writeCollection{files: map[string]int{}, metadata: map[string]chroma.DocumentMetadata{}}
+	s := NewIngestService(writeClient{collection: col})
+
+	if _, err := s.IngestNotionExport(context.Background(), "docs", "export.zip", notionZip(t, map[string]string{"a.png": "png"}), IngestOptions{}); !errors.Is(err, ErrInvalidNotionExport) {
+		t.Errorf("expected ErrInvalidNotionExport without pages, got %v", err)
+	}
+
+	out, err := s.IngestNotionExport(context.Background(), "docs", "export.zip", export, IngestOptions{Metadata: map[string]interface{}{"team": "eng"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Pages != 3 || out.Databases != 1 || out.Rows != 2 || out.Attachments != 1 || out.Ingested != 5 || out.Failed != 0 {
+		t.Errorf("unexpected import %+v", out)
+	}
+	var names []string
+	for name := range col.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "Untitled.md,Wiki.md,Wiki/Setup.md,Wiki/Tasks/Order laptop.md,Wiki/Tasks/Write docs.md" {
+		t.Errorf("unexpected documents %s", got)
+	}
+
+	get := func(file, key string) string {
+		t.Helper()
+		v, _ := col.metadata[file].GetString(userMetadataPrefix + key)
+		return v
+	}
+	if get("Wiki/Setup.md", "notion_path") != "Wiki / Setup" || get("Wiki/Setup.md", "notion_parent_id") != wiki || get("Wiki/Setup.md", "notion_id") != setup || get("Wiki/Setup.md", "team") != "eng" {
+		t.Errorf("unexpected page metadata %v", col.metadata["Wiki/Setup.md"])
+	}
+	row := "Wiki/Tasks/Order laptop.md"
+	if get(row, "notion_database") != "Tasks" || get(row, "notion_database_id") != tasks || get(row, "prop_due_date") != "2026-03-01" || get(row, "notion_id") != laptop {
+		t.Errorf("unexpected row metadata %v", col.metadata[row])
+	}
+	if get("Wiki/Tasks/Write docs.md", "prop_status") != "In progress" {
+		t.Errorf("unexpected row metadata %v", col.metadata["Wiki/Tasks/Write docs.md"])
+	}
+}
+
+func TestNotionRows(t *testing.T) {
+	rows, err := notionRows([]byte("Name,Status,\"Owner (team)\"\nShip,Done,Ana\n,Skipped,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].props[1].key != "owner_team" {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	if got := rows[0].text(); got != "# Ship\n\nStatus: Done\nOwner (team): Ana" {
+		t.Errorf("unexpected row text %q", got)
+	}
+}
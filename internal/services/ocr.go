@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata attached to chunks of OCR-recognized text.
+const (
+	ocrKey           = "ocr"            // true when the text came from OCR
+	ocrConfidenceKey = "ocr_confidence" // mean recognition confidence, 0-1
+)
+
+// OCR backends selectable through the ocr_backend config value.
+const (
+	OCRTesseract = "tesseract"
+	OCRHTTP      = "http"
+)
+
+// ErrOCRUnavailable is returned for images and PDFs without a text layer
+// when no OCR backend is configured.
+var ErrOCRUnavailable = errors.New("file has no text layer and OCR is not configured")
+
+// imageExtensions are ingested through OCR only.
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true,
+	".bmp": true, ".gif": true, ".webp": true,
+}
+
+// OCRResult is recognized text and its confidence (0-1).
+type OCRResult struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+}
+
+// OCR recognizes text in an image or a scanned PDF.
+type OCR interface {
+	Recognize(ctx context.Context, filename string, content []byte) (OCRResult, error)
+}
+
+// OCRConfig selects and configures an OCR backend.
+type OCRConfig struct {
+	Backend string // "", OCRTesseract or OCRHTTP
+	// URL of an OCR service (OCRHTTP).
+	URL string
+	// Languages passed to tesseract, e.g. "eng+deu".
+	Languages string
+	// TesseractPath and PdftoppmPath locate the binaries; pdftoppm renders
+	// PDF pages to images for tesseract.
+	TesseractPath string
+	PdftoppmPath  string
+}
+
+// NewOCR builds the configured backend, or returns nil when Backend is empty.
+func NewOCR(cfg OCRConfig) (OCR, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case OCRTesseract:
+		t := &TesseractOCR{Path: cfg.TesseractPath, PdftoppmPath: cfg.PdftoppmPath, Languages: cfg.Languages}
+		if t.Path == "" {
+			t.Path = "tesseract"
+		}
+		if t.PdftoppmPath == "" {
+			t.PdftoppmPath = "pdftoppm"
+		}
+		if _, err := exec.LookPath(t.Path); err != nil {
+			return nil, fmt.Errorf("tesseract not found: %w", err)
+		}
+		return t, nil
+	case OCRHTTP:
+		if cfg.URL == "" {
+			return nil, errors.New("http OCR backend requires ocr_url")
+		}
+		return &HTTPOCR{URL: cfg.URL, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+	default:
+		return nil, fmt.Errorf("unknown OCR backend %q", cfg.Backend)
+	}
+}
+
+// WithOCR enables OCR for images and PDFs without a text layer.
+func (s *IngestService) WithOCR(ocr OCR) *IngestService {
+	s.ocr = ocr
+	return s
+}
+
+func (s *IngestService) recognize(ctx context.Context, filePath string, content []byte) ([]docSection, error) {
+	if s.ocr == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOCRUnavailable, filePath)
+	}
+	res, err := s.ocr.Recognize(ctx, filePath, content)
+	if err != nil {
+		return nil, fmt.Errorf("ocr %s: %w", filePath, err)
+	}
+	if strings.TrimSpace(res.Text) == "" {
+		return nil, nil
+	}
+	return []docSection{{text: res.Text, metadata: map[string]interface{}{
+		ocrKey:           true,
+		ocrConfidenceKey: res.Confidence,
+	}}}, nil
+}
+
+// HTTPOCR posts the file to an OCR service, which must answer with JSON
+// {"text": "...", "confidence": 0.93}.
+type HTTPOCR struct {
+	URL    string
+	client *http.Client
+}
+
+func (o *HTTPOCR) Recognize(ctx context.Context, filename string, content []byte) (OCRResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(content))
+	if err != nil {
+		return OCRResult{}, err
+	}
+	contentType := mime.TypeByExtension(path.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Filename", path.Base(filename))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return OCRResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return OCRResult{}, fmt.Errorf("ocr service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var res OCRResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return OCRResult{}, fmt.Errorf("decode ocr response: %w", err)
+	}
+	return res, nil
+}
+
+// TesseractOCR runs the tesseract CLI, rendering PDF pages with pdftoppm
+// first.
+type TesseractOCR struct {
+	Path         string
+	PdftoppmPath string
+	Languages    string
+}
+
+func (o *TesseractOCR) Recognize(ctx context.Context, filename string, content []byte) (OCRResult, error) {
+	if strings.ToLower(path.Ext(filename)) != ".pdf" {
+		return o.recognizeImage(ctx, content)
+	}
+	pages, cleanup, err := o.renderPDF(ctx, content)
+	if err != nil {
+		return OCRResult{}, err
+	}
+	defer cleanup()
+	var texts []string
+	var confidence float64
+	for _, page := range pages {
+		img, err := os.ReadFile(page)
+		if err != nil {
+			return OCRResult{}, err
+		}
+		res, err := o.recognizeImage(ctx, img)
+		if err != nil {
+			return OCRResult{}, err
+		}
+		texts = append(texts, res.Text)
+		confidence += res.Confidence
+	}
+	if len(pages) > 0 {
+		confidence /= float64(len(pages))
+	}
+	return OCRResult{Text: strings.Join(texts, "\n\n"), Confidence: confidence}, nil
+}
+
+// renderPDF writes one PNG per page into a temporary directory, returned in
+// page order.
+func (o *TesseractOCR) renderPDF(ctx context.Context, content []byte) ([]string, func(), error) {
+	dir, err := os.MkdirTemp("", "forge-ocr-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	src := filepath.Join(dir, "in.pdf")
+	if err := os.WriteFile(src, content, 0o600); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	cmd := exec.CommandContext(ctx, o.PdftoppmPath, "-r", "300", "-png", src, filepath.Join(dir, "page"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("render pdf pages: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	sort.Strings(pages) // pdftoppm zero-pads page numbers
+	return pages, cleanup, nil
+}
+
+func (o *TesseractOCR) recognizeImage(ctx context.Context, img []byte) (OCRResult, error) {
+	args := []string{"stdin", "stdout"}
+	if o.Languages != "" {
+		args = append(args, "-l", o.Languages)
+	}
+	args = append(args, "tsv")
+	cmd := exec.CommandContext(ctx, o.Path, args...)
+	cmd.Stdin = bytes.NewReader(img)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return OCRResult{}, fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTesseractTSV(out), nil
+}
+
+// parseTesseractTSV rebuilds text from tesseract's word-level TSV output,
+// one line per recognized line and a blank line between paragraphs, and
+// averages the word confidences.
+func parseTesseractTSV(tsv []byte) OCRResult {
+	var b strings.Builder
+	var line []string
+	var lineKey, parKey string
+	var confSum float64
+	words := 0
+	flush := func() {
+		if len(line) > 0 {
+			b.WriteString(strings.Join(line, " "))
+			b.WriteString("\n")
+			line = line[:0]
+		}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(tsv))
+	for sc.Scan() {
+		// level page block par line word left top width height conf text
+		f := strings.Split(sc.Text(), "\t")
+		if len(f) < 12 || f[0] != "5" {
+			continue
+		}
+		conf, err := strconv.ParseFloat(f[10], 64)
+		text := strings.TrimSpace(f[11])
+		if err != nil || conf < 0 || text == "" {
+			continue
+		}
+		if key := strings.Join(f[1:4], "."); key != parKey {
+			flush()
+			if parKey != "" {
+				b.WriteString("\n")
+			}
+			parKey = key
+		}
+		if key := strings.Join(f[1:5], "."); key != lineKey {
+			flush()
+			lineKey = key
+		}
+		line = append(line, text)
+		confSum += conf
+		words++
+	}
+	flush()
+	res := OCRResult{Text: strings.TrimSpace(b.String())}
+	if words > 0 {
+		res.Confidence = confSum / float64(words) / 100
+	}
+	return res
+}
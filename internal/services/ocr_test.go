@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeOCR struct {
+	calls int
+}
+
+func (f *fakeOCR) Recognize(ctx context.Context, filename string, content []byte) (OCRResult, error) {
+	f.calls++
+	return OCRResult{Text: "recognized " + filename, Confidence: 0.9}, nil
+}
+
+func TestExtractFallsBackToOCR(t *testing.T) {
+	ocr := &fakeOCR{}
+	s := NewIngestService(nil).WithOCR(ocr)
+	ctx := context.Background()
+
+	for _, name := range []string{"diagram.PNG", "scan.pdf"} {
+		content := []byte("binary")
+		if name == "scan.pdf" {
+			content = buildPdf(t, `q /Im0 Do Q`)
+		}
+		sections, err := s.extract(ctx, "docs", name, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sections) != 1 || sections[0].text != "recognized "+name ||
+			sections[0].metadata[ocrKey] != true || sections[0].metadata[ocrConfidenceKey] != 0.9 {
+			t.Errorf("%s: unexpected sections %+v", name, sections)
+		}
+	}
+
+	text := buildPdf(t, `BT (This PDF has a real text layer) Tj ET`)
+	if _, err := s.extract(ctx, "docs", "text.pdf", text); err != nil || ocr.calls != 2 {
+		t.Errorf("text-layer PDF should not be OCRed: calls=%d err=%v", ocr.calls, err)
+	}
+
+	if _, err := NewIngestService(nil).extract(ctx, "docs", "diagram.png", []byte("binary")); !errors.Is(err, ErrOCRUnavailable) {
+		t.Errorf("expected ErrOCRUnavailable, got %v", err)
+	}
+}
+
+func TestParseTesseractTSV(t *testing.T) {
+	tsv := "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+		"1\t1\t0\t0\t0\t0\t0\t0\t100\t100\t-1\t\n" +
+		"5\t1\t1\t1\t1\t1\t0\t0\t10\t10\t96\tHello\n" +
+		"5\t1\t1\t1\t1\t2\t0\t0\t10\t10\t90\tworld\n" +
+		"5\t1\t1\t1\t2\t1\t0\t0\t10\t10\t84\tagain\n" +
+		"5\t1\t2\t1\t1\t1\t0\t0\t10\t10\t70\tNext\n"
+	res := parseTesseractTSV([]byte(tsv))
+	if res.Text != "Hello world\nagain\n\nNext" {
+		t.Errorf("unexpected text %q", res.Text)
+	}
+	if res.Confidence != 0.85 {
+		t.Errorf("unexpected confidence %v", res.Confidence)
+	}
+}
+
+func TestHTTPOCR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "image/png" || r.Header.Get("X-Filename") != "shot.png" || string(body) != "img" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"text":"Login failed","confidence":0.75}`))
+	}))
+	defer srv.Close()
+
+	ocr, err := NewOCR(OCRConfig{Backend: OCRHTTP, URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ocr.Recognize(context.Background(), "uploads/shot.png", []byte("img"))
+	if err != nil || res.Text != "Login failed" || res.Confidence != 0.75 {
+		t.Errorf("unexpected result %+v (%v)", res, err)
+	}
+}
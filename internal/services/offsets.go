@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"unicode"
+)
+
+// Metadata locating a chunk in the original file, for highlighting
+// citations. Bytes are 0-based with an exclusive end; lines are 1-based
+// and inclusive. Unlike a code declaration's start_line and end_line,
+// these bound the chunk itself.
+const (
+	startByteKey      = "start_byte"
+	endByteKey        = "end_byte"
+	chunkStartLineKey = "chunk_start_line"
+	chunkEndLineKey   = "chunk_end_line"
+)
+
+// offsetSearchWindow bounds how far past the previous chunk the next one is
+// looked for, so text that isn't in the file (e.g. extracted from a PDF)
+// costs little to rule out.
+const offsetSearchWindow = 64 << 10
+
+// chunkSpan is where a chunk's text was found in the original file.
+type chunkSpan struct {
+	start, end         int
+	startLine, endLine int
+}
+
+// chunkSpans locates each chunk in content, the file's original bytes, in
+// order: a chunk is looked for from where the previous one found ended,
+// less the text it repeats from it. A chunk found only without its
+// trailing whitespace, which line packing may add, spans the rest. Chunks
+// whose text doesn't appear verbatim, e.g. after decoding from another
+// charset or transforming, get no span, so recorded offsets are exact.
+func chunkSpans(content []byte, chunks []string, overlaps []int) []*chunkSpan {
+	spans := make([]*chunkSpan, len(chunks))
+	cursor, line := 0, 1 // line is the line number at cursor
+	for i, chunk := range chunks {
+		if chunk == "" {
+			continue
+		}
+		from := max(cursor-overlaps[i], 0)
+		window := content[from:min(len(content), cursor+len(chunk)+offsetSearchWindow)]
+		text := []byte(chunk)
+		pos := bytes.Index(window, text)
+		if pos < 0 {
+			text = bytes.TrimRightFunc(text, unicode.IsSpace)
+			if len(text) == 0 {
+				continue
+			}
+			if pos = bytes.Index(window, text); pos < 0 {
+				continue
+			}
+		}
+		start := from + pos
+		end := start + len(text)
+		// Count lines between the cursor and the chunk, which may start
+		// before the cursor when it overlaps the previous chunk
+		startLine := line
+		if start >= cursor {
+			startLine += bytes.Count(content[cursor:start], []byte("\n"))
+		} else {
+			startLine -= bytes.Count(content[start:cursor], []byte("\n"))
+		}
+		endLine := startLine + bytes.Count(content[start:end-1], []byte("\n"))
+		spans[i] = &chunkSpan{start: start, end: end, startLine: startLine, endLine: endLine}
+		cursor, line = end, endLine
+		if content[end-1] == '\n' {
+			line++
+		}
+	}
+	return spans
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChunkOffsets(t *testing.T) {
+	s := NewIngestService(nil).WithSettings(memSettings{}).WithDefaultTokenizer("whitespace").
+		WithDefaultChunking(Chunking{Strategy: ChunkLines, Overlap: 1})
+	var lines []string
+	for i := range 12 {
+		lines = append(lines, strings.Repeat("word ", i%3+1)+"end")
+	}
+	content := []byte("\xef\xbb\xbf" + strings.Join(lines, "\n") + "\n")
+	prepared, err := s.prepareChunks(context.Background(), "docs", "notes.txt", content, "abc", IngestOptions{MaxTokens: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prepared.chunks) < 3 {
+		t.Fatalf("expected several chunks, got %q", prepared.chunks)
+	}
+	for i, chunk := range prepared.chunks {
+		md := prepared.metadatas[i]
+		start, ok := md[startByteKey].(int)
+		end, _ := md[endByteKey].(int)
+		located := string(content[start:end])
+		if !ok || located != chunk && located != strings.TrimRight(chunk, "\n") {
+			t.Fatalf("chunk %d: offsets %v-%v don't locate %q", i, md[startByteKey], md[endByteKey], chunk)
+		}
+		// Lines count from the start of the file, BOM included
+		wantStart := bytes.Count(content[:start], []byte("\n")) + 1
+		wantEnd := wantStart + strings.Count(strings.TrimSuffix(located, "\n"), "\n")
+		if md[chunkStartLineKey] != wantStart || md[chunkEndLineKey] != wantEnd {
+			t.Errorf("chunk %d: expected lines %d-%d, got %v-%v", i, wantStart, wantEnd, md[chunkStartLineKey], md[chunkEndLineKey])
+		}
+	}
+
+	// Text that was decoded or transformed isn't in the file as such
+	prepared, err = s.prepareChunks(context.Background(), "docs", "notes.txt", content, "abc", IngestOptions{MaxTokens: 6, Transform: strings.ToUpper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := prepared.metadatas[0][startByteKey]; ok {
+		t.Error("expected no offsets for transformed text")
+	}
+	spans := chunkSpans([]byte("alpha beta"), []string{"alpha ", "gamma", "beta"}, []int{0, 0, 0})
+	if spans[0] == nil || spans[1] != nil || spans[2] == nil || spans[2].start != 6 {
+		t.Errorf("expected chunks missing from the file to be skipped, got %+v", spans)
+	}
+}
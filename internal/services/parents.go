@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// parentOverfetch is how many candidates per requested result are fetched
+// when expanding to parents, since chunks of one parent collapse.
+const parentOverfetch = 3
+
+// expandParents replaces each result whose chunk was split from a parent
+// section with the parent's text, keeping only the best-ranked chunk of
+// each parent, and returns at most k results. Results without a parent are
+// kept as they are.
+func (s *IngestService) expandParents(ctx context.Context, results []SearchResult, k int) ([]SearchResult, error) {
+	metadata, err := s.resultMetadata(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ collection, parent string }
+	seen := map[key]bool{}
+	wanted := map[string][]string{}
+	out := make([]SearchResult, 0, min(len(results), k))
+	for _, r := range results {
+		if len(out) == k {
+			break
+		}
+		if parent, _ := metadata[r.Collection+"/"+r.ID][parentChunkKey].(string); parent != "" {
+			kk := key{r.Collection, parent}
+			if seen[kk] {
+				continue
+			}
+			seen[kk] = true
+			r.ParentID = parent
+			wanted[r.Collection] = append(wanted[r.Collection], parent)
+		}
+		out = append(out, r)
+	}
+
+	texts := map[key]string{}
+	for name, parents := range wanted {
+		collection, err := s.chromaDB.GetCollection(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get collection '%s': %w", name, err)
+		}
+		where := andWhere([]chroma.WhereClause{chroma.InString(parentChunkKey, parents...), aclWhere(PrincipalsFromContext(ctx))})
+		records, err := scanRecords(ctx, collection, where)
+		if err != nil {
+			return nil, err
+		}
+		for parent, text := range s.parentTexts(records) {
+			texts[key{name, parent}] = text
+		}
+	}
+	for i, r := range out {
+		if text, ok := texts[key{r.Collection, r.ParentID}]; ok && r.ParentID != "" {
+			out[i].Document = text
+		}
+	}
+	return out, nil
+}
+
+// parentTexts rebuilds parent sections from their chunks, keyed by parent
+// ID: each parent's chunks concatenated in chunk_index order, less the text
+// each repeats from the one before.
+func (s *IngestService) parentTexts(records []Record) map[string]string {
+	sort.SliceStable(records, func(i, j int) bool {
+		return toInt64(records[i].Metadata[s.keys.ChunkIndex]) < toInt64(records[j].Metadata[s.keys.ChunkIndex])
+	})
+	builders := map[string]*strings.Builder{}
+	for _, r := range records {
+		parent, _ := r.Metadata[parentChunkKey].(string)
+		if parent == "" {
+			continue
+		}
+		b, ok := builders[parent]
+		if !ok {
+			b = &strings.Builder{}
+			builders[parent] = b
+		}
+		b.WriteString(withoutOverlap(r))
+	}
+	out := make(map[string]string, len(builders))
+	for parent, b := range builders {
+		out[parent] = b.String()
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParentChunks(t *testing.T) {
+	col := &fileCollection{records: map[string]Record{}}
+	s := NewIngestService(fileClient{collection: col}).WithSettings(memSettings{}).WithDefaultTokenizer("whitespace").
+		WithDefaultChunking(Chunking{Strategy: ChunkRecursive, Size: 3, Overlap: 1, ParentSize: 8})
+	text := "one two three four five six seven eight\n\nnine ten eleven twelve"
+	prepared, err := s.prepareChunks(context.Background(), "docs", "notes.txt", []byte(text), "abc", IngestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks are grouped by parent, and each parent's chunks rebuild it
+	var parents []string
+	rebuilt := map[string]string{}
+	for i, chunk := range prepared.chunks {
+		parent, _ := prepared.metadatas[i][parentChunkKey].(string)
+		if parent == "" {
+			t.Fatalf("chunk %d has no parent", i)
+		}
+		if len(parents) == 0 || parents[len(parents)-1] != parent {
+			parents = append(parents, parent)
+		}
+		overlap, _ := prepared.metadatas[i][overlapKey].(int)
+		rebuilt[parent] += chunk[overlap:]
+		id := prepared.ids[i]
+		col.records[id] = Record{ID: id, Document: chunk, Metadata: prepared.metadatas[i]}
+	}
+	want := []string{"one two three four five six seven eight\n\n", "nine ten eleven twelve"}
+	if len(parents) != len(want) {
+		t.Fatalf("expected %d parents, got %d", len(want), len(parents))
+	}
+	for i, parent := range parents {
+		if parent != ParentID("notes.txt", i, want[i]) {
+			t.Errorf("parent %d: unexpected ID %s", i, parent)
+		}
+		if rebuilt[parent] != want[i] {
+			t.Errorf("parent %d: chunks rebuild %q, want %q", i, rebuilt[parent], want[i])
+		}
+	}
+
+	// Searches return each parent once, in the rank of its best chunk
+	results := []SearchResult{
+		{ID: prepared.ids[len(prepared.ids)-1], Document: prepared.chunks[len(prepared.ids)-1], Collection: "docs"},
+		{ID: prepared.ids[0], Document: prepared.chunks[0], Collection: "docs"},
+		{ID: prepared.ids[1], Document: prepared.chunks[1], Collection: "docs"},
+	}
+	got, err := s.expandParents(context.Background(), results, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ParentID != parents[1] || got[0].Document != want[1] || got[1].ParentID != parents[0] || got[1].Document != want[0] {
+		t.Errorf("unexpected expanded results: %+v", got)
+	}
+	if got[0].ID != results[0].ID {
+		t.Errorf("expected the matched chunk's ID to be kept, got %s", got[0].ID)
+	}
+	if got, _ := s.expandParents(context.Background(), results, 1); len(got) != 1 {
+		t.Errorf("expected k to cap the results, got %d", len(got))
+	}
+
+	if err := (Chunking{Strategy: ChunkRecursive, Size: 100, ParentSize: 50}).Validate(); !errors.Is(err, ErrInvalidChunking) {
+		t.Errorf("expected a parent smaller than a chunk to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minTextLayerChars is how many letters or digits a PDF's text layer must
+// yield before it is trusted over OCR.
+const minTextLayerChars = 16
+
+func init() {
+	extractors[".pdf"] = extractPdf
+}
+
+// extractPdf reads the text layer of a PDF: strings drawn by text operators
+// in uncompressed or Flate-compressed content streams. It does not map
+// embedded font encodings, so PDFs using custom or CID encodings (and
+// scanned PDFs with no text at all) yield no sections and fall back to OCR.
+func extractPdf(content []byte) ([]docSection, error) {
+	var b strings.Builder
+	for _, stream := range pdfStreams(content, maxExtractedPartSize) {
+		b.WriteString(pdfContentText(stream))
+	}
+	text := blankLinesRe.ReplaceAllString(strings.TrimSpace(collapseLineSpaces(b.String())), "\n\n")
+	if !hasTextLayer(text) {
+		return nil, nil
+	}
+	return []docSection{{text: text}}, nil
+}
+
+func hasTextLayer(text string) bool {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if n++; n >= minTextLayerChars {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pdfStreams returns the decoded data of every stream that is stored raw or
+// Flate-compressed; streams with other filters (images, fonts) are skipped.
+// At most limit bytes are decompressed across all streams, so a PDF packed
+// with small streams that each inflate hugely can't exhaust memory.
+func pdfStreams(content []byte, limit int64) [][]byte {
+	var out [][]byte
+	for pos := 0; ; {
+		i := bytes.Index(content[pos:], []byte("stream"))
+		if i < 0 {
+			return out
+		}
+		start := pos + i
+		pos = start + len("stream")
+		if start >= 3 && string(content[start-3:start]) == "end" {
+			continue
+		}
+		body := pos
+		switch {
+		case bytes.HasPrefix(content[body:], []byte("\r\n")):
+			body += 2
+		case bytes.HasPrefix(content[body:], []byte("\n")), bytes.HasPrefix(content[body:], []byte("\r")):
+			body++
+		default:
+			continue
+		}
+		end := bytes.Index(content[body:], []byte("endstream"))
+		if end < 0 {
+			return out
+		}
+		data := content[body : body+end]
+		pos = body + end + len("endstream")
+
+		// The stream dictionary sits between the object header and "stream"
+		dict := content[:start]
+		if obj := bytes.LastIndex(dict, []byte(" obj")); obj >= 0 {
+			dict = dict[obj:]
+		}
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// Tolerate truncated streams; keep what decompressed
+			decoded, _ := io.ReadAll(io.LimitReader(r, limit))
+			r.Close()
+			out = append(out, decoded)
+			if limit -= int64(len(decoded)); limit <= 0 {
+				return out
+			}
+		case !bytes.Contains(dict, []byte("/Filter")):
+			out = append(out, data)
+		}
+	}
+}
+
+// pdfContentText collects the strings shown by Tj, TJ, ' and " inside
+// BT/ET text objects, starting a new line on line-moving operators.
+func pdfContentText(data []byte) string {
+	var b strings.Builder
+	var pending []string // string operands since the last operator
+	inText := false
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := pdfLiteralString(data, i)
+			pending = append(pending, s)
+			i = next
+		case c == '<' && i+1 < len(data) && data[i+1] != '<':
+			end := bytes.IndexByte(data[i:], '>')
+			if end < 0 {
+				return b.String()
+			}
+			pending = append(pending, pdfHexString(data[i+1:i+end]))
+			i += end + 1
+		case c == '[' || c == ']' || c == '<' || c == '>' || c == '{' || c == '}' || isPdfSpace(c):
+			i++
+		default:
+			start := i
+			for i < len(data) && !isPdfSpace(data[i]) && !strings.ContainsRune("()<>[]{}/%", rune(data[i])) {
+				i++
+			}
+			if i == start { // a name's leading '/'
+				i++
+				continue
+			}
+			token := string(data[start:i])
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				// A large negative TJ adjustment is a word gap
+				if inText && n < -200 && len(pending) > 0 {
+					pending = append(pending, " ")
+				}
+				continue
+			}
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				newline()
+			case "Tj", "TJ":
+				if inText {
+					b.WriteString(strings.Join(pending, ""))
+				}
+			case "'", "\"":
+				if inText {
+					newline()
+					b.WriteString(strings.Join(pending, ""))
+				}
+			case "Td", "TD", "T*":
+				if inText {
+					newline()
+				}
+			}
+			pending = pending[:0]
+		}
+	}
+	return b.String()
+}
+
+func isPdfSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// pdfLiteralString decodes a (...) string starting at data[start], returning
+// it and the index just past the closing parenthesis.
+func pdfLiteralString(data []byte, start int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfPrintable(b.String()), i + 1
+			}
+			b.WriteByte(c)
+		case '\\':
+			if i+1 >= len(data) {
+				break
+			}
+			i++
+			switch e := data[i]; e {
+			case 'n', 'r':
+				b.WriteByte(' ')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n': // line continuation
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						n = n*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					b.WriteByte(byte(n))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return pdfPrintable(b.String()), len(data)
+}
+
+func pdfHexString(hex []byte) string {
+	var digits []byte
+	for _, c := range hex {
+		if !isPdfSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return ""
+		}
+		out = append(out, byte(n))
+	}
+	return pdfPrintable(string(out))
+}
+
+// pdfPrintable keeps the printable Latin-1 characters of a decoded string;
+// bytes from unmapped font encodings would otherwise end up as noise.
+func pdfPrintable(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		r := rune(s[i])
+		if unicode.IsPrint(r) || r == '\t' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
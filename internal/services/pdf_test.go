@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// buildPdf wraps content streams in just enough PDF structure for the
+// text-layer extractor.
+func buildPdf(t *testing.T, streams ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+4, z.Len())
+		b.Write(z.Bytes())
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPdfTextLayer(t *testing.T) {
+	content := buildPdf(t,
+		`BT /F1 12 Tf 72 720 Td (Quarterly \(draft\) report) Tj 0 -14 Td [(Rev) -20 (enue gr) -250 (ew)] TJ ET`,
+		`BT /F1 12 Tf T* <4F7065726174696E67> Tj (costs fell) ' ET`,
+	)
+	sections, err := extractSections("report.pdf", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Quarterly (draft) report\nRevenue gr ew\nOperating\ncosts fell"
+	if len(sections) != 1 || sections[0].text != want {
+		t.Errorf("unexpected sections: %+v", sections)
+	}
+}
+
+func TestExtractPdfWithoutTextLayer(t *testing.T) {
+	// A scanned page: only an image is drawn
+	sections, err := extractSections("scan.pdf", buildPdf(t, `q 612 0 0 792 0 0 cm /Im0 Do Q`))
+	if err != nil || len(sections) != 0 {
+		t.Errorf("expected no sections, got %+v (%v)", sections, err)
+	}
+}
+
+func TestPdfStreamsDecodeLimit(t *testing.T) {
+	content := buildPdf(t, string(bytes.Repeat([]byte("a"), 600)), string(bytes.Repeat([]byte("b"), 600)), "c")
+	streams := pdfStreams(content, 1000)
+	if len(streams) != 2 || len(streams[0]) != 600 || len(streams[1]) != 400 {
+		t.Errorf("expected decoding to stop after 1000 bytes in total, got %d streams", len(streams))
+	}
+}
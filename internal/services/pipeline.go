@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPipelineRunning is returned when a pipeline is triggered while a run is in progress.
+var ErrPipelineRunning = errors.New("pipeline is already running")
+
+// PipelineSpec declares a reproducible ingest flow:
+// source → transforms → chunker → collection.
+//
+//	name: handbook
+//	collection: docs
+//	schedule: 6h
+//	source:
+//	  type: path
+//	  path: /srv/handbook
+//	  include: ["**/*.md", "**/*.txt"]
+//	extractors:
+//	  - {match: "*.txt", as: .md}
+//	transforms: [strip_html, collapse_whitespace]
+//	chunker:
+//	  max_tokens: 256
+type PipelineSpec struct {
+	Name       string                 `json:"name" yaml:"name"`
+	Collection string                 `json:"collection" yaml:"collection"`
+	Schedule   string                 `json:"schedule,omitempty" yaml:"schedule"`
+	Source     PipelineSource         `json:"source" yaml:"source"`
+	Extractors []PipelineExtractor    `json:"extractors,omitempty" yaml:"extractors"`
+	Transforms []string               `json:"transforms,omitempty" yaml:"transforms"`
+	Chunker    PipelineChunker        `json:"chunker,omitempty" yaml:"chunker"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty" yaml:"metadata"`
+	ACL        []string               `json:"acl,omitempty" yaml:"acl"`
+}
+
+// PipelineSource selects where a pipeline reads files from.
+type PipelineSource struct {
+	Type    string   `json:"type" yaml:"type"` // "path" or "url"
+	Path    string   `json:"path,omitempty" yaml:"path"`
+	Include []string `json:"include,omitempty" yaml:"include"`
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude"`
+	URLs    []string `json:"urls,omitempty" yaml:"urls"`
+}
+
+// PipelineExtractor reads the files matching a glob with the extractor of
+// another file type, named by its extension: {match: "*.txt", as: .md}
+// chunks plain text files as Markdown. Globs match a path source's relative
+// paths and a url source's URL paths; the first matching entry applies.
+type PipelineExtractor struct {
+	Match string `json:"match" yaml:"match"`
+	As    string `json:"as" yaml:"as"`
+}
+
+// PipelineChunker configures chunking for a pipeline.
+type PipelineChunker struct {
+	MaxTokens int `json:"max_tokens,omitempty" yaml:"max_tokens"`
+	// Strategy overrides the collection's chunking strategy.
+	Strategy string `json:"strategy,omitempty" yaml:"strategy"`
+}
+
+// pipelineTransforms are the text transforms a spec may reference by name.
+var pipelineTransforms = map[string]func(string) string{
+	"trim":                strings.TrimSpace,
+	"lowercase":           strings.ToLower,
+	"strip_html":          stripHTML,
+	"collapse_whitespace": collapseWhitespace,
+}
+
+var (
+	htmlTagRe    = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]*>`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+	spacesRe     = regexp.MustCompile(`[ \t]+`)
+)
+
+func stripHTML(s string) string {
+	return html.UnescapeString(htmlTagRe.ReplaceAllString(s, " "))
+}
+
+func collapseWhitespace(s string) string {
+	s = spacesRe.ReplaceAllString(s, " ")
+	return blankLinesRe.ReplaceAllString(s, "\n\n")
+}
+
+// ParsePipelineSpec decodes a YAML (or JSON) spec and validates it.
+func ParsePipelineSpec(raw []byte) (*PipelineSpec, error) {
+	var spec PipelineSpec
+	if err := yaml.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("invalid pipeline spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks required fields and references to known transforms.
+func (p *PipelineSpec) Validate() error {
+	if p.Name == "" {
+		return errors.New("pipeline name is required")
+	}
+	if p.Collection == "" {
+		return errors.New("pipeline collection is required")
+	}
+	if p.Schedule != "" {
+		d, err := time.ParseDuration(p.Schedule)
+		if err != nil || d < time.Minute {
+			return fmt.Errorf("invalid schedule %q: must be a duration of at least 1m", p.Schedule)
+		}
+	}
+	switch p.Source.Type {
+	case "path":
+		if p.Source.Path == "" {
+			return errors.New("path source requires source.path")
+		}
+		if _, err := compileGlobs(append(p.Source.Include, p.Source.Exclude...)); err != nil {
+			return fmt.Errorf("invalid glob: %w", err)
+		}
+	case "url":
+		if len(p.Source.URLs) == 0 {
+			return errors.New("url source requires source.urls")
+		}
+		for _, raw := range p.Source.URLs {
+			if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid source url %q: must be an absolute http(s) URL", raw)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown source type %q", p.Source.Type)
+	}
+	for _, e := range p.Extractors {
+		if _, err := compileGlobs([]string{e.Match}); err != nil || strings.TrimSpace(e.Match) == "" {
+			return fmt.Errorf("invalid extractor match %q", e.Match)
+		}
+		if !knownExtension(e.As) {
+			return fmt.Errorf("unknown extractor type %q: use the extension of a supported type, e.g. .md", e.As)
+		}
+	}
+	for _, t := range p.Transforms {
+		if _, ok := pipelineTransforms[t]; !ok {
+			return fmt.Errorf("unknown transform %q", t)
+		}
+	}
+	if p.Chunker.MaxTokens < 0 {
+		return errors.New("chunker.max_tokens must be positive")
+	}
+	if p.Chunker.Strategy != "" {
+		if _, err := GetChunker(p.Chunker.Strategy); err != nil {
+			return fmt.Errorf("chunker.strategy: %w", err)
+		}
+	}
+	return nil
+}
+
+// interval returns the schedule duration, or zero for on-demand pipelines.
+// knownExtension reports whether ext names a built-in or registered file type.
+func knownExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	if _, ok := fileTypeByExtension(ext); ok {
+		return true
+	}
+	for _, t := range registeredTypes() {
+		for _, e := range t.Extensions {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// format returns the extension of the type name is read as under the
+// spec's extractors, or "" for the detected type.
+func (p *PipelineSpec) format(name string) string {
+	for _, e := range p.Extractors {
+		if g, err := compileGlobs([]string{e.Match}); err == nil && matchAny(g, name) {
+			return strings.ToLower(e.As)
+		}
+	}
+	return ""
+}
+
+func (p *PipelineSpec) interval() time.Duration {
+	d, _ := time.ParseDuration(p.Schedule)
+	return d
+}
+
+// PipelineStore persists pipeline specs and their run state.
+type PipelineStore interface {
+	SavePipeline(name, spec string) error
+	GetPipeline(name string) (config.Pipeline, error)
+	ListPipelines() ([]config.Pipeline, error)
+	DeletePipeline(name string) error
+	RecordPipelineRun(name string, at time.Time, status string) error
+}
+
+// PipelineRun reports the outcome of a pipeline run.
+type PipelineRun struct {
+	Pipeline  string         `json:"pipeline"`
+	StartedAt time.Time      `json:"started_at"`
+	Duration  string         `json:"duration"`
+	Timings   IngestTimings  `json:"timings"`
+	Results   []IngestResult `json:"results"`
+	Errors    []string       `json:"errors,omitempty"`
+	// Added, Updated and Unchanged classify a path source's files by the
+	// file index (see PathIngestReport).
+	Added     int `json:"added,omitempty"`
+	Updated   int `json:"updated,omitempty"`
+	Unchanged int `json:"unchanged,omitempty"`
+}
+
+// PipelineService stores, runs and schedules declarative pipelines.
+type PipelineService struct {
+	ingest   *IngestService
+	store    PipelineStore
+	notifier Notifier
+	client   *http.Client
+	// allowPrivate lets url sources reach loopback and private addresses.
+	allowPrivate bool
+	mu           sync.Mutex
+	running      map[string]bool
+}
+
+func NewPipelineService(ingest *IngestService, store PipelineStore) *PipelineService {
+	return &PipelineService{ingest: ingest, store: store, client: newFetchClient(30*time.Second, isPublicIP), running: make(map[string]bool)}
+}
+
+// WithPrivateNetworks lets url sources fetch loopback, private and
+// link-local addresses. By default they are refused.
+func (s *PipelineService) WithPrivateNetworks(allow bool) *PipelineService {
+	s.allowPrivate = allow
+	s.client = newFetchClient(30*time.Second, fetchGuard(allow))
+	return s
+}
+
+// WithNotifier reports failed runs to n.
+func (s *PipelineService) WithNotifier(n Notifier) *PipelineService {
+	s.notifier = n
+	return s
+}
+
+// Save validates and stores a spec, returning the parsed form.
+func (s *PipelineService) Save(raw []byte) (*PipelineSpec, error) {
+	spec, err := ParsePipelineSpec(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSource(spec); err != nil {
+		return nil, err
+	}
+	if err := s.store.SavePipeline(spec.Name, string(raw)); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// checkSource applies the server's limits to a spec's source: a path must
+// be within the allowed roots and URLs must not name private hosts.
+func (s *PipelineService) checkSource(spec *PipelineSpec) error {
+	switch spec.Source.Type {
+	case "path":
+		_, err := s.sourcePath(spec)
+		return err
+	case "url":
+		if s.allowPrivate {
+			return nil
+		}
+		for _, raw := range spec.Source.URLs {
+			u, _ := url.Parse(raw)
+			if err := checkPublicHost(u); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sourcePath resolves a path source against the ingest service's allowed
+// roots, so a pipeline can't read more of the server than /api/ingest/path.
+func (s *PipelineService) sourcePath(spec *PipelineSpec) (string, error) {
+	if len(s.ingest.pathRoots) == 0 {
+		return "", ErrPathIngestDisabled
+	}
+	return s.ingest.allowedPath(spec.Source.Path)
+}
+
+func (s *PipelineService) Get(name string) (config.Pipeline, error) {
+	return s.store.GetPipeline(name)
+}
+
+func (s *PipelineService) List() ([]config.Pipeline, error) {
+	return s.store.ListPipelines()
+}
+
+func (s *PipelineService) Delete(name string) error {
+	return s.store.DeletePipeline(name)
+}
+
+// Run executes a stored pipeline now.
+func (s *PipelineService) Run(ctx context.Context, name string) (*PipelineRun, error) {
+	stored, err := s.store.GetPipeline(name)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParsePipelineSpec([]byte(stored.Spec))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, ErrPipelineRunning
+	}
+	s.running[name] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+	}()
+
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{"pipeline": name, "state": "running"}})
+	run := s.execute(ctx, spec)
+	status := "ok"
+	if len(run.Errors) > 0 {
+		status = fmt.Sprintf("errors: %d", len(run.Errors))
+		notify(s.notifier, EventJobFailed, *run)
+	}
+	s.ingest.events.Publish(Event{Type: EventJobState, Collection: spec.Collection, Data: map[string]interface{}{
+		"pipeline": name,
+		"state":    "finished",
+		"status":   status,
+		"files":    len(run.Results),
+		"duration": run.Duration,
+	}})
+	if err := s.store.RecordPipelineRun(name, run.StartedAt, status); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("pipeline", name).Warn("Failed to record pipeline run")
+	}
+	return run, nil
+}
+
+func (s *PipelineService) execute(ctx context.Context, spec *PipelineSpec) *PipelineRun {
+	run := &PipelineRun{Pipeline: spec.Name, StartedAt: time.Now()}
+	opts := IngestOptions{
+		Metadata:  spec.Metadata,
+		ACL:       spec.ACL,
+		MaxTokens: spec.Chunker.MaxTokens,
+		Chunker:   spec.Chunker.Strategy,
+		Source:    IngestSource{ID: "pipeline:" + spec.Name, Kind: SourcePipeline, Ref: spec.Name},
+		Transform: func(text string) string {
+			for _, t := range spec.Transforms {
+				text = pipelineTransforms[t](text)
+			}
+			return text
+		},
+	}
+
+	ingest := func(name, matchName string, content []byte) {
+		opts := opts
+		opts.Format = spec.format(matchName)
+		res, err := s.ingest.IngestFileWithOptions(ctx, spec.Collection, name, content, opts)
+		if err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", name, err))
+			run.Results = append(run.Results, IngestResult{Status: "error", File: name})
+			return
+		}
+		run.Results = append(run.Results, *res)
+	}
+
+	switch spec.Source.Type {
+	case "path":
+		dir, err := s.sourcePath(spec)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			break
+		}
+		files, err := walkFiles(dir, spec.Source.Include, spec.Source.Exclude)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			break
+		}
+		index, err := s.ingest.openDirIndex(spec.Collection, opts.Source.ID, false)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			break
+		}
+		for _, rel := range files {
+			if ctx.Err() != nil {
+				break
+			}
+			fileOpts := opts
+			fileOpts.Format = spec.format(rel)
+			res, change := s.ingest.ingestIndexed(ctx, spec.Collection, filepath.Join(dir, filepath.FromSlash(rel)), rel, fileOpts, index)
+			switch {
+			case res.Status == "error":
+				run.Errors = append(run.Errors, fmt.Sprintf("%s: %s", rel, res.Error))
+				res.Error = ""
+			case res.Status == statusUnchanged:
+				run.Unchanged++
+				continue
+			case res.Status == "ingested" && change == fileUpdated:
+				run.Updated++
+			case res.Status == "ingested":
+				run.Added++
+			}
+			run.Results = append(run.Results, res)
+		}
+		if err := index.save(); err != nil {
+			run.Errors = append(run.Errors, err.Error())
+		}
+	case "url":
+		for _, u := range spec.Source.URLs {
+			if ctx.Err() != nil {
+				break
+			}
+			content, err := fetchURL(ctx, s.client, u)
+			if err != nil {
+				run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", u, err))
+				continue
+			}
+			parsed, _ := url.Parse(u)
+			ingest(u, strings.TrimPrefix(parsed.Path, "/"), content)
+		}
+	}
+
+	run.Duration = time.Since(run.StartedAt).Round(time.Millisecond).String()
+	run.Timings = sumTimings(run.Results)
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"pipeline": spec.Name,
+		"files":    len(run.Results),
+		"errors":   len(run.Errors),
+	}).Info("Pipeline run complete")
+	return run
+}
+
+func fetchURL(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// RunScheduler runs scheduled pipelines whose interval has elapsed, checking
+// every tick until ctx is canceled.
+func (s *PipelineService) RunScheduler(ctx context.Context, tick time.Duration) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			pipelines, err := s.store.ListPipelines()
+			if err != nil {
+				logging.FromContext(ctx).WithError(err).Warn("Failed to list pipelines for scheduling")
+				continue
+			}
+			for _, p := range pipelines {
+				spec, err := ParsePipelineSpec([]byte(p.Spec))
+				if err != nil || spec.interval() == 0 || now.Sub(p.LastRunAt) < spec.interval() {
+					continue
+				}
+				go func(name string) {
+					if _, err := s.Run(ctx, name); err != nil && !errors.Is(err, ErrPipelineRunning) {
+						logging.FromContext(ctx).WithError(err).WithField("pipeline", name).Error("Scheduled pipeline run failed")
+					}
+				}(p.Name)
+			}
+		}
+	}
+}
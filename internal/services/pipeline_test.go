@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func TestParsePipelineSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{
+			name: "yaml path source",
+			raw: `
+name: handbook
+collection: docs
+schedule: 6h
+source:
+  type: path
+  path: /srv/handbook
+  include: ["**/*.md"]
+transforms: [strip_html, trim]
+chunker:
+  max_tokens: 256
+`,
+		},
+		{
+			name: "json url source",
+			raw:  `{"name":"site","collection":"web","source":{"type":"url","urls":["http://example.com"]}}`,
+		},
+		{name: "missing collection", raw: "name: x\nsource: {type: path, path: .}", wantErr: true},
+		{name: "unknown transform", raw: "name: x\ncollection: c\nsource: {type: path, path: .}\ntransforms: [nope]", wantErr: true},
+		{name: "schedule too short", raw: "name: x\ncollection: c\nschedule: 5s\nsource: {type: path, path: .}", wantErr: true},
+		{name: "unknown extractor type", raw: "name: x\ncollection: c\nsource: {type: path, path: .}\nextractors: [{match: '*.txt', as: .zzz}]", wantErr: true},
+		{name: "non-http url", raw: `{"name":"x","collection":"c","source":{"type":"url","urls":["file:///etc/passwd"]}}`, wantErr: true},
+		{name: "unknown source", raw: "name: x\ncollection: c\nsource: {type: ftp}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePipelineSpec([]byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePipelineSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"README.md", "docs/guide.md", "docs/img.png", "vendor/lib.md"} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := walkFiles(root, []string{"*.md"}, []string{"vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"README.md", "docs/guide.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walkFiles() = %v, want %v", got, want)
+	}
+
+	got, _ = walkFiles(root, []string{"docs/**"}, nil)
+	want = []string{"docs/guide.md", "docs/img.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walkFiles(docs/**) = %v, want %v", got, want)
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	got := collapseWhitespace(stripHTML("<p>Hello&amp;<b>world</b></p><script>x()</script>"))
+	if got != " Hello& world " {
+		t.Errorf("stripHTML() = %q", got)
+	}
+}
+
+// memPipelineStore keeps pipelines in memory.
+type memPipelineStore map[string]config.Pipeline
+
+func (m memPipelineStore) SavePipeline(name, spec string) error {
+	m[name] = config.Pipeline{Name: name, Spec: spec}
+	return nil
+}
+
+func (m memPipelineStore) GetPipeline(name string) (config.Pipeline, error) {
+	p, ok := m[name]
+	if !ok {
+		return config.Pipeline{}, config.ErrNotFound
+	}
+	return p, nil
+}
+
+func (m memPipelineStore) ListPipelines() ([]config.Pipeline, error) {
+	var out []config.Pipeline
+	for _, p := range m {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (m memPipelineStore) DeletePipeline(name string) error {
+	delete(m, name)
+	return nil
+}
+
+func (m memPipelineStore) RecordPipelineRun(name string, at time.Time, status string) error {
+	return nil
+}
+
+func TestPipelinePathRoots(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{root, outside} {
+		if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("Alpha"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	col := &writeCollection{files: map[string]int{}}
+	store := memPipelineStore{}
+	s := NewPipelineService(NewIngestService(writeClient{collection: col}), store)
+	spec := func(name, dir string) []byte {
+		return []byte("name: " + name + "\ncollection: docs\nsource: {type: path, path: " + dir + "}")
+	}
+
+	if _, err := s.Save(spec("inside", root)); !errors.Is(err, ErrPathIngestDisabled) {
+		t.Errorf("expected ErrPathIngestDisabled without roots, got %v", err)
+	}
+	s.ingest.WithPathRoots([]string{root})
+	if _, err := s.Save(spec("inside", root)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Save(spec("outside", outside)); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("expected ErrPathNotAllowed on save, got %v", err)
+	}
+
+	// A spec stored before the roots changed is checked again when it runs
+	store.SavePipeline("outside", string(spec("outside", outside)))
+	run, err := s.Run(context.Background(), "outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Errors) != 1 || !strings.Contains(run.Errors[0], ErrPathNotAllowed.Error()) || len(col.files) != 0 {
+		t.Errorf("expected the run refused without reading files, got %+v and writes %v", run, col.files)
+	}
+	if run, err := s.Run(context.Background(), "inside"); err != nil || len(run.Errors) != 0 || col.files["a.md"] != 1 {
+		t.Errorf("expected the allowed directory ingested, got %+v, %v and writes %v", run, err, col.files)
+	}
+}
+
+func TestPipelineURLPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+	col := &writeCollection{files: map[string]int{}}
+	store := memPipelineStore{}
+	s := NewPipelineService(NewIngestService(writeClient{collection: col}), store)
+	raw := `{"name":"site","collection":"docs","source":{"type":"url","urls":["` + srv.URL + `/page.txt"]}}`
+
+	if _, err := s.Save([]byte(raw)); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected a loopback URL refused on save, got %v", err)
+	}
+	store.SavePipeline("site", raw)
+	run, err := s.Run(context.Background(), "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Errors) != 1 || !strings.Contains(run.Errors[0], ErrPrivateAddress.Error()) || len(col.files) != 0 {
+		t.Errorf("expected the fetch refused, got %+v and writes %v", run, col.files)
+	}
+
+	s.WithPrivateNetworks(true)
+	if _, err := s.Save([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	if run, err := s.Run(context.Background(), "site"); err != nil || len(run.Errors) != 0 || col.files[srv.URL+"/page.txt"] != 1 {
+		t.Errorf("expected the page ingested with private networks allowed, got %+v, %v and writes %v", run, err, col.files)
+	}
+}
+
+func TestPipelineExtractors(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"notes.txt": "# Setup\nInstall it.", "plain.log": "# not a heading"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	col := &writeCollection{files: map[string]int{}, metadata: map[string]chroma.DocumentMetadata{}}
+	store := memPipelineStore{}
+	s := NewPipelineService(NewIngestService(writeClient{collection: col}).WithPathRoots([]string{root}), store)
+	if _, err := s.Save([]byte("name: notes\ncollection: docs\nsource: {type: path, path: " + root + "}\nextractors: [{match: '*.txt', as: .md}]")); err != nil {
+		t.Fatal(err)
+	}
+	if run, err := s.Run(context.Background(), "notes"); err != nil || len(run.Errors) != 0 {
+		t.Fatalf("unexpected run %+v, %v", run, err)
+	}
+	if heading, _ := col.metadata["notes.txt"].GetString(headingKey); heading != "Setup" {
+		t.Errorf("expected notes.txt read as Markdown, got heading %q", heading)
+	}
+	if heading, ok := col.metadata["plain.log"].GetString(headingKey); ok {
+		t.Errorf("expected plain.log read as text, got heading %q", heading)
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+var (
+	// ErrNoPreview is returned for a file type previews can't be drawn for.
+	ErrNoPreview = errors.New("no preview for this file type")
+	// ErrPreviewUnavailable is returned when rendering needs a tool that
+	// isn't installed, e.g. pdftoppm for PDFs.
+	ErrPreviewUnavailable = errors.New("preview rendering is not available")
+)
+
+const (
+	defaultPreviewSize = 256
+	minPreviewSize     = 32
+	maxPreviewSize     = 1024
+	// maxPreviewPixels bounds the images decoded for a preview, so a small
+	// file can't claim a huge canvas.
+	maxPreviewPixels      = 50_000_000
+	maxPreviewSourceBytes = 64 << 20
+	previewQuality        = 80
+)
+
+// Preview is a JPEG thumbnail of a stored original file.
+type Preview struct {
+	FileMD5 string
+	Size    int
+	Data    []byte
+}
+
+// WithPdftoppm sets the pdftoppm binary that renders PDF previews
+// (default: "pdftoppm" on the PATH).
+func (s *IngestService) WithPdftoppm(path string) *IngestService {
+	s.pdftoppm = path
+	return s
+}
+
+// Preview returns a thumbnail of the file with the given content hash whose
+// longer side is at most size pixels: the first page of a PDF, or a PNG,
+// JPEG or GIF image scaled down. It needs the original in the blob store,
+// where the rendered preview is cached too.
+func (s *IngestService) Preview(ctx context.Context, collectionName, md5Hash string, size int) (*Preview, error) {
+	if size <= 0 {
+		size = defaultPreviewSize
+	}
+	size = min(max(size, minPreviewSize), maxPreviewSize)
+	file, err := s.OpenOriginal(ctx, collectionName, md5Hash)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Body.Close()
+	out := &Preview{FileMD5: md5Hash, Size: size}
+
+	key := previewKey(md5Hash, size)
+	if rc, err := s.blobs.Open(ctx, key); err == nil {
+		out.Data, err = io.ReadAll(rc)
+		rc.Close()
+		if err == nil {
+			return out, nil
+		}
+	}
+
+	var img image.Image
+	switch file.MIME {
+	case "image/png", "image/jpeg", "image/gif":
+		content, err := io.ReadAll(io.LimitReader(file.Body, maxPreviewSourceBytes))
+		if err != nil {
+			return nil, err
+		}
+		if img, err = decodePreviewImage(content); err != nil {
+			return nil, err
+		}
+	case "application/pdf":
+		content, err := io.ReadAll(io.LimitReader(file.Body, maxPreviewSourceBytes))
+		if err != nil {
+			return nil, err
+		}
+		if img, err = s.renderPDFPage(ctx, content, size); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNoPreview, file.MIME)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail(img, size), &jpeg.Options{Quality: previewQuality}); err != nil {
+		return nil, fmt.Errorf("encode preview: %w", err)
+	}
+	out.Data = buf.Bytes()
+	if err := s.blobs.Put(ctx, key, out.Data); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("file_md5", md5Hash).Warn("Failed to cache preview")
+	}
+	return out, nil
+}
+
+// previewKey derives the blob key a preview is cached under. It is a hex
+// hash like the originals' keys, so it can't collide with one.
+func previewKey(md5Hash string, size int) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte("preview\x00"+md5Hash+"\x00"+strconv.Itoa(size))))
+}
+
+func decodePreviewImage(content []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxPreviewPixels {
+		return nil, fmt.Errorf("%w: image is %dx%d", ErrNoPreview, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	return img, nil
+}
+
+// renderPDFPage renders the first page of a PDF with pdftoppm, scaled so
+// its longer side is size pixels.
+func (s *IngestService) renderPDFPage(ctx context.Context, content []byte, size int) (image.Image, error) {
+	bin := s.pdftoppm
+	if bin == "" {
+		bin = "pdftoppm"
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		return nil, fmt.Errorf("%w: PDF previews need pdftoppm: %v", ErrPreviewUnavailable, err)
+	}
+	dir, err := os.MkdirTemp("", "forge-preview-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "in.pdf")
+	if err := os.WriteFile(src, content, 0o600); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, bin, "-f", "1", "-l", "1", "-scale-to", strconv.Itoa(size), "-png", "-singlefile", src, filepath.Join(dir, "page"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("render pdf page: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "page.png"))
+	if err != nil {
+		return nil, err
+	}
+	return decodePreviewImage(page)
+}
+
+// thumbnail scales img so its longer side is at most size, averaging the
+// source pixels under each target pixel, onto a white background so
+// transparent images stay legible as JPEG.
+func thumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > size {
+		tw, th = size, max(1, h*size/w)
+	} else if h > w && h > size {
+		tw, th = max(1, w*size/h), size
+	}
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := b.Min.Y+ty*h/th, b.Min.Y+max((ty+1)*h/th, ty*h/th+1)
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := b.Min.X+tx*w/tw, b.Min.X+max((tx+1)*w/tw, tx*w/tw+1)
+			var r, g, bl, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(x, y).RGBA()
+					// Colors are alpha-premultiplied; add the white showing through
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			out.SetRGBA(tx, ty, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: 0xff})
+		}
+	}
+	return out
+}
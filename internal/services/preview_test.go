@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	ctx := context.Background()
+	// fileCollection ignores filters, so it holds one file at a time
+	col := &fileCollection{}
+	addFile := func(md5Hash, name string) {
+		id := ChunkID(name, 0, "text")
+		col.records = map[string]Record{id: {ID: id, Document: "text", Metadata: map[string]interface{}{"file_md5": md5Hash, "file_name": name, "chunk_index": int64(0)}}}
+	}
+	addFile("aa11", "photo.png")
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewIngestService(fileClient{collection: col}).WithBlobStore(store)
+
+	// A 400x200 image, transparent on the right half
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	s.storeBlob(ctx, "aa11", "photo.png", buf.Bytes())
+	s.storeBlob(ctx, "bb22", "notes.txt", []byte("plain text"))
+
+	preview, err := s.Preview(ctx, "docs", "aa11", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(preview.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("expected a 100x50 preview, got %v", b)
+	}
+	if r, g, _, _ := img.At(10, 25).RGBA(); r>>8 < 0xe0 || g>>8 > 0x20 {
+		t.Errorf("expected red on the left, got %v", img.At(10, 25))
+	}
+	if r, g, b, _ := img.At(90, 25).RGBA(); r>>8 < 0xe0 || g>>8 < 0xe0 || b>>8 < 0xe0 {
+		t.Errorf("expected transparency on white, got %v", img.At(90, 25))
+	}
+
+	// The preview is cached in the blob store
+	if rc, err := store.Open(ctx, previewKey("aa11", 100)); err != nil {
+		t.Errorf("expected a cached preview, got %v", err)
+	} else {
+		rc.Close()
+	}
+	if again, err := s.Preview(ctx, "docs", "aa11", 100); err != nil || !bytes.Equal(again.Data, preview.Data) {
+		t.Errorf("expected the cached preview, got %v", err)
+	}
+
+	addFile("bb22", "notes.txt")
+	if _, err := s.Preview(ctx, "docs", "bb22", 0); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview for text, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// protectedSettingKey marks a collection as protected in the settings table.
+const protectedSettingKey = "protected"
+
+// ErrCollectionProtected is returned when a destructive operation on a
+// protected collection is attempted without the force flag.
+var ErrCollectionProtected = errors.New("collection is protected; pass force=true to proceed")
+
+// ErrAdminRequired is returned when the caller lacks an admin credential.
+var ErrAdminRequired = errors.New("admin credential required")
+
+// IsAdmin reports whether the caller authenticated with an admin credential
+// (see WithAdmin). Principals are not enough: they are asserted by the
+// client, not authenticated.
+func (s *IngestService) IsAdmin(ctx context.Context) bool {
+	return AdminFromContext(ctx)
+}
+
+// IsProtected reports whether a collection is marked protected.
+func (s *IngestService) IsProtected(collection string) (bool, error) {
+	if s.settings == nil {
+		return false, nil
+	}
+	var protected bool
+	if _, err := s.settings.GetCollectionSetting(collection, protectedSettingKey, &protected); err != nil {
+		return false, err
+	}
+	return protected, nil
+}
+
+// SetProtected marks or unmarks a collection as protected. Only admins may
+// change protection.
+func (s *IngestService) SetProtected(ctx context.Context, collection string, protected bool) error {
+	if s.settings == nil {
+		return errNoSettingsStore
+	}
+	if !s.IsAdmin(ctx) {
+		return ErrAdminRequired
+	}
+	return s.settings.SetCollectionSetting(collection, protectedSettingKey, protected)
+}
+
+// checkDestructive guards operations that remove a collection or many of its
+// records: protected collections require force and an admin caller.
+func (s *IngestService) checkDestructive(ctx context.Context, collection string, force bool) error {
+	if IsSnapshotCollection(collection) {
+		return ErrSnapshotReadOnly
+	}
+	protected, err := s.IsProtected(collection)
+	if err != nil {
+		return fmt.Errorf("check protection of %q: %w", collection, err)
+	}
+	switch {
+	case !protected:
+		return nil
+	case !force:
+		return ErrCollectionProtected
+	case !s.IsAdmin(ctx):
+		return ErrAdminRequired
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// memSettings is an in-memory SettingsStore.
+type memSettings map[string][]byte
+
+func (m memSettings) GetCollectionSetting(collection, key string, dst any) (bool, error) {
+	raw, ok := m[collection+"/"+key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, dst)
+}
+
+func (m memSettings) SetCollectionSetting(collection, key string, value any) error {
+	raw, err := json.Marshal(value)
+	m[collection+"/"+key] = raw
+	return err
+}
+
+func TestProtectedCollections(t *testing.T) {
+	s := NewIngestService(nil).WithSettings(memSettings{})
+	admin := WithAdmin(context.Background())
+	user := WithPrincipals(context.Background(), []string{"bob"})
+	// Asserted principals don't grant admin scope, whatever their name
+	if err := s.SetProtected(WithPrincipals(context.Background(), []string{"admin"}), "prod", true); !errors.Is(err, ErrAdminRequired) {
+		t.Fatalf("expected an asserted admin principal to be refused, got %v", err)
+	}
+
+	if err := s.SetProtected(user, "prod", true); !errors.Is(err, ErrAdminRequired) {
+		t.Fatalf("expected non-admin to be refused, got %v", err)
+	}
+	if err := s.SetProtected(admin, "prod", true); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		ctx        context.Context
+		collection string
+		force      bool
+		want       error
+	}{
+		{user, "scratch", false, nil},
+		{user, "prod", false, ErrCollectionProtected},
+		{admin, "prod", false, ErrCollectionProtected},
+		{user, "prod", true, ErrAdminRequired},
+		{admin, "prod", true, nil},
+	}
+	for i, tc := range cases {
+		if err := s.checkDestructive(tc.ctx, tc.collection, tc.force); !errors.Is(err, tc.want) {
+			t.Errorf("case %d: got %v, want %v", i, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+// getPageSize bounds each Get call when scanning a whole collection.
+const getPageSize = 500
+
+// Record is a single stored chunk in plain Go types.
+type Record struct {
+	ID        string                 `json:"id"`
+	Document  string                 `json:"document"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Embedding []float32              `json:"embedding,omitempty"`
+}
+
+// scanRecords pages through every record matching where (nil for all).
+func scanRecords(ctx context.Context, collection chroma.Collection, where chroma.WhereFilter, include ...chroma.Include) ([]Record, error) {
+	if len(include) == 0 {
+		include = []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas}
+	}
+	var out []Record
+	for offset := 0; ; offset += getPageSize {
+		opts := []chroma.CollectionGetOption{
+			chroma.WithIncludeGet(include...),
+			chroma.WithLimitGet(getPageSize),
+			chroma.WithOffsetGet(offset),
+		}
+		if where != nil {
+			opts = append(opts, chroma.WithWhereGet(where))
+		}
+		res, err := collection.Get(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("get records at offset %d: %w", offset, err)
+		}
+		page := toRecords(res)
+		out = append(out, page...)
+		if len(page) < getPageSize {
+			return out, nil
+		}
+	}
+}
+
+// toRecords flattens a GetResult into records.
+func toRecords(res chroma.GetResult) []Record {
+	ids := res.GetIDs()
+	docs := res.GetDocuments()
+	metadatas := res.GetMetadatas()
+	embs := res.GetEmbeddings()
+	records := make([]Record, len(ids))
+	for i, id := range ids {
+		records[i].ID = string(id)
+		if i < len(docs) && docs[i] != nil {
+			records[i].Document = docs[i].ContentString()
+		}
+		if i < len(metadatas) {
+			records[i].Metadata = metadataToMap(metadatas[i])
+		}
+		if i < len(embs) && embs[i] != nil {
+			records[i].Embedding = embs[i].ContentAsFloat32()
+		}
+	}
+	return records
+}
+
+// writeRecords writes records in pages with add or upsert, reusing stored
+// embeddings when every record in a page has one.
+func writeRecords(ctx context.Context, write func(context.Context, ...chroma.CollectionAddOption) error, records []Record) error {
+	for start := 0; start < len(records); start += getPageSize {
+		end := min(start+getPageSize, len(records))
+		page := records[start:end]
+		ids := make([]chroma.DocumentID, len(page))
+		texts := make([]string, len(page))
+		metadatas := make([]chroma.DocumentMetadata, len(page))
+		var embs []embeddings.Embedding
+		for i, rec := range page {
+			ids[i] = chroma.DocumentID(rec.ID)
+			texts[i] = rec.Document
+			metadatas[i] = toDocumentMetadata(rec.Metadata)
+			if len(rec.Embedding) > 0 {
+				embs = append(embs, embeddings.NewEmbeddingFromFloat32(rec.Embedding))
+			}
+		}
+		opts := []chroma.CollectionAddOption{
+			chroma.WithIDs(ids...),
+			chroma.WithTexts(texts...),
+			chroma.WithMetadatas(metadatas...),
+		}
+		if len(embs) == len(page) {
+			opts = append(opts, chroma.WithEmbeddings(embs...))
+		}
+		if err := write(ctx, opts...); err != nil {
+			return fmt.Errorf("write records %d-%d: %w", start, end, err)
+		}
+	}
+	return nil
+}
+
+// metadataToMap converts Chroma document metadata into a plain map.
+func metadataToMap(md chroma.DocumentMetadata) map[string]interface{} {
+	keyed, ok := md.(interface{ Keys() []string })
+	if !ok {
+		return make(map[string]interface{})
+	}
+	return readMetadata(md, keyed.Keys())
+}
+
+// collectionMetadataToMap converts collection metadata into a plain map.
+func collectionMetadataToMap(md chroma.CollectionMetadata) map[string]interface{} {
+	if md == nil {
+		return make(map[string]interface{})
+	}
+	return readMetadata(md, md.Keys())
+}
+
+type metadataReader interface {
+	GetString(key string) (string, bool)
+	GetInt(key string) (int64, bool)
+	GetFloat(key string) (float64, bool)
+	GetBool(key string) (bool, bool)
+}
+
+func readMetadata(md metadataReader, keys []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := md.GetString(k); ok {
+			out[k] = v
+		} else if v, ok := md.GetInt(k); ok {
+			out[k] = v
+		} else if v, ok := md.GetFloat(k); ok {
+			out[k] = v
+		} else if v, ok := md.GetBool(k); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
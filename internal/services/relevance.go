@@ -0,0 +1,37 @@
+package services
+
+import (
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// distanceSpace returns the distance function of a collection's HNSW index;
+// Chroma defaults to squared L2.
+func distanceSpace(collection chroma.Collection) string {
+	if md := collection.Metadata(); md != nil {
+		if space, ok := md.GetString(chroma.HNSWSpace); ok && space != "" {
+			return space
+		}
+	}
+	return "l2"
+}
+
+// relevance maps a distance in the given space onto [0, 1], higher for
+// closer matches. Cosine distances lie in [0, 2], as do inner-product ones
+// for normalized embeddings; squared L2 distances are unbounded.
+func relevance(space string, distance float32) float32 {
+	var r float32
+	switch space {
+	case "cosine", "ip":
+		r = 1 - distance/2
+	default:
+		r = 1 / (1 + max(distance, 0))
+	}
+	return min(max(r, 0), 1)
+}
+
+// setRelevance scores results by their distance in the given space.
+func setRelevance(results []SearchResult, space string) {
+	for i := range results {
+		results[i].Relevance = relevance(space, results[i].Distance)
+	}
+}
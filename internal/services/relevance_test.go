@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRelevance(t *testing.T) {
+	cases := []struct {
+		space    string
+		distance float32
+		want     float32
+	}{
+		{"cosine", 0, 1},
+		{"cosine", 0.5, 0.75},
+		{"cosine", 2.5, 0},
+		{"ip", 1, 0.5},
+		{"ip", -0.2, 1},
+		{"l2", 0, 1},
+		{"l2", 3, 0.25},
+	}
+	for _, c := range cases {
+		if got := relevance(c.space, c.distance); got != c.want {
+			t.Errorf("relevance(%q, %g) = %g, want %g", c.space, c.distance, got, c.want)
+		}
+	}
+}
+
+func TestSearchRelevance(t *testing.T) {
+	docs := &queryCollection{name: "docs", hits: []queryHit{{"a.md", 0.5}}}
+	s := NewIngestService(docsClient{docs: docs})
+	// Collections without a space use Chroma's default, squared L2
+	for space, want := range map[string]float32{"": 2.0 / 3, "l2": 2.0 / 3, "cosine": 0.75} {
+		docs.space = space
+		results, err := s.Search(context.Background(), "docs", "a", 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Relevance != want {
+			t.Errorf("space %q: unexpected results %+v", space, results)
+		}
+	}
+}
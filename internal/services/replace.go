@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// fileVersions returns the IDs of a file's chunks stored with an MD5 other
+// than md5Hash, and whether any already carry md5Hash.
+func (s *IngestService) fileVersions(ctx context.Context, collection chroma.Collection, filePath, md5Hash string) ([]string, bool, error) {
+	records, err := scanRecords(ctx, collection, chroma.EqString(s.keys.FileName, filePath), chroma.IncludeMetadatas)
+	if err != nil {
+		return nil, false, err
+	}
+	var stale []string
+	current := false
+	for _, r := range records {
+		if name, _ := r.Metadata[s.keys.FileName].(string); name != filePath {
+			continue
+		}
+		if md5, _ := r.Metadata[s.keys.FileMD5].(string); md5 == md5Hash {
+			current = true
+		} else {
+			stale = append(stale, r.ID)
+		}
+	}
+	return stale, current, nil
+}
+
+// deleteReplaced deletes the chunks of a file's earlier versions once the
+// new version is stored, leaving those whose ID the new version reused,
+// and returns how many it deleted. Writing before deleting means searches
+// see the old version until the new one is in place; the intent log covers
+// a crash in between.
+func (s *IngestService) deleteReplaced(ctx context.Context, collection chroma.Collection, filePath string, replaced, kept []string) (int, error) {
+	keep := make(map[string]bool, len(kept))
+	for _, id := range kept {
+		keep[id] = true
+	}
+	var stale []chroma.DocumentID
+	var staleIDs []string
+	for _, id := range replaced {
+		if !keep[id] {
+			stale = append(stale, chroma.DocumentID(id))
+			staleIDs = append(staleIDs, id)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	finish, err := s.beginIntent(ctx, config.Intent{Collection: collection.Name(), Op: IntentDelete, IDs: staleIDs, Ref: filePath})
+	if err != nil {
+		return 0, err
+	}
+	err = collection.Delete(ctx, chroma.WithIDsDelete(stale...))
+	finish(err)
+	if err != nil {
+		return 0, fmt.Errorf("delete replaced chunks: %w", err)
+	}
+	return len(stale), nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReplaceIngest(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{}}
+	s := NewIngestService(fileClient{collection: col}).WithSettings(memSettings{}).WithDefaultTokenizer("whitespace").
+		WithDefaultChunking(Chunking{Strategy: ChunkLines})
+	opts := IngestOptions{MaxTokens: 3, Replace: true}
+	other, err := s.prepareChunks(ctx, "docs", "other.txt", []byte("x y z"), "o1", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	col.records[other.ids[0]] = Record{ID: other.ids[0], Document: other.chunks[0], Metadata: other.metadatas[0]}
+
+	if res, err := s.IngestFileWithOptions(ctx, "docs", "guide.txt", []byte("a b c\nd e f\ng h i"), opts); err != nil || res.Status != "ingested" || res.Replaced != 0 {
+		t.Fatalf("first version: %+v, %v", res, err)
+	}
+	// The unchanged first line keeps its ID; the rest of v1 is deleted
+	res, err := s.IngestFileWithOptions(ctx, "docs", "guide.txt", []byte("a b c\nd e X"), opts)
+	if err != nil || res.Status != "ingested" || res.Chunks != 2 || res.Replaced != 2 {
+		t.Fatalf("second version: %+v, %v", res, err)
+	}
+	if len(col.records) != 3 {
+		t.Errorf("expected other.txt and 2 chunks of guide.txt, got %d records", len(col.records))
+	}
+	for _, r := range col.records {
+		if r.Metadata[DefaultSystemKeys.FileName] == "guide.txt" && !strings.Contains("a b c\nd e X\n", r.Document) {
+			t.Errorf("stale chunk left: %q", r.Document)
+		}
+	}
+	if res, err := s.IngestFileWithOptions(ctx, "docs", "guide.txt", []byte("a b c\nd e X"), opts); err != nil || res.Status != "skipped" {
+		t.Errorf("unchanged version: %+v, %v", res, err)
+	}
+
+	// With chunk dedupe, chunks of the replaced version don't count as stored
+	s.WithChunkDedupe(true)
+	res, err = s.IngestFileWithOptions(ctx, "docs", "guide.txt", []byte("a b c\nx y z"), opts)
+	if err != nil || res.Chunks != 1 || res.DuplicateChunks != 1 || res.Replaced != 1 {
+		t.Fatalf("deduped version: %+v, %v", res, err)
+	}
+	if len(col.records) != 2 {
+		t.Errorf("expected other.txt and the first line of guide.txt, got %d records", len(col.records))
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+var (
+	// ErrReplicaDisabled is returned by replica endpoints when no replica
+	// backend is configured.
+	ErrReplicaDisabled = errors.New("collection replicas are not configured")
+	// ErrNotReplicated is returned for a collection without a replica.
+	ErrNotReplicated = errors.New("collection is not replicated")
+)
+
+const (
+	// replicaProbeInterval is how often the primary's health is probed.
+	replicaProbeInterval = 10 * time.Second
+	// replicaProbeTimeout bounds each heartbeat against the primary.
+	replicaProbeTimeout = 2 * time.Second
+)
+
+// ReplicaCheck reports a consistency check of a collection's copy on another
+// server: records missing or different there are copied from the primary,
+// and records only the copy holds are removed.
+type ReplicaCheck struct {
+	Collection string    `json:"collection"`
+	Records    int       `json:"records"`
+	Copied     int       `json:"copied"`
+	Removed    int       `json:"removed"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ReplicaStatus reports the primary's health, how many searches failed over
+// to a replica, and the last check of each replicated collection.
+type ReplicaStatus struct {
+	PrimaryHealthy bool           `json:"primary_healthy"`
+	Failovers      int64          `json:"failovers"`
+	Collections    []ReplicaCheck `json:"collections"`
+}
+
+// ReplicaService keeps warm copies of collections on a second Chroma server.
+// Replicas are brought up to date after every change to their collection
+// through the IngestService and by periodic consistency checks, and serve
+// searches while the primary is unhealthy (see IngestService.WithReplica).
+type ReplicaService struct {
+	primary     chroma.Client
+	replica     chroma.Client
+	collections map[string]bool
+	unhealthy   atomic.Bool
+	failovers   atomic.Int64
+	mu          sync.Mutex // serializes checks
+
+	statusMu sync.Mutex
+	pending  map[string]bool
+	last     map[string]ReplicaCheck
+}
+
+// NewReplicaService replicates the named collections from primary to replica.
+func NewReplicaService(primary, replica chroma.Client, collections []string) *ReplicaService {
+	s := &ReplicaService{primary: primary, replica: replica, collections: map[string]bool{}, pending: map[string]bool{}, last: map[string]ReplicaCheck{}}
+	for _, c := range collections {
+		s.collections[c] = true
+	}
+	return s
+}
+
+// Replicates reports whether collection has a replica.
+func (s *ReplicaService) Replicates(collection string) bool {
+	return s != nil && s.collections[collection]
+}
+
+// Status reports the primary's health and the last check of every
+// replicated collection.
+func (s *ReplicaService) Status() ReplicaStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	status := ReplicaStatus{PrimaryHealthy: !s.unhealthy.Load(), Failovers: s.failovers.Load(), Collections: []ReplicaCheck{}}
+	for _, name := range s.names() {
+		check, ok := s.last[name]
+		if !ok {
+			check = ReplicaCheck{Collection: name}
+		}
+		status.Collections = append(status.Collections, check)
+	}
+	return status
+}
+
+func (s *ReplicaService) names() []string {
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check brings a collection's replica up to date with the primary.
+func (s *ReplicaService) Check(ctx context.Context, collection string) (*ReplicaCheck, error) {
+	if !s.Replicates(collection) {
+		return nil, fmt.Errorf("%w: %s", ErrNotReplicated, collection)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusMu.Lock()
+	delete(s.pending, collection)
+	s.statusMu.Unlock()
+
+	check, err := copyCollection(ctx, s.primary, s.replica, collection)
+	check.Collection, check.CheckedAt = collection, time.Now().UTC()
+	if err != nil {
+		check.Error = err.Error()
+	}
+	s.statusMu.Lock()
+	s.last[collection] = *check
+	s.statusMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if check.Copied > 0 || check.Removed > 0 {
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"collection": collection,
+			"copied":     check.Copied,
+			"removed":    check.Removed,
+		}).Info("Updated collection replica")
+	}
+	return check, nil
+}
+
+// copyCollection makes collection on to match collection on from.
+func copyCollection(ctx context.Context, from, to chroma.Client, collection string) (*ReplicaCheck, error) {
+	source, err := from.GetCollection(ctx, collection)
+	if err != nil {
+		return &ReplicaCheck{}, fmt.Errorf("get collection %q: %w", collection, err)
+	}
+	records, err := scanRecords(ctx, source, nil, chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings)
+	if err != nil {
+		return &ReplicaCheck{}, err
+	}
+	check := &ReplicaCheck{Records: len(records)}
+
+	target, err := copyTarget(ctx, to, source)
+	if err != nil {
+		return check, err
+	}
+	existing, err := scanRecords(ctx, target, nil)
+	if err != nil {
+		return check, fmt.Errorf("scan copy of %q: %w", collection, err)
+	}
+	held := make(map[string][32]byte, len(existing))
+	for _, r := range existing {
+		held[r.ID] = recordFingerprint(r)
+	}
+
+	var stale []Record
+	for _, r := range records {
+		if sum, ok := held[r.ID]; !ok || sum != recordFingerprint(r) {
+			stale = append(stale, r)
+		}
+		delete(held, r.ID)
+	}
+	if err := writeRecords(ctx, target.Upsert, stale); err != nil {
+		return check, fmt.Errorf("copy records of %q: %w", collection, err)
+	}
+	check.Copied = len(stale)
+
+	extra := make([]chroma.DocumentID, 0, len(held))
+	for id := range held {
+		extra = append(extra, chroma.DocumentID(id))
+	}
+	for start := 0; start < len(extra); start += getPageSize {
+		end := min(start+getPageSize, len(extra))
+		if err := target.Delete(ctx, chroma.WithIDsDelete(extra[start:end]...)); err != nil {
+			return check, fmt.Errorf("delete records from copy of %q: %w", collection, err)
+		}
+		check.Removed = end
+	}
+	return check, nil
+}
+
+// copyTarget gets or creates the copy of source on to, with the same
+// metadata (e.g. distance space).
+func copyTarget(ctx context.Context, to chroma.Client, source chroma.Collection) (chroma.Collection, error) {
+	var createOpts []chroma.CreateCollectionOption
+	if md := collectionMetadataToMap(source.Metadata()); len(md) > 0 {
+		createOpts = append(createOpts, chroma.WithCollectionMetadataCreate(chroma.NewMetadataFromMap(md)))
+	}
+	target, err := to.GetOrCreateCollection(ctx, source.Name(), createOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("get/create copy of %q: %w", source.Name(), err)
+	}
+	return target, nil
+}
+
+// recordFingerprint hashes a record's text and metadata. Embeddings are
+// left out: they follow from the text.
+func recordFingerprint(r Record) [32]byte {
+	md, _ := json.Marshal(r.Metadata) // map keys are sorted
+	return sha256.Sum256(append(append([]byte(r.Document), 0), md...))
+}
+
+// schedule checks collection in the background unless a check is already
+// waiting to start.
+func (s *ReplicaService) schedule(collection string) {
+	s.statusMu.Lock()
+	if s.pending[collection] {
+		s.statusMu.Unlock()
+		return
+	}
+	s.pending[collection] = true
+	s.statusMu.Unlock()
+	go func() {
+		ctx := context.Background()
+		if _, err := s.Check(ctx, collection); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", collection).Warn("Failed to update collection replica")
+		}
+	}()
+}
+
+// drop deletes the replica of a collection deleted from the primary.
+func (s *ReplicaService) drop(ctx context.Context, collection string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.replica.DeleteCollection(ctx, collection); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", collection).Warn("Failed to delete collection replica")
+	}
+	s.statusMu.Lock()
+	delete(s.last, collection)
+	s.statusMu.Unlock()
+}
+
+// Run checks every replica, then probes the primary's health until ctx is
+// done and checks the replicas again each interval; zero interval only
+// probes.
+func (s *ReplicaService) Run(ctx context.Context, interval time.Duration) {
+	for _, name := range s.names() {
+		s.schedule(name)
+	}
+	probe := time.NewTicker(replicaProbeInterval)
+	defer probe.Stop()
+	var checks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		checks = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-probe.C:
+			s.probe(ctx)
+		case <-checks:
+			for _, name := range s.names() {
+				s.schedule(name)
+			}
+		}
+	}
+}
+
+// probe heartbeats the primary and records whether it answered.
+func (s *ReplicaService) probe(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), replicaProbeTimeout)
+	defer cancel()
+	healthy := s.primary.Heartbeat(ctx) == nil
+	if s.unhealthy.Swap(!healthy) == healthy {
+		log := logging.FromContext(ctx)
+		if healthy {
+			log.Info("Primary Chroma server is healthy again; searches use it")
+		} else {
+			log.Warn("Primary Chroma server is unhealthy; replicated collections are searched on the replica")
+		}
+	}
+	return healthy
+}
+
+// serving returns the replica of collection when the primary is known to be
+// unhealthy.
+func (s *ReplicaService) serving(ctx context.Context, collection string) chroma.Collection {
+	if !s.Replicates(collection) || !s.unhealthy.Load() {
+		return nil
+	}
+	return s.replicaCollection(ctx, collection)
+}
+
+// failover returns the replica of collection when a search of the primary
+// failed with err because the primary is down, rather than because of the
+// collection or the query.
+func (s *ReplicaService) failover(ctx context.Context, collection string, err error) chroma.Collection {
+	if !s.Replicates(collection) || ctx.Err() != nil || errors.Is(dimensionError(collection, err), ErrDimensionMismatch) {
+		return nil
+	}
+	if s.probe(ctx) {
+		return nil
+	}
+	return s.replicaCollection(ctx, collection)
+}
+
+func (s *ReplicaService) replicaCollection(ctx context.Context, collection string) chroma.Collection {
+	c, err := s.replica.GetCollection(ctx, collection)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", collection).Error("Failed to get collection replica")
+		return nil
+	}
+	s.failovers.Add(1)
+	markReplica(ctx)
+	return c
+}
+
+type replicaMarkKey struct{}
+
+// withReplicaMark lets the caller learn whether searches made with the
+// returned context were served by a replica.
+func withReplicaMark(ctx context.Context) (context.Context, *atomic.Bool) {
+	served := &atomic.Bool{}
+	return context.WithValue(ctx, replicaMarkKey{}, served), served
+}
+
+func markReplica(ctx context.Context) {
+	if served, _ := ctx.Value(replicaMarkKey{}).(*atomic.Bool); served != nil {
+		served.Store(true)
+	}
+}
+
+// WithReplica searches the replicas r keeps while the primary is unhealthy,
+// and keeps them up to date with changes made through the service.
+func (s *IngestService) WithReplica(r *ReplicaService) *IngestService {
+	s.replica = r
+	s.events.Subscribe(func(e Event) {
+		if !r.Replicates(e.Collection) {
+			return
+		}
+		if deleted, _ := e.Data["collection_deleted"].(bool); deleted {
+			go r.drop(context.Background(), e.Collection)
+			return
+		}
+		r.schedule(e.Collection)
+	}, EventIngested, EventDeleted)
+	return s
+}
+
+// searchCollection returns the collection to search: the primary's, or its
+// replica's when the primary is down.
+func (s *IngestService) searchCollection(ctx context.Context, collectionName string) (chroma.Collection, error) {
+	if replica := s.replica.serving(ctx, collectionName); replica != nil {
+		return replica, nil
+	}
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		if replica := s.replica.failover(ctx, collectionName, err); replica != nil {
+			return replica, nil
+		}
+		return nil, fmt.Errorf("failed to get collection '%s': %w", collectionName, err)
+	}
+	return collection, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+// replicaCollection answers queries with every record it holds.
+type replicaCollection struct {
+	*fileCollection
+}
+
+func (c replicaCollection) Metadata() chroma.CollectionMetadata { return nil }
+
+func (c replicaCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	var ids chroma.DocumentIDs
+	var docs chroma.Documents
+	var metadatas chroma.DocumentMetadatas
+	var distances embeddings.Distances
+	for id, r := range c.records {
+		ids = append(ids, chroma.DocumentID(id))
+		docs = append(docs, chroma.NewTextDocument(r.Document))
+		metadatas = append(metadatas, toDocumentMetadata(r.Metadata))
+		distances = append(distances, 0.1)
+	}
+	return &chroma.QueryResultImpl{IDLists: []chroma.DocumentIDs{ids}, DocumentsLists: []chroma.Documents{docs},
+		MetadatasLists: []chroma.DocumentMetadatas{metadatas}, DistancesLists: []embeddings.Distances{distances}}, nil
+}
+
+// replicaClient serves one collection, or fails every call while down.
+type replicaClient struct {
+	chroma.Client
+	collection *replicaCollection
+	down       *bool
+}
+
+func (c replicaClient) Heartbeat(ctx context.Context) error {
+	if *c.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c replicaClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if *c.down {
+		return nil, errors.New("connection refused")
+	}
+	if c.collection == nil || name != "docs" {
+		return nil, errors.New("collection not found")
+	}
+	return *c.collection, nil
+}
+
+func (c replicaClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	return c.GetCollection(ctx, name)
+}
+
+func (c replicaClient) DeleteCollection(ctx context.Context, name string, opts ...chroma.DeleteCollectionOption) error {
+	if _, err := c.GetCollection(ctx, name); err != nil {
+		return err
+	}
+	clear(c.collection.records)
+	return nil
+}
+
+func TestReplica(t *testing.T) {
+	ctx := context.Background()
+	primary := &replicaCollection{&fileCollection{records: map[string]Record{
+		"a": {ID: "a", Document: "alpha", Embedding: []float32{1}},
+		"b": {ID: "b", Document: "beta", Embedding: []float32{2}},
+	}}}
+	replica := &replicaCollection{&fileCollection{records: map[string]Record{
+		"b": {ID: "b", Document: "stale beta"},
+		"c": {ID: "c", Document: "gone"},
+	}}}
+	var primaryDown, replicaDown bool
+	r := NewReplicaService(replicaClient{collection: primary, down: &primaryDown}, replicaClient{collection: replica, down: &replicaDown}, []string{"docs"})
+
+	check, err := r.Check(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Records != 2 || check.Copied != 2 || check.Removed != 1 || replica.reused != 2 {
+		t.Errorf("expected both records copied with their embeddings and the extra removed, got %+v after %d reused", check, replica.reused)
+	}
+	if got := documents(replica.fileCollection); len(got) != 2 || got[0] != "alpha" || got[1] != "beta" {
+		t.Errorf("expected the replica to match the primary, got %q", got)
+	}
+	if check, err := r.Check(ctx, "docs"); err != nil || check.Copied != 0 || check.Removed != 0 {
+		t.Errorf("expected a consistent replica left alone, got %+v, %v", check, err)
+	}
+	if _, err := r.Check(ctx, "other"); !errors.Is(err, ErrNotReplicated) {
+		t.Errorf("expected ErrNotReplicated, got %v", err)
+	}
+
+	s := NewIngestService(replicaClient{collection: primary, down: &primaryDown}).WithReplica(r)
+	primaryDown = true
+	resp, err := s.SearchWithFallback(ctx, "docs", "alpha beta", 5, nil, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Replica || len(resp.Results) != 2 {
+		t.Errorf("expected the replica to answer while the primary is down, got %+v", resp)
+	}
+	if status := r.Status(); status.PrimaryHealthy || status.Failovers != 1 || len(status.Collections) != 1 || status.Collections[0].Copied != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	primaryDown = false
+	if !r.probe(ctx) || !r.Status().PrimaryHealthy {
+		t.Error("expected the primary healthy again after a probe")
+	}
+	resp, err = s.SearchWithFallback(ctx, "docs", "alpha beta", 5, nil, SearchOptions{})
+	if err != nil || resp.Replica {
+		t.Errorf("expected the primary to answer again, got %+v, %v", resp, err)
+	}
+
+	// A collection missing from a healthy primary is not served from the replica
+	missing := NewIngestService(replicaClient{down: &primaryDown}).WithReplica(r)
+	if _, err := missing.SearchWithFallback(ctx, "docs", "alpha beta", 5, nil, SearchOptions{}); err == nil {
+		t.Error("expected an error for a collection the primary doesn't have")
+	}
+}
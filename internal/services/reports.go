@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// CollectionStatsStore accumulates per-collection daily activity counters.
+type CollectionStatsStore interface {
+	AddCollectionCounters(collection, day string, delta config.CollectionCounters) error
+	CollectionCountersSince(collection, day string) (config.CollectionCounters, error)
+}
+
+// WithCollectionStats records search and ingest outcomes per collection.
+func (s *IngestService) WithCollectionStats(store CollectionStatsStore) *IngestService {
+	s.stats = store
+	return s
+}
+
+func (s *IngestService) recordCounters(ctx context.Context, collection string, delta config.CollectionCounters) {
+	if s.stats == nil {
+		return
+	}
+	if err := s.stats.AddCollectionCounters(collection, time.Now().UTC().Format(time.DateOnly), delta); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("collection", collection).Debug("Failed to record collection stats")
+	}
+}
+
+// ReportStore persists health reports.
+type ReportStore interface {
+	SaveReport(createdAt time.Time, body string) (int64, error)
+	GetReport(id int64) (config.Report, error)
+	ListReports(limit int) ([]config.Report, error)
+}
+
+// CollectionHealth summarizes one collection over a report period.
+type CollectionHealth struct {
+	Collection     string  `json:"collection"`
+	Chunks         int     `json:"chunks"`
+	Growth         *int    `json:"growth,omitempty"` // chunks added since the previous report
+	Searches       int     `json:"searches"`
+	ZeroResultRate float64 `json:"zero_result_rate"`
+	DuplicateRatio float64 `json:"duplicate_ratio"`
+	FailedIngests  int     `json:"failed_ingests"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// HealthReport is a point-in-time health summary of every collection.
+type HealthReport struct {
+	ID          int64              `json:"id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Since       string             `json:"since"` // first UTC day covered by activity counters
+	Collections []CollectionHealth `json:"collections"`
+}
+
+// ReportService compiles, stores and publishes collection health reports.
+type ReportService struct {
+	ingest     *IngestService
+	store      ReportStore
+	stats      CollectionStatsStore
+	webhookURL string
+	client     *http.Client
+	notifier   Notifier
+}
+
+func NewReportService(ingest *IngestService, store ReportStore, stats CollectionStatsStore, webhookURL string) *ReportService {
+	return &ReportService{ingest: ingest, store: store, stats: stats, webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// WithNotifier sends every generated report to n, e.g. by email.
+func (s *ReportService) WithNotifier(n Notifier) *ReportService {
+	s.notifier = n
+	return s
+}
+
+// Generate compiles a report covering activity since the previous report
+// (or today, for the first one), stores it, posts it to the webhook and
+// sends it to the notifier.
+func (s *ReportService) Generate(ctx context.Context) (*HealthReport, error) {
+	now := time.Now().UTC()
+	report := &HealthReport{CreatedAt: now, Since: now.Format(time.DateOnly), Collections: []CollectionHealth{}}
+	previous := make(map[string]int)
+	if last, err := s.latest(); err != nil {
+		return nil, err
+	} else if last != nil {
+		report.Since = last.CreatedAt.UTC().Format(time.DateOnly)
+		for _, c := range last.Collections {
+			previous[c.Collection] = c.Chunks
+		}
+	}
+
+	names, err := s.ingest.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		report.Collections = append(report.Collections, s.collectionHealth(ctx, name, report.Since, previous))
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if report.ID, err = s.store.SaveReport(now, string(body)); err != nil {
+		return nil, fmt.Errorf("save report: %w", err)
+	}
+	if s.webhookURL != "" {
+		go s.publish(ctx, report)
+	}
+	notify(s.notifier, EventHealthReport, *report)
+	logging.FromContext(ctx).WithFields(logrus.Fields{"report": report.ID, "collections": len(report.Collections)}).Info("Generated health report")
+	return report, nil
+}
+
+func (s *ReportService) collectionHealth(ctx context.Context, name, since string, previous map[string]int) CollectionHealth {
+	h := CollectionHealth{Collection: name}
+	advice, err := s.ingest.Advise(ctx, name)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.Chunks = advice.Chunks
+	h.DuplicateRatio = advice.DuplicateRatio
+	if prev, ok := previous[name]; ok {
+		growth := h.Chunks - prev
+		h.Growth = &growth
+	}
+	counters, err := s.stats.CollectionCountersSince(name, since)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.Searches = counters.Searches
+	h.FailedIngests = counters.IngestFailures
+	if counters.Searches > 0 {
+		h.ZeroResultRate = float64(counters.ZeroResults) / float64(counters.Searches)
+	}
+	return h
+}
+
+func (s *ReportService) latest() (*HealthReport, error) {
+	reports, err := s.List(1)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// List returns the newest reports first.
+func (s *ReportService) List(limit int) ([]HealthReport, error) {
+	stored, err := s.store.ListReports(limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]HealthReport, 0, len(stored))
+	for _, r := range stored {
+		var report HealthReport
+		if err := json.Unmarshal([]byte(r.Body), &report); err != nil {
+			return nil, fmt.Errorf("decode report %d: %w", r.ID, err)
+		}
+		report.ID = r.ID
+		out = append(out, report)
+	}
+	return out, nil
+}
+
+func (s *ReportService) Get(id int64) (*HealthReport, error) {
+	r, err := s.store.GetReport(id)
+	if err != nil {
+		return nil, err
+	}
+	var report HealthReport
+	if err := json.Unmarshal([]byte(r.Body), &report); err != nil {
+		return nil, fmt.Errorf("decode report %d: %w", r.ID, err)
+	}
+	report.ID = r.ID
+	return &report, nil
+}
+
+func (s *ReportService) publish(ctx context.Context, report *HealthReport) {
+	body, _ := json.Marshal(report)
+	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Failed to publish health report")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logging.FromContext(ctx).WithField("status", resp.Status).Error("Health report webhook rejected report")
+	}
+}
+
+// RunScheduler generates a report whenever interval has elapsed since the
+// latest one, checking every tick until ctx is canceled.
+func (s *ReportService) RunScheduler(ctx context.Context, interval, tick time.Duration) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			last, err := s.latest()
+			if err != nil {
+				logging.FromContext(ctx).WithError(err).Warn("Failed to load latest health report")
+				continue
+			}
+			if last != nil && now.Sub(last.CreatedAt) < interval {
+				continue
+			}
+			if _, err := s.Generate(ctx); err != nil {
+				logging.FromContext(ctx).WithError(err).Error("Scheduled health report failed")
+			}
+		}
+	}
+}
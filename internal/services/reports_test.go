@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// memReports is an in-memory ReportStore and CollectionStatsStore.
+type memReports struct {
+	reports  []config.Report
+	counters map[string]config.CollectionCounters
+}
+
+func (m *memReports) SaveReport(createdAt time.Time, body string) (int64, error) {
+	m.reports = append(m.reports, config.Report{ID: int64(len(m.reports) + 1), CreatedAt: createdAt, Body: body})
+	return int64(len(m.reports)), nil
+}
+
+func (m *memReports) GetReport(id int64) (config.Report, error) {
+	return m.reports[id-1], nil
+}
+
+func (m *memReports) ListReports(limit int) ([]config.Report, error) {
+	var out []config.Report
+	for i := len(m.reports) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, m.reports[i])
+	}
+	return out, nil
+}
+
+func (m *memReports) AddCollectionCounters(collection, day string, delta config.CollectionCounters) error {
+	return nil
+}
+
+func (m *memReports) CollectionCountersSince(collection, day string) (config.CollectionCounters, error) {
+	return m.counters[collection], nil
+}
+
+// reportClient lists and serves one collection, docs.
+type reportClient struct {
+	fileClient
+}
+
+func (c reportClient) ListCollections(ctx context.Context, opts ...chroma.ListCollectionsOption) ([]chroma.Collection, error) {
+	return []chroma.Collection{listedCollection{name: "docs"}}, nil
+}
+
+func TestReportNotifies(t *testing.T) {
+	col := &fileCollection{records: map[string]Record{
+		"a": {ID: "a", Document: "alpha", Metadata: map[string]interface{}{"file_name": "a.md", "file_md5": "a"}},
+		"b": {ID: "b", Document: "beta", Metadata: map[string]interface{}{"file_name": "b.md", "file_md5": "b"}},
+	}}
+	store := &memReports{counters: map[string]config.CollectionCounters{"docs": {Searches: 4, ZeroResults: 1, IngestFailures: 2}}}
+	n, err := NewSMTPNotifier(SMTPConfig{Host: "mail.local", From: "forge@local", To: []string{"ops@local"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan string, 2)
+	n.send = func(_ string, _ smtp.Auth, _ string, _ []string, m []byte) error {
+		sent <- string(m)
+		return nil
+	}
+	s := NewReportService(NewIngestService(reportClient{fileClient{collection: col}}), store, store, "").WithNotifier(n)
+
+	ctx := context.Background()
+	if _, err := s.Generate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	col.records["c"] = Record{ID: "c", Document: "gamma", Metadata: map[string]interface{}{"file_name": "c.md", "file_md5": "c"}}
+	report, err := s.Generate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Collections) != 1 || report.Collections[0].Chunks != 3 || *report.Collections[0].Growth != 1 {
+		t.Fatalf("unexpected report %+v", report.Collections)
+	}
+
+	// Notifications are sent in the background, in no particular order
+	var second string
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-sent:
+			if strings.Contains(msg, "Subject: [forge] Health report 2: 1 collection(s)\r\n") {
+				second = msg
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected both reports to be mailed")
+		}
+	}
+	if !strings.Contains(second, "- docs: 3 chunks (1 since the last report), 4 searches, 0.25 zero-result rate, 0.00 duplicate ratio, 2 failed ingests") {
+		t.Errorf("unexpected second report:\n%s", second)
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+	defaultef "github.com/forrest321/chroma-go/pkg/embeddings/default_ef"
+)
+
+var (
+	// ErrNoEmbedder is returned by Embed when no embedding function is configured.
+	ErrNoEmbedder = errors.New("embedding endpoint is not configured")
+	// ErrInvalidRetrievalQuery is returned for an empty query or a bad date.
+	ErrInvalidRetrievalQuery = errors.New("invalid retrieval query")
+)
+
+const (
+	// defaultRetrievalTopK is the retrieval plugin's default result count.
+	defaultRetrievalTopK = 3
+	// dateOverfetch is how many candidates per requested result are fetched
+	// when a date range drops some after the query.
+	dateOverfetch = 3
+)
+
+// RetrievalQuery is a query in the OpenAI retrieval plugin protocol.
+type RetrievalQuery struct {
+	Query  string           `json:"query"`
+	Filter *RetrievalFilter `json:"filter,omitempty"`
+	TopK   int              `json:"top_k,omitempty"`
+}
+
+// RetrievalFilter narrows a retrieval query. document_id is a file's
+// content hash, source_id the source that ingested it and author the
+// "author" user metadata; dates bound the ingest time. source is ignored:
+// everything in Forge is a file.
+type RetrievalFilter struct {
+	DocumentID string `json:"document_id,omitempty"`
+	Source     string `json:"source,omitempty"`
+	SourceID   string `json:"source_id,omitempty"`
+	Author     string `json:"author,omitempty"`
+	StartDate  string `json:"start_date,omitempty"`
+	EndDate    string `json:"end_date,omitempty"`
+}
+
+// RetrievalMetadata is a chunk's metadata in the retrieval plugin's terms,
+// plus where it is stored in Forge.
+type RetrievalMetadata struct {
+	Source     string `json:"source"`
+	SourceID   string `json:"source_id,omitempty"`
+	URL        string `json:"url,omitempty"`
+	CreatedAt  string `json:"created_at,omitempty"`
+	Author     string `json:"author,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+}
+
+// RetrievalChunk is one result; Score is higher for closer matches.
+type RetrievalChunk struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata RetrievalMetadata `json:"metadata"`
+	Score    float64           `json:"score"`
+}
+
+// RetrievalQueryResult holds the results of one query.
+type RetrievalQueryResult struct {
+	Query   string           `json:"query"`
+	Results []RetrievalChunk `json:"results"`
+}
+
+// dateRange parses the filter's start and end dates (RFC 3339 or
+// YYYY-MM-DD, an end date including the whole day) as Unix seconds; zero
+// means unbounded.
+func (f *RetrievalFilter) dateRange() (int64, int64, error) {
+	if f == nil {
+		return 0, 0, nil
+	}
+	parse := func(field, value string, day time.Duration) (int64, error) {
+		if value == "" {
+			return 0, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.Unix(), nil
+		}
+		if t, err := time.Parse(time.DateOnly, value); err == nil {
+			return t.Add(day).Unix(), nil
+		}
+		return 0, fmt.Errorf("%w: %s must be an RFC 3339 time or a date, got %q", ErrInvalidRetrievalQuery, field, value)
+	}
+	start, err := parse("start_date", f.StartDate, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parse("end_date", f.EndDate, 24*time.Hour-time.Second)
+	return start, end, err
+}
+
+// retrievalWhere converts the filter's equality fields to a search filter.
+func (s *IngestService) retrievalWhere(f *RetrievalFilter) map[string]interface{} {
+	filter := map[string]interface{}{}
+	if f == nil {
+		return filter
+	}
+	for key, value := range map[string]string{
+		s.keys.FileMD5:                f.DocumentID,
+		s.keys.SourceID:               f.SourceID,
+		userMetadataPrefix + "author": f.Author,
+	} {
+		if value != "" {
+			filter[key] = value
+		}
+	}
+	return filter
+}
+
+// RetrievalSearch answers retrieval plugin queries over the collections.
+// Each query's top_k defaults to 3 and is capped at the maximum k (see
+// WithSearchLimits).
+func (s *IngestService) RetrievalSearch(ctx context.Context, collections []string, queries []RetrievalQuery) ([]RetrievalQueryResult, error) {
+	out := make([]RetrievalQueryResult, 0, len(queries))
+	for _, q := range queries {
+		if q.Query == "" {
+			return nil, fmt.Errorf("%w: query is required", ErrInvalidRetrievalQuery)
+		}
+		start, end, err := q.Filter.dateRange()
+		if err != nil {
+			return nil, err
+		}
+		k := q.TopK
+		if k <= 0 {
+			k = defaultRetrievalTopK
+		}
+		k = min(k, s.searchLimits.MaxK)
+		n := k
+		if start != 0 || end != 0 {
+			n *= dateOverfetch
+		}
+		resp, err := s.MultiSearch(ctx, collections, q.Query, n, s.retrievalWhere(q.Filter), SearchOptions{})
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := s.resultMetadata(ctx, resp.Results)
+		if err != nil {
+			return nil, err
+		}
+		result := RetrievalQueryResult{Query: q.Query, Results: []RetrievalChunk{}}
+		for _, r := range resp.Results {
+			md := metadata[r.Collection+"/"+r.ID]
+			created := toInt64(md[s.keys.Timestamp])
+			if (start != 0 && created < start) || (end != 0 && created > end) {
+				continue
+			}
+			result.Results = append(result.Results, s.retrievalChunk(r, md))
+			if len(result.Results) == k {
+				break
+			}
+		}
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+// resultMetadata fetches the stored metadata of search results, keyed by
+// collection and ID.
+func (s *IngestService) resultMetadata(ctx context.Context, results []SearchResult) (map[string]map[string]interface{}, error) {
+	ids := map[string][]chroma.DocumentID{}
+	for _, r := range results {
+		ids[r.Collection] = append(ids[r.Collection], chroma.DocumentID(r.ID))
+	}
+	out := map[string]map[string]interface{}{}
+	for name, docIDs := range ids {
+		collection, err := s.chromaDB.GetCollection(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get collection '%s': %w", name, err)
+		}
+		res, err := collection.Get(ctx, chroma.WithIDsGet(docIDs...), chroma.WithIncludeGet(chroma.IncludeMetadatas))
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range toRecords(res) {
+			out[name+"/"+rec.ID] = rec.Metadata
+		}
+	}
+	return out, nil
+}
+
+// retrievalChunk converts a search result and its stored metadata.
+func (s *IngestService) retrievalChunk(r SearchResult, md map[string]interface{}) RetrievalChunk {
+	str := func(key string) string {
+		v, _ := md[key].(string)
+		return v
+	}
+	meta := RetrievalMetadata{
+		Source:     "file",
+		SourceID:   str(s.keys.SourceID),
+		Author:     str(userMetadataPrefix + "author"),
+		DocumentID: str(s.keys.FileMD5),
+		Collection: r.Collection,
+		FileName:   str(s.keys.FileName),
+	}
+	if ts := toInt64(md[s.keys.Timestamp]); ts > 0 {
+		meta.CreatedAt = time.Unix(ts, 0).UTC().Format(time.RFC3339)
+	}
+	score := float64(r.Relevance)
+	if r.Score > 0 {
+		score = float64(r.Score)
+	}
+	return RetrievalChunk{ID: r.ID, Text: r.Document, Metadata: meta, Score: score}
+}
+
+// WithEmbedder sets the embedding function served by Embed and the model
+// name reported for it.
+func (s *IngestService) WithEmbedder(ef embeddings.EmbeddingFunction, model string) *IngestService {
+	s.embedder = ef
+	s.embedderModel = model
+	return s
+}
+
+// EmbeddingModel names the model behind Embed.
+func (s *IngestService) EmbeddingModel() string {
+	return s.embedderModel
+}
+
+// Embed returns a vector for each text with the configured embedding
+// function, the same one that embeds collections by default.
+func (s *IngestService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if s.embedder == nil {
+		return nil, ErrNoEmbedder
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Embed)
+	defer cancel()
+	embs, err := s.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	s.costs.embedded(ctx, "", texts...)
+	out := make([][]float32, len(embs))
+	for i, e := range embs {
+		out[i] = e.ContentAsFloat32()
+	}
+	return out, nil
+}
+
+// DefaultEmbedder is the embedding function the Chroma client embeds
+// collections with (all-MiniLM-L6-v2 on the ONNX runtime), created on first
+// use.
+func DefaultEmbedder() embeddings.EmbeddingFunction {
+	return LazyEmbedder(func() (embeddings.EmbeddingFunction, error) {
+		ef, _, err := defaultef.NewDefaultEmbeddingFunction()
+		return ef, err
+	})
+}
+
+// LazyEmbedder creates its embedding function on first use, so e.g. the
+// ONNX runtime is only loaded once embeddings are requested. A failed
+// creation is retried on the next call.
+func LazyEmbedder(create func() (embeddings.EmbeddingFunction, error)) embeddings.EmbeddingFunction {
+	return &lazyEmbedder{create: create}
+}
+
+type lazyEmbedder struct {
+	mu     sync.Mutex
+	create func() (embeddings.EmbeddingFunction, error)
+	ef     embeddings.EmbeddingFunction
+}
+
+func (l *lazyEmbedder) get() (embeddings.EmbeddingFunction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ef == nil {
+		ef, err := l.create()
+		if err != nil {
+			return nil, fmt.Errorf("create embedding function: %w", err)
+		}
+		l.ef = ef
+	}
+	return l.ef, nil
+}
+
+func (l *lazyEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([]embeddings.Embedding, error) {
+	ef, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return ef.EmbedDocuments(ctx, texts)
+}
+
+func (l *lazyEmbedder) EmbedQuery(ctx context.Context, text string) (embeddings.Embedding, error) {
+	ef, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return ef.EmbedQuery(ctx, text)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+func TestRetrievalFilter(t *testing.T) {
+	s := NewIngestService(nil)
+	got := s.retrievalWhere(&RetrievalFilter{DocumentID: "abc", Author: "ann", Source: "email"})
+	if want := map[string]interface{}{"file_md5": "abc", "user_author": "ann"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	start, end, err := (&RetrievalFilter{StartDate: "2026-03-01", EndDate: "2026-03-01"}).dateRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An end date includes its whole day
+	if end-start != 24*60*60-1 {
+		t.Errorf("unexpected range %d-%d", start, end)
+	}
+	if _, _, err := (&RetrievalFilter{EndDate: "yesterday"}).dateRange(); !errors.Is(err, ErrInvalidRetrievalQuery) {
+		t.Errorf("expected a bad date to be rejected, got %v", err)
+	}
+	if _, err := s.RetrievalSearch(context.Background(), []string{"docs"}, []RetrievalQuery{{Query: ""}}); !errors.Is(err, ErrInvalidRetrievalQuery) {
+		t.Errorf("expected an empty query to be rejected, got %v", err)
+	}
+}
+
+func TestRetrievalChunk(t *testing.T) {
+	s := NewIngestService(nil)
+	r := SearchResult{ID: "c1", Document: "text", Distance: 1, Relevance: 0.5, Collection: "docs"}
+	md := map[string]interface{}{"file_md5": "abc", "file_name": "a.md", "timestamp": int64(1772366400), "source_id": "s1"}
+	got := s.retrievalChunk(r, md)
+	want := RetrievalChunk{ID: "c1", Text: "text", Score: 0.5, Metadata: RetrievalMetadata{
+		Source: "file", SourceID: "s1", CreatedAt: "2026-03-01T12:00:00Z", DocumentID: "abc", Collection: "docs", FileName: "a.md",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+type stubEmbedder struct{ calls int }
+
+func (e *stubEmbedder) EmbedDocuments(_ context.Context, texts []string) ([]embeddings.Embedding, error) {
+	e.calls++
+	out := make([]embeddings.Embedding, len(texts))
+	for i, text := range texts {
+		out[i] = embeddings.NewEmbeddingFromFloat32([]float32{float32(len(text))})
+	}
+	return out, nil
+}
+
+func (e *stubEmbedder) EmbedQuery(ctx context.Context, text string) (embeddings.Embedding, error) {
+	out, err := e.EmbedDocuments(ctx, []string{text})
+	return out[0], err
+}
+
+func TestEmbed(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewIngestService(nil).Embed(ctx, []string{"a"}); !errors.Is(err, ErrNoEmbedder) {
+		t.Errorf("expected ErrNoEmbedder, got %v", err)
+	}
+
+	stub := &stubEmbedder{}
+	created, fail := 0, true
+	lazy := LazyEmbedder(func() (embeddings.EmbeddingFunction, error) {
+		created++
+		if fail {
+			return nil, errors.New("no runtime")
+		}
+		return stub, nil
+	})
+	s := NewIngestService(nil).WithEmbedder(lazy, "test-model")
+	if _, err := s.Embed(ctx, []string{"a"}); err == nil {
+		t.Fatal("expected the creation error")
+	}
+	// A failed creation is retried, and a successful one kept
+	fail = false
+	for range 2 {
+		got, err := s.Embed(ctx, []string{"a", "bcd"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := [][]float32{{1}, {3}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if created != 2 || stub.calls != 2 || s.EmbeddingModel() != "test-model" {
+		t.Errorf("unexpected creations %d, calls %d, model %q", created, stub.calls, s.EmbeddingModel())
+	}
+}
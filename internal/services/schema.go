@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// userMetadataPrefix namespaces caller-supplied metadata on ingested chunks.
+const userMetadataPrefix = "user_"
+
+// SystemKeys names the metadata keys the ingest service writes on every
+// chunk. Keys may be renamed and share a namespace prefix so they cannot
+// clash with metadata from other writers of the same Chroma collections.
+type SystemKeys struct {
+	FileMD5    string `json:"file_md5"`
+	FileName   string `json:"file_name"`
+	Timestamp  string `json:"timestamp"`
+	ChunkIndex string `json:"chunk_index"`
+	TokenCount string `json:"token_count"`
+	SourceID   string `json:"source_id"`
+	// ContentHash hashes a chunk's text, for chunk deduplication.
+	ContentHash string `json:"content_hash"`
+}
+
+// DefaultSystemKeys are the historical, un-namespaced key names.
+var DefaultSystemKeys = SystemKeys{
+	FileMD5:     "file_md5",
+	FileName:    "file_name",
+	Timestamp:   "timestamp",
+	ChunkIndex:  "chunk_index",
+	TokenCount:  "token_count",
+	SourceID:    sourceIDKey,
+	ContentHash: "content_hash",
+}
+
+var metadataKeyRe = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// NewSystemKeys builds the system key set from a JSON object of overrides
+// (e.g. {"file_name": "path"}) and an optional namespace prefix, and
+// validates the result.
+func NewSystemKeys(overrides, namespace string) (SystemKeys, error) {
+	keys := DefaultSystemKeys
+	if strings.TrimSpace(overrides) != "" {
+		dec := json.NewDecoder(strings.NewReader(overrides))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&keys); err != nil {
+			return SystemKeys{}, fmt.Errorf("invalid system metadata keys: %w", err)
+		}
+	}
+	if namespace != "" {
+		if !metadataKeyRe.MatchString(namespace) {
+			return SystemKeys{}, fmt.Errorf("invalid system metadata namespace %q", namespace)
+		}
+		for _, f := range keys.fields() {
+			*f.key = namespace + *f.key
+		}
+	}
+	return keys, keys.Validate()
+}
+
+type systemKeyField struct {
+	name string
+	key  *string
+}
+
+func (k *SystemKeys) fields() []systemKeyField {
+	return []systemKeyField{
+		{"file_md5", &k.FileMD5},
+		{"file_name", &k.FileName},
+		{"timestamp", &k.Timestamp},
+		{"chunk_index", &k.ChunkIndex},
+		{"token_count", &k.TokenCount},
+		{"source_id", &k.SourceID},
+		{"content_hash", &k.ContentHash},
+	}
+}
+
+// Names returns the key names, in field order.
+func (k SystemKeys) Names() []string {
+	var out []string
+	for _, f := range k.fields() {
+		out = append(out, *f.key)
+	}
+	return out
+}
+
+// Validate checks that keys are non-empty, unique, use safe characters and
+// don't collide with user, ACL or derived-collection keys.
+func (k SystemKeys) Validate() error {
+	seen := make(map[string]string)
+	for _, f := range k.fields() {
+		key := *f.key
+		switch {
+		case key == "":
+			return fmt.Errorf("system metadata key %s must not be empty", f.name)
+		case !metadataKeyRe.MatchString(key):
+			return fmt.Errorf("system metadata key %s=%q has invalid characters", f.name, key)
+		case strings.HasPrefix(key, userMetadataPrefix):
+			return fmt.Errorf("system metadata key %s=%q must not use the %q prefix", f.name, key, userMetadataPrefix)
+		case strings.HasPrefix(key, aclPrincipalKey) || key == aclRestrictedKey || key == derivedFromKey:
+			return fmt.Errorf("system metadata key %s=%q is reserved", f.name, key)
+		}
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("system metadata keys %s and %s are both %q", other, f.name, key)
+		}
+		seen[key] = f.name
+	}
+	return nil
+}
+
+// WithSystemKeys sets the system metadata key names; validate them first.
+func (s *IngestService) WithSystemKeys(keys SystemKeys) *IngestService {
+	s.keys = keys
+	return s
+}
+
+// MetadataKeyStats describes one metadata key found in a collection.
+type MetadataKeyStats struct {
+	Key   string         `json:"key"`
+	Kind  string         `json:"kind"` // "system", "user", "acl" or "other"
+	Count int            `json:"count"`
+	Types map[string]int `json:"types"`
+}
+
+// CollectionSchema lists the metadata keys present in a collection.
+type CollectionSchema struct {
+	Collection string             `json:"collection"`
+	Records    int                `json:"records"`
+	SystemKeys SystemKeys         `json:"system_keys"`
+	Keys       []MetadataKeyStats `json:"keys"`
+}
+
+// Schema scans a collection's metadata and reports each key's types and counts.
+func (s *IngestService) Schema(ctx context.Context, collectionName string) (*CollectionSchema, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	records, err := scanRecords(ctx, collection, nil, chroma.IncludeMetadatas)
+	if err != nil {
+		return nil, err
+	}
+	schema := describeMetadata(records, s.keys)
+	schema.Collection = collectionName
+	return schema, nil
+}
+
+func describeMetadata(records []Record, keys SystemKeys) *CollectionSchema {
+	system := make(map[string]bool)
+	for _, n := range keys.Names() {
+		system[n] = true
+	}
+	stats := make(map[string]*MetadataKeyStats)
+	for _, r := range records {
+		for k, v := range r.Metadata {
+			st, ok := stats[k]
+			if !ok {
+				st = &MetadataKeyStats{Key: k, Kind: metadataKind(k, system), Types: make(map[string]int)}
+				stats[k] = st
+			}
+			st.Count++
+			st.Types[metadataType(v)]++
+		}
+	}
+	out := &CollectionSchema{Records: len(records), SystemKeys: keys, Keys: make([]MetadataKeyStats, 0, len(stats))}
+	for _, st := range stats {
+		out.Keys = append(out.Keys, *st)
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Key < out.Keys[j].Key })
+	return out
+}
+
+func metadataKind(key string, system map[string]bool) string {
+	switch {
+	case system[key]:
+		return "system"
+	case strings.HasPrefix(key, userMetadataPrefix):
+		return "user"
+	case key == aclRestrictedKey || strings.HasPrefix(key, aclPrincipalKey):
+		return "acl"
+	}
+	return "other"
+}
+
+func metadataType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int, int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	}
+	return "unknown"
+}
@@ -0,0 +1,51 @@
+package services
+
+import "testing"
+
+func TestNewSystemKeys(t *testing.T) {
+	keys, err := NewSystemKeys(`{"file_name": "path"}`, "forge.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.FileName != "forge.path" || keys.FileMD5 != "forge.file_md5" {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+
+	bad := []struct{ overrides, namespace string }{
+		{`{"file_name": "file_md5"}`, ""},
+		{`{"file_name": "user_file"}`, ""},
+		{`{"file_name": "acl:x"}`, ""},
+		{`{"file_name": "has space"}`, ""},
+		{`{"unknown": "x"}`, ""},
+		{"", "bad ns"},
+	}
+	for _, b := range bad {
+		if _, err := NewSystemKeys(b.overrides, b.namespace); err == nil {
+			t.Errorf("expected error for overrides=%s namespace=%q", b.overrides, b.namespace)
+		}
+	}
+}
+
+func TestDescribeMetadata(t *testing.T) {
+	records := []Record{
+		{ID: "1", Metadata: map[string]interface{}{"file_name": "a.md", "chunk_index": int64(0), "user_lang": "en", "acl_restricted": false}},
+		{ID: "2", Metadata: map[string]interface{}{"file_name": "b.md", "chunk_index": int64(1), "user_lang": 3.5}},
+	}
+	schema := describeMetadata(records, DefaultSystemKeys)
+	if schema.Records != 2 || len(schema.Keys) != 4 {
+		t.Fatalf("unexpected schema: %+v", schema)
+	}
+	byKey := make(map[string]MetadataKeyStats)
+	for _, k := range schema.Keys {
+		byKey[k.Key] = k
+	}
+	if k := byKey["file_name"]; k.Kind != "system" || k.Count != 2 || k.Types["string"] != 2 {
+		t.Errorf("file_name: %+v", k)
+	}
+	if k := byKey["user_lang"]; k.Kind != "user" || k.Types["string"] != 1 || k.Types["float"] != 1 {
+		t.Errorf("user_lang: %+v", k)
+	}
+	if k := byKey["acl_restricted"]; k.Kind != "acl" || k.Types["bool"] != 1 {
+		t.Errorf("acl_restricted: %+v", k)
+	}
+}
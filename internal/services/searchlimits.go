@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidK is returned for a result count outside SearchLimits.
+var ErrInvalidK = errors.New("invalid k")
+
+// SearchLimits bound how many results a search returns.
+type SearchLimits struct {
+	// DefaultK is used when a request leaves k unset.
+	DefaultK int `json:"default_k"`
+	// MaxK is the most results any search may ask for.
+	MaxK int `json:"max_k"`
+}
+
+// DefaultSearchLimits is used unless WithSearchLimits overrides it.
+var DefaultSearchLimits = SearchLimits{DefaultK: 5, MaxK: 100}
+
+// WithSearchLimits sets the default and maximum result counts. Non-positive
+// fields keep their defaults, and a default above the maximum is lowered
+// to it.
+func (s *IngestService) WithSearchLimits(l SearchLimits) *IngestService {
+	if l.DefaultK <= 0 {
+		l.DefaultK = DefaultSearchLimits.DefaultK
+	}
+	if l.MaxK <= 0 {
+		l.MaxK = DefaultSearchLimits.MaxK
+	}
+	l.DefaultK = min(l.DefaultK, l.MaxK)
+	s.searchLimits = l
+	return s
+}
+
+// SearchLimits reports the configured result counts.
+func (s *IngestService) SearchLimits() SearchLimits {
+	return s.searchLimits
+}
+
+// SearchK resolves a requested result count: 0 selects the default, and
+// counts outside 1..MaxK return ErrInvalidK.
+func (s *IngestService) SearchK(k int) (int, error) {
+	switch {
+	case k == 0:
+		return s.searchLimits.DefaultK, nil
+	case k < 0 || k > s.searchLimits.MaxK:
+		return 0, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidK, s.searchLimits.MaxK)
+	}
+	return k, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchK(t *testing.T) {
+	s := NewIngestService(nil).WithSearchLimits(SearchLimits{DefaultK: 50, MaxK: 20})
+	if got := s.SearchLimits(); got != (SearchLimits{DefaultK: 20, MaxK: 20}) {
+		t.Errorf("expected the default lowered to the maximum, got %+v", got)
+	}
+	for k, want := range map[int]int{0: 20, 1: 1, 20: 20} {
+		if got, err := s.SearchK(k); err != nil || got != want {
+			t.Errorf("SearchK(%d) = %d, %v; want %d", k, got, err, want)
+		}
+	}
+	for _, k := range []int{-1, 21} {
+		if _, err := s.SearchK(k); !errors.Is(err, ErrInvalidK) {
+			t.Errorf("SearchK(%d): expected ErrInvalidK, got %v", k, err)
+		}
+	}
+	if got := NewIngestService(nil).WithSearchLimits(SearchLimits{}).SearchLimits(); got != DefaultSearchLimits {
+		t.Errorf("expected zero limits to keep the defaults, got %+v", got)
+	}
+}
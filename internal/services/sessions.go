@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// searchSessionTTL is how long a session is remembered after its last search.
+	searchSessionTTL = time.Hour
+	// maxSearchSessions bounds how many sessions are tracked at once.
+	maxSearchSessions = 10000
+	// maxSessionResults bounds the chunk IDs a session suppresses; the
+	// oldest are forgotten first.
+	maxSessionResults = 1000
+)
+
+// ErrSessionNotFound is returned for a search session started with another
+// API key.
+var ErrSessionNotFound = errors.New("search session not found")
+
+// searchSessions remembers which chunks each search session has returned,
+// so later searches in the session exclude them.
+type searchSessions struct {
+	mu    sync.Mutex
+	items map[string]*searchSession
+	now   func() time.Time
+}
+
+type searchSession struct {
+	owner   string // ID of the API key that started the session
+	ids     []string
+	seen    map[string]bool
+	touched time.Time
+}
+
+func newSearchSessions() *searchSessions {
+	return &searchSessions{items: make(map[string]*searchSession), now: time.Now}
+}
+
+// sessionKey scopes a session ID to the caller's principals, so one client
+// cannot read or reset another's session.
+func sessionKey(ctx context.Context, id string) string {
+	principals := append([]string(nil), PrincipalsFromContext(ctx)...)
+	sort.Strings(principals)
+	return strings.Join(principals, ",") + "\x00" + id
+}
+
+// sessionOwner identifies the API key a request authenticated with, or ""
+// without one.
+func sessionOwner(ctx context.Context) string {
+	key, _ := APIKeyFromContext(ctx)
+	return key.ID
+}
+
+// live returns a session that hasn't expired, or ErrSessionNotFound when
+// another key owns it.
+func (s *searchSessions) live(key, owner string) (*searchSession, error) {
+	sess, ok := s.items[key]
+	if !ok || s.now().Sub(sess.touched) > searchSessionTTL {
+		return nil, nil
+	}
+	if sess.owner != owner {
+		return nil, ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+// returned lists the chunk IDs a session has returned, oldest first.
+func (s *searchSessions) returned(key, owner string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, err := s.live(key, owner)
+	if sess == nil {
+		return nil, err
+	}
+	return append([]string(nil), sess.ids...), nil
+}
+
+// remember adds results to a session, starting it for owner if needed. A
+// session owned by another key is left alone.
+func (s *searchSessions) remember(key, owner string, results []SearchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	sess, err := s.live(key, owner)
+	if err != nil {
+		return
+	}
+	if sess == nil {
+		s.evict(now)
+		sess = &searchSession{owner: owner, seen: make(map[string]bool)}
+		s.items[key] = sess
+	}
+	sess.touched = now
+	for _, r := range results {
+		if !sess.seen[r.ID] {
+			sess.seen[r.ID] = true
+			sess.ids = append(sess.ids, r.ID)
+		}
+	}
+	if drop := len(sess.ids) - maxSessionResults; drop > 0 {
+		for _, id := range sess.ids[:drop] {
+			delete(sess.seen, id)
+		}
+		sess.ids = append([]string(nil), sess.ids[drop:]...)
+	}
+}
+
+// evict makes room for a new session: expired sessions go first, then the
+// least recently used one.
+func (s *searchSessions) evict(now time.Time) {
+	if len(s.items) < maxSearchSessions {
+		return
+	}
+	var oldest string
+	for k, sess := range s.items {
+		if now.Sub(sess.touched) > searchSessionTTL {
+			delete(s.items, k)
+		} else if oldest == "" || sess.touched.Before(s.items[oldest].touched) {
+			oldest = k
+		}
+	}
+	if len(s.items) >= maxSearchSessions {
+		delete(s.items, oldest)
+	}
+}
+
+func (s *searchSessions) reset(key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.live(key, owner); err != nil {
+		return err
+	}
+	delete(s.items, key)
+	return nil
+}
+
+// ResetSearchSession forgets what a search session has returned. Only the
+// API key that started the session may reset it.
+func (s *IngestService) ResetSearchSession(ctx context.Context, id string) error {
+	return s.sessions.reset(sessionKey(ctx, id), sessionOwner(ctx))
+}
+
+// sessionSearch runs search with the session's earlier results excluded,
+// then records the new results in the session.
+func (s *IngestService) sessionSearch(ctx context.Context, id string, opts SearchOptions, search func(SearchOptions) (*SearchResponse, error)) (*SearchResponse, error) {
+	key, owner := sessionKey(ctx, id), sessionOwner(ctx)
+	returned, err := s.sessions.returned(key, owner)
+	if err != nil {
+		return nil, err
+	}
+	opts.Exclude.IDs = append(append([]string(nil), opts.Exclude.IDs...), returned...)
+	resp, err := search(opts)
+	if err != nil {
+		return nil, err
+	}
+	s.sessions.remember(key, owner, resp.Results)
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func TestSessionSearch(t *testing.T) {
+	s := &IngestService{sessions: newSearchSessions()}
+	now := time.Unix(0, 0)
+	s.sessions.now = func() time.Time { return now }
+
+	var excluded []string
+	search := func(ids ...string) {
+		t.Helper()
+		_, err := s.sessionSearch(context.Background(), "agent", SearchOptions{Exclude: Exclusion{IDs: []string{"x"}}}, func(opts SearchOptions) (*SearchResponse, error) {
+			excluded = opts.Exclude.IDs
+			resp := &SearchResponse{}
+			for _, id := range ids {
+				resp.Results = append(resp.Results, SearchResult{ID: id})
+			}
+			return resp, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search("a", "b")
+	search("b", "c")
+	if want := []string{"x", "a", "b"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("second search excluded %v, want %v", excluded, want)
+	}
+	search()
+	if want := []string{"x", "a", "b", "c"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("third search excluded %v, want %v", excluded, want)
+	}
+
+	// Other principals do not share the session
+	other := WithPrincipals(context.Background(), []string{"bob"})
+	if ids, err := s.sessions.returned(sessionKey(other, "agent"), ""); ids != nil || err != nil {
+		t.Errorf("expected no results for another caller, got %v, %v", ids, err)
+	}
+
+	// Nor do other API keys, which cannot read or reset it
+	keyed := WithAPIKey(context.Background(), config.APIKey{ID: "k2"})
+	if _, err := s.sessionSearch(keyed, "agent", SearchOptions{}, func(SearchOptions) (*SearchResponse, error) {
+		return &SearchResponse{}, nil
+	}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected another key's search refused, got %v", err)
+	}
+	if err := s.ResetSearchSession(keyed, "agent"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected another key's reset refused, got %v", err)
+	}
+
+	now = now.Add(searchSessionTTL + time.Second)
+	search()
+	if want := []string{"x"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("expired session excluded %v, want %v", excluded, want)
+	}
+
+	for i := 0; i < maxSessionResults+5; i++ {
+		s.sessions.remember(sessionKey(context.Background(), "agent"), "", []SearchResult{{ID: fmt.Sprint(i)}})
+	}
+	ids, _ := s.sessions.returned(sessionKey(context.Background(), "agent"), "")
+	if len(ids) != maxSessionResults || ids[0] != "5" {
+		t.Errorf("expected the latest %d IDs, got %d starting at %s", maxSessionResults, len(ids), ids[0])
+	}
+
+	if err := s.ResetSearchSession(context.Background(), "agent"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := s.sessions.returned(sessionKey(context.Background(), "agent"), ""); ids != nil {
+		t.Errorf("expected reset session to be empty, got %v", ids)
+	}
+}
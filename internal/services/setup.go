@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/db"
+)
+
+var (
+	// ErrInvalidSetup is returned for an unusable setup request.
+	ErrInvalidSetup = errors.New("invalid setup")
+	// ErrSetupCheck is returned when Chroma or a provider fails its test;
+	// nothing is written.
+	ErrSetupCheck = errors.New("setup check failed")
+)
+
+// setupCompletedKey marks a config database written by the setup flow.
+const setupCompletedKey = "setup_completed"
+
+// chromaCandidates are probed, after the configured URL, for a local Chroma.
+var chromaCandidates = []string{"http://localhost:8000", "http://127.0.0.1:8000", "http://localhost:8001"}
+
+// SetupStore reads and writes config values.
+type SetupStore interface {
+	GetAll() (config.Values, error)
+	Get(key string) (string, error)
+	Set(key, value string) error
+}
+
+// SetupChroma is a connection to a Chroma server under test.
+type SetupChroma interface {
+	ChromaProber
+	Client() chroma.Client
+	Close() error
+}
+
+// ChromaCandidate is the outcome of probing one Chroma URL.
+type ChromaCandidate struct {
+	URL           string   `json:"url"`
+	Reachable     bool     `json:"reachable"`
+	Compatible    bool     `json:"compatible"`
+	ServerVersion string   `json:"server_version,omitempty"`
+	Problems      []string `json:"problems,omitempty"`
+}
+
+// SetupStatus describes whether setup has run and what it found.
+type SetupStatus struct {
+	Completed      bool              `json:"completed"`
+	ChromaURL      string            `json:"chroma_url"`
+	CollectionName string            `json:"collection_name"`
+	Candidates     []ChromaCandidate `json:"candidates"`
+	// Detected is the first compatible candidate, the default for Apply.
+	Detected string `json:"detected,omitempty"`
+}
+
+// SetupRequest is the configuration to validate and write. Empty fields
+// keep the detected Chroma and the configured collection; the LLM is
+// optional.
+type SetupRequest struct {
+	ChromaURL      string `json:"chroma_url"`
+	CollectionName string `json:"collection_name"`
+	LLMURL         string `json:"llm_url,omitempty"`
+	LLMAPIKey      string `json:"llm_api_key,omitempty"`
+	LLMModel       string `json:"llm_model,omitempty"`
+}
+
+// SetupResult reports the checks run by Apply and whether the running
+// backend must restart to use the new config.
+type SetupResult struct {
+	ChromaURL       string            `json:"chroma_url"`
+	Collection      string            `json:"collection"`
+	Checks          []DiagnosticCheck `json:"checks"`
+	RestartRequired bool              `json:"restart_required"`
+}
+
+// SetupService runs the guided first-run setup.
+type SetupService struct {
+	store   SetupStore
+	running config.Values
+	connect func(url string) (SetupChroma, error)
+}
+
+// NewSetupService builds the setup flow for a backend started with running.
+func NewSetupService(store SetupStore, running config.Values) *SetupService {
+	return &SetupService{store: store, running: running, connect: func(u string) (SetupChroma, error) { return db.NewChromaDB(u) }}
+}
+
+// Completed reports whether setup has run. Config databases from before
+// the setup flow, which already hold a chroma_url, count as set up.
+func (s *SetupService) Completed() (bool, error) {
+	for _, key := range []string{setupCompletedKey, "chroma_url"} {
+		v, err := s.store.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if v != "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Status probes the configured Chroma URL and the usual local ones.
+func (s *SetupService) Status(ctx context.Context) (*SetupStatus, error) {
+	completed, err := s.Completed()
+	if err != nil {
+		return nil, err
+	}
+	vals, err := s.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	status := &SetupStatus{Completed: completed, ChromaURL: vals.ChromaURL, CollectionName: vals.CollectionName}
+	seen := make(map[string]bool)
+	for _, u := range append([]string{vals.ChromaURL}, chromaCandidates...) {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		c := s.probe(ctx, u)
+		status.Candidates = append(status.Candidates, c)
+		if c.Compatible && status.Detected == "" {
+			status.Detected = u
+		}
+	}
+	return status, nil
+}
+
+func (s *SetupService) probe(ctx context.Context, u string) ChromaCandidate {
+	c := ChromaCandidate{URL: u}
+	conn, err := s.connect(u)
+	if err != nil {
+		c.Problems = []string{err.Error()}
+		return c
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	compat := conn.DetectCompatibility(ctx)
+	c.Reachable = compat.API != "unknown"
+	c.Compatible = compat.Compatible
+	c.ServerVersion = compat.ServerVersion
+	c.Problems = compat.Problems
+	return c
+}
+
+// Apply tests the requested Chroma (and embedding through it) and the LLM,
+// creates the default collection and writes the config. On a failed check
+// it returns ErrSetupCheck with the result, and writes nothing.
+func (s *SetupService) Apply(ctx context.Context, req SetupRequest) (*SetupResult, error) {
+	vals, err := s.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if req.ChromaURL == "" {
+		status, err := s.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if status.Detected == "" {
+			return nil, fmt.Errorf("%w: no Chroma server detected; set chroma_url", ErrInvalidSetup)
+		}
+		req.ChromaURL = status.Detected
+	}
+	if u, err := url.Parse(req.ChromaURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: chroma_url must be an http(s) URL", ErrInvalidSetup)
+	}
+	if req.CollectionName == "" {
+		req.CollectionName = vals.CollectionName
+	}
+	if err := validateCollectionName(req.CollectionName); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSetup, err)
+	}
+	generator, err := NewGenerator(LLMConfig{URL: req.LLMURL, APIKey: req.LLMAPIKey, Model: req.LLMModel})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSetup, err)
+	}
+
+	result := &SetupResult{ChromaURL: req.ChromaURL, Collection: req.CollectionName}
+	failed := false
+	check := func(name string, run func() (string, error)) {
+		started := time.Now()
+		c := DiagnosticCheck{Name: name, Status: DiagnosticPass}
+		detail, err := run()
+		c.Detail = detail
+		if err != nil {
+			c.Status, c.Detail, failed = DiagnosticFail, err.Error(), true
+		}
+		c.Duration = time.Since(started).Round(time.Millisecond).String()
+		result.Checks = append(result.Checks, c)
+	}
+
+	conn, err := s.connect(req.ChromaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSetup, err)
+	}
+	defer conn.Close()
+	checkCtx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	check("chroma", func() (string, error) {
+		compat := conn.DetectCompatibility(checkCtx)
+		if !compat.Compatible {
+			return "", fmt.Errorf("not a compatible Chroma server: %v", compat.Problems)
+		}
+		return fmt.Sprintf("version %s (%s API)", compat.ServerVersion, compat.API), nil
+	})
+	if !failed {
+		check("embedding", func() (string, error) {
+			return fmt.Sprintf("%s/%s embedded a sample", vals.EmbeddingProvider, vals.EmbeddingModel), probeEmbedding(checkCtx, conn.Client())
+		})
+	}
+	if generator != nil {
+		check("llm", func() (string, error) {
+			if _, _, err := generator.Generate(checkCtx, "Reply with OK.", "ping", GenerationParams{}); err != nil {
+				return "", err
+			}
+			return req.LLMModel + " answered a sample prompt", nil
+		})
+	}
+	if failed {
+		return result, ErrSetupCheck
+	}
+	if _, err := conn.Client().GetOrCreateCollection(ctx, req.CollectionName); err != nil {
+		return nil, fmt.Errorf("create default collection: %w", err)
+	}
+
+	settings := [][2]string{{"chroma_url", req.ChromaURL}, {"collection_name", req.CollectionName}}
+	if generator != nil {
+		settings = append(settings, [2]string{"llm_url", req.LLMURL}, [2]string{"llm_api_key", req.LLMAPIKey}, [2]string{"llm_model", req.LLMModel})
+	}
+	for _, kv := range append(settings, [2]string{setupCompletedKey, "true"}) {
+		if err := s.store.Set(kv[0], kv[1]); err != nil {
+			return nil, fmt.Errorf("write %s: %w", kv[0], err)
+		}
+	}
+	result.RestartRequired = req.ChromaURL != s.running.ChromaURL || req.CollectionName != s.running.CollectionName ||
+		(generator != nil && (req.LLMURL != s.running.LLMURL || req.LLMModel != s.running.LLMModel || req.LLMAPIKey != s.running.LLMAPIKey))
+	return result, nil
+}
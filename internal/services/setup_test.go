@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// mapConfig is a SetupStore over explicitly set keys.
+type mapConfig map[string]string
+
+func (m mapConfig) Get(key string) (string, error) { return m[key], nil }
+func (m mapConfig) Set(key, value string) error    { m[key] = value; return nil }
+
+func (m mapConfig) GetAll() (config.Values, error) {
+	vals := config.Values{ChromaURL: "http://localhost:8000", CollectionName: "default"}
+	if v := m["chroma_url"]; v != "" {
+		vals.ChromaURL = v
+	}
+	if v := m["collection_name"]; v != "" {
+		vals.CollectionName = v
+	}
+	return vals, nil
+}
+
+type fakeSetupChroma struct {
+	fixedProber
+	client *probeClient
+}
+
+func (f fakeSetupChroma) Client() chroma.Client { return f.client }
+func (f fakeSetupChroma) Close() error          { return nil }
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	store := mapConfig{}
+	client := &probeClient{memClient{collections: map[string]*memCollection{}}}
+	s := NewSetupService(store, config.Values{ChromaURL: "http://localhost:8000", CollectionName: "default"})
+	// Only the second candidate runs a compatible Chroma
+	s.connect = func(u string) (SetupChroma, error) {
+		if u == "http://127.0.0.1:8000" {
+			return fakeSetupChroma{fixedProber{API: "v2", ServerVersion: "1.0.0", Compatible: true}, client}, nil
+		}
+		return fakeSetupChroma{fixedProber{API: "unknown"}, client}, nil
+	}
+
+	if done, _ := s.Completed(); done {
+		t.Fatal("expected a fresh config to need setup")
+	}
+	status, err := s.Status(ctx)
+	if err != nil || status.Detected != "http://127.0.0.1:8000" || len(status.Candidates) != 3 {
+		t.Fatalf("unexpected status %+v, %v", status, err)
+	}
+
+	res, err := s.Apply(ctx, SetupRequest{ChromaURL: "http://localhost:8000"})
+	if !errors.Is(err, ErrSetupCheck) || res == nil || res.Checks[0].Status != DiagnosticFail || len(store) != 0 {
+		t.Fatalf("expected a failed chroma check and nothing written, got %+v, %v, %v", res, err, store)
+	}
+	if _, err := s.Apply(ctx, SetupRequest{CollectionName: "bad name!"}); !errors.Is(err, ErrInvalidSetup) {
+		t.Errorf("expected ErrInvalidSetup, got %v", err)
+	}
+
+	res, err = s.Apply(ctx, SetupRequest{CollectionName: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.RestartRequired || res.ChromaURL != "http://127.0.0.1:8000" || len(res.Checks) != 2 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if store["chroma_url"] != "http://127.0.0.1:8000" || store["collection_name"] != "notes" || store[setupCompletedKey] != "true" {
+		t.Errorf("unexpected config %v", store)
+	}
+	if _, ok := client.collections["notes"]; !ok || len(client.collections) != 1 {
+		t.Errorf("expected only the default collection to remain, got %v", client.collections)
+	}
+	if done, _ := s.Completed(); !done {
+		t.Error("expected setup to be completed")
+	}
+}
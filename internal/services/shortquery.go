@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrVagueQuery is returned for a query with no terms once stopwords and
+// punctuation are removed: its nearest neighbours would be meaningless.
+var ErrVagueQuery = errors.New("query too vague")
+
+// StrategyLexical marks responses a short query got from lexical search.
+const StrategyLexical = "lexical"
+
+// WithShortQueryTerms sends queries of at most n terms, after stopwords are
+// removed, to lexical search first, falling back to vector search when no
+// chunk contains them. Zero disables it.
+func (s *IngestService) WithShortQueryTerms(n int) *IngestService {
+	s.shortQueryTerms = max(n, 0)
+	return s
+}
+
+// searchShortQuery rejects vague queries and answers short ones lexically.
+// It returns nil when the query should go to vector search.
+func (s *IngestService) searchShortQuery(ctx context.Context, collectionName, query string, k int, filter map[string]interface{}, exclude Exclusion) (*SearchResponse, error) {
+	settings, err := s.CollectionAnalyzer(collectionName)
+	if err != nil {
+		settings = DefaultAnalyzerSettings
+	}
+	terms := NewAnalyzer(settings).Analyze(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: %q has no terms besides stopwords and punctuation; add a distinctive word or use a metadata filter", ErrVagueQuery, query)
+	}
+	if len(terms) > s.shortQueryTerms {
+		return nil, nil
+	}
+	results, err := s.lexicalSearch(ctx, collectionName, query, k, filter, exclude)
+	if err != nil || len(results) == 0 {
+		return nil, nil
+	}
+	return &SearchResponse{Results: results, Strategy: StrategyLexical}, nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestShortQueries(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{
+		"c1": {ID: "c1", Document: "Deploying on Kubernetes", Metadata: map[string]interface{}{"file_name": "k8s.md"}},
+		"c2": {ID: "c2", Document: "Release notes", Metadata: map[string]interface{}{"file_name": "notes.md"}},
+	}}
+	s := NewIngestService(fileClient{collection: col}).WithShortQueryTerms(1)
+
+	for _, q := range []string{"the", "how is it?", "?!"} {
+		if _, err := s.SearchWithFallback(ctx, "docs", q, 5, nil, SearchOptions{}); !errors.Is(err, ErrVagueQuery) {
+			t.Errorf("%q: expected ErrVagueQuery, got %v", q, err)
+		}
+	}
+	resp, err := s.SearchWithFallback(ctx, "docs", "kubernetes?", 5, nil, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Strategy != StrategyLexical || len(resp.Results) != 1 || resp.Results[0].ID != "c1" {
+		t.Errorf("expected a lexical match for a one-term query, got %+v", resp)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func TestURLSigner(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewURLSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+
+	ctx := WithAPIKey(WithPrincipals(context.Background(), []string{"alice", "team-a"}), config.APIKey{ID: "k1"})
+	token, expires, err := signer.Sign(ctx, "docs", "abc123", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected expiry %s", expires)
+	}
+	grant, err := signer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SignedDownload{Collection: "docs", FileMD5: "abc123", Principals: []string{"alice", "team-a"}, KeyID: "k1", Expires: expires}
+	if !reflect.DeepEqual(grant, want) {
+		t.Errorf("got %+v, want %+v", grant, want)
+	}
+
+	// Principals asserted without authenticating are not signed
+	anonymous, _, _ := signer.Sign(WithPrincipals(context.Background(), []string{"alice"}), "docs", "abc123", time.Hour)
+	if grant, err := signer.Verify(anonymous); err != nil || grant.Principals != nil || grant.KeyID != "" {
+		t.Errorf("expected an anonymous link without principals, got %+v, %v", grant, err)
+	}
+
+	// Tampering with the payload, or another secret, breaks the signature
+	payload, sig, _ := strings.Cut(token, ".")
+	for _, bad := range []string{payload[:len(payload)-2] + "xx." + sig, payload, "", token + "x"} {
+		if _, err := signer.Verify(bad); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("expected %q to be rejected, got %v", bad, err)
+		}
+	}
+	if _, err := NewURLSigner([]byte("other")).Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected another secret to be rejected, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := signer.Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an expired link to be rejected, got %v", err)
+	}
+
+	// Lifetimes default and are capped
+	if _, expires, _ := signer.Sign(ctx, "docs", "abc123", 0); !expires.Equal(now.Add(DefaultSignedURLTTL)) {
+		t.Errorf("expected the default lifetime, got %s", expires)
+	}
+	if _, expires, _ := signer.Sign(ctx, "docs", "abc123", 30*24*time.Hour); !expires.Equal(now.Add(MaxSignedURLTTL)) {
+		t.Errorf("expected the lifetime to be capped, got %s", expires)
+	}
+}
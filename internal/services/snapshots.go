@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// Snapshots are archives of a collection taken without removing it, stored
+// as "<collection>@<snapshot>". A snapshot is searched by restoring it, on
+// first use, into a hidden read-only collection; the least recently used are
+// dropped again once more than maxMountedSnapshots are restored.
+const (
+	snapshotSeparator        = "@"
+	snapshotCollectionSuffix = "__snapshot_"
+	maxMountedSnapshots      = 4
+)
+
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// ErrSnapshotNotFound is returned for an unknown snapshot.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrInvalidSnapshot is returned for an unusable snapshot name.
+var ErrInvalidSnapshot = errors.New("invalid snapshot name")
+
+// ErrSnapshotReadOnly is returned when writing to a restored snapshot.
+var ErrSnapshotReadOnly = errors.New("snapshot collections are read-only")
+
+// SnapshotInfo describes a snapshot of a collection.
+type SnapshotInfo struct {
+	Collection string    `json:"collection"`
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Records    int       `json:"records,omitempty"`
+}
+
+// IsSnapshotCollection reports whether name is a restored snapshot.
+func IsSnapshotCollection(name string) bool {
+	return strings.Contains(name, snapshotCollectionSuffix)
+}
+
+func snapshotEntry(collection, snapshot string) string {
+	return collection + snapshotSeparator + snapshot
+}
+
+func snapshotCollection(collection, snapshot string) string {
+	return collection + snapshotCollectionSuffix + snapshot
+}
+
+func validSnapshot(snapshot string) error {
+	if !snapshotNameRe.MatchString(snapshot) {
+		return fmt.Errorf("%w: %q (use letters, digits, '.', '_' and '-')", ErrInvalidSnapshot, snapshot)
+	}
+	return nil
+}
+
+// Snapshot stores the current contents of a collection as a named snapshot.
+func (s *ArchiveService) Snapshot(ctx context.Context, collection, snapshot string) (_ *SnapshotInfo, err error) {
+	if err := validSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+	if IsSnapshotCollection(collection) {
+		return nil, fmt.Errorf("%w: %s is itself a snapshot", ErrInvalidSnapshot, collection)
+	}
+	var records int
+	defer func() { s.notifyResult("snapshot", collection, records, err) }()
+	archive, err := s.export(ctx, collection, snapshotEntry(collection, snapshot))
+	if archive != nil {
+		records = len(archive.Records)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"collection": collection,
+		"snapshot":   snapshot,
+		"records":    records,
+	}).Info("Snapshotted collection")
+	return &SnapshotInfo{Collection: collection, Name: snapshot, CreatedAt: archive.ArchivedAt, Records: records}, nil
+}
+
+// ListSnapshots returns a collection's snapshots, oldest first.
+func (s *ArchiveService) ListSnapshots(collection string) ([]SnapshotInfo, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	prefix := collection + snapshotSeparator
+	out := []SnapshotInfo{}
+	for _, e := range entries {
+		if name, ok := strings.CutPrefix(e.Name, prefix); ok {
+			out = append(out, SnapshotInfo{Collection: collection, Name: name, SizeBytes: e.SizeBytes, CreatedAt: e.ArchivedAt})
+		}
+	}
+	slices.SortFunc(out, func(a, b SnapshotInfo) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out, nil
+}
+
+// DeleteSnapshot removes a snapshot and its restored collection, if any.
+func (s *ArchiveService) DeleteSnapshot(ctx context.Context, collection, snapshot string) error {
+	if err := validSnapshot(snapshot); err != nil {
+		return err
+	}
+	if err := s.store.Delete(snapshotEntry(collection, snapshot)); errors.Is(err, ErrArchiveNotFound) {
+		return fmt.Errorf("%w: %s@%s", ErrSnapshotNotFound, collection, snapshot)
+	} else if err != nil {
+		return err
+	}
+	name := snapshotCollection(collection, snapshot)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mounted = slices.DeleteFunc(s.mounted, func(m string) bool { return m == name })
+	if _, err := s.chromaDB.GetCollection(ctx, name); err == nil {
+		return s.chromaDB.DeleteCollection(ctx, name)
+	}
+	return nil
+}
+
+// MountSnapshot returns the collection holding a snapshot, restoring it from
+// the store if needed. A collection restored by an earlier run is reused, as
+// snapshots never change.
+func (s *ArchiveService) MountSnapshot(ctx context.Context, collection, snapshot string) (string, error) {
+	if err := validSnapshot(snapshot); err != nil {
+		return "", err
+	}
+	name := snapshotCollection(collection, snapshot)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.mounted, name); i >= 0 {
+		s.mounted = append(slices.Delete(s.mounted, i, i+1), name)
+		return name, nil
+	}
+	if _, err := s.chromaDB.GetCollection(ctx, name); err != nil {
+		archive, err := s.load(snapshotEntry(collection, snapshot))
+		if errors.Is(err, ErrArchiveNotFound) {
+			return "", fmt.Errorf("%w: %s@%s", ErrSnapshotNotFound, collection, snapshot)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := s.create(ctx, name, archive); err != nil {
+			// Don't leave a partial snapshot to be reused
+			_ = s.chromaDB.DeleteCollection(ctx, name)
+			return "", err
+		}
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"collection": collection,
+			"snapshot":   snapshot,
+			"records":    len(archive.Records),
+		}).Info("Restored snapshot")
+	}
+	s.mounted = append(s.mounted, name)
+	for len(s.mounted) > maxMountedSnapshots {
+		evict := s.mounted[0]
+		s.mounted = s.mounted[1:]
+		if err := s.chromaDB.DeleteCollection(ctx, evict); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", evict).Warn("Failed to drop restored snapshot")
+		}
+	}
+	return name, nil
+}
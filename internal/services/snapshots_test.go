@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// memCollection holds documents by id.
+type memCollection struct {
+	chroma.Collection
+	name string
+	docs map[string]string
+}
+
+func (c *memCollection) Name() string                        { return c.name }
+func (c *memCollection) Metadata() chroma.CollectionMetadata { return nil }
+
+func (c *memCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(c.docs))
+	for id := range c.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	res := &chroma.GetResultImpl{}
+	for _, id := range ids[min(op.Offset, len(ids)):] {
+		res.Ids = append(res.Ids, chroma.DocumentID(id))
+		res.Documents = append(res.Documents, chroma.NewTextDocument(c.docs[id]))
+		res.Metadatas = append(res.Metadatas, chroma.NewDocumentMetadata())
+	}
+	return res, nil
+}
+
+func (c *memCollection) Add(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	op, err := chroma.NewCollectionAddOp(opts...)
+	if err != nil {
+		return err
+	}
+	for i, id := range op.Ids {
+		c.docs[string(id)] = op.Documents[i].ContentString()
+	}
+	return nil
+}
+
+type memClient struct {
+	chroma.Client
+	collections map[string]*memCollection
+	creates     int
+}
+
+func (c *memClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if col, ok := c.collections[name]; ok {
+		return col, nil
+	}
+	return nil, errors.New("collection not found")
+}
+
+func (c *memClient) CreateCollection(ctx context.Context, name string, opts ...chroma.CreateCollectionOption) (chroma.Collection, error) {
+	c.creates++
+	c.collections[name] = &memCollection{name: name, docs: map[string]string{}}
+	return c.collections[name], nil
+}
+
+func (c *memClient) DeleteCollection(ctx context.Context, name string, opts ...chroma.DeleteCollectionOption) error {
+	delete(c.collections, name)
+	return nil
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	docs := &memCollection{name: "docs", docs: map[string]string{"a": "March pricing"}}
+	client := &memClient{collections: map[string]*memCollection{"docs": docs}}
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewArchiveService(client, store)
+
+	if _, err := s.Snapshot(ctx, "docs", "../march"); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("expected ErrInvalidSnapshot, got %v", err)
+	}
+	if info, err := s.Snapshot(ctx, "docs", "2026-03"); err != nil || info.Records != 1 {
+		t.Fatalf("Snapshot() = %+v, %v", info, err)
+	}
+	if _, err := s.Snapshot(ctx, "docs", "2026-03"); !errors.Is(err, ErrArchiveExists) {
+		t.Errorf("expected a repeated snapshot to fail, got %v", err)
+	}
+	docs.docs["a"] = "April pricing"
+
+	// Snapshots are not archives and the collection stays in place
+	if archives, _ := s.ListArchives(); len(archives) != 0 {
+		t.Errorf("expected snapshots to be hidden from archives, got %+v", archives)
+	}
+	if list, err := s.ListSnapshots("docs"); err != nil || len(list) != 1 || list[0].Name != "2026-03" {
+		t.Errorf("ListSnapshots() = %+v, %v", list, err)
+	}
+
+	name, err := s.MountSnapshot(ctx, "docs", "2026-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSnapshotCollection(name) || !reflect.DeepEqual(client.collections[name].docs, map[string]string{"a": "March pricing"}) {
+		t.Errorf("expected the snapshot contents in %s, got %v", name, client.collections[name])
+	}
+	if again, _ := s.MountSnapshot(ctx, "docs", "2026-03"); again != name || client.creates != 1 {
+		t.Errorf("expected the mounted snapshot to be reused, got %s after %d creates", again, client.creates)
+	}
+	if _, err := s.MountSnapshot(ctx, "docs", "2025-01"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+
+	// Mounting more than the limit drops the least recently used
+	for _, snap := range []string{"s1", "s2", "s3", "s4"} {
+		if _, err := s.Snapshot(ctx, "docs", snap); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MountSnapshot(ctx, "docs", snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := client.collections[name]; ok {
+		t.Errorf("expected %s to be evicted", name)
+	}
+
+	if err := s.DeleteSnapshot(ctx, "docs", "s4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.collections[snapshotCollection("docs", "s4")]; ok {
+		t.Error("expected the restored snapshot to be dropped with it")
+	}
+	if err := s.DeleteSnapshot(ctx, "docs", "s4"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+
+	ingest := NewIngestService(client)
+	if _, err := ingest.CreateDocDirect(ctx, snapshotCollection("docs", "s1"), "b", "text", nil, nil, IngestSource{}); !errors.Is(err, ErrSnapshotReadOnly) {
+		t.Errorf("expected writes to a snapshot to be refused, got %v", err)
+	}
+	if err := ingest.DeleteCollection(ctx, snapshotCollection("docs", "s1"), true); !errors.Is(err, ErrSnapshotReadOnly) {
+		t.Errorf("expected deleting a snapshot collection to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// sourceIDKey is the default chunk metadata key recording where a chunk came from.
+const sourceIDKey = "source_id"
+
+// Source kinds.
+const (
+	SourceUpload   = "upload"
+	SourceText     = "text"
+	SourcePipeline = "pipeline"
+	SourceCrawl    = "crawl"
+	SourceGit      = "git"
+	SourceBucket   = "bucket"
+	SourcePath     = "path"
+	SourceFeed     = "feed"
+	SourceNotion   = "notion"
+)
+
+// ErrSourceNotRerunnable is returned when re-running a source that isn't a
+// pipeline.
+var ErrSourceNotRerunnable = errors.New("source cannot be re-run")
+
+// IngestSource identifies the origin of ingested chunks.
+type IngestSource struct {
+	ID   string
+	Kind string
+	Ref  string // e.g. the pipeline name, URL or repository
+}
+
+// NewUploadSource returns a fresh source for one upload batch.
+func NewUploadSource() IngestSource {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return IngestSource{ID: fmt.Sprintf("upload-%s-%x", time.Now().UTC().Format("20060102T150405"), b), Kind: SourceUpload}
+}
+
+// SourceStore persists the source registry.
+type SourceStore interface {
+	RecordSource(src config.Source) error
+	ListSources(collection string) ([]config.Source, error)
+	GetSource(collection, id string) (config.Source, error)
+	DeleteSource(collection, id string) error
+}
+
+// WithSources attaches the source registry so ingests record their origin.
+func (s *IngestService) WithSources(store SourceStore) *IngestService {
+	s.sources = store
+	return s
+}
+
+func (s *IngestService) recordSource(collection string, src IngestSource) {
+	if s.sources == nil || src.ID == "" {
+		return
+	}
+	_ = s.sources.RecordSource(config.Source{ID: src.ID, Collection: collection, Kind: src.Kind, Ref: src.Ref})
+}
+
+// SourceService lists, re-runs and purges ingest sources.
+type SourceService struct {
+	ingest    *IngestService
+	pipelines *PipelineService
+	store     SourceStore
+}
+
+func NewSourceService(ingest *IngestService, pipelines *PipelineService, store SourceStore) *SourceService {
+	return &SourceService{ingest: ingest, pipelines: pipelines, store: store}
+}
+
+func (s *SourceService) List(collection string) ([]config.Source, error) {
+	return s.store.ListSources(collection)
+}
+
+// Rerun re-runs the pipeline a source was ingested by. Only pipelines can
+// be re-run: uploads, text and Notion pages keep no original content, git,
+// path, bucket and crawl sources record their location but not the
+// filters, metadata and ACL they were ingested with, and feeds are re-run
+// by polling them.
+func (s *SourceService) Rerun(ctx context.Context, collection, id string) ([]IngestResult, error) {
+	src, err := s.store.GetSource(collection, id)
+	if err != nil {
+		return nil, err
+	}
+	switch src.Kind {
+	case SourcePipeline:
+		run, err := s.pipelines.Run(ctx, src.Ref)
+		if err != nil {
+			return nil, err
+		}
+		return run.Results, nil
+	case SourceGit, SourcePath, SourceBucket, SourceCrawl:
+		return nil, fmt.Errorf("%w: %s sources keep their location (%s) but not the options they were ingested with; repeat the original ingest", ErrSourceNotRerunnable, src.Kind, src.Ref)
+	case SourceFeed:
+		return nil, fmt.Errorf("%w: poll the feed (%s) instead", ErrSourceNotRerunnable, src.Ref)
+	}
+	return nil, fmt.Errorf("%w: %s sources keep no original content", ErrSourceNotRerunnable, src.Kind)
+}
+
+// Purge deletes every chunk tagged with the source and forgets the source.
+// Protected collections require force and an admin caller.
+func (s *SourceService) Purge(ctx context.Context, collection, id string, force bool) error {
+	if err := s.ingest.checkDestructive(ctx, collection, force); err != nil {
+		return err
+	}
+	if _, err := s.store.GetSource(collection, id); err != nil {
+		return err
+	}
+	col, err := s.ingest.chromaDB.GetCollection(ctx, collection)
+	if err != nil {
+		return fmt.Errorf("get collection %q: %w", collection, err)
+	}
+	finish, err := s.ingest.beginIntent(ctx, config.Intent{Collection: collection, Op: IntentDelete, Filter: map[string]string{s.ingest.keys.SourceID: id}})
+	if err != nil {
+		return err
+	}
+	err = col.Delete(ctx, chroma.WithWhereDelete(chroma.EqString(s.ingest.keys.SourceID, id)))
+	finish(err)
+	if err != nil {
+		return fmt.Errorf("purge source %q: %w", id, err)
+	}
+	s.ingest.forgetIndexed(ctx, collection, id)
+	s.ingest.publishChange(EventDeleted, collection, map[string]interface{}{"source_id": id})
+	return s.store.DeleteSource(collection, id)
+}
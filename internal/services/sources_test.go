@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// memSources is an in-memory SourceStore.
+type memSources map[string]config.Source
+
+func (m memSources) RecordSource(src config.Source) error {
+	m[src.Collection+"/"+src.ID] = src
+	return nil
+}
+
+func (m memSources) ListSources(collection string) ([]config.Source, error) {
+	var out []config.Source
+	for _, src := range m {
+		if src.Collection == collection {
+			out = append(out, src)
+		}
+	}
+	return out, nil
+}
+
+func (m memSources) GetSource(collection, id string) (config.Source, error) {
+	src, ok := m[collection+"/"+id]
+	if !ok {
+		return config.Source{}, config.ErrNotFound
+	}
+	return src, nil
+}
+
+func (m memSources) DeleteSource(collection, id string) error {
+	delete(m, collection+"/"+id)
+	return nil
+}
+
+func TestSourceRerun(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.md"), []byte("Alpha"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	col := &writeCollection{files: map[string]int{}}
+	sources := memSources{}
+	ingest := NewIngestService(writeClient{collection: col}).WithPathRoots([]string{root}).WithSources(sources)
+	pipelines := NewPipelineService(ingest, memPipelineStore{})
+	if _, err := pipelines.Save([]byte("name: handbook\ncollection: docs\nsource: {type: path, path: " + root + "}")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := pipelines.Run(ctx, "handbook"); err != nil {
+		t.Fatal(err)
+	}
+	src, err := sources.GetSource("docs", "pipeline:handbook")
+	if err != nil || src.Kind != SourcePipeline || src.Ref != "handbook" {
+		t.Fatalf("expected the pipeline recorded as the source, got %+v, %v", src, err)
+	}
+
+	s := NewSourceService(ingest, pipelines, sources)
+	results, err := s.Rerun(ctx, "docs", src.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || col.files["a.md"] != 2 {
+		t.Errorf("expected the pipeline run again, got %+v after %v", results, col.files)
+	}
+
+	upload := NewUploadSource()
+	ingest.recordSource("docs", upload)
+	if _, err := s.Rerun(ctx, "docs", upload.ID); !errors.Is(err, ErrSourceNotRerunnable) {
+		t.Errorf("expected an upload batch not to be re-runnable, got %v", err)
+	}
+	ingest.recordSource("docs", IngestSource{ID: "git-1", Kind: SourceGit, Ref: "https://example.com/repo.git"})
+	if _, err := s.Rerun(ctx, "docs", "git-1"); !errors.Is(err, ErrSourceNotRerunnable) || !strings.Contains(err.Error(), "repeat the original ingest") {
+		t.Errorf("expected a git source to point at repeating its ingest, got %v", err)
+	}
+	if _, err := s.Rerun(ctx, "docs", "missing"); !errors.Is(err, config.ErrNotFound) {
+		t.Errorf("expected an unknown source to be not found, got %v", err)
+	}
+}
+
+func TestSourcePurge(t *testing.T) {
+	ctx := context.Background()
+	col := &whereCollection{ids: []string{"a", "b"}}
+	sources := memSources{}
+	ingest := NewIngestService(whereClient{collection: col}).WithSettings(memSettings{}).WithSources(sources)
+	ingest.recordSource("docs", IngestSource{ID: "feed-1", Kind: SourceFeed, Ref: "https://example.com/feed.xml"})
+	s := NewSourceService(ingest, nil, sources)
+
+	if err := s.Purge(ctx, "docs", "feed-1", false); err != nil {
+		t.Fatal(err)
+	}
+	if col.deleted != `{"source_id":{"$eq":"feed-1"}}` {
+		t.Errorf("expected the source's chunks deleted, got filter %s", col.deleted)
+	}
+	if list, _ := s.List("docs"); len(list) != 0 {
+		t.Errorf("expected the source forgotten, got %+v", list)
+	}
+	if err := s.Purge(ctx, "docs", "feed-1", false); !errors.Is(err, config.ErrNotFound) {
+		t.Errorf("expected a purged source to be not found, got %v", err)
+	}
+
+	ingest.recordSource("docs", IngestSource{ID: "feed-2", Kind: SourceFeed})
+	if err := ingest.SetProtected(WithAdmin(ctx), "docs", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Purge(ctx, "docs", "feed-2", false); !errors.Is(err, ErrCollectionProtected) {
+		t.Errorf("expected a protected collection to need force, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// chunkingSettingKey stores a collection's chunking strategy in the settings table.
+const chunkingSettingKey = "chunking"
+
+// Chunk metadata linking a file's chunks, so neighbours can be fetched to
+// widen a result's context.
+const (
+	prevChunkKey = "prev_chunk_id"
+	nextChunkKey = "next_chunk_id"
+	// overlapKey is the length in bytes of the text a chunk repeats from the
+	// one before it.
+	overlapKey = "overlap_bytes"
+	// parentChunkKey links a chunk to the parent section it was split from
+	// (see Chunking.ParentSize).
+	parentChunkKey = "parent_id"
+)
+
+// Built-in chunking strategies (see RegisterChunker) for text without
+// structure of its own; Markdown and code files are always split by block.
+const (
+	// ChunkRecursive splits by paragraph, then line, sentence and word, only
+	// going finer where a piece is still too large.
+	ChunkRecursive = "recursive"
+	// ChunkLines packs whole lines.
+	ChunkLines = "lines"
+	// ChunkSentences packs whole sentences, ignoring line breaks within
+	// paragraphs.
+	ChunkSentences = "sentences"
+	// ChunkMarkdown packs lines, keeping fenced code blocks whole.
+	ChunkMarkdown = "markdown"
+	// ChunkCode packs blocks separated by blank lines.
+	ChunkCode = "code"
+	// ChunkSemantic breaks chunks where the topic shifts.
+	ChunkSemantic = "semantic"
+)
+
+// DefaultChunkSeparators are tried in order by the recursive strategy.
+var DefaultChunkSeparators = []string{"\n\n", "\n", ". ", "? ", "! ", "; ", ", ", " "}
+
+// ErrInvalidChunking is returned for an unknown strategy, an empty
+// separator or a negative size or overlap.
+var ErrInvalidChunking = errors.New("invalid chunking")
+
+// Chunking selects how text is split into chunks. Stored per collection, it
+// lets e.g. code and prose collections chunk differently without request
+// parameters.
+type Chunking struct {
+	Strategy string `json:"strategy"`
+	// Size is the chunk size in tokens (zero for defaultChunkTokens). A size
+	// given with the ingest, e.g. a pipeline's max_tokens, takes precedence.
+	Size int `json:"size,omitempty"`
+	// Separators override DefaultChunkSeparators for the recursive strategy.
+	Separators []string `json:"separators,omitempty"`
+	// Overlap repeats about this many tokens from the end of each chunk at
+	// the start of the next, so context isn't lost at chunk boundaries. It
+	// counts toward the chunk size and is capped at half of it.
+	Overlap int `json:"overlap"`
+	// ParentSize, when set, first splits text into parent sections of this
+	// many tokens and then each parent into chunks, which record the
+	// parent's ID; searches with SearchOptions.Parents return the parent in
+	// place of the matched chunk.
+	ParentSize int `json:"parent_size,omitempty"`
+}
+
+func (c Chunking) Validate() error {
+	if _, err := GetChunker(c.Strategy); err != nil {
+		return err
+	}
+	for _, sep := range c.Separators {
+		if sep == "" {
+			return fmt.Errorf("%w: empty separator", ErrInvalidChunking)
+		}
+	}
+	if c.Size < 0 {
+		return fmt.Errorf("%w: size must not be negative", ErrInvalidChunking)
+	}
+	if c.Overlap < 0 {
+		return fmt.Errorf("%w: overlap must not be negative", ErrInvalidChunking)
+	}
+	if c.ParentSize < 0 {
+		return fmt.Errorf("%w: parent_size must not be negative", ErrInvalidChunking)
+	}
+	if c.ParentSize > 0 && c.ParentSize <= c.chunkTokens(0) {
+		return fmt.Errorf("%w: parent_size must be larger than the chunk size", ErrInvalidChunking)
+	}
+	return nil
+}
+
+// WithDefaultChunking sets the chunking for collections without one of
+// their own (default ChunkRecursive, defaultChunkTokens, without overlap).
+func (s *IngestService) WithDefaultChunking(c Chunking) *IngestService {
+	s.defaultChunking = c
+	return s
+}
+
+// CollectionChunking returns the chunking configured for a collection,
+// falling back to the service's default, with its size filled in.
+func (s *IngestService) CollectionChunking(collection string) (Chunking, error) {
+	c := s.defaultChunking
+	if c.Strategy == "" {
+		c.Strategy = ChunkRecursive
+	}
+	if s.settings != nil {
+		if _, err := s.settings.GetCollectionSetting(collection, chunkingSettingKey, &c); err != nil {
+			return Chunking{}, err
+		}
+	}
+	c.Size = c.chunkTokens(0)
+	return c, nil
+}
+
+// SetCollectionChunking selects how a collection's files are chunked.
+// Existing chunks are not re-chunked.
+func (s *IngestService) SetCollectionChunking(collection string, c Chunking) error {
+	if s.settings == nil {
+		return errNoSettingsStore
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return s.settings.SetCollectionSetting(collection, chunkingSettingKey, c)
+}
+
+// chunkTokens is the chunk size for an ingest asking for maxTokens, zero
+// for the configured size.
+func (c Chunking) chunkTokens(maxTokens int) int {
+	switch {
+	case maxTokens > 0:
+		return maxTokens
+	case c.Size > 0:
+		return c.Size
+	}
+	return defaultChunkTokens
+}
+
+// overlapTokens is the overlap to use for chunks of maxTokens.
+func (c Chunking) overlapTokens(maxTokens int) int {
+	return min(c.Overlap, maxTokens/2)
+}
+
+// withOverlap prefixes each chunk after the first with about overlap tokens
+// from the end of the one before, starting at a word where there is one,
+// and returns the prefix lengths in bytes. Tokenizers that can't split
+// text get no overlap.
+func withOverlap(chunks []string, overlap int, tokenizer Tokenizer) ([]string, []int) {
+	prefixes := make([]int, len(chunks))
+	splitter, ok := tokenizer.(tokenSplitter)
+	if !ok || overlap <= 0 || len(chunks) < 2 {
+		return chunks, prefixes
+	}
+	out := make([]string, len(chunks))
+	out[0] = chunks[0]
+	for i := 1; i < len(chunks); i++ {
+		prev := chunks[i-1]
+		tail := splitter.Tail(prev, overlap)
+		if len(tail) < len(prev) && !unicode.IsSpace(rune(prev[len(prev)-len(tail)-1])) {
+			if j := strings.IndexFunc(tail, unicode.IsSpace); j >= 0 {
+				tail = tail[j:]
+			}
+		}
+		tail = strings.TrimLeftFunc(tail, unicode.IsSpace)
+		out[i] = tail + chunks[i]
+		prefixes[i] = len(tail)
+	}
+	return out, prefixes
+}
+
+// split chunks text of about maxTokens each with the strategy, falling
+// back to ChunkRecursive for one no longer registered.
+func (c Chunking) split(text string, maxTokens int, tokenizer Tokenizer) []string {
+	strategy, err := GetChunker(c.Strategy)
+	if err != nil {
+		strategy, _ = GetChunker(ChunkRecursive)
+	}
+	return strategy.Chunk(text, maxTokens, tokenizer, c)
+}
+
+// splitRecursive splits text at the first separator it contains, keeping
+// each separator with the text before it, and packs the pieces into chunks
+// of at most maxTokens. A piece that is still too large is split again with
+// the remaining separators and, when none is left, cut at token boundaries
+// if the tokenizer can split. The chunks concatenate back to text.
+func splitRecursive(text string, maxTokens int, tokenizer Tokenizer, separators []string) []string {
+	if tokenizer.Count(text) <= maxTokens {
+		return []string{text}
+	}
+	var sep string
+	for len(separators) > 0 && sep == "" {
+		if strings.Contains(text, separators[0]) {
+			sep = separators[0]
+		}
+		separators = separators[1:]
+	}
+	if sep == "" {
+		if splitter, ok := tokenizer.(tokenSplitter); ok {
+			return splitter.Split(text, maxTokens)
+		}
+		return []string{text}
+	}
+	return packPieces(strings.SplitAfter(text, sep), maxTokens, tokenizer, func(piece string) []string {
+		return splitRecursive(piece, maxTokens, tokenizer, separators)
+	})
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitRecursive(t *testing.T) {
+	tok, _ := GetTokenizer("whitespace")
+	para1 := "One two three. Four five six."
+	para2 := strings.Repeat("alpha beta gamma. ", 4) + "End"
+	text := para1 + "\n\n" + para2
+
+	chunks := Chunking{Strategy: ChunkRecursive}.split(text, 8, tok)
+	if got := strings.Join(chunks, ""); got != text {
+		t.Fatalf("chunks don't concatenate back to the text: %q", chunks)
+	}
+	// The first paragraph fits whole; the second is split by sentence
+	want := []string{
+		para1 + "\n\n",
+		"alpha beta gamma. alpha beta gamma. ",
+		"alpha beta gamma. alpha beta gamma. End",
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("got %q, want %q", chunks, want)
+	}
+
+	// Words are the last separator; a run without any is cut by tokens
+	long := strings.Repeat("x", 300)
+	if chunks := splitRecursive("a b c d e", 2, tok, DefaultChunkSeparators); len(chunks) != 3 {
+		t.Errorf("expected word-level chunks, got %q", chunks)
+	}
+	cl100k, _ := GetTokenizer("cl100k")
+	for _, c := range splitRecursive(long, 10, cl100k, DefaultChunkSeparators) {
+		if n := cl100k.Count(c); n > 10 {
+			t.Errorf("chunk has %d tokens: %q", n, c)
+		}
+	}
+
+	// Blank runs stay with the chunk before them
+	if chunks := splitRecursive("a b\n\n\n\n"+strings.Repeat("c ", 6), 3, tok, []string{"\n\n", " "}); chunks[0] != "a b\n\n\n\n" {
+		t.Errorf("expected blank lines to stay with the first chunk, got %q", chunks)
+	}
+}
+
+func TestCollectionChunking(t *testing.T) {
+	s := NewIngestService(nil).WithSettings(memSettings{})
+	if c, _ := s.CollectionChunking("docs"); c.Strategy != ChunkRecursive || c.Size != defaultChunkTokens {
+		t.Errorf("expected the recursive default, got %+v", c)
+	}
+	if c, _ := s.WithDefaultChunking(Chunking{Strategy: ChunkLines}).CollectionChunking("docs"); c.Strategy != ChunkLines {
+		t.Errorf("expected the service default, got %+v", c)
+	}
+	for _, bad := range []Chunking{{Strategy: "words"}, {Strategy: ChunkRecursive, Separators: []string{""}}, {Strategy: ChunkRecursive, Overlap: -1}, {Strategy: ChunkLines, Size: -1}} {
+		if err := s.SetCollectionChunking("docs", bad); !errors.Is(err, ErrInvalidChunking) {
+			t.Errorf("expected %+v to be rejected, got %v", bad, err)
+		}
+	}
+	set := Chunking{Strategy: ChunkRecursive, Separators: []string{"\n---\n", "\n"}, Size: 200, Overlap: 20}
+	if err := s.SetCollectionChunking("docs", set); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.CollectionChunking("docs"); !reflect.DeepEqual(c, set) {
+		t.Errorf("got %+v, want %+v", c, set)
+	}
+
+	// A collection's size applies unless the ingest asks for one
+	if err := s.SetCollectionChunking("code", Chunking{Strategy: ChunkLines, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	s.WithDefaultTokenizer("whitespace")
+	text := []byte("a b c d\ne f g h\ni j k l\n")
+	for _, tc := range []struct {
+		maxTokens, want int
+	}{{0, 3}, {8, 2}} {
+		prepared, err := s.prepareChunks(context.Background(), "code", "main.txt", text, "abc", IngestOptions{MaxTokens: tc.maxTokens})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prepared.chunks) != tc.want {
+			t.Errorf("max_tokens %d: expected %d chunks, got %q", tc.maxTokens, tc.want, prepared.chunks)
+		}
+	}
+}
+
+func TestChunkOverlap(t *testing.T) {
+	s := NewIngestService(nil).WithSettings(memSettings{}).WithDefaultTokenizer("whitespace").
+		WithDefaultChunking(Chunking{Strategy: ChunkRecursive, Overlap: 2})
+	words := make([]string, 20)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	text := strings.Join(words, " ")
+	prepared, err := s.prepareChunks(context.Background(), "docs", "notes.txt", []byte(text), "abc", IngestOptions{MaxTokens: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prepared.chunks) < 3 {
+		t.Fatalf("expected several chunks, got %q", prepared.chunks)
+	}
+	tok, _ := GetTokenizer("whitespace")
+	var rebuilt strings.Builder
+	for i, chunk := range prepared.chunks {
+		md := prepared.metadatas[i]
+		if n := tok.Count(chunk); n > 6 {
+			t.Errorf("chunk %d has %d tokens: %q", i, n, chunk)
+		}
+		overlap, _ := md[overlapKey].(int)
+		if i > 0 {
+			// Each chunk starts with the last two words of the one before
+			prev := strings.Fields(prepared.chunks[i-1])
+			if want := strings.Join(prev[len(prev)-2:], " ") + " "; !strings.HasPrefix(chunk, want) || overlap != len(want) {
+				t.Errorf("chunk %d: expected to start with %q (%d bytes), got %q (%d)", i, want, len(want), chunk, overlap)
+			}
+			if md[prevChunkKey] != prepared.ids[i-1] {
+				t.Errorf("chunk %d: expected prev %s, got %v", i, prepared.ids[i-1], md[prevChunkKey])
+			}
+		} else if _, ok := md[prevChunkKey]; ok {
+			t.Error("expected the first chunk to have no prev")
+		}
+		if i < len(prepared.chunks)-1 {
+			if md[nextChunkKey] != prepared.ids[i+1] {
+				t.Errorf("chunk %d: expected next %s, got %v", i, prepared.ids[i+1], md[nextChunkKey])
+			}
+		} else if _, ok := md[nextChunkKey]; ok {
+			t.Error("expected the last chunk to have no next")
+		}
+		rebuilt.WriteString(chunk[overlap:])
+	}
+	if rebuilt.String() != text {
+		t.Errorf("chunks less their overlap don't rebuild the text: %q", rebuilt.String())
+	}
+}
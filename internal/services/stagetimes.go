@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ingest stages timed per file.
+const (
+	stageExtract = "extract"
+	stageChunk   = "chunk"
+	stageEmbed   = "embed"
+	stageAdd     = "add"
+)
+
+// IngestTimings is the time ingestion spent in each stage, in
+// milliseconds: extracting text, chunking it, embedding the chunks and
+// adding them to Chroma. Batches written concurrently add up, so embed and
+// add can exceed the wall-clock time.
+type IngestTimings struct {
+	ExtractMS float64 `json:"extract_ms"`
+	ChunkMS   float64 `json:"chunk_ms"`
+	EmbedMS   float64 `json:"embed_ms"`
+	AddMS     float64 `json:"add_ms"`
+}
+
+// Add adds o's times to t.
+func (t *IngestTimings) Add(o IngestTimings) {
+	t.ExtractMS += o.ExtractMS
+	t.ChunkMS += o.ChunkMS
+	t.EmbedMS += o.EmbedMS
+	t.AddMS += o.AddMS
+}
+
+func (t *IngestTimings) record(stage string, d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000
+	switch stage {
+	case stageExtract:
+		t.ExtractMS += ms
+	case stageChunk:
+		t.ChunkMS += ms
+	case stageEmbed:
+		t.EmbedMS += ms
+	case stageAdd:
+		t.AddMS += ms
+	}
+}
+
+// sumTimings adds up the stage times of ingest results.
+func sumTimings(results []IngestResult) IngestTimings {
+	var t IngestTimings
+	for _, r := range results {
+		if r.Timings != nil {
+			t.Add(*r.Timings)
+		}
+	}
+	return t
+}
+
+// stageTimer collects the stage times of one file's ingest.
+type stageTimer struct {
+	mu sync.Mutex
+	t  IngestTimings
+}
+
+type stageTimerKey struct{}
+
+// withStageTimer starts timing the stages of work done with the returned
+// context.
+func withStageTimer(ctx context.Context) (context.Context, *stageTimer) {
+	st := &stageTimer{}
+	return context.WithValue(ctx, stageTimerKey{}, st), st
+}
+
+// recordStage adds the time since began to stage, if ctx is being timed.
+func recordStage(ctx context.Context, stage string, began time.Time) {
+	st, _ := ctx.Value(stageTimerKey{}).(*stageTimer)
+	if st == nil {
+		return
+	}
+	d := time.Since(began)
+	st.mu.Lock()
+	st.t.record(stage, d)
+	st.mu.Unlock()
+}
+
+func (st *stageTimer) timings() IngestTimings {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.t
+}
+
+// IngestTimingTotals sums the stage times of every file ingested since the
+// backend started.
+type IngestTimingTotals struct {
+	Files int `json:"files"`
+	IngestTimings
+}
+
+type stageTotals struct {
+	mu sync.Mutex
+	IngestTimingTotals
+}
+
+func (s *stageTotals) add(t IngestTimings) {
+	s.mu.Lock()
+	s.Files++
+	s.IngestTimings.Add(t)
+	s.mu.Unlock()
+}
+
+// IngestTimings reports the stage times of every file ingested since start.
+func (s *IngestService) IngestTimings() IngestTimingTotals {
+	s.stageTotals.mu.Lock()
+	defer s.stageTotals.mu.Unlock()
+	return s.stageTotals.IngestTimingTotals
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStageTimes(t *testing.T) {
+	ctx, timer := withStageTimer(context.Background())
+	recordStage(ctx, stageExtract, time.Now().Add(-5*time.Millisecond))
+	recordStage(ctx, stageAdd, time.Now().Add(-2*time.Millisecond))
+	recordStage(ctx, stageAdd, time.Now().Add(-2*time.Millisecond))
+	recordStage(context.Background(), stageAdd, time.Now().Add(-time.Hour))
+	if got := timer.timings(); got.ExtractMS < 5 || got.AddMS < 4 || got.AddMS > 1000 || got.ChunkMS != 0 {
+		t.Errorf("unexpected timings %+v", got)
+	}
+
+	col := &writeCollection{files: map[string]int{}}
+	stub := &stubEmbedder{}
+	s := NewIngestService(writeClient{collection: col}).WithEmbedder(stub, "stub")
+	res, err := s.IngestFileWithOptions(context.Background(), "docs", "guide.md", []byte("# Setup\n\nRun it."), IngestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Timings == nil || stub.calls != 1 {
+		t.Errorf("expected timings and chunks embedded before the write, got %+v after %d embed calls", res.Timings, stub.calls)
+	}
+	if totals := s.IngestTimings(); totals.Files != 1 || totals.IngestTimings != *res.Timings {
+		t.Errorf("expected the file in the totals, got %+v", totals)
+	}
+	if got := sumTimings([]IngestResult{*res, {Status: "skipped"}, *res}); got.ExtractMS != 2*res.Timings.ExtractMS {
+		t.Errorf("expected results summed, got %+v", got)
+	}
+}
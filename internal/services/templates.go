@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// Limits on generation parameters, following the OpenAI chat API.
+const (
+	maxTemperature   = 2.0
+	maxStopSequences = 4
+)
+
+// ErrInvalidGeneration is returned for generation parameters the configured
+// LLM provider cannot honour.
+var ErrInvalidGeneration = errors.New("invalid generation parameters")
+
+// ErrTemplateNotFound is returned for an unknown prompt template.
+var ErrTemplateNotFound = errors.New("prompt template not found")
+
+// GenerationParams tune one generation call. Zero values leave the
+// provider's defaults (and the configured llm_model) in place.
+type GenerationParams struct {
+	Model       string   `json:"model,omitempty" jsonschema:"the LLM to answer with"`
+	Temperature *float64 `json:"temperature,omitempty" jsonschema:"sampling temperature, 0 to 2"`
+	MaxTokens   int      `json:"max_tokens,omitempty" jsonschema:"maximum tokens to generate"`
+	Stop        []string `json:"stop,omitempty" jsonschema:"up to 4 stop sequences"`
+}
+
+// merge returns p with the fields set in over replacing its own.
+func (p GenerationParams) merge(over GenerationParams) GenerationParams {
+	if over.Model != "" {
+		p.Model = over.Model
+	}
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.MaxTokens != 0 {
+		p.MaxTokens = over.MaxTokens
+	}
+	if over.Stop != nil {
+		p.Stop = over.Stop
+	}
+	return p
+}
+
+// validate checks p against the models the generator serves.
+func (p GenerationParams) validate(models []string) error {
+	if p.Model != "" && !slices.Contains(models, p.Model) {
+		return fmt.Errorf("%w: model %q is not configured (have %s)", ErrInvalidGeneration, p.Model, strings.Join(models, ", "))
+	}
+	if t := p.Temperature; t != nil && (*t < 0 || *t > maxTemperature) {
+		return fmt.Errorf("%w: temperature must be between 0 and %g", ErrInvalidGeneration, maxTemperature)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("%w: max_tokens must be positive", ErrInvalidGeneration)
+	}
+	if len(p.Stop) > maxStopSequences {
+		return fmt.Errorf("%w: at most %d stop sequences", ErrInvalidGeneration, maxStopSequences)
+	}
+	for _, s := range p.Stop {
+		if s == "" {
+			return fmt.Errorf("%w: stop sequences must not be empty", ErrInvalidGeneration)
+		}
+	}
+	return nil
+}
+
+// PromptTemplate is a named system prompt with the generation parameters to
+// answer it with. An empty Prompt uses the configured answer prompt.
+type PromptTemplate struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt,omitempty"`
+	GenerationParams
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// TemplateStore persists prompt templates.
+type TemplateStore interface {
+	SavePromptTemplate(name, spec string) error
+	GetPromptTemplate(name string) (config.PromptTemplate, error)
+	ListPromptTemplates() ([]config.PromptTemplate, error)
+	DeletePromptTemplate(name string) error
+}
+
+// WithTemplateStore enables stored prompt templates.
+func (s *IngestService) WithTemplateStore(store TemplateStore) *IngestService {
+	s.templates = store
+	return s
+}
+
+// SaveTemplate validates t against the configured generator and stores it.
+func (s *IngestService) SaveTemplate(t PromptTemplate) (*PromptTemplate, error) {
+	if s.generator == nil || s.templates == nil {
+		return nil, ErrNoGenerator
+	}
+	if t.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidGeneration)
+	}
+	if err := t.validate(s.generator.Models()); err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = time.Time{}
+	spec, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.templates.SavePromptTemplate(t.Name, string(spec)); err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = time.Now()
+	return &t, nil
+}
+
+func (s *IngestService) GetTemplate(name string) (*PromptTemplate, error) {
+	if s.templates == nil {
+		return nil, ErrNoGenerator
+	}
+	stored, err := s.templates.GetPromptTemplate(name)
+	if errors.Is(err, config.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeTemplate(stored)
+}
+
+func (s *IngestService) ListTemplates() ([]PromptTemplate, error) {
+	if s.templates == nil {
+		return nil, ErrNoGenerator
+	}
+	stored, err := s.templates.ListPromptTemplates()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PromptTemplate, 0, len(stored))
+	for _, st := range stored {
+		t, err := decodeTemplate(st)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, nil
+}
+
+func (s *IngestService) DeleteTemplate(name string) error {
+	if s.templates == nil {
+		return ErrNoGenerator
+	}
+	if err := s.templates.DeletePromptTemplate(name); errors.Is(err, config.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func decodeTemplate(stored config.PromptTemplate) (*PromptTemplate, error) {
+	var t PromptTemplate
+	if err := json.Unmarshal([]byte(stored.Spec), &t); err != nil {
+		return nil, fmt.Errorf("prompt template %q: %w", stored.Name, err)
+	}
+	t.Name, t.UpdatedAt = stored.Name, stored.UpdatedAt
+	return &t, nil
+}
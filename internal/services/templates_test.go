@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+type memTemplateStore map[string]string
+
+func (m memTemplateStore) SavePromptTemplate(name, spec string) error {
+	m[name] = spec
+	return nil
+}
+
+func (m memTemplateStore) GetPromptTemplate(name string) (config.PromptTemplate, error) {
+	spec, ok := m[name]
+	if !ok {
+		return config.PromptTemplate{}, config.ErrNotFound
+	}
+	return config.PromptTemplate{Name: name, Spec: spec}, nil
+}
+
+func (m memTemplateStore) ListPromptTemplates() ([]config.PromptTemplate, error) {
+	var out []config.PromptTemplate
+	for name, spec := range m {
+		out = append(out, config.PromptTemplate{Name: name, Spec: spec})
+	}
+	return out, nil
+}
+
+func (m memTemplateStore) DeletePromptTemplate(name string) error {
+	if _, ok := m[name]; !ok {
+		return config.ErrNotFound
+	}
+	delete(m, name)
+	return nil
+}
+
+func TestAnswerTemplates(t *testing.T) {
+	gen := &countingGenerator{}
+	s := NewIngestService(docsClient{docs: &queryCollection{name: "faq", hits: []queryHit{{"reset.md", 0.1}}}}).
+		WithGenerator(gen, "").WithTemplateStore(memTemplateStore{})
+
+	hot, cold, tooHot := 1.5, 0.0, 3.0
+	for _, bad := range []PromptTemplate{
+		{Name: "x", GenerationParams: GenerationParams{Model: "gpt-9"}},
+		{Name: "x", GenerationParams: GenerationParams{Temperature: &tooHot}},
+		{Name: "x", GenerationParams: GenerationParams{MaxTokens: -1}},
+		{Name: "x", GenerationParams: GenerationParams{Stop: []string{"a", "b", "c", "d", "e"}}},
+		{GenerationParams: GenerationParams{Model: "small"}},
+	} {
+		if _, err := s.SaveTemplate(bad); !errors.Is(err, ErrInvalidGeneration) {
+			t.Errorf("expected ErrInvalidGeneration for %+v, got %v", bad, err)
+		}
+	}
+	if _, err := s.SaveTemplate(PromptTemplate{Name: "terse", Prompt: "Be brief.", GenerationParams: GenerationParams{Model: "large", Temperature: &hot, MaxTokens: 64, Stop: []string{"\n\n"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := func(opts AnswerOptions) (*AnswerResponse, error) {
+		return s.Answer(context.Background(), []string{"faq"}, "How do I reset?", 3, nil, opts)
+	}
+	if _, err := answer(AnswerOptions{Template: "terse", GenerationParams: GenerationParams{Temperature: &cold}}); err != nil {
+		t.Fatal(err)
+	}
+	got := gen.params[0]
+	if gen.systems[0] != "Be brief." || got.Model != "large" || *got.Temperature != 0 || got.MaxTokens != 64 || len(got.Stop) != 1 {
+		t.Errorf("expected template merged with overrides, got %q %+v", gen.systems[0], got)
+	}
+	// Different parameters don't share a cached answer
+	if resp, _ := answer(AnswerOptions{Template: "terse"}); resp.Cached {
+		t.Error("expected a fresh answer for different parameters")
+	}
+	if resp, _ := answer(AnswerOptions{}); resp.Cached || gen.systems[2] != DefaultAnswerPrompt {
+		t.Errorf("expected default prompt without a template, got %q", gen.systems[2])
+	}
+
+	if _, err := answer(AnswerOptions{Template: "missing"}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound, got %v", err)
+	}
+	if _, err := answer(AnswerOptions{GenerationParams: GenerationParams{Model: "gpt-9"}}); !errors.Is(err, ErrInvalidGeneration) {
+		t.Errorf("expected ErrInvalidGeneration for unknown model, got %v", err)
+	}
+	if err := s.DeleteTemplate("terse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTemplate("terse"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound, got %v", err)
+	}
+}
+
+func TestChatGeneratorParams(t *testing.T) {
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req = nil
+		_ = json.Unmarshal(body, &req)
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	g, err := NewGenerator(LLMConfig{URL: srv.URL, Model: "small", Models: []string{"large"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := 0.2
+	_, usage, err := g.Generate(context.Background(), "sys", "prompt", GenerationParams{Model: "large", Temperature: &temp, MaxTokens: 10, Stop: []string{"END"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["model"] != "large" || req["temperature"] != 0.2 || req["max_tokens"] != 10.0 || usage.Model != "large" {
+		t.Errorf("unexpected request %v (usage %+v)", req, usage)
+	}
+	if _, _, err := g.Generate(context.Background(), "sys", "prompt", GenerationParams{}); err != nil || req["model"] != "small" || req["temperature"] != nil {
+		t.Errorf("expected defaults without params, got %v (%v)", req, err)
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// MaxSearchTimeout caps per-request timeout overrides.
+const MaxSearchTimeout = 2 * time.Minute
+
+// Timeouts bounds upstream calls. Zero values leave calls bounded only by
+// the caller's context.
+type Timeouts struct {
+	// Query bounds a search: collection lookup, embedding the query text
+	// (done client-side by Chroma's embedding function) and the query itself.
+	Query time.Duration
+	// Embed bounds each write that embeds chunks.
+	Embed time.Duration
+}
+
+// WithTimeouts sets default upstream timeouts.
+func (s *IngestService) WithTimeouts(t Timeouts) *IngestService {
+	s.timeouts = t
+	return s
+}
+
+// withTimeout derives a context bounded by d, or a plain cancelable context
+// when d is zero.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
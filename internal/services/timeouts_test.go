@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+// hangingCollection blocks every query and write until its context ends.
+type hangingCollection struct {
+	*fileCollection
+}
+
+func (c hangingCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c hangingCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type hangingClient struct {
+	chroma.Client
+	collection hangingCollection
+}
+
+func (c hangingClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	return c.collection, nil
+}
+
+func TestTimeouts(t *testing.T) {
+	ctx := context.Background()
+	col := hangingCollection{&fileCollection{records: map[string]Record{}}}
+	s := NewIngestService(hangingClient{collection: col}).WithTimeouts(Timeouts{Query: 20 * time.Millisecond, Embed: 20 * time.Millisecond})
+
+	if _, err := s.SearchWithOptions(ctx, "docs", "alpha beta", 5, nil, SearchOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the query timeout to end the search, got %v", err)
+	}
+	began := time.Now()
+	if _, err := s.SearchWithOptions(ctx, "docs", "alpha beta", 5, nil, SearchOptions{Timeout: 100 * time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the per-request timeout to end the search, got %v", err)
+	}
+	if waited := time.Since(began); waited < 100*time.Millisecond {
+		t.Errorf("expected the per-request timeout to replace the default, returned after %s", waited)
+	}
+
+	err := s.upsertChunks(ctx, col, []chroma.DocumentID{"a"}, []string{"alpha"}, []chroma.DocumentMetadata{chroma.NewDocumentMetadata()}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the embed timeout to end the write, got %v", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline for a zero timeout")
+	}
+	ctx, cancel = withTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected a deadline within a minute, got %v", deadline)
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// titleSettingKey stores a collection's TitleBoost in the settings table.
+const titleSettingKey = "title_boost"
+
+// titleCollectionSuffix names the companion collection holding one title
+// record per document. Companions are hidden from collection listings.
+const titleCollectionSuffix = "__titles"
+
+const (
+	// titleCandidates is how many title matches a boosted search considers.
+	titleCandidates = 5
+	// titleOverfetch widens the chunk candidates of a boosted search so
+	// reranking has documents to promote.
+	titleOverfetch = 2
+)
+
+// TitleBoost blends title similarity into a collection's search ranking.
+// Weight is the share of a result's distance taken from its document's
+// title distance; zero disables title embeddings.
+type TitleBoost struct {
+	Weight float64 `json:"weight"`
+}
+
+// Validate checks that the weight is within [0, 1].
+func (b TitleBoost) Validate() error {
+	if b.Weight < 0 || b.Weight > 1 {
+		return fmt.Errorf("title weight must be between 0 and 1, got %g", b.Weight)
+	}
+	return nil
+}
+
+func titleCollection(collection string) string {
+	return collection + titleCollectionSuffix
+}
+
+// titleID derives a document's title record ID from its file name.
+func titleID(filePath string) string {
+	h := sha256.Sum256([]byte("forge-title-v1\x00" + path.Clean(filepath.ToSlash(filePath))))
+	return fmt.Sprintf("%x", h[:8])
+}
+
+// documentTitle is the file name without its extension, followed by the
+// first heading when there is one.
+func documentTitle(filePath, heading string) string {
+	name := path.Base(filepath.ToSlash(filePath))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if first, _, _ := strings.Cut(heading, " > "); first != "" && !strings.EqualFold(first, name) {
+		return name + ": " + first
+	}
+	return name
+}
+
+// firstHeading returns the heading path of the first section that has one.
+func firstHeading(sections []docSection) string {
+	for _, sec := range sections {
+		if h, ok := sec.metadata[headingKey].(string); ok && h != "" {
+			return h
+		}
+	}
+	return ""
+}
+
+// CollectionTitleBoost returns a collection's title boost; zero when unset.
+func (s *IngestService) CollectionTitleBoost(collection string) (TitleBoost, error) {
+	var boost TitleBoost
+	if s.settings == nil {
+		return boost, nil
+	}
+	if _, err := s.settings.GetCollectionSetting(collection, titleSettingKey, &boost); err != nil {
+		return TitleBoost{}, err
+	}
+	return boost, nil
+}
+
+// SetCollectionTitleBoost stores a collection's title boost. Enabling it
+// embeds the titles of documents already in the collection, returning how
+// many were written.
+func (s *IngestService) SetCollectionTitleBoost(ctx context.Context, collection string, boost TitleBoost) (int, error) {
+	if s.settings == nil {
+		return 0, errNoSettingsStore
+	}
+	if err := boost.Validate(); err != nil {
+		return 0, err
+	}
+	if err := s.settings.SetCollectionSetting(collection, titleSettingKey, boost); err != nil {
+		return 0, err
+	}
+	if boost.Weight == 0 {
+		return 0, nil
+	}
+	return s.RebuildTitles(ctx, collection)
+}
+
+// RebuildTitles writes a title record for every document in a collection,
+// taking the title, or else the first heading, from each document's first
+// chunk.
+func (s *IngestService) RebuildTitles(ctx context.Context, collectionName string) (int, error) {
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get collection: %w", err)
+	}
+	records, err := scanRecords(ctx, collection, nil, chroma.IncludeMetadatas)
+	if err != nil {
+		return 0, err
+	}
+	type doc struct {
+		md5, heading string
+		index        int64
+	}
+	docs := map[string]*doc{}
+	for _, r := range records {
+		file, _ := r.Metadata[s.keys.FileName].(string)
+		if file == "" {
+			continue
+		}
+		index, _ := r.Metadata[s.keys.ChunkIndex].(int64)
+		d, ok := docs[file]
+		if ok && index >= d.index {
+			continue
+		}
+		if !ok {
+			d = &doc{}
+			docs[file] = d
+		}
+		d.md5, _ = r.Metadata[s.keys.FileMD5].(string)
+		if d.heading, _ = r.Metadata[titleKey].(string); d.heading == "" {
+			d.heading, _ = r.Metadata[headingKey].(string)
+		}
+		d.index = index
+	}
+	titles := make([]Record, 0, len(docs))
+	for file, d := range docs {
+		titles = append(titles, s.titleRecord(file, d.md5, d.heading))
+	}
+	if err := s.writeTitles(ctx, collectionName, titles); err != nil {
+		return 0, err
+	}
+	return len(titles), nil
+}
+
+func (s *IngestService) titleRecord(filePath, md5Hash, heading string) Record {
+	return Record{
+		ID:       titleID(filePath),
+		Document: documentTitle(filePath, heading),
+		Metadata: map[string]interface{}{s.keys.FileName: filePath, s.keys.FileMD5: md5Hash},
+	}
+}
+
+func (s *IngestService) writeTitles(ctx context.Context, collectionName string, titles []Record) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	companion, err := s.chromaDB.GetOrCreateCollection(ctx, titleCollection(collectionName))
+	if err != nil {
+		return fmt.Errorf("get/create title collection: %w", err)
+	}
+	if err := writeRecords(ctx, companion.Upsert, titles); err != nil {
+		return err
+	}
+	texts := make([]string, len(titles))
+	for i, t := range titles {
+		texts[i] = t.Document
+	}
+	s.costs.embedded(ctx, collectionName, texts...)
+	return nil
+}
+
+// storeTitle embeds an ingested document's title when the collection boosts
+// titles. Titles only refine ranking, so failures are logged, not returned.
+func (s *IngestService) storeTitle(ctx context.Context, collectionName, filePath, md5Hash, title string) {
+	boost, err := s.CollectionTitleBoost(collectionName)
+	if err != nil || boost.Weight == 0 {
+		return
+	}
+	if err := s.writeTitles(ctx, collectionName, []Record{s.titleRecord(filePath, md5Hash, title)}); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("file", filePath).Warn("Failed to store document title")
+	}
+}
+
+// boostTitles reranks results by blending each result's distance with its
+// document's title distance. Documents whose titles match but that have no
+// chunk among the results contribute their best chunk. Results from
+// documents outside the title matches take the worst title distance seen.
+func (s *IngestService) boostTitles(ctx context.Context, collection chroma.Collection, query string, clauses []chroma.WhereClause, results []SearchResult, files []string, weight float64) []SearchResult {
+	log := logging.FromContext(ctx)
+	companion, err := s.chromaDB.GetCollection(ctx, titleCollection(collection.Name()))
+	if err != nil {
+		// No titles embedded yet
+		return results
+	}
+	res, err := companion.Query(ctx, chroma.WithQueryTexts(query), chroma.WithNResults(titleCandidates))
+	if err != nil {
+		log.WithError(err).Warn("Title search failed; ranking by content only")
+		return results
+	}
+	s.costs.embedded(ctx, collection.Name(), query)
+	titleDistance := map[string]float32{}
+	var order []string
+	var worst float32
+	if groups := res.GetMetadatasGroups(); len(groups) > 0 {
+		distances := res.GetDistancesGroups()[0]
+		for i, md := range groups[0] {
+			file, ok := md.GetString(s.keys.FileName)
+			if !ok || i >= len(distances) {
+				continue
+			}
+			titleDistance[file] = float32(distances[i])
+			order = append(order, file)
+			worst = max(worst, float32(distances[i]))
+		}
+	}
+	if len(titleDistance) == 0 {
+		return results
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f] = true
+	}
+	for _, file := range order {
+		if seen[file] {
+			continue
+		}
+		where := andWhere(append(append([]chroma.WhereClause{}, clauses...), chroma.EqString(s.keys.FileName, file)))
+		best, err := collection.Query(ctx, chroma.WithQueryTexts(query), chroma.WithNResults(1), chroma.WithWhereQuery(where))
+		if err != nil {
+			log.WithError(err).WithField("file", file).Warn("Failed to fetch chunks of a title match")
+			continue
+		}
+		s.costs.embedded(ctx, collection.Name(), query)
+		extra, extraFiles := queryResults(best, s.keys.FileName)
+		results = append(results, extra...)
+		files = append(files, extraFiles...)
+	}
+
+	w := float32(weight)
+	for i := range results {
+		td, ok := titleDistance[files[i]]
+		if !ok {
+			td = worst
+		}
+		results[i].Distance = (1-w)*results[i].Distance + w*td
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	return results
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+func TestDocumentTitle(t *testing.T) {
+	cases := map[[2]string]string{
+		{"docs/install-guide.md", "Installing Forge > Linux"}: "install-guide: Installing Forge",
+		{"notes/Roadmap.docx", "roadmap"}:                     "Roadmap",
+		{"report.pdf", ""}:                                    "report",
+	}
+	for in, want := range cases {
+		if got := documentTitle(in[0], in[1]); got != want {
+			t.Errorf("documentTitle(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+// queryCollection answers every query with the same hits.
+type queryCollection struct {
+	chroma.Collection
+	name  string
+	space string
+	hits  []queryHit
+}
+
+type queryHit struct {
+	file     string
+	distance float64
+}
+
+func (c *queryCollection) Name() string { return c.name }
+
+func (c *queryCollection) Metadata() chroma.CollectionMetadata {
+	if c.space == "" {
+		return nil
+	}
+	return chroma.NewMetadata(chroma.NewStringAttribute(chroma.HNSWSpace, c.space))
+}
+
+func (c *queryCollection) Query(ctx context.Context, opts ...chroma.CollectionQueryOption) (chroma.QueryResult, error) {
+	res := &chroma.QueryResultImpl{IDLists: []chroma.DocumentIDs{nil}, DocumentsLists: []chroma.Documents{nil},
+		MetadatasLists: []chroma.DocumentMetadatas{nil}, DistancesLists: []embeddings.Distances{nil}}
+	for _, h := range c.hits {
+		res.IDLists[0] = append(res.IDLists[0], chroma.DocumentID(h.file))
+		res.DocumentsLists[0] = append(res.DocumentsLists[0], chroma.NewTextDocument(h.file))
+		res.MetadatasLists[0] = append(res.MetadatasLists[0], chroma.NewDocumentMetadata(chroma.NewStringAttribute("file_name", h.file)))
+		res.DistancesLists[0] = append(res.DistancesLists[0], embeddings.Distance(h.distance))
+	}
+	return res, nil
+}
+
+type titleClient struct {
+	chroma.Client
+	titles *queryCollection
+}
+
+func (c titleClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if !strings.HasSuffix(name, titleCollectionSuffix) {
+		return nil, errors.New("unexpected collection " + name)
+	}
+	return c.titles, nil
+}
+
+func TestBoostTitles(t *testing.T) {
+	titles := &queryCollection{name: "docs" + titleCollectionSuffix, hits: []queryHit{{"b.md", 0.1}, {"c.md", 0.2}}}
+	s := NewIngestService(titleClient{titles: titles})
+	// c.md has a matching title but no chunk among the results
+	docs := &queryCollection{name: "docs", hits: []queryHit{{"c.md", 0.5}}}
+	results := []SearchResult{{ID: "a", Distance: 0.3}, {ID: "b", Distance: 0.35}}
+
+	got := s.boostTitles(context.Background(), docs, "b", nil, results, []string{"a.md", "b.md"}, 0.5)
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	// b: 0.35/2+0.1/2, a: 0.3/2+0.2/2 (worst title), c: 0.5/2+0.2/2
+	if strings.Join(ids, ",") != "b,a,c.md" {
+		t.Errorf("unexpected order %v (%+v)", ids, got)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// tokenizerSettingKey stores a collection's tokenizer name in the settings table.
+const tokenizerSettingKey = "tokenizer"
+
+// DefaultTokenizer is used for collections without a configured tokenizer,
+// unless the service is given another default.
+const DefaultTokenizer = "cl100k"
+
+// Tokenizer counts tokens the way a model family does.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// tokenSplitter is implemented by tokenizers that can cut text at token
+// boundaries, so a line longer than a chunk is not embedded past the limit.
+type tokenSplitter interface {
+	// Split cuts text into consecutive pieces of at most maxTokens tokens
+	// that concatenate back to text.
+	Split(text string, maxTokens int) []string
+	// Tail returns the suffix of text holding its last n tokens.
+	Tail(text string, n int) string
+}
+
+var (
+	tokenizerMu        sync.Mutex
+	tokenizerFactories = map[string]func() (Tokenizer, error){}
+	tokenizerCache     = map[string]Tokenizer{}
+)
+
+// RegisterTokenizer adds a tokenizer to the registry. The factory runs once,
+// on first use.
+func RegisterTokenizer(name string, factory func() (Tokenizer, error)) {
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+	tokenizerFactories[name] = factory
+	delete(tokenizerCache, name)
+}
+
+// GetTokenizer returns a registered tokenizer by name.
+func GetTokenizer(name string) (Tokenizer, error) {
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+	if t, ok := tokenizerCache[name]; ok {
+		return t, nil
+	}
+	factory, ok := tokenizerFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q (available: %s)", name, strings.Join(tokenizerNames(), ", "))
+	}
+	t, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("load tokenizer %q: %w", name, err)
+	}
+	tokenizerCache[name] = t
+	return t, nil
+}
+
+// Tokenizers lists the registered tokenizer names.
+func Tokenizers() []string {
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+	return tokenizerNames()
+}
+
+func tokenizerNames() []string {
+	out := make([]string, 0, len(tokenizerFactories))
+	for n := range tokenizerFactories {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// whitespaceTokenizer counts whitespace-separated words.
+type whitespaceTokenizer struct{}
+
+func (whitespaceTokenizer) Name() string          { return "whitespace" }
+func (whitespaceTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+
+// Split cuts before every maxTokens-th word, leaving whitespace at the end
+// of the previous piece.
+func (whitespaceTokenizer) Split(text string, maxTokens int) []string {
+	var pieces []string
+	start, words, inWord := 0, 0, false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if !space && !inWord {
+			if words == maxTokens {
+				pieces = append(pieces, text[start:i])
+				start, words = i, 0
+			}
+			words++
+		}
+		inWord = !space
+	}
+	return append(pieces, text[start:])
+}
+
+// Tail starts at the n-th word from the end.
+func (whitespaceTokenizer) Tail(text string, n int) string {
+	var starts []int
+	inWord := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if !space && !inWord {
+			starts = append(starts, i)
+		}
+		inWord = !space
+	}
+	if n <= 0 {
+		return ""
+	}
+	if len(starts) <= n {
+		return text
+	}
+	return text[starts[len(starts)-n]:]
+}
+
+// bpeTokenizer counts tokens with a tiktoken BPE encoding.
+type bpeTokenizer struct {
+	name string
+	enc  *tiktoken.Tiktoken
+}
+
+func (t *bpeTokenizer) Name() string { return t.name }
+
+func (t *bpeTokenizer) Count(text string) int { return len(t.enc.EncodeOrdinary(text)) }
+
+// Split decodes runs of maxTokens tokens.
+func (t *bpeTokenizer) Split(text string, maxTokens int) []string {
+	tokens := t.enc.EncodeOrdinary(text)
+	var pieces []string
+	for len(tokens) > 0 {
+		n := t.cut(tokens, maxTokens)
+		pieces = append(pieces, t.enc.Decode(tokens[:n]))
+		tokens = tokens[n:]
+	}
+	return pieces
+}
+
+// Tail decodes the last n tokens, dropping up to 3 more when they would
+// start inside a character.
+func (t *bpeTokenizer) Tail(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	tokens := t.enc.EncodeOrdinary(text)
+	if len(tokens) <= n {
+		return text
+	}
+	for k := len(tokens) - n; k < len(tokens) && k <= len(tokens)-n+3; k++ {
+		if tail := t.enc.Decode(tokens[k:]); utf8.ValidString(tail) {
+			return tail
+		}
+	}
+	return t.enc.Decode(tokens[len(tokens)-n:])
+}
+
+// cut returns how many leading tokens to decode as one piece. BPE tokens
+// are byte sequences, so a cut inside a multi-byte character (at most 4
+// bytes, hence 3 tokens) moves back to its start, or forward to the next
+// character boundary when the run holds none; only text that isn't valid
+// UTF-8 to begin with is cut at maxTokens.
+func (t *bpeTokenizer) cut(tokens []int, maxTokens int) int {
+	if len(tokens) <= maxTokens {
+		return len(tokens)
+	}
+	for n := maxTokens; n > 0 && n >= maxTokens-3; n-- {
+		if utf8.ValidString(t.enc.Decode(tokens[:n])) {
+			return n
+		}
+	}
+	for n := maxTokens + 1; n <= len(tokens) && n <= maxTokens+8; n++ {
+		if utf8.ValidString(t.enc.Decode(tokens[:n])) {
+			return n
+		}
+	}
+	return maxTokens
+}
+
+func bpeFactory(name, encoding string) func() (Tokenizer, error) {
+	return func() (Tokenizer, error) {
+		enc, err := tiktoken.GetEncoding(encoding)
+		if err != nil {
+			return nil, err
+		}
+		return &bpeTokenizer{name: name, enc: enc}, nil
+	}
+}
+
+func init() {
+	// Encodings are embedded; never download vocabularies at runtime
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+
+	RegisterTokenizer("whitespace", func() (Tokenizer, error) { return whitespaceTokenizer{}, nil })
+	RegisterTokenizer("cl100k", bpeFactory("cl100k", tiktoken.MODEL_CL100K_BASE))
+	RegisterTokenizer("o200k", bpeFactory("o200k", tiktoken.MODEL_O200K_BASE))
+	// Llama 3's tokenizer extends cl100k's BPE merges; counts are a close
+	// approximation until a native vocabulary is registered.
+	RegisterTokenizer("llama", bpeFactory("llama", tiktoken.MODEL_CL100K_BASE))
+}
+
+// WithDefaultTokenizer sets the tokenizer for collections without one of
+// their own (default DefaultTokenizer); see GetTokenizer to validate it.
+func (s *IngestService) WithDefaultTokenizer(name string) *IngestService {
+	s.defaultTokenizer = name
+	return s
+}
+
+// CollectionTokenizer returns the tokenizer configured for a collection,
+// falling back to the service's default.
+func (s *IngestService) CollectionTokenizer(collection string) (Tokenizer, error) {
+	name := DefaultTokenizer
+	if s.defaultTokenizer != "" {
+		name = s.defaultTokenizer
+	}
+	if s.settings != nil {
+		if _, err := s.settings.GetCollectionSetting(collection, tokenizerSettingKey, &name); err != nil {
+			return nil, err
+		}
+	}
+	return GetTokenizer(name)
+}
+
+// SetCollectionTokenizer selects the tokenizer used to chunk and measure a
+// collection. Existing chunks are not re-chunked.
+func (s *IngestService) SetCollectionTokenizer(collection, name string) error {
+	if s.settings == nil {
+		return errNoSettingsStore
+	}
+	if _, err := GetTokenizer(name); err != nil {
+		return err
+	}
+	return s.settings.SetCollectionSetting(collection, tokenizerSettingKey, name)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTokenizers(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"whitespace", "hello  world\nagain", 3},
+		{"cl100k", "hello world", 2},
+		{"o200k", "hello world", 2},
+	}
+	for _, tc := range cases {
+		tok, err := GetTokenizer(tc.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := tok.Count(tc.text); got != tc.want {
+			t.Errorf("%s: expected %d tokens, got %d", tc.name, tc.want, got)
+		}
+	}
+	if _, err := GetTokenizer("nope"); err == nil {
+		t.Fatal("expected error for unknown tokenizer")
+	}
+}
+
+func TestChunkTextUsesTokenizer(t *testing.T) {
+	tok, _ := GetTokenizer("whitespace")
+	chunks := chunkText("a b c\nd e f\ng h i\n", 6, tok)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+}
+
+func TestChunkTextSplitsLongLines(t *testing.T) {
+	cjk := strings.Repeat("東京は日本の首都です。", 40)
+	for _, name := range []string{"whitespace", "cl100k"} {
+		tok, _ := GetTokenizer(name)
+		text := "short line\n" + cjk + "\n" + strings.Repeat("word ", 50) + "\nend"
+		chunks := chunkText(text, 20, tok)
+		if got := strings.Join(chunks, ""); got != text+"\n" {
+			t.Errorf("%s: chunks don't concatenate back to the text: %q", name, chunks)
+		}
+		for _, c := range chunks {
+			if !utf8.ValidString(c) {
+				t.Errorf("%s: chunk cuts a character: %q", name, c)
+			}
+			if n := tok.Count(c); n > 20 {
+				t.Errorf("%s: chunk has %d tokens: %q", name, n, c)
+			}
+		}
+	}
+	// Tokens of one character spanning the whole run aren't cut apart
+	tok, _ := GetTokenizer("cl100k")
+	for _, p := range tok.(tokenSplitter).Split(strings.Repeat("𠜎", 5), 1) {
+		if !utf8.ValidString(p) {
+			t.Errorf("piece cuts a character: %q", p)
+		}
+	}
+}
+
+func TestTokenizerTail(t *testing.T) {
+	tok, _ := GetTokenizer("whitespace")
+	splitter := tok.(tokenSplitter)
+	if got := splitter.Tail("one two  three\n", 2); got != "two  three\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := splitter.Tail("one two", 5); got != "one two" {
+		t.Errorf("expected the whole text, got %q", got)
+	}
+	cl100k, _ := GetTokenizer("cl100k")
+	text := strings.Repeat("東京は日本の首都です。", 5)
+	tail := cl100k.(tokenSplitter).Tail(text, 7)
+	if !utf8.ValidString(tail) || !strings.HasSuffix(text, tail) || cl100k.Count(tail) > 7 || tail == "" {
+		t.Errorf("unexpected tail %q", tail)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Metadata attached to chunks of transcribed audio.
+const (
+	startTimeKey = "start_time" // seconds from the start of the recording
+	endTimeKey   = "end_time"
+	languageKey  = "language" // as detected or configured for transcription; the programming language of code
+)
+
+// Transcription backends selectable through the transcription_backend
+// config value.
+const (
+	TranscribeWhisperCpp = "whisper.cpp"
+	TranscribeOpenAI     = "openai"
+)
+
+const (
+	openAITranscriptionURL = "https://api.openai.com/v1/audio/transcriptions"
+	openAIWhisperModel     = "whisper-1"
+	// DefaultTranscriptWindow is how much audio one transcript section covers.
+	DefaultTranscriptWindow = time.Minute
+)
+
+// ErrTranscriptionUnavailable is returned for audio files when no
+// transcription backend is configured.
+var ErrTranscriptionUnavailable = errors.New("audio transcription is not configured")
+
+// audioExtensions are ingested through transcription only.
+var audioExtensions = map[string]bool{".mp3": true, ".wav": true, ".m4a": true}
+
+// TranscriptSegment is a timed span of a transcript, in seconds.
+type TranscriptSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Transcript is the recognized speech of a recording.
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// Transcriber converts speech in an audio file to a timed transcript.
+type Transcriber interface {
+	Transcribe(ctx context.Context, filename string, content []byte) (*Transcript, error)
+}
+
+// TranscriptionConfig selects and configures a transcription backend.
+type TranscriptionConfig struct {
+	Backend string // "", TranscribeWhisperCpp or TranscribeOpenAI
+	// URL of the transcription endpoint; required for whisper.cpp (its
+	// server's /inference route), defaulted for OpenAI.
+	URL      string
+	APIKey   string
+	Model    string // OpenAI model, default whisper-1
+	Language string // ISO-639-1 hint; empty lets the backend detect it
+}
+
+// NewTranscriber builds the configured backend, or returns nil when Backend
+// is empty.
+func NewTranscriber(cfg TranscriptionConfig) (Transcriber, error) {
+	w := &WhisperTranscriber{URL: cfg.URL, APIKey: cfg.APIKey, Language: cfg.Language, client: &http.Client{Timeout: 15 * time.Minute}}
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case TranscribeWhisperCpp:
+		if w.URL == "" {
+			return nil, errors.New("whisper.cpp backend requires transcription_url")
+		}
+	case TranscribeOpenAI:
+		if w.URL == "" {
+			w.URL = openAITranscriptionURL
+		}
+		if w.APIKey == "" {
+			return nil, errors.New("openai transcription requires transcription_api_key")
+		}
+		w.Model = cfg.Model
+		if w.Model == "" {
+			w.Model = openAIWhisperModel
+		}
+	default:
+		return nil, fmt.Errorf("unknown transcription backend %q", cfg.Backend)
+	}
+	return w, nil
+}
+
+// WithTranscriber enables audio ingestion. Transcripts are split into
+// sections of about window each (DefaultTranscriptWindow when zero).
+func (s *IngestService) WithTranscriber(t Transcriber, window time.Duration) *IngestService {
+	if window <= 0 {
+		window = DefaultTranscriptWindow
+	}
+	s.transcriber = t
+	s.transcriptWindow = window
+	return s
+}
+
+func (s *IngestService) transcribe(ctx context.Context, filePath string, content []byte) ([]docSection, error) {
+	if s.transcriber == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTranscriptionUnavailable, filePath)
+	}
+	tr, err := s.transcriber.Transcribe(ctx, filePath, content)
+	if err != nil {
+		return nil, fmt.Errorf("transcribe %s: %w", filePath, err)
+	}
+	return transcriptSections(tr, s.transcriptWindow), nil
+}
+
+// transcriptSections groups segments into consecutive windows, one segment
+// per line, each section carrying the start and end time it covers. A
+// transcript without segments becomes a single untimed section.
+func transcriptSections(tr *Transcript, window time.Duration) []docSection {
+	metadata := func() map[string]interface{} {
+		md := map[string]interface{}{}
+		if tr.Language != "" {
+			md[languageKey] = tr.Language
+		}
+		return md
+	}
+	if len(tr.Segments) == 0 {
+		if strings.TrimSpace(tr.Text) == "" {
+			return nil
+		}
+		return []docSection{{text: strings.TrimSpace(tr.Text), metadata: metadata()}}
+	}
+
+	var sections []docSection
+	var lines []string
+	var cur docSection
+	flush := func(end float64) {
+		if len(lines) > 0 {
+			cur.text = strings.Join(lines, "\n")
+			cur.metadata[endTimeKey] = end
+			sections = append(sections, cur)
+			lines = nil
+		}
+	}
+	var start, last float64
+	for _, seg := range tr.Segments {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		if len(lines) > 0 && seg.Start-start >= window.Seconds() {
+			flush(last)
+		}
+		if len(lines) == 0 {
+			start = seg.Start
+			cur = docSection{metadata: metadata()}
+			cur.metadata[startTimeKey] = start
+		}
+		lines = append(lines, text)
+		last = seg.End
+	}
+	flush(last)
+	return sections
+}
+
+// WhisperTranscriber posts audio to a Whisper-compatible endpoint (the
+// whisper.cpp server or OpenAI's transcription API) and requests
+// verbose_json, which both answer with timed segments.
+type WhisperTranscriber struct {
+	URL      string
+	APIKey   string
+	Model    string
+	Language string
+	client   *http.Client
+}
+
+func (w *WhisperTranscriber) Transcribe(ctx context.Context, filename string, content []byte) (*Transcript, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", path.Base(filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{"response_format": "verbose_json"}
+	if w.Model != "" {
+		fields["model"] = w.Model
+		fields["timestamp_granularities[]"] = "segment"
+	}
+	if w.Language != "" {
+		fields["language"] = w.Language
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if w.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.APIKey)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("transcription service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var tr Transcript
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("decode transcription response: %w", err)
+	}
+	return &tr, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTranscriptSections(t *testing.T) {
+	tr := &Transcript{Language: "en", Segments: []TranscriptSegment{
+		{Start: 0, End: 4.5, Text: " Welcome to the call."},
+		{Start: 4.5, End: 30, Text: "First topic."},
+		{Start: 31, End: 33, Text: "  "},
+		{Start: 62, End: 70, Text: "Second topic."},
+	}}
+	sections := transcriptSections(tr, time.Minute)
+	if len(sections) != 2 {
+		t.Fatalf("expected 2 windows, got %+v", sections)
+	}
+	first, second := sections[0], sections[1]
+	if first.text != "Welcome to the call.\nFirst topic." || first.metadata[startTimeKey] != 0.0 || first.metadata[endTimeKey] != 30.0 {
+		t.Errorf("unexpected first window: %+v", first)
+	}
+	if second.metadata[startTimeKey] != 62.0 || second.metadata[endTimeKey] != 70.0 || second.metadata[languageKey] != "en" {
+		t.Errorf("unexpected second window: %+v", second)
+	}
+}
+
+func TestWhisperTranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil || header.Filename != "standup.mp3" || r.FormValue("response_format") != "verbose_json" ||
+			r.FormValue("model") != openAIWhisperModel || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		file.Close()
+		_, _ = w.Write([]byte(`{"text":"Hi","language":"english","segments":[{"start":0,"end":1.2,"text":"Hi"}]}`))
+	}))
+	defer srv.Close()
+
+	tr, err := NewTranscriber(TranscriptionConfig{Backend: TranscribeOpenAI, URL: srv.URL, APIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Transcribe(context.Background(), "calls/standup.mp3", []byte("audio"))
+	if err != nil || len(got.Segments) != 1 || got.Segments[0].End != 1.2 || got.Language != "english" {
+		t.Errorf("unexpected transcript %+v (%v)", got, err)
+	}
+
+	if _, err := NewIngestService(nil).extract(context.Background(), "docs", "standup.mp3", []byte("audio")); !errors.Is(err, ErrTranscriptionUnavailable) {
+		t.Errorf("expected ErrTranscriptionUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// trashSettingKey stores a collection's pending deletion in the settings table.
+const trashSettingKey = "trash"
+
+var (
+	// ErrCollectionTrashed is returned for a collection awaiting deletion.
+	ErrCollectionTrashed = errors.New("collection is in the trash")
+	// ErrNotInTrash is returned when restoring or purging a collection that
+	// isn't in the trash.
+	ErrNotInTrash = errors.New("collection is not in the trash")
+)
+
+// TrashEntry is a collection pending deletion. It is kept in Chroma, hidden
+// from listings and search, until PurgeAt.
+type TrashEntry struct {
+	Collection string    `json:"collection"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAt    time.Time `json:"purge_at"`
+}
+
+// WithTrashGrace sets how long deleted collections stay restorable; zero
+// (the default) deletes them right away.
+func (s *IngestService) WithTrashGrace(grace time.Duration) *IngestService {
+	s.trashGrace = grace
+	return s
+}
+
+// trashEntry returns the collection's pending deletion, or nil.
+func (s *IngestService) trashEntry(collection string) (*TrashEntry, error) {
+	if s.settings == nil {
+		return nil, nil
+	}
+	var entry *TrashEntry
+	if _, err := s.settings.GetCollectionSetting(collection, trashSettingKey, &entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// checkNotTrashed fails for a collection in the trash.
+func (s *IngestService) checkNotTrashed(collection string) error {
+	entry, err := s.trashEntry(collection)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		return fmt.Errorf("%w: %q is deleted on %s unless restored", ErrCollectionTrashed, collection, entry.PurgeAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// TrashCollection deletes a collection after the grace period, moving it
+// to the trash meanwhile. Without a grace period (or settings store) it is
+// deleted right away and the entry is nil. Protected collections require
+// force and an admin caller, as for DeleteCollection.
+func (s *IngestService) TrashCollection(ctx context.Context, name string, force bool) (*TrashEntry, error) {
+	if s.trashGrace <= 0 || s.settings == nil {
+		return nil, s.DeleteCollection(ctx, name, force)
+	}
+	if err := s.checkDestructive(ctx, name, force); err != nil {
+		return nil, err
+	}
+	if entry, err := s.trashEntry(name); err != nil || entry != nil {
+		return entry, err
+	}
+	if _, err := s.chromaDB.GetCollection(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to get collection '%s': %w", name, err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	entry := &TrashEntry{Collection: name, DeletedAt: now, PurgeAt: now.Add(s.trashGrace)}
+	if err := s.settings.SetCollectionSetting(name, trashSettingKey, entry); err != nil {
+		return nil, err
+	}
+	s.events.Publish(Event{Type: EventTrashed, Collection: name, Data: map[string]interface{}{"purge_at": entry.PurgeAt}})
+	return entry, nil
+}
+
+// RestoreCollection takes a collection out of the trash.
+func (s *IngestService) RestoreCollection(ctx context.Context, name string) error {
+	entry, err := s.trashEntry(name)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("%w: %q", ErrNotInTrash, name)
+	}
+	if err := s.settings.SetCollectionSetting(name, trashSettingKey, nil); err != nil {
+		return err
+	}
+	s.events.Publish(Event{Type: EventRestored, Collection: name})
+	return nil
+}
+
+// PurgeCollection deletes a collection in the trash now.
+func (s *IngestService) PurgeCollection(ctx context.Context, name string, force bool) error {
+	entry, err := s.trashEntry(name)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("%w: %q", ErrNotInTrash, name)
+	}
+	return s.DeleteCollection(ctx, name, force)
+}
+
+// ListTrash lists the collections pending deletion, soonest first.
+func (s *IngestService) ListTrash(ctx context.Context) ([]TrashEntry, error) {
+	if s.settings == nil {
+		return nil, nil
+	}
+	collections, err := s.chromaDB.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	entries := []TrashEntry{}
+	for _, c := range collections {
+		entry, err := s.trashEntry(c.Name())
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].PurgeAt.Before(entries[j].PurgeAt) })
+	return entries, nil
+}
+
+// PurgeExpiredTrash deletes the collections whose grace period has ended.
+// They were authorized when trashed, so protection is not checked again.
+func (s *IngestService) PurgeExpiredTrash(ctx context.Context) (int, error) {
+	entries, err := s.ListTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, entry := range entries {
+		if time.Now().Before(entry.PurgeAt) {
+			break
+		}
+		if err := s.dropCollection(ctx, entry.Collection); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("collection", entry.Collection).Warn("Failed to purge collection from the trash")
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunTrashPurger purges expired trash every interval until ctx is done.
+func (s *IngestService) RunTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.PurgeExpiredTrash(ctx); err != nil {
+				logging.FromContext(ctx).WithError(err).Warn("Failed to purge trash")
+			} else if n > 0 {
+				logging.FromContext(ctx).WithField("collections", n).Info("Purged collections from the trash")
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+)
+
+func (c *memClient) ListCollections(ctx context.Context, opts ...chroma.ListCollectionsOption) ([]chroma.Collection, error) {
+	var out []chroma.Collection
+	for _, col := range c.collections {
+		out = append(out, col)
+	}
+	return out, nil
+}
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	client := &memClient{collections: map[string]*memCollection{
+		"docs":  {name: "docs", docs: map[string]string{}},
+		"notes": {name: "notes", docs: map[string]string{}},
+	}}
+	settings := memSettings{}
+	s := NewIngestService(client).WithSettings(settings).WithTrashGrace(time.Hour)
+
+	entry, err := s.TrashCollection(ctx, "docs", false)
+	if err != nil || entry == nil {
+		t.Fatalf("TrashCollection() = %+v, %v", entry, err)
+	}
+	if got := entry.PurgeAt.Sub(entry.DeletedAt); got != time.Hour {
+		t.Errorf("expected an hour's grace, got %s", got)
+	}
+	if _, ok := client.collections["docs"]; !ok {
+		t.Fatal("expected the collection to be kept during the grace period")
+	}
+
+	// Trashed collections are hidden and refuse writes and searches
+	if names, _ := s.ListCollections(ctx); len(names) != 1 || names[0] != "notes" {
+		t.Errorf("expected only notes to be listed, got %v", names)
+	}
+	if _, err := s.MultiSearch(ctx, []string{"notes", "docs"}, "q", 5, nil, SearchOptions{}); !errors.Is(err, ErrCollectionTrashed) {
+		t.Errorf("expected search to be refused, got %v", err)
+	}
+	if _, err := s.CreateDocDirect(ctx, "docs", "a", "text", nil, nil, IngestSource{}); !errors.Is(err, ErrCollectionTrashed) {
+		t.Errorf("expected writes to be refused, got %v", err)
+	}
+	if trash, _ := s.ListTrash(ctx); len(trash) != 1 || trash[0] != *entry {
+		t.Errorf("ListTrash() = %+v", trash)
+	}
+
+	if err := s.RestoreCollection(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := s.ListCollections(ctx); len(names) != 2 {
+		t.Errorf("expected docs to be listed again, got %v", names)
+	}
+	if err := s.RestoreCollection(ctx, "docs"); !errors.Is(err, ErrNotInTrash) {
+		t.Errorf("expected ErrNotInTrash, got %v", err)
+	}
+
+	// Only expired entries are purged
+	past := time.Now().Add(-time.Minute)
+	settings.SetCollectionSetting("docs", trashSettingKey, TrashEntry{Collection: "docs", DeletedAt: past.Add(-time.Hour), PurgeAt: past})
+	if _, err := s.TrashCollection(ctx, "notes", false); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeExpiredTrash(ctx); err != nil || n != 1 {
+		t.Fatalf("PurgeExpiredTrash() = %d, %v", n, err)
+	}
+	if _, ok := client.collections["docs"]; ok {
+		t.Error("expected docs to be deleted")
+	}
+	if _, ok := client.collections["notes"]; !ok {
+		t.Error("expected notes to wait out its grace period")
+	}
+	if entry, _ := s.trashEntry("docs"); entry != nil {
+		t.Errorf("expected the trash entry to be cleared, got %+v", entry)
+	}
+
+	if err := s.PurgeCollection(ctx, "notes", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.collections["notes"]; ok {
+		t.Error("expected notes to be purged")
+	}
+}
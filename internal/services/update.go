@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// ErrFileNotFound is returned when a collection holds no chunks of a file.
+var ErrFileNotFound = errors.New("file not found in collection")
+
+// UpdateResult counts what replacing a file's text did to its chunks.
+type UpdateResult struct {
+	File      string `json:"file"`
+	Chunks    int    `json:"chunks"`
+	Unchanged int    `json:"unchanged"` // same position and text; metadata refreshed only
+	Moved     int    `json:"moved"`     // text kept at a new position, reusing its embedding
+	Embedded  int    `json:"embedded"`  // new or changed text
+	Deleted   int    `json:"deleted"`
+}
+
+// UpdateFileText replaces the text of an ingested file, re-chunking it and
+// writing only what changed: chunks whose position and text are unchanged
+// keep their vectors, moved text reuses its stored embedding, and chunks
+// that are no longer produced are deleted. The file's ACL, source and
+// caller metadata carry over unless opts sets them. The replaced text is
+// kept as a version (see WithVersionStore).
+func (s *IngestService) UpdateFileText(ctx context.Context, collectionName, filePath, text string, opts IngestOptions) (*UpdateResult, error) {
+	if IsSnapshotCollection(collectionName) {
+		return nil, ErrSnapshotReadOnly
+	}
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, filePath)
+	}
+	old, err := scanRecords(ctx, collection, chroma.EqString(s.keys.FileName, filePath), chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+	if len(old) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFileNotFound, collectionName, filePath)
+	}
+	inherited := s.inheritFileOptions(old[0].Metadata, &opts)
+
+	content := []byte(text)
+	md5Hash := fmt.Sprintf("%x", md5.Sum(content))
+	prepared, err := s.prepareChunks(ctx, collectionName, filePath, content, md5Hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, md := range prepared.metadatas {
+		for k, v := range inherited {
+			if _, ok := md[k]; !ok {
+				md[k] = v
+			}
+		}
+	}
+	result, err := s.rewriteFile(ctx, collection, collectionName, filePath, md5Hash, old, prepared)
+	if err != nil {
+		return nil, err
+	}
+
+	s.saveVersions(ctx, collectionName, filePath, old, md5Hash)
+	s.storeTitle(ctx, collectionName, filePath, md5Hash, prepared.title)
+	s.publishChange(EventIngested, collectionName, map[string]interface{}{"file": filePath, "chunks": len(prepared.ids), "source_id": opts.Source.ID})
+	return result, nil
+}
+
+// rewriteFile replaces a file's chunks old with prepared, writing only what
+// changed.
+func (s *IngestService) rewriteFile(ctx context.Context, collection chroma.Collection, collectionName, filePath, md5Hash string, old []Record, prepared *preparedChunks) (*UpdateResult, error) {
+	existing := make(map[string]bool, len(old))
+	vectors := make(map[string][]float32, len(old))
+	for _, r := range old {
+		existing[r.ID] = true
+		if r.Embedding != nil {
+			vectors[r.Document] = r.Embedding
+		}
+	}
+	var unchanged, moved, fresh chunkBatch
+	keep := make(map[string]bool, len(prepared.ids))
+	for i, id := range prepared.ids {
+		keep[id] = true
+		md := prepared.metadatas[i]
+		switch vec, ok := vectors[prepared.chunks[i]]; {
+		case existing[id]:
+			unchanged.add(id, prepared.chunks[i], md, nil)
+		case prepared.embeddings != nil:
+			fresh.add(id, prepared.chunks[i], md, prepared.embeddings[i])
+		case ok:
+			moved.add(id, prepared.chunks[i], md, embeddings.NewEmbeddingFromFloat32(vec))
+		default:
+			fresh.add(id, prepared.chunks[i], md, nil)
+		}
+	}
+	var stale []string
+	for _, r := range old {
+		if !keep[r.ID] {
+			stale = append(stale, r.ID)
+		}
+	}
+
+	finish, err := s.beginIntent(ctx, config.Intent{
+		Collection: collectionName,
+		Op:         IntentUpsert,
+		IDs:        prepared.ids,
+		Filter:     map[string]string{s.keys.FileMD5: md5Hash},
+		Ref:        filePath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.writeUpdate(ctx, collection, unchanged, moved, fresh)
+	finish(err)
+	if err != nil {
+		return nil, dimensionError(collectionName, err)
+	}
+	if len(stale) > 0 {
+		finish, err := s.beginIntent(ctx, config.Intent{Collection: collectionName, Op: IntentDelete, IDs: stale, Ref: filePath})
+		if err != nil {
+			return nil, err
+		}
+		docIDs := make([]chroma.DocumentID, len(stale))
+		for i, id := range stale {
+			docIDs[i] = chroma.DocumentID(id)
+		}
+		err = collection.Delete(ctx, chroma.WithIDsDelete(docIDs...))
+		finish(err)
+		if err != nil {
+			return nil, fmt.Errorf("delete removed chunks: %w", err)
+		}
+	}
+	return &UpdateResult{
+		File:      filePath,
+		Chunks:    len(prepared.ids),
+		Unchanged: len(unchanged.ids),
+		Moved:     len(moved.ids),
+		Embedded:  len(fresh.ids),
+		Deleted:   len(stale),
+	}, nil
+}
+
+// inheritFileOptions fills the ACL and source in opts from a file's current
+// chunk metadata, and returns the caller metadata to carry over when opts
+// sets none.
+func (s *IngestService) inheritFileOptions(md map[string]interface{}, opts *IngestOptions) map[string]interface{} {
+	if opts.ACL == nil {
+		for k, v := range md {
+			if p, ok := strings.CutPrefix(k, aclPrincipalKey); ok && v == true {
+				opts.ACL = append(opts.ACL, p)
+			}
+		}
+	}
+	if opts.Source.ID == "" {
+		opts.Source.ID, _ = md[s.keys.SourceID].(string)
+	}
+	if opts.Metadata != nil {
+		return nil
+	}
+	inherited := make(map[string]interface{})
+	for k, v := range md {
+		if strings.HasPrefix(k, userMetadataPrefix) {
+			inherited[k] = v
+		}
+	}
+	return inherited
+}
+
+// chunkBatch collects chunks for one write.
+type chunkBatch struct {
+	ids   []chroma.DocumentID
+	texts []string
+	mds   []chroma.DocumentMetadata
+	embs  []embeddings.Embedding
+}
+
+func (b *chunkBatch) add(id, text string, md map[string]interface{}, emb embeddings.Embedding) {
+	b.ids = append(b.ids, chroma.DocumentID(id))
+	b.texts = append(b.texts, text)
+	b.mds = append(b.mds, toDocumentMetadata(md))
+	if emb != nil {
+		b.embs = append(b.embs, emb)
+	}
+}
+
+// writeUpdate refreshes the metadata of unchanged chunks and upserts the
+// moved (with their stored vectors) and new ones.
+func (s *IngestService) writeUpdate(ctx context.Context, collection chroma.Collection, unchanged, moved, fresh chunkBatch) error {
+	for start := 0; start < len(unchanged.ids); start += getPageSize {
+		end := min(start+getPageSize, len(unchanged.ids))
+		wctx, cancel := withTimeout(ctx, s.timeouts.Embed)
+		err := collection.Update(wctx, chroma.WithIDsUpdate(unchanged.ids[start:end]...), chroma.WithMetadatasUpdate(unchanged.mds[start:end]...))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("update chunk metadata: %w", err)
+		}
+	}
+	for _, b := range []chunkBatch{moved, fresh} {
+		if len(b.ids) == 0 {
+			continue
+		}
+		if err := s.upsertChunks(ctx, collection, b.ids, b.texts, b.mds, b.embs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
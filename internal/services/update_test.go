@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+	"github.com/forrest321/chroma-go/pkg/embeddings"
+)
+
+// fileCollection holds one file's chunks and counts how they are written.
+type fileCollection struct {
+	chroma.Collection
+	records  map[string]Record
+	embedded int // chunks upserted without a vector
+	reused   int // chunks upserted with a vector
+	updated  int
+}
+
+func (c *fileCollection) Name() string { return "docs" }
+
+func (c *fileCollection) Get(ctx context.Context, opts ...chroma.CollectionGetOption) (chroma.GetResult, error) {
+	op, err := chroma.NewCollectionGetOp(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := &chroma.GetResultImpl{}
+	if op.Offset > 0 {
+		return res, nil
+	}
+	for id, r := range c.records {
+		res.Ids = append(res.Ids, chroma.DocumentID(id))
+		res.Documents = append(res.Documents, chroma.NewTextDocument(r.Document))
+		res.Metadatas = append(res.Metadatas, toDocumentMetadata(r.Metadata))
+		if slices.Contains(op.Include, chroma.IncludeEmbeddings) {
+			res.Embeddings = append(res.Embeddings, embeddings.NewEmbeddingFromFloat32(r.Embedding))
+		}
+	}
+	return res, nil
+}
+
+func (c *fileCollection) Upsert(ctx context.Context, opts ...chroma.CollectionAddOption) error {
+	op, err := chroma.NewCollectionAddOp(opts...)
+	if err != nil {
+		return err
+	}
+	for i, id := range op.Ids {
+		text := op.Documents[i].ContentString()
+		vec := []float32{float32(len(text))}
+		if len(op.Embeddings) > 0 {
+			c.reused++
+		} else {
+			c.embedded++
+		}
+		c.records[string(id)] = Record{ID: string(id), Document: text, Metadata: metadataToMap(op.Metadatas[i]), Embedding: vec}
+	}
+	return nil
+}
+
+func (c *fileCollection) Update(ctx context.Context, opts ...chroma.CollectionUpdateOption) error {
+	op, err := chroma.NewCollectionUpdateOp(opts...)
+	if err != nil {
+		return err
+	}
+	for i, id := range op.Ids {
+		r := c.records[string(id)]
+		r.Metadata = metadataToMap(op.Metadatas[i])
+		c.records[string(id)] = r
+		c.updated++
+	}
+	return nil
+}
+
+func (c *fileCollection) Delete(ctx context.Context, opts ...chroma.CollectionDeleteOption) error {
+	op, err := chroma.NewCollectionDeleteOp(opts...)
+	if err != nil {
+		return err
+	}
+	for _, id := range op.Ids {
+		delete(c.records, string(id))
+	}
+	return nil
+}
+
+type fileClient struct {
+	chroma.Client
+	collection *fileCollection
+}
+
+func (c fileClient) GetCollection(ctx context.Context, name string, opts ...chroma.GetCollectionOption) (chroma.Collection, error) {
+	if name != "docs" {
+		return nil, errors.New("collection not found")
+	}
+	return c.collection, nil
+}
+
+func TestUpdateFileText(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{}}
+	s := NewIngestService(fileClient{collection: col})
+
+	prepared, err := s.prepareChunks(ctx, "docs", "guide.md", []byte("# A\nalpha\n# B\nbeta\n# C\ngamma\n# D\ndelta"), "v1", IngestOptions{Metadata: map[string]interface{}{"team": "docs"}, ACL: []string{"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range prepared.ids {
+		col.records[id] = Record{ID: id, Document: prepared.chunks[i], Metadata: prepared.metadatas[i], Embedding: []float32{float32(i)}}
+	}
+
+	result, err := s.UpdateFileText(ctx, "docs", "guide.md", "# A\nalpha\n# C\ngamma\n# E\nepsilon\n# D\ndelta", IngestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UpdateResult{File: "guide.md", Chunks: 4, Unchanged: 2, Moved: 1, Embedded: 1, Deleted: 2}
+	if *result != want {
+		t.Errorf("got %+v, want %+v", *result, want)
+	}
+	if col.embedded != 1 || col.reused != 1 || col.updated != 2 || len(col.records) != 4 {
+		t.Errorf("unexpected writes: embedded %d, reused %d, updated %d, records %d", col.embedded, col.reused, col.updated, len(col.records))
+	}
+	for _, r := range col.records {
+		if r.Metadata["user_team"] != "docs" || r.Metadata[aclPrincipalKey+"alice"] != true || r.Metadata[DefaultSystemKeys.FileMD5] == "v1" {
+			t.Errorf("expected inherited metadata and the new hash, got %v", r.Metadata)
+		}
+	}
+
+	if _, err := s.UpdateFileText(ctx, "missing", "guide.md", "x", IngestOptions{}); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
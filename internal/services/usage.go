@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// ErrInvalidAPIKey is returned when a presented API key is unknown.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+var (
+	// ErrKeyScope is returned when a key restricted to one collection is used
+	// on another.
+	ErrKeyScope = errors.New("API key is restricted to another collection")
+	// ErrInvalidKeyScope is returned for a restricted key without a collection.
+	ErrInvalidKeyScope = errors.New("a restricted API key needs a collection")
+)
+
+// softLimitWarnRatio is the fraction of a soft limit at which a key is
+// considered to be approaching it.
+const softLimitWarnRatio = 0.8
+
+// Alert levels sent to quota webhooks.
+const (
+	AlertApproaching = "approaching"
+	AlertExceeded    = "exceeded"
+)
+
+type apiKeyCtxKey struct{}
+
+// WithAPIKey returns a context carrying the authenticated API key.
+func WithAPIKey(ctx context.Context, key config.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, key)
+}
+
+// APIKeyFromContext returns the key stored by WithAPIKey.
+func APIKeyFromContext(ctx context.Context) (config.APIKey, bool) {
+	k, ok := ctx.Value(apiKeyCtxKey{}).(config.APIKey)
+	return k, ok
+}
+
+type adminCtxKey struct{}
+
+// WithAdmin returns a context marking the request as authenticated with an
+// admin credential.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminCtxKey{}, true)
+}
+
+// AdminFromContext reports whether the request presented an admin credential.
+func AdminFromContext(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminCtxKey{}).(bool)
+	return admin
+}
+
+// KeyScope binds a key to a default collection, optionally restricting it
+// to that collection. An admin key grants admin scope instead, e.g. to
+// delete protected collections; it can't be restricted.
+type KeyScope struct {
+	Collection string `json:"collection"`
+	Restricted bool   `json:"restricted"`
+	Admin      bool   `json:"admin"`
+}
+
+func (sc KeyScope) validate() error {
+	if sc.Restricted && (sc.Collection == "" || sc.Admin) {
+		return ErrInvalidKeyScope
+	}
+	return nil
+}
+
+// ScopeCollections applies the request's API key to the collections a
+// request names: with none named it uses the key's default collection, and
+// a restricted key may only name its own.
+func ScopeCollections(ctx context.Context, collections []string) ([]string, error) {
+	key, ok := APIKeyFromContext(ctx)
+	if !ok || key.Collection == "" {
+		return collections, nil
+	}
+	if len(collections) == 0 {
+		return []string{key.Collection}, nil
+	}
+	if key.Restricted {
+		for _, c := range collections {
+			if c != key.Collection {
+				return nil, fmt.Errorf("%w: %q may only use %q", ErrKeyScope, key.Name, key.Collection)
+			}
+		}
+	}
+	return collections, nil
+}
+
+// KeyStore persists API keys and their daily usage.
+type KeyStore interface {
+	SaveAPIKey(k config.APIKey) error
+	GetAPIKey(id string) (config.APIKey, error)
+	GetAPIKeyByHash(hash string) (config.APIKey, error)
+	ListAPIKeys() ([]config.APIKey, error)
+	DeleteAPIKey(id string) error
+	AddUsage(keyID string, delta config.Usage) (config.Usage, error)
+	ListUsage(keyID, since string) ([]config.Usage, error)
+	MarkUsageAlert(keyID, day, metric string) (bool, error)
+}
+
+// QuotaAlert is the webhook payload sent when a key nears or passes a soft limit.
+type QuotaAlert struct {
+	KeyID   string `json:"key_id"`
+	KeyName string `json:"key_name"`
+	Day     string `json:"day"`
+	Metric  string `json:"metric"`
+	Level   string `json:"level"`
+	Used    int    `json:"used"`
+	Limit   int    `json:"limit"`
+}
+
+// UsageService manages API keys, tracks their ingest and search volume per
+// day and fires webhook alerts as keys approach their soft limits. Soft
+// limits are never enforced.
+type UsageService struct {
+	store      KeyStore
+	webhookURL string // fallback for keys without their own webhook
+	client     *http.Client
+	notifier   Notifier
+}
+
+func NewUsageService(store KeyStore, webhookURL string) *UsageService {
+	return &UsageService{store: store, webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// WithNotifier also delivers quota alerts through n.
+func (s *UsageService) WithNotifier(n Notifier) *UsageService {
+	s.notifier = n
+	return s
+}
+
+// CreateKey registers a key and returns it with its secret, which is not
+// stored and cannot be recovered.
+func (s *UsageService) CreateKey(name, webhookURL string, limits config.Usage, scope KeyScope) (config.APIKey, string, error) {
+	if name == "" {
+		return config.APIKey{}, "", errors.New("key name is required")
+	}
+	if err := scope.validate(); err != nil {
+		return config.APIKey{}, "", err
+	}
+	id, err := randomHex(6)
+	if err != nil {
+		return config.APIKey{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return config.APIKey{}, "", err
+	}
+	secret = "fk_" + secret
+	limits.Day = ""
+	key := config.APIKey{ID: id, Name: name, Hash: hashSecret(secret), WebhookURL: webhookURL, Limits: limits, Collection: scope.Collection, Restricted: scope.Restricted, Admin: scope.Admin, CreatedAt: time.Now()}
+	if err := s.store.SaveAPIKey(key); err != nil {
+		return config.APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+// Authenticate resolves a presented secret to its key.
+func (s *UsageService) Authenticate(secret string) (config.APIKey, error) {
+	key, err := s.store.GetAPIKeyByHash(hashSecret(secret))
+	if errors.Is(err, config.ErrNotFound) {
+		return config.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, err
+}
+
+// Key returns a key by ID.
+func (s *UsageService) Key(id string) (config.APIKey, error) {
+	return s.store.GetAPIKey(id)
+}
+
+func (s *UsageService) ListKeys() ([]config.APIKey, error) {
+	return s.store.ListAPIKeys()
+}
+
+// SetKeyScope changes a key's default collection, restriction and admin scope.
+func (s *UsageService) SetKeyScope(id string, scope KeyScope) (config.APIKey, error) {
+	if err := scope.validate(); err != nil {
+		return config.APIKey{}, err
+	}
+	key, err := s.store.GetAPIKey(id)
+	if err != nil {
+		return config.APIKey{}, err
+	}
+	key.Collection, key.Restricted, key.Admin = scope.Collection, scope.Restricted, scope.Admin
+	if err := s.store.SaveAPIKey(key); err != nil {
+		return config.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *UsageService) DeleteKey(id string) error {
+	return s.store.DeleteAPIKey(id)
+}
+
+// KeyUsage reports a key's soft limits and its usage over the last days.
+type KeyUsage struct {
+	Key     config.APIKey  `json:"key"`
+	Today   config.Usage   `json:"today"`
+	History []config.Usage `json:"history"`
+}
+
+// Usage returns a key's usage for the last days (including today).
+func (s *UsageService) Usage(id string, days int) (*KeyUsage, error) {
+	key, err := s.store.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if days <= 0 {
+		days = 30
+	}
+	now := time.Now().UTC()
+	history, err := s.store.ListUsage(id, now.AddDate(0, 0, -(days-1)).Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	report := &KeyUsage{Key: key, Today: config.Usage{Day: now.Format(time.DateOnly)}, History: history}
+	if len(history) > 0 && history[0].Day == report.Today.Day {
+		report.Today = history[0]
+	}
+	return report, nil
+}
+
+// Record adds delta to the usage of the request's API key, if any, and
+// alerts when a soft limit is approached or exceeded.
+func (s *UsageService) Record(ctx context.Context, delta config.Usage) {
+	key, ok := APIKeyFromContext(ctx)
+	if !ok {
+		return
+	}
+	delta.Day = time.Now().UTC().Format(time.DateOnly)
+	total, err := s.store.AddUsage(key.ID, delta)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("key", key.ID).Warn("Failed to record API key usage")
+		return
+	}
+	for _, a := range softLimitAlerts(key, total) {
+		fresh, err := s.store.MarkUsageAlert(key.ID, a.Day, a.Metric+":"+a.Level)
+		if err != nil || !fresh {
+			continue
+		}
+		go s.sendAlert(ctx, key, a)
+	}
+}
+
+// softLimitAlerts lists the alert levels reached by usage.
+func softLimitAlerts(key config.APIKey, u config.Usage) []QuotaAlert {
+	metrics := []struct {
+		name        string
+		used, limit int
+	}{
+		{"searches", u.Searches, key.Limits.Searches},
+		{"ingest_files", u.IngestFiles, key.Limits.IngestFiles},
+		{"ingest_chunks", u.IngestChunks, key.Limits.IngestChunks},
+	}
+	var out []QuotaAlert
+	for _, m := range metrics {
+		if m.limit <= 0 {
+			continue
+		}
+		level := ""
+		switch {
+		case m.used >= m.limit:
+			level = AlertExceeded
+		case float64(m.used) >= softLimitWarnRatio*float64(m.limit):
+			level = AlertApproaching
+		default:
+			continue
+		}
+		out = append(out, QuotaAlert{KeyID: key.ID, KeyName: key.Name, Day: u.Day, Metric: m.name, Level: level, Used: m.used, Limit: m.limit})
+	}
+	return out
+}
+
+func (s *UsageService) sendAlert(ctx context.Context, key config.APIKey, alert QuotaAlert) {
+	url := key.WebhookURL
+	if url == "" {
+		url = s.webhookURL
+	}
+	log := logging.FromContext(ctx).WithFields(logrus.Fields{"key": key.ID, "metric": alert.Metric, "level": alert.Level})
+	notify(s.notifier, EventQuotaAlert, alert)
+	if url == "" {
+		if s.notifier == nil {
+			log.Warn("API key soft limit reached; no webhook or notifier configured")
+		}
+		return
+	}
+	body, _ := json.Marshal(alert)
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Error("Failed to send quota alert")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.WithField("status", resp.Status).Error("Quota alert webhook rejected alert")
+	}
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
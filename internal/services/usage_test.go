@@ -0,0 +1,24 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func TestSoftLimitAlerts(t *testing.T) {
+	key := config.APIKey{ID: "k", Limits: config.Usage{Searches: 10, IngestChunks: 100}}
+	alerts := softLimitAlerts(key, config.Usage{Day: "2026-01-02", Searches: 8, IngestFiles: 50, IngestChunks: 120})
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", alerts)
+	}
+	if alerts[0].Metric != "searches" || alerts[0].Level != AlertApproaching {
+		t.Fatalf("expected approaching searches alert, got %+v", alerts[0])
+	}
+	if alerts[1].Metric != "ingest_chunks" || alerts[1].Level != AlertExceeded {
+		t.Fatalf("expected exceeded chunk alert, got %+v", alerts[1])
+	}
+	if got := softLimitAlerts(key, config.Usage{Searches: 7}); len(got) != 0 {
+		t.Fatalf("expected no alerts below the warning ratio, got %+v", got)
+	}
+}
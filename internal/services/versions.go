@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	chroma "github.com/forrest321/chroma-go/pkg/api/v2"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+var (
+	// ErrVersioningDisabled is returned by version endpoints when no version
+	// store is configured.
+	ErrVersioningDisabled = errors.New("document versioning is not configured")
+	// ErrNotVersioned is returned for a document that is not part of an
+	// ingested file, such as one created directly.
+	ErrNotVersioned = errors.New("document has no file name; only ingested files are versioned")
+	// ErrVersionNotFound is returned for a version a file doesn't have.
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// VersionStore keeps the superseded versions of ingested files.
+type VersionStore interface {
+	AddDocVersion(v config.DocVersion, keep int) (int, error)
+	DocVersions(collection, file string) ([]config.DocVersion, error)
+	GetDocVersion(collection, file string, version int) (config.DocVersion, error)
+	ForgetDocVersions(collection string) error
+}
+
+// WithVersionStore keeps the chunks a file had before a replacing ingest,
+// a text update or a rollback, up to keep versions per file, so the file
+// can be rolled back. Embeddings are not kept: restored text is embedded
+// again unless the current version still holds it. Zero keep disables it.
+func (s *IngestService) WithVersionStore(store VersionStore, keep int) *IngestService {
+	if keep > 0 {
+		s.versions, s.versionsKeep = store, keep
+	}
+	return s
+}
+
+// DocHistory is the file a document belongs to, its current content hash
+// and the earlier versions kept of it.
+type DocHistory struct {
+	File     string              `json:"file"`
+	FileMD5  string              `json:"file_md5"`
+	Versions []config.DocVersion `json:"versions"`
+}
+
+// DocVersions lists the kept versions of the file the document id belongs
+// to, newest first.
+func (s *IngestService) DocVersions(ctx context.Context, collectionName, id string) (*DocHistory, error) {
+	if s.versions == nil {
+		return nil, ErrVersioningDisabled
+	}
+	file, md5Hash, err := s.docFile(ctx, collectionName, id)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versions.DocVersions(collectionName, file)
+	if err != nil {
+		return nil, err
+	}
+	return &DocHistory{File: file, FileMD5: md5Hash, Versions: versions}, nil
+}
+
+// RollbackDoc restores a kept version of the file the document id belongs
+// to, writing only the chunks that differ from the current ones. The
+// current version is kept in turn, so a rollback can be undone.
+func (s *IngestService) RollbackDoc(ctx context.Context, collectionName, id string, version int) (*UpdateResult, error) {
+	if s.versions == nil {
+		return nil, ErrVersioningDisabled
+	}
+	if IsSnapshotCollection(collectionName) {
+		return nil, ErrSnapshotReadOnly
+	}
+	file, _, err := s.docFile(ctx, collectionName, id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.versions.GetDocVersion(collectionName, file, version)
+	if errors.Is(err, config.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s has no version %d", ErrVersionNotFound, file, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	dec := json.NewDecoder(strings.NewReader(v.Records))
+	dec.UseNumber() // keep integer metadata (e.g. chunk_index) as ints
+	if err := dec.Decode(&records); err != nil {
+		return nil, fmt.Errorf("decode version %d of %s: %w", version, file, err)
+	}
+	prepared := &preparedChunks{}
+	for _, r := range records {
+		prepared.ids = append(prepared.ids, r.ID)
+		prepared.chunks = append(prepared.chunks, r.Document)
+		prepared.metadatas = append(prepared.metadatas, r.Metadata)
+		if prepared.title == "" {
+			prepared.title, _ = r.Metadata[titleKey].(string)
+		}
+	}
+
+	collection, err := s.chromaDB.GetCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("get collection %q: %w", collectionName, err)
+	}
+	current, err := scanRecords(ctx, collection, chroma.EqString(s.keys.FileName, file), chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.rewriteFile(ctx, collection, collectionName, file, v.MD5, current, prepared)
+	if err != nil {
+		return nil, err
+	}
+	s.saveVersions(ctx, collectionName, file, current, v.MD5)
+	s.storeTitle(ctx, collectionName, file, v.MD5, prepared.title)
+	s.publishChange(EventIngested, collectionName, map[string]interface{}{"file": file, "chunks": len(prepared.ids), "version": version})
+	return result, nil
+}
+
+// docFile returns the file name and content hash of a document the caller
+// can see.
+func (s *IngestService) docFile(ctx context.Context, collectionName, id string) (string, string, error) {
+	doc, err := s.GetDoc(ctx, collectionName, id, false)
+	if err != nil {
+		return "", "", err
+	}
+	file, _ := doc.Metadata[s.keys.FileName].(string)
+	if file == "" {
+		return "", "", fmt.Errorf("%w: %s/%s", ErrNotVersioned, collectionName, id)
+	}
+	md5Hash, _ := doc.Metadata[s.keys.FileMD5].(string)
+	return file, md5Hash, nil
+}
+
+// replacedRecords reads a file's chunks before a replacing ingest
+// overwrites them, when versions are kept and there is something to
+// replace.
+func (s *IngestService) replacedRecords(ctx context.Context, collection chroma.Collection, filePath string, replaced []string) ([]Record, error) {
+	if s.versions == nil || len(replaced) == 0 {
+		return nil, nil
+	}
+	return scanRecords(ctx, collection, chroma.EqString(s.keys.FileName, filePath))
+}
+
+// saveVersions keeps the chunks of a file that are not its current version
+// md5Hash, one version per content hash. Failures are logged: the write
+// they follow has already happened.
+func (s *IngestService) saveVersions(ctx context.Context, collectionName, filePath string, records []Record, md5Hash string) {
+	if s.versions == nil {
+		return
+	}
+	byMD5 := map[string][]Record{}
+	var order []string
+	for _, r := range records {
+		md5, _ := r.Metadata[s.keys.FileMD5].(string)
+		if name, _ := r.Metadata[s.keys.FileName].(string); name != filePath || md5 == md5Hash {
+			continue
+		}
+		if byMD5[md5] == nil {
+			order = append(order, md5)
+		}
+		byMD5[md5] = append(byMD5[md5], Record{ID: r.ID, Document: r.Document, Metadata: r.Metadata})
+	}
+	for _, md5 := range order {
+		chunks := byMD5[md5]
+		sort.SliceStable(chunks, func(i, j int) bool {
+			return toInt64(chunks[i].Metadata[s.keys.ChunkIndex]) < toInt64(chunks[j].Metadata[s.keys.ChunkIndex])
+		})
+		body, err := json.Marshal(chunks)
+		if err == nil {
+			_, err = s.versions.AddDocVersion(config.DocVersion{Collection: collectionName, File: filePath, MD5: md5, Chunks: len(chunks), Records: string(body)}, s.versionsKeep)
+		}
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("file", filePath).Warn("Failed to keep document version")
+		}
+	}
+}
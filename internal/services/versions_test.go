@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+// memVersionStore keeps versions in memory, numbered per file.
+type memVersionStore struct {
+	versions []config.DocVersion
+}
+
+func (m *memVersionStore) AddDocVersion(v config.DocVersion, keep int) (int, error) {
+	v.Version = len(m.versions) + 1
+	m.versions = append(m.versions, v)
+	return v.Version, nil
+}
+
+func (m *memVersionStore) DocVersions(collection, file string) ([]config.DocVersion, error) {
+	out := []config.DocVersion{}
+	for i := len(m.versions) - 1; i >= 0; i-- {
+		v := m.versions[i]
+		v.Records = ""
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (m *memVersionStore) GetDocVersion(collection, file string, version int) (config.DocVersion, error) {
+	if version < 1 || version > len(m.versions) {
+		return config.DocVersion{}, config.ErrNotFound
+	}
+	return m.versions[version-1], nil
+}
+
+func (m *memVersionStore) ForgetDocVersions(collection string) error {
+	m.versions = nil
+	return nil
+}
+
+func TestDocVersions(t *testing.T) {
+	ctx := context.Background()
+	col := &fileCollection{records: map[string]Record{}}
+	s := NewIngestService(fileClient{collection: col})
+	if _, err := s.DocVersions(ctx, "docs", "x"); !errors.Is(err, ErrVersioningDisabled) {
+		t.Errorf("expected ErrVersioningDisabled, got %v", err)
+	}
+	store := &memVersionStore{}
+	s.WithVersionStore(store, 5)
+
+	prepared, err := s.prepareChunks(ctx, "docs", "guide.md", []byte("# A\nalpha\n# B\nbeta"), "v1", IngestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range prepared.ids {
+		col.records[id] = Record{ID: id, Document: prepared.chunks[i], Metadata: prepared.metadatas[i], Embedding: []float32{float32(i)}}
+	}
+	original := documents(col)
+	if _, err := s.UpdateFileText(ctx, "docs", "guide.md", "# A\nalpha\n# C\ngamma", IngestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.DocVersions(ctx, "docs", prepared.ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.File != "guide.md" || history.FileMD5 == "v1" || len(history.Versions) != 1 || history.Versions[0].MD5 != "v1" || history.Versions[0].Chunks != 2 {
+		t.Fatalf("expected the original kept as version 1, got %+v", history)
+	}
+
+	result, err := s.RollbackDoc(ctx, "docs", prepared.ids[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 1 || result.Embedded != 1 || result.Deleted != 1 {
+		t.Errorf("expected the unchanged chunk kept and the other restored, got %+v", result)
+	}
+	if got := documents(col); len(got) != len(original) || got[0] != original[0] || got[1] != original[1] {
+		t.Errorf("expected the original text back, got %q", got)
+	}
+	for _, r := range col.records {
+		if r.Metadata[DefaultSystemKeys.FileMD5] != "v1" || toInt64(r.Metadata[DefaultSystemKeys.ChunkIndex]) > 1 {
+			t.Errorf("expected the original metadata back, got %v", r.Metadata)
+		}
+	}
+	if len(store.versions) != 2 {
+		t.Errorf("expected the updated text kept in turn, got %d versions", len(store.versions))
+	}
+
+	if _, err := s.RollbackDoc(ctx, "docs", prepared.ids[0], 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("expected ErrVersionNotFound, got %v", err)
+	}
+}
+
+func documents(col *fileCollection) []string {
+	var out []string
+	for _, r := range col.records {
+		out = append(out, r.Document)
+	}
+	sort.Strings(out)
+	return out
+}
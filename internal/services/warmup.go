@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/typicalfo/forge/backend/internal/config"
+	"github.com/typicalfo/forge/backend/internal/logging"
+)
+
+// warmupK is the result count used when replaying queries; it matches the
+// /search default so replays populate the cache entries clients hit.
+const warmupK = 5
+
+// QueryStatsStore records search frequency for warm-up.
+type QueryStatsStore interface {
+	RecordQuery(collection, query string) error
+	TopQueries(n int) ([]config.QueryStat, error)
+	TopCollections(n int) ([]string, error)
+}
+
+// WithQueryStats records each searched collection/query pair.
+func (s *IngestService) WithQueryStats(store QueryStatsStore) *IngestService {
+	s.queryStats = store
+	return s
+}
+
+func (s *IngestService) recordQuery(ctx context.Context, collection, query string) {
+	if s.queryStats == nil {
+		return
+	}
+	if err := s.queryStats.RecordQuery(collection, query); err != nil {
+		logging.FromContext(ctx).WithError(err).Debug("Failed to record query stats")
+	}
+}
+
+// WarmupOptions selects what to preload at startup.
+type WarmupOptions struct {
+	// Collections are always opened.
+	Collections []string
+	// TopCollections additionally opens the most searched collections.
+	TopCollections int
+	// ReplayQueries replays this many of the most frequent queries.
+	ReplayQueries int
+}
+
+// WarmupReport summarizes a warm-up run.
+type WarmupReport struct {
+	Collections []string `json:"collections"`
+	Replayed    int      `json:"replayed"`
+	Errors      []string `json:"errors,omitempty"`
+	Duration    string   `json:"duration"`
+}
+
+// WarmUp opens collections, primes the embedding function with a throwaway
+// query per collection and optionally replays frequent queries, so the first
+// real requests don't pay connection and model-loading costs.
+func (s *IngestService) WarmUp(ctx context.Context, opts WarmupOptions) *WarmupReport {
+	start := time.Now()
+	report := &WarmupReport{}
+	names := append([]string(nil), opts.Collections...)
+	if opts.TopCollections > 0 && s.queryStats != nil {
+		top, err := s.queryStats.TopCollections(opts.TopCollections)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		names = append(names, top...)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] || ctx.Err() != nil {
+			continue
+		}
+		seen[name] = true
+		collection, err := s.chromaDB.GetCollection(ctx, name)
+		if err != nil {
+			report.Errors = append(report.Errors, name+": "+err.Error())
+			continue
+		}
+		if n, err := collection.Count(ctx); err == nil && n > 0 {
+			// Loads the embedding model and opens the HTTP connection
+			if _, err := s.SearchWithOptions(ctx, name, "warm-up", 1, nil, SearchOptions{}); err != nil {
+				report.Errors = append(report.Errors, name+": "+err.Error())
+				continue
+			}
+		}
+		report.Collections = append(report.Collections, name)
+	}
+
+	if opts.ReplayQueries > 0 && s.queryStats != nil {
+		queries, err := s.queryStats.TopQueries(opts.ReplayQueries)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		for _, q := range queries {
+			if ctx.Err() != nil {
+				break
+			}
+			if _, err := s.SearchWithFallback(ctx, q.Collection, q.Query, warmupK, nil, SearchOptions{}); err != nil {
+				report.Errors = append(report.Errors, q.Collection+": "+err.Error())
+				continue
+			}
+			report.Replayed++
+		}
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"collections": len(report.Collections),
+		"replayed":    report.Replayed,
+		"errors":      len(report.Errors),
+		"duration":    report.Duration,
+	}).Info("Warm-up complete")
+	return report
+}
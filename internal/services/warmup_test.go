@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/typicalfo/forge/backend/internal/config"
+)
+
+func (c replicaCollection) Count(ctx context.Context) (int, error) { return len(c.records), nil }
+
+// memQueryStats returns fixed top collections and queries.
+type memQueryStats struct {
+	collections []string
+	queries     []config.QueryStat
+	recorded    []string
+}
+
+func (m *memQueryStats) RecordQuery(collection, query string) error {
+	m.recorded = append(m.recorded, collection+": "+query)
+	return nil
+}
+
+func (m *memQueryStats) TopQueries(n int) ([]config.QueryStat, error) {
+	return m.queries[:min(n, len(m.queries))], nil
+}
+
+func (m *memQueryStats) TopCollections(n int) ([]string, error) {
+	return m.collections[:min(n, len(m.collections))], nil
+}
+
+func TestWarmUp(t *testing.T) {
+	col := &replicaCollection{&fileCollection{records: map[string]Record{"a": {ID: "a", Document: "alpha"}}}}
+	var down bool
+	stats := &memQueryStats{
+		collections: []string{"docs", "gone", "ignored"},
+		queries:     []config.QueryStat{{Collection: "docs", Query: "alpha"}, {Collection: "gone", Query: "beta"}},
+	}
+	s := NewIngestService(replicaClient{collection: col, down: &down}).WithQueryStats(stats)
+
+	report := s.WarmUp(context.Background(), WarmupOptions{Collections: []string{"docs"}, TopCollections: 2, ReplayQueries: 5})
+	if !reflect.DeepEqual(report.Collections, []string{"docs"}) {
+		t.Errorf("expected docs opened once, got %v", report.Collections)
+	}
+	if report.Replayed != 1 {
+		t.Errorf("expected one query replayed, got %d", report.Replayed)
+	}
+	if len(report.Errors) != 2 {
+		t.Errorf("expected the missing collection reported when opened and replayed, got %q", report.Errors)
+	}
+	if len(stats.recorded) != 0 {
+		t.Errorf("expected warm-up searches not counted as queries, got %q", stats.recorded)
+	}
+
+	// Without stats only the named collections are opened
+	s = NewIngestService(replicaClient{collection: col, down: &down})
+	if report := s.WarmUp(context.Background(), WarmupOptions{Collections: []string{"docs"}, TopCollections: 2, ReplayQueries: 5}); len(report.Collections) != 1 || report.Replayed != 0 || len(report.Errors) != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
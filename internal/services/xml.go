@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidXMLMapping is returned for a mapping with a bad XPath.
+var ErrInvalidXMLMapping = errors.New("invalid XML mapping")
+
+// xmlRecordKey numbers the records of a mapped XML file, from 1.
+const xmlRecordKey = "xml_record"
+
+// xmlExtensions are the file types an XML mapping applies to.
+var xmlExtensions = map[string]bool{".xml": true, ".dita": true, ".ditamap": true, ".dbk": true}
+
+// XMLMapping selects the content of XML files with XPath (see xpath.go for
+// the supported subset). Records selects the elements that each become a
+// section, e.g. "//url" in a sitemap or "//topic" in DITA; empty treats the
+// whole document as one. Body selects a record's text, relative to it
+// (default: all of its text). Fields map metadata names to paths, relative
+// to the record or absolute; each is stored as user_<name>, multiple
+// matches joined with ", ".
+type XMLMapping struct {
+	Records string            `json:"records,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// compiledXMLMapping holds a mapping's parsed expressions.
+type compiledXMLMapping struct {
+	records *xpathExpr
+	body    *xpathExpr
+	fields  map[string]*xpathExpr
+	names   []string // field names, sorted
+}
+
+// compile parses the mapping's expressions.
+func (m XMLMapping) compile() (*compiledXMLMapping, error) {
+	c := &compiledXMLMapping{fields: make(map[string]*xpathExpr, len(m.Fields))}
+	var err error
+	if m.Records != "" {
+		if c.records, err = compileXPath(m.Records); err != nil {
+			return nil, fmt.Errorf("%w: records: %v", ErrInvalidXMLMapping, err)
+		}
+	}
+	if m.Body != "" {
+		if c.body, err = compileXPath(m.Body); err != nil {
+			return nil, fmt.Errorf("%w: body: %v", ErrInvalidXMLMapping, err)
+		}
+	}
+	for name, expr := range m.Fields {
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty field name", ErrInvalidXMLMapping)
+		}
+		if c.fields[name], err = compileXPath(expr); err != nil {
+			return nil, fmt.Errorf("%w: field %s: %v", ErrInvalidXMLMapping, name, err)
+		}
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+	return c, nil
+}
+
+// Validate checks that every expression compiles.
+func (m XMLMapping) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+// isXMLFile reports whether an XML mapping applies to filePath.
+func isXMLFile(filePath string) bool {
+	return xmlExtensions[strings.ToLower(path.Ext(filePath))]
+}
+
+// extractXML maps an XML document to sections, one per record with text.
+func extractXML(content []byte, m XMLMapping) ([]docSection, error) {
+	c, err := m.compile()
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseXMLTree(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse XML: %w", err)
+	}
+	records := []*xnode{root}
+	if c.records != nil {
+		records = c.records.eval(root)
+	}
+	var sections []docSection
+	for i, rec := range records {
+		text := strings.TrimSpace(rec.stringValue())
+		if c.body != nil {
+			text = strings.Join(c.body.strings(rec), "\n")
+		}
+		if text == "" {
+			continue
+		}
+		md := make(map[string]interface{}, len(c.names)+1)
+		if c.records != nil {
+			md[xmlRecordKey] = i + 1
+		}
+		for _, name := range c.names {
+			if values := c.fields[name].strings(rec); len(values) > 0 {
+				md[userMetadataPrefix+name] = strings.Join(values, ", ")
+			}
+		}
+		sections = append(sections, docSection{text: text, metadata: md})
+	}
+	return sections, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const ditaTopic = `<?xml version="1.0"?>
+<!DOCTYPE topic PUBLIC "-//OASIS//DTD DITA Topic//EN" "topic.dtd">
+<map title="Guide">
+  <topic id="install" audience="admin">
+    <title>Installing</title>
+    <body>
+      <p>Run the <cmd>installer</cmd> as root.</p>
+      <p>Then reboot&nbsp;once.</p>
+    </body>
+    <prolog><keywords><keyword>setup</keyword><keyword>linux</keyword></keywords></prolog>
+  </topic>
+  <topic id="usage">
+    <title>Using</title>
+    <body><p>Open the app.</p></body>
+  </topic>
+  <topic id="empty"><title>Empty</title><body/></topic>
+</map>`
+
+func TestXPath(t *testing.T) {
+	root, err := parseXMLTree([]byte(ditaTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]string{
+		"/map/topic[1]/title":                {"Installing"},
+		"//topic[@id='usage']/title":         {"Using"},
+		"//topic[@audience]/@id":             {"install"},
+		"//topic[title!='Using']/@id":        {"install", "empty"},
+		"//keyword":                          {"setup", "linux"},
+		"//p[1]":                             {"Run the installer as root.", "Open the app."},
+		"//cmd/../../../title | /map/@title": {"Installing", "Guide"},
+		"//topic[2]/body/p/text()":           {"Open the app."},
+		"//topic[9]":                         nil,
+	}
+	for expr, want := range cases {
+		x, err := compileXPath(expr)
+		if err != nil {
+			t.Errorf("compile %s: %v", expr, err)
+			continue
+		}
+		if got := x.strings(root); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %q, want %q", expr, got, want)
+		}
+	}
+	for _, bad := range []string{"", "//topic[", "count(//p)", "//topic[0]", "@", "//p[.='x]"} {
+		if _, err := compileXPath(bad); err == nil {
+			t.Errorf("expected %q not to compile", bad)
+		}
+	}
+}
+
+func TestExtractXML(t *testing.T) {
+	sections, err := extractXML([]byte(ditaTopic), XMLMapping{
+		Records: "//topic",
+		Body:    "body",
+		Fields:  map[string]string{"title": "title", "keywords": "prolog//keyword", "guide": "/map/@title"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("expected the empty topic to be dropped, got %+v", sections)
+	}
+	if sections[0].text != "Run the installer as root.\nThen reboot once." {
+		t.Errorf("unexpected body %q", sections[0].text)
+	}
+	want := map[string]interface{}{xmlRecordKey: 1, "user_title": "Installing", "user_keywords": "setup, linux", "user_guide": "Guide"}
+	if !reflect.DeepEqual(sections[0].metadata, want) {
+		t.Errorf("unexpected metadata %v", sections[0].metadata)
+	}
+	if sections[1].metadata[xmlRecordKey] != 2 || sections[1].metadata["user_keywords"] != nil {
+		t.Errorf("unexpected second record metadata %v", sections[1].metadata)
+	}
+
+	// Without records the whole document is one section
+	sections, err = extractXML([]byte(`<urlset><url><loc>https://a/</loc></url><url><loc>https://b/</loc></url></urlset>`), XMLMapping{Fields: map[string]string{"locs": "//loc"}})
+	if err != nil || len(sections) != 1 || sections[0].metadata["user_locs"] != "https://a/, https://b/" {
+		t.Errorf("unexpected sections %+v, %v", sections, err)
+	}
+
+	if err := (XMLMapping{Fields: map[string]string{"x": "//["}}).Validate(); !errors.Is(err, ErrInvalidXMLMapping) {
+		t.Errorf("expected ErrInvalidXMLMapping, got %v", err)
+	}
+}
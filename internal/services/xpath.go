@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements the subset of XPath 1.0 that XML field mappings use:
+// absolute and relative location paths over the child, descendant ("//"),
+// self ("."), parent ("..") and attribute ("@") axes; name, "*", "text()"
+// and "node()" tests; unions ("|"); and predicates that are a position, an
+// existence test or a comparison of a path with a string literal, e.g.
+// //section[@id='intro']/title or /urlset/url[1]/loc. Namespace prefixes
+// are ignored: names match by local name.
+
+type xnodeKind int
+
+const (
+	xnodeRoot xnodeKind = iota
+	xnodeElement
+	xnodeText
+	xnodeAttr
+)
+
+type xnode struct {
+	kind     xnodeKind
+	name     string // local name of elements and attributes
+	value    string // text and attribute values
+	parent   *xnode
+	attrs    []*xnode
+	children []*xnode
+}
+
+// parseXMLTree reads a document into a node tree. Parsing is lenient about
+// HTML entities and undeclared ones, which DTD-based formats such as DITA
+// and DocBook use.
+func parseXMLTree(content []byte) (*xnode, error) {
+	d := xml.NewDecoder(bytes.NewReader(content))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	// Ingest has already decoded the file to UTF-8, whatever it declares
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	root := &xnode{kind: xnodeRoot}
+	cur := root
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xnode{kind: xnodeElement, name: t.Name.Local, parent: cur}
+			for _, a := range t.Attr {
+				n.attrs = append(n.attrs, &xnode{kind: xnodeAttr, name: a.Name.Local, value: a.Value, parent: n})
+			}
+			cur.children = append(cur.children, n)
+			cur = n
+		case xml.EndElement:
+			if cur.parent != nil {
+				cur = cur.parent
+			}
+		case xml.CharData:
+			cur.children = append(cur.children, &xnode{kind: xnodeText, value: string(t), parent: cur})
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, errors.New("no XML elements")
+	}
+	return root, nil
+}
+
+// stringValue is a node's text. Element text is whitespace-collapsed, with
+// a line break where the markup between elements had one, so block
+// elements stay on separate lines.
+func (n *xnode) stringValue() string {
+	if n.kind == xnodeText || n.kind == xnodeAttr {
+		return n.value
+	}
+	var b strings.Builder
+	newline, space := false, false
+	var walk func(*xnode)
+	walk = func(n *xnode) {
+		for _, c := range n.children {
+			if c.kind == xnodeElement {
+				walk(c)
+				continue
+			}
+			text := strings.Join(strings.Fields(c.value), " ")
+			if text == "" {
+				newline = newline || strings.Contains(c.value, "\n")
+				space = space || c.value != ""
+				continue
+			}
+			if b.Len() > 0 {
+				switch {
+				case newline:
+					b.WriteByte('\n')
+				case space || unicode.IsSpace(rune(c.value[0])):
+					b.WriteByte(' ')
+				}
+			}
+			b.WriteString(text)
+			last := rune(c.value[len(c.value)-1])
+			newline, space = false, unicode.IsSpace(last)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// xpathExpr is a compiled union of location paths.
+type xpathExpr struct {
+	src   string
+	paths []xpathPath
+}
+
+type xpathPath struct {
+	absolute bool
+	steps    []xpathStep
+}
+
+type xpathStep struct {
+	descendant bool   // preceded by "//"
+	axis       string // "child", "self", "parent" or "attribute"
+	test       string // name, "*", "text()" or "node()"
+	preds      []xpathPred
+}
+
+type xpathPred struct {
+	position int // 1-based; 0 when path is used
+	path     *xpathPath
+	op       string // "", "=" or "!="
+	literal  string
+}
+
+// compileXPath parses expr in the supported subset.
+func compileXPath(expr string) (*xpathExpr, error) {
+	p := &xpathParser{toks: tokenizeXPath(expr)}
+	out := &xpathExpr{src: expr}
+	for {
+		path, err := p.path()
+		if err != nil {
+			return nil, fmt.Errorf("xpath %q: %w", expr, err)
+		}
+		out.paths = append(out.paths, path)
+		if !p.accept("|") {
+			break
+		}
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("xpath %q: unexpected %q", expr, p.toks[p.pos])
+	}
+	return out, nil
+}
+
+// tokenizeXPath splits an expression into operators, names, numbers and
+// quoted literals (kept with their quotes).
+func tokenizeXPath(s string) []string {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(s[i:], "//"), strings.HasPrefix(s[i:], ".."), strings.HasPrefix(s[i:], "!="):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.ContainsRune("/[]@|=*()", rune(c)):
+			toks = append(toks, string(c))
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				toks = append(toks, s[i:])
+				return toks
+			}
+			toks = append(toks, s[i:i+end+2])
+			i += end + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n/[]@|=*()'\"!", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks
+}
+
+type xpathParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *xpathParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *xpathParser) accept(tok string) bool {
+	if p.peek() == tok {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) path() (xpathPath, error) {
+	var path xpathPath
+	descendant := false
+	switch {
+	case p.accept("/"):
+		path.absolute = true
+		if p.peek() == "" || p.peek() == "|" || p.peek() == "]" {
+			return path, nil // the root itself
+		}
+	case p.accept("//"):
+		path.absolute, descendant = true, true
+	}
+	for {
+		step, err := p.step()
+		if err != nil {
+			return path, err
+		}
+		step.descendant = descendant
+		path.steps = append(path.steps, step)
+		switch {
+		case p.accept("/"):
+			descendant = false
+		case p.accept("//"):
+			descendant = true
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *xpathParser) step() (xpathStep, error) {
+	var step xpathStep
+	switch tok := p.peek(); {
+	case tok == ".":
+		p.pos++
+		step.axis, step.test = "self", "node()"
+	case tok == "..":
+		p.pos++
+		step.axis, step.test = "parent", "node()"
+	case tok == "@":
+		p.pos++
+		name := p.peek()
+		if name == "" || !isXPathName(name) && name != "*" {
+			return step, errors.New("expected attribute name after @")
+		}
+		p.pos++
+		step.axis, step.test = "attribute", localName(name)
+	case tok == "*":
+		p.pos++
+		step.axis, step.test = "child", "*"
+	case isXPathName(tok):
+		p.pos++
+		step.axis, step.test = "child", localName(tok)
+		if tok == "text" || tok == "node" {
+			if p.accept("(") {
+				if !p.accept(")") {
+					return step, fmt.Errorf("expected ) after %s(", tok)
+				}
+				step.test = tok + "()"
+			}
+		}
+	default:
+		if tok == "" {
+			return step, errors.New("unexpected end of expression")
+		}
+		return step, fmt.Errorf("unexpected %q", tok)
+	}
+	for p.accept("[") {
+		pred, err := p.predicate()
+		if err != nil {
+			return step, err
+		}
+		if !p.accept("]") {
+			return step, errors.New("expected ]")
+		}
+		step.preds = append(step.preds, pred)
+	}
+	return step, nil
+}
+
+func (p *xpathParser) predicate() (xpathPred, error) {
+	if n, err := strconv.Atoi(p.peek()); err == nil {
+		p.pos++
+		if n < 1 {
+			return xpathPred{}, errors.New("positions start at 1")
+		}
+		return xpathPred{position: n}, nil
+	}
+	path, err := p.path()
+	if err != nil {
+		return xpathPred{}, err
+	}
+	pred := xpathPred{path: &path}
+	if op := p.peek(); op == "=" || op == "!=" {
+		p.pos++
+		lit := p.peek()
+		if len(lit) < 2 || (lit[0] != '\'' && lit[0] != '"') || lit[len(lit)-1] != lit[0] {
+			return pred, fmt.Errorf("expected a quoted string after %s", op)
+		}
+		p.pos++
+		pred.op, pred.literal = op, lit[1:len(lit)-1]
+	}
+	return pred, nil
+}
+
+func isXPathName(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	r := rune(tok[0])
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// localName drops a namespace prefix.
+func localName(name string) string {
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// eval returns the nodes expr selects from ctx, without duplicates, in the
+// order they are found.
+func (e *xpathExpr) eval(ctx *xnode) []*xnode {
+	var out []*xnode
+	seen := make(map[*xnode]bool)
+	for _, path := range e.paths {
+		for _, n := range path.eval(ctx) {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	return out
+}
+
+// strings returns the string values of the selected nodes, dropping empty
+// ones.
+func (e *xpathExpr) strings(ctx *xnode) []string {
+	var out []string
+	for _, n := range e.eval(ctx) {
+		if v := strings.TrimSpace(n.stringValue()); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (p xpathPath) eval(ctx *xnode) []*xnode {
+	nodes := []*xnode{ctx}
+	if p.absolute {
+		for nodes[0].parent != nil {
+			nodes[0] = nodes[0].parent
+		}
+	}
+	for _, step := range p.steps {
+		var next []*xnode
+		seen := make(map[*xnode]bool)
+		for _, n := range nodes {
+			for _, m := range step.eval(n) {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (s xpathStep) eval(ctx *xnode) []*xnode {
+	contexts := []*xnode{ctx}
+	if s.descendant {
+		contexts = descendantsOrSelf(ctx)
+	}
+	var out []*xnode
+	for _, c := range contexts {
+		var candidates []*xnode
+		switch s.axis {
+		case "self":
+			candidates = []*xnode{c}
+		case "parent":
+			if c.parent != nil {
+				candidates = []*xnode{c.parent}
+			}
+		case "attribute":
+			candidates = c.attrs
+		default:
+			candidates = c.children
+		}
+		var matched []*xnode
+		for _, n := range candidates {
+			if s.matches(n) {
+				matched = append(matched, n)
+			}
+		}
+		for _, pred := range s.preds {
+			matched = pred.filter(matched)
+		}
+		out = append(out, matched...)
+	}
+	return out
+}
+
+func (s xpathStep) matches(n *xnode) bool {
+	switch s.test {
+	case "node()":
+		return true
+	case "text()":
+		return n.kind == xnodeText
+	case "*":
+		return n.kind == xnodeElement || (s.axis == "attribute" && n.kind == xnodeAttr)
+	default:
+		return (n.kind == xnodeElement || n.kind == xnodeAttr) && n.name == s.test
+	}
+}
+
+func (p xpathPred) filter(nodes []*xnode) []*xnode {
+	if p.position > 0 {
+		if p.position > len(nodes) {
+			return nil
+		}
+		return nodes[p.position-1 : p.position]
+	}
+	var out []*xnode
+	for _, n := range nodes {
+		selected := p.path.eval(n)
+		keep := false
+		switch p.op {
+		case "":
+			keep = len(selected) > 0
+		default:
+			for _, m := range selected {
+				if (strings.TrimSpace(m.stringValue()) == p.literal) == (p.op == "=") {
+					keep = true
+					break
+				}
+			}
+		}
+		if keep {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func descendantsOrSelf(n *xnode) []*xnode {
+	out := []*xnode{n}
+	for _, c := range n.children {
+		if c.kind == xnodeElement {
+			out = append(out, descendantsOrSelf(c)...)
+		}
+	}
+	return out
+}